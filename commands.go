@@ -0,0 +1,325 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/koltyakov/pghealth/internal/analyze"
+	"github.com/koltyakov/pghealth/internal/auth"
+	"github.com/koltyakov/pghealth/internal/collect"
+	"github.com/koltyakov/pghealth/internal/hub"
+	"github.com/koltyakov/pghealth/internal/report"
+	"github.com/koltyakov/pghealth/internal/snapshot"
+)
+
+// subcommands maps the first CLI argument to a handler receiving the remaining
+// arguments. Without a known subcommand pghealth runs a one-shot health check,
+// which is collect, analyze and report in one process.
+var subcommands = map[string]func(args []string) int{
+	"hub":            runHub,
+	"doctor":         runDoctor,
+	"bootstrap-role": runBootstrapRole,
+	"collect":        runCollect,
+	"analyze":        runAnalyze,
+	"report":         runReport,
+}
+
+// dispatchSubcommand runs a subcommand when args names one.
+func dispatchSubcommand(args []string) (int, bool) {
+	if len(args) == 0 {
+		return 0, false
+	}
+	cmd, ok := subcommands[args[0]]
+	if !ok {
+		return 0, false
+	}
+	return cmd(args[1:]), true
+}
+
+// runHub starts the aggregation server that receives snapshots from -post-url.
+func runHub(args []string) int {
+	fs := flag.NewFlagSet("hub", flag.ContinueOnError)
+	var cfg hub.Config
+	fs.StringVar(&cfg.Addr, "addr", hub.DefaultAddr, "HTTP listen address")
+	fs.StringVar(&cfg.DSN, "db", os.Getenv("PGHEALTH_HUB_DB"), "Postgres connection string for snapshot storage (env: PGHEALTH_HUB_DB)")
+	fs.StringVar(&cfg.Secret, "secret", os.Getenv(postSecretEnv), "Require snapshots signed with this HMAC key (env: "+postSecretEnv+")")
+	fs.BoolVar(&cfg.Insecure, "insecure", false, "Accept unsigned snapshots when no -secret is set")
+	if err := fs.Parse(args); err != nil {
+		return exitUsageError
+	}
+	if err := cfg.Validate(); err != nil {
+		log.Printf("invalid configuration: %v", err)
+		return exitUsageError
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := hub.Run(ctx, cfg); err != nil {
+		log.Printf("hub: %v", err)
+		return exitCollectError
+	}
+	return exitSuccess
+}
+
+// runDoctor connects with the current role and reports which collectors will
+// work, together with the GRANTs and extensions that unlock the rest.
+func runDoctor(args []string) int {
+	fs := flag.NewFlagSet("doctor", flag.ContinueOnError)
+	var f Flags
+	fs.StringVar(&f.URL, "url", auth.FirstNonEmpty(os.Getenv("PGURL"), os.Getenv("DATABASE_URL")), "Postgres connection string (env: PGURL or DATABASE_URL)")
+	fs.DurationVar(&f.Timeout, "timeout", defaultTimeout, "Timeout for the checks")
+	fs.StringVar(&f.DBs, "dbs", "", "Comma-separated database names that will be passed to -dbs")
+	addTunnelFlags(fs, &f)
+	if err := fs.Parse(args); err != nil {
+		return exitUsageError
+	}
+	if err := f.resolveURL(); err != nil {
+		log.Printf("url-from: %v", err)
+		return exitCollectError
+	}
+	cfg := f.ToCollectorConfig()
+	if err := cfg.Validate(); err != nil {
+		log.Printf("invalid configuration: %v", err)
+		return exitUsageError
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Timeout)
+	defer cancel()
+
+	if err := f.applyAuth(&cfg); err != nil {
+		log.Printf("auth: %v", err)
+		return exitCollectError
+	}
+	closeTunnel, err := f.openTunnel(ctx, &cfg)
+	if err != nil {
+		log.Printf("tunnel: %v", err)
+		return exitCollectError
+	}
+	defer closeTunnel()
+
+	d, err := collect.Doctor(ctx, cfg)
+	if err != nil {
+		log.Printf("doctor: %v", err)
+		return exitCollectError
+	}
+	if err := d.WriteText(os.Stdout); err != nil {
+		log.Printf("doctor: %v", err)
+		return exitReportError
+	}
+	return exitSuccess
+}
+
+// runBootstrapRole prints (or writes) the SQL script creating a dedicated
+// monitoring role with the minimal privileges pghealth needs.
+func runBootstrapRole(args []string) int {
+	fs := flag.NewFlagSet("bootstrap-role", flag.ContinueOnError)
+	var opts collect.RoleOptions
+	var dbs, selects, out string
+	fs.StringVar(&opts.Role, "role", collect.DefaultRole, "Name of the role to create")
+	fs.StringVar(&dbs, "dbs", "", "Comma-separated databases the role may connect to (required)")
+	fs.StringVar(&opts.PSSSchema, "schema", "public", "Schema of the pg_stat_statements extension")
+	fs.IntVar(&opts.ConnectionLimit, "connection-limit", 3, "Connection limit for the role (0 = unlimited)")
+	fs.StringVar(&selects, "grant-select", "", "Comma-separated schemas whose tables the role may read, so top queries can be EXPLAINed")
+	fs.StringVar(&out, "out", "", "Write the script to this file instead of stdout")
+	if err := fs.Parse(args); err != nil {
+		return exitUsageError
+	}
+	opts.DBs = splitCSV(dbs)
+	opts.SelectSchemas = splitCSV(selects)
+
+	script, err := collect.RoleScript(opts)
+	if err != nil {
+		log.Printf("invalid configuration: %v", err)
+		return exitUsageError
+	}
+	if out == "" {
+		fmt.Print(script)
+		return exitSuccess
+	}
+	if err := os.WriteFile(out, []byte(script), 0o600); err != nil {
+		log.Printf("write role script: %v", err)
+		return exitReportError
+	}
+	fmt.Printf("Role script written to %s\n", out)
+	return exitSuccess
+}
+
+// parseRunFlags parses the health check flags of the collect, analyze and
+// report subcommands; input adds -in, the snapshot to read. ok is false after
+// a usage error, which has been logged.
+func parseRunFlags(name string, args []string, input bool) (f Flags, ok bool) {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	registerFlags(fs, &f)
+	if input {
+		fs.StringVar(&f.Input, "in", "", "Snapshot to read: a JSON document written by collect, analyze or -format json ('-' for stdin)")
+	}
+	if err := fs.Parse(args); err != nil {
+		return Flags{}, false
+	}
+	if input && f.Input == "" {
+		log.Printf("invalid configuration: %s requires -in", name)
+		return Flags{}, false
+	}
+	if f.URL == "" && fs.NArg() >= 1 {
+		f.URL = fs.Arg(0)
+	}
+	if err := f.Validate(); err != nil {
+		log.Printf("invalid configuration: %v", err)
+		return Flags{}, false
+	}
+	// Times are rendered in UTC so output does not depend on the host zone
+	if f.Deterministic {
+		time.Local = time.UTC
+	}
+	return f, true
+}
+
+// runCollect collects the metrics of one database and writes them as a
+// snapshot without findings, to snapshot.json unless -out is set ("-" for
+// stdout). A timed-out run writes what was collected and exits with 2.
+func runCollect(args []string) int {
+	cfg, ok := parseRunFlags("collect", args, false)
+	if !ok {
+		return exitUsageError
+	}
+	if cfg.DryRun {
+		if err := collect.DryRun(os.Stdout, cfg.ToCollectorConfig()); err != nil {
+			log.Printf("dry run: %v", err)
+			return exitReportError
+		}
+		return exitSuccess
+	}
+	if cfg.fleet() {
+		log.Printf("invalid configuration: collect checks a single database: run it once per target")
+		return exitUsageError
+	}
+	targets, err := cfg.fleetTargets()
+	if err != nil {
+		log.Printf("targets: %v", err)
+		return exitUsageError
+	}
+	if len(targets) == 1 {
+		cfg.URL = targets[0].URL
+		cfg.Labels = targets[0].labels(cfg.Labels)
+	}
+	if err := cfg.resolveURL(); err != nil {
+		log.Printf("url-from: %v", err)
+		return exitCollectError
+	}
+
+	res, meta, truncated, code := collectTarget(cfg)
+	if code != exitSuccess {
+		return code
+	}
+	if code := writeSnapshot(cfg.Output, defaultSnapshotFile, res, analyze.Analysis{}, meta); code != exitSuccess {
+		return code
+	}
+	if truncated {
+		return exitCollectError
+	}
+	return exitSuccess
+}
+
+// runAnalyze reads a snapshot from -in, analyzes its result with the current
+// rules, -suppress, -owners, -slo and -archive, and writes the snapshot with
+// the findings, to analysis.json unless -out is set ("-" for stdout).
+func runAnalyze(args []string) int {
+	cfg, ok := parseRunFlags("analyze", args, true)
+	if !ok {
+		return exitUsageError
+	}
+	snap, err := readSnapshot(cfg.Input)
+	if err != nil {
+		log.Printf("read snapshot: %v", err)
+		return exitUsageError
+	}
+	rules, objectives, err := loadAnalysisFiles(cfg)
+	if err != nil {
+		log.Print(err)
+		return exitUsageError
+	}
+
+	res, analysis := analyzeRun(cfg, snap.Result, snap.Meta, rules, objectives)
+	if code := writeSnapshot(cfg.Output, defaultAnalysisFile, res, analysis, snap.Meta); code != exitSuccess {
+		return code
+	}
+	return failOnExitCode(cfg.FailOn, analysis)
+}
+
+// runReport reads a snapshot from -in and writes its report in the -format,
+// with the archive, post, issue and digest outputs of a one-shot run. A
+// snapshot written by collect, without an analysis, is analyzed first, as is
+// an analyzed one when -suppress, -ignore-query, -owners or -slo change what
+// the analysis holds; otherwise it only gets the -runbook-base links.
+func runReport(args []string) int {
+	cfg, ok := parseRunFlags("report", args, true)
+	if !ok {
+		return exitUsageError
+	}
+	snap, err := readSnapshot(cfg.Input)
+	if err != nil {
+		log.Printf("read snapshot: %v", err)
+		return exitUsageError
+	}
+	rules, objectives, err := loadAnalysisFiles(cfg)
+	if err != nil {
+		log.Print(err)
+		return exitUsageError
+	}
+
+	res, analysis := snap.Result, snap.Analysis
+	reanalyze := cfg.Suppress != "" || len(cfg.IgnoreQueries) > 0 || cfg.Owners != "" || cfg.SLO != ""
+	if len(analysis.Summary) == 0 || reanalyze {
+		res, analysis = analyzeRun(cfg, res, snap.Meta, rules, objectives)
+	} else {
+		analysis = analyze.LinkRunbooks(analysis, cfg.Runbook)
+	}
+	return writeRun(cfg, res, analysis, snap.Meta, rules, false)
+}
+
+// readSnapshot decodes the snapshot at path; "-" reads stdin.
+func readSnapshot(path string) (snapshot.Snapshot, error) {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return snapshot.Snapshot{}, err
+		}
+		defer f.Close()
+		r = f
+	}
+	snap, err := snapshot.Decode(r)
+	if err != nil {
+		return snapshot.Snapshot{}, err
+	}
+	if snap.Schema == 0 {
+		return snapshot.Snapshot{}, errors.New("not a pghealth snapshot: schema is missing")
+	}
+	return snap, nil
+}
+
+// writeSnapshot writes the snapshot document of a subcommand to out, or to
+// def when -out is left at its default.
+func writeSnapshot(out, def string, res collect.Result, a analyze.Analysis, meta collect.Meta) int {
+	if out == "" || out == defaultOutputFile {
+		out = def
+	}
+	out = expandOutPlaceholders(out, meta.StartedAt)
+	if err := report.WriteJSON(out, res, a, meta); err != nil {
+		log.Printf("failed to write snapshot: %v", err)
+		return exitReportError
+	}
+	if out != "-" {
+		fmt.Printf("Snapshot written to %s\n", out)
+	}
+	return exitSuccess
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/koltyakov/pghealth/internal/analyze"
+	"github.com/koltyakov/pghealth/internal/collect"
+	"github.com/koltyakov/pghealth/internal/report"
+)
+
+// TestAnalyzeReportSubcommands verifies a collected snapshot goes through the
+// analyze and report subcommands without a database.
+func TestAnalyzeReportSubcommands(t *testing.T) {
+	dir := t.TempDir()
+	collected := filepath.Join(dir, "snapshot.json")
+	analyzed := filepath.Join(dir, "analysis.json")
+	html := filepath.Join(dir, "report.html")
+
+	var res collect.Result
+	res.ConnInfo.CurrentDB = "app"
+	res.ConnInfo.Version = "PostgreSQL 16.2"
+	meta := collect.Meta{Version: "test", Target: "db.example:5432/app"}
+	if err := report.WriteJSON(collected, res, analyze.Analysis{}, meta); err != nil {
+		t.Fatalf("WriteJSON() error = %v", err)
+	}
+
+	if code := runAnalyze([]string{"-in", collected, "-out", analyzed}); code != exitSuccess {
+		t.Fatalf("analyze exit code = %d", code)
+	}
+	snap, err := readSnapshot(analyzed)
+	if err != nil {
+		t.Fatalf("readSnapshot() error = %v", err)
+	}
+	if len(snap.Analysis.Summary) == 0 || snap.Meta.Target != meta.Target {
+		t.Errorf("analysis not written: summary %v, target %q", snap.Analysis.Summary, snap.Meta.Target)
+	}
+
+	if code := runReport([]string{"-in", analyzed, "-out", html, "-open=false"}); code != exitSuccess {
+		t.Fatalf("report exit code = %d", code)
+	}
+	b, err := os.ReadFile(html)
+	if err != nil {
+		t.Fatalf("report not written: %v", err)
+	}
+	if !strings.Contains(string(b), snap.Analysis.Summary[0]) {
+		t.Error("report does not render the analyzed snapshot")
+	}
+}
+
+// TestSubcommandUsage verifies analyze and report require -in and collect a
+// database.
+func TestSubcommandUsage(t *testing.T) {
+	t.Setenv("PGURL", "")
+	t.Setenv("DATABASE_URL", "")
+	for name, run := range map[string]func([]string) int{"collect": runCollect, "analyze": runAnalyze, "report": runReport} {
+		if code := run(nil); code != exitUsageError {
+			t.Errorf("%s without arguments: exit code = %d, want %d", name, code, exitUsageError)
+		}
+	}
+	if code := runReport([]string{"-in", filepath.Join(t.TempDir(), "missing.json")}); code != exitUsageError {
+		t.Errorf("report of a missing snapshot: exit code = %d, want %d", code, exitUsageError)
+	}
+}
+
+// TestReportBeforeSideOutputs verifies a failing snapshot post still leaves
+// the report written and fails the run.
+func TestReportBeforeSideOutputs(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	in := filepath.Join(dir, "snapshot.json")
+	html := filepath.Join(dir, "report.html")
+	if err := report.WriteJSON(in, collect.Result{}, analyze.Analysis{}, collect.Meta{Version: "test"}); err != nil {
+		t.Fatalf("WriteJSON() error = %v", err)
+	}
+	if code := runReport([]string{"-in", in, "-out", html, "-open=false", "-post-url", srv.URL}); code != exitReportError {
+		t.Errorf("exit code = %d, want %d", code, exitReportError)
+	}
+	if _, err := os.Stat(html); err != nil {
+		t.Errorf("report not written before the failed post: %v", err)
+	}
+}
+
+// TestDeterministicStripsOnlyReport verifies -deterministic leaves the clock
+// out of the rendered report only: the output is named from the real start
+// time and the posted snapshot keeps its timings.
+func TestDeterministicStripsOnlyReport(t *testing.T) {
+	var posted []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		posted, _ = io.ReadAll(r.Body)
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	in := filepath.Join(dir, "snapshot.json")
+	started := time.Date(2024, 8, 30, 14, 25, 0, 0, time.UTC)
+	res := collect.Result{Timings: []collect.CollectorTiming{{Name: "tables", Duration: time.Second}}}
+	meta := collect.Meta{Version: "test", StartedAt: started}
+	if err := report.WriteJSON(in, res, analyze.Analysis{}, meta); err != nil {
+		t.Fatalf("WriteJSON() error = %v", err)
+	}
+	out := filepath.Join(dir, "report_{ts}.json")
+	if code := runReport([]string{"-in", in, "-format", "json", "-out", out, "-deterministic", "-post-url", srv.URL}); code != exitSuccess {
+		t.Fatalf("exit code = %d", code)
+	}
+
+	rendered, err := readSnapshot(filepath.Join(dir, "report_2024-08-30_1425.json"))
+	if err != nil {
+		t.Fatalf("report not named from the start time: %v", err)
+	}
+	if len(rendered.Result.Timings) != 0 || !rendered.Meta.StartedAt.IsZero() {
+		t.Errorf("rendered report kept the clock: timings %v, started %v", rendered.Result.Timings, rendered.Meta.StartedAt)
+	}
+	if !strings.Contains(string(posted), "tables") {
+		t.Errorf("posted snapshot lost its timings: %s", posted)
+	}
+}
+
+// TestReportReanalyzesWithSuppress verifies report applies -suppress to an
+// already analyzed snapshot rather than rendering its stored findings.
+func TestReportReanalyzesWithSuppress(t *testing.T) {
+	dir := t.TempDir()
+	in := filepath.Join(dir, "analysis.json")
+	out := filepath.Join(dir, "report.json")
+	analysis := analyze.Analysis{
+		Summary:         []string{"One finding."},
+		Recommendations: []analyze.Finding{{Code: "stale-finding", Title: "Stale finding"}},
+	}
+	if err := report.WriteJSON(in, collect.Result{}, analysis, collect.Meta{Version: "test"}); err != nil {
+		t.Fatalf("WriteJSON() error = %v", err)
+	}
+	if code := runReport([]string{"-in", in, "-format", "json", "-out", out, "-suppress", "stale-finding"}); code != exitSuccess {
+		t.Fatalf("exit code = %d", code)
+	}
+	snap, err := readSnapshot(out)
+	if err != nil {
+		t.Fatalf("readSnapshot() error = %v", err)
+	}
+	for _, f := range snap.Analysis.Recommendations {
+		if f.Code == "stale-finding" {
+			t.Errorf("suppressed finding still reported")
+		}
+	}
+}
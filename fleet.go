@@ -0,0 +1,252 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/jackc/pgservicefile"
+	"gopkg.in/yaml.v3"
+
+	"github.com/koltyakov/pghealth/internal/auth"
+	"github.com/koltyakov/pghealth/internal/collect"
+)
+
+// targetPlaceholder is replaced with the target name in fleet output paths.
+const targetPlaceholder = "{target}"
+
+// target is one database checked in fleet mode.
+type target struct {
+	Name    string `yaml:"name"`
+	URL     string `yaml:"url"`
+	Service string `yaml:"service"` // pg_service.conf section, used when URL is empty
+
+	Labels map[string]string `yaml:"labels"` // added to the -label flags
+}
+
+// targetsFile is the -targets YAML document:
+//
+//	targets:
+//	  - name: orders
+//	    url: postgres://pghealth@orders-db:5432/orders
+//	  - service: billing-prod
+//	    labels: {team: payments}
+type targetsFile struct {
+	Targets []target `yaml:"targets"`
+}
+
+// fleetTargets returns the databases named by -service and -targets, or nil
+// when neither is set and -url is checked alone.
+func (f Flags) fleetTargets() ([]target, error) {
+	var out []target
+	if f.Service != "" {
+		ts, err := serviceTargets(f.Service)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, ts...)
+	}
+	if f.Targets != "" {
+		ts, err := loadTargets(f.Targets)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, ts...)
+	}
+	seen := map[string]bool{}
+	for _, t := range out {
+		if seen[t.Name] {
+			return nil, fmt.Errorf("duplicate target name %q", t.Name)
+		}
+		seen[t.Name] = true
+	}
+	return out, nil
+}
+
+// fleet reports whether the flags may name more than one database.
+func (f Flags) fleet() bool {
+	return f.Targets != "" || strings.ContainsAny(f.Service, ",*?[")
+}
+
+// runFleet checks each target in turn with the shared flags, writing one
+// report per target. It returns the first non-zero exit code, after all
+// targets were checked.
+func runFleet(cfg Flags, targets []target) int {
+	code := exitSuccess
+	failed := 0
+	for i, t := range targets {
+		fmt.Printf("Checking %s (%d/%d)\n", t.Name, i+1, len(targets))
+		tc := cfg
+		tc.URL = t.URL
+		tc.Labels = t.labels(cfg.Labels)
+		if tc.Format != formatGitHubSummary {
+			tc.Output = targetOutputPath(cfg.Output, t.Name)
+		}
+		if cfg.Digest != "" && cfg.Digest != "-" {
+			tc.Digest = targetOutputPath(cfg.Digest, t.Name)
+		}
+		if c := runTarget(tc); c != exitSuccess {
+			log.Printf("target %s: exit code %d", t.Name, c)
+			failed++
+			if code == exitSuccess {
+				code = c
+			}
+		}
+	}
+	if failed > 0 {
+		log.Printf("%d of %d targets did not pass", failed, len(targets))
+	}
+	return code
+}
+
+// labels merges the target's labels over the -label flags.
+func (t target) labels(flags labelFlag) labelFlag {
+	if len(t.Labels) == 0 {
+		return flags
+	}
+	out := labelFlag{}
+	for k, v := range flags {
+		out[k] = v
+	}
+	for k, v := range t.Labels {
+		out[k] = v
+	}
+	return out
+}
+
+// loadTargets reads a targets YAML file. Entries without a name are named
+// after their service or connection target.
+func loadTargets(file string) ([]target, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("read targets: %w", err)
+	}
+	var tf targetsFile
+	if err := yaml.Unmarshal(b, &tf); err != nil {
+		return nil, fmt.Errorf("parse targets %s: %w", file, err)
+	}
+	if len(tf.Targets) == 0 {
+		return nil, fmt.Errorf("%s lists no targets", file)
+	}
+	out := make([]target, 0, len(tf.Targets))
+	for i, t := range tf.Targets {
+		switch {
+		case t.URL != "" && t.Service != "":
+			return nil, fmt.Errorf("%s: target %d sets both url and service", file, i+1)
+		case t.Service != "":
+			t.URL = serviceURL(t.Service)
+		case t.URL == "":
+			return nil, fmt.Errorf("%s: target %d needs a url or service", file, i+1)
+		}
+		if t.Name == "" {
+			t.Name = auth.FirstNonEmpty(t.Service, collect.TargetName(t.URL))
+		}
+		for k := range t.Labels {
+			if err := validateLabelName(k); err != nil {
+				return nil, fmt.Errorf("%s: target %s: %w", file, t.Name, err)
+			}
+		}
+		out = append(out, t)
+	}
+	return out, nil
+}
+
+// serviceTargets expands a comma-separated list of pg_service.conf sections.
+// Names with glob characters (e.g. prod-*) match every section of the
+// service file; plain names are left to libpq resolution when connecting.
+func serviceTargets(spec string) ([]target, error) {
+	var sections []string
+	var out []target
+	for _, name := range strings.Split(spec, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if !strings.ContainsAny(name, "*?[") {
+			out = append(out, target{Name: name, Service: name, URL: serviceURL(name)})
+			continue
+		}
+		if sections == nil {
+			var err error
+			if sections, err = serviceSections(); err != nil {
+				return nil, err
+			}
+		}
+		matched := false
+		for _, s := range sections {
+			if ok, _ := path.Match(name, s); ok {
+				out = append(out, target{Name: s, Service: s, URL: serviceURL(s)})
+				matched = true
+			}
+		}
+		if !matched {
+			return nil, fmt.Errorf("no service in %s matches %q", serviceFilePath(), name)
+		}
+	}
+	return out, nil
+}
+
+// serviceSections lists the sections of the service file, sorted.
+func serviceSections() ([]string, error) {
+	file := serviceFilePath()
+	sf, err := pgservicefile.ReadServicefile(file)
+	if err != nil {
+		return nil, fmt.Errorf("read service file: %w", err)
+	}
+	names := make([]string, 0, len(sf.Services))
+	for _, s := range sf.Services {
+		names = append(names, s.Name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// serviceFilePath is the service file libpq reads: PGSERVICEFILE, else
+// ~/.pg_service.conf, else pg_service.conf in PGSYSCONFDIR.
+func serviceFilePath() string {
+	if p := os.Getenv("PGSERVICEFILE"); p != "" {
+		return p
+	}
+	home, _ := os.UserHomeDir()
+	p := filepath.Join(home, ".pg_service.conf")
+	if dir := os.Getenv("PGSYSCONFDIR"); dir != "" {
+		if _, err := os.Stat(p); errors.Is(err, os.ErrNotExist) {
+			return filepath.Join(dir, "pg_service.conf")
+		}
+	}
+	return p
+}
+
+// serviceURL is the connection string selecting a service. The service file
+// is named explicitly as the driver does not consult PGSYSCONFDIR; passwords
+// not in it are read from ~/.pgpass (PGPASSFILE) when connecting.
+func serviceURL(name string) string {
+	return "service=" + quoteDSNValue(name) + " servicefile=" + quoteDSNValue(serviceFilePath())
+}
+
+// quoteDSNValue quotes a keyword/value connection string value when needed.
+func quoteDSNValue(v string) string {
+	if v != "" && !strings.ContainsAny(v, ` '\`) {
+		return v
+	}
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(v) + "'"
+}
+
+// targetOutputPath names a fleet target's report: {target} in the -out path
+// is replaced, otherwise the name is appended before the extension.
+func targetOutputPath(out, name string) string {
+	if out == "" || out == "-" {
+		out = defaultOutputFile
+	}
+	name = slugify(name)
+	if strings.Contains(out, targetPlaceholder) {
+		return strings.ReplaceAll(out, targetPlaceholder, name)
+	}
+	ext := filepath.Ext(out)
+	return strings.TrimSuffix(out, ext) + "-" + name + ext
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// TestLoadTargets verifies target entries with URLs and services, default
+// names and invalid entries.
+func TestLoadTargets(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("PGSERVICEFILE", filepath.Join(dir, "pg_service.conf"))
+	write := func(name, body string) string {
+		p := filepath.Join(dir, name)
+		if err := os.WriteFile(p, []byte(body), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+
+	got, err := loadTargets(write("ok.yaml", `
+targets:
+  - name: orders
+    url: postgres://pghealth@orders-db:5432/orders
+  - url: postgres://billing-db/billing
+  - service: analytics
+`))
+	if err != nil {
+		t.Fatalf("loadTargets: %v", err)
+	}
+	want := []target{
+		{Name: "orders", URL: "postgres://pghealth@orders-db:5432/orders"},
+		{Name: "billing-db:5432/billing", URL: "postgres://billing-db/billing"},
+		{Name: "analytics", Service: "analytics", URL: "service=analytics servicefile=" + filepath.Join(dir, "pg_service.conf")},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("loadTargets = %+v, want %+v", got, want)
+	}
+
+	for name, body := range map[string]string{
+		"empty.yaml":   "targets: []\n",
+		"both.yaml":    "targets:\n  - url: postgres://a/b\n    service: x\n",
+		"neither.yaml": "targets:\n  - name: x\n",
+		"bad.yaml":     "targets: [\n",
+	} {
+		if _, err := loadTargets(write(name, body)); err == nil {
+			t.Errorf("loadTargets(%s): want an error", name)
+		}
+	}
+}
+
+// TestServiceTargets verifies service lists and glob expansion over the
+// sections of the service file.
+func TestServiceTargets(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "pg service.conf")
+	t.Setenv("PGSERVICEFILE", file)
+	conf := "[prod-orders]\nhost=orders-db\ndbname=orders\n\n[prod-billing]\nhost=billing-db\n\n[staging]\nhost=staging-db\n"
+	if err := os.WriteFile(file, []byte(conf), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := serviceTargets("prod-*, staging")
+	if err != nil {
+		t.Fatalf("serviceTargets: %v", err)
+	}
+	var names []string
+	for _, tg := range got {
+		names = append(names, tg.Name)
+	}
+	if want := []string{"prod-billing", "prod-orders", "staging"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("service names = %v, want %v", names, want)
+	}
+	if want := "service=staging servicefile='" + file + "'"; got[2].URL != want {
+		t.Errorf("URL = %q, want %q", got[2].URL, want)
+	}
+	if _, err := serviceTargets("dev-*"); err == nil {
+		t.Error("serviceTargets(dev-*): want an error for no match")
+	}
+}
+
+// TestTargetOutputPath verifies per-target report names.
+func TestTargetOutputPath(t *testing.T) {
+	tests := []struct{ out, name, want string }{
+		{"", "orders", "report-orders.html"},
+		{"reports/{target}/{ts}.html", "Prod Orders", "reports/prod-orders/{ts}.html"},
+		{"out/health.html", "billing", "out/health-billing.html"},
+	}
+	for _, tt := range tests {
+		if got := targetOutputPath(tt.out, tt.name); got != tt.want {
+			t.Errorf("targetOutputPath(%q, %q) = %q, want %q", tt.out, tt.name, got, tt.want)
+		}
+	}
+}
@@ -9,6 +9,10 @@ package analyze
 
 import (
 	"fmt"
+	"math"
+	"net/url"
+	"regexp"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
@@ -18,6 +22,7 @@ import (
 	"golang.org/x/text/language"
 
 	"github.com/koltyakov/pghealth/internal/collect"
+	"github.com/koltyakov/pghealth/internal/sqlparse"
 )
 
 // Severity levels for findings.
@@ -27,6 +32,13 @@ const (
 	SeverityRec     = "rec"  // Recommendation for improvement
 )
 
+// Levels of Finding.Confidence and Finding.EstimatedImpact.
+const (
+	LevelHigh   = "high"
+	LevelMedium = "medium"
+	LevelLow    = "low"
+)
+
 // Threshold constants for analysis heuristics.
 // These values are based on PostgreSQL best practices and can be tuned.
 const (
@@ -75,6 +87,19 @@ const (
 	// xidCriticalPct triggers a critical warning when XID age exceeds this.
 	xidCriticalPct = 75.0
 
+	// xidForecastWindow is how far ahead reaching xidCriticalPct is warned about.
+	xidForecastWindow = 30 * 24 * time.Hour
+
+	// clockSkewThreshold is the server clock skew against the machine running
+	// pghealth that misaligns incident timelines.
+	clockSkewThreshold = 10 * time.Second
+
+	// freezeForecastWindow is how far ahead anti-wraparound autovacuums are forecast.
+	freezeForecastWindow = 7 * 24 * time.Hour
+
+	// freezeForecastMinBytes is the table size from which a forced freeze is worth scheduling.
+	freezeForecastMinBytes = 1 << 30 // 1GB
+
 	// idleInTransactionMinutes is the minimum idle-in-transaction duration to flag.
 	idleInTransactionMinutes = 5
 
@@ -89,36 +114,553 @@ const (
 
 	// preparedXactAgeHours is the age in hours for a prepared transaction to be flagged.
 	preparedXactAgeHours = 1
+
+	// loadConcentrationShare is the execution time share that attributes load to a single role.
+	loadConcentrationShare = 0.5
+
+	// nPlusOneMinCallsPerHour is the call rate of a single-row lookup pattern
+	// that suggests an ORM N+1 loop.
+	nPlusOneMinCallsPerHour = 10000
+
+	// nPlusOneMaxRowsPerCall is the rows per call at most returned by an N+1 lookup.
+	nPlusOneMaxRowsPerCall = 1.5
+
+	// tailLatencyFactor is the estimated p99 to mean execution time ratio that
+	// marks a query whose mean hides a slow tail.
+	tailLatencyFactor = 5.0
+
+	// tailLatencyMinMs is the estimated p99 execution time below which tails
+	// are not reported.
+	tailLatencyMinMs = 100.0
+
+	// autoExplainMinMs is the smallest auto_explain.log_min_duration advised;
+	// no advice is given unless a top statement's p99 reaches it.
+	autoExplainMinMs = 100.0
+
+	// autoExplainPlansPerHour is the plan volume auto_explain is sampled down to.
+	autoExplainPlansPerHour = 600.0
+
+	// autoExplainNoisyFactor is how far below the advised threshold a
+	// configured log_min_duration is reported as logging too many plans.
+	autoExplainNoisyFactor = 10.0
+
+	// tablespaceMinBytes is the size from which a busy table or index is worth
+	// moving to another tablespace.
+	tablespaceMinBytes = 1 << 30
+
+	// tablespaceIdleBytes is the size below which a tablespace counts as unused.
+	tablespaceIdleBytes = 100 << 20
+
+	// tablespaceMaxMoves caps the relations suggested for a move.
+	tablespaceMaxMoves = 5
+
+	// subtransSLRUMinReads is the Subtrans SLRU page reads needed to judge its hit ratio.
+	subtransSLRUMinReads = 1000
+
+	// subtransSLRUMinHitRatio is the Subtrans SLRU hit ratio below which lookups thrash.
+	subtransSLRUMinHitRatio = 0.9
+
+	// vacuumHorizonMinAge is the xmin age (in transactions) of a standby or
+	// slot that noticeably holds back dead tuple cleanup.
+	vacuumHorizonMinAge = 1000000
+
+	// hugePagesMinSharedBuffers is the shared_buffers size from which huge
+	// pages noticeably cut page table memory and TLB misses.
+	hugePagesMinSharedBuffers = 4 << 30
+
+	// patroniRecentFailover is how far back failovers are reported.
+	patroniRecentFailover = 7 * 24 * time.Hour
+
+	// haStaleReport is the age of the latest repmgrd monitoring sample or
+	// pg_auto_failover keeper report after which a node counts as unmonitored.
+	haStaleReport = 5 * time.Minute
+
+	// churnMinSessions is the sessions a database needs before its churn is judged.
+	churnMinSessions = 1000
+
+	// churnMinPerHour is the session rate from which short sessions are churn.
+	churnMinPerHour = 360
+
+	// churnMaxSessionMs is the mean session lifetime below which connections
+	// are opened per request rather than kept.
+	churnMaxSessionMs = 10000.0
+
+	// sessionSetupMs is the estimated server cost of a new session: backend
+	// fork, authentication and catalog cache warm-up (more with TLS).
+	sessionSetupMs = 5.0
+
+	// sessionErrorPct is the share of abandoned, fatal and killed sessions
+	// worth mentioning.
+	sessionErrorPct = 1.0
+
+	// workloadAnalyticalPct is the share of the top statements' time in
+	// analytical statements from which the workload counts as analytical.
+	workloadAnalyticalPct = 70.0
+
+	// workloadOLTPPct is the share below which the workload counts as OLTP.
+	workloadOLTPPct = 30.0
+
+	// workloadMinStatements is the statements needed for a classification.
+	workloadMinStatements = 5
+
+	// analyticalMeanMs is the mean execution time of an analytical statement.
+	analyticalMeanMs = 1000.0
+
+	// analyticalRowsPerCall is the rows per call of an analytical statement.
+	analyticalRowsPerCall = 10000.0
+
+	// analyticalTempBlksPerCall is the temp blocks (8 kB) an analytical
+	// statement writes per call.
+	analyticalTempBlksPerCall = 128.0
+
+	// analyticalShapeMs is the mean execution time from which a read with a
+	// reporting shape counts as analytical.
+	analyticalShapeMs = 100.0
+
+	// analyticalShapeJoins is the joins of a reporting-shaped read.
+	analyticalShapeJoins = 3
+
+	// oltpMaxGather is the max_parallel_workers_per_gather an OLTP workload
+	// is advised to stay within.
+	oltpMaxGather = 2
+
+	// poolerMinBackends is the client backends needed to recognize pooling by shape.
+	poolerMinBackends = 10
+
+	// poolerMaxHosts is the most client hosts holding the bulk of the
+	// backends for them to look like a pooler's server connections.
+	poolerMaxHosts = 3
+
+	// poolerHostShare is the share of client backends those hosts must hold.
+	poolerHostShare = 0.8
+
+	// poolerMinAge is the mean backend age of pooled server connections.
+	poolerMinAge = 30 * time.Minute
+
+	// storageMinHistoryDays is the time archived sizes must span before
+	// their growth is extrapolated.
+	storageMinHistoryDays = 1.0
+
+	// storageFullWarnDays is the forecast days until the disk fills that
+	// raise a warning.
+	storageFullWarnDays = 30.0
+
+	// storageFullRecDays is the forecast days until the disk fills that
+	// raise a recommendation.
+	storageFullRecDays = 90.0
+
+	// storageMaxItems caps the databases and tables named as growing fastest.
+	storageMaxItems = 5
+
+	// connMinHistoryDays is the time archived connection counts must span
+	// before their trend is extrapolated.
+	connMinHistoryDays = 1.0
+
+	// connLimitWarnDays is the forecast days until peak connections reach
+	// max_connections that raise a warning.
+	connLimitWarnDays = 30.0
+
+	// connLimitRecDays is the forecast days until peak connections reach
+	// max_connections that raise a recommendation.
+	connLimitRecDays = 90.0
+
+	// connPoolHeadroom scales an application's busy peak into a pool size.
+	connPoolHeadroom = 1.5
+
+	// connMaxApps caps the applications listed with their peaks and pool sizes.
+	connMaxApps = 5
+
+	// trendCacheHitDrop is the fall of the cache hit ratio below its median
+	// over the earlier runs, in percentage points, that raises a finding.
+	trendCacheHitDrop = 1.0
+
+	// trendRegressionFactor and trendRegressionMinMs are how many times, and
+	// by how much, a statement's mean time must exceed its median over the
+	// earlier runs to count as regressed; trendRegressionMinCalls leaves out
+	// rarely called statements.
+	trendRegressionFactor   = 2.0
+	trendRegressionMinMs    = 5.0
+	trendRegressionMinCalls = 100
+
+	// trendGrowthPct and trendGrowthMinBytes are the growth of a table or
+	// index over the fitted runs that raises a finding.
+	trendGrowthPct      = 50.0
+	trendGrowthMinBytes = 1 << 30
+
+	// trendConnGrowthPct and trendConnGrowthMin are the growth of the client
+	// connections over the runs that raises a finding.
+	trendConnGrowthPct = 50.0
+	trendConnGrowthMin = 20
+
+	// trendMaxItems caps the statements and relations listed per trend.
+	trendMaxItems = 5
+
+	// skewHotFreq is the fraction of rows holding one value (or NULL) at which
+	// a filter or join column counts as skewed.
+	skewHotFreq = 0.5
+
+	// skewPartitionBytes is the table size from which a skewed column with few
+	// distinct values is suggested as a partition key.
+	skewPartitionBytes = 10 << 30
+
+	// skewPartitionMaxDistinct is the most distinct values a skewed column may
+	// have to be suggested for LIST partitioning.
+	skewPartitionMaxDistinct = 100
+
+	// skewMaxColumns caps the skewed columns listed.
+	skewMaxColumns = 5
+
+	// retentionAgeDays is the row age from which data counts as old enough
+	// for a retention policy or archival.
+	retentionAgeDays = 365
+
+	// retentionAppendOnlyPct is the most updates and deletes, as a share of
+	// the inserts, of a table counted as append-only.
+	retentionAppendOnlyPct = 5.0
+
+	// retentionMinOldBytes is the space old rows must take to be worth a
+	// retention finding.
+	retentionMinOldBytes = 1 << 30
+
+	// retentionMaxTables caps the tables listed as retention candidates.
+	retentionMaxTables = 5
+
+	// clientMaxListed caps the applications listed by the client library
+	// findings.
+	clientMaxListed = 5
+
+	// idleTxTimeoutDefault is the idle_in_transaction_session_timeout
+	// suggested for roles seen without transactions sitting idle.
+	idleTxTimeoutDefault = 5 * time.Minute
+
+	// idleTxTimeoutMax caps the suggested idle_in_transaction_session_timeout;
+	// transactions idle for longer are taken as abandoned rather than as the
+	// application's normal pace.
+	idleTxTimeoutMax = 30 * time.Minute
+
+	// idleSessionMinAge is the idle time from which sessions look leaked: a
+	// role with sessions idle for longer gets an idle_session_timeout.
+	idleSessionMinAge = time.Hour
+
+	// idleSessionTimeoutMin bounds the suggested idle_session_timeout from
+	// below; it is four times the role's median idle time, up to
+	// idleSessionMinAge.
+	idleSessionTimeoutMin = 15 * time.Minute
+
+	// adhocConnLimit and adhocStatementTimeout are the safeguards suggested
+	// for login roles that are not used by applications.
+	adhocConnLimit        = 5
+	adhocStatementTimeout = "5min"
+
+	// defaultHugePageSize is the x86-64 huge page size, assumed when neither
+	// the server nor the OS reports one.
+	defaultHugePageSize = 2 << 20
 )
 
 // Analysis contains categorized findings from the metrics analysis.
 type Analysis struct {
 	// Recommendations are suggested improvements that would benefit performance.
-	Recommendations []Finding
+	Recommendations []Finding `json:"Recommendations"`
 
 	// Warnings are issues that need attention and may impact availability.
-	Warnings []Finding
+	Warnings []Finding `json:"Warnings"`
 
 	// Infos are informational observations about the database state.
-	Infos []Finding
+	Infos []Finding `json:"Infos"`
+
+	// Workload is the class of the workload the configuration advice is
+	// tailored to; its Class is empty when unknown.
+	Workload Workload `json:"Workload"`
+
+	// Tuning holds the SQL statements of the configuration advice, rendered
+	// by the report as a tuning script.
+	Tuning []string `json:"Tuning,omitempty"`
+
+	// Summary is the plain-language executive summary set by Summarize:
+	// grade, biggest risks, quick wins, largest project and trend.
+	Summary []string `json:"Summary,omitempty"`
+}
+
+// Workload classes.
+const (
+	WorkloadOLTP       = "oltp"       // short, selective queries and writes
+	WorkloadAnalytical = "analytical" // long scans, aggregates and reports
+	WorkloadMixed      = "mixed"      // both in significant shares
+)
+
+// Workload classifies the top statements by total time. A statement counts as
+// analytical when it runs long, returns many rows per call, spills to temp
+// files, runs a parallel plan or reads with a reporting shape (many joins or
+// no filter, without LIMIT) for a noticeable time.
+type Workload struct {
+	Class         string  `json:"Class"`         // WorkloadOLTP, WorkloadAnalytical or WorkloadMixed; empty when unknown
+	Statements    int     `json:"Statements"`    // statements classified
+	AnalyticalPct float64 `json:"AnalyticalPct"` // share of their execution time in analytical statements
+	RowsPerCall   float64 `json:"RowsPerCall"`   // rows per call over all of them
+	TempPct       float64 `json:"TempPct"`       // share of their execution time in statements spilling to temp files
+	ParallelPct   float64 `json:"ParallelPct"`   // share of their execution time in statements with parallel plans
+}
+
+// Label names the class for reports, e.g. "OLTP".
+func (w Workload) Label() string {
+	switch w.Class {
+	case WorkloadOLTP:
+		return "OLTP"
+	case WorkloadAnalytical:
+		return "analytical"
+	case WorkloadMixed:
+		return "mixed OLTP and analytical"
+	}
+	return ""
+}
+
+// advice returns the advice for the workload class: oltp or analytical, or
+// generic when the workload is mixed or unknown.
+func (w Workload) advice(generic, oltp, analytical string) string {
+	switch w.Class {
+	case WorkloadOLTP:
+		return oltp
+	case WorkloadAnalytical:
+		return analytical
+	}
+	return generic
 }
 
 // Finding represents a single analysis finding with its details.
 type Finding struct {
 	// Title is a short descriptive name for the finding.
-	Title string
+	Title string `json:"Title"`
 
 	// Severity indicates the importance level (info, warn, rec).
-	Severity string
+	Severity string `json:"Severity"`
 
 	// Code is a machine-readable identifier for suppression support.
-	Code string
+	Code string `json:"Code"`
 
 	// Description provides details about what was found.
-	Description string
+	Description string `json:"Description"`
 
 	// Action suggests what steps to take to address the finding.
-	Action string
+	Action string `json:"Action"`
+
+	// Owners are the teams the finding is assigned to by -owners rules.
+	Owners []string `json:"Owners,omitempty"`
+
+	// Evidence is the data the finding is based on, nil when it has none
+	// beyond the description.
+	Evidence *Evidence `json:"Evidence,omitempty"`
+
+	// Confidence is how far the data behind a warning or recommendation can
+	// be trusted (LevelHigh, LevelMedium or LevelLow), lowered by young
+	// statistics, few calls, missing plans and stale planner statistics;
+	// ConfidenceNote names what lowered it.
+	Confidence     string `json:"Confidence,omitempty"`
+	ConfidenceNote string `json:"ConfidenceNote,omitempty"`
+
+	// EstimatedImpact is the expected benefit of acting on a warning or
+	// recommendation: the share of query time or the size of the objects
+	// involved, else the severity.
+	EstimatedImpact string `json:"EstimatedImpact,omitempty"`
+
+	// Effort is the work acting on a warning or recommendation takes:
+	// LevelLow for a configuration change, LevelMedium for an index build
+	// or maintenance, LevelHigh for a query or schema refactor; EffortKind
+	// names which.
+	Effort     string `json:"Effort,omitempty"`
+	EffortKind string `json:"EffortKind,omitempty"`
+
+	// Docs is the public documentation of the finding's code (PostgreSQL,
+	// or the project of an extension or HA tool), empty when none applies.
+	Docs string `json:"Docs,omitempty"`
+
+	// Runbook is the internal runbook of the code, set by LinkRunbooks.
+	Runbook string `json:"Runbook,omitempty"`
+}
+
+// Quadrants of the effort/impact matrix; see Finding.Quadrant.
+const (
+	QuadrantQuickWins = "quick-wins"     // medium or high impact, low effort
+	QuadrantMajor     = "major-projects" // medium or high impact, more effort
+	QuadrantLowValue  = "low-value"      // low impact
+)
+
+// Quadrant places a rated finding in the effort/impact matrix; empty for
+// findings without an impact (infos).
+func (f Finding) Quadrant() string {
+	switch {
+	case f.EstimatedImpact == "":
+		return ""
+	case f.EstimatedImpact == LevelLow:
+		return QuadrantLowValue
+	case f.Effort == LevelLow:
+		return QuadrantQuickWins
+	}
+	return QuadrantMajor
+}
+
+// Evidence is the raw data behind a finding, so consumers can reason about it
+// without parsing the description.
+type Evidence struct {
+	// Objects are the database objects, settings and sessions concerned.
+	Objects []Object `json:"Objects,omitempty"`
+
+	// Metrics are the measured values the finding is based on, by snake_case
+	// name, e.g. cache_hit_pct.
+	Metrics map[string]float64 `json:"Metrics,omitempty"`
+
+	// QueryIDs are the pg_stat_statements query ids involved.
+	QueryIDs []int64 `json:"QueryIDs,omitempty"`
+
+	// Anchor is the report section detailing the finding, e.g. #hdr-wal;
+	// set by the report, which knows the sections it renders.
+	Anchor string `json:"Anchor,omitempty"`
+}
+
+// Object is a database object, setting or session a finding refers to.
+type Object struct {
+	// Kind is table, index, sequence, function, setting, role, database,
+	// extension, slot, standby, backend, transaction, application or column.
+	Kind     string `json:"Kind"`
+	Database string `json:"Database,omitempty"`
+	Schema   string `json:"Schema,omitempty"`
+	Name     string `json:"Name"`
+
+	// Metrics are values measured on this object.
+	Metrics map[string]float64 `json:"Metrics,omitempty"`
+}
+
+// ID is the qualified name of the object.
+func (o Object) ID() string {
+	switch {
+	case o.Schema != "":
+		return collect.QualifiedName(o.Database, o.Schema, o.Name)
+	case o.Database != "":
+		return o.Database + "." + o.Name
+	}
+	return o.Name
+}
+
+// blockingEvidence lists the blocked backends with the backend blocking each.
+func blockingEvidence(blocked []collect.Blocking) *Evidence {
+	e := &Evidence{}
+	for _, b := range blocked {
+		e.Objects = append(e.Objects, Object{Kind: "backend", Database: b.Datname, Name: strconv.Itoa(b.BlockedPID),
+			Metrics: map[string]float64{"blocking_pid": float64(b.BlockingPID)}})
+	}
+	return e
+}
+
+// longRunningEvidence lists the backends running long queries.
+func longRunningEvidence(long []collect.LongQuery) *Evidence {
+	e := &Evidence{}
+	for _, q := range long {
+		e.Objects = append(e.Objects, Object{Kind: "backend", Database: q.Datname, Name: strconv.Itoa(q.PID)})
+	}
+	return e
+}
+
+// idleInTransactionEvidence lists the backends idle in a transaction.
+func idleInTransactionEvidence(idle []collect.IdleInTransaction) *Evidence {
+	e := &Evidence{}
+	for _, s := range idle {
+		e.Objects = append(e.Objects, Object{Kind: "backend", Database: s.Datname, Name: strconv.Itoa(s.PID)})
+	}
+	return e
+}
+
+// preparedEvidence lists prepared transactions by their global id.
+func preparedEvidence(xacts []collect.PreparedXact) *Evidence {
+	e := &Evidence{}
+	for _, x := range xacts {
+		e.Objects = append(e.Objects, Object{Kind: "transaction", Database: x.Database, Name: x.GID})
+	}
+	return e
+}
+
+// extensionEvidence lists extensions by name.
+func extensionEvidence(names []string) *Evidence {
+	e := &Evidence{}
+	for _, n := range names {
+		e.Objects = append(e.Objects, Object{Kind: "extension", Name: n})
+	}
+	return e
+}
+
+// unusedIndexEvidence lists unused indexes with their size.
+func unusedIndexEvidence(list []collect.IndexUnused) *Evidence {
+	e := &Evidence{}
+	for _, ix := range list {
+		e.Objects = append(e.Objects, Object{Kind: "index", Database: ix.Database, Schema: ix.Schema, Name: ix.Name,
+			Metrics: map[string]float64{"size_bytes": float64(ix.SizeBytes)}})
+	}
+	return e
+}
+
+// relationObject is the object of a relation named schema.name, or name when
+// the search path resolves it.
+func relationObject(kind, db, rel string) Object {
+	schema, name, ok := strings.Cut(rel, ".")
+	if !ok {
+		schema, name = "", rel
+	}
+	return Object{Kind: kind, Database: db, Schema: schema, Name: name}
+}
+
+// queryIDs is the query id of s as a list, empty when pg_stat_statements
+// hides it.
+func queryIDs(s collect.Statement) []int64 {
+	if s.QueryID == 0 {
+		return nil
+	}
+	return []int64{s.QueryID}
+}
+
+// settingsEvidence is the evidence of a finding on the named settings, with
+// the values measured; metrics may be nil.
+func settingsEvidence(metrics map[string]float64, names ...string) *Evidence {
+	e := &Evidence{Metrics: metrics}
+	for _, n := range names {
+		e.Objects = append(e.Objects, Object{Kind: "setting", Name: n})
+	}
+	return e
+}
+
+// Option adjusts how Run analyzes a result.
+type Option func(*options)
+
+// options holds the settings of an analysis.
+type options struct {
+	deterministic bool
+	history       []collect.Result
+	historyGrowth []collect.SizeGrowth
+	historyConns  *collect.ConnectionHistory
+}
+
+// Deterministic makes the findings depend on the collected data alone: values
+// measured against the current time, such as the uptime, the statistics
+// window, rates since a statistics reset, recent failovers and the clock
+// skew, are left out.
+func Deterministic() Option {
+	return func(o *options) { o.deterministic = true }
+}
+
+// History compares the result with earlier runs of the same target, newest
+// first, as loaded from the archive, and with the size growth and client
+// connection fits over those runs: a falling cache hit ratio, regressed
+// statements, fast growing tables and indexes and growing client connections
+// become findings. The fits are separate from Result.SizeGrowth and
+// Result.ConnectionHistory, whose forecasts span their own windows.
+func History(runs []collect.Result, growth []collect.SizeGrowth, conns *collect.ConnectionHistory) Option {
+	return func(o *options) { o.history, o.historyGrowth, o.historyConns = runs, growth, conns }
+}
+
+// elapsed is the time since t, or false when t is unset or the analysis is
+// deterministic.
+func (o options) elapsed(t time.Time) (time.Duration, bool) {
+	if t.IsZero() || o.deterministic {
+		return 0, false
+	}
+	return time.Since(t), true
 }
 
 // Run analyzes the collected PostgreSQL metrics and returns categorized findings.
@@ -129,20 +671,30 @@ type Finding struct {
 //   - Input res should contain valid collected metrics (not necessarily complete)
 //   - Output slices are never nil (always initialized)
 //   - All findings have non-empty Title and Severity
-func Run(res collect.Result) Analysis {
+func Run(res collect.Result, opts ...Option) Analysis {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
 	a := Analysis{
 		Recommendations: make([]Finding, 0, 16), // Pre-allocate for typical case
 		Warnings:        make([]Finding, 0, 8),
 		Infos:           make([]Finding, 0, 16),
+		Workload:        classifyWorkload(res.Statements.TopByTotalTime),
 	}
+	wl := a.Workload
 
 	// Uptime info
 	if !res.ConnInfo.StartTime.IsZero() {
-		up := time.Since(res.ConnInfo.StartTime)
+		desc := "Up since " + formatLocalTime(res.ConnInfo.StartTime)
+		if up, ok := o.elapsed(res.ConnInfo.StartTime); ok {
+			desc = fmt.Sprintf("%s (since %s)", humanizeDuration(up), formatLocalTime(res.ConnInfo.StartTime))
+		}
 		a.Infos = append(a.Infos, Finding{
 			Title:       "Server uptime",
 			Severity:    SeverityInfo,
-			Description: fmt.Sprintf("%s (since %s)", humanizeDuration(up), formatLocalTime(res.ConnInfo.StartTime)),
+			Description: desc,
 			Action:      "",
 		})
 	}
@@ -155,6 +707,7 @@ func Run(res collect.Result) Analysis {
 				Severity:    SeverityWarning,
 				Description: fmt.Sprintf("Cache hit: %.1f%%", res.CacheHitCurrent),
 				Action:      "Review working set size, shared_buffers, and query patterns; ensure sufficient memory and indexes.",
+				Evidence:    &Evidence{Metrics: map[string]float64{"cache_hit_pct": res.CacheHitCurrent}},
 			})
 		} else {
 			a.Infos = append(a.Infos, Finding{Title: "Cache hit ratio (current)", Severity: SeverityInfo, Description: fmt.Sprintf("%.1f%%", res.CacheHitCurrent)})
@@ -168,10 +721,22 @@ func Run(res collect.Result) Analysis {
 				Code:        "cache-overall",
 				Description: fmt.Sprintf("Cluster-wide cache hit: %.1f%%", res.CacheHitOverall),
 				Action:      "Consider memory tuning and index coverage across busiest databases.",
+				Evidence:    &Evidence{Metrics: map[string]float64{"cache_hit_pct": res.CacheHitOverall}},
 			})
 		}
 	}
 
+	// Connection pooler in front of the server, if any; pooling advice depends on it
+	pool := detectPooler(res)
+	if pool.found() {
+		a.Infos = append(a.Infos, Finding{
+			Title:       "Connection pooler detected",
+			Severity:    SeverityInfo,
+			Code:        "pooler-detected",
+			Description: pool.evidence,
+		})
+	}
+
 	// Connection usage
 	if res.ConnInfo.MaxConnections > 0 && res.TotalConnections > 0 {
 		pct := float64(res.TotalConnections) / float64(res.ConnInfo.MaxConnections) * 100
@@ -180,7 +745,10 @@ func Run(res collect.Result) Analysis {
 				Title:       "High connection usage",
 				Severity:    SeverityWarning,
 				Description: fmt.Sprintf("%d/%d (%.0f%%) connections in use", res.TotalConnections, res.ConnInfo.MaxConnections, pct),
-				Action:      "Use a pooler (pgbouncer), limit app connection pools, and tune max_connections accordingly.",
+				Action:      pool.advice("Use a pooler (pgbouncer), limit app connection pools, and tune max_connections accordingly.", "lower its server pool size (e.g. default_pool_size, max_db_connections) below max_connections and look for clients connecting around it."),
+				Evidence: settingsEvidence(map[string]float64{
+					"connections": float64(res.TotalConnections), "max_connections": float64(res.ConnInfo.MaxConnections), "usage_pct": pct,
+				}, "max_connections"),
 			})
 		} else {
 			a.Infos = append(a.Infos, Finding{Title: "Connection usage", Severity: SeverityInfo, Description: fmt.Sprintf("%d/%d (%.0f%%)", res.TotalConnections, res.ConnInfo.MaxConnections, pct)})
@@ -194,6 +762,7 @@ func Run(res collect.Result) Analysis {
 			Severity:    "warn",
 			Description: fmt.Sprintf("%d blocked sessions", len(res.Blocking)),
 			Action:      "Inspect lock tree, add indexes, shorten transactions, consider lock timeouts.",
+			Evidence:    blockingEvidence(res.Blocking),
 		})
 	}
 	if len(res.LongRunning) > 0 {
@@ -203,25 +772,38 @@ func Run(res collect.Result) Analysis {
 			Code:        "long-running",
 			Description: fmt.Sprintf("%d active queries > 5m", len(res.LongRunning)),
 			Action:      "EXPLAIN ANALYZE top offenders; optimize plans, add indexes, break large batches.",
+			Evidence:    longRunningEvidence(res.LongRunning),
 		})
 	}
 	if len(res.AutoVacuum) > 0 {
+		desc := fmt.Sprintf("%d vacuum workers in progress", len(res.AutoVacuum))
+		var slowest collect.AutoVacuum
+		for _, av := range res.AutoVacuum {
+			if av.ETA() > slowest.ETA() {
+				slowest = av
+			}
+		}
+		if eta := slowest.ETA(); eta > 0 {
+			desc += fmt.Sprintf("; longest heap scan left: %s (%.0f%% scanned, ~%s to go)", slowest.Relation, slowest.Progress(), humanizeDuration(eta))
+		}
 		a.Infos = append(a.Infos, Finding{
 			Title:       "Autovacuum activity",
 			Severity:    "info",
-			Description: fmt.Sprintf("%d vacuum workers in progress", len(res.AutoVacuum)),
+			Description: desc,
 			Action:      "Ensure autovacuum is not throttled for large tables; tune naptime, scale_factor, and cost limits if needed.",
 		})
+		analyzeVacuumPasses(&a, res.AutoVacuum)
 	}
 
 	// Privilege and extensions
-	if !res.Extensions.PgStatStatements {
+	if !res.Extensions.HasQueryStats() {
 		a.Recommendations = append(a.Recommendations, Finding{
 			Title:       "Install pg_stat_statements",
 			Severity:    "rec",
 			Code:        "install-pgss",
 			Description: "pg_stat_statements is not installed. Without it, detailed query performance analysis is limited.",
 			Action:      "CREATE EXTENSION IF NOT EXISTS pg_stat_statements; and set shared_preload_libraries='pg_stat_statements' then restart.",
+			Evidence:    &Evidence{Objects: []Object{{Kind: "extension", Name: collect.SourcePgStatStatements}}},
 		})
 	}
 	if !res.ConnInfo.IsSuperuser && !res.Roles.HasPgMonitor {
@@ -245,7 +827,10 @@ func Run(res collect.Result) Analysis {
 			Title:       "High active connections",
 			Severity:    "warn",
 			Description: fmt.Sprintf("Active connections %d are above 80%% of max_connections (%d)", totalActive, res.ConnInfo.MaxConnections),
-			Action:      "Consider using a connection pooler (e.g., pgbouncer) and review max_connections and work_mem settings.",
+			Action:      pool.advice("Consider using a connection pooler (e.g., pgbouncer) and review max_connections and work_mem settings.", "lower its server pool size toward a few times the CPU count so excess requests queue in the pooler instead of the server."),
+			Evidence: settingsEvidence(map[string]float64{
+				"active_connections": float64(totalActive), "max_connections": float64(res.ConnInfo.MaxConnections),
+			}, "max_connections"),
 		})
 	}
 
@@ -258,21 +843,15 @@ func Run(res collect.Result) Analysis {
 		}
 		return collect.Setting{}, false
 	}
-	if s, ok := setting("track_io_timing"); ok && (s.Val == "off" || s.Val == "0") {
-		a.Recommendations = append(a.Recommendations, Finding{
-			Title:       "Enable track_io_timing",
-			Severity:    "rec",
-			Code:        "enable-track-io",
-			Description: "track_io_timing is off; enabling provides better latency insights.",
-			Action:      "SET track_io_timing = on; then persist in postgresql.conf and reload.",
-		})
-	}
+	analyzeTrackSettings(&a, res, setting)
+	analyzeWorkload(&a, setting)
 	if s, ok := setting("autovacuum"); ok && (s.Val == "off" || s.Val == "0") {
 		a.Warnings = append(a.Warnings, Finding{
 			Title:       "Autovacuum disabled",
 			Severity:    "warn",
 			Description: "Autovacuum appears disabled; this risks bloat and xid wraparound.",
 			Action:      "Enable autovacuum and tune thresholds/freeze settings.",
+			Evidence:    settingsEvidence(nil, "autovacuum"),
 		})
 	}
 
@@ -284,6 +863,7 @@ func Run(res collect.Result) Analysis {
 			Code:        "wal-level-minimal",
 			Description: "wal_level=minimal disables replication and can hinder PITR; production systems typically use 'replica' or 'logical'.",
 			Action:      "Set wal_level=replica (or logical if needed) and restart.",
+			Evidence:    settingsEvidence(nil, "wal_level"),
 		})
 	}
 	// checkpoint timeout sanity
@@ -295,6 +875,7 @@ func Run(res collect.Result) Analysis {
 				Code:        "checkpoint-timeout-low",
 				Description: fmt.Sprintf("checkpoint_timeout=%.0fs; frequent checkpoints may increase write amplification.", secs),
 				Action:      "Consider 5-15 minutes depending on workload; tune with max_wal_size.",
+				Evidence:    settingsEvidence(map[string]float64{"checkpoint_timeout_seconds": secs}, "checkpoint_timeout"),
 			})
 		}
 	}
@@ -308,17 +889,43 @@ func Run(res collect.Result) Analysis {
 			Code:        "ecs-low-vs-sb",
 			Description: "effective_cache_size is typically 2-3x shared_buffers to reflect OS page cache.",
 			Action:      "Increase effective_cache_size to approximate available OS cache.",
+			Evidence: settingsEvidence(map[string]float64{
+				"shared_buffers_bytes": float64(sb), "effective_cache_size_bytes": float64(ecs),
+			}, "effective_cache_size", "shared_buffers"),
 		})
 	}
 	wm, _ := asBytes(setting("work_mem"))
-	if wm > 0 && res.ConnInfo.MaxConnections > 0 && ecs > 0 {
+	capConcurrency := "cap concurrency with a connection pooler."
+	if pool.found() {
+		capConcurrency = fmt.Sprintf("cap concurrency with the server pool size of %s connections already come through.", pool.label())
+	}
+	// With the host RAM or container limit known, size memory advice on it
+	// rather than on effective_cache_size
+	budget, limited := memoryBudget(res)
+	if wm > 0 && res.ConnInfo.MaxConnections > 0 && budget > sb {
+		totalPotential := wm * int64(res.ConnInfo.MaxConnections)
+		if totalPotential > budget-sb {
+			a.Warnings = append(a.Warnings, Finding{
+				Title:       "work_mem may be high",
+				Severity:    "warn",
+				Description: fmt.Sprintf("work_mem x max_connections could exceed memory (%.1f GB vs %.1f GB left of the %s after shared_buffers)", bytesToGB(totalPotential), bytesToGB(budget-sb), memoryBudgetName(budget, limited)),
+				Action:      fmt.Sprintf("Lower work_mem to about %s ((memory - shared_buffers) / (max_connections x 3)) or %s", pgSize(workMemBudget(budget, sb, res.ConnInfo.MaxConnections)), capConcurrency),
+				Evidence: settingsEvidence(map[string]float64{
+					"work_mem_total_bytes": float64(totalPotential), "memory_bytes": float64(budget), "shared_buffers_bytes": float64(sb),
+				}, "work_mem", "max_connections", "shared_buffers"),
+			})
+		}
+	} else if wm > 0 && res.ConnInfo.MaxConnections > 0 && ecs > 0 {
 		totalPotential := wm * int64(res.ConnInfo.MaxConnections)
 		if totalPotential > ecs*2 {
 			a.Warnings = append(a.Warnings, Finding{
 				Title:       "work_mem may be high",
 				Severity:    "warn",
 				Description: fmt.Sprintf("work_mem x max_connections could exceed memory (%.1f GB vs cache %.1f GB)", bytesToGB(totalPotential), bytesToGB(ecs)),
-				Action:      "Lower work_mem or rely on memory context tuning; consider connection pooler to cap concurrency.",
+				Action:      "Lower work_mem or rely on memory context tuning; " + capConcurrency,
+				Evidence: settingsEvidence(map[string]float64{
+					"work_mem_total_bytes": float64(totalPotential), "effective_cache_size_bytes": float64(ecs),
+				}, "work_mem", "max_connections", "effective_cache_size"),
 			})
 		}
 	}
@@ -334,6 +941,7 @@ func Run(res collect.Result) Analysis {
 				Severity:    "info",
 				Description: fmt.Sprintf("~%.0f%% of shared_buffers in use (%0.2f GB of %0.2f GB)", pct, bytesToGB(used), bytesToGB(total)),
 				Action:      "If utilization is persistently low, consider right-sizing shared_buffers; if high with low hit ratio, consider more memory and indexing.",
+				Evidence:    settingsEvidence(map[string]float64{"used_bytes": float64(used), "shared_buffers_bytes": float64(total), "usage_pct": pct}, "shared_buffers"),
 			})
 		}
 	}
@@ -343,21 +951,39 @@ func Run(res collect.Result) Analysis {
 			Severity:    "warn",
 			Description: fmt.Sprintf("Current DB used %.2f GB in temp files across %d files (since stats reset)", bytesToGB(res.MemoryStats.TempBytesCurrentDB), res.MemoryStats.TempFilesCurrentDB),
 			Action:      "Increase work_mem for large sorts/hashes, optimize queries to avoid spills, and consider temp_file_limit.",
+			Evidence: &Evidence{
+				Objects: []Object{{Kind: "database", Name: res.ConnInfo.CurrentDB}},
+				Metrics: map[string]float64{"temp_bytes": float64(res.MemoryStats.TempBytesCurrentDB), "temp_files": float64(res.MemoryStats.TempFilesCurrentDB)},
+			},
 		})
 	}
 
+	// With -dbs the same schema.name can exist in several databases
+	objectName := res.ObjectNamer()
+
 	// Table bloat heuristics
 	type blo struct {
-		schema, table string
-		pct           float64
+		db, schema, table string
+		pct               float64
 	}
 	var bloats []blo
 	for _, t := range res.Tables {
 		if t.BloatPct > 20 && (t.NLiveTup+t.NDeadTup) > 10000 {
-			bloats = append(bloats, blo{t.Schema, t.Name, t.BloatPct})
+			bloats = append(bloats, blo{t.Database, t.Schema, t.Name, t.BloatPct})
 		}
 	}
-	sort.Slice(bloats, func(i, j int) bool { return bloats[i].pct > bloats[j].pct })
+	sort.Slice(bloats, func(i, j int) bool {
+		if bloats[i].pct != bloats[j].pct {
+			return bloats[i].pct > bloats[j].pct
+		}
+		if bloats[i].db != bloats[j].db {
+			return bloats[i].db < bloats[j].db
+		}
+		if bloats[i].schema != bloats[j].schema {
+			return bloats[i].schema < bloats[j].schema
+		}
+		return bloats[i].table < bloats[j].table
+	})
 	if len(bloats) > 0 {
 		top := bloats
 		if len(top) > 10 {
@@ -368,7 +994,11 @@ func Run(res collect.Result) Analysis {
 			if i > 0 {
 				list += ", "
 			}
-			list += fmt.Sprintf("%s.%s(%.0f%%)", b.schema, b.table, b.pct)
+			list += fmt.Sprintf("%s(%.0f%%)", objectName(b.db, b.schema, b.table), b.pct)
+		}
+		ev := &Evidence{}
+		for _, b := range bloats {
+			ev.Objects = append(ev.Objects, Object{Kind: "table", Database: b.db, Schema: b.schema, Name: b.table, Metrics: map[string]float64{"bloat_pct": b.pct}})
 		}
 		a.Warnings = append(a.Warnings, Finding{
 			Title:       "Potential table bloat (heuristic)",
@@ -376,6 +1006,7 @@ func Run(res collect.Result) Analysis {
 			Code:        "table-bloat-heuristic",
 			Description: fmt.Sprintf("Tables with high dead tuple ratio: %s", list),
 			Action:      "Rows highlighted in 'Tables with index counts' exceed ~20% bloat by dead tuple share. Short-term: run VACUUM; for severe cases (>50%) schedule VACUUM FULL or pg_repack during maintenance. Long-term: tune autovacuum thresholds (lower scale_factor for hot tables), consider lower fillfactor to improve HOT updates, and periodically REINDEX if indexes are bloated.",
+			Evidence:    ev,
 		})
 	}
 
@@ -397,9 +1028,51 @@ func Run(res collect.Result) Analysis {
 			if ib.Scans == 0 {
 				k := key{strings.TrimSpace(res.ConnInfo.CurrentDB), ib.Schema, ib.Name}
 				if prev, ok := combined[k]; !ok || ib.WastedBytes > prev.SizeBytes {
-					combined[k] = collect.IndexUnused{Database: res.ConnInfo.CurrentDB, Schema: ib.Schema, Table: ib.Table, Name: ib.Name, SizeBytes: ib.WastedBytes}
+					combined[k] = collect.IndexUnused{Database: res.ConnInfo.CurrentDB, Schema: ib.Schema, Table: ib.Table, Name: ib.Name, SizeBytes: ib.WastedBytes, Constraint: res.IndexConstraint(ib.Schema, ib.Name)}
+				}
+			}
+		}
+		// Indexes backing a primary key, unique or exclusion constraint or the
+		// replica identity cannot be dropped without changing the schema contract
+		var constrained []collect.IndexUnused
+		for k, v := range combined {
+			if v.Constraint != "" {
+				constrained = append(constrained, v)
+				delete(combined, k)
+			}
+		}
+		if len(constrained) > 0 {
+			sort.Slice(constrained, func(i, j int) bool {
+				a, b := constrained[i], constrained[j]
+				if a.SizeBytes != b.SizeBytes {
+					return a.SizeBytes > b.SizeBytes
+				}
+				if a.Database != b.Database {
+					return a.Database < b.Database
+				}
+				if a.Schema != b.Schema {
+					return a.Schema < b.Schema
+				}
+				return a.Name < b.Name
+			})
+			names := ""
+			for i, ix := range constrained {
+				if i >= 10 {
+					break
+				}
+				if i > 0 {
+					names += ", "
 				}
+				names += fmt.Sprintf("%s (%s)", objectName(ix.Database, ix.Schema, ix.Name), ix.Constraint)
 			}
+			a.Infos = append(a.Infos, Finding{
+				Title:       "Unused indexes backing constraints",
+				Severity:    SeverityInfo,
+				Code:        "unused-constraint-indexes",
+				Description: fmt.Sprintf("%d unscanned indexes enforce a constraint or replica identity and are not drop candidates: %s", len(constrained), names),
+				Action:      "Keep them; they are used on writes. Drop only together with the constraint if it is no longer needed.",
+				Evidence:    unusedIndexEvidence(constrained),
+			})
 		}
 		if len(combined) > 0 {
 			// materialize for sampling and count large ones
@@ -407,7 +1080,19 @@ func Run(res collect.Result) Analysis {
 			for _, v := range combined {
 				list = append(list, v)
 			}
-			sort.Slice(list, func(i, j int) bool { return list[i].SizeBytes > list[j].SizeBytes })
+			sort.Slice(list, func(i, j int) bool {
+				a, b := list[i], list[j]
+				if a.SizeBytes != b.SizeBytes {
+					return a.SizeBytes > b.SizeBytes
+				}
+				if a.Database != b.Database {
+					return a.Database < b.Database
+				}
+				if a.Schema != b.Schema {
+					return a.Schema < b.Schema
+				}
+				return a.Name < b.Name
+			})
 			names := ""
 			max := 10
 			for i, ix := range list {
@@ -417,7 +1102,7 @@ func Run(res collect.Result) Analysis {
 				if i > 0 {
 					names += ", "
 				}
-				names += fmt.Sprintf("%s.%s", ix.Schema, ix.Name)
+				names += objectName(ix.Database, ix.Schema, ix.Name)
 			}
 			large := 0
 			for _, ix := range list {
@@ -435,29 +1120,74 @@ func Run(res collect.Result) Analysis {
 				Code:        "unused-indexes",
 				Description: desc,
 				Action:      "Validate with workload owners and drop truly unused indexes to reduce write/maintenance overhead.",
+				Evidence:    unusedIndexEvidence(list),
 			})
 		}
 	}
 
 	// Missing index hints
 	if len(res.MissingIndexes) > 0 {
+		desc := "Some tables show heavy sequential scans with low index usage."
+		var hints []string
+		ev := &Evidence{}
+		for _, h := range res.MissingIndexes {
+			if h.Columns != "" && h.Columns != "(unknown)" && len(hints) < 5 {
+				hints = append(hints, fmt.Sprintf("%s.%s (%s)", h.Schema, h.Table, h.Columns))
+			}
+			ev.Objects = append(ev.Objects, Object{Kind: "table", Schema: h.Schema, Name: h.Table})
+		}
+		if len(hints) > 0 {
+			desc += " Candidate composite indexes from top query predicates: " + strings.Join(hints, ", ") + "."
+		}
 		a.Recommendations = append(a.Recommendations, Finding{
 			Title:       "Possible missing indexes",
 			Severity:    "rec",
 			Code:        "missing-indexes",
-			Description: "Some tables show heavy sequential scans with low index usage.",
-			Action:      "EXPLAIN problematic queries; create indexes on selective predicates/joins as appropriate.",
+			Description: desc,
+			Action:      "EXPLAIN problematic queries; create indexes on selective predicates/joins as appropriate. Suggested columns put equality filters first, then one range filter, then sort keys.",
+			Evidence:    ev,
+		})
+	}
+
+	// Low-selectivity indexes: scans read many entries per row returned
+	if n := len(res.LowSelectivityIndexes); n > 0 {
+		list := make([]string, 0, 5)
+		for i, ix := range res.LowSelectivityIndexes {
+			if i >= 5 {
+				break
+			}
+			list = append(list, fmt.Sprintf("%s (%s entries/scan, %.0f read per row)", objectName(ix.Database, ix.Schema, ix.Name), formatThousands0(ix.TuplesPerScan()), ix.ReadPerFetch()))
+		}
+		desc := fmt.Sprintf("%d indexes read many entries per row they return: %s", n, strings.Join(list, ", "))
+		if n > 5 {
+			desc += fmt.Sprintf(" and %d more", n-5)
+		}
+		ev := &Evidence{}
+		for _, ix := range res.LowSelectivityIndexes {
+			ev.Objects = append(ev.Objects, Object{Kind: "index", Database: ix.Database, Schema: ix.Schema, Name: ix.Name,
+				Metrics: map[string]float64{"entries_per_scan": ix.TuplesPerScan(), "read_per_row": ix.ReadPerFetch()}})
+		}
+		a.Recommendations = append(a.Recommendations, Finding{
+			Title:       "Indexes with poor selectivity",
+			Severity:    SeverityRec,
+			Code:        "index-low-selectivity",
+			Description: desc,
+			Action:      "Find the queries using these indexes and add the other filtered columns as a composite index, or a partial index (WHERE ...) for constant predicates. Confirm with EXPLAIN (ANALYZE, BUFFERS): bitmap scans also read entries without fetching rows.",
+			Evidence:    ev,
 		})
 	}
 
 	// Statements / pg_stat_statements context
 	if res.Statements.Available {
 		if !res.Statements.StatsResetTime.IsZero() {
-			statsAge := time.Since(res.Statements.StatsResetTime)
+			desc := "pg_stat_statements data covers the time since " + formatLocalTime(res.Statements.StatsResetTime)
+			if statsAge, ok := o.elapsed(res.Statements.StatsResetTime); ok {
+				desc = fmt.Sprintf("pg_stat_statements data covers the last %s (since %s)", humanizeDuration(statsAge), formatLocalTime(res.Statements.StatsResetTime))
+			}
 			a.Infos = append(a.Infos, Finding{
 				Title:       "Query stats window",
 				Severity:    "info",
-				Description: fmt.Sprintf("pg_stat_statements data covers the last %s (since %s)", humanizeDuration(statsAge), formatLocalTime(res.Statements.StatsResetTime)),
+				Description: desc,
 				Action:      "Run `SELECT pg_stat_statements_reset()` to clear stats if needed.",
 			})
 		}
@@ -465,8 +1195,8 @@ func Run(res collect.Result) Analysis {
 		if len(res.Statements.TopByTotalTime) > 0 {
 			q := res.Statements.TopByTotalTime[0]
 			desc := fmt.Sprintf("Calls: %s, Total: %s", formatThousands0(q.Calls), humanizeMs(q.TotalTime))
-			if !res.Statements.StatsResetTime.IsZero() {
-				statsAgeHours := time.Since(res.Statements.StatsResetTime).Hours()
+			if statsAge, ok := o.elapsed(res.Statements.StatsResetTime); ok {
+				statsAgeHours := statsAge.Hours()
 				if statsAgeHours > 0 {
 					callsPerHour := q.Calls / statsAgeHours
 					desc += fmt.Sprintf(", Calls/hr: %.1f", callsPerHour)
@@ -477,6 +1207,23 @@ func Run(res collect.Result) Analysis {
 				Severity:    "info",
 				Description: desc,
 				Action:      "Review execution plan and caching. Consider increasing work_mem for heavy sorts/aggregations.",
+				Evidence:    &Evidence{QueryIDs: queryIDs(q), Metrics: map[string]float64{"calls": q.Calls, "total_time_ms": q.TotalTime}},
+			})
+		}
+
+		// Attribute load to roles when one dominates execution time
+		if len(res.Statements.ByRole) > 1 && res.Statements.ByRole[0].Share >= loadConcentrationShare {
+			top := res.Statements.ByRole[0]
+			a.Infos = append(a.Infos, Finding{
+				Title:       "Query load concentrated in one role",
+				Severity:    SeverityInfo,
+				Code:        "load-by-role",
+				Description: fmt.Sprintf("role %s accounts for %.0f%% of total execution time (%s calls)", top.Name, top.Share*100, formatThousands0(top.Calls)),
+				Action:      "Review the workload of this role first; consider a dedicated pool or replica for it.",
+				Evidence: &Evidence{
+					Objects: []Object{{Kind: "role", Name: top.Name}},
+					Metrics: map[string]float64{"share_pct": top.Share * 100, "calls": top.Calls, "total_time_ms": top.TotalTime},
+				},
 			})
 		}
 
@@ -486,16 +1233,21 @@ func Run(res collect.Result) Analysis {
 		canBeRefactoredCount := 0
 		hasSort := false
 		hasJoin := false
+		// Query ids of the statements behind each recommendation
+		var seqScanIDs, indexedIDs, refactorIDs, sortIDs, joinIDs []int64
 		for _, st := range res.Statements.TopByTotalTime {
 			if st.Advice == nil {
 				continue
 			}
 			if st.Advice.CanBeIndexed {
 				canBeIndexedCount++
+				indexedIDs = append(indexedIDs, queryIDs(st)...)
 			}
 			if st.Advice.CanBeRefactored {
 				canBeRefactoredCount++
+				refactorIDs = append(refactorIDs, queryIDs(st)...)
 			}
+			var seq, sorts, joins bool
 			for _, h := range st.Advice.Highlights {
 				uh := strings.ToUpper(h)
 				if strings.HasPrefix(uh, "SEQ SCAN ON ") {
@@ -504,15 +1256,25 @@ func Run(res collect.Result) Analysis {
 					name = strings.TrimSpace(name)
 					if name != "" {
 						seqScanTables[name] = struct{}{}
+						seq = true
 					}
 				}
 				if strings.Contains(uh, "SORT") {
-					hasSort = true
+					hasSort, sorts = true, true
 				}
 				if strings.Contains(uh, "JOIN") {
-					hasJoin = true
+					hasJoin, joins = true, true
 				}
 			}
+			if seq {
+				seqScanIDs = append(seqScanIDs, queryIDs(st)...)
+			}
+			if sorts {
+				sortIDs = append(sortIDs, queryIDs(st)...)
+			}
+			if joins {
+				joinIDs = append(joinIDs, queryIDs(st)...)
+			}
 		}
 		if len(seqScanTables) > 0 {
 			// build table list
@@ -521,6 +1283,14 @@ func Run(res collect.Result) Analysis {
 				names = append(names, n)
 			}
 			sort.Strings(names)
+			ev := &Evidence{QueryIDs: seqScanIDs}
+			for _, n := range names {
+				schema, table, ok := strings.Cut(n, ".")
+				if !ok {
+					schema, table = "", n
+				}
+				ev.Objects = append(ev.Objects, Object{Kind: "table", Schema: schema, Name: table})
+			}
 			// cap the list for readability
 			max := 8
 			if len(names) > max {
@@ -532,6 +1302,7 @@ func Run(res collect.Result) Analysis {
 				Code:        "slow-seq-scans",
 				Description: fmt.Sprintf("Sequential scans detected on: %s", strings.Join(names, ", ")),
 				Action:      "Create or refine indexes on selective WHERE and JOIN columns; analyze tables; ensure statistics are up to date.",
+				Evidence:    ev,
 			})
 		}
 		if canBeIndexedCount > 0 {
@@ -541,6 +1312,7 @@ func Run(res collect.Result) Analysis {
 				Code:        "slow-index-improve",
 				Description: fmt.Sprintf("%d slow queries could be improved with new or better indexes.", canBeIndexedCount),
 				Action:      "Run EXPLAIN on slow queries to identify missing indexes on columns used in WHERE clauses, JOINs, or ORDER BY.",
+				Evidence:    &Evidence{QueryIDs: indexedIDs},
 			})
 		}
 		if canBeRefactoredCount > 0 {
@@ -550,6 +1322,7 @@ func Run(res collect.Result) Analysis {
 				Code:        "slow-refactor",
 				Description: fmt.Sprintf("%d slow queries may need refactoring as indexes alone may not solve the performance issue.", canBeRefactoredCount),
 				Action:      "Analyze the execution plan of slow queries to understand the cause. Consider rewriting the query, breaking it into smaller parts, or using different join strategies.",
+				Evidence:    &Evidence{QueryIDs: refactorIDs},
 			})
 		}
 		if hasSort {
@@ -559,6 +1332,7 @@ func Run(res collect.Result) Analysis {
 				Code:        "slow-sorts",
 				Description: "Plans include Sort nodes for top slow queries.",
 				Action:      "Add or adjust indexes matching ORDER BY leading columns to enable sorted index scans where appropriate.",
+				Evidence:    &Evidence{QueryIDs: sortIDs},
 			})
 		}
 		if hasJoin {
@@ -568,15 +1342,20 @@ func Run(res collect.Result) Analysis {
 				Code:        "slow-joins",
 				Description: "Join operations detected; missing or suboptimal indexes can cause hash/merge joins to spill or nested loops to scan many rows.",
 				Action:      "Ensure join key columns are indexed on both sides; consider composite indexes matching join + filter predicates.",
+				Evidence:    &Evidence{QueryIDs: joinIDs},
 			})
 		}
 	} else {
-		if res.Extensions.PgStatStatements {
+		if res.Extensions.HasQueryStats() {
+			ext := res.Statements.Source
+			if ext == "" {
+				ext = collect.SourcePgStatStatements
+			}
 			a.Infos = append(a.Infos, Finding{
-				Title:       "pg_stat_statements installed",
+				Title:       ext + " installed",
 				Severity:    "info",
 				Description: "Extension is present but returned no rows for top queries (possibly recently reset or limited visibility).",
-				Action:      "Run workload, ensure pg_stat_statements is preloaded and tracking settings are appropriate; verify role has access.",
+				Action:      "Run workload, ensure " + ext + " is preloaded and tracking settings are appropriate; verify role has access.",
 			})
 		} else {
 			a.Infos = append(a.Infos, Finding{
@@ -592,12 +1371,17 @@ func Run(res collect.Result) Analysis {
 	if len(res.TablesWithIndexCount) > 0 {
 		tablesWithoutIndexes := 0
 		tablesWithManyIndexes := 0
+		noIndexes, manyIndexes := &Evidence{}, &Evidence{}
 		for _, t := range res.TablesWithIndexCount {
+			obj := Object{Kind: "table", Database: t.Database, Schema: t.Schema, Name: t.Name,
+				Metrics: map[string]float64{"indexes": float64(t.IndexCount), "rows": float64(t.RowCount)}}
 			if t.IndexCount == 0 && t.RowCount > 1000 {
 				tablesWithoutIndexes++
+				noIndexes.Objects = append(noIndexes.Objects, obj)
 			}
 			if t.IndexCount > 10 {
 				tablesWithManyIndexes++
+				manyIndexes.Objects = append(manyIndexes.Objects, obj)
 			}
 		}
 		if tablesWithoutIndexes > 0 {
@@ -606,6 +1390,7 @@ func Run(res collect.Result) Analysis {
 				Severity:    "warn",
 				Description: fmt.Sprintf("%d large tables have no indexes", tablesWithoutIndexes),
 				Action:      "Review tables with >1000 rows and no indexes; consider adding primary keys and selective indexes.",
+				Evidence:    noIndexes,
 			})
 		}
 		if tablesWithManyIndexes > 0 {
@@ -615,6 +1400,7 @@ func Run(res collect.Result) Analysis {
 				Code:        "too-many-indexes",
 				Description: fmt.Sprintf("%d tables have >10 indexes", tablesWithManyIndexes),
 				Action:      "Review index usage; consider dropping unused indexes to reduce write overhead and storage.",
+				Evidence:    manyIndexes,
 			})
 		}
 	}
@@ -623,18 +1409,23 @@ func Run(res collect.Result) Analysis {
 	if len(res.TableBloatStats) > 0 {
 		severeBloat := 0
 		totalWasted := int64(0)
+		ev := &Evidence{}
 		for _, b := range res.TableBloatStats {
 			if b.EstimatedBloat > 50 {
 				severeBloat++
+				ev.Objects = append(ev.Objects, Object{Kind: "table", Database: res.ConnInfo.CurrentDB, Schema: b.Schema, Name: b.Name,
+					Metrics: map[string]float64{"bloat_pct": b.EstimatedBloat, "wasted_bytes": float64(b.WastedBytes)}})
 			}
 			totalWasted += b.WastedBytes
 		}
+		ev.Metrics = map[string]float64{"wasted_bytes": float64(totalWasted)}
 		if severeBloat > 0 {
 			a.Warnings = append(a.Warnings, Finding{
 				Title:       "Severe table bloat detected",
 				Severity:    "warn",
 				Description: fmt.Sprintf("%d tables with >50%% bloat, wasting %.2f GB", severeBloat, bytesToGB(totalWasted)),
 				Action:      "Run VACUUM FULL or use pg_repack on severely bloated tables; review autovacuum settings.",
+				Evidence:    ev,
 			})
 		}
 	}
@@ -644,9 +1435,11 @@ func Run(res collect.Result) Analysis {
 	// Replication health
 	if len(res.ReplicationStats) > 0 {
 		lagIssues := 0
+		ev := &Evidence{}
 		for _, r := range res.ReplicationStats {
 			if r.SyncState != "sync" && r.SyncState != "quorum" {
 				lagIssues++
+				ev.Objects = append(ev.Objects, Object{Kind: "standby", Name: r.Name})
 			}
 		}
 		if lagIssues > 0 {
@@ -655,6 +1448,7 @@ func Run(res collect.Result) Analysis {
 				Severity:    "warn",
 				Description: fmt.Sprintf("%d replicas not in sync state", lagIssues),
 				Action:      "Check network connectivity, replica performance, and wal_sender/wal_receiver processes.",
+				Evidence:    ev,
 			})
 		}
 	} else if res.ConnInfo.IsSuperuser {
@@ -676,6 +1470,9 @@ func Run(res collect.Result) Analysis {
 				Severity:    "warn",
 				Description: fmt.Sprintf("%.1f%% of checkpoints are requested (not scheduled)", reqRatio),
 				Action:      "Increase max_wal_size and checkpoint_timeout; reduce checkpoint_completion_target if needed.",
+				Evidence: &Evidence{Metrics: map[string]float64{
+					"requested_pct": reqRatio, "requested": float64(res.CheckpointStats.RequestedCheckpoints), "scheduled": float64(res.CheckpointStats.ScheduledCheckpoints),
+				}},
 			})
 		}
 	}
@@ -690,6 +1487,7 @@ func Run(res collect.Result) Analysis {
 				Severity:    "warn",
 				Description: fmt.Sprintf("Heap cache hit ratio: %.1f%%", heapHitRatio),
 				Action:      "Increase shared_buffers; ensure working set fits in memory; check for memory pressure.",
+				Evidence:    &Evidence{Metrics: map[string]float64{"heap_hit_pct": heapHitRatio}},
 			})
 		}
 	}
@@ -716,7 +1514,12 @@ func Run(res collect.Result) Analysis {
 			for k, v := range m {
 				arr = append(arr, kv{k, v})
 			}
-			sort.Slice(arr, func(i, j int) bool { return arr[i].v > arr[j].v })
+			sort.Slice(arr, func(i, j int) bool {
+				if arr[i].v != arr[j].v {
+					return arr[i].v > arr[j].v
+				}
+				return arr[i].k < arr[j].k
+			})
 			if len(arr) > n {
 				arr = arr[:n]
 			}
@@ -745,6 +1548,13 @@ func Run(res collect.Result) Analysis {
 		// Targeted recommendations based on dominant waits
 		get := func(key string) int { return byType[strings.ToUpper(key)] }
 		dom := func(key string) bool { return total > 0 && float64(get(key))/float64(total) >= 0.6 }
+		waits := func(types ...string) *Evidence {
+			e := &Evidence{Metrics: map[string]float64{"sessions": float64(total)}}
+			for _, t := range types {
+				e.Metrics[strings.ToLower(t)+"_waits"] = float64(get(t))
+			}
+			return e
+		}
 		// IO waits
 		if get("IO") > 0 {
 			sev := "rec"
@@ -757,6 +1567,7 @@ func Run(res collect.Result) Analysis {
 				Code:        "io-waits",
 				Description: "pg_stat_activity shows IO waits (reads/writes/sync).",
 				Action:      "Improve cache hit (shared_buffers, indexing), tune effective_io_concurrency and checkpoint settings, and consider faster storage.",
+				Evidence:    waits("IO"),
 			})
 		}
 		// Lock and LWLock waits
@@ -773,6 +1584,7 @@ func Run(res collect.Result) Analysis {
 				Code:        "lock-waits",
 				Description: "Waits due to locks/LWLocks detected; possible blockers or high contention.",
 				Action:      "Identify blockers (Blocking section), shorten transactions, add indexes to reduce lock duration, and consider lock timeouts.",
+				Evidence:    waits("LOCK", "LWLOCK"),
 			})
 		}
 		// BufferPin waits (often long-running transactions pin buffers)
@@ -783,6 +1595,7 @@ func Run(res collect.Result) Analysis {
 				Code:        "bufferpin-waits",
 				Description: "BufferPin waits suggest pinned buffers—often due to long-running queries/transactions.",
 				Action:      "Avoid long transactions and idle-in-transaction sessions; commit sooner and set idle_in_transaction_session_timeout.",
+				Evidence:    waits("BUFFERPIN"),
 			})
 		}
 		// Client waits: usually benign, but high proportions can indicate app idling
@@ -799,14 +1612,14 @@ func Run(res collect.Result) Analysis {
 
 	// WAL volume context & FPI ratio (pg_monitor)
 	if res.WAL != nil && res.WAL.Bytes > 0 && !res.WAL.StatsReset.IsZero() {
-		dur := time.Since(res.WAL.StatsReset)
-		if dur > 0 {
+		if dur, ok := o.elapsed(res.WAL.StatsReset); ok && dur > 0 {
 			bytesPerSec := float64(res.WAL.Bytes) / dur.Seconds()
 			// High sustained WAL write rate
 			if bytesPerSec > 10*1024*1024 { // >10MB/s
 				a.Warnings = append(a.Warnings, Finding{Title: "High WAL write rate", Severity: "warn", Code: "high-wal",
 					Description: fmt.Sprintf("~%.1f MB/s since %s", bytesPerSec/(1024*1024), formatLocalTime(res.WAL.StatsReset)),
 					Action:      "Tune checkpoint_timeout and max_wal_size; avoid unnecessary bulk updates and bloated indexes; ensure autovacuum keeps up.",
+					Evidence:    &Evidence{Metrics: map[string]float64{"wal_bytes_per_second": bytesPerSec, "wal_bytes": float64(res.WAL.Bytes)}},
 				})
 			} else {
 				a.Infos = append(a.Infos, Finding{Title: "WAL rate", Severity: "info",
@@ -822,6 +1635,7 @@ func Run(res collect.Result) Analysis {
 					Code:        "wal-fpi-high",
 					Description: fmt.Sprintf("FPI/records ratio ~%.0f%%", fpiRatio*100),
 					Action:      "Likely frequent checkpoints or many first-touches of pages. Increase checkpoint_timeout/max_wal_size and avoid unnecessary table rewrites.",
+					Evidence:    &Evidence{Metrics: map[string]float64{"fpi_pct": fpiRatio * 100}},
 				})
 			} else if fpiRatio > 0.2 {
 				a.Recommendations = append(a.Recommendations, Finding{
@@ -830,6 +1644,7 @@ func Run(res collect.Result) Analysis {
 					Code:        "wal-fpi",
 					Description: fmt.Sprintf("FPI/records ratio ~%.0f%%", fpiRatio*100),
 					Action:      "Consider fewer checkpoints (tune checkpoint_timeout, max_wal_size) and reduce bulk page modifications where possible.",
+					Evidence:    &Evidence{Metrics: map[string]float64{"fpi_pct": fpiRatio * 100}},
 				})
 			}
 		}
@@ -851,6 +1666,9 @@ func Run(res collect.Result) Analysis {
 				Code:        "hot-function",
 				Description: fmt.Sprintf("%s.%s — calls: %s, total: %.1f ms, self: %.1f ms (avg self %.2f ms)", f.Schema, f.Name, formatThousands0(float64(f.Calls)), f.TotalTime, f.SelfTime, avgSelf),
 				Action:      "Profile function logic; reduce loops and per-row work; consider set-based SQL or indexing; enable track_functions='pl'/'all' if more granularity is needed.",
+				Evidence: &Evidence{Objects: []Object{{Kind: "function", Schema: f.Schema, Name: f.Name, Metrics: map[string]float64{
+					"calls": float64(f.Calls), "total_time_ms": f.TotalTime, "self_time_ms": f.SelfTime,
+				}}}},
 			})
 		} else {
 			a.Infos = append(a.Infos, Finding{Title: "Top function", Severity: "info",
@@ -858,9 +1676,12 @@ func Run(res collect.Result) Analysis {
 		}
 		// Multiple heavy functions (avg self time threshold)
 		heavy := 0
+		heavyEv := &Evidence{}
 		for _, fn := range res.FunctionStats {
 			if fn.Calls >= 100 && (fn.SelfTime/float64(fn.Calls)) > 5.0 { // >5ms self per call
 				heavy++
+				heavyEv.Objects = append(heavyEv.Objects, Object{Kind: "function", Schema: fn.Schema, Name: fn.Name,
+					Metrics: map[string]float64{"calls": float64(fn.Calls), "avg_self_time_ms": fn.SelfTime / float64(fn.Calls)}})
 			}
 		}
 		if heavy >= 3 {
@@ -870,6 +1691,7 @@ func Run(res collect.Result) Analysis {
 				Code:        "hot-functions-multi",
 				Description: fmt.Sprintf("%d functions exceed ~5ms self time per call (>=100 calls)", heavy),
 				Action:      "Look for row-by-row PL/pgSQL patterns; push work into SQL set operations; add indexes to speed lookups inside functions.",
+				Evidence:    heavyEv,
 			})
 		}
 	}
@@ -877,9 +1699,11 @@ func Run(res collect.Result) Analysis {
 	// Progress views (pg_monitor): detect waits during index builds/analyze
 	if len(res.ProgressCreateIndex) > 0 {
 		waiting := 0
+		ev := &Evidence{}
 		for _, pr := range res.ProgressCreateIndex {
 			if strings.Contains(strings.ToLower(pr.Phase), "wait") || (pr.LockersTotal > 0 && pr.LockersDone < pr.LockersTotal) {
 				waiting++
+				ev.Objects = append(ev.Objects, relationObject("table", pr.Datname, pr.Relation))
 			}
 		}
 		if waiting > 0 {
@@ -889,6 +1713,7 @@ func Run(res collect.Result) Analysis {
 				Code:        "ci-wait-lockers",
 				Description: fmt.Sprintf("%d CREATE INDEX operations are waiting on locks", waiting),
 				Action:      "Prefer CREATE INDEX CONCURRENTLY for live systems; schedule builds off-peak; reduce long transactions holding locks.",
+				Evidence:    ev,
 			})
 		} else {
 			a.Infos = append(a.Infos, Finding{Title: "Index builds in progress", Severity: "info",
@@ -902,6 +1727,8 @@ func Run(res collect.Result) Analysis {
 			Action:      "Allow ANALYZE to complete for up-to-date planner statistics.",
 		})
 	}
+	// COPY, CLUSTER/VACUUM FULL and base backups in progress
+	analyzeOperations(&a, res)
 
 	// Lock contention analysis
 	if len(res.LockStats) > 0 {
@@ -917,10 +1744,16 @@ func Run(res collect.Result) Analysis {
 				Severity:    "warn",
 				Description: fmt.Sprintf("%d locks are waiting to be granted", totalWaiting),
 				Action:      "Review long-running transactions; consider shorter transaction durations and lock timeouts.",
+				Evidence:    &Evidence{Metrics: map[string]float64{"waiting_locks": float64(totalWaiting)}},
 			})
 		}
 	}
 
+	// Hot rows and table-level lock queues
+	if len(res.LockHotspots) > 0 {
+		analyzeLockHotspots(&a, res.LockHotspots)
+	}
+
 	// Temporary file analysis
 	if len(res.TempFileStats) > 0 {
 		totalTempBytes := int64(0)
@@ -933,6 +1766,7 @@ func Run(res collect.Result) Analysis {
 				Severity:    "warn",
 				Description: fmt.Sprintf("Sessions using %.2f GB in temporary files", bytesToGB(totalTempBytes)),
 				Action:      "Increase work_mem; review queries with large sorts/hashes; consider temp_file_limit.",
+				Evidence:    &Evidence{Metrics: map[string]float64{"temp_bytes": float64(totalTempBytes)}},
 			})
 		}
 	}
@@ -960,6 +1794,7 @@ func Run(res collect.Result) Analysis {
 				Code:        "missing-extensions",
 				Description: fmt.Sprintf("Consider installing: %s", strings.Join(missing, ", ")),
 				Action:      "CREATE EXTENSION IF NOT EXISTS extension_name; (requires superuser or appropriate privileges)",
+				Evidence:    extensionEvidence(missing),
 			})
 		}
 	}
@@ -972,7 +1807,8 @@ func Run(res collect.Result) Analysis {
 				Severity:    "rec",
 				Code:        "shared-buffers-low",
 				Description: "shared_buffers is at default value",
-				Action:      "Set shared_buffers to 25-40% of available RAM for dedicated PostgreSQL servers.",
+				Action:      sharedBuffersAdvice(budget, limited),
+				Evidence:    settingsEvidence(map[string]float64{"memory_bytes": float64(budget)}, "shared_buffers"),
 			})
 		}
 	}
@@ -980,13 +1816,26 @@ func Run(res collect.Result) Analysis {
 	// work_mem guardrails already covered above; add low suggestion if very small
 	if wmS, ok := setting("work_mem"); ok {
 		if wm, _ := asBytes(wmS, true); wm > 0 && wm < 4*1024*1024 { // <4MB
-			a.Recommendations = append(a.Recommendations, Finding{
-				Title:       "work_mem may be too low",
-				Severity:    "rec",
-				Code:        "work-mem-low",
-				Description: fmt.Sprintf("work_mem=%s can cause frequent temp spills for sorts/hashes", wmS.Val),
-				Action:      "Consider 16-64MB depending on workload; prefer per-query SET work_mem for heavy reports.",
-			})
+			action := wl.advice("Consider 16-64MB depending on workload; prefer per-query SET work_mem for heavy reports.",
+				"Consider 16-32MB: OLTP queries sort little and many connections share the memory; prefer per-query SET work_mem for heavy reports.",
+				"Consider 64-256MB: analytical queries sort and hash large sets, so keep concurrent connections low enough for the memory to cover them.")
+			fits := true
+			if budget > sb && res.ConnInfo.MaxConnections > 0 {
+				// Never suggest more than the memory budget supports
+				up := workMemBudget(budget, sb, res.ConnInfo.MaxConnections)
+				fits = up > wm
+				action = fmt.Sprintf("Consider up to %s ((memory - shared_buffers) / (max_connections x 3) for the %s); prefer per-query SET work_mem for heavy reports.", pgSize(min(up, 64<<20)), memoryBudgetName(budget, limited))
+			}
+			if fits {
+				a.Recommendations = append(a.Recommendations, Finding{
+					Title:       "work_mem may be too low",
+					Severity:    "rec",
+					Code:        "work-mem-low",
+					Description: fmt.Sprintf("work_mem=%s can cause frequent temp spills for sorts/hashes", wmS.Val),
+					Action:      action,
+					Evidence:    settingsEvidence(map[string]float64{"work_mem_bytes": float64(wm)}, "work_mem"),
+				})
+			}
 		}
 	}
 
@@ -999,6 +1848,7 @@ func Run(res collect.Result) Analysis {
 				Code:        "max-wal-size-low",
 				Description: "Small max_wal_size can cause frequent checkpoints and high FPI rate.",
 				Action:      "Consider 4-16GB depending on write workload to reduce checkpoint frequency.",
+				Evidence:    settingsEvidence(map[string]float64{"max_wal_size_bytes": float64(mb)}, "max_wal_size"),
 			})
 		}
 	}
@@ -1013,6 +1863,7 @@ func Run(res collect.Result) Analysis {
 					Code:        "wal-buffers-low",
 					Description: fmt.Sprintf("wal_buffers=%s; small buffers can throttle WAL writes under bursty load", s.Val),
 					Action:      "Either leave wal_buffers at default (auto) or set to at least 16MB for busy systems.",
+					Evidence:    settingsEvidence(map[string]float64{"wal_buffers_bytes": float64(b)}, "wal_buffers"),
 				})
 			}
 		}
@@ -1028,6 +1879,7 @@ func Run(res collect.Result) Analysis {
 				Code:        "parallel-workers-low",
 				Description: fmt.Sprintf("max_parallel_workers=%d can limit parallel query speedups", val),
 				Action:      "Set max_parallel_workers (and per-gather variants) to 4-8+ depending on CPU cores and workload.",
+				Evidence:    settingsEvidence(map[string]float64{"max_parallel_workers": float64(val)}, "max_parallel_workers"),
 			})
 		}
 	}
@@ -1048,8 +1900,9 @@ func Run(res collect.Result) Analysis {
 			Title:       "High max_connections setting",
 			Severity:    "rec",
 			Description: fmt.Sprintf("max_connections=%d may be high", res.ConnInfo.MaxConnections),
-			Action:      "Consider using a connection pooler (pgbouncer) and reducing max_connections to 50-100.",
+			Action:      pool.advice("Consider using a connection pooler (pgbouncer) and reducing max_connections to 50-100.", "reduce max_connections toward its total server pool size plus headroom for direct admin and replication connections."),
 			Code:        "high-max-connections",
+			Evidence:    settingsEvidence(map[string]float64{"max_connections": float64(res.ConnInfo.MaxConnections)}, "max_connections"),
 		})
 	}
 
@@ -1062,6 +1915,7 @@ func Run(res collect.Result) Analysis {
 				Description: fmt.Sprintf("autovacuum_naptime=%.0fs", secs),
 				Action:      "Consider reducing to 20-60 seconds for more aggressive autovacuum scheduling.",
 				Code:        "autovacuum-naptime-high",
+				Evidence:    settingsEvidence(map[string]float64{"autovacuum_naptime_seconds": secs}, "autovacuum_naptime"),
 			})
 		}
 	}
@@ -1075,6 +1929,7 @@ func Run(res collect.Result) Analysis {
 				Description: "maintenance_work_mem is low for VACUUM/REINDEX operations",
 				Action:      "Increase maintenance_work_mem to 256MB-1GB for better maintenance performance.",
 				Code:        "maintenance-work-mem-low",
+				Evidence:    settingsEvidence(map[string]float64{"maintenance_work_mem_bytes": float64(val)}, "maintenance_work_mem"),
 			})
 		}
 	}
@@ -1087,7 +1942,10 @@ func Run(res collect.Result) Analysis {
 				Severity:    "rec",
 				Code:        "random-page-cost-default",
 				Description: "random_page_cost=4.0 may not reflect modern storage",
-				Action:      "For SSD storage, consider reducing to 1.1-2.0; for HDD, 4.0 is usually appropriate.",
+				Action: wl.advice("For SSD storage, consider reducing to 1.1-2.0; for HDD, 4.0 is usually appropriate.",
+					"For SSD storage, consider 1.1-1.5 so the short, selective OLTP queries get index scans; for HDD, 4.0 is usually appropriate.",
+					"For SSD storage, consider 1.5-2.0: analytical queries read large parts of tables, where sequential scans stay the cheaper plan, so check the plans of the top queries after lowering it; for HDD, 4.0 is usually appropriate."),
+				Evidence: settingsEvidence(map[string]float64{"random_page_cost": 4}, "random_page_cost"),
 			})
 		}
 	}
@@ -1099,7 +1957,10 @@ func Run(res collect.Result) Analysis {
 				Title:       "work_mem may be too high",
 				Severity:    "warn",
 				Description: fmt.Sprintf("work_mem=%s", s.Val),
-				Action:      "High work_mem can cause memory pressure; consider per-query work_mem or lower global setting.",
+				Action: wl.advice("High work_mem can cause memory pressure; consider per-query work_mem or lower global setting.",
+					"OLTP queries rarely need more than 16-32MB: lower the global setting and SET work_mem per reporting query.",
+					"Analytical queries benefit from a high work_mem, but every sort and hash node of every running query may use it: cap concurrent queries (e.g. with a pooler) so work_mem x active queries fits memory."),
+				Evidence: settingsEvidence(map[string]float64{"work_mem_bytes": float64(val)}, "work_mem"),
 			})
 		}
 	}
@@ -1112,6 +1973,7 @@ func Run(res collect.Result) Analysis {
 			Code:        "ssl-off",
 			Description: "SSL encryption is not enabled for connections",
 			Action:      "Enable SSL for encrypted client connections; configure ssl=on and provide certificates.",
+			Evidence:    settingsEvidence(nil, "ssl"),
 		})
 	}
 
@@ -1124,6 +1986,7 @@ func Run(res collect.Result) Analysis {
 				Code:        "no-statement-timeout",
 				Description: "statement_timeout is disabled",
 				Action:      "Set statement_timeout to prevent runaway queries; consider 30s-5m depending on workload.",
+				Evidence:    settingsEvidence(nil, "statement_timeout"),
 			})
 		}
 	}
@@ -1137,6 +2000,7 @@ func Run(res collect.Result) Analysis {
 				Code:        "no-idle-tx-timeout",
 				Description: "idle_in_transaction_session_timeout is disabled",
 				Action:      "Set idle_in_transaction_session_timeout to 10-60 minutes to prevent abandoned transactions.",
+				Evidence:    settingsEvidence(nil, "idle_in_transaction_session_timeout"),
 			})
 		}
 	}
@@ -1149,11 +2013,15 @@ func Run(res collect.Result) Analysis {
 	if len(res.XIDAge) > 0 {
 		criticalDBs := []string{}
 		warningDBs := []string{}
+		criticalEv, warningEv := &Evidence{}, &Evidence{}
 		for _, x := range res.XIDAge {
+			obj := Object{Kind: "database", Name: x.Datname, Metrics: map[string]float64{"xid_age": float64(x.Age), "pct_to_limit": x.PctToLimit}}
 			if x.PctToLimit >= xidCriticalPct {
 				criticalDBs = append(criticalDBs, fmt.Sprintf("%s (%.1f%%)", x.Datname, x.PctToLimit))
+				criticalEv.Objects = append(criticalEv.Objects, obj)
 			} else if x.PctToLimit >= xidWarningPct {
 				warningDBs = append(warningDBs, fmt.Sprintf("%s (%.1f%%)", x.Datname, x.PctToLimit))
+				warningEv.Objects = append(warningEv.Objects, obj)
 			}
 		}
 		if len(criticalDBs) > 0 {
@@ -1163,6 +2031,7 @@ func Run(res collect.Result) Analysis {
 				Code:        "xid-wraparound-critical",
 				Description: fmt.Sprintf("Databases approaching XID wraparound: %s. PostgreSQL will SHUT DOWN to prevent data corruption if this reaches 100%%.", strings.Join(criticalDBs, ", ")),
 				Action:      "IMMEDIATELY run VACUUM FREEZE on affected databases. Consider emergency maintenance window. Check for long-running transactions blocking vacuum.",
+				Evidence:    criticalEv,
 			})
 		}
 		if len(warningDBs) > 0 {
@@ -1172,19 +2041,51 @@ func Run(res collect.Result) Analysis {
 				Code:        "xid-age-warning",
 				Description: fmt.Sprintf("Databases with elevated XID age: %s", strings.Join(warningDBs, ", ")),
 				Action:      "Schedule VACUUM FREEZE operations. Review autovacuum_freeze_max_age settings. Ensure autovacuum is not blocked.",
+				Evidence:    warningEv,
 			})
 		}
+		oldest := res.XIDAge[0] // Already sorted by age DESC
+		rate := res.XIDRatePerHour()
+		wraparoundIn := res.WraparoundIn(oldest.Age)
+		if len(criticalDBs) == 0 && wraparoundIn > 0 {
+			// Consumption is linear, so the critical threshold comes at the same pace
+			toCritical := time.Duration(float64(wraparoundIn) * (xidCriticalPct - oldest.PctToLimit) / (100 - oldest.PctToLimit))
+			if toCritical <= xidForecastWindow {
+				source := "measured during the run"
+				if n := len(res.XIDRates); n > 0 {
+					source = fmt.Sprintf("over the last %d archived runs", n)
+				}
+				a.Warnings = append(a.Warnings, Finding{
+					Title:       "XID wraparound forecast",
+					Severity:    SeverityWarning,
+					Code:        "xid-wraparound-forecast",
+					Description: fmt.Sprintf("At %s XIDs/hour (%s), %s reaches %.0f%% of the XID limit in ~%s and the wraparound limit in ~%s unless vacuum advances its datfrozenxid.", formatThousands0(rate), source, oldest.Datname, xidCriticalPct, humanizeDuration(toCritical), humanizeDuration(wraparoundIn)),
+					Action:      "Schedule VACUUM (FREEZE) of the oldest tables in this database now, and check that nothing holds back the xmin horizon (long transactions, replication slots, prepared transactions).",
+					Evidence: &Evidence{
+						Objects: []Object{{Kind: "database", Name: oldest.Datname}},
+						Metrics: map[string]float64{"xid_age": float64(oldest.Age), "xids_per_hour": rate, "hours_to_critical": toCritical.Hours(), "hours_to_wraparound": wraparoundIn.Hours()},
+					},
+				})
+			}
+		}
 		// Info for healthy databases
-		if len(criticalDBs) == 0 && len(warningDBs) == 0 && len(res.XIDAge) > 0 {
-			oldest := res.XIDAge[0] // Already sorted by age DESC
+		if len(criticalDBs) == 0 && len(warningDBs) == 0 {
+			desc := fmt.Sprintf("Oldest XID age: %s at %.1f%% of limit", oldest.Datname, oldest.PctToLimit)
+			if wraparoundIn > 0 {
+				desc += fmt.Sprintf("; consuming %s XIDs/hour, the limit is ~%s away at this rate", formatThousands0(rate), humanizeDuration(wraparoundIn))
+			}
 			a.Infos = append(a.Infos, Finding{
 				Title:       "XID age healthy",
 				Severity:    SeverityInfo,
-				Description: fmt.Sprintf("Oldest XID age: %s at %.1f%% of limit", oldest.Datname, oldest.PctToLimit),
+				Description: desc,
 			})
 		}
 	}
 
+	if ff := res.FreezeForecast; ff != nil {
+		analyzeFreezeForecast(&a, *ff)
+	}
+
 	// 2. Idle-in-Transaction Analysis
 	if len(res.IdleInTransaction) > 0 {
 		a.Warnings = append(a.Warnings, Finding{
@@ -1193,6 +2094,7 @@ func Run(res collect.Result) Analysis {
 			Code:        "idle-in-transaction",
 			Description: fmt.Sprintf("%d sessions have been idle-in-transaction for >5 minutes. These block vacuum, hold locks, and consume connection slots.", len(res.IdleInTransaction)),
 			Action:      "Investigate application connection handling. Set idle_in_transaction_session_timeout. Consider terminating with pg_terminate_backend() if safe.",
+			Evidence:    idleInTransactionEvidence(res.IdleInTransaction),
 		})
 	}
 
@@ -1210,12 +2112,18 @@ func Run(res collect.Result) Analysis {
 		if count > 5 {
 			desc += fmt.Sprintf(" and %d more", count-5)
 		}
+		ev := &Evidence{}
+		for _, t := range res.StaleStatsTables {
+			ev.Objects = append(ev.Objects, Object{Kind: "table", Schema: t.Schema, Name: t.Table,
+				Metrics: map[string]float64{"days_since_analyze": float64(t.DaysSinceAnalyze), "mods_since_analyze": float64(t.ModsSinceAnalyze)}})
+		}
 		a.Recommendations = append(a.Recommendations, Finding{
 			Title:       "Stale table statistics",
 			Severity:    SeverityRec,
 			Code:        "stale-statistics",
 			Description: desc,
 			Action:      "Run ANALYZE on affected tables. Review autovacuum_analyze_threshold and autovacuum_analyze_scale_factor settings.",
+			Evidence:    ev,
 		})
 	}
 
@@ -1223,6 +2131,7 @@ func Run(res collect.Result) Analysis {
 	if len(res.DuplicateIndexes) > 0 {
 		totalWasted := int64(0)
 		pairs := make([]string, 0, 5)
+		ev := &Evidence{}
 		for i, di := range res.DuplicateIndexes {
 			// The smaller/less-used index is typically the one to drop
 			wastedSize := di.Index1Size
@@ -1230,6 +2139,9 @@ func Run(res collect.Result) Analysis {
 				wastedSize = di.Index2Size
 			}
 			totalWasted += wastedSize
+			ev.Objects = append(ev.Objects,
+				Object{Kind: "index", Schema: di.Schema, Name: di.Index1, Metrics: map[string]float64{"size_bytes": float64(di.Index1Size), "scans": float64(di.Index1Scans)}},
+				Object{Kind: "index", Schema: di.Schema, Name: di.Index2, Metrics: map[string]float64{"size_bytes": float64(di.Index2Size), "scans": float64(di.Index2Scans)}})
 			if i < 5 {
 				pairs = append(pairs, fmt.Sprintf("%s.%s ↔ %s", di.Schema, di.Index1, di.Index2))
 			}
@@ -1240,93 +2152,3653 @@ func Run(res collect.Result) Analysis {
 			Code:        "duplicate-indexes",
 			Description: fmt.Sprintf("%d index pairs have identical column definitions, wasting ~%.2f GB: %s", len(res.DuplicateIndexes), bytesToGB(totalWasted), strings.Join(pairs, "; ")),
 			Action:      "Compare scan counts and drop the less-used duplicate. Verify no unique constraints depend on them first.",
+			Evidence:    ev,
 		})
 	}
 
-	// 5. Invalid Indexes Analysis
-	if len(res.InvalidIndexes) > 0 {
-		names := make([]string, 0, len(res.InvalidIndexes))
+	// Redundant (prefix-covered) indexes
+	if len(res.RedundantIndexes) > 0 {
 		totalSize := int64(0)
+		examples := make([]string, 0, 5)
+		ev := &Evidence{}
+		for i, ri := range res.RedundantIndexes {
+			totalSize += ri.SizeBytes
+			ev.Objects = append(ev.Objects, Object{Kind: "index", Schema: ri.Schema, Name: ri.Index,
+				Metrics: map[string]float64{"size_bytes": float64(ri.SizeBytes), "scans": float64(ri.Scans)}})
+			if i < 5 {
+				examples = append(examples, fmt.Sprintf("%s.%s (%s; %.1f MB, %s scans) covered by %s (%s; %s scans)",
+					ri.Schema, ri.Index, ri.Columns, float64(ri.SizeBytes)/(1024*1024), formatThousands0(float64(ri.Scans)),
+					ri.CoveredBy, ri.CoveredByColumns, formatThousands0(float64(ri.CoveredByScans))))
+			}
+		}
+		a.Recommendations = append(a.Recommendations, Finding{
+			Title:       "Redundant indexes",
+			Severity:    SeverityRec,
+			Code:        "redundant-indexes",
+			Description: fmt.Sprintf("%d indexes are a leading prefix of a wider index on the same table, using ~%.2f GB: %s", len(res.RedundantIndexes), bytesToGB(totalSize), strings.Join(examples, "; ")),
+			Action:      "Drop the narrower index; the wider one serves the same lookups and ordering at a slightly higher per-scan cost. Check the wider index is not about to be dropped or replaced first.",
+			Evidence:    ev,
+		})
+	}
+
+	// 5. Invalid Indexes Analysis
+	if len(res.InvalidIndexes) > 0 {
+		names := make([]string, 0, len(res.InvalidIndexes))
+		totalSize := int64(0)
+		ev := &Evidence{}
 		for _, ii := range res.InvalidIndexes {
 			names = append(names, fmt.Sprintf("%s.%s (%s)", ii.Schema, ii.Name, ii.Reason))
 			totalSize += ii.SizeBytes
+			ev.Objects = append(ev.Objects, Object{Kind: "index", Schema: ii.Schema, Name: ii.Name, Metrics: map[string]float64{"size_bytes": float64(ii.SizeBytes)}})
+		}
+		a.Warnings = append(a.Warnings, Finding{
+			Title:       "Invalid indexes found",
+			Severity:    SeverityWarning,
+			Code:        "invalid-indexes",
+			Description: fmt.Sprintf("%d invalid indexes wasting %.2f GB and not providing any benefit: %s", len(res.InvalidIndexes), bytesToGB(totalSize), strings.Join(names, ", ")),
+			Action:      "Drop invalid indexes with DROP INDEX and recreate with CREATE INDEX CONCURRENTLY. Investigate why they failed (disk space, locks, errors).",
+			Evidence:    ev,
+		})
+	}
+
+	// 6. Foreign Key Missing Indexes Analysis
+	if len(res.FKMissingIndexes) > 0 {
+		// Prioritize by table size (rows)
+		count := len(res.FKMissingIndexes)
+		fks := make([]string, 0, 5)
+		for i, fk := range res.FKMissingIndexes {
+			if i >= 5 {
+				break
+			}
+			fks = append(fks, fmt.Sprintf("%s.%s(%s)", fk.Schema, fk.Table, fk.Columns))
+		}
+		desc := fmt.Sprintf("%d foreign keys lack supporting indexes, causing slow JOINs and cascading deletes: %s", count, strings.Join(fks, ", "))
+		if count > 5 {
+			desc += fmt.Sprintf(" and %d more", count-5)
+		}
+		ev := &Evidence{}
+		for _, fk := range res.FKMissingIndexes {
+			ev.Objects = append(ev.Objects, Object{Kind: "table", Schema: fk.Schema, Name: fk.Table, Metrics: map[string]float64{"rows": float64(fk.TableRows)}})
+		}
+		a.Recommendations = append(a.Recommendations, Finding{
+			Title:       "Foreign keys without indexes",
+			Severity:    SeverityRec,
+			Code:        "fk-missing-index",
+			Description: desc,
+			Action:      "Create indexes on FK columns. Example: CREATE INDEX CONCURRENTLY ON table(fk_column). Review 'FK Missing Indexes' table for suggested DDL.",
+			Evidence:    ev,
+		})
+	}
+
+	// 7. Sequence Exhaustion Analysis
+	if len(res.SequenceHealth) > 0 {
+		criticalSeqs := []string{}
+		warningSeqs := []string{}
+		criticalEv, warningEv := &Evidence{}, &Evidence{}
+		for _, sq := range res.SequenceHealth {
+			obj := Object{Kind: "sequence", Schema: sq.Schema, Name: sq.Name, Metrics: map[string]float64{"pct_used": sq.PctUsed, "calls_left": float64(sq.CallsLeft)}}
+			if sq.PctUsed >= sequenceCriticalPct {
+				criticalSeqs = append(criticalSeqs, fmt.Sprintf("%s.%s (%.1f%%)", sq.Schema, sq.Name, sq.PctUsed))
+				criticalEv.Objects = append(criticalEv.Objects, obj)
+			} else if sq.PctUsed >= sequenceWarningPct {
+				warningSeqs = append(warningSeqs, fmt.Sprintf("%s.%s (%.1f%%)", sq.Schema, sq.Name, sq.PctUsed))
+				warningEv.Objects = append(warningEv.Objects, obj)
+			}
+		}
+		if len(criticalSeqs) > 0 {
+			a.Warnings = append(a.Warnings, Finding{
+				Title:       "Sequences near exhaustion",
+				Severity:    SeverityWarning,
+				Code:        "sequence-exhaustion-critical",
+				Description: fmt.Sprintf("Sequences >%d%% exhausted will cause INSERT failures: %s", int(sequenceCriticalPct), strings.Join(criticalSeqs, ", ")),
+				Action:      "Alter sequences to use bigint (ALTER SEQUENCE ... AS bigint) or reset with appropriate min/max values. Plan migration before exhaustion.",
+				Evidence:    criticalEv,
+			})
+		}
+		if len(warningSeqs) > 0 {
+			a.Recommendations = append(a.Recommendations, Finding{
+				Title:       "Sequences approaching exhaustion",
+				Severity:    SeverityRec,
+				Code:        "sequence-exhaustion-warning",
+				Description: fmt.Sprintf("Sequences >%d%% used: %s", int(sequenceWarningPct), strings.Join(warningSeqs, ", ")),
+				Action:      "Monitor sequence usage. Plan to convert to bigint before reaching limit.",
+				Evidence:    warningEv,
+			})
+		}
+	}
+
+	// 8. Prepared Transactions (2PC) Analysis
+	if len(res.PreparedXacts) > 0 {
+		a.Warnings = append(a.Warnings, Finding{
+			Title:       "Prepared transactions detected",
+			Severity:    SeverityWarning,
+			Code:        "prepared-transactions",
+			Description: fmt.Sprintf("%d prepared (2PC) transactions found. These block vacuum, prevent XID advancement, and hold locks indefinitely until committed or rolled back.", len(res.PreparedXacts)),
+			Action:      "Investigate orphaned transactions with pg_prepared_xacts. Commit with COMMIT PREPARED 'gid' or rollback with ROLLBACK PREPARED 'gid'. Consider disabling max_prepared_transactions if not using 2PC.",
+			Evidence:    preparedEvidence(res.PreparedXacts),
+		})
+	}
+
+	// 9. Standby query cancellations (recovery conflicts)
+	if sc := res.StandbyConflicts; sc != nil {
+		analyzeStandbyConflicts(&a, *sc)
+	}
+
+	// 10. N+1 query patterns
+	analyzeNPlusOne(&a, res.Statements)
+
+	// 11. Subtransaction overload
+	if st := res.Subtransactions; st != nil {
+		analyzeSubtransactions(&a, *st)
+	}
+
+	// 12. Vacuum held back by replicas and slots
+	if vh := res.VacuumHorizon; vh != nil {
+		analyzeVacuumHorizon(&a, *vh, res.StandbyConflicts)
+	}
+
+	// 13. Clock skew and time zones
+	if ci := res.ConnInfo; ci.TimeZone != "" {
+		if o.deterministic {
+			ci.ClockSkew = 0
+		}
+		analyzeClock(&a, ci)
+	}
+
+	// 14. Configuration file errors
+	if cf := res.ConfigFiles; cf != nil {
+		analyzeConfigFiles(&a, *cf)
+	}
+
+	// 15. Huge pages and OS memory settings
+	analyzeOSMemory(&a, res.Settings, res.OSMemory)
+
+	// 16. CPU, NUMA and cgroup limits
+	analyzeOSCPU(&a, res.Settings, res.OSCPU, wl)
+
+	// 17. Container memory limits
+	analyzeMemoryBudget(&a, res, setting)
+
+	// 18. Patroni cluster state
+	if pc := res.Patroni; pc != nil {
+		analyzePatroni(&a, *pc, res.ReplicationStats, res.ConnInfo.InRecovery, o)
+	}
+
+	// 19. repmgr and pg_auto_failover node registrations
+	if ha := res.HA; ha != nil {
+		analyzeHA(&a, *ha, res.ReplicationStats, res.ConnInfo.InRecovery)
+	}
+
+	// 20. WAL archiving
+	if wa := res.WALArchiving; wa != nil && wa.Mode != "off" {
+		analyzeWALArchiving(&a, *wa)
+	}
+
+	// 21. Restore verification hook
+	if rc := res.RestoreCheck; rc != nil {
+		analyzeRestoreCheck(&a, *rc)
+	}
+
+	// 22. Service level objectives
+	for _, st := range res.SLOs {
+		analyzeSLO(&a, st)
+	}
+
+	// 23. Query tail latency
+	analyzeTailLatency(&a, res.Statements)
+
+	// 24. auto_explain configuration
+	analyzeAutoExplain(&a, res.Settings, res.Statements)
+
+	// 25. Hidden and truncated query texts
+	analyzeQueryTexts(&a, res)
+
+	// 26. Tablespace placement
+	analyzeTablespaces(&a, res)
+
+	// 27. Inactive and lost replication slots
+	analyzeReplicationSlots(&a, res.ReplicationSlots)
+
+	// 28. Synchronous replication against the connected standbys
+	if !res.ConnInfo.InRecovery {
+		analyzeSyncReplication(&a, res.ReplicationStats, setting)
+	}
+
+	// 29. Connection churn from session statistics
+	analyzeSessionChurn(&a, res.SessionStats, pool)
+
+	// 30. Storage growth forecast from archived sizes
+	analyzeStorageForecast(&a, res)
+
+	// 31. Connection peaks and max_connections forecast from archived runs
+	analyzeConnectionForecast(&a, res.ConnectionHistory, res.ConnInfo.MaxConnections, pool)
+
+	// 32. Skewed value distributions of the columns top statements filter on
+	analyzeColumnSkew(&a, res.ColumnStats, res.Tables)
+
+	// 33. Append-only tables holding old data: retention and archival
+	analyzeRetention(&a, res.RetentionTables)
+
+	// 34. Client libraries: outdated drivers, mixed versions, weak transport
+	analyzeClientFingerprints(&a, res.ClientFingerprints, res.ConnInfo.SSL)
+
+	// 35. Per-role idle timeouts from observed idle times
+	analyzeRoleIdleTimeouts(&a, res.RoleIdle)
+
+	// 36. Login roles without connection limits or statement timeouts
+	analyzeRoleLimits(&a, res)
+
+	// 37. MD5 and cleartext passwords
+	analyzePasswords(&a, res)
+
+	// 38. pgaudit: what is audited and compliance gaps
+	analyzePgAudit(&a, res.Settings, res.ExtensionStats)
+
+	// 39. template1 objects, default privileges for PUBLIC, database locales
+	analyzeTemplates(&a, res)
+
+	// 40. SQL_ASCII databases and applications spanning collations
+	analyzeDatabaseLocales(&a, res.DBs)
+
+	// 41. Trends against earlier runs of the target (-history)
+	analyzeTrends(&a, res, o)
+
+	dropEmptyEvidence(a.Warnings, a.Recommendations, a.Infos)
+
+	// 42. Confidence, expected impact and effort of the actionable findings
+	rateFindings(a.Warnings, res, o)
+	rateFindings(a.Recommendations, res, o)
+
+	// 43. Public documentation per finding code
+	linkDocs(a.Warnings, a.Recommendations, a.Infos)
+	return a
+}
+
+// dropEmptyEvidence clears evidence left without objects, metrics or query
+// ids, e.g. a slow query group whose statements have no query id.
+func dropEmptyEvidence(lists ...[]Finding) {
+	for _, list := range lists {
+		for i := range list {
+			if e := list[i].Evidence; e != nil && len(e.Objects) == 0 && len(e.Metrics) == 0 && len(e.QueryIDs) == 0 {
+				list[i].Evidence = nil
+			}
+		}
+	}
+}
+
+// analyzeOSMemory recommends huge pages for large shared_buffers and, when
+// the server runs on this machine, kernel settings PostgreSQL documents for
+// self-hosted deployments: no transparent huge pages and strict overcommit.
+func analyzeOSMemory(a *Analysis, settings []collect.Setting, om *collect.OSMemory) {
+	setting := func(name string) (collect.Setting, bool) {
+		for _, s := range settings {
+			if s.Name == name {
+				return s, true
+			}
+		}
+		return collect.Setting{}, false
+	}
+
+	hp, _ := setting("huge_pages")
+	sb, _ := asBytes(setting("shared_buffers"))
+	if hp.Val != "" && hp.Val != "on" && sb >= hugePagesMinSharedBuffers {
+		pageSize := int64(defaultHugePageSize)
+		if om != nil && om.HugePageSizeBytes > 0 {
+			pageSize = om.HugePageSizeBytes
+		}
+		// shared_memory_size_in_huge_pages (PostgreSQL 15+) includes the
+		// segments beyond shared_buffers
+		need := (sb + pageSize - 1) / pageSize
+		if s, ok := setting("shared_memory_size_in_huge_pages"); ok {
+			if n, err := strconv.ParseInt(s.Val, 10, 64); err == nil && n > 0 {
+				need = n
+			}
+		}
+		status, _ := setting("huge_pages_status")
+		var why string
+		switch {
+		case hp.Val == "off":
+			why = "huge_pages = off"
+		case status.Val == "off":
+			why = "huge_pages = try fell back to regular pages (huge_pages_status = off)"
+		case om != nil && om.HugePagesTotal < need:
+			why = fmt.Sprintf("huge_pages = try, but only %s of the %s huge pages needed are reserved, so the server falls back to regular pages", formatThousands0(float64(om.HugePagesTotal)), formatThousands0(float64(need)))
+		}
+		if why != "" {
+			a.Recommendations = append(a.Recommendations, Finding{
+				Title:       "Huge pages not used",
+				Severity:    SeverityRec,
+				Code:        "huge-pages",
+				Description: fmt.Sprintf("shared_buffers is %.1f GB and %s. Regular 4 kB pages cost every backend its own page table entries for shared memory and more TLB misses.", bytesToGB(sb), why),
+				Action:      fmt.Sprintf("Reserve about %s huge pages (vm.nr_hugepages = %d in /etc/sysctl.d; postgres -C shared_memory_size_in_huge_pages prints the exact number) and set huge_pages = on, so a missing reservation fails at start instead of silently falling back; requires a restart.", formatThousands0(float64(need)), need),
+				Evidence: settingsEvidence(map[string]float64{"shared_buffers_bytes": float64(sb), "huge_pages_needed": float64(need)},
+					"huge_pages", "shared_buffers"),
+			})
+		}
+	}
+
+	if om == nil {
+		return
+	}
+	if om.THPEnabled == "always" {
+		a.Recommendations = append(a.Recommendations, Finding{
+			Title:       "Transparent huge pages enabled",
+			Severity:    SeverityRec,
+			Code:        "transparent-huge-pages",
+			Description: fmt.Sprintf("Transparent huge pages are set to always (defrag = %s): background compaction and page collapsing cause latency spikes and memory bloat for PostgreSQL backends.", om.THPDefrag),
+			Action:      "Set transparent_hugepage=never on the kernel command line (or echo never > /sys/kernel/mm/transparent_hugepage/enabled at boot) and use explicit huge pages with huge_pages = on instead.",
+		})
+	}
+	if om.OvercommitMemory != 2 {
+		a.Recommendations = append(a.Recommendations, Finding{
+			Title:       "Memory overcommit allowed",
+			Severity:    SeverityRec,
+			Code:        "vm-overcommit",
+			Description: fmt.Sprintf("vm.overcommit_memory = %d: under memory pressure the kernel OOM killer picks a process to kill, and a killed backend makes the postmaster restart every session.", om.OvercommitMemory),
+			Action:      "On a dedicated database host set vm.overcommit_memory = 2 and size vm.overcommit_ratio so the commit limit covers RAM not reserved for huge pages; allocations then fail with an error instead of the OOM killer ending sessions.",
+			Evidence:    &Evidence{Metrics: map[string]float64{"overcommit_memory": float64(om.OvercommitMemory)}},
+		})
+	}
+}
+
+// analyzeOSCPU compares the worker settings with each other and, with
+// -local-os, with the CPUs, NUMA layout and cgroup limits of the host.
+func analyzeOSCPU(a *Analysis, settings []collect.Setting, cpu *collect.OSCPU, wl Workload) {
+	setting := func(name string) (collect.Setting, bool) {
+		for _, s := range settings {
+			if s.Name == name {
+				return s, true
+			}
+		}
+		return collect.Setting{}, false
+	}
+	intSetting := func(name string) (int, bool) {
+		s, ok := setting(name)
+		n, err := strconv.Atoi(s.Val)
+		return n, ok && err == nil
+	}
+	mwp, okMWP := intSetting("max_worker_processes")
+	mpw, okMPW := intSetting("max_parallel_workers")
+	if okMWP && okMPW && mpw > mwp {
+		a.Recommendations = append(a.Recommendations, Finding{
+			Title:       "Parallel workers capped by max_worker_processes",
+			Severity:    SeverityRec,
+			Code:        "worker-processes-low",
+			Description: fmt.Sprintf("max_parallel_workers = %d exceeds max_worker_processes = %d, the pool parallel workers share with logical replication and extension background workers, so fewer parallel workers start than configured.", mpw, mwp),
+			Action:      fmt.Sprintf("Raise max_worker_processes to at least %d plus the background workers extensions and logical replication need (requires a restart), or lower max_parallel_workers.", mpw),
+			Evidence: settingsEvidence(map[string]float64{"max_worker_processes": float64(mwp), "max_parallel_workers": float64(mpw)},
+				"max_worker_processes", "max_parallel_workers"),
+		})
+	}
+
+	if cpu == nil {
+		return
+	}
+	cpus := cpu.EffectiveCPUs()
+	limit := fmt.Sprintf("%d CPUs", cpu.CPUs)
+	if cpus < float64(cpu.CPUs) {
+		limit = fmt.Sprintf("a cgroup quota of %.1f CPUs (of %d)", cpus, cpu.CPUs)
+	}
+	if okMPW && cpus > 0 && float64(mpw) > cpus {
+		a.Recommendations = append(a.Recommendations, Finding{
+			Title:       "More parallel workers than CPUs",
+			Severity:    SeverityRec,
+			Code:        "parallel-workers-cpus",
+			Description: fmt.Sprintf("max_parallel_workers = %d on a host with %s: parallel queries compete with each other and with regular sessions for CPU instead of finishing faster.", mpw, limit),
+			Action:      fmt.Sprintf("Set max_parallel_workers to at most %d and max_parallel_workers_per_gather to a fraction of it.", int(cpus)),
+			Evidence:    settingsEvidence(map[string]float64{"max_parallel_workers": float64(mpw), "cpus": cpus}, "max_parallel_workers"),
+		})
+	}
+	if gather, ok := intSetting("max_parallel_workers_per_gather"); ok && cpus > 0 && float64(gather) >= cpus {
+		a.Recommendations = append(a.Recommendations, Finding{
+			Title:       "Parallel workers per query exceed CPUs",
+			Severity:    SeverityRec,
+			Code:        "parallel-gather-cpus",
+			Description: fmt.Sprintf("max_parallel_workers_per_gather = %d on a host with %s lets a single query occupy every CPU.", gather, limit),
+			Action: wl.advice("Keep max_parallel_workers_per_gather well below the CPU count (2-4 is typical for OLTP).",
+				"Keep max_parallel_workers_per_gather at 2 or less: parallel workers of one query take CPUs from concurrent OLTP sessions.",
+				"Keep max_parallel_workers_per_gather at about half the CPU count so a few analytical queries can run in parallel at once."),
+			Evidence: settingsEvidence(map[string]float64{"max_parallel_workers_per_gather": float64(gather), "cpus": cpus}, "max_parallel_workers_per_gather"),
+		})
+	}
+	if len(cpu.NUMANodes) > 1 && cpu.ZoneReclaimMode != 0 {
+		a.Recommendations = append(a.Recommendations, Finding{
+			Title:       "NUMA zone reclaim enabled",
+			Severity:    SeverityRec,
+			Code:        "numa-zone-reclaim",
+			Description: fmt.Sprintf("The host has %d NUMA nodes and vm.zone_reclaim_mode = %d: the kernel evicts page cache on the local node rather than use memory of another node, which shrinks the OS cache PostgreSQL relies on.", len(cpu.NUMANodes), cpu.ZoneReclaimMode),
+			Action:      "Set vm.zone_reclaim_mode = 0; shared_buffers is accessed from every node anyway, so consider starting the server with numactl --interleave=all.",
+			Evidence:    &Evidence{Metrics: map[string]float64{"numa_nodes": float64(len(cpu.NUMANodes)), "zone_reclaim_mode": float64(cpu.ZoneReclaimMode)}},
+		})
+	}
+}
+
+// memoryBudget returns the memory available to the server: the cgroup
+// memory limit of a container (limited is true) or the host RAM, and 0 when
+// neither is known (remote runs).
+func memoryBudget(res collect.Result) (bytes int64, limited bool) {
+	if om := res.OSMemory; om != nil {
+		bytes = om.MemTotalBytes
+	}
+	for _, l := range []int64{osMemoryLimit(res.OSMemory), osCPUMemoryLimit(res.OSCPU)} {
+		if l > 0 && (bytes == 0 || l < bytes) {
+			bytes, limited = l, true
+		}
+	}
+	return bytes, limited
+}
+
+func osMemoryLimit(om *collect.OSMemory) int64 {
+	if om == nil {
+		return 0
+	}
+	return om.CgroupMemoryLimitBytes
+}
+
+func osCPUMemoryLimit(c *collect.OSCPU) int64 {
+	if c == nil {
+		return 0
+	}
+	return c.MemoryLimitBytes
+}
+
+// pgSize formats b as a PostgreSQL memory setting value, rounded down to
+// whole megabytes (whole gigabytes from 4GB).
+func pgSize(b int64) string {
+	mb := b >> 20
+	if mb >= 4096 {
+		return fmt.Sprintf("%dGB", mb>>10)
+	}
+	return fmt.Sprintf("%dMB", mb)
+}
+
+// sharedBuffersAdvice suggests a shared_buffers size for the memory budget.
+func sharedBuffersAdvice(budget int64, limited bool) string {
+	if budget == 0 {
+		return "Set shared_buffers to 25-40% of available RAM for dedicated PostgreSQL servers."
+	}
+	if limited {
+		// Page cache counts against the container limit too
+		return fmt.Sprintf("Set shared_buffers to about %s (25%% of the %s; the page cache counts against the limit too).", pgSize(budget/4), memoryBudgetName(budget, limited))
+	}
+	return fmt.Sprintf("Set shared_buffers to %s-%s (25-40%% of the %s) for a dedicated PostgreSQL server.", pgSize(budget/4), pgSize(budget*2/5), memoryBudgetName(budget, limited))
+}
+
+// workMemBudget is the work_mem the memory left after shared_buffers
+// supports with every connection running up to three sort or hash nodes.
+func workMemBudget(budget, sharedBuffers int64, maxConnections int) int64 {
+	return (budget - sharedBuffers) / (int64(maxConnections) * 3)
+}
+
+// memoryBudgetName describes the memory budget in findings.
+func memoryBudgetName(budget int64, limited bool) string {
+	if limited {
+		return fmt.Sprintf("%.1f GB container memory limit", bytesToGB(budget))
+	}
+	return fmt.Sprintf("%.1f GB of host RAM", bytesToGB(budget))
+}
+
+// analyzeMemoryBudget warns when shared_buffers or effective_cache_size do
+// not fit the cgroup memory limit of a containerized server, where the OOM
+// killer rather than the host RAM bounds memory.
+func analyzeMemoryBudget(a *Analysis, res collect.Result, setting func(string) (collect.Setting, bool)) {
+	budget, limited := memoryBudget(res)
+	if !limited {
+		return
+	}
+	sb, _ := asBytes(setting("shared_buffers"))
+	ecs, _ := asBytes(setting("effective_cache_size"))
+	if sb > budget/2 {
+		a.Warnings = append(a.Warnings, Finding{
+			Title:       "shared_buffers close to the cgroup memory limit",
+			Severity:    SeverityWarning,
+			Code:        "cgroup-memory-limit",
+			Description: fmt.Sprintf("shared_buffers is %.1f GB of a %s: backend memory, work_mem and page cache share the rest, and exceeding the limit gets a process OOM-killed, restarting every session.", bytesToGB(sb), memoryBudgetName(budget, limited)),
+			Action:      fmt.Sprintf("Set shared_buffers to about %s (25%% of the limit), or raise the limit.", pgSize(budget/4)),
+			Evidence:    settingsEvidence(map[string]float64{"shared_buffers_bytes": float64(sb), "memory_limit_bytes": float64(budget)}, "shared_buffers"),
+		})
+	} else if ecs > budget {
+		a.Recommendations = append(a.Recommendations, Finding{
+			Title:       "effective_cache_size above the cgroup memory limit",
+			Severity:    SeverityRec,
+			Code:        "cgroup-effective-cache-size",
+			Description: fmt.Sprintf("effective_cache_size is %.1f GB but the server runs under a %s, so the planner assumes more cached data than can fit.", bytesToGB(ecs), memoryBudgetName(budget, limited)),
+			Action:      fmt.Sprintf("Set effective_cache_size to about %s (50-75%% of the limit).", pgSize(budget*3/5)),
+			Evidence:    settingsEvidence(map[string]float64{"effective_cache_size_bytes": float64(ecs), "memory_limit_bytes": float64(budget)}, "effective_cache_size"),
+		})
+	}
+}
+
+// analyzePatroni flags a paused or leaderless Patroni cluster, recent
+// failovers and, when connected to the primary, standbys whose Patroni role
+// does not match pg_stat_replication (Patroni uses the member name as
+// application_name).
+func analyzePatroni(a *Analysis, pc collect.PatroniCluster, repl []collect.ReplicationStat, inRecovery bool, o options) {
+	if pc.Paused {
+		a.Warnings = append(a.Warnings, Finding{
+			Title:       "Patroni cluster paused",
+			Severity:    SeverityWarning,
+			Code:        "patroni-paused",
+			Description: "Patroni is in maintenance mode: it does not fail over if the leader goes down.",
+			Action:      "Resume with patronictl resume once maintenance is over.",
+		})
+	}
+	leader, ok := pc.Leader()
+	if !ok && len(pc.Members) > 0 {
+		a.Warnings = append(a.Warnings, Finding{
+			Title:       "Patroni cluster without leader",
+			Severity:    SeverityWarning,
+			Code:        "patroni-no-leader",
+			Description: fmt.Sprintf("None of the %d Patroni members holds the leader key.", len(pc.Members)),
+			Action:      "Check patronictl list and the Patroni logs and DCS (etcd, Consul, ZooKeeper) connectivity of every member.",
+		})
+	}
+
+	var recent []string
+	for _, f := range pc.History {
+		if age, ok := o.elapsed(f.At); ok && age <= patroniRecentFailover {
+			item := fmt.Sprintf("%s (timeline %d", formatLocalTime(f.At), f.Timeline+1)
+			if f.NewLeader != "" {
+				item += ", new leader " + f.NewLeader
+			}
+			recent = append(recent, item+")")
+		}
+	}
+	if len(recent) > 0 {
+		a.Infos = append(a.Infos, Finding{
+			Title:       "Recent Patroni failovers",
+			Severity:    SeverityInfo,
+			Code:        "patroni-failovers",
+			Description: fmt.Sprintf("%d leader change(s) in the last %s: %s.", len(recent), humanizeDuration(patroniRecentFailover), listFirst(recent, 5, "; ")),
+		})
+	}
+
+	// pg_stat_replication is only meaningful on the leader
+	if inRecovery || !ok || leader.Role != "leader" {
+		return
+	}
+	streaming := map[string]collect.ReplicationStat{}
+	for _, r := range repl {
+		streaming[r.Name] = r
+	}
+	members := map[string]bool{}
+	var missing, notSync []string
+	missingEv, notSyncEv := &Evidence{}, &Evidence{}
+	for _, m := range pc.Members {
+		members[m.Name] = true
+		if !m.IsStandby() {
+			continue
+		}
+		r, ok := streaming[m.Name]
+		switch {
+		case !ok:
+			missing = append(missing, fmt.Sprintf("%s (%s, %s)", m.Name, m.Role, m.State))
+			missingEv.Objects = append(missingEv.Objects, Object{Kind: "standby", Name: m.Name})
+		case m.Role == "sync_standby" && r.SyncState != "sync" && r.SyncState != "quorum":
+			notSync = append(notSync, fmt.Sprintf("%s (sync_state %s)", m.Name, r.SyncState))
+			notSyncEv.Objects = append(notSyncEv.Objects, Object{Kind: "standby", Name: m.Name})
+		}
+	}
+	var unknown []string
+	unknownEv := &Evidence{}
+	for _, r := range repl {
+		if !members[r.Name] {
+			unknown = append(unknown, r.Name)
+			unknownEv.Objects = append(unknownEv.Objects, Object{Kind: "standby", Name: r.Name})
+		}
+	}
+	if len(missing) > 0 {
+		a.Warnings = append(a.Warnings, Finding{
+			Title:       "Patroni replicas not streaming",
+			Severity:    SeverityWarning,
+			Code:        "patroni-replica-missing",
+			Description: fmt.Sprintf("Patroni lists standbys without a replication connection in pg_stat_replication on the leader %s: %s.", leader.Name, strings.Join(missing, ", ")),
+			Action:      "Check the replica's PostgreSQL log and patronictl list; a replica that cannot stream falls behind and is not a failover candidate.",
+			Evidence:    missingEv,
+		})
+	}
+	if len(notSync) > 0 {
+		a.Warnings = append(a.Warnings, Finding{
+			Title:       "Patroni synchronous standby not synchronous",
+			Severity:    SeverityWarning,
+			Code:        "patroni-sync-mismatch",
+			Description: fmt.Sprintf("Patroni reports sync_standby members that PostgreSQL does not replicate to synchronously: %s.", strings.Join(notSync, ", ")),
+			Action:      "Compare synchronous_standby_names with patronictl list; a failover to a standby that was not synchronous can lose committed transactions.",
+			Evidence:    notSyncEv,
+		})
+	}
+	if len(unknown) > 0 {
+		a.Recommendations = append(a.Recommendations, Finding{
+			Title:       "Standbys outside Patroni",
+			Severity:    SeverityRec,
+			Code:        "patroni-unknown-standby",
+			Description: fmt.Sprintf("pg_stat_replication has connections that are not Patroni members: %s.", strings.Join(unknown, ", ")),
+			Action:      "Expected for backup tools (pg_basebackup, pg_receivewal) and cascading or external standbys; otherwise check for a member that lost its DCS registration.",
+			Evidence:    unknownEv,
+		})
+	}
+}
+
+// analyzeHA flags repmgr nodes that are inactive, unmonitored or not
+// streaming from the connected primary, and pg_auto_failover nodes that are
+// unhealthy, silent or not in their assigned state.
+func analyzeHA(a *Analysis, ha collect.HAMetadata, repl []collect.ReplicationStat, inRecovery bool) {
+	streaming := map[string]bool{}
+	for _, r := range repl {
+		streaming[r.Name] = true
+	}
+	primaries := map[string]bool{}
+	for _, n := range ha.RepmgrNodes {
+		if n.Type == "primary" && n.Active {
+			primaries[n.Name] = true
+		}
+	}
+	var inactive, unmonitored, notStreaming []string
+	inactiveEv, unmonitoredEv, notStreamingEv := &Evidence{}, &Evidence{}, &Evidence{}
+	for _, n := range ha.RepmgrNodes {
+		node := Object{Kind: "standby", Name: n.Name}
+		switch {
+		case !n.Active:
+			inactive = append(inactive, fmt.Sprintf("%s (%s, id %d)", n.Name, n.Type, n.ID))
+			inactiveEv.Objects = append(inactiveEv.Objects, node)
+			continue
+		case n.Type != "standby":
+			continue
+		}
+		if n.MonitorAge > haStaleReport {
+			unmonitored = append(unmonitored, fmt.Sprintf("%s (last sample %s ago)", n.Name, humanizeDuration(n.MonitorAge)))
+			node.Metrics = map[string]float64{"monitor_age_seconds": n.MonitorAge.Seconds()}
+			unmonitoredEv.Objects = append(unmonitoredEv.Objects, node)
+		}
+		// repmgr sets application_name to the node name
+		if !inRecovery && len(repl) > 0 && primaries[n.Upstream] && !streaming[n.Name] {
+			notStreaming = append(notStreaming, n.Name)
+			notStreamingEv.Objects = append(notStreamingEv.Objects, Object{Kind: "standby", Name: n.Name})
+		}
+	}
+	if len(notStreaming) > 0 {
+		a.Warnings = append(a.Warnings, Finding{
+			Title:       "repmgr standbys not streaming",
+			Severity:    SeverityWarning,
+			Code:        "repmgr-standby-not-streaming",
+			Description: fmt.Sprintf("Active repmgr standbys of this primary have no connection in pg_stat_replication: %s.", strings.Join(notStreaming, ", ")),
+			Action:      "Check repmgr cluster show and the standby's PostgreSQL log; a standby that does not stream falls behind and is a poor promotion candidate.",
+			Evidence:    notStreamingEv,
+		})
+	}
+	if len(unmonitored) > 0 {
+		a.Warnings = append(a.Warnings, Finding{
+			Title:       "repmgrd monitoring gap",
+			Severity:    SeverityWarning,
+			Code:        "repmgr-monitoring-gap",
+			Description: fmt.Sprintf("repmgrd has not recorded monitoring history for these standbys for over %s: %s.", humanizeDuration(haStaleReport), strings.Join(unmonitored, ", ")),
+			Action:      "Check that repmgrd runs on every node (repmgr service status); without it automatic failover does not happen.",
+			Evidence:    unmonitoredEv,
+		})
+	}
+	if len(inactive) > 0 {
+		a.Recommendations = append(a.Recommendations, Finding{
+			Title:       "Inactive repmgr nodes",
+			Severity:    SeverityRec,
+			Code:        "repmgr-inactive-node",
+			Description: fmt.Sprintf("repmgr.nodes keeps registrations marked inactive: %s.", strings.Join(inactive, ", ")),
+			Action:      "Rejoin the node (repmgr node rejoin) or remove the stale registration with repmgr standby unregister --node-id=<id>.",
+			Evidence:    inactiveEv,
+		})
+	}
+
+	var unhealthy, silent, transitioning []string
+	unhealthyEv, transitioningEv := &Evidence{}, &Evidence{}
+	for _, n := range ha.AutoFailoverNodes {
+		name := fmt.Sprintf("%s (%s:%d, formation %s group %d)", n.Name, n.Host, n.Port, n.Formation, n.Group)
+		switch {
+		case n.ReportAge > haStaleReport:
+			silent = append(silent, fmt.Sprintf("%s, last report %s ago", name, humanizeDuration(n.ReportAge)))
+			unhealthyEv.Objects = append(unhealthyEv.Objects, Object{Kind: "standby", Name: n.Name})
+		case !n.PgRunning || n.Health == 0:
+			unhealthy = append(unhealthy, name)
+			unhealthyEv.Objects = append(unhealthyEv.Objects, Object{Kind: "standby", Name: n.Name})
+		}
+		if n.GoalState != n.ReportedState {
+			transitioning = append(transitioning, fmt.Sprintf("%s: %s, assigned %s", n.Name, n.ReportedState, n.GoalState))
+			transitioningEv.Objects = append(transitioningEv.Objects, Object{Kind: "standby", Name: n.Name})
+		}
+	}
+	if len(silent) > 0 || len(unhealthy) > 0 {
+		var desc []string
+		if len(silent) > 0 {
+			desc = append(desc, "keepers not reporting: "+strings.Join(silent, "; "))
+		}
+		if len(unhealthy) > 0 {
+			desc = append(desc, "PostgreSQL down or failing health checks: "+strings.Join(unhealthy, "; "))
+		}
+		a.Warnings = append(a.Warnings, Finding{
+			Title:       "pg_auto_failover nodes unhealthy",
+			Severity:    SeverityWarning,
+			Code:        "autofailover-unhealthy",
+			Description: strings.Join(desc, ". ") + ".",
+			Action:      "Check pg_autoctl show state and the pg_autoctl service on the listed nodes; the monitor cannot fail over to a node it does not hear from.",
+			Evidence:    unhealthyEv,
+		})
+	}
+	if len(transitioning) > 0 {
+		a.Recommendations = append(a.Recommendations, Finding{
+			Title:       "pg_auto_failover nodes not in their assigned state",
+			Severity:    SeverityRec,
+			Code:        "autofailover-state-mismatch",
+			Description: fmt.Sprintf("Nodes whose reported state differs from the goal state assigned by the monitor: %s. Brief differences are normal during a transition.", strings.Join(transitioning, "; ")),
+			Action:      "If it persists, check pg_autoctl show events for the transition that is stuck.",
+			Evidence:    transitioningEv,
+		})
+	}
+}
+
+// analyzeWALArchiving warns when archive_command is failing and, when the
+// archive was checked, about segments missing from it: recovery replays WAL
+// in order, so point-in-time recovery stops at the first gap.
+func analyzeWALArchiving(a *Analysis, wa collect.WALArchiving) {
+	if wa.Failing() {
+		a.Warnings = append(a.Warnings, Finding{
+			Title:       "WAL archiving failing",
+			Severity:    SeverityWarning,
+			Code:        "wal-archiving-failing",
+			Description: fmt.Sprintf("archive_command last failed on %s at %s (%s failures since the statistics reset); the last successful archive was %s. Unarchived WAL accumulates in pg_wal until it succeeds.", wa.LastFailedWAL, formatLocalTime(wa.LastFailedAt), formatThousands0(float64(wa.FailedCount)), walArchivedDesc(wa)),
+			Action:      "Check the PostgreSQL log for the archive_command error (credentials, network, full destination) and watch pg_wal disk usage until archiving catches up.",
+			Evidence:    settingsEvidence(map[string]float64{"failed_count": float64(wa.FailedCount)}, "archive_command"),
+		})
+	}
+
+	c := wa.Check
+	switch {
+	case c == nil:
+	case len(c.Missing) > 0:
+		a.Warnings = append(a.Warnings, Finding{
+			Title:       "CRITICAL: WAL archive gap",
+			Severity:    SeverityWarning,
+			Code:        "wal-archive-gap",
+			Description: fmt.Sprintf("%d of the latest %d segments reported as archived are missing from %s: %s. Point-in-time recovery cannot replay past a missing segment.", len(c.Missing), len(c.Checked), c.Destination, listFirst(c.Missing, 5, ", ")),
+			Action:      "Take a new base backup now so recovery does not depend on the gap, then find why archive_command reported success without storing the segment (retention or lifecycle rules, a wrong prefix, asynchronous uploads).",
+			Evidence:    &Evidence{Metrics: map[string]float64{"missing_segments": float64(len(c.Missing)), "checked_segments": float64(len(c.Checked))}},
+		})
+	case c.Error != "":
+		a.Infos = append(a.Infos, Finding{
+			Title:       "WAL archive not verified",
+			Severity:    SeverityInfo,
+			Code:        "wal-archive-unverified",
+			Description: fmt.Sprintf("The latest archived segments could not be looked up in the archive: %s.", c.Error),
+		})
+	default:
+		a.Infos = append(a.Infos, Finding{
+			Title:       "WAL archive verified",
+			Severity:    SeverityInfo,
+			Code:        "wal-archive-verified",
+			Description: fmt.Sprintf("The latest %d archived segments (up to %s) are present in %s.", len(c.Checked), c.Checked[0], c.Destination),
+		})
+	}
+}
+
+// analyzeRestoreCheck reports the -verify-restore-cmd result: backups that
+// exist but do not restore are not backups.
+func analyzeRestoreCheck(a *Analysis, rc collect.RestoreCheck) {
+	detail := ""
+	if line := lastLine(rc.Output); line != "" {
+		detail = fmt.Sprintf(" Last output line: %q.", line)
+	}
+	if rc.Passed() {
+		a.Infos = append(a.Infos, Finding{
+			Title:       "Restore verified",
+			Severity:    SeverityInfo,
+			Code:        "restore-verified",
+			Description: fmt.Sprintf("The restore verification command exited with status 0 after %s.%s", humanizeDuration(rc.Duration), detail),
+		})
+		return
+	}
+	status := fmt.Sprintf("exited with status %d", rc.ExitCode)
+	if rc.Error != "" {
+		status = "did not complete: " + rc.Error
+	}
+	a.Warnings = append(a.Warnings, Finding{
+		Title:       "Restore verification failed",
+		Severity:    SeverityWarning,
+		Code:        "restore-verify-failed",
+		Description: fmt.Sprintf("The restore verification command %s.%s Backups that cannot be restored give no protection.", status, detail),
+		Action:      "Check the restore-validation job and its output in the report; fix the backup or restore procedure and rerun the validation.",
+	})
+}
+
+// analyzeSLO warns when the current run breaches an objective and recommends
+// attention when it is met now but missed in too many recent runs.
+func analyzeSLO(a *Analysis, st collect.SLOStatus) {
+	if !st.Measured {
+		return
+	}
+	history := fmt.Sprintf("%d of the last %d runs met it (%.0f%%, goal %.0f%%), error budget burn rate %.1f×",
+		st.MetRuns, st.Runs, st.Compliance(), st.Goal, st.BurnRate)
+	ev := &Evidence{Metrics: map[string]float64{st.Metric: st.Value, "compliance_pct": st.Compliance(), "burn_rate": st.BurnRate}}
+	switch {
+	case !st.Met:
+		a.Warnings = append(a.Warnings, Finding{
+			Title:       "SLO breached: " + st.Name,
+			Severity:    SeverityWarning,
+			Code:        "slo-breached",
+			Description: fmt.Sprintf("%s is %s against the objective %s; %s.", st.Metric, st.ValueText(), st.Target, history),
+			Action:      "Review the report sections behind this metric and address the cause before the error budget is spent.",
+			Evidence:    ev,
+		})
+	case st.BurnRate > 1:
+		a.Recommendations = append(a.Recommendations, Finding{
+			Title:       "SLO error budget exhausted: " + st.Name,
+			Severity:    SeverityRec,
+			Code:        "slo-budget-burn",
+			Description: fmt.Sprintf("%s meets %s in this run, but %s.", st.Metric, st.Target, history),
+			Action:      "Look for recurring causes in the archived runs; the objective is missed more often than its goal allows.",
+			Evidence:    ev,
+		})
+	}
+}
+
+// lastLine returns the last non-empty line of s.
+func lastLine(s string) string {
+	s = strings.TrimRight(s, " \t\r\n")
+	if i := strings.LastIndexByte(s, '\n'); i >= 0 {
+		s = s[i+1:]
+	}
+	return strings.TrimSpace(s)
+}
+
+// walArchivedDesc describes the last successful archive.
+func walArchivedDesc(wa collect.WALArchiving) string {
+	if wa.LastArchivedWAL == "" {
+		return "never"
+	}
+	return fmt.Sprintf("%s at %s", wa.LastArchivedWAL, formatLocalTime(wa.LastArchivedAt))
+}
+
+// analyzeConfigFiles warns about postgresql.conf entries the server rejects
+// and pg_hba.conf lines it cannot load, which only surface as log lines on
+// reload and as a surprise on the next restart.
+func analyzeConfigFiles(a *Analysis, cf collect.ConfigFiles) {
+	if errs := cf.Errors(); len(errs) > 0 {
+		var items, names []string
+		for _, f := range errs {
+			items = append(items, fmt.Sprintf("%s = '%s' (%s:%d): %s", f.Name, f.Val, f.File, f.Line, f.Error))
+			names = append(names, f.Name)
+		}
+		a.Warnings = append(a.Warnings, Finding{
+			Title:       "Configuration file errors",
+			Severity:    SeverityWarning,
+			Code:        "file-settings-errors",
+			Description: fmt.Sprintf("%d postgresql.conf entries are not in effect: %s.", len(errs), listFirst(items, 5, "; ")),
+			Action:      "Fix or remove the entries and check SELECT * FROM pg_file_settings WHERE error IS NOT NULL is empty before reloading. \"setting could not be applied\" means the value changed but needs a restart to take effect.",
+			Evidence:    settingsEvidence(nil, names...),
+		})
+	}
+	if len(cf.HBARules) > 0 {
+		var items []string
+		for _, r := range cf.HBARules {
+			items = append(items, fmt.Sprintf("line %d: %s", r.Line, r.Error))
+		}
+		a.Warnings = append(a.Warnings, Finding{
+			Title:       "pg_hba.conf errors",
+			Severity:    SeverityWarning,
+			Code:        "hba-file-errors",
+			Description: fmt.Sprintf("pg_hba.conf has %d invalid lines: %s. A reload keeps the previous rules, but the server refuses to start with this file.", len(cf.HBARules), listFirst(items, 5, "; ")),
+			Action:      "Fix the listed lines and check SELECT * FROM pg_hba_file_rules WHERE error IS NOT NULL is empty before the next reload or restart.",
+		})
+	}
+	if dup := cf.Overridden(); len(dup) > 0 {
+		var items, names []string
+		for _, f := range dup {
+			items = append(items, fmt.Sprintf("%s (%s:%d)", f.Name, f.File, f.Line))
+			names = append(names, f.Name)
+		}
+		a.Recommendations = append(a.Recommendations, Finding{
+			Title:       "Overridden configuration entries",
+			Severity:    SeverityRec,
+			Code:        "file-settings-overridden",
+			Description: fmt.Sprintf("%d configuration file entries are overridden by a later entry for the same setting (for example in postgresql.auto.conf via ALTER SYSTEM): %s.", len(dup), listFirst(items, 5, ", ")),
+			Action:      "Remove the stale entries so the file shows the value in effect.",
+			Evidence:    settingsEvidence(nil, names...),
+		})
+	}
+}
+
+// listFirst joins the first n items with sep and counts the rest.
+func listFirst(items []string, n int, sep string) string {
+	if len(items) <= n {
+		return strings.Join(items, sep)
+	}
+	return fmt.Sprintf("%s and %d more", strings.Join(items[:n], sep), len(items)-n)
+}
+
+// analyzeClock warns when the server clock is off from the machine running
+// pghealth and when server log, server session and report timestamps use
+// different UTC offsets, which commonly confuses incident timelines.
+func analyzeClock(a *Analysis, ci collect.ConnInfo) {
+	if skew := ci.ClockSkew; skew >= clockSkewThreshold || skew <= -clockSkewThreshold {
+		dir := "ahead of"
+		if skew < 0 {
+			dir = "behind"
+		}
+		a.Warnings = append(a.Warnings, Finding{
+			Title:       "Server clock skew",
+			Severity:    SeverityWarning,
+			Code:        "clock-skew",
+			Description: fmt.Sprintf("The server clock is %s %s the machine running pghealth; timestamps in logs, pg_stat_* views and this report will not line up.", humanizeDuration(skew), dir),
+			Action:      "Enable NTP (chrony or systemd-timesyncd) on the database host and the monitoring host and check they use the same time source.",
+			Evidence:    &Evidence{Metrics: map[string]float64{"skew_seconds": skew.Seconds()}},
+		})
+	}
+	if ci.TimeZoneOffset != ci.LogTimeZoneOffset {
+		a.Recommendations = append(a.Recommendations, Finding{
+			Title:       "TimeZone and log_timezone differ",
+			Severity:    SeverityRec,
+			Code:        "timezone-mismatch",
+			Description: fmt.Sprintf("TimeZone = %s (%s) but log_timezone = %s (%s): timestamps returned by queries and those in the server log are %s apart.", ci.TimeZone, utcOffset(ci.TimeZoneOffset), ci.LogTimeZone, utcOffset(ci.LogTimeZoneOffset), humanizeDuration(time.Duration(ci.TimeZoneOffset-ci.LogTimeZoneOffset)*time.Second)),
+			Action:      "Use the same zone for both, preferably UTC: set timezone and log_timezone in postgresql.conf and reload.",
+			Evidence:    settingsEvidence(nil, "TimeZone", "log_timezone"),
+		})
+	}
+	if ci.ClientTimeZone != "" && ci.ClientTimeZoneOffset != ci.LogTimeZoneOffset {
+		a.Infos = append(a.Infos, Finding{
+			Title:       "Report and server log time zones differ",
+			Severity:    SeverityInfo,
+			Code:        "timezone-report",
+			Description: fmt.Sprintf("Report times are shown in %s (%s) while the server log uses %s (%s).", ci.ClientTimeZone, utcOffset(ci.ClientTimeZoneOffset), ci.LogTimeZone, utcOffset(ci.LogTimeZoneOffset)),
+			Action:      "Convert times when correlating the report with server logs, or run pghealth with TZ=" + ci.LogTimeZone + ".",
+		})
+	}
+}
+
+// utcOffset formats seconds east of UTC as UTC+hh:mm.
+func utcOffset(sec int) string {
+	sign := '+'
+	if sec < 0 {
+		sign, sec = '-', -sec
+	}
+	return fmt.Sprintf("UTC%c%02d:%02d", sign, sec/3600, sec%3600/60)
+}
+
+// analyzeFreezeForecast lists large tables whose relfrozenxid age reaches
+// autovacuum_freeze_max_age within freezeForecastWindow at the measured XID
+// rate (or already has), so the forced freeze can be run ahead of time.
+func analyzeFreezeForecast(a *Analysis, ff collect.FreezeForecast) {
+	var due []string
+	ev := &Evidence{}
+	if ff.XIDRate > 0 {
+		ev.Metrics = map[string]float64{"xids_per_hour": ff.XIDRate}
+	}
+	for _, t := range ff.Tables {
+		if t.SizeBytes < freezeForecastMinBytes {
+			continue
+		}
+		name := fmt.Sprintf("%s.%s (%.1f GB", t.Schema, t.Name, bytesToGB(t.SizeBytes))
+		if t.Due() {
+			due = append(due, name+", due now)")
+		} else if in := ff.DueIn(t); in > 0 && in <= freezeForecastWindow {
+			due = append(due, fmt.Sprintf("%s, in ~%s)", name, humanizeDuration(in)))
+		} else {
+			continue
+		}
+		ev.Objects = append(ev.Objects, Object{Kind: "table", Schema: t.Schema, Name: t.Name, Metrics: map[string]float64{
+			"xid_age": float64(t.XIDAge), "freeze_max_age": float64(t.FreezeMaxAge), "size_bytes": float64(t.SizeBytes),
+		}})
+	}
+	if len(due) == 0 {
+		return
+	}
+	desc := "Large tables at their autovacuum_freeze_max_age"
+	if ff.XIDRate > 0 {
+		desc = fmt.Sprintf("Large tables reaching autovacuum_freeze_max_age within %s at %s XIDs/hour", humanizeDuration(freezeForecastWindow), formatThousands0(ff.XIDRate))
+	}
+	a.Recommendations = append(a.Recommendations, Finding{
+		Title:       "Anti-wraparound vacuums due",
+		Severity:    SeverityRec,
+		Code:        "freeze-vacuum-forecast",
+		Description: desc + ": " + strings.Join(due, ", "),
+		Action:      "Run VACUUM (FREEZE) on these tables in a quiet window before autovacuum forces it: an anti-wraparound autovacuum does not yield to conflicting locks, so DDL on the table waits for it. Lowering vacuum_freeze_min_age or enabling more frequent vacuums spreads the freezing work.",
+		Evidence:    ev,
+	})
+}
+
+// analyzeLockHotspots names the tables where sessions queue for the same rows
+// (tuple locks) or behind table-level locks, with queueing pattern advice.
+func analyzeLockHotspots(a *Analysis, hs []collect.LockHotspot) {
+	var rows, tables []string
+	rowEv, tableEv := &Evidence{}, &Evidence{}
+	for _, h := range hs {
+		obj := Object{Kind: "table", Schema: h.Schema, Name: h.Table, Metrics: map[string]float64{
+			"waiting": float64(h.Waiting), "longest_wait_seconds": h.LongestWaitSecs,
+		}}
+		if h.HotRows > 0 {
+			rows = append(rows, fmt.Sprintf("%s.%s (%d row(s), %d waiting, longest %s)", h.Schema, h.Table, h.HotRows, h.Waiting,
+				humanizeDuration(h.LongestWait())))
+			obj.Metrics["hot_rows"] = float64(h.HotRows)
+			rowEv.Objects = append(rowEv.Objects, obj)
+		} else if h.StrongLocks > 0 && h.Waiting > 0 {
+			tables = append(tables, fmt.Sprintf("%s.%s (%d waiting)", h.Schema, h.Table, h.Waiting))
+			tableEv.Objects = append(tableEv.Objects, obj)
+		}
+	}
+	if len(rows) > 0 {
+		a.Warnings = append(a.Warnings, Finding{
+			Title:       "Hot-row lock contention",
+			Severity:    SeverityWarning,
+			Code:        "lock-hot-rows",
+			Description: "Sessions queue to update the same rows: " + strings.Join(rows, ", "),
+			Action:      "For job/queue tables claim work with SELECT ... FOR UPDATE SKIP LOCKED; serialize per-key work with pg_advisory_xact_lock instead of row locks; spread hot counters over several rows or batch increments; keep transactions that touch these rows short.",
+			Evidence:    rowEv,
+		})
+	}
+	if len(tables) > 0 {
+		a.Warnings = append(a.Warnings, Finding{
+			Title:       "Sessions queued behind table locks",
+			Severity:    SeverityWarning,
+			Code:        "lock-table-queue",
+			Description: "Share/exclusive table-level locks (DDL, LOCK TABLE, REFRESH MATERIALIZED VIEW) block writers on: " + strings.Join(tables, ", "),
+			Action:      "Run DDL with a short lock_timeout and retries; use CREATE INDEX CONCURRENTLY and REFRESH ... CONCURRENTLY; avoid explicit LOCK TABLE in application code.",
+			Evidence:    tableEv,
+		})
+	}
+}
+
+// subxactAction is the shared advice of subtransaction findings.
+const subxactAction = "Remove per-row savepoints: disable driver auto-savepoints (JDBC autosave=always, psql ON_ERROR_ROLLBACK), avoid PL/pgSQL EXCEPTION blocks inside loops, and commit batches instead of wrapping each row in a SAVEPOINT; keep transactions under 64 subtransactions."
+
+// analyzeSubtransactions warns about sessions past the 64 cached
+// subtransaction IDs (PG16+) and about Subtrans SLRU misses, the cluster-wide
+// symptom of overflowed subtransactions slowing every snapshot.
+func analyzeSubtransactions(a *Analysis, st collect.Subtransactions) {
+	var overflowed []string
+	ev := &Evidence{}
+	for _, b := range st.Backends {
+		if b.Overflowed {
+			app := b.Application
+			if app == "" {
+				app = b.Usename
+			}
+			overflowed = append(overflowed, fmt.Sprintf("pid %d (%s, %d subxacts)", b.PID, app, b.Count))
+			ev.Objects = append(ev.Objects, Object{Kind: "backend", Database: b.Datname, Name: strconv.Itoa(b.PID),
+				Metrics: map[string]float64{"subxacts": float64(b.Count)}})
+		}
+	}
+	if len(overflowed) > 0 {
+		a.Warnings = append(a.Warnings, Finding{
+			Title:       "Subtransaction overflow",
+			Severity:    SeverityWarning,
+			Code:        "subxact-overflow",
+			Description: fmt.Sprintf("%d session(s) exceed 64 subtransactions, forcing Subtrans SLRU lookups for all concurrent snapshots: %s", len(overflowed), strings.Join(overflowed, ", ")),
+			Action:      subxactAction,
+			Evidence:    ev,
+		})
+	}
+	if s := st.SLRU; s != nil && s.BlksRead >= subtransSLRUMinReads && s.HitRatio() < subtransSLRUMinHitRatio {
+		a.Warnings = append(a.Warnings, Finding{
+			Title:       "Subtrans SLRU misses",
+			Severity:    SeverityWarning,
+			Code:        "subtrans-slru-misses",
+			Description: fmt.Sprintf("Subtrans SLRU read %s pages (%.0f%% hit ratio) since %s; heavy savepoint use is overflowing backend subtransaction caches.", formatThousands0(float64(s.BlksRead)), s.HitRatio()*100, formatLocalTime(s.StatsReset)),
+			Action:      subxactAction,
+			Evidence: &Evidence{Metrics: map[string]float64{
+				"blocks_read": float64(s.BlksRead), "hit_ratio_pct": s.HitRatio() * 100,
+			}},
+		})
+	}
+}
+
+// analyzeVacuumHorizon explains dead tuples vacuum cannot remove because a
+// standby (hot_standby_feedback) or replication slot holds an old xmin, and
+// ties the finding to the dead tuple bloat warning when there is one. sc, when
+// a standby was checked, supplies its hot_standby_feedback setting.
+func analyzeVacuumHorizon(a *Analysis, vh collect.VacuumHorizon, sc *collect.StandbyConflicts) {
+	if d, ok := vh.Setting("vacuum_defer_cleanup_age"); ok && d.Val != "" && d.Val != "0" {
+		a.Warnings = append(a.Warnings, Finding{
+			Title:       "vacuum_defer_cleanup_age delays cleanup",
+			Severity:    SeverityWarning,
+			Code:        "vacuum-defer-cleanup-age",
+			Description: fmt.Sprintf("vacuum_defer_cleanup_age = %s: vacuum keeps rows deleted by the last %s transactions on every table, in addition to what standbys and slots hold.", d.Val, d.Val),
+			Action:      "Set vacuum_defer_cleanup_age = 0 and protect standby queries with hot_standby_feedback or a replication slot instead (the setting was removed in PostgreSQL 16).",
+			Evidence:    settingsEvidence(nil, "vacuum_defer_cleanup_age"),
+		})
+	}
+
+	var holders, actions []string
+	var oldest, session int64
+	var standby, inactive, logical bool
+	ev := &Evidence{}
+	for _, h := range vh.Holders {
+		if h.Kind == "session" {
+			session = max(session, h.XminAge)
+			continue
+		}
+		if h.Age() < vacuumHorizonMinAge {
+			continue
+		}
+		oldest = max(oldest, h.Age())
+		ev.Objects = append(ev.Objects, Object{Kind: h.Kind, Name: h.Name, Metrics: map[string]float64{"xmin_age": float64(h.Age())}})
+		switch {
+		case h.Kind == "standby":
+			standby = true
+			holders = append(holders, fmt.Sprintf("standby %s via hot_standby_feedback (%s transactions)", h.Name, formatThousands0(float64(h.XminAge))))
+		case !h.Active:
+			inactive = true
+			holders = append(holders, fmt.Sprintf("inactive slot %s (%s transactions)", h.Name, formatThousands0(float64(h.Age()))))
+		case h.CatalogXminAge > h.XminAge:
+			logical = true
+			holders = append(holders, fmt.Sprintf("logical slot %s catalog xmin (%s transactions)", h.Name, formatThousands0(float64(h.CatalogXminAge))))
+		default:
+			// A physical slot only holds an xmin through standby feedback
+			standby = true
+			holders = append(holders, fmt.Sprintf("slot %s (%s transactions)", h.Name, formatThousands0(float64(h.XminAge))))
+		}
+	}
+	if len(holders) == 0 {
+		return
+	}
+
+	if inactive {
+		actions = append(actions, "Drop abandoned slots with SELECT pg_drop_replication_slot('name') or reconnect their consumer; set max_slot_wal_keep_size so a lost standby cannot hold the horizon forever.")
+	}
+	if standby {
+		feedback := "hot_standby_feedback"
+		if sc != nil {
+			if st, ok := sc.Setting("hot_standby_feedback"); ok {
+				feedback = fmt.Sprintf("hot_standby_feedback (%s on %s)", st.Val, sc.Host)
+			}
+		}
+		actions = append(actions, "Long queries on the standby pin the horizon through "+feedback+": shorten them or set statement_timeout on the standby, or turn feedback off and let max_standby_streaming_delay cancel them instead.")
+	}
+	if logical {
+		actions = append(actions, "Make sure logical replication consumers keep up and confirm their progress; a stalled subscriber also blocks cleanup of system catalogs.")
+	}
+	desc := fmt.Sprintf("Vacuum cannot remove rows deleted in the last %s transactions; the xmin horizon is held by %s.", formatThousands0(float64(oldest)), strings.Join(holders, ", "))
+	if session > oldest {
+		desc += fmt.Sprintf(" A local session holds an even older snapshot (%s transactions); see long-running transactions.", formatThousands0(float64(session)))
+	}
+	for i := range a.Warnings {
+		if a.Warnings[i].Code == "table-bloat-heuristic" {
+			desc += " This is the likely cause of the dead tuples in 'Potential table bloat': VACUUM runs but must keep them."
+			a.Warnings[i].Action = "Vacuum is held back by replicas or slots (see 'Vacuum held back by replicas'); release the xmin horizon first, then VACUUM. " + a.Warnings[i].Action
+			break
+		}
+	}
+	a.Warnings = append(a.Warnings, Finding{
+		Title:       "Vacuum held back by replicas",
+		Severity:    SeverityWarning,
+		Code:        "replica-xmin-horizon",
+		Description: desc,
+		Action:      strings.Join(actions, " "),
+		Evidence:    ev,
+	})
+}
+
+// nPlusOnePattern aggregates top statements sharing a fingerprint.
+type nPlusOnePattern struct {
+	fingerprint  string
+	table        string
+	columns      []string
+	statements   int
+	calls        float64
+	callsPerHour float64
+	rows         float64
+	queryIDs     []int64
+}
+
+// analyzeNPlusOne reports likely ORM N+1 loops: single-table SELECTs by
+// equality that return about one row and run at a very high rate, grouped by
+// fingerprint so variants of the same lookup (IN list lengths, constants)
+// count together.
+func analyzeNPlusOne(a *Analysis, sts collect.Statements) {
+	byFP := map[string]*nPlusOnePattern{}
+	var order []string
+	seen := map[string]bool{}
+	for _, list := range [][]collect.Statement{sts.TopByCalls, sts.TopByTotalTime} {
+		for _, st := range list {
+			if seen[st.Key()] || st.Calls <= 0 || st.TextProblem() != "" {
+				continue
+			}
+			seen[st.Key()] = true
+			parsed := sqlparse.Parse(st.Query)
+			if parsed.Command != "select" || len(parsed.Tables) != 1 || parsed.Joins > 0 {
+				continue
+			}
+			var cols []string
+			for _, f := range parsed.Filters {
+				if f.Kind == sqlparse.Equality && !slices.Contains(cols, f.Column) {
+					cols = append(cols, f.Column)
+				}
+			}
+			if len(cols) == 0 {
+				continue
+			}
+			fp := sqlparse.Fingerprint(st.Query)
+			p, ok := byFP[fp]
+			if !ok {
+				p = &nPlusOnePattern{fingerprint: fp, table: parsed.Tables[0].String(), columns: cols}
+				byFP[fp] = p
+				order = append(order, fp)
+			}
+			p.statements++
+			p.calls += st.Calls
+			p.callsPerHour += st.CallsPerHour
+			p.rows += st.Rows
+			p.queryIDs = append(p.queryIDs, queryIDs(st)...)
+		}
+	}
+	var hits []*nPlusOnePattern
+	for _, fp := range order {
+		p := byFP[fp]
+		if p.callsPerHour >= nPlusOneMinCallsPerHour && p.rows/p.calls <= nPlusOneMaxRowsPerCall {
+			hits = append(hits, p)
+		}
+	}
+	if len(hits) == 0 {
+		return
+	}
+	sort.SliceStable(hits, func(i, j int) bool { return hits[i].callsPerHour > hits[j].callsPerHour })
+	list := make([]string, 0, 3)
+	ev := &Evidence{}
+	for i, p := range hits {
+		obj := relationObject("table", "", p.table)
+		obj.Metrics = map[string]float64{"calls_per_hour": p.callsPerHour, "rows_per_call": p.rows / p.calls}
+		ev.Objects = append(ev.Objects, obj)
+		ev.QueryIDs = append(ev.QueryIDs, p.queryIDs...)
+		if i >= 3 {
+			continue
+		}
+		fp := p.fingerprint
+		if len(fp) > 120 {
+			fp = fp[:117] + "..."
+		}
+		list = append(list, fmt.Sprintf("%s by %s (%s calls/h, %.1f rows/call, %d statement(s): %s)",
+			p.table, strings.Join(p.columns, ", "), formatThousands0(p.callsPerHour), p.rows/p.calls, p.statements, fp))
+	}
+	desc := fmt.Sprintf("%d single-row lookup pattern(s) run over %s times per hour, typical of ORM lazy loading in a loop: %s",
+		len(hits), formatThousands0(nPlusOneMinCallsPerHour), strings.Join(list, "; "))
+	if len(hits) > 3 {
+		desc += fmt.Sprintf(" and %d more", len(hits)-3)
+	}
+	a.Recommendations = append(a.Recommendations, Finding{
+		Title:       "Likely N+1 query patterns",
+		Severity:    SeverityRec,
+		Code:        "n-plus-one",
+		Description: desc,
+		Action:      "Fetch related rows in one round trip: batch keys into WHERE col = ANY($1) / IN (...), JOIN them into the parent query, or enable eager loading in the ORM.",
+		Evidence:    ev,
+	})
+}
+
+// analyzeTailLatency recommends looking at top queries whose estimated p99
+// execution time is far above their mean: intermittent slowness from lock
+// waits, cold caches or plan flips that averages do not show.
+func analyzeTailLatency(a *Analysis, sts collect.Statements) {
+	var hits []collect.Statement
+	seen := map[string]bool{}
+	for _, list := range [][]collect.Statement{sts.TopByTotalTime, sts.TopByCalls} {
+		for _, st := range list {
+			if seen[st.Key()] || !st.HasLatencySpread() || st.MeanTime <= 0 {
+				continue
+			}
+			seen[st.Key()] = true
+			if p99 := st.LatencyP99(); p99 >= tailLatencyMinMs && p99 >= st.MeanTime*tailLatencyFactor {
+				hits = append(hits, st)
+			}
+		}
+	}
+	if len(hits) == 0 {
+		return
+	}
+	sort.SliceStable(hits, func(i, j int) bool {
+		return hits[i].LatencyP99()/hits[i].MeanTime > hits[j].LatencyP99()/hits[j].MeanTime
+	})
+	list := make([]string, 0, 3)
+	ev := &Evidence{}
+	for i, st := range hits {
+		ev.QueryIDs = append(ev.QueryIDs, queryIDs(st)...)
+		if i >= 3 {
+			continue
+		}
+		q := strings.Join(strings.Fields(st.Query), " ")
+		if len(q) > 80 {
+			q = q[:77] + "..."
+		}
+		list = append(list, fmt.Sprintf("mean %s, p99 ≈ %s, max %s: %s", humanizeMs(st.MeanTime), humanizeMs(st.LatencyP99()), humanizeMs(st.MaxTime), q))
+	}
+	desc := fmt.Sprintf("%d top statement(s) run far slower than their mean at the tail (p99 estimated from the pg_stat_statements standard deviation): %s",
+		len(hits), strings.Join(list, "; "))
+	if len(hits) > 3 {
+		desc += fmt.Sprintf(" and %d more", len(hits)-3)
+	}
+	a.Recommendations = append(a.Recommendations, Finding{
+		Title:       "Query tail latency hidden by the mean",
+		Severity:    SeverityRec,
+		Code:        "query-tail-latency",
+		Description: desc,
+		Action:      "Capture slow executions with auto_explain (log_min_duration) or log_min_duration_statement and compare their plans and wait events with typical runs: lock waits, cold caches, generic plans and parameter-dependent plan flips are common causes.",
+		Evidence:    ev,
+	})
+}
+
+// analyzeTrackSettings reports statistics collection settings that are off
+// or too small, naming the report sections they leave empty or misleading.
+func analyzeTrackSettings(a *Analysis, res collect.Result, setting func(string) (collect.Setting, bool)) {
+	off := func(name string) bool {
+		s, ok := setting(name)
+		return ok && (s.Val == "off" || s.Val == "0")
+	}
+	if off("track_counts") {
+		a.Warnings = append(a.Warnings, Finding{
+			Title:       "track_counts is off",
+			Severity:    SeverityWarning,
+			Code:        "track-counts-off",
+			Evidence:    settingsEvidence(nil, "track_counts"),
+			Description: "Table and index activity is not counted: autovacuum cannot tell which tables need vacuuming or analyzing, and Top tables, Tables with lowest index usage, Unused indexes, Stale table statistics, Tables dead rows bloat and the missing index advice are built on empty counters.",
+			Action:      "ALTER SYSTEM SET track_counts = on; SELECT pg_reload_conf();",
+		})
+	}
+	if off("track_activities") {
+		a.Recommendations = append(a.Recommendations, Finding{
+			Title:       "track_activities is off",
+			Severity:    SeverityRec,
+			Code:        "track-activities-off",
+			Evidence:    settingsEvidence(nil, "track_activities"),
+			Description: "Sessions do not report their current statement: Blocking queries, Long-running queries, Idle-in-transaction sessions, Lock hotspots and Subtransactions show no query text.",
+			Action:      "ALTER SYSTEM SET track_activities = on; SELECT pg_reload_conf();",
+		})
+	}
+	if off("track_io_timing") {
+		a.Recommendations = append(a.Recommendations, Finding{
+			Title:       "Enable track_io_timing",
+			Severity:    SeverityRec,
+			Code:        "enable-track-io",
+			Evidence:    settingsEvidence(nil, "track_io_timing"),
+			Description: "track_io_timing is off: statements record no read/write time, so Top queries by CPU and by I/O time fall back to total time and Query details show no I/O time.",
+			Action:      "SET track_io_timing = on; then persist in postgresql.conf and reload. Check the clock overhead with pg_test_timing first on virtualized hosts.",
+		})
+	}
+	if s, ok := setting("track_functions"); ok && s.Val == "none" && len(res.FunctionStats) == 0 {
+		a.Infos = append(a.Infos, Finding{
+			Title:       "track_functions is none",
+			Severity:    SeverityInfo,
+			Code:        "track-functions-off",
+			Evidence:    settingsEvidence(nil, "track_functions"),
+			Description: "Function calls are not counted, so Top functions by total time is empty even when PL/pgSQL functions carry the load.",
+			Action:      "ALTER SYSTEM SET track_functions = 'pl'; SELECT pg_reload_conf(); ('all' also counts SQL and C functions).",
+		})
+	}
+	if s, ok := setting("track_activity_query_size"); ok {
+		if size, err := strconv.Atoi(s.Val); err == nil && size > 0 {
+			analyzeTruncatedActivity(a, res, size)
+		}
+	}
+}
+
+// analyzeTruncatedActivity reports session query texts cut at
+// track_activity_query_size - 1 bytes, by report section. The cut falls on a
+// character boundary, up to 3 bytes earlier for multibyte text.
+func analyzeTruncatedActivity(a *Analysis, res collect.Result, size int) {
+	cut := func(q string) bool { return len(q) >= size-4 }
+	var sections []string
+	n := 0
+	count := func(section string, texts ...string) {
+		hit := 0
+		for _, q := range texts {
+			if cut(q) {
+				hit++
+			}
+		}
+		if hit > 0 {
+			n += hit
+			sections = append(sections, section)
+		}
+	}
+	var texts []string
+	for _, b := range res.Blocking {
+		texts = append(texts, b.BlockedQuery, b.BlockingQuery)
+	}
+	count("Blocking queries", texts...)
+	texts = texts[:0]
+	for _, q := range res.LongRunning {
+		texts = append(texts, q.Query)
+	}
+	count("Long-running queries", texts...)
+	texts = texts[:0]
+	for _, q := range res.IdleInTransaction {
+		texts = append(texts, q.Query)
+	}
+	count("Idle-in-transaction sessions", texts...)
+	texts = texts[:0]
+	for _, h := range res.LockHotspots {
+		texts = append(texts, h.SampleQuery)
+	}
+	count("Lock hotspots", texts...)
+	if n == 0 {
+		return
+	}
+	a.Recommendations = append(a.Recommendations, Finding{
+		Title:       "Session query texts are truncated",
+		Severity:    SeverityRec,
+		Code:        "track-activity-query-size",
+		Description: fmt.Sprintf("%d session query text(s) were cut at %d bytes by track_activity_query_size = %d, leaving partial statements in %s.", n, size-1, size, strings.Join(sections, ", ")),
+		Action:      "ALTER SYSTEM SET track_activity_query_size = '8kB'; then restart. Each connection slot reserves this much shared memory.",
+		Evidence:    settingsEvidence(map[string]float64{"truncated_texts": float64(n)}, "track_activity_query_size"),
+	})
+}
+
+// analyzeVacuumPasses explains vacuums that ran out of dead tuple memory and
+// vacuum every index more than once, and sizes the memory fitting them in a
+// single pass.
+func analyzeVacuumPasses(a *Analysis, avs []collect.AutoVacuum) {
+	var list []string
+	var need int64
+	var auto, manual, capped bool
+	ev := &Evidence{}
+	for _, av := range avs {
+		if !av.MultiPass() {
+			continue
+		}
+		obj := relationObject("table", av.Datname, av.Relation)
+		obj.Metrics = map[string]float64{"index_passes": float64(av.IndexVacuumCount), "dead_memory_bytes": float64(av.DeadMemoryLimit())}
+		ev.Objects = append(ev.Objects, obj)
+		what := fmt.Sprintf("%s (%d index pass(es) so far, %.0f MB of dead tuple memory %.0f%% full", av.Relation, av.IndexVacuumCount,
+			float64(av.DeadMemoryLimit())/(1024*1024), av.DeadMemoryFill())
+		if av.Phase == "scanning heap" {
+			what += fmt.Sprintf(", %.0f%% of the heap scanned", av.Progress())
+		}
+		list = append(list, what+")")
+		need = max(need, av.DeadMemoryNeeded())
+		if av.MemoryCapped() {
+			capped = true
+		}
+		if av.Auto {
+			auto = true
+		} else {
+			manual = true
+		}
+	}
+	if len(list) == 0 {
+		return
+	}
+	const step = 64 << 20
+	size := (need + step - 1) / step * step
+	var actions []string
+	if auto {
+		actions = append(actions, fmt.Sprintf("ALTER SYSTEM SET autovacuum_work_mem = '%dMB'; SELECT pg_reload_conf(); (every autovacuum worker may use that much)", size>>20))
+	}
+	if manual {
+		actions = append(actions, fmt.Sprintf("SET maintenance_work_mem = '%dMB'; in the session before a manual VACUUM", size>>20))
+	}
+	action := "Give vacuum enough memory to collect the dead tuples in one pass: " + strings.Join(actions, "; ") + "."
+	if capped {
+		action = "Before PostgreSQL 17 vacuum uses at most 1 GB for dead tuples whatever the setting: vacuum these tables more often (lower autovacuum_vacuum_scale_factor on them) so fewer dead tuples pile up, or upgrade to PostgreSQL 17, whose dead tuple storage is far more compact and uncapped."
+	}
+	a.Recommendations = append(a.Recommendations, Finding{
+		Title:       "Vacuums making several index passes",
+		Severity:    SeverityRec,
+		Code:        "vacuum-index-passes",
+		Description: fmt.Sprintf("%d running vacuum(s) filled their dead tuple memory before finishing the heap scan, so every index is scanned once per fill: %s. About %.0f MB would fit the dead tuples in one pass.", len(list), strings.Join(list, ", "), float64(need)/(1024*1024)),
+		Action:      action,
+		Evidence:    ev,
+	})
+}
+
+// analyzeOperations reports ongoing bulk loads, table rewrites and base
+// backups. Rewrites lock their table for their whole duration, so they are
+// warnings; the others only add load.
+func analyzeOperations(a *Analysis, res collect.Result) {
+	if n := len(res.ProgressCopy); n > 0 {
+		var list []string
+		for _, p := range res.ProgressCopy {
+			what := p.Command
+			if p.Relation != "" {
+				what += " " + p.Relation
+			}
+			if p.BytesTotal > 0 {
+				what += fmt.Sprintf(" (%.0f%%, running %s)", p.Progress(), humanizeDuration(time.Duration(p.Seconds)*time.Second))
+			} else {
+				what += fmt.Sprintf(" (%s rows, running %s)", formatThousands0(float64(p.TuplesProcessed)), humanizeDuration(time.Duration(p.Seconds)*time.Second))
+			}
+			list = append(list, what)
+		}
+		a.Infos = append(a.Infos, Finding{
+			Title:       "Bulk loads in progress",
+			Severity:    SeverityInfo,
+			Code:        "copy-in-progress",
+			Description: fmt.Sprintf("%d COPY operation(s) running: %s.", n, strings.Join(list, ", ")),
+			Action:      "Large COPY FROM loads write WAL and dirty buffers in bursts: load into tables without secondary indexes where possible, and run VACUUM ANALYZE on the loaded tables afterwards.",
+		})
+	}
+	if n := len(res.ProgressCluster); n > 0 {
+		var list []string
+		ev := &Evidence{}
+		for _, p := range res.ProgressCluster {
+			ev.Objects = append(ev.Objects, relationObject("table", p.Datname, p.Relation))
+			what := fmt.Sprintf("%s %s (%s", p.Command, p.Relation, p.Phase)
+			if p.HeapBlksTotal > 0 {
+				what += fmt.Sprintf(", %.0f%%", p.Progress())
+			}
+			list = append(list, what+fmt.Sprintf(", running %s)", humanizeDuration(time.Duration(p.Seconds)*time.Second)))
+		}
+		a.Warnings = append(a.Warnings, Finding{
+			Title:       "Table rewrites holding exclusive locks",
+			Severity:    SeverityWarning,
+			Code:        "rewrite-in-progress",
+			Description: fmt.Sprintf("%d CLUSTER/VACUUM FULL operation(s) running: %s. Every query on these tables waits until the rewrite finishes.", n, strings.Join(list, ", ")),
+			Action:      "Run rewrites in maintenance windows, or use pg_repack or pg_squeeze to rewrite tables online.",
+			Evidence:    ev,
+		})
+	}
+	if n := len(res.ProgressBasebackup); n > 0 {
+		var list []string
+		for _, p := range res.ProgressBasebackup {
+			what := p.Client
+			if what == "" {
+				what = fmt.Sprintf("pid %d", p.PID)
+			}
+			what += " (" + p.Phase
+			if p.BytesTotal > 0 {
+				what += fmt.Sprintf(", %.0f%%", p.Progress())
+			}
+			list = append(list, what+fmt.Sprintf(", running %s)", humanizeDuration(time.Duration(p.Seconds)*time.Second)))
+		}
+		a.Infos = append(a.Infos, Finding{
+			Title:       "Base backups in progress",
+			Severity:    SeverityInfo,
+			Code:        "base-backup-in-progress",
+			Description: fmt.Sprintf("%d base backup(s) streaming: %s.", n, strings.Join(list, ", ")),
+			Action:      "Backups add read I/O and retain WAL until they finish: schedule them off-peak and throttle with pg_basebackup --max-rate if they compete with the workload.",
+		})
+	}
+}
+
+// analyzeQueryTexts counts the top statements whose text is hidden, missing
+// or truncated: their plan, shape, N+1 and index advice is skipped, so the
+// query sections are less useful than they look.
+func analyzeQueryTexts(a *Analysis, res collect.Result) {
+	var total, hidden, missing, truncated int
+	seen := map[string]bool{}
+	for _, list := range [][]collect.Statement{res.Statements.TopByTotalTime, res.Statements.TopByCPU, res.Statements.TopByCalls,
+		res.Statements.TopByIO, res.Statements.TopByIOBlocks} {
+		for _, st := range list {
+			// Hidden statements share their text and have no queryid; count each row
+			if st.Query != collect.HiddenQueryText {
+				if seen[st.Key()] {
+					continue
+				}
+				seen[st.Key()] = true
+			}
+			total++
+			switch {
+			case st.Query == collect.HiddenQueryText:
+				hidden++
+			case strings.TrimSpace(st.Query) == "":
+				missing++
+			case st.Truncated:
+				truncated++
+			}
+		}
+	}
+	if hidden+missing+truncated == 0 {
+		return
+	}
+	var parts, actions []string
+	if hidden > 0 {
+		parts = append(parts, fmt.Sprintf("%d hidden as %s", hidden, collect.HiddenQueryText))
+		role := res.ConnInfo.CurrentUser
+		if role == "" {
+			role = "<role>"
+		}
+		actions = append(actions, fmt.Sprintf("GRANT pg_read_all_stats TO %s; to see the statements of other roles", role))
+	}
+	if missing > 0 {
+		parts = append(parts, fmt.Sprintf("%d without text", missing))
+		actions = append(actions, "texts are missing when pg_stat_statements cannot read its query text file (e.g. after a failed garbage collection); SELECT pg_stat_statements_reset(); restores them for new entries")
+	}
+	if truncated > 0 {
+		parts = append(parts, fmt.Sprintf("%d truncated", truncated))
+		if res.Statements.Source == collect.SourcePgStatMonitor {
+			actions = append(actions, "ALTER SYSTEM SET pg_stat_monitor.pgsm_query_max_len = 8192; then restart, so texts are kept whole")
+		} else {
+			actions = append(actions, "raise the text length limit of whatever cut the statements (statement logging proxies or the extension's text limit)")
+		}
+	}
+	a.Recommendations = append(a.Recommendations, Finding{
+		Title:       "Top statements without usable query text",
+		Severity:    SeverityRec,
+		Code:        "query-text-unavailable",
+		Description: fmt.Sprintf("%d of %d top statement(s) have no usable text (%s): plan, shape, N+1 and index advice is skipped for them.", hidden+missing+truncated, total, strings.Join(parts, ", ")),
+		Action:      strings.ToUpper(actions[0][:1]) + actions[0][1:] + strings.Join(append([]string{""}, actions[1:]...), "; ") + ".",
+		Evidence: &Evidence{Metrics: map[string]float64{
+			"statements": float64(total), "hidden": float64(hidden), "missing": float64(missing), "truncated": float64(truncated),
+		}},
+	})
+}
+
+// analyzeTablespaces flags an unused tablespace while the busiest large tables
+// and indexes sit on their database's default tablespace, typically a fast
+// volume set up and never moved to, and suggests the moves.
+func analyzeTablespaces(a *Analysis, res collect.Result) {
+	if len(res.Tablespaces) < 2 {
+		return
+	}
+	type relation struct {
+		kind, db, schema, name string
+		size, scans            int64
+	}
+	placed := map[string]int{}
+	var busy []relation
+	for _, t := range res.Tables {
+		placed[res.EffectiveTablespace(t.Database, t.Tablespace)]++
+		if t.Tablespace == "" && t.SizeBytes >= tablespaceMinBytes && t.SeqScans+t.IdxScans > 0 {
+			busy = append(busy, relation{"TABLE", t.Database, t.Schema, t.Name, t.SizeBytes, t.SeqScans + t.IdxScans})
+		}
+	}
+	for _, ix := range res.Indexes {
+		placed[res.EffectiveTablespace(ix.Database, ix.Tablespace)]++
+		if ix.Tablespace == "" && ix.SizeBytes >= tablespaceMinBytes && ix.Scans > 0 {
+			busy = append(busy, relation{"INDEX", ix.Database, ix.Schema, ix.Name, ix.SizeBytes, ix.Scans})
+		}
+	}
+	if len(busy) == 0 {
+		return
+	}
+
+	// The target is the unused tablespace marked fastest by random_page_cost
+	var target *collect.Tablespace
+	for i := range res.Tablespaces {
+		ts := &res.Tablespaces[i]
+		if ts.Name == "pg_default" || placed[ts.Name] > 0 || ts.SizeBytes >= tablespaceIdleBytes {
+			continue
+		}
+		if target == nil {
+			target = ts
+			continue
+		}
+		cost, ok := ts.RandomPageCost()
+		best, bestOK := target.RandomPageCost()
+		if ok && (!bestOK || cost < best) {
+			target = ts
+		}
+	}
+	if target == nil {
+		return
+	}
+
+	sort.Slice(busy, func(i, j int) bool {
+		if busy[i].scans != busy[j].scans {
+			return busy[i].scans > busy[j].scans
+		}
+		return busy[i].size > busy[j].size
+	})
+	objectName := res.ObjectNamer()
+	multi := res.MultiDatabase()
+	var names, moves []string
+	ev := &Evidence{}
+	for i, r := range busy {
+		ev.Objects = append(ev.Objects, Object{Kind: strings.ToLower(r.kind), Database: r.db, Schema: r.schema, Name: r.name,
+			Metrics: map[string]float64{"size_bytes": float64(r.size), "scans": float64(r.scans)}})
+		if i >= tablespaceMaxMoves {
+			if i == tablespaceMaxMoves {
+				names = append(names, fmt.Sprintf("and %d more", len(busy)-i))
+			}
+			continue
+		}
+		names = append(names, fmt.Sprintf("%s (%.1f GB, %s scans, on %s)", objectName(r.db, r.schema, r.name), bytesToGB(r.size),
+			formatThousands0(float64(r.scans)), res.EffectiveTablespace(r.db, "")))
+		move := fmt.Sprintf("ALTER %s %s.%s SET TABLESPACE %s;", r.kind, r.schema, r.name, target.Name)
+		if multi {
+			move += " in " + valueOrCurrent(r.db, res.ConnInfo.CurrentDB)
+		}
+		moves = append(moves, move)
+	}
+
+	where := target.Name
+	var details []string
+	if target.Location != "" {
+		details = append(details, target.Location)
+	}
+	if target.Options != "" {
+		details = append(details, target.Options)
+	}
+	if len(details) > 0 {
+		where += " (" + strings.Join(details, ", ") + ")"
+	}
+	held := "holds no collected tables or indexes"
+	if target.SizeBytes >= 0 {
+		held = fmt.Sprintf("holds %.0f MB", float64(target.SizeBytes)/(1024*1024))
+	}
+	action := "Move the busiest relations first: " + strings.Join(moves, " ") +
+		" The move rewrites the relation under an ACCESS EXCLUSIVE lock and ALTER TABLE leaves its indexes behind; use pg_repack --tablespace to move online."
+	if _, ok := target.RandomPageCost(); !ok {
+		action += fmt.Sprintf(" If %s is faster storage, tell the planner: ALTER TABLESPACE %s SET (random_page_cost = 1.1);", target.Name, target.Name)
+	}
+	a.Recommendations = append(a.Recommendations, Finding{
+		Title:       "Busy relations on the default tablespace while another is unused",
+		Severity:    SeverityRec,
+		Code:        "tablespace-imbalance",
+		Description: fmt.Sprintf("Tablespace %s %s while %d large busy table(s)/index(es) sit on their database's default tablespace: %s.", where, held, len(busy), strings.Join(names, ", ")),
+		Action:      action,
+		Evidence:    ev,
+	})
+}
+
+// analyzeReplicationSlots flags lost slots and inactive slots retaining a lot
+// of WAL, most urgent first; the report lists each with its drop statement.
+func analyzeReplicationSlots(a *Analysis, slots []collect.ReplicationSlot) {
+	drop := collect.DropCandidates(slots)
+	if len(drop) == 0 {
+		return
+	}
+	var list []string
+	var retained int64
+	unlimited := false
+	ev := &Evidence{}
+	for _, sl := range drop {
+		retained += sl.RetainedBytes
+		ev.Objects = append(ev.Objects, Object{Kind: "slot", Name: sl.Name, Metrics: map[string]float64{"retained_bytes": float64(sl.RetainedBytes)}})
+		what := fmt.Sprintf("%s (%s", sl.Name, sl.Type)
+		switch {
+		case sl.Lost():
+			what += ", lost"
+		case sl.InactiveSeconds >= 0:
+			what += ", inactive for " + humanizeDuration(time.Duration(sl.InactiveSeconds)*time.Second)
+		default:
+			what += ", inactive"
+		}
+		if sl.RetainedBytes > 0 {
+			what += fmt.Sprintf(", %.1f GB retained", bytesToGB(sl.RetainedBytes))
+		}
+		list = append(list, what+")")
+		if !sl.Lost() && sl.SafeWALSize < 0 {
+			unlimited = true
+		}
+	}
+	action := fmt.Sprintf("Confirm the consumer of each slot is gone, then drop it; the report lists the statements commented out with their risk, e.g. %s", drop[0].DropCommand())
+	if unlimited {
+		action += " Set max_slot_wal_keep_size so an abandoned slot cannot fill the WAL volume."
+	}
+	a.Warnings = append(a.Warnings, Finding{
+		Title:       "Replication slots to clean up",
+		Severity:    SeverityWarning,
+		Code:        "replication-slot-cleanup",
+		Description: fmt.Sprintf("%d replication slot(s) are lost or have no consumer while retaining %.1f GB of WAL: %s.", len(drop), bytesToGB(retained), strings.Join(list, ", ")),
+		Action:      action,
+		Evidence:    ev,
+	})
+}
+
+// analyzeSyncReplication checks synchronous_standby_names against the
+// standbys streaming from the primary: too few listed standbys blocks every
+// commit that waits for them, and exactly enough leaves no room for one to
+// disconnect.
+func analyzeSyncReplication(a *Analysis, stats []collect.ReplicationStat, setting func(string) (collect.Setting, bool)) {
+	ssn, ok := setting("synchronous_standby_names")
+	if !ok || strings.TrimSpace(ssn.Val) == "" {
+		return
+	}
+	ss, err := collect.ParseSyncStandbys(ssn.Val)
+	if err != nil {
+		return
+	}
+	commit := "on"
+	if sc, ok := setting("synchronous_commit"); ok && sc.Val != "" {
+		commit = sc.Val
+	}
+	waits := commit != "off" && commit != "local"
+
+	streaming := 0
+	var unlisted []string
+	for _, r := range stats {
+		if r.State != "streaming" {
+			continue
+		}
+		if ss.Matches(r.Name) {
+			streaming++
+		} else {
+			unlisted = append(unlisted, r.Name)
+		}
+	}
+	var missing []string
+	for _, n := range ss.Names {
+		if n == "*" {
+			continue
+		}
+		found := false
+		for _, r := range stats {
+			if r.State == "streaming" && strings.EqualFold(r.Name, n) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			missing = append(missing, n)
+		}
+	}
+	method := "priority"
+	if ss.Quorum {
+		method = "quorum"
+	}
+
+	if streaming < ss.Num {
+		desc := fmt.Sprintf("synchronous_standby_names = '%s' needs %d synchronous standby(s) (%s) but %d listed standby(s) are streaming.", ssn.Val, ss.Num, method, streaming)
+		if !ss.Wildcard() && ss.Num > len(ss.Names) {
+			desc += fmt.Sprintf(" It lists only %d name(s), so it can never be satisfied.", len(ss.Names))
+		}
+		if len(missing) > 0 {
+			desc += " Not connected: " + strings.Join(missing, ", ") + "."
+		}
+		if len(unlisted) > 0 {
+			desc += " Streaming but not listed: " + strings.Join(unlisted, ", ") + "; compare their application_name in primary_conninfo."
+		}
+		ev := settingsEvidence(map[string]float64{"num_sync": float64(ss.Num), "streaming": float64(streaming)}, "synchronous_standby_names", "synchronous_commit")
+		for _, n := range missing {
+			ev.Objects = append(ev.Objects, Object{Kind: "standby", Name: n})
+		}
+		title := "Synchronous standbys missing"
+		if waits {
+			title = "Synchronous replication is blocking commits"
+			desc += fmt.Sprintf(" With synchronous_commit = %s every commit waits until enough standbys confirm it.", commit)
+		} else {
+			desc += fmt.Sprintf(" synchronous_commit = %s keeps commits from waiting by default, but sessions setting it to on or remote_* hang.", commit)
+		}
+		a.Warnings = append(a.Warnings, Finding{
+			Title:       title,
+			Severity:    SeverityWarning,
+			Code:        "sync-standby-missing",
+			Description: desc,
+			Action:      "Bring the missing standbys back or fix their application_name. To unblock commits meanwhile, lower num_sync or clear synchronous_standby_names and run SELECT pg_reload_conf(), accepting asynchronous durability until they return.",
+			Evidence:    ev,
+		})
+		return
+	}
+	if waits && streaming == ss.Num {
+		a.Recommendations = append(a.Recommendations, Finding{
+			Title:    "No spare synchronous standby",
+			Severity: SeverityRec,
+			Code:     "sync-quorum-mismatch",
+			Description: fmt.Sprintf("synchronous_standby_names = '%s' needs %d synchronous standby(s) (%s) and exactly %d listed standby(s) are streaming: commits block as soon as one of them disconnects or restarts.",
+				ssn.Val, ss.Num, method, streaming),
+			Action: fmt.Sprintf("List one more standby than num_sync requires, e.g. ANY %d of %d standbys, or lower num_sync if the durability guarantee allows it.", ss.Num, ss.Num+1),
+			Evidence: settingsEvidence(map[string]float64{"num_sync": float64(ss.Num), "streaming": float64(streaming)},
+				"synchronous_standby_names"),
+		})
+	}
+}
+
+// analyzeSessionChurn flags databases whose sessions are short-lived and
+// frequent, a client opening a connection per request: each one costs a
+// backend fork and authentication that a pooler would save, sized by the
+// mean number of sessions actually running statements.
+func analyzeSessionChurn(a *Analysis, stats []collect.SessionStat, pool pooler) {
+	var churn []collect.SessionStat
+	for _, st := range stats {
+		if st.Sessions >= churnMinSessions && st.PerHour() >= churnMinPerHour && st.AvgSessionMs() < churnMaxSessionMs {
+			churn = append(churn, st)
+		}
+	}
+	if len(churn) == 0 {
+		return
+	}
+	sort.Slice(churn, func(i, j int) bool { return churn[i].PerHour() > churn[j].PerHour() })
+
+	var parts []string
+	var perHour, active float64
+	var sessions, failed int64
+	ev := &Evidence{}
+	for _, st := range churn {
+		ev.Objects = append(ev.Objects, Object{Kind: "database", Name: st.Datname, Metrics: map[string]float64{
+			"sessions_per_hour": st.PerHour(), "avg_session_ms": st.AvgSessionMs(),
+		}})
+		perHour += st.PerHour()
+		active += st.AvgActive()
+		sessions += st.Sessions
+		failed += st.Abandoned + st.Fatal + st.Killed
+		parts = append(parts, fmt.Sprintf("%s (%s sessions/h lasting %s on average, %.0f%% of it running statements)",
+			st.Datname, formatThousands0(st.PerHour()), humanizeMs(st.AvgSessionMs()), st.ActivePct()))
+	}
+	desc := fmt.Sprintf("%d database(s) open short-lived sessions at a high rate: %s. At about %.0f ms of setup each, that is %s of backend startup per hour.",
+		len(churn), strings.Join(parts, ", "), sessionSetupMs, humanizeMs(perHour*sessionSetupMs))
+	if pct := float64(failed) / float64(sessions) * 100; pct >= sessionErrorPct {
+		desc += fmt.Sprintf(" %.1f%% of these sessions were abandoned by the client, ended by a fatal error or killed.", pct)
+	}
+	// Twice the mean leaves room for bursts
+	size := max(int(math.Ceil(active*2)), 1)
+	a.Recommendations = append(a.Recommendations, Finding{
+		Title:       "Connection churn",
+		Severity:    SeverityRec,
+		Code:        "connection-churn",
+		Description: desc,
+		Action: pool.advice(fmt.Sprintf("Keep connections open in the application's pool or put a transaction-mode pooler (pgbouncer) in front: on average %.1f session(s) run a statement at once, so a pool of about %d server connection(s) serves this load without a fork per connect.",
+			active, size), fmt.Sprintf("find the clients connecting around it or raise its server_lifetime and server_idle_timeout so server connections are reused; on average %.1f session(s) run a statement at once, so a pool of about %d server connection(s) serves this load.",
+			active, size)),
+		Evidence: ev,
+	})
+}
+
+// analyzeStorageForecast extrapolates the database growth fitted over the
+// archived runs: the days until the volume given by -disk-size fills, or the
+// growth alone when its size is unknown.
+func analyzeStorageForecast(a *Analysis, res collect.Result) {
+	var dbs, tables []collect.SizeGrowth
+	var perDay float64
+	for _, g := range res.SizeGrowth {
+		if g.Days < storageMinHistoryDays {
+			continue
+		}
+		if g.IsDatabase() {
+			dbs = append(dbs, g)
+			perDay += g.BytesPerDay
+		} else if g.BytesPerDay > 0 && !g.Index {
+			tables = append(tables, g)
+		}
+	}
+	if len(dbs) == 0 {
+		return
+	}
+	var used int64
+	for _, db := range res.DBs {
+		used += db.SizeBytes
+	}
+	byGrowth := func(list []collect.SizeGrowth) {
+		sort.Slice(list, func(i, j int) bool { return list[i].BytesPerDay > list[j].BytesPerDay })
+	}
+	byGrowth(dbs)
+	byGrowth(tables)
+
+	objectName := res.ObjectNamer()
+	ev := &Evidence{Metrics: map[string]float64{"used_bytes": float64(used), "bytes_per_day": perDay}}
+	var growing []string
+	for _, g := range dbs {
+		ev.Objects = append(ev.Objects, Object{Kind: "database", Name: g.Database,
+			Metrics: map[string]float64{"size_bytes": float64(g.Bytes), "bytes_per_day": g.BytesPerDay}})
+		if g.BytesPerDay > 0 && len(growing) < storageMaxItems {
+			growing = append(growing, fmt.Sprintf("%s %s/day", g.Database, formatSize(g.BytesPerDay)))
+		}
+	}
+	for i, g := range tables {
+		if i == storageMaxItems {
+			break
+		}
+		ev.Objects = append(ev.Objects, Object{Kind: "table", Database: g.Database, Schema: g.Schema, Name: g.Name,
+			Metrics: map[string]float64{"size_bytes": float64(g.Bytes), "bytes_per_day": g.BytesPerDay}})
+		growing = append(growing, fmt.Sprintf("table %s %s/day", objectName(g.Database, g.Schema, g.Name), formatSize(g.BytesPerDay)))
+	}
+	span := 0.0
+	for _, g := range dbs {
+		span = max(span, g.Days)
+	}
+	desc := fmt.Sprintf("Over the last %.0f day(s) of archived runs, the databases grew by %s/day to %s in total.", span, formatSize(perDay), formatSize(float64(used)))
+	if perDay <= 0 {
+		desc = fmt.Sprintf("Over the last %.0f day(s) of archived runs, the databases did not grow (%s in total).", span, formatSize(float64(used)))
+	}
+	if len(growing) > 0 {
+		desc += " Growing fastest: " + strings.Join(growing, ", ") + "."
+	}
+
+	if res.DiskSizeBytes <= 0 {
+		if perDay > 0 {
+			desc += " Set -disk-size to the capacity of the data volume to forecast when it fills."
+		}
+		a.Infos = append(a.Infos, Finding{
+			Title:       "Storage growth",
+			Severity:    SeverityInfo,
+			Code:        "storage-growth",
+			Description: desc,
+			Evidence:    ev,
+		})
+		return
+	}
+	ev.Metrics["disk_size_bytes"] = float64(res.DiskSizeBytes)
+	free := float64(res.DiskSizeBytes - used)
+	if perDay <= 0 {
+		a.Infos = append(a.Infos, Finding{
+			Title:       "Storage forecast",
+			Severity:    SeverityInfo,
+			Code:        "storage-forecast",
+			Description: desc + fmt.Sprintf(" %s of %s is free.", formatSize(max(free, 0)), formatSize(float64(res.DiskSizeBytes))),
+			Evidence:    ev,
+		})
+		return
+	}
+	days := max(free/perDay, 0)
+	ev.Metrics["days_until_full"] = days
+	desc += fmt.Sprintf(" At this rate the %s volume, %s free, is full in ~%.0f day(s).", formatSize(float64(res.DiskSizeBytes)), formatSize(max(free, 0)), days)
+	f := Finding{
+		Title:       "Storage full forecast",
+		Code:        "storage-full-forecast",
+		Description: desc,
+		Action:      "Grow the volume or free space before then: archive or drop old data (partition the fastest growing tables to drop partitions), remove unused indexes and reclaim bloat. WAL, temporary files and logs share the volume on top of the database sizes, so the disk fills sooner if they do.",
+		Evidence:    ev,
+	}
+	switch {
+	case days <= storageFullWarnDays:
+		f.Severity = SeverityWarning
+		a.Warnings = append(a.Warnings, f)
+	case days <= storageFullRecDays:
+		f.Severity = SeverityRec
+		a.Recommendations = append(a.Recommendations, f)
+	default:
+		a.Infos = append(a.Infos, Finding{
+			Title:       "Storage forecast",
+			Severity:    SeverityInfo,
+			Code:        "storage-forecast",
+			Description: desc,
+			Evidence:    ev,
+		})
+	}
+}
+
+// analyzeConnectionForecast compares the peak client connections of the
+// archived runs with max_connections, extrapolates their trend to the day the
+// peak reaches it and sizes application pools by their busy peaks.
+func analyzeConnectionForecast(a *Analysis, h *collect.ConnectionHistory, maxConns int, pool pooler) {
+	if h == nil || maxConns <= 0 || h.Total.Runs < 2 {
+		return
+	}
+	tot := h.Total
+	peakPct := float64(tot.Peak) / float64(maxConns) * 100
+	ev := &Evidence{Metrics: map[string]float64{
+		"peak_connections": float64(tot.Peak), "peak_busy_connections": float64(tot.PeakBusy),
+		"connections": float64(tot.Current), "max_connections": float64(maxConns), "peak_pct": peakPct,
+	}}
+	desc := fmt.Sprintf("Over the last %d runs (%.0f day(s)), client connections peaked at %d of max_connections %d (%.0f%%) on %s, %d of them busy; %d now.",
+		tot.Runs, tot.Days, tot.Peak, maxConns, peakPct, tot.PeakAt.UTC().Format("2006-01-02 15:04 UTC"), tot.PeakBusy, tot.Current)
+
+	days := -1.0
+	if tot.Days >= connMinHistoryDays && tot.PerDay > 0 {
+		days = max(float64(maxConns-tot.Peak)/tot.PerDay, 0)
+		ev.Metrics["connections_per_day"] = tot.PerDay
+		ev.Metrics["days_until_limit"] = days
+		desc += fmt.Sprintf(" They grow by %.1f a day: at this rate the peak reaches max_connections in ~%.0f day(s).", tot.PerDay, days)
+	}
+
+	var apps, pools []string
+	for i, app := range h.Apps {
+		if i == connMaxApps {
+			apps = append(apps, fmt.Sprintf("and %d more", len(h.Apps)-i))
+			break
+		}
+		size := max(int(math.Ceil(float64(app.PeakBusy)*connPoolHeadroom)), 1)
+		ev.Objects = append(ev.Objects, Object{Kind: "application", Name: app.Application, Metrics: map[string]float64{
+			"peak_connections": float64(app.Peak), "peak_busy_connections": float64(app.PeakBusy), "connections_per_day": app.PerDay, "pool_size": float64(size),
+		}})
+		name := app.Application
+		if name == "" {
+			name = "clients without application_name"
+		}
+		trend := ""
+		if app.Days >= connMinHistoryDays && math.Abs(app.PerDay) >= 0.1 {
+			trend = fmt.Sprintf(", %+.1f/day", app.PerDay)
+		}
+		apps = append(apps, fmt.Sprintf("%s peak %d (%d busy%s)", name, app.Peak, app.PeakBusy, trend))
+		pools = append(pools, fmt.Sprintf("%s ~%d", name, size))
+	}
+	if len(apps) > 0 {
+		desc += " By application: " + strings.Join(apps, ", ") + "."
+	}
+
+	f := Finding{
+		Title:       "Connection limit forecast",
+		Code:        "connection-limit-forecast",
+		Description: desc,
+		Evidence:    ev,
+	}
+	if len(pools) > 0 {
+		sizes := strings.Join(pools, ", ")
+		f.Action = pool.advice(
+			fmt.Sprintf("Size application pools by their busy peak plus %.0f%% rather than their connection count: %s. Idle connections hold server slots too; a transaction-mode pooler (pgbouncer) in front lets many client connections share these. Raising max_connections costs memory per backend.",
+				(connPoolHeadroom-1)*100, sizes),
+			fmt.Sprintf("size its server pools per application by their busy peak plus %.0f%%: %s, keeping the total below max_connections.", (connPoolHeadroom-1)*100, sizes))
+	}
+	switch {
+	case days >= 0 && days <= connLimitWarnDays:
+		f.Severity = SeverityWarning
+		a.Warnings = append(a.Warnings, f)
+	case (days >= 0 && days <= connLimitRecDays) || peakPct >= connectionUsageWarningPct:
+		f.Severity = SeverityRec
+		a.Recommendations = append(a.Recommendations, f)
+	default:
+		f.Title, f.Severity, f.Code, f.Action = "Connection peaks", SeverityInfo, "connection-peaks", ""
+		a.Infos = append(a.Infos, f)
+	}
+}
+
+// analyzeTrends compares the run with the earlier runs in history, newest
+// first; nothing without history.
+func analyzeTrends(a *Analysis, res collect.Result, o options) {
+	if len(o.history) == 0 {
+		return
+	}
+	analyzeCacheHitTrend(a, res.CacheHitCurrent, o.history)
+	analyzeQueryRegressions(a, res.Statements.Unique(), o.history)
+	analyzeRelationGrowth(a, o.historyGrowth, res.ObjectNamer())
+	analyzeConnectionGrowth(a, o.historyConns)
+}
+
+// analyzeCacheHitTrend flags a cache hit ratio fallen below its median over
+// the earlier runs: the working set outgrows shared_buffers or a new workload
+// reads cold data.
+func analyzeCacheHitTrend(a *Analysis, current float64, history []collect.Result) {
+	var past []float64
+	for _, h := range history {
+		if h.CacheHitCurrent > 0 {
+			past = append(past, h.CacheHitCurrent)
+		}
+	}
+	if current <= 0 || len(past) == 0 {
+		return
+	}
+	was := median(past)
+	if was-current < trendCacheHitDrop {
+		return
+	}
+	a.Recommendations = append(a.Recommendations, Finding{
+		Title:    "Cache hit ratio falling",
+		Severity: SeverityRec,
+		Code:     "cache-hit-trend",
+		Description: fmt.Sprintf("The cache hit ratio is %.2f%%, down %.2f points from its median of %.2f%% over the previous %d run(s).",
+			current, was-current, was, len(past)),
+		Action: "Check whether the data or a new workload outgrew shared_buffers: compare the regressed and new top queries by shared blocks read, and size shared_buffers (and effective_cache_size) for the working set.",
+		Evidence: settingsEvidence(map[string]float64{
+			"cache_hit_pct": current, "previous_median_pct": was, "drop_points": was - current,
+		}, "shared_buffers"),
+	})
+}
+
+// analyzeQueryRegressions flags statements whose mean time grew well beyond
+// their median over the earlier runs they were in, slowest factor first.
+func analyzeQueryRegressions(a *Analysis, stmts []collect.Statement, history []collect.Result) {
+	past := map[string][]float64{}
+	for _, h := range history {
+		for _, st := range h.Statements.TopByTotalTime {
+			if st.MeanTime > 0 {
+				past[st.Key()] = append(past[st.Key()], st.MeanTime)
+			}
+		}
+	}
+	type regression struct {
+		st     collect.Statement
+		was    float64
+		factor float64
+	}
+	var slow []regression
+	for _, st := range stmts {
+		if st.Calls < trendRegressionMinCalls || len(past[st.Key()]) == 0 {
+			continue
+		}
+		was := median(past[st.Key()])
+		if st.MeanTime >= was*trendRegressionFactor && st.MeanTime-was >= trendRegressionMinMs {
+			slow = append(slow, regression{st, was, st.MeanTime / was})
+		}
+	}
+	if len(slow) == 0 {
+		return
+	}
+	sort.SliceStable(slow, func(i, j int) bool { return slow[i].factor > slow[j].factor })
+
+	ev := &Evidence{Metrics: map[string]float64{"regressed_statements": float64(len(slow))}}
+	var list []string
+	for i, r := range slow {
+		ev.QueryIDs = append(ev.QueryIDs, queryIDs(r.st)...)
+		if i >= trendMaxItems {
+			continue
+		}
+		q := strings.Join(strings.Fields(r.st.Query), " ")
+		if len(q) > 80 {
+			q = q[:77] + "..."
+		}
+		list = append(list, fmt.Sprintf("%.1f× (%s → %s mean): %s", r.factor, humanizeMs(r.was), humanizeMs(r.st.MeanTime), q))
+	}
+	desc := fmt.Sprintf("%d top statement(s) got slower than their median over the previous runs: %s", len(slow), strings.Join(list, "; "))
+	if len(slow) > trendMaxItems {
+		desc += fmt.Sprintf(" and %d more", len(slow)-trendMaxItems)
+	}
+	a.Recommendations = append(a.Recommendations, Finding{
+		Title:       "Query time regressions",
+		Severity:    SeverityRec,
+		Code:        "query-regression",
+		Description: desc + ".",
+		Action:      "Compare the current plans with the previous ones (a changed plan after ANALYZE, a dropped or bloated index, grown data) and check what was deployed since; auto_explain catches the slow executions.",
+		Evidence:    ev,
+	})
+}
+
+// analyzeRelationGrowth flags tables and indexes that grew by half or more,
+// and by at least trendGrowthMinBytes, over the fitted runs.
+func analyzeRelationGrowth(a *Analysis, growth []collect.SizeGrowth, objectName func(db, schema, name string) string) {
+	var grown []collect.SizeGrowth
+	for _, g := range growth {
+		if !g.IsDatabase() && g.Runs > 1 && g.Grown() >= trendGrowthMinBytes && g.GrownPct() >= trendGrowthPct {
+			grown = append(grown, g)
+		}
+	}
+	if len(grown) == 0 {
+		return
+	}
+	sort.SliceStable(grown, func(i, j int) bool { return grown[i].Grown() > grown[j].Grown() })
+
+	ev := &Evidence{}
+	var list []string
+	for i, g := range grown {
+		kind := "table"
+		if g.Index {
+			kind = "index"
+		}
+		ev.Objects = append(ev.Objects, Object{Kind: kind, Database: g.Database, Schema: g.Schema, Name: g.Name,
+			Metrics: map[string]float64{"size_bytes": float64(g.Bytes), "grown_bytes": float64(g.Grown()), "grown_pct": g.GrownPct()}})
+		if i < trendMaxItems {
+			list = append(list, fmt.Sprintf("%s %s %s → %s (+%.0f%% in %.0f day(s))", kind, objectName(g.Database, g.Schema, g.Name),
+				formatSize(float64(g.StartBytes)), formatSize(float64(g.Bytes)), g.GrownPct(), g.Days))
+		}
+	}
+	desc := fmt.Sprintf("%d table(s) and index(es) grew by %.0f%% or more over the archived runs: %s", len(grown), trendGrowthPct, strings.Join(list, "; "))
+	if len(grown) > trendMaxItems {
+		desc += fmt.Sprintf(" and %d more", len(grown)-trendMaxItems)
+	}
+	a.Recommendations = append(a.Recommendations, Finding{
+		Title:       "Tables and indexes growing fast",
+		Severity:    SeverityRec,
+		Code:        "relation-growth",
+		Description: desc + ".",
+		Action:      "Check whether the growth is data or bloat (dead tuples, an index grown faster than its table) and plan for it: retention or partitioning for data, VACUUM tuning or REINDEX CONCURRENTLY for bloat.",
+		Evidence:    ev,
+	})
+}
+
+// analyzeConnectionGrowth flags client connections growing by half or more
+// over the runs, unless a connection limit forecast already covers them.
+func analyzeConnectionGrowth(a *Analysis, h *collect.ConnectionHistory) {
+	if h == nil || h.Total.Runs < 2 || h.Total.Days < connMinHistoryDays || h.Total.PerDay <= 0 {
+		return
+	}
+	for _, list := range [][]Finding{a.Warnings, a.Recommendations} {
+		for _, f := range list {
+			if f.Code == "connection-limit-forecast" {
+				return
+			}
+		}
+	}
+	tot := h.Total
+	grown := tot.PerDay * tot.Days
+	start := float64(tot.Current) - grown
+	if start < 1 || grown < trendConnGrowthMin || grown/start*100 < trendConnGrowthPct {
+		return
+	}
+	a.Recommendations = append(a.Recommendations, Finding{
+		Title:    "Client connections growing",
+		Severity: SeverityRec,
+		Code:     "connection-growth",
+		Description: fmt.Sprintf("Client connections grew from ~%.0f to %d over the last %d runs (%.0f day(s)), %.1f a day; the peak was %d.",
+			start, tot.Current, tot.Runs, tot.Days, tot.PerDay, tot.Peak),
+		Action: "Find the applications adding connections in the Connections section and cap their pools, or put a transaction-mode pooler (pgbouncer) in front, before they reach max_connections.",
+		Evidence: &Evidence{Metrics: map[string]float64{
+			"connections": float64(tot.Current), "connections_per_day": tot.PerDay, "peak_connections": float64(tot.Peak),
+		}},
+	})
+}
+
+// median is the middle value of v, the mean of the two middle ones for an
+// even count; v is left unsorted.
+func median(v []float64) float64 {
+	s := append([]float64(nil), v...)
+	sort.Float64s(s)
+	if len(s)%2 == 1 {
+		return s[len(s)/2]
+	}
+	return (s[len(s)/2-1] + s[len(s)/2]) / 2
+}
+
+// analyzeColumnSkew flags columns that top statements filter or join on where
+// one value, or NULL, holds most of the rows. An index on such a column helps
+// only the lookups of the rare values, while the planner, estimating from the
+// average or a generic plan, may pick it for the hot one too. A partial index
+// without the hot value serves the rare lookups at a fraction of the size; on
+// large tables with few distinct values the column is a LIST partitioning
+// key.
+func analyzeColumnSkew(a *Analysis, cols []collect.ColumnStat, tables []collect.TableStat) {
+	var skewed []collect.ColumnStat
+	for _, c := range cols {
+		if c.HotFreq >= skewHotFreq || c.NullFrac >= skewHotFreq {
+			skewed = append(skewed, c)
+		}
+	}
+	if len(skewed) == 0 {
+		return
+	}
+	size := func(c collect.ColumnStat) int64 {
+		for _, t := range tables {
+			if t.Database == c.Database && t.Schema == c.Schema && t.Name == c.Table {
+				return t.SizeBytes
+			}
+		}
+		return 0
+	}
+
+	ev := &Evidence{}
+	var items, ddl, partition []string
+	for i, c := range skewed {
+		if i == skewMaxColumns {
+			items = append(items, fmt.Sprintf("and %d more", len(skewed)-i))
+			break
+		}
+		table := c.Schema + "." + c.Table
+		ev.Objects = append(ev.Objects, Object{Kind: "column", Database: c.Database, Schema: c.Schema, Name: c.Table + "." + c.Column, Metrics: map[string]float64{
+			"hot_freq": c.HotFreq, "null_frac": c.NullFrac, "n_distinct": c.NDistinct, "calls": c.Calls,
+		}})
+		if c.NullFrac >= skewHotFreq {
+			items = append(items, fmt.Sprintf("%s.%s is NULL in %.0f%% of rows (%s calls)", table, c.Column, c.NullFrac*100, formatThousands0(c.Calls)))
+			ddl = append(ddl, fmt.Sprintf("CREATE INDEX CONCURRENTLY ON %s (%s) WHERE %s IS NOT NULL;", table, c.Column, c.Column))
+			continue
+		}
+		hot := "'" + strings.ReplaceAll(c.HotValue, "'", "''") + "'"
+		items = append(items, fmt.Sprintf("%s.%s = %s in %.0f%% of rows (%s calls)", table, c.Column, hot, c.HotFreq*100, formatThousands0(c.Calls)))
+		ddl = append(ddl, fmt.Sprintf("CREATE INDEX CONCURRENTLY ON %s (%s) WHERE %s <> %s;", table, c.Column, c.Column, hot))
+		if c.NDistinct > 0 && c.NDistinct <= skewPartitionMaxDistinct && size(c) >= skewPartitionBytes {
+			partition = append(partition, fmt.Sprintf("%s by LIST (%s)", table, c.Column))
+		}
+	}
+	action := "Index the rare values only with a partial index, which stays small and lets lookups of the hot value fall back to a scan: " + strings.Join(ddl, " ") +
+		" Queries must repeat the index predicate (or use a literal the planner can prove against it). With prepared statements, check the generic plan is not chosen for both hot and rare parameters (plan_cache_mode = force_custom_plan for the affected sessions)."
+	if len(partition) > 0 {
+		action += " On large tables with few distinct values consider partitioning by the skew key, so the hot value gets its own partition: " + strings.Join(partition, ", ") + "."
+	}
+	a.Recommendations = append(a.Recommendations, Finding{
+		Title:       "Skewed filter columns",
+		Severity:    SeverityRec,
+		Code:        "skewed-filter-columns",
+		Description: "One value holds most rows of columns the top statements filter or join on: " + strings.Join(items, "; ") + ". A plain index on them serves only the rare values, and row estimates that average over the skew mislead join and scan choices.",
+		Action:      action,
+		Evidence:    ev,
+	})
+}
+
+// analyzeRetention flags append-only tables where rows older than
+// retentionAgeDays, by their row age column, take at least
+// retentionMinOldBytes, estimating the share of old rows from the column
+// histogram. Partitions holding only old rows can be dropped whole; other
+// tables need a retention policy, ideally by partitioning on the column.
+func analyzeRetention(a *Analysis, tables []collect.RetentionTable) {
+	type candidate struct {
+		t     collect.RetentionTable
+		old   float64 // fraction of rows older than the cutoff
+		bytes int64   // space they take, estimated
+	}
+	var found []candidate
+	var total int64
+	for _, t := range tables {
+		if t.Inserts <= 0 || float64(t.Updates+t.Deletes) > float64(t.Inserts)*retentionAppendOnlyPct/100 || len(t.Bounds) < 2 {
+			continue
+		}
+		old := t.OlderThan(t.CheckedAt.AddDate(0, 0, -retentionAgeDays))
+		bytes := int64(float64(t.SizeBytes) * old)
+		if bytes < retentionMinOldBytes {
+			continue
+		}
+		found = append(found, candidate{t, old, bytes})
+		total += bytes
+	}
+	if len(found) == 0 {
+		return
+	}
+	sort.SliceStable(found, func(i, j int) bool { return found[i].bytes > found[j].bytes })
+
+	ev := &Evidence{Metrics: map[string]float64{"reclaimable_bytes": float64(total)}}
+	var items, drop, policy []string
+	for i, c := range found {
+		t := c.t
+		name := t.Schema + "." + t.Table
+		ev.Objects = append(ev.Objects, Object{Kind: "table", Database: t.Database, Schema: t.Schema, Name: t.Table, Metrics: map[string]float64{
+			"size_bytes": float64(t.SizeBytes), "old_rows_pct": c.old * 100, "old_bytes": float64(c.bytes),
+		}})
+		if i < retentionMaxTables {
+			items = append(items, fmt.Sprintf("%s: %.0f%% of rows (~%s of %s) older than %d days by %s, oldest %s",
+				name, c.old*100, formatSize(float64(c.bytes)), formatSize(float64(t.SizeBytes)), retentionAgeDays, t.Column, t.Bounds[0].UTC().Format("2006-01-02")))
+		}
+		switch {
+		case t.Parent != "" && c.old >= 1:
+			drop = append(drop, fmt.Sprintf("ALTER TABLE %s.%s DETACH PARTITION %s CONCURRENTLY;", t.Schema, t.Parent, name))
+		case t.Parent == "":
+			policy = append(policy, fmt.Sprintf("%s by RANGE (%s)", name, t.Column))
+		}
+	}
+	if len(found) > retentionMaxTables {
+		items = append(items, fmt.Sprintf("and %d more", len(found)-retentionMaxTables))
+	}
+
+	var action []string
+	if len(drop) > 0 {
+		action = append(action, "Partitions holding only old rows can be detached, archived (pg_dump -t) and dropped, which frees their space at once: "+strings.Join(drop, " "))
+	}
+	if len(policy) > 0 {
+		action = append(action, fmt.Sprintf("Agree a retention period with the data owners, then partition %s so expiring data is a DROP PARTITION instead of a DELETE.", strings.Join(policy, ", "))+
+			" Until then, archive old rows (COPY (SELECT ...) TO, or an archive table) and delete them in small batches; deleted space is reused by new rows but only returned to the OS by pg_repack or VACUUM FULL.")
+	}
+	action = append(action, fmt.Sprintf("Row ages come from the column statistics (ANALYZE them if stale); pass -retention-columns when the creation time column has another name. Old means over %d days.", retentionAgeDays))
+	a.Recommendations = append(a.Recommendations, Finding{
+		Title:       "Data retention candidates",
+		Severity:    SeverityRec,
+		Code:        "retention-candidates",
+		Description: fmt.Sprintf("Append-only tables hold ~%s of rows older than %d days: %s.", formatSize(float64(total)), retentionAgeDays, strings.Join(items, "; ")),
+		Action:      strings.Join(action, " "),
+		Evidence:    ev,
+	})
+}
+
+// outdatedClients are client libraries with known problems, matched on the
+// library part of the application name (or all of it when it carries no
+// version). Versions below "below" are flagged; an empty "below" flags every
+// version.
+var outdatedClients = []struct {
+	match *regexp.Regexp
+	below string
+	why   string
+}{
+	{regexp.MustCompile(`(?i)^pgAdmin III\b`), "", "unmaintained since 2016, it misreads the catalogs of PostgreSQL 10 and later"},
+	{regexp.MustCompile(`(?i)^(PostgreSQL JDBC Driver|pgjdbc)$`), "42.2.0", "lacks SCRAM authentication and retries server-prepared statements poorly after schema changes (cached plan must not change result type)"},
+	{regexp.MustCompile(`(?i)^npgsql$`), "4.0", "lacks SCRAM authentication"},
+}
+
+// outdatedClient returns why a client library is known to be problematic, or
+// an empty string.
+func outdatedClient(c collect.ClientFingerprint) string {
+	name := c.Library
+	if name == "" {
+		name = c.Application
+	}
+	for _, o := range outdatedClients {
+		if !o.match.MatchString(name) {
+			continue
+		}
+		if o.below == "" || (c.Version != "" && compareVersions(c.Version, o.below) < 0) {
+			return o.why
+		}
+	}
+	return ""
+}
+
+// compareVersions compares dotted numeric versions: -1, 0 or 1.
+func compareVersions(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < max(len(as), len(bs)); i++ {
+		var x, y int
+		if i < len(as) {
+			x, _ = strconv.Atoi(as[i])
+		}
+		if i < len(bs) {
+			y, _ = strconv.Atoi(bs[i])
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+// clientLabel names a client fingerprint in findings.
+func clientLabel(c collect.ClientFingerprint) string {
+	if c.Application == "" {
+		return "(no application_name)"
+	}
+	return c.Application
+}
+
+// analyzeClientFingerprints flags client libraries known to cause problems,
+// connections on TLS 1.0/1.1, unencrypted remote connections while the server
+// offers SSL, and libraries connecting with several versions at once. The
+// server exposes no driver or protocol details beyond application_name and
+// the transport encryption, so libraries are only recognized by name.
+func analyzeClientFingerprints(a *Analysis, clients []collect.ClientFingerprint, ssl string) {
+	listed := func(items []string) string {
+		if len(items) > clientMaxListed {
+			items = append(items[:clientMaxListed:clientMaxListed], fmt.Sprintf("and %d more", len(items)-clientMaxListed))
+		}
+		return strings.Join(items, "; ")
+	}
+	object := func(c collect.ClientFingerprint) Object {
+		return Object{Kind: "application", Name: clientLabel(c), Metrics: map[string]float64{"connections": float64(c.Count)}}
+	}
+
+	var outdated, tls, plain []string
+	outdatedEv, tlsEv, plainEv := &Evidence{}, &Evidence{}, &Evidence{}
+	versions := map[string][]string{}
+	var libraries []string
+	for _, c := range clients {
+		if why := outdatedClient(c); why != "" {
+			outdated = append(outdated, fmt.Sprintf("%s (%d connections): %s", clientLabel(c), c.Count, why))
+			outdatedEv.Objects = append(outdatedEv.Objects, object(c))
+		}
+		if c.SSL && (c.SSLVersion == "TLSv1" || c.SSLVersion == "TLSv1.1" || strings.HasPrefix(c.SSLVersion, "SSL")) {
+			tls = append(tls, fmt.Sprintf("%s (%d connections, %s)", clientLabel(c), c.Count, c.SSLVersion))
+			tlsEv.Objects = append(tlsEv.Objects, object(c))
+		}
+		if !c.Local && !c.Encrypted() {
+			plain = append(plain, fmt.Sprintf("%s (%d connections)", clientLabel(c), c.Count))
+			plainEv.Objects = append(plainEv.Objects, object(c))
+		}
+		if c.Library != "" {
+			if _, ok := versions[c.Library]; !ok {
+				libraries = append(libraries, c.Library)
+			}
+			if !slices.Contains(versions[c.Library], c.Version) {
+				versions[c.Library] = append(versions[c.Library], c.Version)
+			}
+		}
+	}
+
+	if len(outdated) > 0 {
+		a.Recommendations = append(a.Recommendations, Finding{
+			Title:       "Outdated client drivers",
+			Severity:    SeverityRec,
+			Code:        "client-outdated-driver",
+			Description: "Clients connect with drivers or tools known to cause problems: " + listed(outdated) + ".",
+			Action:      "Upgrade these drivers to a current release; old drivers miss authentication methods (SCRAM) and protocol fixes, and break in subtle ways on newer servers. Drivers are recognized by application_name only, so set it to the application and driver version.",
+			Evidence:    outdatedEv,
+		})
+	}
+	if len(tls) > 0 {
+		a.Recommendations = append(a.Recommendations, Finding{
+			Title:       "Clients on outdated TLS versions",
+			Severity:    SeverityRec,
+			Code:        "client-tls-outdated",
+			Description: "Connections use deprecated TLS protocol versions: " + listed(tls) + ".",
+			Action:      "Upgrade the client TLS libraries, then set ssl_min_protocol_version = 'TLSv1.2' (PostgreSQL 12+) so the server refuses older protocols.",
+			Evidence:    tlsEv,
+		})
+	}
+	if len(plain) > 0 && ssl == "on" {
+		a.Recommendations = append(a.Recommendations, Finding{
+			Title:       "Unencrypted remote connections",
+			Severity:    SeverityRec,
+			Code:        "client-unencrypted",
+			Description: "The server offers SSL, yet remote clients connect unencrypted: " + listed(plain) + ".",
+			Action:      "Set sslmode=require (or verify-full) in these clients' connection strings, then switch their pg_hba.conf entries from host to hostssl so plain connections are refused.",
+			Evidence:    plainEv,
+		})
+	}
+
+	var mixed []string
+	mixedEv := &Evidence{}
+	for _, lib := range libraries {
+		vs := versions[lib]
+		if len(vs) < 2 {
+			continue
+		}
+		slices.SortFunc(vs, compareVersions)
+		mixed = append(mixed, fmt.Sprintf("%s %s", lib, strings.Join(vs, ", ")))
+		mixedEv.Objects = append(mixedEv.Objects, Object{Kind: "application", Name: lib, Metrics: map[string]float64{"versions": float64(len(vs))}})
+	}
+	if len(mixed) > 0 {
+		a.Infos = append(a.Infos, Finding{
+			Title:       "Mixed client versions",
+			Severity:    SeverityInfo,
+			Code:        "client-versions-mixed",
+			Description: "Applications connect with several versions at once: " + listed(mixed) + ".",
+			Action:      "Expected during a rolling deploy; otherwise some instances were left behind. Align them so fixes and connection settings apply everywhere.",
+			Evidence:    mixedEv,
+		})
+	}
+}
+
+// analyzeRoleIdleTimeouts suggests idle_in_transaction_session_timeout and,
+// on PostgreSQL 14+, idle_session_timeout values for the roles no timeout
+// applies to, sized from the idle times of their sessions, and adds the ALTER
+// ROLE statements to the tuning script. Poolers keep idle server connections
+// on purpose, so their roles get no idle_session_timeout.
+func analyzeRoleIdleTimeouts(a *Analysis, idle []collect.RoleIdle) {
+	type role struct {
+		name          string
+		apps          []string
+		sessions      int
+		medianIdle    float64 // largest median over the role's applications
+		maxIdle       float64
+		idleInTx      int
+		maxIdleInTx   float64
+		iit, ist      string
+		hasIST, proxy bool
+	}
+	secs := func(f float64) time.Duration { return time.Duration(f * float64(time.Second)) }
+	var roles []*role
+	byName := map[string]*role{}
+	for _, r := range idle {
+		ro, ok := byName[r.Role]
+		if !ok {
+			ro = &role{name: r.Role, iit: r.IdleInTxTimeout, ist: r.IdleSessionTimeout, hasIST: r.HasIdleSessionTimeout}
+			byName[r.Role] = ro
+			roles = append(roles, ro)
+		}
+		if r.Application != "" {
+			ro.apps = append(ro.apps, r.Application)
+		}
+		ro.sessions += r.Sessions
+		ro.medianIdle = max(ro.medianIdle, r.IdleMedianSeconds)
+		ro.maxIdle = max(ro.maxIdle, r.IdleMaxSeconds)
+		ro.idleInTx += r.IdleInTx
+		ro.maxIdleInTx = max(ro.maxIdleInTx, r.IdleInTxMaxSeconds)
+		for _, name := range knownPoolers {
+			if strings.Contains(strings.ToLower(r.Application), name) || strings.EqualFold(r.Role, name) {
+				ro.proxy = true
+			}
+		}
+	}
+
+	var items, stmts []string
+	ev := &Evidence{}
+	for _, ro := range roles {
+		var set, why []string
+		if ro.iit == "" {
+			timeout := idleTxTimeoutDefault
+			if maxTx := secs(ro.maxIdleInTx); maxTx > 0 && maxTx < idleTxTimeoutMax {
+				timeout = min(max(timeout, (2*maxTx).Truncate(time.Minute)+time.Minute), idleTxTimeoutMax)
+			}
+			set = append(set, fmt.Sprintf("idle_in_transaction_session_timeout = '%dmin'", int(timeout.Minutes())))
+			if ro.idleInTx > 0 {
+				why = append(why, fmt.Sprintf("%d idle in transaction, longest %s", ro.idleInTx, humanizeDuration(secs(ro.maxIdleInTx))))
+			}
+		}
+		if ro.ist == "" && ro.hasIST && !ro.proxy && secs(ro.maxIdle) >= idleSessionMinAge {
+			timeout := min(max((4*secs(ro.medianIdle)).Truncate(time.Minute)+time.Minute, idleSessionTimeoutMin), idleSessionMinAge)
+			set = append(set, fmt.Sprintf("idle_session_timeout = '%dmin'", int(timeout.Minutes())))
+			why = append(why, fmt.Sprintf("idle for %s at the median, longest %s", humanizeDuration(secs(ro.medianIdle)), humanizeDuration(secs(ro.maxIdle))))
+		}
+		if len(set) == 0 {
+			continue
+		}
+		for _, kv := range set {
+			stmts = append(stmts, fmt.Sprintf("ALTER ROLE %s SET %s;", quoteRole(ro.name), kv))
+		}
+		item := fmt.Sprintf("%s (%d sessions", ro.name, ro.sessions)
+		if len(ro.apps) > 0 {
+			item += " from " + listFirst(ro.apps, 3, ", ")
+		}
+		if len(why) > 0 {
+			item += "; " + strings.Join(why, "; ")
+		}
+		items = append(items, item+")")
+		ev.Objects = append(ev.Objects, Object{Kind: "role", Name: ro.name, Metrics: map[string]float64{
+			"sessions": float64(ro.sessions), "idle_median_seconds": ro.medianIdle, "idle_max_seconds": ro.maxIdle,
+			"idle_in_transaction": float64(ro.idleInTx), "idle_in_transaction_max_seconds": ro.maxIdleInTx,
+		}})
+	}
+	if len(stmts) == 0 {
+		return
+	}
+	a.Tuning = append(a.Tuning, stmts...)
+	a.Recommendations = append(a.Recommendations, Finding{
+		Title:       "Per-role idle timeouts",
+		Severity:    SeverityRec,
+		Code:        "role-idle-timeouts",
+		Description: fmt.Sprintf("No idle timeout applies to %d roles with client sessions: %s.", len(items), listFirst(items, clientMaxListed, "; ")),
+		Action: strings.Join(stmts, " ") + " Values leave room for the idle times seen now; transactions idle for over " + humanizeDuration(idleTxTimeoutMax) +
+			" and sessions idle for over " + humanizeDuration(idleSessionMinAge) + " are taken as abandoned. Role settings apply to new sessions only." +
+			" Application pools must close idle connections before idle_session_timeout does (e.g. HikariCP idleTimeout and maxLifetime below it), or they hand out dead connections.",
+		Evidence: ev,
+	})
+}
+
+// interactiveClients are application_name fragments of the interactive SQL
+// clients people connect with.
+var interactiveClients = []string{"psql", "pgcli", "pgadmin", "dbeaver", "datagrip", "tableplus", "postico", "heidisql", "navicat", "dbvisualizer", "azure data studio", "beekeeper"}
+
+// analyzeRoleLimits lists the login roles a runaway session can exhaust the
+// server with: no connection limit, no statement_timeout. Roles seen only
+// with application clients are left alone, a limit there would cap their
+// pool; the others, seen from interactive clients or not connected now, get
+// ALTER ROLE safeguards in the tuning script. Superusers are exempt from
+// connection limits, so they only get the timeout; replication roles are
+// skipped.
+func analyzeRoleLimits(a *Analysis, res collect.Result) {
+	serverTimeout := false
+	for _, s := range res.Settings {
+		if s.Name == "statement_timeout" && s.Val != "0" {
+			serverTimeout = true
+		}
+	}
+	apps := map[string][]string{}
+	interactive := map[string]bool{}
+	for _, c := range res.ConnectionsByClient {
+		seen := apps[c.User]
+		if c.Application != "" && !slices.Contains(seen, c.Application) {
+			seen = append(seen, c.Application)
+		}
+		apps[c.User] = seen
+		for _, name := range interactiveClients {
+			if strings.Contains(strings.ToLower(c.Application), name) {
+				interactive[c.User] = true
+			}
+		}
+	}
+
+	var items, appRoles, stmts []string
+	ev := &Evidence{}
+	for _, r := range res.LoginRoles {
+		if r.Replication && !r.Superuser {
+			continue
+		}
+		var missing, set []string
+		if r.ConnLimit < 0 && !r.Superuser {
+			missing = append(missing, "no connection limit")
+			set = append(set, fmt.Sprintf("ALTER ROLE %s CONNECTION LIMIT %d;", quoteRole(r.Name), adhocConnLimit))
+		}
+		if r.StatementTimeout == "" && !serverTimeout {
+			missing = append(missing, "no statement_timeout")
+			set = append(set, fmt.Sprintf("ALTER ROLE %s SET statement_timeout = '%s';", quoteRole(r.Name), adhocStatementTimeout))
+		}
+		if len(missing) == 0 {
+			continue
+		}
+		seen, connected := apps[r.Name]
+		if connected && !interactive[r.Name] {
+			appRoles = append(appRoles, r.Name)
+			continue
+		}
+		how := "not connected now"
+		if connected {
+			how = "connects with " + listFirst(seen, 3, ", ")
+		}
+		if r.Superuser {
+			how = "superuser, " + how
+		}
+		items = append(items, fmt.Sprintf("%s (%s: %s)", r.Name, how, strings.Join(missing, ", ")))
+		stmts = append(stmts, set...)
+		ev.Objects = append(ev.Objects, Object{Kind: "role", Name: r.Name})
+	}
+	if len(stmts) == 0 {
+		return
+	}
+	desc := fmt.Sprintf("%d login roles not used by applications can run unbounded sessions: %s.", len(items), listFirst(items, clientMaxListed, "; "))
+	if len(appRoles) > 0 {
+		desc += fmt.Sprintf(" Application roles without limits (%s) are left out: a connection limit there caps the pool, and their timeouts belong in the application.", listFirst(appRoles, clientMaxListed, ", "))
+	}
+	a.Tuning = append(a.Tuning, stmts...)
+	a.Recommendations = append(a.Recommendations, Finding{
+		Title:       "Login roles without limits",
+		Severity:    SeverityRec,
+		Code:        "roles-without-limits",
+		Description: desc,
+		Action: strings.Join(stmts, " ") + " A forgotten ad-hoc query or a script opening connections in a loop then fails on its own instead of exhausting connections or CPU for everyone." +
+			" Raise the timeout per session (SET statement_timeout) for known long maintenance; roles not connected now may belong to batch jobs, check before limiting them.",
+		Evidence: ev,
+	})
+}
+
+// analyzePasswords warns about login roles whose passwords are stored as MD5
+// (or unhashed) and about password_encryption still producing MD5, and about
+// pg_hba.conf lines sending passwords in clear text outside of TLS. Once every
+// readable hash is SCRAM, md5 lines of pg_hba.conf can move to scram-sha-256.
+// Hashes and rules are only readable by superusers.
+func analyzePasswords(a *Analysis, res collect.Result) {
+	var md5, plain []string
+	readable := false
+	ev := settingsEvidence(nil, "password_encryption")
+	for _, r := range res.LoginRoles {
+		switch r.Password {
+		case "":
+			continue
+		case "md5":
+			md5 = append(md5, r.Name)
+		case "plain":
+			plain = append(plain, r.Name)
+		default:
+			readable = true
+			continue
+		}
+		readable = true
+		ev.Objects = append(ev.Objects, Object{Kind: "role", Name: r.Name})
+	}
+	var md5Lines, clearLines []string
+	for _, r := range res.PasswordHBARules {
+		line := fmt.Sprintf("line %d (%s %s %s %s)", r.Line, r.Type, r.Database, r.User, r.Address)
+		switch {
+		case r.Method == "md5":
+			md5Lines = append(md5Lines, line)
+		case r.Method == "password" && r.Type != "hostssl" && r.Type != "local":
+			clearLines = append(clearLines, line)
+		}
+	}
+	enc := res.ConnInfo.PasswordEncryption
+	md5Default := enc == "md5" || enc == "on" // "on" means md5 before PostgreSQL 14
+
+	if len(md5) > 0 || len(plain) > 0 || md5Default {
+		var desc []string
+		if len(md5) > 0 {
+			desc = append(desc, fmt.Sprintf("%d login roles still have MD5 password hashes: %s.", len(md5), listFirst(md5, clientMaxListed, ", ")))
+		}
+		if len(plain) > 0 {
+			desc = append(desc, fmt.Sprintf("%d login roles have unhashed passwords, left over from an old upgrade: %s.", len(plain), listFirst(plain, clientMaxListed, ", ")))
+		}
+		if md5Default {
+			desc = append(desc, fmt.Sprintf("password_encryption = %s hashes new and changed passwords with MD5.", enc))
+		}
+		action := "ALTER SYSTEM SET password_encryption = 'scram-sha-256'; SELECT pg_reload_conf(); then set the passwords of the listed roles again (\\password in psql) so they are stored as SCRAM."
+		if len(md5Lines) > 0 {
+			action += fmt.Sprintf(" The md5 pg_hba.conf entries (%s) accept SCRAM hashes as well; switch them to scram-sha-256 once no MD5 hash is left.", listFirst(md5Lines, clientMaxListed, ", "))
+		}
+		action += " Clients need SCRAM support (libpq 10+, pgjdbc 42.2+, Npgsql 4.0+)."
+		a.Warnings = append(a.Warnings, Finding{
+			Title:       "MD5 password hashes",
+			Severity:    SeverityWarning,
+			Code:        "md5-passwords",
+			Description: strings.Join(desc, " ") + " An MD5 hash is as good as the password to anyone who reads it or sniffs an md5 handshake, and MD5 passwords are deprecated since PostgreSQL 18.",
+			Action:      action,
+			Evidence:    ev,
+		})
+	} else if readable && len(md5Lines) > 0 {
+		a.Recommendations = append(a.Recommendations, Finding{
+			Title:       "pg_hba.conf still uses md5",
+			Severity:    SeverityRec,
+			Code:        "hba-md5-method",
+			Description: fmt.Sprintf("Every password is stored as SCRAM, yet pg_hba.conf authenticates with md5: %s.", listFirst(md5Lines, clientMaxListed, "; ")),
+			Action:      "Change these entries to scram-sha-256 and reload, so a role given an MD5 hash again (e.g. restored from an old dump) cannot log in with it.",
+		})
+	}
+	if len(clearLines) > 0 {
+		a.Warnings = append(a.Warnings, Finding{
+			Title:       "Cleartext password authentication",
+			Severity:    SeverityWarning,
+			Code:        "hba-cleartext-password",
+			Description: fmt.Sprintf("pg_hba.conf authenticates with method password, which sends passwords in clear text, on connections that may not use TLS: %s.", listFirst(clearLines, clientMaxListed, "; ")),
+			Action:      "Change these entries to scram-sha-256, or to hostssl if the method must stay (e.g. for clients without SCRAM support), and reload.",
+		})
+	}
+}
+
+// quoteRole quotes a role name for SQL unless it is a plain lowercase
+// identifier.
+func quoteRole(name string) string {
+	for i, r := range name {
+		if !(r >= 'a' && r <= 'z' || r == '_' || i > 0 && (r >= '0' && r <= '9' || r == '$')) {
+			return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+		}
+	}
+	return name
+}
+
+// classifyWorkload classifies the workload from the top statements by total
+// time; see Workload.
+func classifyWorkload(stmts []collect.Statement) Workload {
+	var w Workload
+	var total, analytical, temp, parallel, rows, calls float64
+	for _, st := range stmts {
+		if st.Calls <= 0 || st.TotalTime <= 0 {
+			continue
+		}
+		w.Statements++
+		total += st.TotalTime
+		rows += st.Rows
+		calls += st.Calls
+		spills := st.TempBlksWrite/st.Calls >= analyticalTempBlksPerCall
+		if spills {
+			temp += st.TotalTime
+		}
+		if st.Advice.Parallel() {
+			parallel += st.TotalTime
+		}
+		parsed := sqlparse.Parse(st.Query)
+		report := parsed.Command == "select" && !parsed.Limit && (parsed.Joins >= analyticalShapeJoins || len(parsed.Filters) == 0) && len(parsed.Tables) > 0
+		if st.MeanTime >= analyticalMeanMs || st.Rows/st.Calls >= analyticalRowsPerCall || spills || st.Advice.Parallel() ||
+			(report && st.MeanTime >= analyticalShapeMs) {
+			analytical += st.TotalTime
+		}
+	}
+	if w.Statements < workloadMinStatements {
+		return Workload{}
+	}
+	w.AnalyticalPct = analytical / total * 100
+	w.TempPct = temp / total * 100
+	w.ParallelPct = parallel / total * 100
+	w.RowsPerCall = rows / calls
+	switch {
+	case w.AnalyticalPct >= workloadAnalyticalPct:
+		w.Class = WorkloadAnalytical
+	case w.AnalyticalPct < workloadOLTPPct:
+		w.Class = WorkloadOLTP
+	default:
+		w.Class = WorkloadMixed
+	}
+	return w
+}
+
+// analyzeWorkload states the workload class and checks the JIT and
+// parallel query settings against it.
+func analyzeWorkload(a *Analysis, setting func(string) (collect.Setting, bool)) {
+	wl := a.Workload
+	if wl.Class == "" {
+		return
+	}
+	a.Infos = append(a.Infos, Finding{
+		Title:    "Workload classification",
+		Severity: SeverityInfo,
+		Code:     "workload-class",
+		Description: fmt.Sprintf("The workload looks %s: %.0f%% of the execution time of the top %d statements goes to analytical queries (long-running, large results, temp spills, parallel plans or reporting shapes); %.0f rows per call, %.0f%% of the time spilling to temp files, %.0f%% in parallel plans. Configuration advice in this report is tailored to it.",
+			wl.Label(), wl.AnalyticalPct, wl.Statements, wl.RowsPerCall, wl.TempPct, wl.ParallelPct),
+		Evidence: &Evidence{Metrics: map[string]float64{
+			"analytical_pct": wl.AnalyticalPct, "rows_per_call": wl.RowsPerCall, "temp_pct": wl.TempPct, "parallel_pct": wl.ParallelPct,
+		}},
+	})
+
+	jit, jitOK := setting("jit")
+	if jitOK {
+		on := jit.Val == "on"
+		switch {
+		case on && wl.Class == WorkloadOLTP:
+			cost := "its threshold"
+			if c, ok := setting("jit_above_cost"); ok {
+				cost = "jit_above_cost = " + c.Val
+			}
+			a.Recommendations = append(a.Recommendations, Finding{
+				Title:       "JIT enabled for an OLTP workload",
+				Severity:    SeverityRec,
+				Code:        "jit-oltp",
+				Description: fmt.Sprintf("jit is on: queries whose estimated cost exceeds %s are compiled first, which adds milliseconds that short OLTP queries with misestimated costs pay without benefit.", cost),
+				Action:      "ALTER SYSTEM SET jit = off; SELECT pg_reload_conf(); and SET jit = on in the sessions running reports, or raise jit_above_cost.",
+				Evidence:    settingsEvidence(nil, "jit", "jit_above_cost"),
+			})
+		case !on && wl.Class == WorkloadAnalytical:
+			a.Recommendations = append(a.Recommendations, Finding{
+				Title:       "JIT disabled for an analytical workload",
+				Severity:    SeverityRec,
+				Code:        "jit-analytical",
+				Description: "jit is off: analytical queries evaluating expressions and aggregates over many rows often run faster compiled.",
+				Action:      "ALTER SYSTEM SET jit = on; SELECT pg_reload_conf(); then compare the JIT timing in EXPLAIN (ANALYZE) of the top queries with their execution time.",
+				Evidence:    settingsEvidence(nil, "jit"),
+			})
+		}
+	}
+
+	gatherS, ok := setting("max_parallel_workers_per_gather")
+	gather, err := strconv.Atoi(gatherS.Val)
+	if !ok || err != nil {
+		return
+	}
+	switch {
+	case wl.Class == WorkloadAnalytical && gather == 0:
+		a.Recommendations = append(a.Recommendations, Finding{
+			Title:       "Parallel query disabled for an analytical workload",
+			Severity:    SeverityRec,
+			Code:        "parallel-analytical",
+			Description: "max_parallel_workers_per_gather = 0: large scans, joins and aggregates of the analytical queries run on a single CPU.",
+			Action:      "Set max_parallel_workers_per_gather to 2-4, within max_parallel_workers and the CPU count, so a query can use several CPUs.",
+			Evidence:    settingsEvidence(map[string]float64{"max_parallel_workers_per_gather": 0}, "max_parallel_workers_per_gather"),
+		})
+	case wl.Class == WorkloadOLTP && gather > oltpMaxGather:
+		a.Recommendations = append(a.Recommendations, Finding{
+			Title:       "Parallel query tuned beyond an OLTP workload",
+			Severity:    SeverityRec,
+			Code:        "parallel-oltp",
+			Description: fmt.Sprintf("max_parallel_workers_per_gather = %d lets one query take that many extra workers, and their CPUs, from concurrent OLTP sessions; %.0f%% of the top statements' time runs parallel plans.", gather, wl.ParallelPct),
+			Action:      fmt.Sprintf("Lower max_parallel_workers_per_gather to %d or less, and raise it per session for reports.", oltpMaxGather),
+			Evidence:    settingsEvidence(map[string]float64{"max_parallel_workers_per_gather": float64(gather), "parallel_pct": wl.ParallelPct}, "max_parallel_workers_per_gather"),
+		})
+	}
+}
+
+// formatSize renders a byte count in MB or, from a gigabyte, GB.
+func formatSize(b float64) string {
+	if math.Abs(b) >= 1<<30 {
+		return fmt.Sprintf("%.1f GB", b/(1<<30))
+	}
+	return fmt.Sprintf("%.1f MB", b/(1<<20))
+}
+
+// knownPoolers are connection poolers recognizable by the application_name or
+// user of their server connections.
+var knownPoolers = []string{"pgbouncer", "odyssey", "pgcat", "pgpool", "supavisor", "pgdog"}
+
+// pooler is the connection pooler the server's clients seem to go through.
+type pooler struct {
+	name     string // known pooler, or empty when recognized by shape only
+	evidence string // why the connections look pooled; empty when not
+}
+
+func (p pooler) found() bool { return p.evidence != "" }
+
+// advice returns generic when no pooler was detected, else the advice for
+// the pooler in place, so already pooled setups are not told to add one.
+func (p pooler) advice(generic, pooled string) string {
+	if !p.found() {
+		return generic
+	}
+	return fmt.Sprintf("Connections already come through %s: %s", p.label(), pooled)
+}
+
+// label names the pooler for advice.
+func (p pooler) label() string {
+	if p.name == "" {
+		return "a connection pool"
+	}
+	return p.name
+}
+
+// detectPooler fingerprints the client backends: the application_name or
+// user of a known pooler, or the shape of pooled server connections, most
+// backends coming from a few hosts and staying connected for a long time.
+// An application-side pool (HikariCP, SQLAlchemy) has the same shape.
+func detectPooler(res collect.Result) pooler {
+	for _, c := range res.ConnectionsByClient {
+		for _, name := range knownPoolers {
+			if strings.Contains(strings.ToLower(c.Application), name) || strings.EqualFold(c.User, name) {
+				return pooler{name: name, evidence: fmt.Sprintf("%d backend(s) from %s identify as %s (application %q, user %s).", c.Count, c.Address, name, c.Application, c.User)}
+			}
+		}
+	}
+
+	hosts := map[string]*collect.ClientConn{}
+	total := 0
+	for _, c := range res.ConnectionsByClient {
+		if c.Address == "local" {
+			continue
+		}
+		h, ok := hosts[c.Address]
+		if !ok {
+			h = &collect.ClientConn{Address: c.Address}
+			hosts[c.Address] = h
+		}
+		h.AvgAgeSeconds = (h.AvgAgeSeconds*float64(h.Count) + c.AvgAgeSeconds*float64(c.Count)) / float64(h.Count+c.Count)
+		h.Count += c.Count
+		h.Idle += c.Idle
+		total += c.Count
+	}
+	if total < poolerMinBackends {
+		return pooler{}
+	}
+	top := make([]*collect.ClientConn, 0, len(hosts))
+	for _, h := range hosts {
+		top = append(top, h)
+	}
+	sort.Slice(top, func(i, j int) bool {
+		if top[i].Count != top[j].Count {
+			return top[i].Count > top[j].Count
+		}
+		return top[i].Address < top[j].Address
+	})
+	top = top[:min(len(top), poolerMaxHosts)]
+	held, idle := 0, 0
+	var age float64
+	var names []string
+	for _, h := range top {
+		held += h.Count
+		idle += h.Idle
+		age += h.AvgAgeSeconds * float64(h.Count)
+		names = append(names, h.Address)
+	}
+	age /= float64(held)
+	if float64(held) < float64(total)*poolerHostShare || time.Duration(age)*time.Second < poolerMinAge {
+		return pooler{}
+	}
+	return pooler{evidence: fmt.Sprintf("%d of %d client backend(s) come from %s and have been connected for %s on average (%d idle): the shape of a pooler's or an application pool's server connections.",
+		held, total, strings.Join(names, ", "), humanizeDuration(time.Duration(age)*time.Second), idle)}
+}
+
+// valueOrCurrent returns db, or current when db is empty.
+func valueOrCurrent(db, current string) string {
+	if db == "" {
+		return current
+	}
+	return db
+}
+
+// autoExplainAdvice is the auto_explain configuration fitted to a workload.
+type autoExplainAdvice struct {
+	minDurationMs float64 // log_min_duration
+	sampleRate    float64
+	slowPerHour   float64 // executions per hour expected above minDurationMs
+	slow          int     // top statements whose p99 reaches minDurationMs
+}
+
+// adviseAutoExplain fits log_min_duration to the slowest percent of the top
+// statements' executions (calls-weighted p99 latencies, rounded up to a round
+// value) and samples the plans down to autoExplainPlansPerHour.
+func adviseAutoExplain(sts collect.Statements) (autoExplainAdvice, bool) {
+	var stmts []collect.Statement
+	seen := map[string]bool{}
+	for _, list := range [][]collect.Statement{sts.TopByTotalTime, sts.TopByCalls} {
+		for _, st := range list {
+			if !seen[st.Key()] && st.Calls > 0 {
+				seen[st.Key()] = true
+				stmts = append(stmts, st)
+			}
+		}
+	}
+	sort.Slice(stmts, func(i, j int) bool { return stmts[i].LatencyP99() < stmts[j].LatencyP99() })
+	var calls, acc float64
+	for _, st := range stmts {
+		calls += st.Calls
+	}
+	var ad autoExplainAdvice
+	for _, st := range stmts {
+		acc += st.Calls
+		if acc >= calls*0.99 {
+			ad.minDurationMs = roundUpMs(max(st.LatencyP99(), autoExplainMinMs))
+			break
+		}
+	}
+	for _, st := range stmts {
+		switch {
+		case st.MeanTime >= ad.minDurationMs:
+			ad.slowPerHour += st.CallsPerHour
+		case st.LatencyP95() >= ad.minDurationMs:
+			ad.slowPerHour += st.CallsPerHour * 0.05
+		case st.LatencyP99() >= ad.minDurationMs:
+			ad.slowPerHour += st.CallsPerHour * 0.01
+		default:
+			continue
+		}
+		ad.slow++
+	}
+	if ad.slow == 0 {
+		return ad, false
+	}
+	ad.sampleRate = 1
+	if ad.slowPerHour > autoExplainPlansPerHour {
+		ad.sampleRate = max(0.01, float64(int(autoExplainPlansPerHour/ad.slowPerHour*100))/100)
+	}
+	return ad, true
+}
+
+// roundUpMs rounds a duration in milliseconds up to 1, 2 or 5 times a power of ten.
+func roundUpMs(ms float64) float64 {
+	for p := 1.0; ; p *= 10 {
+		for _, m := range []float64{1, 2, 5} {
+			if m*p >= ms {
+				return m * p
+			}
+		}
+	}
+}
+
+// analyzeAutoExplain recommends auto_explain settings fitted to the observed
+// latencies when top statements have slow executions: loading the module
+// when it is missing, enabling it when log_min_duration is -1, and raising a
+// threshold that logs far more plans than needed. log_analyze is advised
+// with log_timing off, as per-node timing is costly on slow clocks.
+func analyzeAutoExplain(a *Analysis, settings []collect.Setting, sts collect.Statements) {
+	ad, ok := adviseAutoExplain(sts)
+	if !ok {
+		return
+	}
+	setting := func(name string) (collect.Setting, bool) {
+		for _, s := range settings {
+			if s.Name == name {
+				return s, true
+			}
+		}
+		return collect.Setting{}, false
+	}
+	preload, _ := setting("shared_preload_libraries")
+	sessionPreload, _ := setting("session_preload_libraries")
+	minDuration, loaded := setting("auto_explain.log_min_duration")
+	loaded = loaded || hasLibrary(preload.Val, "auto_explain") || hasLibrary(sessionPreload.Val, "auto_explain")
+
+	configure := fmt.Sprintf("ALTER SYSTEM SET auto_explain.log_min_duration = '%gms'; ALTER SYSTEM SET auto_explain.sample_rate = %g; "+
+		"ALTER SYSTEM SET auto_explain.log_analyze = on; ALTER SYSTEM SET auto_explain.log_timing = off; ALTER SYSTEM SET auto_explain.log_buffers = on; SELECT pg_reload_conf();",
+		ad.minDurationMs, ad.sampleRate)
+	caveat := " log_analyze instruments every sampled statement, not only the slow ones, so keep sample_rate below 1 on busy servers; log_timing = off avoids per-node clock reads (check their cost with pg_test_timing)."
+	observed := fmt.Sprintf("%d top statement(s) have executions of %s or more (p99), about %s per hour", ad.slow, humanizeMs(ad.minDurationMs), formatThousands0(ad.slowPerHour))
+	if ad.sampleRate < 1 {
+		observed += fmt.Sprintf("; a sample rate of %g keeps plan logging near %s per hour", ad.sampleRate, formatThousands0(autoExplainPlansPerHour))
+	}
+
+	metrics := map[string]float64{"log_min_duration_ms": ad.minDurationMs, "sample_rate": ad.sampleRate, "slow_per_hour": ad.slowPerHour}
+	if !loaded {
+		libs := "auto_explain"
+		if v := strings.TrimSpace(preload.Val); v != "" {
+			libs = v + ",auto_explain"
+		}
+		a.Recommendations = append(a.Recommendations, Finding{
+			Title:       "Enable auto_explain for slow query plans",
+			Severity:    SeverityRec,
+			Code:        "auto-explain",
+			Description: "auto_explain is not loaded, so the plans of slow executions are lost. " + observed + ".",
+			Action:      fmt.Sprintf("ALTER SYSTEM SET shared_preload_libraries = '%s'; then restart (or add it to session_preload_libraries to cover new sessions without a restart), and %s", libs, configure) + caveat,
+			Evidence:    settingsEvidence(metrics, "shared_preload_libraries"),
+		})
+		return
+	}
+	ms, err := strconv.ParseFloat(minDuration.Val, 64)
+	if err != nil {
+		return
+	}
+	if ms < 0 {
+		a.Recommendations = append(a.Recommendations, Finding{
+			Title:       "auto_explain is loaded but disabled",
+			Severity:    SeverityRec,
+			Code:        "auto-explain",
+			Description: "auto_explain.log_min_duration is -1, so no plans are logged. " + observed + ".",
+			Action:      configure + caveat,
+			Evidence:    settingsEvidence(metrics, "auto_explain.log_min_duration"),
+		})
+		return
+	}
+	rate := 1.0
+	if s, ok := setting("auto_explain.sample_rate"); ok {
+		if v, err := strconv.ParseFloat(s.Val, 64); err == nil {
+			rate = v
+		}
+	}
+	if ms*autoExplainNoisyFactor <= ad.minDurationMs && rate > ad.sampleRate {
+		a.Recommendations = append(a.Recommendations, Finding{
+			Title:       "auto_explain logs too many plans",
+			Severity:    SeverityRec,
+			Code:        "auto-explain-noisy",
+			Description: fmt.Sprintf("auto_explain.log_min_duration is %s with a sample rate of %g, far below the slowest executions of the workload: plan logging inflates the logs and costs CPU. %s.", humanizeMs(ms), rate, observed),
+			Action:      configure + caveat,
+			Evidence:    settingsEvidence(metrics, "auto_explain.log_min_duration", "auto_explain.sample_rate"),
+		})
+	}
+	analyzeOn, _ := setting("auto_explain.log_analyze")
+	timingOn, _ := setting("auto_explain.log_timing")
+	if analyzeOn.Val == "on" && timingOn.Val == "on" && rate >= 1 {
+		a.Recommendations = append(a.Recommendations, Finding{
+			Title:       "auto_explain times every plan node of every statement",
+			Severity:    SeverityRec,
+			Code:        "auto-explain-timing",
+			Evidence:    settingsEvidence(nil, "auto_explain.log_analyze", "auto_explain.log_timing", "auto_explain.sample_rate"),
+			Description: "auto_explain.log_analyze and log_timing are on with a sample rate of 1: every statement is instrumented with per-node timing, whether or not it ends up logged.",
+			Action:      fmt.Sprintf("ALTER SYSTEM SET auto_explain.log_timing = off; ALTER SYSTEM SET auto_explain.sample_rate = %g; SELECT pg_reload_conf(); row counts and buffers stay in the plans, only node timings are dropped.", min(ad.sampleRate, 0.1)),
+		})
+	}
+}
+
+// hasLibrary reports whether a preload library list such as
+// "pg_stat_statements, auto_explain" names lib.
+func hasLibrary(list, lib string) bool {
+	for _, l := range strings.Split(list, ",") {
+		if strings.Trim(strings.TrimSpace(l), `"`) == lib {
+			return true
+		}
+	}
+	return false
+}
+
+// pgauditClasses are the statement classes of pgaudit.log, in the order of
+// the pgaudit documentation.
+var pgauditClasses = []string{"read", "write", "function", "role", "ddl", "misc", "misc_set"}
+
+// pgauditRequired are the classes a compliance audit trail needs at least:
+// schema changes and privilege changes.
+var pgauditRequired = []string{"ddl", "role"}
+
+// parsePgAuditLog returns the classes a pgaudit.log value enables: a comma
+// separated list where "all" enables every class, "none" none, and a "-"
+// prefix removes a class.
+func parsePgAuditLog(val string) map[string]bool {
+	on := map[string]bool{}
+	for _, c := range strings.Split(strings.ToLower(val), ",") {
+		c = strings.TrimSpace(c)
+		c, off := strings.CutPrefix(c, "-")
+		switch {
+		case c == "all":
+			for _, k := range pgauditClasses {
+				on[k] = !off
+			}
+		case c == "none":
+			clear(on)
+		case slices.Contains(pgauditClasses, c):
+			on[c] = !off
+		}
+	}
+	for k, v := range on {
+		if !v {
+			delete(on, k)
+		}
+	}
+	return on
+}
+
+// analyzePgAudit reports what pgaudit audits when it is installed or loaded:
+// a library that is not loaded audits nothing, pgaudit.log = none without an
+// object audit role neither, and a log missing DDL or role changes leaves a
+// gap in a compliance audit trail. Without pgaudit nothing is reported, as
+// whether the environment needs an audit trail is not known.
+func analyzePgAudit(a *Analysis, settings []collect.Setting, exts []collect.ExtensionStat) {
+	setting := func(name string) (collect.Setting, bool) {
+		for _, s := range settings {
+			if s.Name == name {
+				return s, true
+			}
+		}
+		return collect.Setting{}, false
+	}
+	var dbs []string
+	for _, e := range exts {
+		if e.Name == "pgaudit" && !slices.Contains(dbs, e.Database) {
+			dbs = append(dbs, e.Database)
+		}
+	}
+	preload, _ := setting("shared_preload_libraries")
+	loaded := hasLibrary(preload.Val, "pgaudit")
+	if !loaded && len(dbs) == 0 {
+		return
+	}
+	if !loaded {
+		a.Warnings = append(a.Warnings, Finding{
+			Title:       "pgaudit not loaded",
+			Severity:    SeverityWarning,
+			Code:        "pgaudit-not-loaded",
+			Description: fmt.Sprintf("The pgaudit extension is created in %s, but the library is not in shared_preload_libraries, so no statement is audited.", listFirst(dbs, 5, ", ")),
+			Action:      "Add pgaudit to shared_preload_libraries (keeping the libraries already listed) and restart, then set pgaudit.log to the classes to audit.",
+			Evidence:    settingsEvidence(nil, "shared_preload_libraries"),
+		})
+		return
+	}
+
+	logSetting, _ := setting("pgaudit.log")
+	role, _ := setting("pgaudit.role")
+	classes := parsePgAuditLog(logSetting.Val)
+	var audited, skipped []string
+	for _, c := range pgauditClasses {
+		if classes[c] {
+			audited = append(audited, strings.ToUpper(c))
+		} else {
+			skipped = append(skipped, strings.ToUpper(c))
+		}
+	}
+	if len(audited) == 0 && role.Val == "" {
+		a.Warnings = append(a.Warnings, Finding{
+			Title:       "pgaudit audits nothing",
+			Severity:    SeverityWarning,
+			Code:        "pgaudit-inactive",
+			Description: "pgaudit is loaded, but pgaudit.log is none and no pgaudit.role is set for object audit logging, so no statement is audited.",
+			Action:      "ALTER SYSTEM SET pgaudit.log = 'ddl, role'; SELECT pg_reload_conf(); add write (and read) where changes to the data must be traced too.",
+			Evidence:    settingsEvidence(nil, "pgaudit.log", "pgaudit.role"),
+		})
+		return
+	}
+
+	desc := "pgaudit session logging audits " + strings.Join(audited, ", ")
+	if len(audited) == 0 {
+		desc = "pgaudit session logging is off"
+	}
+	if len(skipped) > 0 && len(audited) > 0 {
+		desc += "; not audited: " + strings.Join(skipped, ", ")
+	}
+	desc += "."
+	if role.Val != "" {
+		desc += fmt.Sprintf(" Object audit logging covers the relations granted to role %s.", role.Val)
+	}
+	if len(dbs) == 0 {
+		desc += " The extension is not created in the databases checked; CREATE EXTENSION pgaudit is needed for DDL to be logged with its object type and name."
+	}
+	a.Infos = append(a.Infos, Finding{
+		Title:       "pgaudit coverage",
+		Severity:    SeverityInfo,
+		Code:        "pgaudit-coverage",
+		Description: desc,
+		Evidence:    settingsEvidence(nil, "pgaudit.log", "pgaudit.role"),
+	})
+
+	var missing, upper []string
+	for _, c := range pgauditRequired {
+		if !classes[c] {
+			missing = append(missing, c)
+			upper = append(upper, strings.ToUpper(c))
 		}
-		a.Warnings = append(a.Warnings, Finding{
-			Title:       "Invalid indexes found",
-			Severity:    SeverityWarning,
-			Code:        "invalid-indexes",
-			Description: fmt.Sprintf("%d invalid indexes wasting %.2f GB and not providing any benefit: %s", len(res.InvalidIndexes), bytesToGB(totalSize), strings.Join(names, ", ")),
-			Action:      "Drop invalid indexes with DROP INDEX and recreate with CREATE INDEX CONCURRENTLY. Investigate why they failed (disk space, locks, errors).",
+	}
+	if len(missing) == 0 {
+		return
+	}
+	want := strings.TrimSpace(logSetting.Val)
+	if want == "" || strings.EqualFold(want, "none") {
+		want = strings.Join(missing, ", ")
+	} else {
+		want += ", " + strings.Join(missing, ", ")
+	}
+	stmt := fmt.Sprintf("ALTER SYSTEM SET pgaudit.log = '%s';", want)
+	a.Tuning = append(a.Tuning, stmt, "SELECT pg_reload_conf();")
+	a.Recommendations = append(a.Recommendations, Finding{
+		Title:       "pgaudit compliance gaps",
+		Severity:    SeverityRec,
+		Code:        "pgaudit-gaps",
+		Description: fmt.Sprintf("pgaudit does not audit %s statements: schema changes and privilege grants leave no trace, which most audit regimes (PCI DSS, SOC 2, HIPAA) require.", strings.Join(upper, " and ")),
+		Action:      stmt + " SELECT pg_reload_conf(); object audit logging (pgaudit.role) does not cover these classes.",
+		Evidence:    settingsEvidence(nil, "pgaudit.log"),
+	})
+}
+
+// analyzeTemplates flags user objects and extensions in template1, which
+// every new database copies, ALTER DEFAULT PRIVILEGES entries granting new
+// tables or sequences to PUBLIC, and databases whose encoding or locale
+// differs from template1, the default of new databases.
+func analyzeTemplates(a *Analysis, res collect.Result) {
+	if t := res.Template1; t != nil && len(t.Objects)+len(t.Extensions) > 0 {
+		var parts []string
+		if len(t.Objects) > 0 {
+			parts = append(parts, fmt.Sprintf("%d objects (%s)", len(t.Objects), listFirst(t.Objects, clientMaxListed, ", ")))
+		}
+		if len(t.Extensions) > 0 {
+			parts = append(parts, fmt.Sprintf("extensions %s", strings.Join(t.Extensions, ", ")))
+		}
+		a.Recommendations = append(a.Recommendations, Finding{
+			Title:       "Objects in template1",
+			Severity:    SeverityRec,
+			Code:        "template1-objects",
+			Description: "template1 holds " + strings.Join(parts, " and ") + "; every database created afterwards gets a copy, often by accident (e.g. a script run while connected to template1).",
+			Action:      "Unless they are meant for every new database, drop them from template1 (connect with psql -d template1) and create extensions per database. Databases created from template1 since keep their copies.",
+			Evidence:    &Evidence{Objects: []Object{{Kind: "database", Name: "template1"}}},
 		})
 	}
 
-	// 6. Foreign Key Missing Indexes Analysis
-	if len(res.FKMissingIndexes) > 0 {
-		// Prioritize by table size (rows)
-		count := len(res.FKMissingIndexes)
-		fks := make([]string, 0, 5)
-		for i, fk := range res.FKMissingIndexes {
-			if i >= 5 {
-				break
-			}
-			fks = append(fks, fmt.Sprintf("%s.%s(%s)", fk.Schema, fk.Table, fk.Columns))
+	var public []string
+	ev := &Evidence{}
+	for _, d := range res.DefaultPrivileges {
+		if !d.Public() || d.ObjectType == "functions" || d.ObjectType == "types" {
+			continue // functions and types are granted to PUBLIC by default anyway
 		}
-		desc := fmt.Sprintf("%d foreign keys lack supporting indexes, causing slow JOINs and cascading deletes: %s", count, strings.Join(fks, ", "))
-		if count > 5 {
-			desc += fmt.Sprintf(" and %d more", count-5)
+		schema := d.Schema
+		if schema == "" {
+			schema = "all schemas"
 		}
+		public = append(public, fmt.Sprintf("%s created by %s in %s", d.ObjectType, d.Role, schema))
+		ev.Objects = append(ev.Objects, Object{Kind: "role", Name: d.Role})
+	}
+	if len(public) > 0 {
 		a.Recommendations = append(a.Recommendations, Finding{
-			Title:       "Foreign keys without indexes",
+			Title:       "Default privileges grant to PUBLIC",
 			Severity:    SeverityRec,
-			Code:        "fk-missing-index",
-			Description: desc,
-			Action:      "Create indexes on FK columns. Example: CREATE INDEX CONCURRENTLY ON table(fk_column). Review 'FK Missing Indexes' table for suggested DDL.",
+			Code:        "default-privileges-public",
+			Description: fmt.Sprintf("ALTER DEFAULT PRIVILEGES makes new objects readable by every role: %s.", listFirst(public, clientMaxListed, "; ")),
+			Action:      "Grant to a group role instead (ALTER DEFAULT PRIVILEGES FOR ROLE ... GRANT ... TO readers) and revoke the PUBLIC entries (ALTER DEFAULT PRIVILEGES FOR ROLE ... REVOKE ... FROM PUBLIC); objects created so far keep their grants.",
+			Evidence:    ev,
 		})
 	}
 
-	// 7. Sequence Exhaustion Analysis
-	if len(res.SequenceHealth) > 0 {
-		criticalSeqs := []string{}
-		warningSeqs := []string{}
-		for _, sq := range res.SequenceHealth {
-			if sq.PctUsed >= sequenceCriticalPct {
-				criticalSeqs = append(criticalSeqs, fmt.Sprintf("%s.%s (%.1f%%)", sq.Schema, sq.Name, sq.PctUsed))
-			} else if sq.PctUsed >= sequenceWarningPct {
-				warningSeqs = append(warningSeqs, fmt.Sprintf("%s.%s (%.1f%%)", sq.Schema, sq.Name, sq.PctUsed))
+	if t := res.Template1; t != nil {
+		var differ []string
+		ev := &Evidence{}
+		for _, db := range res.DBs {
+			if db.Encoding == "" {
+				continue
+			}
+			var diffs []string
+			if db.Encoding != t.Encoding {
+				diffs = append(diffs, "encoding "+db.Encoding)
+			}
+			if db.Collate != t.Collate {
+				diffs = append(diffs, "collation "+db.Collate)
+			}
+			if db.Ctype != t.Ctype {
+				diffs = append(diffs, "ctype "+db.Ctype)
+			}
+			if len(diffs) > 0 {
+				differ = append(differ, fmt.Sprintf("%s (%s)", db.Name, strings.Join(diffs, ", ")))
+				ev.Objects = append(ev.Objects, Object{Kind: "database", Name: db.Name})
 			}
 		}
-		if len(criticalSeqs) > 0 {
-			a.Warnings = append(a.Warnings, Finding{
-				Title:       "Sequences near exhaustion",
-				Severity:    SeverityWarning,
-				Code:        "sequence-exhaustion-critical",
-				Description: fmt.Sprintf("Sequences >%d%% exhausted will cause INSERT failures: %s", int(sequenceCriticalPct), strings.Join(criticalSeqs, ", ")),
-				Action:      "Alter sequences to use bigint (ALTER SEQUENCE ... AS bigint) or reset with appropriate min/max values. Plan migration before exhaustion.",
-			})
-		}
-		if len(warningSeqs) > 0 {
+		if len(differ) > 0 {
 			a.Recommendations = append(a.Recommendations, Finding{
-				Title:       "Sequences approaching exhaustion",
+				Title:       "Databases with a non-default locale",
 				Severity:    SeverityRec,
-				Code:        "sequence-exhaustion-warning",
-				Description: fmt.Sprintf("Sequences >%d%% used: %s", int(sequenceWarningPct), strings.Join(warningSeqs, ", ")),
-				Action:      "Monitor sequence usage. Plan to convert to bigint before reaching limit.",
+				Code:        "database-locale-mismatch",
+				Description: fmt.Sprintf("%s differ from the cluster default (template1: encoding %s, collation %s, ctype %s).", listFirst(differ, clientMaxListed, "; "), t.Encoding, t.Collate, t.Ctype),
+				Action:      "Check these were chosen on purpose: text sorts and compares differently across them, so moving data or queries between databases changes ORDER BY results and which indexes serve LIKE. Changing a database's locale means dumping and restoring it into a database created with the right one.",
+				Evidence:    ev,
 			})
 		}
 	}
+}
 
-	// 8. Prepared Transactions (2PC) Analysis
-	if len(res.PreparedXacts) > 0 {
+// analyzeDatabaseLocales warns about SQL_ASCII databases, which store bytes
+// without validating their encoding, and flags applications connected to
+// databases with different collations, where the same query sorts and
+// compares text differently depending on the database. Interactive clients
+// and poolers connect everywhere and are left out.
+func analyzeDatabaseLocales(a *Analysis, dbs []collect.Database) {
+	var ascii []string
+	asciiEv := &Evidence{}
+	collations := map[string]map[string][]string{} // application → collation → databases
+	var apps []string
+	skip := append(slices.Clip(interactiveClients), knownPoolers...)
+	for _, db := range dbs {
+		if db.Encoding == "SQL_ASCII" {
+			ascii = append(ascii, db.Name)
+			asciiEv.Objects = append(asciiEv.Objects, Object{Kind: "database", Name: db.Name})
+		}
+		if db.Encoding == "" {
+			continue
+		}
+		for _, app := range db.Applications {
+			lower := strings.ToLower(app)
+			if slices.ContainsFunc(skip, func(name string) bool { return strings.Contains(lower, name) }) {
+				continue
+			}
+			if collations[app] == nil {
+				collations[app] = map[string][]string{}
+				apps = append(apps, app)
+			}
+			collations[app][db.Collation()] = append(collations[app][db.Collation()], db.Name)
+		}
+	}
+	if len(ascii) > 0 {
 		a.Warnings = append(a.Warnings, Finding{
-			Title:       "Prepared transactions detected",
+			Title:       "SQL_ASCII databases",
 			Severity:    SeverityWarning,
-			Code:        "prepared-transactions",
-			Description: fmt.Sprintf("%d prepared (2PC) transactions found. These block vacuum, prevent XID advancement, and hold locks indefinitely until committed or rolled back.", len(res.PreparedXacts)),
-			Action:      "Investigate orphaned transactions with pg_prepared_xacts. Commit with COMMIT PREPARED 'gid' or rollback with ROLLBACK PREPARED 'gid'. Consider disabling max_prepared_transactions if not using 2PC.",
+			Code:        "sql-ascii-database",
+			Description: fmt.Sprintf("%s use the SQL_ASCII encoding: text is stored as raw bytes without validation, so clients writing different encodings mix them undetectably, and upper(), lower() and collations treat non-ASCII characters as opaque bytes.", strings.Join(ascii, ", ")),
+			Action:      "Migrate to UTF8: find the encodings actually stored (e.g. rows failing convert_from(convert_to(col, 'SQL_ASCII'), 'UTF8')), fix them, then pg_dump and restore into a database created with ENCODING 'UTF8' (and TEMPLATE template0).",
+			Evidence:    asciiEv,
 		})
 	}
 
-	return a
+	var mixed []string
+	mixedEv := &Evidence{}
+	for _, app := range apps {
+		byColl := collations[app]
+		if len(byColl) < 2 {
+			continue
+		}
+		colls := make([]string, 0, len(byColl))
+		for c := range byColl {
+			colls = append(colls, c)
+		}
+		sort.Strings(colls)
+		var parts []string
+		for _, c := range colls {
+			parts = append(parts, fmt.Sprintf("%s (%s)", strings.Join(byColl[c], ", "), c))
+		}
+		mixed = append(mixed, fmt.Sprintf("%s uses %s", app, strings.Join(parts, " and ")))
+		mixedEv.Objects = append(mixedEv.Objects, Object{Kind: "application", Name: app})
+	}
+	if len(mixed) > 0 {
+		a.Recommendations = append(a.Recommendations, Finding{
+			Title:       "Applications spanning collations",
+			Severity:    SeverityRec,
+			Code:        "mixed-collations",
+			Description: "Applications connect to databases with different collations: " + listFirst(mixed, clientMaxListed, "; ") + ". The same ORDER BY, comparison or unique constraint on text can give different results in each.",
+			Action:      "Align the collations when the databases hold the same kind of data (recreate with the matching LC_COLLATE or ICU locale and restore), or pin the collation in the queries that must agree (ORDER BY name COLLATE \"C\").",
+			Evidence:    mixedEv,
+		})
+	}
+}
+
+// analyzeStandbyConflicts reports queries cancelled on a standby by recovery
+// conflicts, one finding per conflict type, with actions that depend on the
+// standby's max_standby_*_delay and hot_standby_feedback settings.
+func analyzeStandbyConflicts(a *Analysis, sc collect.StandbyConflicts) {
+	var total collect.DatabaseConflicts
+	for _, d := range sc.Databases {
+		total.Tablespace += d.Tablespace
+		total.Lock += d.Lock
+		total.Snapshot += d.Snapshot
+		total.BufferPin += d.BufferPin
+		total.Deadlock += d.Deadlock
+	}
+	delaySetting, hasDelay := sc.Setting("max_standby_streaming_delay")
+	delay := asSeconds(delaySetting, hasDelay)
+	feedback, _ := sc.Setting("hot_standby_feedback")
+
+	if hasDelay && delay < 0 {
+		a.Infos = append(a.Infos, Finding{
+			Title:       "Standby never cancels conflicting queries",
+			Severity:    SeverityInfo,
+			Code:        "standby-delay-unlimited",
+			Description: fmt.Sprintf("max_standby_streaming_delay = -1 on %s: long queries are never cancelled but replay (and so replication lag) can stall for as long as they run.", sc.Host),
+			Action:      "Keep if the standby serves long reports and lag is acceptable; otherwise set a bounded delay (e.g. 30s-5min).",
+		})
+	}
+	if total.Total() == 0 {
+		if len(sc.Databases) > 0 {
+			a.Infos = append(a.Infos, Finding{
+				Title:       "No standby query cancellations",
+				Severity:    SeverityInfo,
+				Description: fmt.Sprintf("No queries were cancelled by recovery conflicts on %s since the statistics reset.", sc.Host),
+			})
+		}
+		return
+	}
+
+	delayText := "max_standby_streaming_delay"
+	if hasDelay {
+		delayText = fmt.Sprintf("max_standby_streaming_delay (now %s%s)", delaySetting.Val, delaySetting.Unit)
+	}
+	conflicts := func(n int64) *Evidence {
+		return &Evidence{Objects: []Object{{Kind: "standby", Name: sc.Host}}, Metrics: map[string]float64{"cancelled": float64(n)}}
+	}
+	if total.Snapshot > 0 {
+		action := "Enable hot_standby_feedback so the primary keeps rows standby queries still need (at the cost of some bloat on the primary), or raise " + delayText + "."
+		if feedback.Val == "on" {
+			action = "hot_standby_feedback is already on: conflicts remain when the feedback is interrupted (standby restarts, slot-less reconnects). Raise " + delayText + " or use a replication slot."
+		}
+		a.Recommendations = append(a.Recommendations, Finding{
+			Title:       "Standby queries cancelled by vacuum cleanup",
+			Severity:    SeverityRec,
+			Code:        "standby-conflict-snapshot",
+			Description: fmt.Sprintf("%s standby queries were cancelled because vacuum on the primary removed rows they could still see (snapshot conflicts).", formatThousands0(float64(total.Snapshot))),
+			Action:      action,
+			Evidence:    conflicts(total.Snapshot),
+		})
+	}
+	if total.Lock > 0 {
+		a.Recommendations = append(a.Recommendations, Finding{
+			Title:       "Standby queries cancelled by lock conflicts",
+			Severity:    SeverityRec,
+			Code:        "standby-conflict-lock",
+			Description: fmt.Sprintf("%s standby queries were cancelled while replaying ACCESS EXCLUSIVE locks from the primary (DDL, LOCK TABLE, or vacuum truncating empty pages).", formatThousands0(float64(total.Lock))),
+			Action:      "Schedule DDL outside standby peak hours; for frequently truncated tables set ALTER TABLE ... SET (vacuum_truncate = off) (PostgreSQL 12+); or raise " + delayText + ".",
+			Evidence:    conflicts(total.Lock),
+		})
+	}
+	if total.BufferPin > 0 || total.Deadlock > 0 {
+		a.Recommendations = append(a.Recommendations, Finding{
+			Title:       "Standby queries cancelled by buffer pins or deadlocks",
+			Severity:    SeverityRec,
+			Code:        "standby-conflict-bufferpin",
+			Description: fmt.Sprintf("%s queries cancelled by buffer pin conflicts and %s by deadlocks with recovery.", formatThousands0(float64(total.BufferPin)), formatThousands0(float64(total.Deadlock))),
+			Action:      "Keep standby queries short or raise " + delayText + "; long cursors holding pins on hot pages are the usual cause.",
+			Evidence:    conflicts(total.BufferPin + total.Deadlock),
+		})
+	}
+	if total.Tablespace > 0 {
+		a.Infos = append(a.Infos, Finding{
+			Title:       "Standby queries cancelled by dropped tablespaces",
+			Severity:    SeverityInfo,
+			Code:        "standby-conflict-tablespace",
+			Description: fmt.Sprintf("%s queries were cancelled because a tablespace they used for temporary files was dropped on the primary.", formatThousands0(float64(total.Tablespace))),
+			Evidence:    conflicts(total.Tablespace),
+		})
+	}
 }
 
 func asBytes(s collect.Setting, ok bool) (int64, bool) {
@@ -1471,3 +5943,584 @@ func humanizeMs(ms float64) string {
 	d := time.Duration(ms * float64(time.Millisecond))
 	return humanizeDuration(d)
 }
+
+// Data quality thresholds behind Finding.Confidence and size and load
+// thresholds behind Finding.EstimatedImpact.
+const (
+	// confidenceMinStatsAge is the time cumulative statistics must cover
+	// before their rates and ratios are representative; below
+	// confidenceLowStatsAge they are mostly noise.
+	confidenceMinStatsAge = 24 * time.Hour
+	confidenceLowStatsAge = time.Hour
+
+	// confidenceMinCalls is the number of calls below which the timings of
+	// the statements behind a finding are a small sample.
+	confidenceMinCalls = 100
+
+	// impactHighShare and impactMediumShare grade the share of the top
+	// statements' execution time involved in a finding.
+	impactHighShare   = 0.20
+	impactMediumShare = 0.05
+
+	// impactHighBytes and impactMediumBytes grade the size of the largest
+	// table or index involved in a finding.
+	impactHighBytes   = 10 << 30
+	impactMediumBytes = 1 << 30
+)
+
+// rateFindings sets the confidence and estimated impact of findings from the
+// quality of the data they rest on: statement findings on the age of
+// pg_stat_statements, their calls and whether EXPLAIN plans were collected;
+// table and index findings on the age of the database statistics and the
+// freshness of the planner statistics of the tables. Findings on settings
+// and other state read as is keep high confidence.
+func rateFindings(list []Finding, res collect.Result, o options) {
+	stmts := map[int64]collect.Statement{}
+	var loadTotal float64
+	for _, st := range res.Statements.TopByTotalTime {
+		loadTotal += st.TotalTime
+	}
+	for _, st := range res.Statements.Unique() {
+		if st.QueryID != 0 {
+			stmts[st.QueryID] = st
+		}
+	}
+	stale := map[string]collect.StaleStatsTable{}
+	for _, t := range res.StaleStatsTables {
+		stale[t.Schema+"."+t.Table] = t
+	}
+	// statsAge is the time the counters of a database cover, false when unknown
+	statsAge := func(db string) (time.Duration, bool) {
+		if o.deterministic {
+			return 0, false
+		}
+		if db == "" {
+			db = res.ConnInfo.CurrentDB
+		}
+		for _, s := range res.SessionStats {
+			if s.Datname == db && s.WindowSeconds > 0 {
+				return time.Duration(s.WindowSeconds * float64(time.Second)), true
+			}
+		}
+		return 0, false
+	}
+	size := func(obj Object) int64 {
+		if b, ok := obj.Metrics["size_bytes"]; ok {
+			return int64(b)
+		}
+		switch obj.Kind {
+		case "table":
+			for _, t := range res.Tables {
+				if t.Schema == obj.Schema && t.Name == obj.Name && (obj.Database == "" || t.Database == obj.Database) {
+					return t.SizeBytes
+				}
+			}
+		case "index":
+			for _, ix := range res.Indexes {
+				if ix.Schema == obj.Schema && ix.Name == obj.Name && (obj.Database == "" || ix.Database == obj.Database) {
+					return ix.SizeBytes
+				}
+			}
+		}
+		return 0
+	}
+
+	for i := range list {
+		f := &list[i]
+		var notes []string
+		penalty := 0
+		lower := func(note string, weight int) {
+			notes = append(notes, note)
+			penalty += weight
+		}
+		impact := LevelMedium
+		if f.Severity == SeverityWarning {
+			impact = LevelHigh
+		}
+
+		var ev Evidence
+		if f.Evidence != nil {
+			ev = *f.Evidence
+		}
+		switch {
+		case len(ev.QueryIDs) > 0:
+			var calls, load float64
+			planned, known := false, 0
+			for _, id := range ev.QueryIDs {
+				st, ok := stmts[id]
+				if !ok {
+					continue
+				}
+				known++
+				calls += st.Calls
+				load += st.TotalTime
+				planned = planned || (st.Advice != nil && st.Advice.Plan != "")
+			}
+			if age := res.Statements.StatsDuration; age > 0 && !o.deterministic {
+				noteStatsAge(age, "pg_stat_statements covers", lower)
+			}
+			if known > 0 {
+				if calls < confidenceMinCalls {
+					lower(fmt.Sprintf("%.0f calls", calls), 1)
+				}
+				if !planned {
+					lower("no EXPLAIN plan", 1)
+				}
+				if loadTotal > 0 {
+					impact = shareLevel(load / loadTotal)
+				}
+			}
+		default:
+			var largest int64
+			counted := false
+			for _, obj := range ev.Objects {
+				if obj.Kind != "table" && obj.Kind != "index" {
+					continue
+				}
+				if !counted {
+					counted = true
+					if age, ok := statsAge(obj.Database); ok {
+						noteStatsAge(age, "database statistics cover", lower)
+					}
+				}
+				if obj.Kind == "table" {
+					if t, ok := stale[obj.Schema+"."+obj.Name]; ok && t.DaysSinceAnalyze > 0 {
+						lower(fmt.Sprintf("planner statistics of %s are %d days old", obj.ID(), t.DaysSinceAnalyze), 1)
+					}
+				}
+				largest = max(largest, size(obj))
+			}
+			switch {
+			case largest >= impactHighBytes:
+				impact = LevelHigh
+			case largest >= impactMediumBytes:
+				impact = LevelMedium
+			case largest > 0:
+				impact = LevelLow
+			}
+		}
+
+		switch penalty {
+		case 0:
+			f.Confidence = LevelHigh
+		case 1:
+			f.Confidence = LevelMedium
+		default:
+			f.Confidence = LevelLow
+		}
+		f.ConfidenceNote = strings.Join(notes, "; ")
+		f.EstimatedImpact = impact
+		f.Effort, f.EffortKind = findingEffort(*f)
+	}
+}
+
+// Effort kinds of Finding.EffortKind.
+const (
+	effortConfig      = "config change"
+	effortIndex       = "index build"
+	effortMaintenance = "maintenance"
+	effortRefactor    = "refactor"
+)
+
+// findingEffort classifies the work a finding asks for from its action and
+// evidence: rewriting queries or partitioning is a refactor, index work an
+// index build, findings on settings alone a configuration change, and the
+// rest (vacuum, slots, roles, replication) maintenance.
+func findingEffort(f Finding) (level, kind string) {
+	action := strings.ToLower(f.Action)
+	var ev Evidence
+	if f.Evidence != nil {
+		ev = *f.Evidence
+	}
+	kinds := map[string]bool{}
+	for _, obj := range ev.Objects {
+		kinds[obj.Kind] = true
+	}
+	index := kinds["index"] || strings.Contains(action, "index")
+	switch {
+	case strings.Contains(action, "partition") || strings.Contains(action, "rewrite") || strings.Contains(action, "refactor"):
+		return LevelHigh, effortRefactor
+	case len(ev.QueryIDs) > 0 && !index:
+		return LevelHigh, effortRefactor
+	case index:
+		return LevelMedium, effortIndex
+	case len(kinds) == 1 && kinds["setting"],
+		len(kinds) == 0 && (strings.Contains(action, "alter system") || strings.Contains(action, "postgresql.conf")):
+		return LevelLow, effortConfig
+	}
+	return LevelMedium, effortMaintenance
+}
+
+// noteStatsAge lowers the confidence in statistics covering less than
+// confidenceMinStatsAge, down to low below confidenceLowStatsAge. what is
+// the source with its verb, e.g. "pg_stat_statements covers".
+func noteStatsAge(age time.Duration, what string, lower func(string, int)) {
+	switch {
+	case age < confidenceLowStatsAge:
+		lower(fmt.Sprintf("%s only %s", what, humanizeDuration(age)), 2)
+	case age < confidenceMinStatsAge:
+		lower(fmt.Sprintf("%s only %s", what, humanizeDuration(age)), 1)
+	}
+}
+
+// shareLevel grades a share of the top statements' execution time.
+func shareLevel(share float64) string {
+	switch {
+	case share >= impactHighShare:
+		return LevelHigh
+	case share >= impactMediumShare:
+		return LevelMedium
+	}
+	return LevelLow
+}
+
+// Health score weights: each warning and recommendation takes points off
+// 100, up to a cap per severity so a long tail of advice cannot outweigh an
+// actual risk.
+const (
+	scoreWarning    = 12
+	scoreWarningCap = 60
+	scoreRec        = 2
+	scoreRecCap     = 40
+
+	// summaryListed caps the findings named in one summary bullet.
+	summaryListed = 3
+)
+
+// Previous is the earlier run an executive summary compares with.
+type Previous struct {
+	StartedAt time.Time
+	Findings  []Finding // severity, code and title are enough
+}
+
+// Score is the health score of findings, 0 to 100: 100 less scoreWarning
+// per warning and scoreRec per recommendation, each capped. It depends on
+// severities alone so archived runs score the same way.
+func Score(findings ...[]Finding) int {
+	var warn, rec int
+	for _, list := range findings {
+		for _, f := range list {
+			switch f.Severity {
+			case SeverityWarning:
+				warn++
+			case SeverityRec:
+				rec++
+			}
+		}
+	}
+	return 100 - min(warn*scoreWarning, scoreWarningCap) - min(rec*scoreRec, scoreRecCap)
+}
+
+// Grade is the letter grade of a health score.
+func Grade(score int) string {
+	switch {
+	case score >= 90:
+		return "A"
+	case score >= 75:
+		return "B"
+	case score >= 60:
+		return "C"
+	case score >= 40:
+		return "D"
+	}
+	return "F"
+}
+
+// Summarize writes the five bullets of the executive summary from the
+// scoring: the grade, the warnings with the highest impact, the quick wins,
+// the major project with the highest impact and the change since prev (nil
+// when there is no earlier run). The result depends on its inputs alone.
+func Summarize(a Analysis, prev *Previous) []string {
+	score := Score(a.Warnings, a.Recommendations)
+	out := []string{fmt.Sprintf("Overall grade %s (%d/100): %s and %s.", Grade(score), score,
+		pluralize(len(a.Warnings), "warning"), pluralize(len(a.Recommendations), "recommendation"))}
+
+	titles := func(list []Finding, keep func(Finding) bool) []string {
+		var picked []Finding
+		for _, f := range list {
+			if keep(f) {
+				picked = append(picked, f)
+			}
+		}
+		sort.SliceStable(picked, func(i, j int) bool {
+			return levelRank(picked[i].EstimatedImpact) > levelRank(picked[j].EstimatedImpact)
+		})
+		var out []string
+		for _, f := range picked {
+			out = append(out, f.Title)
+		}
+		return out
+	}
+	all := append(append([]Finding(nil), a.Warnings...), a.Recommendations...)
+
+	if risks := titles(a.Warnings, func(Finding) bool { return true }); len(risks) > 0 {
+		out = append(out, "Biggest risks: "+listFirst(risks, summaryListed, "; ")+".")
+	} else {
+		out = append(out, "Biggest risks: none; no warning needs attention now.")
+	}
+	if wins := titles(all, func(f Finding) bool { return f.Quadrant() == QuadrantQuickWins }); len(wins) > 0 {
+		out = append(out, "Quick wins (configuration changes): "+listFirst(wins, summaryListed, "; ")+".")
+	} else {
+		out = append(out, "Quick wins: none; no impactful finding is fixed by a configuration change alone.")
+	}
+	if major := titles(all, func(f Finding) bool { return f.Quadrant() == QuadrantMajor }); len(major) > 0 {
+		out = append(out, fmt.Sprintf("Largest project: %s (%s in total).", major[0], pluralize(len(major), "major project")))
+	} else {
+		out = append(out, "Largest project: none; no index build, maintenance or refactor is needed for impact.")
+	}
+
+	if prev == nil {
+		out = append(out, "Trend: no earlier run to compare with; archive runs with -archive to follow it.")
+		return out
+	}
+	was := Score(prev.Findings)
+	seen := map[string]bool{}
+	for _, f := range prev.Findings {
+		if f.Severity != SeverityInfo {
+			seen[f.Code+"\x00"+f.Title] = true
+		}
+	}
+	added := 0
+	for _, f := range all {
+		key := f.Code + "\x00" + f.Title
+		if !seen[key] {
+			added++
+		}
+		delete(seen, key)
+	}
+	trend := "unchanged"
+	switch {
+	case score > was:
+		trend = "improving"
+	case score < was:
+		trend = "worsening"
+	}
+	out = append(out, fmt.Sprintf("Trend since %s: %s, grade %s (%d) → %s (%d); %s, %d resolved.",
+		prev.StartedAt.UTC().Format("2006-01-02 15:04 UTC"), trend, Grade(was), was, Grade(score), score, pluralize(added, "new finding"), len(seen)))
+	return out
+}
+
+// levelRank orders LevelHigh above LevelMedium above LevelLow and unrated.
+func levelRank(level string) int {
+	switch level {
+	case LevelHigh:
+		return 3
+	case LevelMedium:
+		return 2
+	case LevelLow:
+		return 1
+	}
+	return 0
+}
+
+// pluralize formats a count with its noun, adding "s" unless n is 1.
+func pluralize(n int, noun string) string {
+	if n == 1 {
+		return "1 " + noun
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
+
+// pgDocs is the current PostgreSQL manual.
+const pgDocs = "https://www.postgresql.org/docs/current/"
+
+// docPages maps finding codes to their page in the PostgreSQL manual, or to a
+// full URL for extensions and tools documented elsewhere.
+var docPages = map[string]string{
+	"auto-explain":                 "auto-explain.html",
+	"auto-explain-noisy":           "auto-explain.html",
+	"auto-explain-timing":          "auto-explain.html",
+	"autovacuum-naptime-high":      "runtime-config-autovacuum.html",
+	"base-backup-in-progress":      "progress-reporting.html#BASEBACKUP-PROGRESS-REPORTING",
+	"bufferpin-waits":              "monitoring-stats.html#WAIT-EVENT-BUFFERPIN-TABLE",
+	"cache-hit-trend":              "runtime-config-resource.html#GUC-SHARED-BUFFERS",
+	"cache-overall":                "runtime-config-resource.html#GUC-SHARED-BUFFERS",
+	"cgroup-effective-cache-size":  "runtime-config-query.html#GUC-EFFECTIVE-CACHE-SIZE",
+	"cgroup-memory-limit":          "kernel-resources.html#LINUX-MEMORY-OVERCOMMIT",
+	"checkpoint-timeout-low":       "wal-configuration.html",
+	"ci-wait-lockers":              "sql-createindex.html#SQL-CREATEINDEX-CONCURRENTLY",
+	"client-outdated-driver":       "protocol.html",
+	"client-tls-outdated":          "runtime-config-connection.html#GUC-SSL-MIN-PROTOCOL-VERSION",
+	"client-unencrypted":           "ssl-tcp.html",
+	"client-versions-mixed":        "protocol.html",
+	"clock-skew":                   "functions-datetime.html#FUNCTIONS-DATETIME-CURRENT",
+	"connection-churn":             "runtime-config-connection.html#GUC-MAX-CONNECTIONS",
+	"connection-growth":            "runtime-config-connection.html#GUC-MAX-CONNECTIONS",
+	"connection-limit-forecast":    "runtime-config-connection.html#GUC-MAX-CONNECTIONS",
+	"connection-peaks":             "runtime-config-connection.html#GUC-MAX-CONNECTIONS",
+	"copy-in-progress":             "progress-reporting.html#COPY-PROGRESS-REPORTING",
+	"database-locale-mismatch":     "locale.html",
+	"default-privileges-public":    "ddl-schemas.html#DDL-SCHEMAS-PATTERNS",
+	"duplicate-indexes":            "indexes.html",
+	"ecs-low-vs-sb":                "runtime-config-query.html#GUC-EFFECTIVE-CACHE-SIZE",
+	"enable-track-io":              "runtime-config-statistics.html#GUC-TRACK-IO-TIMING",
+	"file-settings-errors":         "view-pg-file-settings.html",
+	"file-settings-overridden":     "view-pg-file-settings.html",
+	"fk-missing-index":             "ddl-constraints.html#DDL-CONSTRAINTS-FK",
+	"freeze-vacuum-forecast":       "routine-vacuuming.html#VACUUM-FOR-WRAPAROUND",
+	"hba-cleartext-password":       "auth-password.html",
+	"hba-file-errors":              "view-pg-hba-file-rules.html",
+	"hba-md5-method":               "auth-password.html",
+	"high-max-connections":         "runtime-config-connection.html#GUC-MAX-CONNECTIONS",
+	"high-wal":                     "wal-configuration.html",
+	"hot-function":                 "monitoring-stats.html#MONITORING-PG-STAT-USER-FUNCTIONS-VIEW",
+	"hot-functions-multi":          "monitoring-stats.html#MONITORING-PG-STAT-USER-FUNCTIONS-VIEW",
+	"huge-pages":                   "kernel-resources.html#LINUX-HUGE-PAGES",
+	"idle-in-transaction":          "runtime-config-client.html#GUC-IDLE-IN-TRANSACTION-SESSION-TIMEOUT",
+	"index-low-selectivity":        "indexes-examine.html",
+	"install-pgss":                 "pgstatstatements.html",
+	"invalid-indexes":              "sql-reindex.html",
+	"io-waits":                     "monitoring-stats.html#WAIT-EVENT-IO-TABLE",
+	"jit-analytical":               "jit-decision.html",
+	"jit-oltp":                     "jit-decision.html",
+	"load-by-role":                 "pgstatstatements.html",
+	"lock-hot-rows":                "explicit-locking.html#LOCKING-ROWS",
+	"lock-table-queue":             "explicit-locking.html#LOCKING-TABLES",
+	"lock-waits":                   "explicit-locking.html",
+	"long-running":                 "runtime-config-client.html#GUC-STATEMENT-TIMEOUT",
+	"maintenance-work-mem-low":     "runtime-config-resource.html#GUC-MAINTENANCE-WORK-MEM",
+	"max-wal-size-low":             "wal-configuration.html",
+	"md5-passwords":                "auth-password.html",
+	"missing-extensions":           "sql-createextension.html",
+	"missing-indexes":              "indexes.html",
+	"mixed-collations":             "collation.html",
+	"n-plus-one":                   "pgstatstatements.html",
+	"no-idle-tx-timeout":           "runtime-config-client.html#GUC-IDLE-IN-TRANSACTION-SESSION-TIMEOUT",
+	"no-statement-timeout":         "runtime-config-client.html#GUC-STATEMENT-TIMEOUT",
+	"numa-zone-reclaim":            "https://docs.kernel.org/admin-guide/sysctl/vm.html#zone-reclaim-mode",
+	"parallel-analytical":          "how-parallel-query-works.html",
+	"parallel-gather-cpus":         "runtime-config-resource.html#GUC-MAX-PARALLEL-WORKERS-PER-GATHER",
+	"parallel-oltp":                "how-parallel-query-works.html",
+	"parallel-workers-cpus":        "runtime-config-resource.html#GUC-MAX-PARALLEL-WORKERS",
+	"parallel-workers-low":         "runtime-config-resource.html#GUC-MAX-PARALLEL-WORKERS",
+	"pooler-detected":              "runtime-config-connection.html#GUC-MAX-CONNECTIONS",
+	"prepared-transactions":        "view-pg-prepared-xacts.html",
+	"query-regression":             "using-explain.html",
+	"query-tail-latency":           "pgstatstatements.html",
+	"query-text-unavailable":       "pgstatstatements.html",
+	"random-page-cost-default":     "runtime-config-query.html#GUC-RANDOM-PAGE-COST",
+	"redundant-indexes":            "indexes-multicolumn.html",
+	"relation-growth":              "diskusage.html",
+	"replica-xmin-horizon":         "runtime-config-replication.html#GUC-HOT-STANDBY-FEEDBACK",
+	"replication-slot-cleanup":     "warm-standby.html#STREAMING-REPLICATION-SLOTS",
+	"restore-verified":             "backup.html",
+	"restore-verify-failed":        "backup.html",
+	"retention-candidates":         "ddl-partitioning.html",
+	"rewrite-in-progress":          "progress-reporting.html#CLUSTER-PROGRESS-REPORTING",
+	"role-idle-timeouts":           "sql-alterrole.html",
+	"roles-without-limits":         "sql-alterrole.html",
+	"sequence-exhaustion-critical": "functions-sequence.html",
+	"sequence-exhaustion-warning":  "functions-sequence.html",
+	"shared-buffers-low":           "runtime-config-resource.html#GUC-SHARED-BUFFERS",
+	"skewed-filter-columns":        "planner-stats.html",
+	"slow-index-improve":           "indexes.html",
+	"slow-joins":                   "using-explain.html",
+	"slow-refactor":                "performance-tips.html",
+	"slow-seq-scans":               "indexes.html",
+	"slow-sorts":                   "indexes-ordering.html",
+	"sql-ascii-database":           "multibyte.html",
+	"ssl-off":                      "ssl-tcp.html",
+	"stale-statistics":             "routine-vacuuming.html#VACUUM-FOR-STATISTICS",
+	"standby-delay-unlimited":      "runtime-config-replication.html#GUC-MAX-STANDBY-STREAMING-DELAY",
+	"subtrans-slru-misses":         "monitoring-stats.html#MONITORING-PG-STAT-SLRU-VIEW",
+	"subxact-overflow":             "subxacts.html",
+	"sync-quorum-mismatch":         "runtime-config-replication.html#GUC-SYNCHRONOUS-STANDBY-NAMES",
+	"sync-standby-missing":         "runtime-config-replication.html#GUC-SYNCHRONOUS-STANDBY-NAMES",
+	"table-bloat-heuristic":        "routine-vacuuming.html#VACUUM-FOR-SPACE-RECOVERY",
+	"tablespace-imbalance":         "manage-ag-tablespaces.html",
+	"template1-objects":            "manage-ag-templatedbs.html",
+	"timezone-mismatch":            "datatype-datetime.html#DATATYPE-TIMEZONES",
+	"timezone-report":              "datatype-datetime.html#DATATYPE-TIMEZONES",
+	"too-many-indexes":             "indexes.html",
+	"track-activities-off":         "runtime-config-statistics.html#GUC-TRACK-ACTIVITIES",
+	"track-activity-query-size":    "runtime-config-statistics.html#GUC-TRACK-ACTIVITY-QUERY-SIZE",
+	"track-counts-off":             "runtime-config-statistics.html#GUC-TRACK-COUNTS",
+	"track-functions-off":          "runtime-config-statistics.html#GUC-TRACK-FUNCTIONS",
+	"transparent-huge-pages":       "kernel-resources.html#LINUX-HUGE-PAGES",
+	"unused-constraint-indexes":    "indexes-unique.html",
+	"unused-indexes":               "monitoring-stats.html#MONITORING-PG-STAT-ALL-INDEXES-VIEW",
+	"vacuum-defer-cleanup-age":     "runtime-config-replication.html#GUC-HOT-STANDBY-FEEDBACK",
+	"vacuum-index-passes":          "runtime-config-resource.html#GUC-MAINTENANCE-WORK-MEM",
+	"vm-overcommit":                "kernel-resources.html#LINUX-MEMORY-OVERCOMMIT",
+	"wal-archiving-failing":        "continuous-archiving.html",
+	"wal-buffers-low":              "runtime-config-wal.html#GUC-WAL-BUFFERS",
+	"wal-fpi":                      "runtime-config-wal.html#GUC-FULL-PAGE-WRITES",
+	"wal-fpi-high":                 "runtime-config-wal.html#GUC-FULL-PAGE-WRITES",
+	"wal-level-minimal":            "runtime-config-wal.html#GUC-WAL-LEVEL",
+	"work-mem-low":                 "runtime-config-resource.html#GUC-WORK-MEM",
+	"worker-processes-low":         "runtime-config-resource.html#GUC-MAX-WORKER-PROCESSES",
+	"workload-class":               "pgstatstatements.html",
+}
+
+// docFamilies maps code prefixes to a page for the codes docPages leaves out.
+var docFamilies = []struct{ prefix, page string }{
+	{"standby-conflict-", "hot-standby.html#HOT-STANDBY-CONFLICT"},
+	{"autofailover-", "https://pg-auto-failover.readthedocs.io/"},
+	{"wal-archive-", "continuous-archiving.html"},
+	{"patroni-", "https://patroni.readthedocs.io/en/latest/"},
+	{"pgaudit-", "https://github.com/pgaudit/pgaudit#readme"},
+	{"storage-", "diskusage.html"},
+	{"repmgr-", "https://www.repmgr.org/docs/current/"},
+	{"xid-", "routine-vacuuming.html#VACUUM-FOR-WRAPAROUND"},
+}
+
+// DocsURL is the public documentation of a finding code, empty for codes
+// without one (e.g. SLO findings, which follow the user's own objectives).
+func DocsURL(code string) string {
+	page, ok := docPages[code]
+	if !ok {
+		for _, f := range docFamilies {
+			if strings.HasPrefix(code, f.prefix) {
+				page, ok = f.page, true
+				break
+			}
+		}
+	}
+	if !ok {
+		return ""
+	}
+	if strings.HasPrefix(page, "https://") {
+		return page
+	}
+	return pgDocs + page
+}
+
+// linkDocs sets the public documentation of each finding.
+func linkDocs(lists ...[]Finding) {
+	for _, list := range lists {
+		for i := range list {
+			list[i].Docs = DocsURL(list[i].Code)
+		}
+	}
+}
+
+// RunbookCode is the placeholder of a -runbook-base template replaced by the
+// finding code.
+const RunbookCode = "{code}"
+
+// RunbookURL expands a runbook template for code: RunbookCode is replaced by
+// the path-escaped code, or the code is appended as the last path segment
+// when the template has no placeholder. Empty without a template or code.
+func RunbookURL(tmpl, code string) string {
+	if tmpl == "" || code == "" {
+		return ""
+	}
+	if strings.Contains(tmpl, RunbookCode) {
+		return strings.ReplaceAll(tmpl, RunbookCode, url.PathEscape(code))
+	}
+	return strings.TrimSuffix(tmpl, "/") + "/" + url.PathEscape(code)
+}
+
+// LinkRunbooks sets the runbook of every finding from tmpl (see RunbookURL),
+// leaving the analysis unchanged without a template.
+func LinkRunbooks(a Analysis, tmpl string) Analysis {
+	if tmpl == "" {
+		return a
+	}
+	for _, list := range [][]Finding{a.Warnings, a.Recommendations, a.Infos} {
+		for i := range list {
+			list[i].Runbook = RunbookURL(tmpl, list[i].Code)
+		}
+	}
+	return a
+}
@@ -1,6 +1,11 @@
 package analyze
 
 import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
 	"testing"
 	"time"
 
@@ -508,3 +513,2210 @@ func TestPreparedTransactionsWarning(t *testing.T) {
 		t.Error("expected warning for prepared transactions")
 	}
 }
+
+// TestLoadByRoleInfo verifies load attribution when one role dominates execution time.
+func TestLoadByRoleInfo(t *testing.T) {
+	tests := []struct {
+		name   string
+		roles  []collect.StatementLoad
+		expect bool
+	}{
+		{"dominant role", []collect.StatementLoad{{Name: "analytics_ro", Share: 0.62}, {Name: "app", Share: 0.38}}, true},
+		{"balanced roles", []collect.StatementLoad{{Name: "a", Share: 0.4}, {Name: "b", Share: 0.35}}, false},
+		{"single role", []collect.StatementLoad{{Name: "app", Share: 1}}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := collect.Result{
+				Extensions: collect.Extensions{PgStatStatements: true},
+				Statements: collect.Statements{Available: true, ByRole: tt.roles},
+			}
+			a := Run(res)
+
+			found := false
+			for _, f := range a.Infos {
+				if f.Code == "load-by-role" {
+					found = true
+					if !strings.HasPrefix(f.Description, "role analytics_ro accounts for 62%") {
+						t.Errorf("unexpected description %q", f.Description)
+					}
+				}
+			}
+			if found != tt.expect {
+				t.Errorf("load-by-role found = %v, expected %v", found, tt.expect)
+			}
+		})
+	}
+}
+
+// TestStandbyConflicts verifies recovery conflict findings follow the conflict
+// types and the standby settings.
+func TestStandbyConflicts(t *testing.T) {
+	tests := []struct {
+		name     string
+		conf     collect.DatabaseConflicts
+		feedback string
+		codes    []string
+		action   string
+	}{
+		{"snapshot without feedback", collect.DatabaseConflicts{Snapshot: 12}, "off", []string{"standby-conflict-snapshot"}, "Enable hot_standby_feedback"},
+		{"snapshot with feedback", collect.DatabaseConflicts{Snapshot: 12}, "on", []string{"standby-conflict-snapshot"}, "already on"},
+		{"lock and pins", collect.DatabaseConflicts{Lock: 3, BufferPin: 1}, "off", []string{"standby-conflict-lock", "standby-conflict-bufferpin"}, "vacuum_truncate"},
+		{"no conflicts", collect.DatabaseConflicts{}, "off", nil, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.conf.Datname = "app"
+			res := collect.Result{
+				Extensions: collect.Extensions{PgStatStatements: true},
+				StandbyConflicts: &collect.StandbyConflicts{
+					Host:      "pg-replica:5432",
+					Databases: []collect.DatabaseConflicts{tt.conf},
+					Settings: []collect.Setting{
+						{Name: "hot_standby_feedback", Val: tt.feedback},
+						{Name: "max_standby_streaming_delay", Val: "30000", Unit: "ms"},
+					},
+				},
+			}
+			a := Run(res)
+			var codes []string
+			actions := ""
+			for _, f := range a.Recommendations {
+				if strings.HasPrefix(f.Code, "standby-") {
+					codes = append(codes, f.Code)
+					actions += f.Action
+				}
+			}
+			if strings.Join(codes, ",") != strings.Join(tt.codes, ",") {
+				t.Errorf("codes = %v, expected %v", codes, tt.codes)
+			}
+			if !strings.Contains(actions, tt.action) || (tt.action != "" && !strings.Contains(actions, "now 30000ms")) {
+				t.Errorf("actions %q should mention %q and the current delay", actions, tt.action)
+			}
+		})
+	}
+}
+
+// TestLowSelectivityIndexRecommendation verifies unselective indexes are
+// recommended for a composite or partial replacement.
+func TestLowSelectivityIndexRecommendation(t *testing.T) {
+	res := collect.Result{
+		Extensions: collect.Extensions{PgStatStatements: true},
+		LowSelectivityIndexes: []collect.IndexStat{
+			{Schema: "public", Table: "orders", Name: "orders_status_idx", Scans: 1000, TupRead: 5_000_000, TupFetch: 20_000},
+		},
+	}
+	a := Run(res)
+	for _, f := range a.Recommendations {
+		if f.Code == "index-low-selectivity" {
+			if !strings.Contains(f.Description, "public.orders_status_idx (5,000 entries/scan, 250 read per row)") {
+				t.Errorf("unexpected description %q", f.Description)
+			}
+			return
+		}
+	}
+	t.Error("expected index-low-selectivity recommendation")
+}
+
+// TestUnusedConstraintIndexes verifies unscanned indexes backing constraints
+// are reported as kept rather than recommended for dropping.
+func TestUnusedConstraintIndexes(t *testing.T) {
+	res := collect.Result{
+		Extensions: collect.Extensions{PgStatStatements: true},
+		ConnInfo:   collect.ConnInfo{CurrentDB: "app"},
+		IndexUnused: []collect.IndexUnused{
+			{Database: "app", Schema: "public", Table: "orders", Name: "orders_note_idx", SizeBytes: 1 << 20},
+			{Database: "app", Schema: "public", Table: "orders", Name: "orders_ref_key", SizeBytes: 2 << 20, Constraint: "unique"},
+		},
+		Indexes: []collect.IndexStat{
+			{Database: "app", Schema: "public", Table: "events", Name: "events_pkey", Constraint: "primary key, replica identity"},
+		},
+		IndexBloatStats: []collect.IndexBloatStat{
+			{Schema: "public", Table: "events", Name: "events_pkey", Scans: 0, WastedBytes: 3 << 20},
+		},
+	}
+	a := Run(res)
+	var drop, keep *Finding
+	for i, f := range a.Recommendations {
+		if f.Code == "unused-indexes" {
+			drop = &a.Recommendations[i]
+		}
+	}
+	for i, f := range a.Infos {
+		if f.Code == "unused-constraint-indexes" {
+			keep = &a.Infos[i]
+		}
+	}
+	if drop == nil || !strings.HasPrefix(drop.Description, "1 unused index candidates; examples: public.orders_note_idx") {
+		t.Errorf("unexpected unused-indexes finding %+v", drop)
+	}
+	if keep == nil {
+		t.Fatal("expected unused-constraint-indexes info")
+	}
+	for _, s := range []string{"2 unscanned", "public.events_pkey (primary key, replica identity)", "public.orders_ref_key (unique)"} {
+		if !strings.Contains(keep.Description, s) {
+			t.Errorf("description %q does not contain %q", keep.Description, s)
+		}
+	}
+}
+
+// TestRedundantIndexesRecommendation verifies prefix-covered indexes are
+// recommended for removal with size and scan evidence.
+func TestRedundantIndexesRecommendation(t *testing.T) {
+	res := collect.Result{
+		Extensions: collect.Extensions{PgStatStatements: true},
+		RedundantIndexes: []collect.RedundantIndex{
+			{Schema: "public", Table: "orders", Index: "orders_customer_idx", Columns: "customer_id", SizeBytes: 64 << 20, Scans: 1200,
+				CoveredBy: "orders_customer_created_idx", CoveredByColumns: "customer_id, created_at", CoveredByScans: 50000},
+		},
+	}
+	a := Run(res)
+	for _, f := range a.Recommendations {
+		if f.Code == "redundant-indexes" {
+			expected := "public.orders_customer_idx (customer_id; 64.0 MB, 1,200 scans) covered by orders_customer_created_idx (customer_id, created_at; 50,000 scans)"
+			if !strings.Contains(f.Description, expected) {
+				t.Errorf("description %q does not contain %q", f.Description, expected)
+			}
+			return
+		}
+	}
+	t.Error("expected redundant-indexes recommendation")
+}
+
+// TestNPlusOneDetection verifies high-rate single-row lookups are grouped by
+// fingerprint and reported, while multi-row or low-rate queries are not.
+func TestNPlusOneDetection(t *testing.T) {
+	res := collect.Result{
+		Extensions: collect.Extensions{PgStatStatements: true},
+		Statements: collect.Statements{
+			TopByCalls: []collect.Statement{
+				{QueryID: 1, Query: "SELECT id, name FROM items WHERE order_id = $1", Calls: 90000, CallsPerHour: 30000, Rows: 90000},
+				{QueryID: 2, Query: "select id, name from items where order_id = $1;", Calls: 30000, CallsPerHour: 10000, Rows: 30000},
+				{QueryID: 3, Query: "SELECT * FROM events WHERE tenant_id = $1", Calls: 90000, CallsPerHour: 30000, Rows: 9000000},
+				{QueryID: 4, Query: "SELECT * FROM users WHERE id = $1", Calls: 100, CallsPerHour: 50, Rows: 100},
+			},
+		},
+	}
+	a := Run(res)
+	for _, f := range a.Recommendations {
+		if f.Code != "n-plus-one" {
+			continue
+		}
+		if !strings.HasPrefix(f.Description, "1 single-row lookup pattern(s)") ||
+			!strings.Contains(f.Description, "items by order_id (40,000 calls/h, 1.0 rows/call, 2 statement(s): select id, name from items where order_id = ?)") {
+			t.Errorf("unexpected description %q", f.Description)
+		}
+		return
+	}
+	t.Error("expected n-plus-one recommendation")
+}
+
+// TestTailLatency verifies that queries whose estimated p99 is far above the
+// mean are reported once, and narrow or fast distributions are not.
+func TestTailLatency(t *testing.T) {
+	slow := collect.Statement{QueryID: 1, Query: "SELECT * FROM orders WHERE id = $1", Calls: 1000, MeanTime: 20, MaxTime: 5000, StddevTime: 200}
+	res := collect.Result{Statements: collect.Statements{
+		TopByTotalTime: []collect.Statement{
+			slow,
+			{QueryID: 2, Query: "SELECT 2", Calls: 1000, MeanTime: 200, MaxTime: 400, StddevTime: 50},
+			{QueryID: 3, Query: "SELECT 3", Calls: 1000, MeanTime: 1, MaxTime: 80, StddevTime: 10},
+			{QueryID: 4, Query: "SELECT 4", Calls: 1000, MeanTime: 300},
+		},
+		TopByCalls: []collect.Statement{slow},
+	}}
+	a := Run(res)
+	for _, f := range a.Recommendations {
+		if f.Code != "query-tail-latency" {
+			continue
+		}
+		if !strings.HasPrefix(f.Description, "1 top statement(s)") || !strings.Contains(f.Description, "SELECT * FROM orders WHERE id = $1") {
+			t.Errorf("unexpected description %q", f.Description)
+		}
+		return
+	}
+	t.Error("expected query-tail-latency recommendation")
+}
+
+// TestTrackSettings verifies disabled or undersized track_* settings name
+// the report sections they degrade.
+func TestTrackSettings(t *testing.T) {
+	long := "SELECT " + strings.Repeat("x", 1016)
+	res := collect.Result{
+		Extensions: collect.Extensions{PgStatStatements: true},
+		Settings: []collect.Setting{
+			{Name: "track_counts", Val: "off"},
+			{Name: "track_io_timing", Val: "off"},
+			{Name: "track_functions", Val: "none"},
+			{Name: "track_activity_query_size", Val: "1024", Unit: "B"},
+		},
+		LongRunning: []collect.LongQuery{{Query: long}, {Query: "SELECT 1"}},
+		Blocking:    []collect.Blocking{{BlockedQuery: long, BlockingQuery: "UPDATE t SET x = 1"}},
+	}
+	a := Run(res)
+	found := map[string]string{}
+	for _, list := range [][]Finding{a.Warnings, a.Recommendations, a.Infos} {
+		for _, f := range list {
+			found[f.Code] = f.Description
+		}
+	}
+	for code, want := range map[string]string{
+		"track-counts-off":          "Unused indexes",
+		"enable-track-io":           "Top queries by CPU and by I/O time",
+		"track-functions-off":       "Top functions by total time",
+		"track-activity-query-size": "2 session query text(s) were cut at 1023 bytes by track_activity_query_size = 1024, leaving partial statements in Blocking queries, Long-running queries.",
+	} {
+		if d, ok := found[code]; !ok || !strings.Contains(d, want) {
+			t.Errorf("%s description = %q, expected it to contain %q", code, d, want)
+		}
+	}
+	if _, ok := found["track-activities-off"]; ok {
+		t.Error("track_activities was not collected and should not be reported")
+	}
+}
+
+// TestQueryTexts verifies hidden, missing and truncated statement texts are
+// counted with the grant or setting that restores them.
+func TestQueryTexts(t *testing.T) {
+	res := collect.Result{
+		Extensions: collect.Extensions{PgStatStatements: true},
+		ConnInfo:   collect.ConnInfo{CurrentUser: "monitor"},
+		Statements: collect.Statements{
+			TopByTotalTime: []collect.Statement{
+				{QueryID: 1, Query: "SELECT 1", Calls: 1},
+				{Query: collect.HiddenQueryText, Calls: 5},
+				{Query: collect.HiddenQueryText, Calls: 3},
+				{QueryID: 4, Query: "SELECT * FROM t WHERE id IN (SELECT", Truncated: true, Calls: 2},
+			},
+			TopByCalls: []collect.Statement{{QueryID: 1, Query: "SELECT 1", Calls: 1}},
+		},
+	}
+	a := Run(res)
+	for _, f := range a.Recommendations {
+		if f.Code != "query-text-unavailable" {
+			continue
+		}
+		if want := "3 of 4 top statement(s) have no usable text (2 hidden as <insufficient privilege>, 1 truncated)"; !strings.HasPrefix(f.Description, want) {
+			t.Errorf("description = %q, expected prefix %q", f.Description, want)
+		}
+		if !strings.HasPrefix(f.Action, "GRANT pg_read_all_stats TO monitor;") {
+			t.Errorf("action = %q", f.Action)
+		}
+		return
+	}
+	t.Error("expected query-text-unavailable recommendation")
+}
+
+// TestAutoExplain verifies auto_explain advice is fitted to the latencies of
+// the top statements and depends on how the module is configured.
+func TestAutoExplain(t *testing.T) {
+	fast := collect.Statement{QueryID: 1, Query: "SELECT 1", Calls: 10000, CallsPerHour: 100000, MeanTime: 2, MaxTime: 30, StddevTime: 1}
+	slow := collect.Statement{QueryID: 2, Query: "SELECT 2", Calls: 100, CallsPerHour: 1000, MeanTime: 300, MaxTime: 2000, StddevTime: 100}
+	set := func(kv ...string) []collect.Setting {
+		var out []collect.Setting
+		for i := 0; i+1 < len(kv); i += 2 {
+			out = append(out, collect.Setting{Name: kv[i], Val: kv[i+1]})
+		}
+		return out
+	}
+	tests := []struct {
+		name     string
+		stmts    []collect.Statement
+		settings []collect.Setting
+		code     string
+		want     []string
+	}{
+		{"not loaded", []collect.Statement{fast, slow}, set("shared_preload_libraries", "pg_stat_statements"), "auto-explain",
+			[]string{"shared_preload_libraries = 'pg_stat_statements,auto_explain'", "log_min_duration = '100ms'", "sample_rate = 0.6"}},
+		{"disabled", []collect.Statement{fast, slow}, set("shared_preload_libraries", "auto_explain", "auto_explain.log_min_duration", "-1"), "auto-explain",
+			[]string{"log_min_duration = '100ms'"}},
+		{"noisy", []collect.Statement{fast, slow}, set("auto_explain.log_min_duration", "0", "auto_explain.sample_rate", "1"), "auto-explain-noisy",
+			[]string{"sample_rate = 0.6"}},
+		{"timing", []collect.Statement{fast, slow}, set("auto_explain.log_min_duration", "250", "auto_explain.log_analyze", "on", "auto_explain.log_timing", "on"), "auto-explain-timing",
+			[]string{"log_timing = off", "sample_rate = 0.1"}},
+		{"fast workload", []collect.Statement{fast}, nil, "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := Run(collect.Result{
+				Extensions: collect.Extensions{PgStatStatements: true},
+				Settings:   tt.settings,
+				Statements: collect.Statements{Available: true, TopByTotalTime: tt.stmts},
+			})
+			var got []Finding
+			for _, f := range a.Recommendations {
+				if strings.HasPrefix(f.Code, "auto-explain") {
+					got = append(got, f)
+				}
+			}
+			if tt.code == "" {
+				if len(got) > 0 {
+					t.Fatalf("unexpected auto_explain advice %+v", got)
+				}
+				return
+			}
+			if len(got) != 1 || got[0].Code != tt.code {
+				t.Fatalf("auto_explain advice = %+v, expected one %s", got, tt.code)
+			}
+			for _, w := range tt.want {
+				if !strings.Contains(got[0].Action, w) {
+					t.Errorf("action %q missing %q", got[0].Action, w)
+				}
+			}
+		})
+	}
+}
+
+// TestLockHotspots verifies hot rows and table-lock queues produce separate
+// warnings naming the tables.
+func TestLockHotspots(t *testing.T) {
+	res := collect.Result{
+		Extensions: collect.Extensions{PgStatStatements: true},
+		LockHotspots: []collect.LockHotspot{
+			{Schema: "public", Table: "jobs", Granted: 3, Waiting: 5, TupleLocks: 4, HotRows: 1, LongestWaitSecs: 12},
+			{Schema: "public", Table: "orders", Granted: 1, Waiting: 2, StrongLocks: 1},
+			{Schema: "public", Table: "idle", Granted: 1, StrongLocks: 1},
+		},
+	}
+	a := Run(res)
+	found := map[string]string{}
+	for _, f := range a.Warnings {
+		found[f.Code] = f.Description
+	}
+	if d := found["lock-hot-rows"]; !strings.Contains(d, "public.jobs (1 row(s), 5 waiting, longest 12s)") {
+		t.Errorf("lock-hot-rows description = %q", d)
+	}
+	if d := found["lock-table-queue"]; !strings.Contains(d, "public.orders (2 waiting)") || strings.Contains(d, "idle") {
+		t.Errorf("lock-table-queue description = %q", d)
+	}
+}
+
+// TestSubtransactions verifies overflowed sessions and Subtrans SLRU misses
+// are reported, and a healthy SLRU is not.
+func TestSubtransactions(t *testing.T) {
+	res := collect.Result{
+		Extensions: collect.Extensions{PgStatStatements: true},
+		Subtransactions: &collect.Subtransactions{
+			SLRU: &collect.SLRUStat{BlksHit: 9000, BlksRead: 6000},
+			Backends: []collect.SubxactBackend{
+				{PID: 42, Application: "importer", Count: 250, Overflowed: true},
+				{PID: 43, Usename: "app", Count: 3},
+			},
+		},
+	}
+	a := Run(res)
+	found := map[string]string{}
+	for _, f := range a.Warnings {
+		found[f.Code] = f.Description
+	}
+	if d := found["subxact-overflow"]; !strings.Contains(d, "1 session(s)") || !strings.Contains(d, "pid 42 (importer, 250 subxacts)") {
+		t.Errorf("subxact-overflow description = %q", d)
+	}
+	if d := found["subtrans-slru-misses"]; !strings.Contains(d, "6,000 pages (60% hit ratio)") {
+		t.Errorf("subtrans-slru-misses description = %q", d)
+	}
+
+	res.Subtransactions = &collect.Subtransactions{SLRU: &collect.SLRUStat{BlksHit: 1000000, BlksRead: 2000}}
+	for _, f := range Run(res).Warnings {
+		if f.Code == "subtrans-slru-misses" || f.Code == "subxact-overflow" {
+			t.Errorf("unexpected finding %s: %s", f.Code, f.Description)
+		}
+	}
+}
+
+// TestVacuumHorizon verifies that standbys and slots holding an old xmin are
+// reported as the cause of dead tuple bloat, and that young horizons are not.
+func TestVacuumHorizon(t *testing.T) {
+	res := collect.Result{
+		Tables: []collect.TableStat{{Schema: "public", Name: "orders", NLiveTup: 8000, NDeadTup: 4000, BloatPct: 33}},
+		VacuumHorizon: &collect.VacuumHorizon{
+			Holders: []collect.XminHolder{
+				{Kind: "slot", Name: "old_replica", XminAge: 5000000},
+				{Kind: "standby", Name: "replica1", Active: true, XminAge: 2000000},
+				{Kind: "session", Name: "77 (psql)", Active: true, XminAge: 1200},
+			},
+			Settings: []collect.Setting{{Name: "vacuum_defer_cleanup_age", Val: "10000"}},
+		},
+	}
+	a := Run(res)
+	found := map[string]Finding{}
+	for _, f := range a.Warnings {
+		found[f.Code] = f
+	}
+	f, ok := found["replica-xmin-horizon"]
+	if !ok {
+		t.Fatalf("expected replica-xmin-horizon finding")
+	}
+	for _, want := range []string{"last 5,000,000 transactions", "inactive slot old_replica", "standby replica1 via hot_standby_feedback", "'Potential table bloat'"} {
+		if !strings.Contains(f.Description, want) {
+			t.Errorf("description missing %q: %q", want, f.Description)
+		}
+	}
+	if !strings.Contains(f.Action, "pg_drop_replication_slot") || !strings.Contains(f.Action, "statement_timeout") {
+		t.Errorf("action = %q", f.Action)
+	}
+	if !strings.HasPrefix(found["table-bloat-heuristic"].Action, "Vacuum is held back") {
+		t.Errorf("bloat action not tied to horizon: %q", found["table-bloat-heuristic"].Action)
+	}
+	if _, ok := found["vacuum-defer-cleanup-age"]; !ok {
+		t.Errorf("expected vacuum-defer-cleanup-age finding")
+	}
+
+	res.VacuumHorizon = &collect.VacuumHorizon{Holders: []collect.XminHolder{{Kind: "standby", Name: "replica1", Active: true, XminAge: 5000}}}
+	for _, f := range Run(res).Warnings {
+		if f.Code == "replica-xmin-horizon" || f.Code == "vacuum-defer-cleanup-age" {
+			t.Errorf("unexpected finding %s: %s", f.Code, f.Description)
+		}
+	}
+}
+
+// TestFreezeForecast verifies that large tables reaching their freeze age
+// limit within the forecast window are reported, and small or distant ones are not.
+func TestFreezeForecast(t *testing.T) {
+	const gb = int64(1) << 30
+	res := collect.Result{
+		FreezeForecast: &collect.FreezeForecast{
+			XIDRate: 1000000,
+			Tables: []collect.FreezeTable{
+				{Schema: "public", Name: "events", XIDAge: 210000000, FreezeMaxAge: 200000000, SizeBytes: 12 * gb},
+				{Schema: "public", Name: "orders", XIDAge: 150000000, FreezeMaxAge: 200000000, SizeBytes: 2 * gb},
+				{Schema: "public", Name: "small", XIDAge: 199000000, FreezeMaxAge: 200000000, SizeBytes: 1000},
+				{Schema: "public", Name: "archive", XIDAge: 10000000, FreezeMaxAge: 200000000, SizeBytes: 50 * gb},
+			},
+		},
+	}
+	var f Finding
+	for _, r := range Run(res).Recommendations {
+		if r.Code == "freeze-vacuum-forecast" {
+			f = r
+		}
+	}
+	for _, want := range []string{"1,000,000 XIDs/hour", "public.events (12.0 GB, due now)", "public.orders (2.0 GB, in ~2d 2h)"} {
+		if !strings.Contains(f.Description, want) {
+			t.Errorf("description missing %q: %q", want, f.Description)
+		}
+	}
+	for _, unwanted := range []string{"small", "archive"} {
+		if strings.Contains(f.Description, unwanted) {
+			t.Errorf("description should not list %s: %q", unwanted, f.Description)
+		}
+	}
+
+	// Without a measured rate only tables already due are reported
+	res.FreezeForecast.XIDRate = 0
+	res.FreezeForecast.Tables = res.FreezeForecast.Tables[1:]
+	for _, r := range Run(res).Recommendations {
+		if r.Code == "freeze-vacuum-forecast" {
+			t.Errorf("unexpected finding: %s", r.Description)
+		}
+	}
+}
+
+// TestXIDWraparoundForecast verifies that the archived XID consumption rate
+// forecasts when the oldest database reaches the critical XID age.
+func TestXIDWraparoundForecast(t *testing.T) {
+	now := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	res := collect.Result{
+		XIDAge: []collect.DatabaseXIDAge{{Datname: "app", Age: 1000000000, PctToLimit: 46.6}},
+		// ~1.15 billion XIDs left to the limit at 2M XIDs/hour: ~24 days
+		XIDRates: []collect.XIDRate{
+			{From: now.Add(-time.Hour), To: now, XIDs: 2000000},
+			{From: now.Add(-2 * time.Hour), To: now.Add(-time.Hour), XIDs: 2000000},
+		},
+	}
+	var f Finding
+	for _, w := range Run(res).Warnings {
+		if w.Code == "xid-wraparound-forecast" {
+			f = w
+		}
+	}
+	for _, want := range []string{"2,000,000 XIDs/hour", "last 2 archived runs", "app reaches 75% of the XID limit in ~12d", "wraparound limit in ~23d 21h"} {
+		if !strings.Contains(f.Description, want) {
+			t.Errorf("description missing %q: %q", want, f.Description)
+		}
+	}
+
+	// A slow consumer gets an informational forecast only
+	res.XIDRates = []collect.XIDRate{{From: now.Add(-time.Hour), To: now, XIDs: 1000}}
+	a := Run(res)
+	for _, w := range a.Warnings {
+		if w.Code == "xid-wraparound-forecast" {
+			t.Errorf("unexpected forecast: %s", w.Description)
+		}
+	}
+	var info string
+	for _, i := range a.Infos {
+		if i.Title == "XID age healthy" {
+			info = i.Description
+		}
+	}
+	if !strings.Contains(info, "consuming 1,000 XIDs/hour") {
+		t.Errorf("healthy info = %q", info)
+	}
+}
+
+// TestClock verifies clock skew and time zone mismatch findings.
+func TestClock(t *testing.T) {
+	tests := []struct {
+		name  string
+		ci    collect.ConnInfo
+		codes []string
+	}{
+		{
+			name: "aligned",
+			ci: collect.ConnInfo{TimeZone: "UTC", LogTimeZone: "Etc/UTC", ClientTimeZone: "UTC",
+				ClockSkew: 2 * time.Second},
+		},
+		{
+			name: "skew behind",
+			ci: collect.ConnInfo{TimeZone: "UTC", LogTimeZone: "UTC", ClientTimeZone: "UTC",
+				ClockSkew: -45 * time.Second},
+			codes: []string{"clock-skew"},
+		},
+		{
+			name: "local server time, UTC logs",
+			ci: collect.ConnInfo{TimeZone: "Europe/Berlin", TimeZoneOffset: 3600, LogTimeZone: "UTC",
+				ClientTimeZone: "CET", ClientTimeZoneOffset: 3600},
+			codes: []string{"timezone-mismatch", "timezone-report"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := Run(collect.Result{ConnInfo: tt.ci})
+			got := map[string]string{}
+			for _, list := range [][]Finding{a.Warnings, a.Recommendations, a.Infos} {
+				for _, f := range list {
+					switch f.Code {
+					case "clock-skew", "timezone-mismatch", "timezone-report":
+						got[f.Code] = f.Description
+					}
+				}
+			}
+			if len(got) != len(tt.codes) {
+				t.Errorf("findings = %v, want %v", got, tt.codes)
+			}
+			for _, c := range tt.codes {
+				if _, ok := got[c]; !ok {
+					t.Errorf("missing %s in %v", c, got)
+				}
+			}
+		})
+	}
+
+	ci := collect.ConnInfo{TimeZone: "Europe/Berlin", TimeZoneOffset: 3600, LogTimeZone: "America/New_York", LogTimeZoneOffset: -18000, ClockSkew: 90 * time.Second}
+	a := Run(collect.Result{ConnInfo: ci})
+	for _, f := range append(a.Warnings, a.Recommendations...) {
+		if f.Code == "clock-skew" && !strings.Contains(f.Description, "1m 30s ahead of") {
+			t.Errorf("clock-skew description = %q", f.Description)
+		}
+		if f.Code == "timezone-mismatch" && !strings.Contains(f.Description, "Europe/Berlin (UTC+01:00) but log_timezone = America/New_York (UTC-05:00)") {
+			t.Errorf("timezone-mismatch description = %q", f.Description)
+		}
+	}
+}
+
+// TestConfigFiles verifies rejected and overridden configuration file entries
+// and invalid pg_hba.conf lines raise their findings.
+func TestConfigFiles(t *testing.T) {
+	tests := []struct {
+		name  string
+		cf    collect.ConfigFiles
+		codes []string
+	}{
+		{
+			name: "overridden by ALTER SYSTEM",
+			cf: collect.ConfigFiles{Settings: []collect.FileSetting{
+				{File: "/etc/postgresql/postgresql.conf", Line: 120, Name: "work_mem", Val: "4MB"},
+			}},
+			codes: []string{"file-settings-overridden"},
+		},
+		{
+			name: "invalid value and hba line",
+			cf: collect.ConfigFiles{
+				Settings: []collect.FileSetting{
+					{File: "/etc/postgresql/postgresql.conf", Line: 64, Name: "shared_buffers", Val: "8GBB", Error: "setting could not be applied"},
+				},
+				HBARules: []collect.HBARule{{Line: 98, Type: "host", Error: "invalid authentication method \"md6\""}},
+			},
+			codes: []string{"file-settings-errors", "hba-file-errors"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cf := tt.cf
+			a := Run(collect.Result{ConfigFiles: &cf})
+			got := map[string]string{}
+			for _, list := range [][]Finding{a.Warnings, a.Recommendations, a.Infos} {
+				for _, f := range list {
+					switch f.Code {
+					case "file-settings-errors", "file-settings-overridden", "hba-file-errors":
+						got[f.Code] = f.Description
+					}
+				}
+			}
+			if len(got) != len(tt.codes) {
+				t.Errorf("findings = %v, want %v", got, tt.codes)
+			}
+			for _, c := range tt.codes {
+				if _, ok := got[c]; !ok {
+					t.Errorf("missing %s in %v", c, got)
+				}
+			}
+		})
+	}
+}
+
+// TestOSMemory verifies huge page and kernel memory recommendations.
+func TestOSMemory(t *testing.T) {
+	settings := func(hp string) []collect.Setting {
+		return []collect.Setting{
+			{Name: "shared_buffers", Val: "1048576", Unit: "8kB"},
+			{Name: "huge_pages", Val: hp},
+			{Name: "shared_memory_size_in_huge_pages", Val: "4150"},
+		}
+	}
+	tests := []struct {
+		name     string
+		settings []collect.Setting
+		om       *collect.OSMemory
+		codes    []string
+	}{
+		{
+			name:     "remote, huge pages off",
+			settings: settings("off"),
+			codes:    []string{"huge-pages"},
+		},
+		{
+			name:     "reserved and tuned",
+			settings: settings("try"),
+			om:       &collect.OSMemory{THPEnabled: "never", OvercommitMemory: 2, HugePagesTotal: 4200, HugePageSizeBytes: 2 << 20},
+		},
+		{
+			name:     "not reserved, THP always, heuristic overcommit",
+			settings: settings("try"),
+			om:       &collect.OSMemory{THPEnabled: "always", HugePagesTotal: 0, HugePageSizeBytes: 2 << 20},
+			codes:    []string{"huge-pages", "transparent-huge-pages", "vm-overcommit"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := Run(collect.Result{Settings: tt.settings, OSMemory: tt.om})
+			got := map[string]string{}
+			for _, f := range a.Recommendations {
+				switch f.Code {
+				case "huge-pages", "transparent-huge-pages", "vm-overcommit":
+					got[f.Code] = f.Action
+				}
+			}
+			if len(got) != len(tt.codes) {
+				t.Errorf("findings = %v, want %v", got, tt.codes)
+			}
+			for _, c := range tt.codes {
+				if _, ok := got[c]; !ok {
+					t.Errorf("missing %s in %v", c, got)
+				}
+			}
+			if act := got["huge-pages"]; act != "" && !strings.Contains(act, "vm.nr_hugepages = 4150") {
+				t.Errorf("huge-pages action = %q, want the server's page count", act)
+			}
+		})
+	}
+}
+
+// TestOSCPU verifies worker settings are compared with each other and with
+// the CPUs, NUMA layout and cgroup limits of the host.
+func TestOSCPU(t *testing.T) {
+	settings := []collect.Setting{
+		{Name: "max_worker_processes", Val: "8"},
+		{Name: "max_parallel_workers", Val: "16"},
+		{Name: "max_parallel_workers_per_gather", Val: "4"},
+		{Name: "shared_buffers", Val: "1048576", Unit: "8kB"},
+	}
+	tests := []struct {
+		name  string
+		cpu   *collect.OSCPU
+		codes []string
+	}{
+		{name: "remote", codes: []string{"worker-processes-low"}},
+		{
+			name:  "large host",
+			cpu:   &collect.OSCPU{CPUs: 32, NUMANodes: []collect.NUMANode{{ID: 0}, {ID: 1}}},
+			codes: []string{"worker-processes-low"},
+		},
+		{
+			name: "container",
+			cpu: &collect.OSCPU{CPUs: 32, CPUQuota: 4, MemoryLimitBytes: 12 << 30,
+				NUMANodes: []collect.NUMANode{{ID: 0}, {ID: 1}}, ZoneReclaimMode: 1},
+			codes: []string{"worker-processes-low", "parallel-workers-cpus", "parallel-gather-cpus", "numa-zone-reclaim", "cgroup-memory-limit"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := Run(collect.Result{Settings: settings, OSCPU: tt.cpu})
+			got := map[string]bool{}
+			for _, list := range [][]Finding{a.Warnings, a.Recommendations} {
+				for _, f := range list {
+					switch f.Code {
+					case "worker-processes-low", "parallel-workers-cpus", "parallel-gather-cpus", "numa-zone-reclaim", "cgroup-memory-limit", "cgroup-effective-cache-size":
+						got[f.Code] = true
+					}
+				}
+			}
+			if len(got) != len(tt.codes) {
+				t.Errorf("findings = %v, want %v", got, tt.codes)
+			}
+			for _, c := range tt.codes {
+				if !got[c] {
+					t.Errorf("missing %s in %v", c, got)
+				}
+			}
+		})
+	}
+}
+
+// TestMemoryBudget verifies memory advice is sized on the container memory
+// limit rather than the host RAM.
+func TestMemoryBudget(t *testing.T) {
+	tests := []struct {
+		name     string
+		workMem  string
+		om       *collect.OSMemory
+		code     string
+		contains string
+	}{
+		{name: "remote", workMem: "1024", code: "work-mem-low", contains: "16-64MB"},
+		{name: "host RAM", workMem: "1024", om: &collect.OSMemory{MemTotalBytes: 64 << 30, OvercommitMemory: 2},
+			code: "shared-buffers-low", contains: "16GB-25GB (25-40% of the 64.0 GB of host RAM)"},
+		{name: "container low work_mem", workMem: "1024", om: &collect.OSMemory{MemTotalBytes: 64 << 30, CgroupMemoryLimitBytes: 2 << 30, OvercommitMemory: 2},
+			code: "work-mem-low", contains: "up to 6MB"},
+		{name: "container shared_buffers", workMem: "1024", om: &collect.OSMemory{MemTotalBytes: 64 << 30, CgroupMemoryLimitBytes: 2 << 30, OvercommitMemory: 2},
+			code: "shared-buffers-low", contains: "about 512MB (25% of the 2.0 GB container memory limit"},
+		{name: "container high work_mem", workMem: "65536", om: &collect.OSMemory{MemTotalBytes: 64 << 30, CgroupMemoryLimitBytes: 2 << 30, OvercommitMemory: 2},
+			code: "", contains: "Lower work_mem to about 6MB"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := collect.Result{
+				ConnInfo: collect.ConnInfo{MaxConnections: 100},
+				Settings: []collect.Setting{
+					{Name: "shared_buffers", Val: "16384", Unit: "8kB"},
+					{Name: "work_mem", Val: tt.workMem, Unit: "kB"},
+				},
+				OSMemory: tt.om,
+			}
+			a := Run(res)
+			for _, list := range [][]Finding{a.Warnings, a.Recommendations} {
+				for _, f := range list {
+					if f.Code == tt.code && strings.Contains(f.Action, tt.contains) {
+						return
+					}
+				}
+			}
+			t.Errorf("no %q finding with %q in:\n%v\n%v", tt.code, tt.contains, a.Warnings, a.Recommendations)
+		})
+	}
+}
+
+// TestPatroni verifies Patroni cluster state findings and the comparison
+// with pg_stat_replication on the leader.
+func TestPatroni(t *testing.T) {
+	pc := collect.PatroniCluster{
+		Paused: true,
+		Members: []collect.PatroniMember{
+			{Name: "pg1", Role: "leader", State: "running"},
+			{Name: "pg2", Role: "sync_standby", State: "streaming"},
+			{Name: "pg3", Role: "replica", State: "stopped"},
+		},
+		History: []collect.PatroniFailover{{Timeline: 2, At: time.Now().Add(-time.Hour), NewLeader: "pg1"}},
+	}
+	repl := []collect.ReplicationStat{
+		{Name: "pg2", SyncState: "async"},
+		{Name: "pg_basebackup", SyncState: "async"},
+	}
+	tests := []struct {
+		name       string
+		inRecovery bool
+		codes      []string
+	}{
+		{name: "leader", codes: []string{"patroni-paused", "patroni-failovers", "patroni-replica-missing", "patroni-sync-mismatch", "patroni-unknown-standby"}},
+		{name: "replica", inRecovery: true, codes: []string{"patroni-paused", "patroni-failovers"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := Run(collect.Result{Patroni: &pc, ReplicationStats: repl, ConnInfo: collect.ConnInfo{InRecovery: tt.inRecovery}})
+			got := map[string]bool{}
+			for _, list := range [][]Finding{a.Warnings, a.Recommendations, a.Infos} {
+				for _, f := range list {
+					if strings.HasPrefix(f.Code, "patroni-") {
+						got[f.Code] = true
+					}
+				}
+			}
+			if len(got) != len(tt.codes) {
+				t.Errorf("findings = %v, want %v", got, tt.codes)
+			}
+			for _, c := range tt.codes {
+				if !got[c] {
+					t.Errorf("missing %s in %v", c, got)
+				}
+			}
+		})
+	}
+}
+
+// TestHA verifies repmgr and pg_auto_failover node registration findings.
+func TestHA(t *testing.T) {
+	ha := collect.HAMetadata{
+		RepmgrNodes: []collect.RepmgrNode{
+			{ID: 1, Name: "node1", Type: "primary", Active: true, MonitorAge: -1},
+			{ID: 2, Name: "node2", Type: "standby", Active: true, Upstream: "node1", MonitorAge: time.Second},
+			{ID: 3, Name: "node3", Type: "standby", Active: true, Upstream: "node1", MonitorAge: time.Hour},
+			{ID: 4, Name: "node4", Type: "standby", Active: false, Upstream: "node1", MonitorAge: -1},
+		},
+		AutoFailoverNodes: []collect.AutoFailoverNode{
+			{Name: "node_1", GoalState: "primary", ReportedState: "primary", PgRunning: true, Health: 1, ReportAge: 2 * time.Second},
+			{Name: "node_2", GoalState: "secondary", ReportedState: "catchingup", PgRunning: true, Health: 1, ReportAge: 2 * time.Second},
+			{Name: "node_3", GoalState: "secondary", ReportedState: "secondary", PgRunning: true, Health: 1, ReportAge: time.Hour},
+		},
+	}
+	repl := []collect.ReplicationStat{{Name: "node2"}}
+	tests := []struct {
+		name       string
+		inRecovery bool
+		codes      []string
+	}{
+		{name: "primary", codes: []string{"repmgr-standby-not-streaming", "repmgr-monitoring-gap", "repmgr-inactive-node", "autofailover-unhealthy", "autofailover-state-mismatch"}},
+		{name: "standby", inRecovery: true, codes: []string{"repmgr-monitoring-gap", "repmgr-inactive-node", "autofailover-unhealthy", "autofailover-state-mismatch"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := Run(collect.Result{HA: &ha, ReplicationStats: repl, ConnInfo: collect.ConnInfo{InRecovery: tt.inRecovery}})
+			got := map[string]string{}
+			for _, list := range [][]Finding{a.Warnings, a.Recommendations} {
+				for _, f := range list {
+					if strings.HasPrefix(f.Code, "repmgr-") || strings.HasPrefix(f.Code, "autofailover-") {
+						got[f.Code] = f.Description
+					}
+				}
+			}
+			if len(got) != len(tt.codes) {
+				t.Errorf("findings = %v, want %v", got, tt.codes)
+			}
+			for _, c := range tt.codes {
+				if _, ok := got[c]; !ok {
+					t.Errorf("missing %s in %v", c, got)
+				}
+			}
+			if d := got["repmgr-standby-not-streaming"]; d != "" && !strings.Contains(d, "node3") {
+				t.Errorf("not streaming = %q, want node3 only", d)
+			}
+		})
+	}
+}
+
+// TestWALArchiving verifies the archiver failure warning and the findings
+// of the archive lookup.
+func TestWALArchiving(t *testing.T) {
+	now := time.Now()
+	checked := []string{"000000010000000A00000002", "000000010000000A00000001"}
+	tests := []struct {
+		name string
+		wa   collect.WALArchiving
+		code string
+	}{
+		{name: "healthy", wa: collect.WALArchiving{Mode: "on", LastArchivedWAL: checked[0], LastArchivedAt: now, LastFailedAt: now.Add(-time.Hour)}},
+		{name: "failing", wa: collect.WALArchiving{Mode: "on", LastArchivedAt: now.Add(-time.Hour), LastFailedWAL: checked[0], LastFailedAt: now, FailedCount: 12}, code: "wal-archiving-failing"},
+		{name: "gap", wa: collect.WALArchiving{Mode: "on", Check: &collect.WALArchiveCheck{Destination: "s3://b/p", Checked: checked, Missing: checked[1:]}}, code: "wal-archive-gap"},
+		{name: "unverified", wa: collect.WALArchiving{Mode: "on", Check: &collect.WALArchiveCheck{Error: "access denied"}}, code: "wal-archive-unverified"},
+		{name: "verified", wa: collect.WALArchiving{Mode: "on", Check: &collect.WALArchiveCheck{Destination: "/archive", Checked: checked}}, code: "wal-archive-verified"},
+		{name: "off", wa: collect.WALArchiving{Mode: "off", LastFailedAt: now}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wa := tt.wa
+			a := Run(collect.Result{WALArchiving: &wa})
+			var got []string
+			for _, list := range [][]Finding{a.Warnings, a.Recommendations, a.Infos} {
+				for _, f := range list {
+					if strings.HasPrefix(f.Code, "wal-archiv") {
+						got = append(got, f.Code)
+					}
+				}
+			}
+			if (tt.code == "" && len(got) != 0) || (tt.code != "" && (len(got) != 1 || got[0] != tt.code)) {
+				t.Errorf("findings = %v, want %q", got, tt.code)
+			}
+		})
+	}
+}
+
+// TestRestoreCheck verifies the findings of the restore verification hook.
+func TestRestoreCheck(t *testing.T) {
+	tests := []struct {
+		rc   collect.RestoreCheck
+		code string
+		want string
+	}{
+		{rc: collect.RestoreCheck{Output: "restored 12 GB\nok\n"}, code: "restore-verified", want: `"ok"`},
+		{rc: collect.RestoreCheck{ExitCode: 2, Output: "pg_restore: error: checksum mismatch"}, code: "restore-verify-failed", want: "status 2"},
+		{rc: collect.RestoreCheck{ExitCode: -1, Error: "timed out after 10m0s"}, code: "restore-verify-failed", want: "timed out"},
+	}
+	for _, tt := range tests {
+		rc := tt.rc
+		a := Run(collect.Result{RestoreCheck: &rc})
+		var found *Finding
+		for _, list := range [][]Finding{a.Warnings, a.Infos} {
+			for i := range list {
+				if strings.HasPrefix(list[i].Code, "restore-") {
+					found = &list[i]
+				}
+			}
+		}
+		if found == nil || found.Code != tt.code || !strings.Contains(found.Description, tt.want) {
+			t.Errorf("finding for %+v = %+v, want %s containing %q", tt.rc, found, tt.code, tt.want)
+		}
+	}
+}
+
+// TestSLO verifies that a breached objective is a warning and an objective met
+// now but missed too often is a recommendation.
+func TestSLO(t *testing.T) {
+	tests := []struct {
+		st   collect.SLOStatus
+		code string
+	}{
+		{collect.SLOStatus{Name: "Cache", Measured: true, Met: false, Runs: 10, MetRuns: 9, Goal: 95, BurnRate: 2}, "slo-breached"},
+		{collect.SLOStatus{Name: "Cache", Measured: true, Met: true, Runs: 10, MetRuns: 8, Goal: 95, BurnRate: 4}, "slo-budget-burn"},
+		{collect.SLOStatus{Name: "Cache", Measured: true, Met: true, Runs: 10, MetRuns: 10, Goal: 95}, ""},
+		{collect.SLOStatus{Name: "Lag"}, ""},
+	}
+	for _, tt := range tests {
+		a := Run(collect.Result{SLOs: []collect.SLOStatus{tt.st}})
+		code := ""
+		for _, list := range [][]Finding{a.Warnings, a.Recommendations} {
+			for _, f := range list {
+				if strings.HasPrefix(f.Code, "slo-") {
+					code = f.Code
+				}
+			}
+		}
+		if code != tt.code {
+			t.Errorf("finding for %+v = %q, want %q", tt.st, code, tt.code)
+		}
+	}
+}
+
+// TestObjectNamesAcrossDatabases verifies findings name objects with their
+// database when the same schema.name was collected from several databases.
+func TestObjectNamesAcrossDatabases(t *testing.T) {
+	res := collect.Result{
+		ConnInfo: collect.ConnInfo{CurrentDB: "app"},
+		Tables: []collect.TableStat{
+			{Database: "app", Schema: "public", Name: "orders", NLiveTup: 10000, NDeadTup: 5000, BloatPct: 33},
+			{Database: "billing", Schema: "public", Name: "orders", NLiveTup: 10000, NDeadTup: 8000, BloatPct: 44},
+		},
+	}
+	var desc string
+	for _, f := range Run(res).Warnings {
+		if f.Code == "table-bloat-heuristic" {
+			desc = f.Description
+		}
+	}
+	for _, want := range []string{"billing.public.orders(44%)", "app.public.orders(33%)"} {
+		if !strings.Contains(desc, want) {
+			t.Errorf("bloat description %q missing %q", desc, want)
+		}
+	}
+}
+
+// TestTablespaceImbalance verifies busy large relations on the default
+// tablespace are suggested for the unused tablespace marked fastest.
+func TestTablespaceImbalance(t *testing.T) {
+	base := collect.Result{
+		ConnInfo: collect.ConnInfo{CurrentDB: "app"},
+		DBs:      []collect.Database{{Name: "app", Tablespaces: "pg_default"}},
+		Tables:   []collect.TableStat{{Database: "app", Schema: "public", Name: "orders", SizeBytes: 12 << 30, SeqScans: 10, IdxScans: 5000}},
+		Indexes:  []collect.IndexStat{{Database: "app", Schema: "public", Name: "orders_pkey", SizeBytes: 2 << 30, Scans: 4000}},
+	}
+	tests := []struct {
+		name        string
+		tablespaces []collect.Tablespace
+		placed      string
+		want        string
+	}{
+		{"only pg_default", []collect.Tablespace{{Name: "pg_default", SizeBytes: 20 << 30}}, "", ""},
+		{"fast tablespace unused", []collect.Tablespace{{Name: "archive", SizeBytes: 0}, {Name: "nvme", Location: "/mnt/nvme", SizeBytes: 8 << 20, Options: "random_page_cost=1.1"},
+			{Name: "pg_default", SizeBytes: 20 << 30}}, "", "ALTER TABLE public.orders SET TABLESPACE nvme;"},
+		{"already moved", []collect.Tablespace{{Name: "nvme", SizeBytes: -1}, {Name: "pg_default", SizeBytes: 20 << 30}}, "nvme", ""},
+		{"other tablespace in use", []collect.Tablespace{{Name: "nvme", SizeBytes: 5 << 30}, {Name: "pg_default", SizeBytes: 20 << 30}}, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := base
+			res.Tablespaces = tt.tablespaces
+			res.Tables = []collect.TableStat{base.Tables[0]}
+			res.Tables[0].Tablespace = tt.placed
+			var got *Finding
+			recs := Run(res).Recommendations
+			for i := range recs {
+				if recs[i].Code == "tablespace-imbalance" {
+					got = &recs[i]
+				}
+			}
+			if tt.want == "" {
+				if got != nil {
+					t.Errorf("unexpected finding: %+v", *got)
+				}
+				return
+			}
+			if got == nil || !strings.Contains(got.Action, tt.want) || !strings.Contains(got.Action, "ALTER INDEX public.orders_pkey SET TABLESPACE nvme;") {
+				t.Errorf("finding = %+v, want action containing %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestOperations verifies table rewrites in progress are warnings while bulk
+// loads and base backups are informational.
+func TestOperations(t *testing.T) {
+	a := Run(collect.Result{
+		ProgressCopy:       []collect.ProgressCopy{{Relation: "public.events", Command: "COPY FROM", BytesProcessed: 1, BytesTotal: 4, Seconds: 60}},
+		ProgressCluster:    []collect.ProgressCluster{{Relation: "public.orders", Command: "VACUUM FULL", Phase: "seq scanning heap", Seconds: 600}},
+		ProgressBasebackup: []collect.ProgressBasebackup{{PID: 7, Phase: "streaming database files"}},
+	})
+	found := map[string]Finding{}
+	for _, list := range [][]Finding{a.Warnings, a.Infos} {
+		for _, f := range list {
+			found[f.Code] = f
+		}
+	}
+	tests := []struct {
+		code, severity, want string
+	}{
+		{"copy-in-progress", SeverityInfo, "COPY FROM public.events (25%, running 1m"},
+		{"rewrite-in-progress", SeverityWarning, "VACUUM FULL public.orders (seq scanning heap, running 10m"},
+		{"base-backup-in-progress", SeverityInfo, "pid 7 (streaming database files, running"},
+	}
+	for _, tt := range tests {
+		f, ok := found[tt.code]
+		if !ok || f.Severity != tt.severity || !strings.Contains(f.Description, tt.want) {
+			t.Errorf("%s = %+v, want %s containing %q", tt.code, f, tt.severity, tt.want)
+		}
+	}
+}
+
+// TestVacuumIndexPasses verifies multi-pass vacuums get a memory setting
+// sized to fit them in one pass, or the 1GB cap explained.
+func TestVacuumIndexPasses(t *testing.T) {
+	tests := []struct {
+		name string
+		av   collect.AutoVacuum
+		want string
+	}{
+		{"single pass", collect.AutoVacuum{Relation: "public.orders", Phase: "scanning heap", Scanned: 10, Total: 100, Auto: true, MaxDeadTuples: 1 << 20}, ""},
+		{"autovacuum", collect.AutoVacuum{Relation: "public.orders", Phase: "vacuuming indexes", Scanned: 100, Total: 100, Auto: true,
+			IndexVacuumCount: 2, MaxDeadTuples: 64 << 20 / 6, NumDeadTuples: 64 << 20 / 6}, "autovacuum_work_mem = '192MB'"},
+		{"manual", collect.AutoVacuum{Relation: "public.orders", Phase: "vacuuming indexes", Scanned: 100, Total: 100,
+			IndexVacuumCount: 2, MaxDeadTuples: 64 << 20 / 6, NumDeadTuples: 64 << 20 / 6}, "SET maintenance_work_mem = '192MB'"},
+		{"capped", collect.AutoVacuum{Relation: "public.orders", Phase: "vacuuming heap", Auto: true,
+			IndexVacuumCount: 3, MaxDeadTuples: 1 << 30 / 6}, "at most 1 GB"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got *Finding
+			recs := Run(collect.Result{AutoVacuum: []collect.AutoVacuum{tt.av}}).Recommendations
+			for i := range recs {
+				if recs[i].Code == "vacuum-index-passes" {
+					got = &recs[i]
+				}
+			}
+			if tt.want == "" {
+				if got != nil {
+					t.Errorf("unexpected finding: %+v", *got)
+				}
+				return
+			}
+			if got == nil || !strings.Contains(got.Action, tt.want) {
+				t.Errorf("finding = %+v, want action containing %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestReplicationSlots verifies lost and abandoned slots retaining WAL are
+// flagged for cleanup, with max_slot_wal_keep_size advice when unlimited.
+func TestReplicationSlots(t *testing.T) {
+	tests := []struct {
+		name  string
+		slots []collect.ReplicationSlot
+		want  string
+	}{
+		{"active", []collect.ReplicationSlot{{Name: "standby1", Type: "physical", Active: true, RetainedBytes: 20 << 30, SafeWALSize: -1}}, ""},
+		{"small", []collect.ReplicationSlot{{Name: "standby1", Type: "physical", RetainedBytes: 100 << 20, SafeWALSize: -1, InactiveSeconds: -1}}, ""},
+		{"temporary", []collect.ReplicationSlot{{Name: "pg_basebackup_1", Type: "physical", Temporary: true, RetainedBytes: 20 << 30, SafeWALSize: -1}}, ""},
+		{"unlimited", []collect.ReplicationSlot{{Name: "cdc", Type: "logical", Plugin: "pgoutput", Database: "app", RetainedBytes: 20 << 30,
+			SafeWALSize: -1, InactiveSeconds: 3 * 86400}}, "max_slot_wal_keep_size"},
+		{"lost", []collect.ReplicationSlot{{Name: "old", Type: "physical", WALStatus: "lost", SafeWALSize: -1, InactiveSeconds: -1},
+			{Name: "cdc", Type: "logical", RetainedBytes: 2 << 30, SafeWALSize: 8 << 30, InactiveSeconds: -1}}, "SELECT pg_drop_replication_slot('old');"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got *Finding
+			warns := Run(collect.Result{ReplicationSlots: tt.slots}).Warnings
+			for i := range warns {
+				if warns[i].Code == "replication-slot-cleanup" {
+					got = &warns[i]
+				}
+			}
+			if tt.want == "" {
+				if got != nil {
+					t.Errorf("unexpected finding: %+v", *got)
+				}
+				return
+			}
+			if got == nil || !strings.Contains(got.Action, tt.want) {
+				t.Errorf("finding = %+v, want action containing %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestSyncReplication verifies synchronous_standby_names is checked against
+// the streaming standbys: missing ones block commits, an exact count leaves
+// no spare.
+func TestSyncReplication(t *testing.T) {
+	settings := func(names, commit string) []collect.Setting {
+		return []collect.Setting{{Name: "synchronous_standby_names", Val: names}, {Name: "synchronous_commit", Val: commit}}
+	}
+	streaming := func(names ...string) []collect.ReplicationStat {
+		var out []collect.ReplicationStat
+		for _, n := range names {
+			out = append(out, collect.ReplicationStat{Name: n, State: "streaming", SyncState: "quorum"})
+		}
+		return out
+	}
+	tests := []struct {
+		name      string
+		res       collect.Result
+		code      string
+		wantTitle string
+		wantDesc  string
+	}{
+		{"async", collect.Result{Settings: settings("", "on"), ReplicationStats: streaming("s1")}, "", "", ""},
+		{"spare", collect.Result{Settings: settings("ANY 1 (s1, s2)", "on"), ReplicationStats: streaming("s1", "s2")}, "", "", ""},
+		{"missing", collect.Result{Settings: settings("FIRST 1 (s1, s2)", "on"), ReplicationStats: streaming("S3")},
+			"sync-standby-missing", "Synchronous replication is blocking commits", "Streaming but not listed: S3"},
+		{"impossible", collect.Result{Settings: settings("ANY 3 (s1, s2)", "on"), ReplicationStats: streaming("s1", "s2")},
+			"sync-standby-missing", "Synchronous replication is blocking commits", "can never be satisfied"},
+		{"local commit", collect.Result{Settings: settings("s1", "local")},
+			"sync-standby-missing", "Synchronous standbys missing", "Not connected: s1."},
+		{"no spare", collect.Result{Settings: settings("ANY 2 (s1, s2)", "remote_apply"), ReplicationStats: streaming("s1", "s2")},
+			"sync-quorum-mismatch", "No spare synchronous standby", "exactly 2 listed standby(s)"},
+		{"standby", collect.Result{Settings: settings("s1", "on"), ConnInfo: collect.ConnInfo{InRecovery: true}}, "", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := Run(tt.res)
+			var got *Finding
+			all := append(append([]Finding{}, a.Warnings...), a.Recommendations...)
+			for i := range all {
+				if strings.HasPrefix(all[i].Code, "sync-") {
+					got = &all[i]
+				}
+			}
+			if tt.code == "" {
+				if got != nil {
+					t.Errorf("unexpected finding: %+v", *got)
+				}
+				return
+			}
+			if got == nil || got.Code != tt.code || got.Title != tt.wantTitle || !strings.Contains(got.Description, tt.wantDesc) {
+				t.Errorf("finding = %+v, want %s %q with description containing %q", got, tt.code, tt.wantTitle, tt.wantDesc)
+			}
+		})
+	}
+}
+
+// TestSessionChurn verifies frequent short sessions are flagged with the
+// pool size their active time needs, and long-lived sessions are not.
+func TestSessionChurn(t *testing.T) {
+	day := 86400.0
+	tests := []struct {
+		name  string
+		stat  collect.SessionStat
+		wants []string
+	}{
+		{"pooled", collect.SessionStat{Datname: "app", Sessions: 2000, SessionTimeMs: 2000 * 3600e3, ActiveTimeMs: 1e6, WindowSeconds: day}, nil},
+		{"few sessions", collect.SessionStat{Datname: "app", Sessions: 500, SessionTimeMs: 500 * 50, ActiveTimeMs: 500 * 10, WindowSeconds: 3600}, nil},
+		{"churn", collect.SessionStat{Datname: "app", Sessions: 864000, SessionTimeMs: 864000 * 80, ActiveTimeMs: 864000 * 20,
+			Abandoned: 20000, WindowSeconds: day}, []string{"36,000 sessions/h lasting 80ms", "3m of backend startup per hour", "25% of it running", "abandoned", "about 1 server connection"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got *Finding
+			recs := Run(collect.Result{SessionStats: []collect.SessionStat{tt.stat}}).Recommendations
+			for i := range recs {
+				if recs[i].Code == "connection-churn" {
+					got = &recs[i]
+				}
+			}
+			if tt.wants == nil {
+				if got != nil {
+					t.Errorf("unexpected finding: %+v", *got)
+				}
+				return
+			}
+			if got == nil {
+				t.Fatal("connection-churn not reported")
+			}
+			for _, want := range tt.wants {
+				if !strings.Contains(got.Description+" "+got.Action, want) {
+					t.Errorf("finding missing %q: %+v", want, *got)
+				}
+			}
+		})
+	}
+}
+
+// TestStorageForecast verifies the days-until-full severity thresholds and
+// the growth-only finding without a disk size.
+func TestStorageForecast(t *testing.T) {
+	gb := int64(1 << 30)
+	tests := []struct {
+		name     string
+		disk     int64
+		perDay   float64
+		days     float64
+		severity string
+		code     string
+		wants    []string
+	}{
+		{"too little history", 1000 * gb, float64(gb), 0.5, "", "", nil},
+		{"no disk size", 0, float64(gb), 10, SeverityInfo, "storage-growth", []string{"1.0 GB/day", "Set -disk-size", "table public.events 512.0 MB/day"}},
+		{"full soon", 120 * gb, float64(gb), 10, SeverityWarning, "storage-full-forecast", []string{"full in ~20 day(s)", "20.0 GB free"}},
+		{"full this quarter", 160 * gb, float64(gb), 10, SeverityRec, "storage-full-forecast", []string{"full in ~60 day(s)"}},
+		{"far off", 1000 * gb, float64(gb), 10, SeverityInfo, "storage-forecast", []string{"full in ~900 day(s)"}},
+		{"shrinking", 1000 * gb, -float64(gb), 10, SeverityInfo, "storage-forecast", []string{"did not grow", "900.0 GB of 1000.0 GB is free"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := collect.Result{
+				DBs:           []collect.Database{{Name: "app", SizeBytes: 100 * gb}},
+				DiskSizeBytes: tt.disk,
+				SizeGrowth: []collect.SizeGrowth{
+					{Database: "app", Bytes: 100 * gb, BytesPerDay: tt.perDay, Runs: 5, Days: tt.days},
+					{Database: "app", Schema: "public", Name: "events", Bytes: 40 * gb, BytesPerDay: tt.perDay / 2, Runs: 5, Days: tt.days},
+				},
+			}
+			a := Run(res)
+			var got *Finding
+			for _, list := range [][]Finding{a.Warnings, a.Recommendations, a.Infos} {
+				for i := range list {
+					if strings.HasPrefix(list[i].Code, "storage-") {
+						got = &list[i]
+					}
+				}
+			}
+			if tt.code == "" {
+				if got != nil {
+					t.Errorf("unexpected finding: %+v", *got)
+				}
+				return
+			}
+			if got == nil || got.Code != tt.code || got.Severity != tt.severity {
+				t.Fatalf("finding = %+v, want %s %s", got, tt.severity, tt.code)
+			}
+			for _, want := range tt.wants {
+				if !strings.Contains(got.Description, want) {
+					t.Errorf("description missing %q: %s", want, got.Description)
+				}
+			}
+			if got.Evidence == nil || got.Evidence.Metrics["used_bytes"] != float64(100*gb) {
+				t.Errorf("evidence = %+v", got.Evidence)
+			}
+		})
+	}
+}
+
+// TestConnectionForecast verifies the max_connections forecast thresholds
+// and the pool sizes derived from busy peaks.
+func TestConnectionForecast(t *testing.T) {
+	peakAt := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		total    collect.ConnectionTrend
+		severity string
+		code     string
+		wants    []string
+	}{
+		{"single run", collect.ConnectionTrend{Current: 90, Peak: 90, PeakAt: peakAt, Runs: 1}, "", "", nil},
+		{"limit soon", collect.ConnectionTrend{Current: 80, Peak: 150, PeakBusy: 40, PeakAt: peakAt, PerDay: 5, Runs: 10, Days: 9}, SeverityWarning, "connection-limit-forecast",
+			[]string{"peaked at 150 of max_connections 200 (75%) on 2024-01-15 10:00 UTC", "in ~10 day(s)", "api peak 120 (40 busy, +4.0/day)", "api ~60"}},
+		{"limit this quarter", collect.ConnectionTrend{Current: 80, Peak: 100, PeakBusy: 40, PeakAt: peakAt, PerDay: 2, Runs: 10, Days: 9}, SeverityRec, "connection-limit-forecast", []string{"in ~50 day(s)"}},
+		{"high peak, flat", collect.ConnectionTrend{Current: 80, Peak: 170, PeakBusy: 40, PeakAt: peakAt, Runs: 10, Days: 9}, SeverityRec, "connection-limit-forecast", []string{"(85%)"}},
+		{"steady", collect.ConnectionTrend{Current: 80, Peak: 90, PeakBusy: 40, PeakAt: peakAt, PerDay: 0.5, Runs: 10, Days: 9}, SeverityInfo, "connection-peaks", []string{"in ~220 day(s)"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := collect.Result{
+				ConnInfo: collect.ConnInfo{MaxConnections: 200},
+				ConnectionHistory: &collect.ConnectionHistory{Total: tt.total, Apps: []collect.ConnectionTrend{
+					{Application: "api", Current: 70, Peak: 120, PeakBusy: 40, PerDay: 4, Runs: 10, Days: 9},
+				}},
+			}
+			a := Run(res)
+			var got *Finding
+			for _, list := range [][]Finding{a.Warnings, a.Recommendations, a.Infos} {
+				for i := range list {
+					if strings.HasPrefix(list[i].Code, "connection-limit") || list[i].Code == "connection-peaks" {
+						got = &list[i]
+					}
+				}
+			}
+			if tt.code == "" {
+				if got != nil {
+					t.Errorf("unexpected finding: %+v", *got)
+				}
+				return
+			}
+			if got == nil || got.Code != tt.code || got.Severity != tt.severity {
+				t.Fatalf("finding = %+v, want %s %s", got, tt.severity, tt.code)
+			}
+			for _, want := range tt.wants {
+				if !strings.Contains(got.Description+" "+got.Action, want) {
+					t.Errorf("finding missing %q: %s %s", want, got.Description, got.Action)
+				}
+			}
+		})
+	}
+}
+
+// TestWorkload verifies the workload classification and the JIT and
+// parallel query advice tailored to it.
+func TestWorkload(t *testing.T) {
+	oltp := func(n int) []collect.Statement {
+		var out []collect.Statement
+		for i := 0; i < n; i++ {
+			out = append(out, collect.Statement{Query: fmt.Sprintf("SELECT * FROM t%d WHERE id = $1", i), Calls: 1e5, TotalTime: 1e5, MeanTime: 1, Rows: 1e5})
+		}
+		return out
+	}
+	report := collect.Statement{Query: "SELECT a.x, sum(b.y) FROM a JOIN b ON b.a = a.id JOIN c ON c.b = b.id JOIN d ON d.c = c.id GROUP BY 1",
+		Calls: 100, TotalTime: 3e5, MeanTime: 3000, Rows: 5e6, TempBlksWrite: 1e5}
+	parallel := collect.Statement{Query: "SELECT count(*) FROM events", Calls: 10, TotalTime: 1e5, MeanTime: 1e4, Rows: 10,
+		Advice: &collect.PlanAdvice{Highlights: []string{"Parallel operation(s)"}}}
+
+	var reports []collect.Statement
+	for i := 0; i < 4; i++ {
+		r := report
+		r.Query += fmt.Sprintf(", %d", i+2)
+		reports = append(reports, r)
+	}
+	mixed := append(oltp(5), report, parallel)
+
+	tests := []struct {
+		name   string
+		stmts  []collect.Statement
+		jit    string
+		gather string
+		class  string
+		codes  []string
+	}{
+		{"too few statements", oltp(3), "on", "4", "", nil},
+		{"oltp", oltp(5), "on", "4", WorkloadOLTP, []string{"jit-oltp", "parallel-oltp"}},
+		{"oltp within limits", oltp(5), "off", "2", WorkloadOLTP, nil},
+		{"analytical", append(reports, parallel, oltp(1)[0]), "off", "0", WorkloadAnalytical, []string{"jit-analytical", "parallel-analytical"}},
+		{"mixed", mixed, "on", "0", WorkloadMixed, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := collect.Result{
+				Statements: collect.Statements{TopByTotalTime: tt.stmts},
+				Settings:   []collect.Setting{{Name: "jit", Val: tt.jit}, {Name: "max_parallel_workers_per_gather", Val: tt.gather}},
+			}
+			a := Run(res)
+			if a.Workload.Class != tt.class {
+				t.Fatalf("class = %q (%+v), want %q", a.Workload.Class, a.Workload, tt.class)
+			}
+			codes := map[string]bool{}
+			for _, f := range append(a.Recommendations, a.Infos...) {
+				codes[f.Code] = true
+			}
+			if codes["workload-class"] != (tt.class != "") {
+				t.Errorf("workload-class reported = %v", codes["workload-class"])
+			}
+			for _, code := range []string{"jit-oltp", "jit-analytical", "parallel-oltp", "parallel-analytical"} {
+				if want := slices.Contains(tt.codes, code); codes[code] != want {
+					t.Errorf("%s reported = %v, want %v", code, codes[code], want)
+				}
+			}
+		})
+	}
+
+	// 3e5 ms of the 9e5 spill to temp files, 1e5 run parallel plans
+	w := classifyWorkload(mixed)
+	if w.Statements != 7 || math.Round(w.TempPct) != 33 || math.Round(w.ParallelPct) != 11 || math.Round(w.AnalyticalPct) != 44 {
+		t.Errorf("classifyWorkload() = %+v", w)
+	}
+}
+
+// TestDetectPooler verifies poolers are recognized by name or by the shape of
+// their server connections, and that pooling advice then targets the pooler
+// in place instead of suggesting one.
+func TestDetectPooler(t *testing.T) {
+	hour := 3600.0
+	tests := []struct {
+		name    string
+		clients []collect.ClientConn
+		pooler  string // "" when none is expected
+	}{
+		{"none", []collect.ClientConn{{Address: "10.0.0.1", User: "app", Count: 40, AvgAgeSeconds: 5}, {Address: "10.0.0.2", User: "app", Count: 40, AvgAgeSeconds: 5}}, ""},
+		{"named", []collect.ClientConn{{Address: "10.0.0.9", User: "app", Application: "PgBouncer", Count: 3}}, "pgbouncer"},
+		{"pooler user", []collect.ClientConn{{Address: "10.0.0.9", User: "odyssey", Count: 3}}, "odyssey"},
+		{"shape", []collect.ClientConn{{Address: "10.0.0.5", User: "app", Count: 30, Idle: 25, AvgAgeSeconds: 6 * hour},
+			{Address: "10.0.0.5", User: "report", Count: 5, AvgAgeSeconds: 6 * hour}, {Address: "10.0.0.7", User: "dba", Count: 2, AvgAgeSeconds: 60}}, "a connection pool"},
+		{"scattered", []collect.ClientConn{{Address: "10.0.0.1", Count: 5, AvgAgeSeconds: 6 * hour}, {Address: "10.0.0.2", Count: 5, AvgAgeSeconds: 6 * hour},
+			{Address: "10.0.0.3", Count: 5, AvgAgeSeconds: 6 * hour}, {Address: "10.0.0.4", Count: 5, AvgAgeSeconds: 6 * hour}}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := Run(collect.Result{ConnInfo: collect.ConnInfo{MaxConnections: 500}, ConnectionsByClient: tt.clients})
+			detected := false
+			for _, f := range a.Infos {
+				if f.Code == "pooler-detected" {
+					detected = true
+				}
+			}
+			if detected != (tt.pooler != "") {
+				t.Errorf("pooler-detected = %v, expected pooler %q", detected, tt.pooler)
+			}
+			for i := range a.Recommendations {
+				if a.Recommendations[i].Code != "high-max-connections" {
+					continue
+				}
+				action := a.Recommendations[i].Action
+				if tt.pooler == "" && !strings.Contains(action, "Consider using a connection pooler") {
+					t.Errorf("action = %q, expected a pooler suggestion", action)
+				}
+				if tt.pooler != "" && !strings.Contains(action, "already come through "+tt.pooler) {
+					t.Errorf("action = %q, expected advice for %s", action, tt.pooler)
+				}
+			}
+		})
+	}
+}
+
+// TestDeterministic verifies that a deterministic analysis leaves out values
+// measured against the current time and keeps the rest of the findings.
+func TestDeterministic(t *testing.T) {
+	started := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	res := collect.Result{
+		ConnInfo: collect.ConnInfo{StartTime: started},
+		WAL:      &collect.WALStat{Bytes: 1 << 40, Records: 100, FullPage: 60, StatsReset: time.Now().Add(-time.Hour)},
+		Patroni:  &collect.PatroniCluster{History: []collect.PatroniFailover{{Timeline: 2, At: time.Now().Add(-time.Hour)}}},
+	}
+	res.Statements.Available = true
+	res.Statements.StatsResetTime = started
+	res.Statements.TopByTotalTime = []collect.Statement{{Query: "SELECT 1", Calls: 1000, TotalTime: 5000}}
+
+	for _, tt := range []struct {
+		name   string
+		opts   []Option
+		uptime string
+		timed  bool // Calls/hr, WAL rate and recent failovers
+	}{
+		{name: "default", uptime: " (since ", timed: true},
+		{name: "deterministic", opts: []Option{Deterministic()}, uptime: "Up since "},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			a := Run(res, tt.opts...)
+			codes := map[string]bool{}
+			descs := map[string]string{}
+			for _, list := range [][]Finding{a.Warnings, a.Recommendations, a.Infos} {
+				for _, f := range list {
+					codes[f.Code] = true
+					descs[f.Title] = f.Description
+				}
+			}
+			if !strings.Contains(descs["Server uptime"], tt.uptime) {
+				t.Errorf("uptime = %q, want %q", descs["Server uptime"], tt.uptime)
+			}
+			if got := strings.Contains(descs["Top query by total time"], "Calls/hr"); got != tt.timed {
+				t.Errorf("top query = %q, want Calls/hr %v", descs["Top query by total time"], tt.timed)
+			}
+			if codes["high-wal"] != tt.timed || codes["patroni-failovers"] != tt.timed {
+				t.Errorf("high-wal %v, patroni-failovers %v, want %v", codes["high-wal"], codes["patroni-failovers"], tt.timed)
+			}
+			if !codes["wal-fpi-high"] {
+				t.Error("wal-fpi-high missing")
+			}
+		})
+	}
+}
+
+// TestEvidence verifies findings carry the objects and metrics they are based
+// on, and that findings without any keep no evidence.
+func TestEvidence(t *testing.T) {
+	res := collect.Result{
+		Extensions:      collect.Extensions{PgStatStatements: true},
+		ConnInfo:        collect.ConnInfo{CurrentDB: "app"},
+		CacheHitCurrent: 80,
+		IndexUnused: []collect.IndexUnused{
+			{Database: "app", Schema: "public", Table: "orders", Name: "orders_note_idx", SizeBytes: 1 << 20},
+		},
+	}
+	a := Run(res)
+	byTitle := map[string]Finding{}
+	for _, list := range [][]Finding{a.Warnings, a.Recommendations, a.Infos} {
+		for _, f := range list {
+			byTitle[f.Title] = f
+		}
+	}
+
+	unused := byTitle["Unused indexes"]
+	if unused.Evidence == nil || len(unused.Evidence.Objects) != 1 {
+		t.Fatalf("unused-indexes evidence = %+v", unused.Evidence)
+	}
+	if o := unused.Evidence.Objects[0]; o.Kind != "index" || o.ID() != "app.public.orders_note_idx" || o.Metrics["size_bytes"] != 1<<20 {
+		t.Errorf("unused index object = %+v (%s)", o, o.ID())
+	}
+	cache := byTitle["Low cache hit ratio (current DB)"]
+	if cache.Evidence == nil || cache.Evidence.Metrics["cache_hit_pct"] != 80 {
+		t.Errorf("cache hit evidence = %+v", cache.Evidence)
+	}
+
+	for _, list := range [][]Finding{a.Warnings, a.Recommendations, a.Infos} {
+		for _, f := range list {
+			if e := f.Evidence; e != nil && len(e.Objects)+len(e.Metrics)+len(e.QueryIDs) == 0 {
+				t.Errorf("%s: empty evidence kept", f.Title)
+			}
+		}
+	}
+	b, err := json.Marshal(Finding{Title: "t", Severity: SeverityInfo})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(b), "Evidence") {
+		t.Errorf("finding without evidence marshals to %s", b)
+	}
+}
+
+// TestColumnSkew verifies skewed filter columns get partial index DDL, NULL
+// skew an IS NOT NULL predicate, and large tables with few distinct values a
+// partitioning hint, while evenly distributed columns are not flagged.
+func TestColumnSkew(t *testing.T) {
+	res := collect.Result{
+		Tables: []collect.TableStat{
+			{Database: "app", Schema: "public", Name: "orders", SizeBytes: 20 << 30},
+			{Database: "app", Schema: "public", Name: "events", SizeBytes: 1 << 30},
+		},
+		ColumnStats: []collect.ColumnStat{
+			{Database: "app", Schema: "public", Table: "orders", Column: "status", HotValue: "done", HotFreq: 0.92, NDistinct: 5, Calls: 5000},
+			{Database: "app", Schema: "public", Table: "events", Column: "note", HotValue: "it's", HotFreq: 0.6, NDistinct: -0.01, Calls: 300},
+			{Database: "app", Schema: "public", Table: "events", Column: "parent_id", NullFrac: 0.8, NDistinct: -0.1, Calls: 200},
+			{Database: "app", Schema: "public", Table: "orders", Column: "customer_id", HotValue: "42", HotFreq: 0.01, NDistinct: -0.2, Calls: 9000},
+		},
+	}
+	a := Run(res)
+	var got *Finding
+	for i := range a.Recommendations {
+		if a.Recommendations[i].Code == "skewed-filter-columns" {
+			got = &a.Recommendations[i]
+		}
+	}
+	if got == nil {
+		t.Fatal("expected a skewed-filter-columns recommendation")
+	}
+	text := got.Description + " " + got.Action
+	for _, want := range []string{
+		"public.orders.status = 'done' in 92% of rows (5,000 calls)",
+		"CREATE INDEX CONCURRENTLY ON public.orders (status) WHERE status <> 'done';",
+		"WHERE note <> 'it''s';",
+		"public.events.parent_id is NULL in 80% of rows",
+		"ON public.events (parent_id) WHERE parent_id IS NOT NULL;",
+		"public.orders by LIST (status).",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("finding missing %q: %s", want, text)
+		}
+	}
+	if strings.Contains(text, "customer_id") || strings.Contains(text, "events by LIST") {
+		t.Errorf("unexpected column or partitioning hint: %s", text)
+	}
+	if n := len(got.Evidence.Objects); n != 3 {
+		t.Errorf("evidence objects = %d, expected 3", n)
+	}
+}
+
+// TestRetention verifies append-only tables with a year of old rows are
+// retention candidates with their estimated space, old partitions get detach
+// DDL, and tables with updates or little old data are left out.
+func TestRetention(t *testing.T) {
+	now := time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC)
+	years := func(from int) []time.Time {
+		var out []time.Time
+		for y := from; y <= 2023; y++ {
+			out = append(out, time.Date(y, 6, 1, 0, 0, 0, 0, time.UTC))
+		}
+		return out
+	}
+	res := collect.Result{RetentionTables: []collect.RetentionTable{
+		// 4 of 5 yearly buckets are over a year old
+		{Database: "app", Schema: "public", Table: "events", Column: "created_at", SizeBytes: 100 << 30, Inserts: 1e6, Updates: 100, Bounds: years(2018), CheckedAt: now},
+		{Database: "app", Schema: "public", Table: "logs_2021", Parent: "logs", Column: "logged_at", SizeBytes: 20 << 30, Inserts: 1e6,
+			Bounds: []time.Time{time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2021, 12, 31, 0, 0, 0, 0, time.UTC)}, CheckedAt: now},
+		{Database: "app", Schema: "public", Table: "orders", Column: "created_at", SizeBytes: 100 << 30, Inserts: 1e6, Updates: 5e5, Bounds: years(2018), CheckedAt: now},
+		{Database: "app", Schema: "public", Table: "recent", Column: "created_at", SizeBytes: 1 << 30, Inserts: 1e6, Bounds: years(2021), CheckedAt: now},
+	}}
+	a := Run(res)
+	var got *Finding
+	for i := range a.Recommendations {
+		if a.Recommendations[i].Code == "retention-candidates" {
+			got = &a.Recommendations[i]
+		}
+	}
+	if got == nil {
+		t.Fatal("expected a retention-candidates recommendation")
+	}
+	text := got.Description + " " + got.Action
+	for _, want := range []string{
+		"hold ~100.0 GB of rows older than 365 days",
+		"public.events: 80% of rows (~80.0 GB of 100.0 GB) older than 365 days by created_at, oldest 2018-06-01",
+		"ALTER TABLE public.logs DETACH PARTITION public.logs_2021 CONCURRENTLY;",
+		"partition public.events by RANGE (created_at)",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("finding missing %q: %s", want, text)
+		}
+	}
+	for _, unwanted := range []string{"orders", "recent"} {
+		if strings.Contains(text, unwanted) {
+			t.Errorf("finding should not list %s: %s", unwanted, text)
+		}
+	}
+}
+
+// TestClientFingerprints verifies outdated drivers, old TLS, unencrypted
+// remote clients and mixed library versions are flagged.
+func TestClientFingerprints(t *testing.T) {
+	res := collect.Result{ClientFingerprints: []collect.ClientFingerprint{
+		{Application: "PostgreSQL JDBC Driver 9.4.1208", Library: "PostgreSQL JDBC Driver", Version: "9.4.1208", SSL: true, SSLVersion: "TLSv1.3", Count: 40},
+		{Application: "PostgreSQL JDBC Driver 42.7.3", Library: "PostgreSQL JDBC Driver", Version: "42.7.3", SSL: true, SSLVersion: "TLSv1.3", Count: 10},
+		{Application: "pgAdmin III - Query Tool", SSL: true, SSLVersion: "TLSv1", Count: 1},
+		{Application: "billing/1.10.0", Library: "billing", Version: "1.10.0", Count: 5},
+		{Application: "billing/1.9.2", Library: "billing", Version: "1.9.2", Count: 5},
+		{Application: "psql", Local: true, Count: 1},
+	}}
+	res.ConnInfo.SSL = "on"
+	a := Run(res)
+	find := func(list []Finding, code string) string {
+		for _, f := range list {
+			if f.Code == code {
+				return f.Description + " " + f.Action
+			}
+		}
+		t.Fatalf("expected a %s finding", code)
+		return ""
+	}
+	for code, want := range map[string][]string{
+		"client-outdated-driver": {"PostgreSQL JDBC Driver 9.4.1208 (40 connections)", "pgAdmin III - Query Tool (1 connections)"},
+		"client-tls-outdated":    {"pgAdmin III - Query Tool (1 connections, TLSv1)"},
+		"client-unencrypted":     {"billing/1.10.0 (5 connections)", "billing/1.9.2 (5 connections)"},
+	} {
+		text := find(a.Recommendations, code)
+		for _, w := range want {
+			if !strings.Contains(text, w) {
+				t.Errorf("%s missing %q: %s", code, w, text)
+			}
+		}
+		if strings.Contains(text, "42.7.3") || strings.Contains(text, "psql") {
+			t.Errorf("%s should not list current or local clients: %s", code, text)
+		}
+	}
+	text := find(a.Infos, "client-versions-mixed")
+	for _, w := range []string{"PostgreSQL JDBC Driver 9.4.1208, 42.7.3", "billing 1.9.2, 1.10.0"} {
+		if !strings.Contains(text, w) {
+			t.Errorf("client-versions-mixed missing %q: %s", w, text)
+		}
+	}
+}
+
+// TestRoleIdleTimeouts verifies idle timeouts are sized per role from the
+// observed idle times and land in the tuning script, skipping configured
+// timeouts and pooler roles.
+func TestRoleIdleTimeouts(t *testing.T) {
+	res := collect.Result{RoleIdle: []collect.RoleIdle{
+		// Transactions idle up to 4 minutes, sessions leaked for hours
+		{Role: "app", Application: "api", Sessions: 30, Idle: 20, IdleMedianSeconds: 300, IdleMaxSeconds: 6 * 3600, IdleInTx: 2, IdleInTxMaxSeconds: 240, HasIdleSessionTimeout: true},
+		{Role: "app", Application: "worker", Sessions: 5, Idle: 5, IdleMedianSeconds: 60, IdleMaxSeconds: 600, HasIdleSessionTimeout: true},
+		// Already configured
+		{Role: "etl", Sessions: 3, Idle: 3, IdleMaxSeconds: 8 * 3600, IdleInTxTimeout: "10min", IdleSessionTimeout: "1h", HasIdleSessionTimeout: true},
+		// Pooler keeps idle server connections on purpose
+		{Role: "pgbouncer", Application: "pgbouncer", Sessions: 20, Idle: 20, IdleMedianSeconds: 3600, IdleMaxSeconds: 5 * 3600, HasIdleSessionTimeout: true},
+		{Role: "Report User", Sessions: 1, Idle: 1, IdleMaxSeconds: 10},
+	}}
+	a := Run(res)
+	want := []string{
+		"ALTER ROLE app SET idle_in_transaction_session_timeout = '9min';",
+		"ALTER ROLE app SET idle_session_timeout = '21min';",
+		"ALTER ROLE pgbouncer SET idle_in_transaction_session_timeout = '5min';",
+		`ALTER ROLE "Report User" SET idle_in_transaction_session_timeout = '5min';`,
+	}
+	if !slices.Equal(a.Tuning, want) {
+		t.Fatalf("tuning script:\n%s\nexpected:\n%s", strings.Join(a.Tuning, "\n"), strings.Join(want, "\n"))
+	}
+	var got *Finding
+	for i := range a.Recommendations {
+		if a.Recommendations[i].Code == "role-idle-timeouts" {
+			got = &a.Recommendations[i]
+		}
+	}
+	if got == nil {
+		t.Fatal("expected a role-idle-timeouts recommendation")
+	}
+	if !strings.Contains(got.Description, "app (35 sessions from api, worker; 2 idle in transaction, longest 4m; idle for 5m at the median, longest 6h)") {
+		t.Errorf("unexpected description: %s", got.Description)
+	}
+}
+
+// TestRoleLimits verifies ad-hoc and unused login roles without limits get
+// safeguards while application, replication and limited roles do not.
+func TestRoleLimits(t *testing.T) {
+	res := collect.Result{
+		LoginRoles: []collect.LoginRole{
+			{Name: "alice", ConnLimit: -1},
+			{Name: "app", ConnLimit: -1},
+			{Name: "batch", ConnLimit: -1},
+			{Name: "limited", ConnLimit: 3, StatementTimeout: "1min"},
+			{Name: "postgres", Superuser: true, ConnLimit: -1},
+			{Name: "replicator", Replication: true, ConnLimit: -1},
+		},
+		ConnectionsByClient: []collect.ClientConn{
+			{Address: "10.0.0.5", User: "alice", Application: "DBeaver 23.1.0 - Main", Count: 2},
+			{Address: "10.0.0.7", User: "app", Application: "api", Count: 20},
+			{Address: "local", User: "postgres", Application: "psql", Count: 1},
+		},
+	}
+	a := Run(res)
+	want := []string{
+		"ALTER ROLE alice CONNECTION LIMIT 5;",
+		"ALTER ROLE alice SET statement_timeout = '5min';",
+		"ALTER ROLE batch CONNECTION LIMIT 5;",
+		"ALTER ROLE batch SET statement_timeout = '5min';",
+		"ALTER ROLE postgres SET statement_timeout = '5min';",
+	}
+	var got []string
+	for _, stmt := range a.Tuning {
+		if !strings.Contains(stmt, "idle_") {
+			got = append(got, stmt)
+		}
+	}
+	if !slices.Equal(got, want) {
+		t.Fatalf("tuning script:\n%s\nexpected:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	for _, f := range a.Recommendations {
+		if f.Code == "roles-without-limits" {
+			for _, w := range []string{"alice (connects with DBeaver 23.1.0 - Main: no connection limit, no statement_timeout)", "batch (not connected now", "postgres (superuser, connects with psql: no statement_timeout)", "Application roles without limits (app)"} {
+				if !strings.Contains(f.Description, w) {
+					t.Errorf("description missing %q: %s", w, f.Description)
+				}
+			}
+			return
+		}
+	}
+	t.Fatal("expected a roles-without-limits recommendation")
+}
+
+// TestPasswords verifies MD5 hashes, an MD5 password_encryption and cleartext
+// pg_hba.conf methods are flagged, and md5 lines once every hash is SCRAM.
+func TestPasswords(t *testing.T) {
+	res := collect.Result{
+		LoginRoles: []collect.LoginRole{
+			{Name: "app", ConnLimit: 10, StatementTimeout: "30s", Password: "md5"},
+			{Name: "alice", ConnLimit: 10, StatementTimeout: "30s", Password: "scram-sha-256"},
+		},
+		PasswordHBARules: []collect.HBARule{
+			{Line: 90, Type: "host", Database: "all", User: "all", Address: "10.0.0.0", Method: "md5"},
+			{Line: 91, Type: "host", Database: "app", User: "legacy", Address: "10.1.0.0", Method: "password"},
+			{Line: 92, Type: "hostssl", Database: "app", User: "ldap", Address: "10.2.0.0", Method: "password"},
+		},
+	}
+	res.ConnInfo.PasswordEncryption = "md5"
+	a := Run(res)
+	text := map[string]string{}
+	for _, f := range append(a.Warnings, a.Recommendations...) {
+		text[f.Code] = f.Description + " " + f.Action
+	}
+	for code, want := range map[string][]string{
+		"md5-passwords":          {"1 login roles still have MD5 password hashes: app.", "password_encryption = md5", "md5 pg_hba.conf entries (line 90 (host all all 10.0.0.0))"},
+		"hba-cleartext-password": {"line 91 (host app legacy 10.1.0.0)"},
+	} {
+		for _, w := range want {
+			if !strings.Contains(text[code], w) {
+				t.Errorf("%s missing %q: %s", code, w, text[code])
+			}
+		}
+	}
+	if strings.Contains(text["hba-cleartext-password"], "line 92") {
+		t.Errorf("hostssl line should not be flagged: %s", text["hba-cleartext-password"])
+	}
+	if _, ok := text["hba-md5-method"]; ok {
+		t.Error("hba-md5-method should wait until no MD5 hash is left")
+	}
+
+	res.LoginRoles[0].Password = "scram-sha-256"
+	res.ConnInfo.PasswordEncryption = "scram-sha-256"
+	a = Run(res)
+	var codes []string
+	for _, f := range append(a.Warnings, a.Recommendations...) {
+		codes = append(codes, f.Code)
+	}
+	if slices.Contains(codes, "md5-passwords") || !slices.Contains(codes, "hba-md5-method") {
+		t.Errorf("after the SCRAM migration expected hba-md5-method only, got %v", codes)
+	}
+}
+
+// TestPgAudit verifies pgaudit.log parsing and the coverage, gap and not
+// loaded findings.
+func TestPgAudit(t *testing.T) {
+	for val, want := range map[string]string{
+		"ddl, role":      "ddl,role",
+		"all, -misc":     "ddl,function,misc_set,read,role,write",
+		"write,none,DDL": "ddl",
+		"":               "",
+	} {
+		var got []string
+		for c := range parsePgAuditLog(val) {
+			got = append(got, c)
+		}
+		slices.Sort(got)
+		if strings.Join(got, ",") != want {
+			t.Errorf("parsePgAuditLog(%q) = %v, expected %s", val, got, want)
+		}
+	}
+
+	has := func(list []Finding, code string) bool {
+		return slices.ContainsFunc(list, func(f Finding) bool { return f.Code == code })
+	}
+	exts := []collect.ExtensionStat{{Database: "app", Name: "pgaudit"}}
+	a := Run(collect.Result{ExtensionStats: exts})
+	if !has(a.Warnings, "pgaudit-not-loaded") {
+		t.Error("expected pgaudit-not-loaded when the library is not preloaded")
+	}
+
+	res := collect.Result{ExtensionStats: exts, Settings: []collect.Setting{
+		{Name: "shared_preload_libraries", Val: "pg_stat_statements,pgaudit"},
+		{Name: "pgaudit.log", Val: "write"},
+	}}
+	a = Run(res)
+	var gap, coverage string
+	for _, f := range a.Recommendations {
+		if f.Code == "pgaudit-gaps" {
+			gap = f.Description + " " + f.Action
+		}
+	}
+	for _, f := range a.Infos {
+		if f.Code == "pgaudit-coverage" {
+			coverage = f.Description
+		}
+	}
+	if !strings.Contains(coverage, "audits WRITE; not audited: READ, FUNCTION, ROLE, DDL, MISC, MISC_SET") {
+		t.Errorf("unexpected coverage: %s", coverage)
+	}
+	if !strings.Contains(gap, "does not audit DDL and ROLE statements") || !strings.Contains(gap, "ALTER SYSTEM SET pgaudit.log = 'write, ddl, role';") {
+		t.Errorf("unexpected gap finding: %s", gap)
+	}
+	if !slices.Contains(a.Tuning, "ALTER SYSTEM SET pgaudit.log = 'write, ddl, role';") {
+		t.Errorf("tuning script misses the pgaudit.log change: %v", a.Tuning)
+	}
+
+	res.Settings[1].Val = "none"
+	if a = Run(res); !has(a.Warnings, "pgaudit-inactive") {
+		t.Error("expected pgaudit-inactive with pgaudit.log = none")
+	}
+}
+
+// TestTemplates verifies template1 objects, PUBLIC default privileges and
+// databases with a locale other than template1's are flagged.
+func TestTemplates(t *testing.T) {
+	res := collect.Result{
+		Template1: &collect.Template1{Encoding: "UTF8", Collate: "en_US.UTF-8", Ctype: "en_US.UTF-8",
+			Objects: []string{"table public.users"}, Extensions: []string{"postgis"}},
+		DefaultPrivileges: []collect.DefaultPrivilege{
+			{Role: "app", Schema: "public", ObjectType: "tables", ACL: "=r/app,app=arwdDxt/app"},
+			{Role: "app", ObjectType: "functions", ACL: "=X/app"},
+			{Role: "app", Schema: "billing", ObjectType: "tables", ACL: "readers=r/app"},
+		},
+		DBs: []collect.Database{
+			{Name: "app", Encoding: "UTF8", Collate: "en_US.UTF-8", Ctype: "en_US.UTF-8"},
+			{Name: "legacy", Encoding: "LATIN1", Collate: "C", Ctype: "C"},
+		},
+	}
+	a := Run(res)
+	text := map[string]string{}
+	for _, f := range a.Recommendations {
+		text[f.Code] = f.Description
+	}
+	for code, want := range map[string]string{
+		"template1-objects":         "template1 holds 1 objects (table public.users) and extensions postgis",
+		"default-privileges-public": "readable by every role: tables created by app in public.",
+		"database-locale-mismatch":  "legacy (encoding LATIN1, collation C, ctype C) differ from the cluster default",
+	} {
+		if !strings.Contains(text[code], want) {
+			t.Errorf("%s: expected %q in %q", code, want, text[code])
+		}
+	}
+	for _, unwanted := range []string{"functions", "billing"} {
+		if strings.Contains(text["default-privileges-public"], unwanted) {
+			t.Errorf("default-privileges-public should not list %s: %s", unwanted, text["default-privileges-public"])
+		}
+	}
+	if strings.Contains(text["database-locale-mismatch"], "app (") {
+		t.Errorf("database-locale-mismatch lists a default database: %s", text["database-locale-mismatch"])
+	}
+}
+
+// TestDatabaseLocales verifies SQL_ASCII databases and applications spanning
+// collations are flagged, leaving out interactive clients.
+func TestDatabaseLocales(t *testing.T) {
+	res := collect.Result{DBs: []collect.Database{
+		{Name: "orders", Encoding: "UTF8", Collate: "en_US.UTF-8", Ctype: "en_US.UTF-8", LocaleProvider: "libc", Applications: []string{"shop", "psql"}},
+		{Name: "catalog", Encoding: "UTF8", Collate: "C", Ctype: "C", LocaleProvider: "icu", Locale: "und", Applications: []string{"shop", "psql"}},
+		{Name: "legacy", Encoding: "SQL_ASCII", Collate: "C", Ctype: "C", LocaleProvider: "libc", Applications: []string{"reports"}},
+	}}
+	a := Run(res)
+	var ascii, mixed string
+	for _, f := range a.Warnings {
+		if f.Code == "sql-ascii-database" {
+			ascii = f.Description
+		}
+	}
+	for _, f := range a.Recommendations {
+		if f.Code == "mixed-collations" {
+			mixed = f.Description
+		}
+	}
+	if !strings.HasPrefix(ascii, "legacy use the SQL_ASCII encoding") {
+		t.Errorf("unexpected sql-ascii-database: %q", ascii)
+	}
+	if !strings.Contains(mixed, "shop uses orders (en_US.UTF-8) and catalog (icu und)") {
+		t.Errorf("unexpected mixed-collations: %q", mixed)
+	}
+	if strings.Contains(mixed, "psql") || strings.Contains(mixed, "reports") {
+		t.Errorf("mixed-collations lists interactive or single-collation clients: %q", mixed)
+	}
+}
+
+// TestRateFindings verifies confidence drops with young statistics, small
+// samples, missing plans and stale planner statistics, and impact follows the
+// share of query time or the size of the objects.
+func TestRateFindings(t *testing.T) {
+	res := collect.Result{
+		ConnInfo: collect.ConnInfo{CurrentDB: "app"},
+		Statements: collect.Statements{
+			StatsDuration: 3 * time.Hour,
+			TopByTotalTime: []collect.Statement{
+				{QueryID: 1, Calls: 5000, TotalTime: 900, Advice: &collect.PlanAdvice{Plan: "Seq Scan on orders"}},
+				{QueryID: 2, Calls: 40, TotalTime: 100},
+			},
+		},
+		Tables:           []collect.TableStat{{Database: "app", Schema: "public", Name: "orders", SizeBytes: 20 << 30}},
+		SessionStats:     []collect.SessionStat{{Datname: "app", WindowSeconds: 30 * 24 * 3600}},
+		StaleStatsTables: []collect.StaleStatsTable{{Schema: "public", Table: "events", DaysSinceAnalyze: 12}},
+	}
+	list := []Finding{
+		{Severity: SeverityRec, Evidence: &Evidence{QueryIDs: []int64{1}}},
+		{Severity: SeverityRec, Evidence: &Evidence{QueryIDs: []int64{2}}},
+		{Severity: SeverityWarning, Evidence: &Evidence{Objects: []Object{{Kind: "table", Database: "app", Schema: "public", Name: "orders"}}}},
+		{Severity: SeverityRec, Evidence: &Evidence{Objects: []Object{{Kind: "table", Schema: "public", Name: "events", Metrics: map[string]float64{"size_bytes": 2 << 30}}}}},
+		{Severity: SeverityRec, Evidence: settingsEvidence(nil, "work_mem")},
+	}
+	rateFindings(list, res, options{})
+	expected := []struct{ confidence, impact, note string }{
+		{LevelMedium, LevelHigh, "pg_stat_statements covers only 3h"},
+		{LevelLow, LevelMedium, "pg_stat_statements covers only 3h; 40 calls; no EXPLAIN plan"},
+		{LevelHigh, LevelHigh, ""},
+		{LevelMedium, LevelMedium, "planner statistics of public.events are 12 days old"},
+		{LevelHigh, LevelMedium, ""},
+	}
+	for i, e := range expected {
+		f := list[i]
+		if f.Confidence != e.confidence || f.EstimatedImpact != e.impact || f.ConfidenceNote != e.note {
+			t.Errorf("finding %d: confidence %s, impact %s, note %q; expected %s, %s, %q", i, f.Confidence, f.EstimatedImpact, f.ConfidenceNote, e.confidence, e.impact, e.note)
+		}
+	}
+
+	rateFindings(list[:1], res, options{deterministic: true})
+	if list[0].Confidence != LevelHigh {
+		t.Errorf("deterministic confidence = %s, expected the statistics age ignored", list[0].Confidence)
+	}
+}
+
+// TestFindingEffort verifies the effort classes and the quadrant they put a
+// finding in together with its impact.
+func TestFindingEffort(t *testing.T) {
+	tests := []struct {
+		f               Finding
+		level, kind, qd string
+	}{
+		{Finding{Action: "Raise work_mem.", Evidence: settingsEvidence(nil, "work_mem"), EstimatedImpact: LevelHigh}, LevelLow, effortConfig, QuadrantQuickWins},
+		{Finding{Action: "Create indexes on selective predicates.", Evidence: &Evidence{QueryIDs: []int64{1}}, EstimatedImpact: LevelMedium}, LevelMedium, effortIndex, QuadrantMajor},
+		{Finding{Action: "Review the statement.", Evidence: &Evidence{QueryIDs: []int64{1}}, EstimatedImpact: LevelHigh}, LevelHigh, effortRefactor, QuadrantMajor},
+		{Finding{Action: "Consider partitioning by month.", Evidence: &Evidence{Objects: []Object{{Kind: "table", Name: "events"}}}, EstimatedImpact: LevelLow}, LevelHigh, effortRefactor, QuadrantLowValue},
+		{Finding{Action: "Run VACUUM.", Evidence: &Evidence{Objects: []Object{{Kind: "table", Name: "events"}}}, EstimatedImpact: LevelMedium}, LevelMedium, effortMaintenance, QuadrantMajor},
+	}
+	for i, tt := range tests {
+		tt.f.Effort, tt.f.EffortKind = findingEffort(tt.f)
+		if tt.f.Effort != tt.level || tt.f.EffortKind != tt.kind || tt.f.Quadrant() != tt.qd {
+			t.Errorf("%d: effort %s (%s), quadrant %s; expected %s (%s), %s", i, tt.f.Effort, tt.f.EffortKind, tt.f.Quadrant(), tt.level, tt.kind, tt.qd)
+		}
+	}
+	if q := (Finding{Severity: SeverityInfo}).Quadrant(); q != "" {
+		t.Errorf("unrated finding quadrant = %q", q)
+	}
+}
+
+// TestSummarize verifies the five summary bullets follow the score, the
+// impact ranking and the quadrants, and compare with the previous run.
+func TestSummarize(t *testing.T) {
+	a := Analysis{
+		Warnings: []Finding{
+			{Title: "Replication slot retains WAL", Code: "slot-lag", Severity: SeverityWarning, EstimatedImpact: LevelMedium, Effort: LevelMedium},
+			{Title: "Transaction ID wraparound near", Code: "xid", Severity: SeverityWarning, EstimatedImpact: LevelHigh, Effort: LevelMedium},
+		},
+		Recommendations: []Finding{
+			{Title: "Raise work_mem", Code: "work-mem", Severity: SeverityRec, EstimatedImpact: LevelMedium, Effort: LevelLow},
+			{Title: "Vacuum audit_log", Code: "bloat", Severity: SeverityRec, EstimatedImpact: LevelLow, Effort: LevelMedium},
+		},
+	}
+	got := Summarize(a, nil)
+	expected := []string{
+		"Overall grade C (72/100): 2 warnings and 2 recommendations.",
+		"Biggest risks: Transaction ID wraparound near; Replication slot retains WAL.",
+		"Quick wins (configuration changes): Raise work_mem.",
+		"Largest project: Transaction ID wraparound near (2 major projects in total).",
+		"Trend: no earlier run to compare with; archive runs with -archive to follow it.",
+	}
+	if !slices.Equal(got, expected) {
+		t.Errorf("Summarize = %q\nexpected %q", got, expected)
+	}
+
+	prev := &Previous{
+		StartedAt: time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC),
+		Findings: []Finding{
+			{Title: "Raise work_mem", Code: "work-mem", Severity: SeverityRec},
+			{Title: "Unused indexes", Code: "unused-indexes", Severity: SeverityRec},
+			{Title: "Server uptime", Severity: SeverityInfo},
+		},
+	}
+	got = Summarize(a, prev)
+	if want := "Trend since 2024-01-15 10:30 UTC: worsening, grade A (96) → C (72); 3 new findings, 1 resolved."; got[4] != want {
+		t.Errorf("trend = %q, expected %q", got[4], want)
+	}
+	if s := Summarize(Analysis{}, nil); s[0] != "Overall grade A (100/100): 0 warnings and 0 recommendations." || !strings.HasPrefix(s[1], "Biggest risks: none") {
+		t.Errorf("empty analysis summary = %q", s)
+	}
+}
+
+// TestRunbookLinks verifies runbook templates with and without the code
+// placeholder and the public documentation per code and code family.
+func TestRunbookLinks(t *testing.T) {
+	tests := []struct{ tmpl, code, want string }{
+		{"https://wiki.example.com/pg/{code}", "unused-indexes", "https://wiki.example.com/pg/unused-indexes"},
+		{"https://wiki.example.com/pg/{code}.md", "ssl-off", "https://wiki.example.com/pg/ssl-off.md"},
+		{"https://wiki.example.com/pg/", "ssl-off", "https://wiki.example.com/pg/ssl-off"},
+		{"", "ssl-off", ""},
+		{"https://wiki.example.com/pg/{code}", "", ""},
+	}
+	for _, tt := range tests {
+		if got := RunbookURL(tt.tmpl, tt.code); got != tt.want {
+			t.Errorf("RunbookURL(%q, %q) = %q, want %q", tt.tmpl, tt.code, got, tt.want)
+		}
+	}
+
+	a := LinkRunbooks(Analysis{Warnings: []Finding{{Code: "xid-age-warning"}}}, "https://wiki.example.com/{code}")
+	if got := a.Warnings[0].Runbook; got != "https://wiki.example.com/xid-age-warning" {
+		t.Errorf("runbook = %q", got)
+	}
+
+	docs := map[string]string{
+		"unused-indexes":           pgDocs + "monitoring-stats.html#MONITORING-PG-STAT-ALL-INDEXES-VIEW",
+		"xid-wraparound-forecast":  pgDocs + "routine-vacuuming.html#VACUUM-FOR-WRAPAROUND",
+		"patroni-paused":           "https://patroni.readthedocs.io/en/latest/",
+		"standby-conflict-lock":    pgDocs + "hot-standby.html#HOT-STANDBY-CONFLICT",
+		"slo-breached":             "",
+		"not-a-known-finding-code": "",
+	}
+	for code, want := range docs {
+		if got := DocsURL(code); got != want {
+			t.Errorf("DocsURL(%q) = %q, want %q", code, got, want)
+		}
+	}
+}
+
+// TestAnalyzeTrends verifies the comparison with earlier runs: a falling cache
+// hit ratio, regressed statements, fast growing relations and connections,
+// and no trend findings without history.
+func TestAnalyzeTrends(t *testing.T) {
+	var res collect.Result
+	res.CacheHitCurrent = 96.5
+	res.Statements.TopByTotalTime = []collect.Statement{
+		{QueryID: 1, Query: "SELECT * FROM orders WHERE id = $1", Calls: 5000, MeanTime: 30},
+		{QueryID: 2, Query: "SELECT 1", Calls: 5000, MeanTime: 0.4},
+		{QueryID: 3, Query: "SELECT * FROM audit", Calls: 10, MeanTime: 900},
+	}
+	growth := []collect.SizeGrowth{
+		{Database: "app", Schema: "public", Name: "events", Bytes: 6 << 30, StartBytes: 2 << 30, Runs: 5, Days: 10},
+		{Database: "app", Schema: "public", Name: "users", Bytes: 3 << 30, StartBytes: 2900 << 20, Runs: 5, Days: 10},
+		{Database: "app", Bytes: 100 << 30, StartBytes: 40 << 30, Runs: 5, Days: 10},
+	}
+	conns := &collect.ConnectionHistory{Total: collect.ConnectionTrend{Current: 90, Peak: 95, PerDay: 5, Runs: 5, Days: 10}}
+	// The forecast fits span their own, longer windows and do not count as trends
+	res.SizeGrowth = growth
+	res.ConnectionHistory = conns
+
+	history := []collect.Result{{CacheHitCurrent: 99.1}, {CacheHitCurrent: 99.3}, {CacheHitCurrent: 98.9}}
+	for i := range history {
+		history[i].Statements.TopByTotalTime = []collect.Statement{
+			{QueryID: 1, Calls: 4000, MeanTime: 8},
+			{QueryID: 2, Calls: 4000, MeanTime: 0.1},
+			{QueryID: 3, Calls: 8, MeanTime: 100},
+		}
+	}
+
+	codes := func(a Analysis) map[string]Finding {
+		out := map[string]Finding{}
+		for _, f := range a.Recommendations {
+			out[f.Code] = f
+		}
+		return out
+	}
+	got := codes(Run(res, History(history, growth, conns)))
+	if f, ok := got["cache-hit-trend"]; !ok || f.Evidence.Metrics["previous_median_pct"] != 99.1 {
+		t.Errorf("cache-hit-trend = %+v", f)
+	}
+	// A 4× slower statement regressed; a fast one (+0.3ms) and a rarely
+	// called one are left out
+	if f, ok := got["query-regression"]; !ok || !slices.Equal(f.Evidence.QueryIDs, []int64{1}) {
+		t.Errorf("query-regression = %+v", f)
+	}
+	if f, ok := got["relation-growth"]; !ok || len(f.Evidence.Objects) != 1 || f.Evidence.Objects[0].Name != "events" {
+		t.Errorf("relation-growth = %+v", f)
+	}
+	if f, ok := got["connection-growth"]; !ok || f.Evidence.Metrics["connections_per_day"] != 5 {
+		t.Errorf("connection-growth = %+v", f)
+	}
+
+	for code := range codes(Run(res)) {
+		switch code {
+		case "cache-hit-trend", "query-regression", "relation-growth", "connection-growth":
+			t.Errorf("%s reported without history", code)
+		}
+	}
+	for code := range codes(Run(res, History(history, nil, nil))) {
+		switch code {
+		case "relation-growth", "connection-growth":
+			t.Errorf("%s reported from the forecast fits", code)
+		}
+	}
+}
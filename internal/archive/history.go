@@ -0,0 +1,774 @@
+package archive
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/koltyakov/pghealth/internal/analyze"
+	"github.com/koltyakov/pghealth/internal/collect"
+)
+
+// QueryPoint is one archived observation of a query.
+type QueryPoint struct {
+	RunID     string
+	Calls     float64
+	TotalTime float64
+	MeanTime  float64
+}
+
+// queryHistoryTables are the archived statement lists searched for history.
+var queryHistoryTables = []string{"statements_top_by_total_time", "statements_top_by_calls"}
+
+// QueryHistory returns up to limit most recent observations per statement in
+// runs of target that started before runID, newest first, keyed by
+// collect.Statement.Key. Statements are matched by queryid when known and by
+// query text otherwise (e.g. archives written before queryid was collected).
+// A missing archive file, or one without targets, yields no history.
+func QueryHistory(ctx context.Context, path, target, runID string, stmts []collect.Statement, limit int) (map[string][]QueryPoint, error) {
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("open archive: %w", err)
+	}
+	defer db.Close()
+
+	cols, err := tableColumns(ctx, db, "runs")
+	if err != nil {
+		return nil, fmt.Errorf("inspect archive: %w", err)
+	}
+	if !cols["target"] {
+		return nil, nil
+	}
+
+	var byID, byText []string
+	for _, t := range queryHistoryTables {
+		cols, err := tableColumns(ctx, db, t)
+		if err != nil {
+			return nil, fmt.Errorf("inspect archive: %w", err)
+		}
+		if !cols["query"] {
+			continue
+		}
+		sel := fmt.Sprintf("SELECT run_id, calls, total_time, mean_time FROM %s WHERE run_id IN (%s) AND ", quoteIdent(t), targetRuns)
+		byText = append(byText, sel+"query = ?3")
+		if cols["query_id"] {
+			byID = append(byID, sel+"query_id = ?3")
+		}
+	}
+	if len(byText) == 0 {
+		return nil, nil
+	}
+
+	out := make(map[string][]QueryPoint, len(stmts))
+	for _, st := range stmts {
+		key := st.Key()
+		if _, done := out[key]; done {
+			continue
+		}
+		parts, arg := byText, any(st.Query)
+		if st.QueryID != 0 && len(byID) > 0 {
+			parts, arg = byID, any(st.QueryID)
+		}
+		points, err := queryPoints(ctx, db, strings.Join(parts, " UNION ")+" ORDER BY run_id DESC LIMIT ?4", target, runID, arg, limit)
+		if err != nil {
+			return nil, err
+		}
+		out[key] = points
+	}
+	return out, nil
+}
+
+// XIDRates returns the XID consumption between the last limit archived runs
+// of target and the current reading, newest first. Pairs where the counter
+// went back (a restored or different cluster under the same target) are
+// skipped. A missing archive file, or one written before the XID counter was
+// collected, yields no rates.
+func XIDRates(ctx context.Context, path, target string, current collect.XIDClock, limit int) ([]collect.XIDRate, error) {
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("open archive: %w", err)
+	}
+	defer db.Close()
+
+	cols, err := tableColumns(ctx, db, "xid_clock")
+	if err != nil {
+		return nil, fmt.Errorf("inspect archive: %w", err)
+	}
+	if !cols["next_xid"] {
+		return nil, nil
+	}
+	rows, err := db.QueryContext(ctx, `SELECT x.next_xid, x.at FROM xid_clock x
+		JOIN runs r ON r.run_id = x.run_id
+		WHERE r.target = ?1 AND x.next_xid IS NOT NULL AND x.at IS NOT NULL
+		ORDER BY x.run_id DESC LIMIT ?2`, target, limit)
+	if err != nil {
+		return nil, fmt.Errorf("query XID history: %w", err)
+	}
+	defer rows.Close()
+
+	var rates []collect.XIDRate
+	next := current
+	for rows.Next() {
+		var c collect.XIDClock
+		var at string
+		if err := rows.Scan(&c.NextXID, &at); err != nil {
+			return nil, fmt.Errorf("scan XID history: %w", err)
+		}
+		if c.At, err = time.Parse(time.RFC3339Nano, at); err != nil {
+			continue
+		}
+		if r, ok := collect.NewXIDRate(c, next); ok {
+			rates = append(rates, r)
+			next = c
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("read XID history: %w", err)
+	}
+	return rates, nil
+}
+
+// Baseline is the part of an archived run that later runs are compared with.
+type Baseline struct {
+	RunID      string
+	StartedAt  time.Time
+	Findings   []analyze.Finding   // severity, code, title and evidence only
+	Tables     []collect.TableStat // database, schema, name and size only
+	Statements []collect.Statement // top statements by total time: id, query, calls and mean time
+}
+
+// PreviousRun loads the latest archived run of target that started before
+// runID. A missing archive file, or one without an earlier run of target,
+// yields nil.
+func PreviousRun(ctx context.Context, path, target, runID string) (*Baseline, error) {
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("open archive: %w", err)
+	}
+	defer db.Close()
+
+	cols, err := tableColumns(ctx, db, "runs")
+	if err != nil {
+		return nil, fmt.Errorf("inspect archive: %w", err)
+	}
+	if !cols["target"] {
+		return nil, nil
+	}
+	b := &Baseline{}
+	var started sql.NullString
+	err = db.QueryRowContext(ctx, `SELECT run_id, started_at FROM runs
+		WHERE target = ?1 AND run_id < ?2 ORDER BY run_id DESC LIMIT 1`, target, runID).Scan(&b.RunID, &started)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("query previous run: %w", err)
+	}
+	if b.StartedAt, err = time.Parse(time.RFC3339Nano, started.String); err != nil {
+		b.StartedAt = runStart(b.RunID)
+	}
+
+	// Archives written before findings carried evidence lack the column
+	if cols, err = tableColumns(ctx, db, "findings"); err != nil {
+		return nil, fmt.Errorf("inspect archive: %w", err)
+	}
+	evidence := "NULL"
+	if cols["evidence"] {
+		evidence = "evidence"
+	}
+	err = scanRows(ctx, db, func(rows *sql.Rows) error {
+		var f analyze.Finding
+		var code, ev sql.NullString
+		if err := rows.Scan(&f.Severity, &code, &f.Title, &ev); err != nil {
+			return err
+		}
+		f.Code = code.String
+		if ev.Valid {
+			f.Evidence = &analyze.Evidence{}
+			if err := json.Unmarshal([]byte(ev.String), f.Evidence); err != nil {
+				return fmt.Errorf("finding %s evidence: %w", f.Title, err)
+			}
+		}
+		b.Findings = append(b.Findings, f)
+		return nil
+	}, "SELECT severity, code, title, "+evidence+" FROM findings WHERE run_id = ?1 ORDER BY ord", b.RunID)
+	if err != nil {
+		return nil, fmt.Errorf("read previous findings: %w", err)
+	}
+
+	if cols, err := tableColumns(ctx, db, "tables"); err != nil {
+		return nil, fmt.Errorf("inspect archive: %w", err)
+	} else if cols["size_bytes"] {
+		err = scanRows(ctx, db, func(rows *sql.Rows) error {
+			var t collect.TableStat
+			var database sql.NullString
+			var size sql.NullInt64
+			if err := rows.Scan(&database, &t.Schema, &t.Name, &size); err != nil {
+				return err
+			}
+			t.Database, t.SizeBytes = database.String, size.Int64
+			b.Tables = append(b.Tables, t)
+			return nil
+		}, "SELECT database, schema, name, size_bytes FROM tables WHERE run_id = ?1", b.RunID)
+		if err != nil {
+			return nil, fmt.Errorf("read previous tables: %w", err)
+		}
+	}
+
+	if cols, err := tableColumns(ctx, db, "statements_top_by_total_time"); err != nil {
+		return nil, fmt.Errorf("inspect archive: %w", err)
+	} else if cols["mean_time"] {
+		id := "0"
+		if cols["query_id"] {
+			id = "query_id"
+		}
+		q := "SELECT " + id + ", query, calls, mean_time FROM statements_top_by_total_time WHERE run_id = ?1 ORDER BY ord"
+		err = scanRows(ctx, db, func(rows *sql.Rows) error {
+			var st collect.Statement
+			var qid sql.NullInt64
+			var calls, mean sql.NullFloat64
+			if err := rows.Scan(&qid, &st.Query, &calls, &mean); err != nil {
+				return err
+			}
+			st.QueryID, st.Calls, st.MeanTime = qid.Int64, calls.Float64, mean.Float64
+			b.Statements = append(b.Statements, st)
+			return nil
+		}, q, b.RunID)
+		if err != nil {
+			return nil, fmt.Errorf("read previous statements: %w", err)
+		}
+	}
+	return b, nil
+}
+
+// RecentResults loads up to limit archived runs of target that started
+// before runID, newest first. Only the fields service level objectives and
+// trends are measured on are filled: the cache hit ratios, the top statements
+// by total time (query id, text, calls and mean time) and the replication
+// lag. A missing archive file yields no runs.
+func RecentResults(ctx context.Context, path, target, runID string, limit int) ([]collect.Result, error) {
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("open archive: %w", err)
+	}
+	defer db.Close()
+
+	cols, err := tableColumns(ctx, db, "runs")
+	if err != nil {
+		return nil, fmt.Errorf("inspect archive: %w", err)
+	}
+	if !cols["target"] || limit <= 0 {
+		return nil, nil
+	}
+	var runIDs []string
+	err = scanRows(ctx, db, func(rows *sql.Rows) error {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return err
+		}
+		runIDs = append(runIDs, id)
+		return nil
+	}, "SELECT run_id FROM runs WHERE target = ?1 AND run_id < ?2 ORDER BY run_id DESC LIMIT ?3", target, runID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("query runs: %w", err)
+	}
+
+	resultCols, err := tableColumns(ctx, db, "result")
+	if err != nil {
+		return nil, fmt.Errorf("inspect archive: %w", err)
+	}
+	stmtCols, err := tableColumns(ctx, db, "statements_top_by_total_time")
+	if err != nil {
+		return nil, fmt.Errorf("inspect archive: %w", err)
+	}
+	replCols, err := tableColumns(ctx, db, "replication_stats")
+	if err != nil {
+		return nil, fmt.Errorf("inspect archive: %w", err)
+	}
+
+	out := make([]collect.Result, len(runIDs))
+	for i, id := range runIDs {
+		res := &out[i]
+		if resultCols["cache_hit_current"] && resultCols["cache_hit_overall"] {
+			err = scanRows(ctx, db, func(rows *sql.Rows) error {
+				var cur, overall sql.NullFloat64
+				if err := rows.Scan(&cur, &overall); err != nil {
+					return err
+				}
+				res.CacheHitCurrent, res.CacheHitOverall = cur.Float64, overall.Float64
+				return nil
+			}, "SELECT cache_hit_current, cache_hit_overall FROM result WHERE run_id = ?1", id)
+			if err != nil {
+				return nil, fmt.Errorf("read archived cache hit: %w", err)
+			}
+		}
+		if stmtCols["calls"] && stmtCols["mean_time"] {
+			qid := "0"
+			if stmtCols["query_id"] {
+				qid = "query_id"
+			}
+			err = scanRows(ctx, db, func(rows *sql.Rows) error {
+				var st collect.Statement
+				var id sql.NullInt64
+				var query sql.NullString
+				var calls, mean sql.NullFloat64
+				if err := rows.Scan(&id, &query, &calls, &mean); err != nil {
+					return err
+				}
+				st.QueryID, st.Query, st.Calls, st.MeanTime = id.Int64, query.String, calls.Float64, mean.Float64
+				res.Statements.TopByTotalTime = append(res.Statements.TopByTotalTime, st)
+				return nil
+			}, "SELECT "+qid+", query, calls, mean_time FROM statements_top_by_total_time WHERE run_id = ?1 ORDER BY ord", id)
+			if err != nil {
+				return nil, fmt.Errorf("read archived statements: %w", err)
+			}
+		}
+		if replCols["replay_lag"] {
+			err = scanRows(ctx, db, func(rows *sql.Rows) error {
+				var name, lag sql.NullString
+				if err := rows.Scan(&name, &lag); err != nil {
+					return err
+				}
+				res.ReplicationStats = append(res.ReplicationStats, collect.ReplicationStat{Name: name.String, ReplayLag: lag.String})
+				return nil
+			}, "SELECT name, replay_lag FROM replication_stats WHERE run_id = ?1 ORDER BY ord", id)
+			if err != nil {
+				return nil, fmt.Errorf("read archived replication: %w", err)
+			}
+		}
+	}
+	return out, nil
+}
+
+// SizeGrowth fits the growth of the databases, tables and indexes of res,
+// measured at at, over their sizes in the last limit archived runs of target
+// that started before runID. Objects without an archived size are left out. A
+// missing archive file, or one without earlier runs of target, yields no
+// growth.
+func SizeGrowth(ctx context.Context, path, target, runID string, res collect.Result, at time.Time, limit int) ([]collect.SizeGrowth, error) {
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("open archive: %w", err)
+	}
+	defer db.Close()
+
+	started, err := runStarts(ctx, db, target, runID, limit)
+	if err != nil || len(started) == 0 {
+		return nil, err
+	}
+
+	// Databases are archived as d_bs, the snake case of Result.DBs
+	dbSizes := map[string][]collect.SizePoint{}
+	if cols, err := tableColumns(ctx, db, "d_bs"); err != nil {
+		return nil, fmt.Errorf("inspect archive: %w", err)
+	} else if cols["size_bytes"] {
+		err = scanRows(ctx, db, func(rows *sql.Rows) error {
+			var id, name string
+			var size sql.NullInt64
+			if err := rows.Scan(&id, &name, &size); err != nil {
+				return err
+			}
+			if size.Int64 > 0 {
+				dbSizes[name] = append(dbSizes[name], collect.SizePoint{At: started[id], Bytes: size.Int64})
+			}
+			return nil
+		}, "SELECT run_id, name, size_bytes FROM d_bs WHERE run_id IN ("+recentRuns+")", target, runID, limit)
+		if err != nil {
+			return nil, fmt.Errorf("read archived database sizes: %w", err)
+		}
+	}
+
+	tableSizes, err := relationSizes(ctx, db, "tables", started, target, runID, limit)
+	if err != nil {
+		return nil, err
+	}
+	indexSizes, err := relationSizes(ctx, db, "indexes", started, target, runID, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	var out []collect.SizeGrowth
+	for _, d := range res.DBs {
+		if d.SizeBytes <= 0 || len(dbSizes[d.Name]) == 0 {
+			continue
+		}
+		if g, ok := collect.FitGrowth(append(dbSizes[d.Name], collect.SizePoint{At: at, Bytes: d.SizeBytes})); ok {
+			g.Database = d.Name
+			out = append(out, g)
+		}
+	}
+	for _, t := range res.Tables {
+		if t.SizeBytes <= 0 || len(tableSizes[t.ID()]) == 0 {
+			continue
+		}
+		if g, ok := collect.FitGrowth(append(tableSizes[t.ID()], collect.SizePoint{At: at, Bytes: t.SizeBytes})); ok {
+			g.Database, g.Schema, g.Name = t.Database, t.Schema, t.Name
+			out = append(out, g)
+		}
+	}
+	for _, ix := range res.Indexes {
+		if ix.SizeBytes <= 0 || len(indexSizes[ix.ID()]) == 0 {
+			continue
+		}
+		if g, ok := collect.FitGrowth(append(indexSizes[ix.ID()], collect.SizePoint{At: at, Bytes: ix.SizeBytes})); ok {
+			g.Database, g.Schema, g.Name, g.Index = ix.Database, ix.Schema, ix.Name, true
+			out = append(out, g)
+		}
+	}
+	return out, nil
+}
+
+// relationSizes reads the archived sizes of the tables or indexes (table) in
+// the recent runs, by qualified name; none when the archive predates sizes.
+func relationSizes(ctx context.Context, db *sql.DB, table string, started map[string]time.Time, target, runID string, limit int) (map[string][]collect.SizePoint, error) {
+	sizes := map[string][]collect.SizePoint{}
+	cols, err := tableColumns(ctx, db, table)
+	if err != nil {
+		return nil, fmt.Errorf("inspect archive: %w", err)
+	}
+	if !cols["size_bytes"] {
+		return sizes, nil
+	}
+	err = scanRows(ctx, db, func(rows *sql.Rows) error {
+		var id, schema, name string
+		var database sql.NullString
+		var size sql.NullInt64
+		if err := rows.Scan(&id, &database, &schema, &name, &size); err != nil {
+			return err
+		}
+		if size.Int64 > 0 {
+			key := collect.QualifiedName(database.String, schema, name)
+			sizes[key] = append(sizes[key], collect.SizePoint{At: started[id], Bytes: size.Int64})
+		}
+		return nil
+	}, "SELECT run_id, database, schema, name, size_bytes FROM "+table+" WHERE run_id IN ("+recentRuns+")", target, runID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("read archived sizes of %s: %w", table, err)
+	}
+	return sizes, nil
+}
+
+// ConnectionHistory summarizes the client connections of res, measured at at,
+// and in the last limit archived runs of target that started before runID:
+// in total and per application_name. It returns nil when res has no client
+// connections. A missing archive file, or one without earlier runs of
+// target, yields the current run alone.
+func ConnectionHistory(ctx context.Context, path, target, runID string, res collect.Result, at time.Time, limit int) (*collect.ConnectionHistory, error) {
+	if len(res.ConnectionsByClient) == 0 {
+		return nil, nil
+	}
+	// Points by run id, in total and per application: clients of one
+	// application from several hosts or users add up
+	total := map[string]collect.ConnectionPoint{}
+	apps := map[string]map[string]collect.ConnectionPoint{}
+	add := func(run, app string, when time.Time, count, idle int) {
+		if apps[app] == nil {
+			apps[app] = map[string]collect.ConnectionPoint{}
+		}
+		for _, m := range []map[string]collect.ConnectionPoint{total, apps[app]} {
+			p := m[run]
+			p.At, p.Count, p.Busy = when, p.Count+count, p.Busy+count-idle
+			m[run] = p
+		}
+	}
+
+	err := func() error {
+		if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		db, err := sql.Open("sqlite", path)
+		if err != nil {
+			return fmt.Errorf("open archive: %w", err)
+		}
+		defer db.Close()
+
+		started, err := runStarts(ctx, db, target, runID, limit)
+		if err != nil || len(started) == 0 {
+			return err
+		}
+		cols, err := tableColumns(ctx, db, "connections_by_client")
+		if err != nil {
+			return fmt.Errorf("inspect archive: %w", err)
+		}
+		if !cols["count"] {
+			return nil
+		}
+		// Archives written before idle connections were counted treat all as busy
+		idle := "0"
+		if cols["idle"] {
+			idle = "coalesce(idle, 0)"
+		}
+		return scanRows(ctx, db, func(rows *sql.Rows) error {
+			var id string
+			var app sql.NullString
+			var count, idle int
+			if err := rows.Scan(&id, &app, &count, &idle); err != nil {
+				return err
+			}
+			add(id, app.String, started[id], count, idle)
+			return nil
+		}, "SELECT run_id, application, \"count\", "+idle+" FROM connections_by_client WHERE run_id IN ("+recentRuns+")", target, runID, limit)
+	}()
+	if err != nil {
+		return nil, fmt.Errorf("read archived connections: %w", err)
+	}
+
+	current := RunID(at, target)
+	for _, c := range res.ConnectionsByClient {
+		add(current, c.Application, at, c.Count, c.Idle)
+	}
+	h := &collect.ConnectionHistory{Total: collect.FitConnections(byRun(total))}
+	for app, points := range apps {
+		// Applications that are gone now are left out
+		if _, ok := points[current]; !ok {
+			continue
+		}
+		tr := collect.FitConnections(byRun(points))
+		tr.Application = app
+		h.Apps = append(h.Apps, tr)
+	}
+	sort.Slice(h.Apps, func(i, j int) bool {
+		if h.Apps[i].Peak != h.Apps[j].Peak {
+			return h.Apps[i].Peak > h.Apps[j].Peak
+		}
+		return h.Apps[i].Application < h.Apps[j].Application
+	})
+	return h, nil
+}
+
+// byRun orders the points of runs keyed by run id oldest first, so the
+// current run is last.
+func byRun(points map[string]collect.ConnectionPoint) []collect.ConnectionPoint {
+	ids := make([]string, 0, len(points))
+	for id := range points {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	out := make([]collect.ConnectionPoint, len(ids))
+	for i, id := range ids {
+		out[i] = points[id]
+	}
+	return out
+}
+
+// statementTables are the archived top statement lists, by their order in
+// collect.Statements.
+var statementTables = []string{
+	"statements_top_by_total_time", "statements_top_by_cpu", "statements_top_by_calls",
+	"statements_top_by_io", "statements_top_by_io_blocks",
+}
+
+// statementCounters are the cumulative pg_stat_statements columns a statement
+// window subtracts.
+var statementCounters = []string{
+	"calls", "total_time", "rows", "shared_blks_read", "shared_blks_write", "temp_blks_write", "io_time",
+}
+
+// StatementDeltas measures the statement load of res, read at at, since the
+// newest archived run of target that started at least window before at, or
+// the oldest earlier run when none is that old. It keeps the limit statements
+// that took the most time. A missing archive file, one without earlier runs
+// of target, or one written before the statement counters were archived
+// yields nil.
+func StatementDeltas(ctx context.Context, path, target, runID string, res collect.Result, at time.Time, window time.Duration, limit int) (*collect.StatementWindow, error) {
+	if !res.Statements.Available {
+		return nil, nil
+	}
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("open archive: %w", err)
+	}
+	defer db.Close()
+
+	cols, err := tableColumns(ctx, db, "runs")
+	if err != nil {
+		return nil, fmt.Errorf("inspect archive: %w", err)
+	}
+	if !cols["target"] {
+		return nil, nil
+	}
+	// Run ids sort by start time, so the cutoff is a run id too
+	var baseID string
+	var started sql.NullString
+	err = db.QueryRowContext(ctx, `SELECT run_id, started_at FROM runs
+		WHERE target = ?1 AND run_id < ?2 AND run_id <= ?3
+		UNION ALL SELECT * FROM (SELECT run_id, started_at FROM runs
+			WHERE target = ?1 AND run_id < ?2 ORDER BY run_id LIMIT 1)
+		ORDER BY run_id DESC LIMIT 1`, target, runID, RunID(at.Add(-window), target)).Scan(&baseID, &started)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("query baseline run: %w", err)
+	}
+	from, err := time.Parse(time.RFC3339Nano, started.String)
+	if err != nil {
+		from = runStart(baseID)
+	}
+
+	var parts []string
+	for _, t := range statementTables {
+		cols, err := tableColumns(ctx, db, t)
+		if err != nil {
+			return nil, fmt.Errorf("inspect archive: %w", err)
+		}
+		complete := cols["query"]
+		for _, c := range statementCounters {
+			complete = complete && cols[c]
+		}
+		if !complete {
+			continue
+		}
+		id := "0"
+		if cols["query_id"] {
+			id = "query_id"
+		}
+		parts = append(parts, "SELECT "+id+", query, "+strings.Join(statementCounters, ", ")+" FROM "+quoteIdent(t)+" WHERE run_id = ?1")
+	}
+	if len(parts) == 0 {
+		return nil, nil
+	}
+	var base []collect.Statement
+	err = scanRows(ctx, db, func(rows *sql.Rows) error {
+		var st collect.Statement
+		var qid sql.NullInt64
+		var v [7]sql.NullFloat64
+		if err := rows.Scan(&qid, &st.Query, &v[0], &v[1], &v[2], &v[3], &v[4], &v[5], &v[6]); err != nil {
+			return err
+		}
+		st.QueryID = qid.Int64
+		st.Calls, st.TotalTime, st.Rows = v[0].Float64, v[1].Float64, v[2].Float64
+		st.SharedBlksRead, st.SharedBlksWrite, st.TempBlksWrite, st.IOTime = v[3].Float64, v[4].Float64, v[5].Float64, v[6].Float64
+		base = append(base, st)
+		return nil
+	}, strings.Join(parts, " UNION "), baseID)
+	if err != nil {
+		return nil, fmt.Errorf("read baseline statements: %w", err)
+	}
+	return collect.NewStatementWindow(base, from, res.Statements, at, limit), nil
+}
+
+// recentRuns selects the ids of the last ?3 runs of target ?1 started before
+// run ?2.
+const recentRuns = "SELECT run_id FROM runs WHERE target = ?1 AND run_id < ?2 ORDER BY run_id DESC LIMIT ?3"
+
+// targetRuns selects the ids of all runs of target ?1 started before run ?2.
+const targetRuns = "SELECT run_id FROM runs WHERE target = ?1 AND run_id < ?2"
+
+// runStarts returns the start times of the last limit runs of target that
+// started before runID, by run id; none for archives without targets.
+func runStarts(ctx context.Context, db *sql.DB, target, runID string, limit int) (map[string]time.Time, error) {
+	cols, err := tableColumns(ctx, db, "runs")
+	if err != nil {
+		return nil, fmt.Errorf("inspect archive: %w", err)
+	}
+	if !cols["target"] || limit <= 0 {
+		return nil, nil
+	}
+	started := map[string]time.Time{}
+	err = scanRows(ctx, db, func(rows *sql.Rows) error {
+		var id string
+		var at sql.NullString
+		if err := rows.Scan(&id, &at); err != nil {
+			return err
+		}
+		t, err := time.Parse(time.RFC3339Nano, at.String)
+		if err != nil {
+			t = runStart(id)
+		}
+		started[id] = t
+		return nil
+	}, "SELECT run_id, started_at FROM runs WHERE target = ?1 AND run_id < ?2 ORDER BY run_id DESC LIMIT ?3", target, runID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("query runs: %w", err)
+	}
+	return started, nil
+}
+
+// scanRows runs q with args and calls scan for every row.
+func scanRows(ctx context.Context, db *sql.DB, scan func(*sql.Rows) error, q string, args ...any) error {
+	rows, err := db.QueryContext(ctx, q, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		if err := scan(rows); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// queryPoints runs a history query, keeping one observation per run since the
+// same run may list a query by total time and by calls.
+func queryPoints(ctx context.Context, db *sql.DB, q string, args ...any) ([]QueryPoint, error) {
+	rows, err := db.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query history: %w", err)
+	}
+	defer rows.Close()
+
+	var points []QueryPoint
+	seen := map[string]bool{}
+	for rows.Next() {
+		var p QueryPoint
+		var calls, total, mean sql.NullFloat64
+		if err := rows.Scan(&p.RunID, &calls, &total, &mean); err != nil {
+			return nil, fmt.Errorf("scan history: %w", err)
+		}
+		if seen[p.RunID] {
+			continue
+		}
+		seen[p.RunID] = true
+		p.Calls, p.TotalTime, p.MeanTime = calls.Float64, total.Float64, mean.Float64
+		points = append(points, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("read history: %w", err)
+	}
+	return points, nil
+}
+
+// tableColumns returns the column names of an archive table; empty when the
+// table does not exist.
+func tableColumns(ctx context.Context, db *sql.DB, table string) (map[string]bool, error) {
+	rows, err := db.QueryContext(ctx, fmt.Sprintf("SELECT name FROM pragma_table_info(%s)", quoteLiteral(table)))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	cols := map[string]bool{}
+	for rows.Next() {
+		var n string
+		if err := rows.Scan(&n); err != nil {
+			return nil, err
+		}
+		cols[n] = true
+	}
+	return cols, rows.Err()
+}
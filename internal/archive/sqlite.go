@@ -0,0 +1,396 @@
+// Package archive writes the tabular data of runs into a local SQLite file for
+// ad-hoc analysis of historical health data (sqlite3, DuckDB, pandas, ...).
+//
+// Every slice in collect.Result becomes a table (tables, indexes,
+// statements_top_by_total_time, ...) and every nested struct a single-row table
+// (conn_info, checkpoint_stats, ...). All rows carry a run_id column holding
+// the run timestamp and target so runs can be joined and compared. Columns are derived from
+// the Go types and added automatically when newer versions collect more fields.
+package archive
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"time"
+	"unicode"
+
+	_ "modernc.org/sqlite" // registers the pure-Go "sqlite" driver
+
+	"github.com/koltyakov/pghealth/internal/analyze"
+	"github.com/koltyakov/pghealth/internal/snapshot"
+)
+
+// runIDFormat formats run timestamps used as the run_id key.
+const runIDFormat = "2006-01-02T15:04:05Z"
+
+// RunID returns the archive key of a run of target started at t: the start
+// timestamp, so keys sort by time, followed by the target, so fleet targets
+// sharing an archive and starting in the same second keep their own rows.
+func RunID(t time.Time, target string) string {
+	id := t.UTC().Format(runIDFormat)
+	if target != "" {
+		id += " " + target
+	}
+	return id
+}
+
+// runStart is the start time encoded in a run key; zero when it has none.
+func runStart(id string) time.Time {
+	t, _ := time.Parse(runIDFormat, id[:min(len(id), len(runIDFormat))])
+	return t
+}
+
+// column is a table column with its SQLite storage class.
+type column struct {
+	name string
+	typ  string
+}
+
+// table is a flattened set of rows sharing a schema. The run_id and ord
+// columns are implicit and prepended on write.
+type table struct {
+	name string
+	cols []column
+	rows [][]any
+}
+
+// WriteSQLite appends the run held in s to the SQLite file at path, creating
+// the file and tables as needed. Writing the same run again replaces its rows.
+func WriteSQLite(ctx context.Context, path string, s snapshot.Snapshot) error {
+	if path == "" {
+		return fmt.Errorf("archive path cannot be empty")
+	}
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return fmt.Errorf("open archive: %w", err)
+	}
+	defer db.Close()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin archive transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	runID := RunID(s.Meta.StartedAt, s.Meta.Target)
+	for _, t := range flatten(s) {
+		if err := writeTable(ctx, tx, t, runID); err != nil {
+			return fmt.Errorf("archive table %s: %w", t.name, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit archive: %w", err)
+	}
+	return nil
+}
+
+// flatten converts a snapshot into archive tables.
+func flatten(s snapshot.Snapshot) []table {
+	runs := table{
+		name: "runs",
+		cols: []column{
+			{"target", "TEXT"}, {"database", "TEXT"}, {"version", "TEXT"}, {"started_at", "TEXT"},
+			{"duration_ms", "INTEGER"}, {"warnings", "INTEGER"}, {"recommendations", "INTEGER"}, {"infos", "INTEGER"},
+			{"labels", "TEXT"},
+		},
+		rows: [][]any{{
+			s.Meta.Target, s.Result.ConnInfo.CurrentDB, s.Meta.Version, timeValue(s.Meta.StartedAt),
+			s.Meta.Duration.Milliseconds(), len(s.Analysis.Warnings), len(s.Analysis.Recommendations), len(s.Analysis.Infos),
+			jsonValue(reflect.ValueOf(s.Meta.Labels)),
+		}},
+	}
+
+	findings := table{
+		name: "findings",
+		cols: []column{
+			{"severity", "TEXT"}, {"code", "TEXT"}, {"title", "TEXT"}, {"description", "TEXT"}, {"action", "TEXT"},
+			{"evidence", "TEXT"},
+		},
+	}
+	for _, list := range [][]analyze.Finding{s.Analysis.Warnings, s.Analysis.Recommendations, s.Analysis.Infos} {
+		for _, f := range list {
+			findings.rows = append(findings.rows, []any{f.Severity, f.Code, f.Title, f.Description, f.Action, jsonValue(reflect.ValueOf(f.Evidence))})
+		}
+	}
+
+	out := []table{runs, findings}
+	return append(out, structTables("result", reflect.ValueOf(s.Result))...)
+}
+
+// structTables flattens a struct value: scalar fields form a single-row table
+// named name, slices and nested structs become their own tables. Children of
+// the root Result are not prefixed so tables read naturally (tables, indexes).
+func structTables(name string, v reflect.Value) []table {
+	prefix := name + "_"
+	if name == "result" {
+		prefix = ""
+	}
+
+	scalars := table{name: name}
+	var row []any
+	var out []table
+
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		fv := v.Field(i)
+		col := snakeCase(f.Name)
+		ft := f.Type
+
+		switch {
+		case isScalar(ft):
+			scalars.cols = append(scalars.cols, column{col, sqlType(ft)})
+			row = append(row, scalarValue(fv))
+		case ft.Kind() == reflect.Slice:
+			out = append(out, sliceTable(prefix+col, fv))
+		case ft.Kind() == reflect.Struct:
+			out = append(out, structTables(prefix+col, fv)...)
+		case ft.Kind() == reflect.Pointer && ft.Elem().Kind() == reflect.Struct:
+			if fv.IsNil() {
+				// Keep the schema stable even when the metric is unavailable.
+				child := structTables(prefix+col, reflect.New(ft.Elem()).Elem())
+				for j := range child {
+					child[j].rows = nil
+				}
+				out = append(out, child...)
+				continue
+			}
+			out = append(out, structTables(prefix+col, fv.Elem())...)
+		default:
+			scalars.cols = append(scalars.cols, column{col, "TEXT"})
+			row = append(row, jsonValue(fv))
+		}
+	}
+
+	if len(scalars.cols) > 0 {
+		scalars.rows = [][]any{row}
+		out = append([]table{scalars}, out...)
+	}
+	return out
+}
+
+// sliceTable builds a table with one row per slice element. Slices of scalars
+// get a single value column; nested non-scalar fields are stored as JSON.
+func sliceTable(name string, v reflect.Value) table {
+	elem := v.Type().Elem()
+	t := table{name: name}
+
+	if elem.Kind() != reflect.Struct || isScalar(elem) {
+		t.cols = []column{{"value", sqlType(elem)}}
+		for i := 0; i < v.Len(); i++ {
+			t.rows = append(t.rows, []any{cellValue(v.Index(i))})
+		}
+		return t
+	}
+
+	var fields []int
+	for i := 0; i < elem.NumField(); i++ {
+		f := elem.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		fields = append(fields, i)
+		t.cols = append(t.cols, column{snakeCase(f.Name), sqlType(f.Type)})
+	}
+	for i := 0; i < v.Len(); i++ {
+		item := v.Index(i)
+		row := make([]any, 0, len(fields))
+		for _, fi := range fields {
+			row = append(row, cellValue(item.Field(fi)))
+		}
+		t.rows = append(t.rows, row)
+	}
+	return t
+}
+
+// writeTable ensures the table schema and replaces the rows of runID.
+func writeTable(ctx context.Context, tx *sql.Tx, t table, runID string) error {
+	defs := []string{`"run_id" TEXT NOT NULL`, `"ord" INTEGER NOT NULL`}
+	for _, c := range t.cols {
+		defs = append(defs, quoteIdent(c.name)+" "+c.typ)
+	}
+	q := quoteIdent(t.name)
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s)", q, strings.Join(defs, ", "))); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s ON %s (run_id)", quoteIdent(t.name+"_run_id_idx"), q)); err != nil {
+		return err
+	}
+	if err := addMissingColumns(ctx, tx, t); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf("DELETE FROM %s WHERE run_id = ?", q), runID); err != nil {
+		return err
+	}
+	if len(t.rows) == 0 {
+		return nil
+	}
+
+	names := []string{`"run_id"`, `"ord"`}
+	for _, c := range t.cols {
+		names = append(names, quoteIdent(c.name))
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(names)), ", ")
+	stmt, err := tx.PrepareContext(ctx, fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", q, strings.Join(names, ", "), placeholders))
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for i, row := range t.rows {
+		args := append([]any{runID, i + 1}, row...)
+		if _, err := stmt.ExecContext(ctx, args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addMissingColumns adds columns introduced since the table was created.
+func addMissingColumns(ctx context.Context, tx *sql.Tx, t table) error {
+	rows, err := tx.QueryContext(ctx, fmt.Sprintf("SELECT name FROM pragma_table_info(%s)", quoteLiteral(t.name)))
+	if err != nil {
+		return err
+	}
+	existing := map[string]bool{}
+	for rows.Next() {
+		var n string
+		if err := rows.Scan(&n); err != nil {
+			rows.Close()
+			return err
+		}
+		existing[n] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, c := range t.cols {
+		if existing[c.name] {
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", quoteIdent(t.name), quoteIdent(c.name), c.typ)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// isScalar reports whether values of t map to a single column.
+func isScalar(t reflect.Type) bool {
+	if t == timeType {
+		return true
+	}
+	switch t.Kind() {
+	case reflect.Bool, reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// sqlType maps a Go type to an SQLite storage class.
+func sqlType(t reflect.Type) string {
+	if t == timeType {
+		return "TEXT"
+	}
+	switch t.Kind() {
+	case reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "INTEGER"
+	case reflect.Float32, reflect.Float64:
+		return "REAL"
+	}
+	return "TEXT"
+}
+
+// cellValue converts a field to a driver value, encoding non-scalars as JSON.
+func cellValue(v reflect.Value) any {
+	if isScalar(v.Type()) {
+		return scalarValue(v)
+	}
+	return jsonValue(v)
+}
+
+// scalarValue converts a scalar field to a driver value. Zero times and
+// non-finite floats are stored as NULL.
+func scalarValue(v reflect.Value) any {
+	if v.Type() == timeType {
+		return timeValue(v.Interface().(time.Time))
+	}
+	switch v.Kind() {
+	case reflect.Bool:
+		return v.Bool()
+	case reflect.String:
+		return v.String()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int64(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		f := v.Float()
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return nil
+		}
+		return f
+	}
+	return nil
+}
+
+func timeValue(t time.Time) any {
+	if t.IsZero() {
+		return nil
+	}
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+func jsonValue(v reflect.Value) any {
+	if (v.Kind() == reflect.Pointer || v.Kind() == reflect.Slice || v.Kind() == reflect.Map) && v.IsNil() {
+		return nil
+	}
+	b, err := json.Marshal(v.Interface())
+	if err != nil {
+		return nil
+	}
+	return string(b)
+}
+
+// snakeCase converts Go field names to column names: TopByIOBlocks -> top_by_io_blocks.
+func snakeCase(s string) string {
+	r := []rune(s)
+	var b strings.Builder
+	for i, c := range r {
+		if unicode.IsUpper(c) {
+			if i > 0 && (unicode.IsLower(r[i-1]) || unicode.IsDigit(r[i-1]) ||
+				(i+1 < len(r) && unicode.IsLower(r[i+1]) && unicode.IsUpper(r[i-1]))) {
+				b.WriteByte('_')
+			}
+			b.WriteRune(unicode.ToLower(c))
+			continue
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+func quoteIdent(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, `""`) + `"`
+}
+
+func quoteLiteral(s string) string {
+	return `'` + strings.ReplaceAll(s, `'`, `''`) + `'`
+}
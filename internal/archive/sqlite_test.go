@@ -0,0 +1,407 @@
+package archive
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/koltyakov/pghealth/internal/analyze"
+	"github.com/koltyakov/pghealth/internal/collect"
+	"github.com/koltyakov/pghealth/internal/snapshot"
+)
+
+// TestSnakeCase verifies Go field names map to readable column names.
+func TestSnakeCase(t *testing.T) {
+	tests := map[string]string{
+		"CacheHitCurrent":  "cache_hit_current",
+		"TopByIOBlocks":    "top_by_io_blocks",
+		"WAL":              "wal",
+		"XIDAge":           "xid_age",
+		"FKMissingIndexes": "fk_missing_indexes",
+		"Name":             "name",
+	}
+	for in, expected := range tests {
+		if got := snakeCase(in); got != expected {
+			t.Errorf("snakeCase(%q) = %q, expected %q", in, got, expected)
+		}
+	}
+}
+
+// TestWriteSQLite verifies tables are created, filled, and rewritten per run.
+func TestWriteSQLite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "archive.db")
+	ctx := context.Background()
+
+	var res collect.Result
+	res.ConnInfo.CurrentDB = "app"
+	res.Tables = []collect.TableStat{{}, {}}
+	res.Errors = []string{"permission denied"}
+	a := analyze.Analysis{Warnings: []analyze.Finding{{Title: "w", Code: "c", Severity: analyze.SeverityWarning}}}
+
+	started := time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC)
+	snap := snapshot.New(res, a, collect.Meta{StartedAt: started, Version: "v1"})
+
+	// Writing twice must not duplicate rows of the same run.
+	for i := 0; i < 2; i++ {
+		if err := WriteSQLite(ctx, path, snap); err != nil {
+			t.Fatalf("WriteSQLite() error = %v", err)
+		}
+	}
+	snap.Meta.StartedAt = started.Add(time.Hour)
+	if err := WriteSQLite(ctx, path, snap); err != nil {
+		t.Fatalf("WriteSQLite() error = %v", err)
+	}
+	// Another target starting in the same second keeps its own rows
+	snap.Meta.Target = "other"
+	if err := WriteSQLite(ctx, path, snap); err != nil {
+		t.Fatalf("WriteSQLite() error = %v", err)
+	}
+
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	counts := map[string]int{"runs": 3, "findings": 3, "tables": 6, "errors": 3, "conn_info": 3, "wal": 0}
+	for name, expected := range counts {
+		var n int
+		if err := db.QueryRow(`SELECT count(*) FROM "` + name + `"`).Scan(&n); err != nil {
+			t.Fatalf("count %s: %v", name, err)
+		}
+		if n != expected {
+			t.Errorf("%s rows = %d, expected %d", name, n, expected)
+		}
+	}
+
+	var db1 string
+	if err := db.QueryRow(`SELECT current_db FROM conn_info WHERE run_id = ?`, RunID(started, "")).Scan(&db1); err != nil || db1 != "app" {
+		t.Errorf("conn_info.current_db = %q, %v", db1, err)
+	}
+}
+
+// TestQueryHistory verifies archived observations of the same target's
+// earlier runs are returned newest first.
+func TestQueryHistory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "archive.db")
+	ctx := context.Background()
+	started := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	current := RunID(started.Add(3*time.Hour), "app")
+
+	if h, err := QueryHistory(ctx, path, "app", current, []collect.Statement{{Query: "SELECT 1"}}, 10); err != nil || h != nil {
+		t.Fatalf("missing archive: history = %v, err = %v", h, err)
+	}
+
+	// The other target's run is the latest before the current one, which is
+	// already archived and must not count toward its own history.
+	runs := []struct {
+		target string
+		at     time.Duration
+		calls  float64
+	}{{"app", 0, 1}, {"app", time.Hour, 2}, {"app", 2 * time.Hour, 3}, {"other", 150 * time.Minute, 40}, {"app", 3 * time.Hour, 50}}
+	for i, run := range runs {
+		var res collect.Result
+		// queryid is stable while the text changes (e.g. whitespace or comments).
+		q := collect.Statement{QueryID: 7, Query: fmt.Sprintf("SELECT 1 /* v%d */", i), Calls: run.calls}
+		res.Statements.TopByTotalTime = []collect.Statement{q}
+		res.Statements.TopByCalls = []collect.Statement{q}
+		snap := snapshot.New(res, analyze.Analysis{}, collect.Meta{StartedAt: started.Add(run.at), Target: run.target})
+		if err := WriteSQLite(ctx, path, snap); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	h, err := QueryHistory(ctx, path, "app", current, []collect.Statement{{QueryID: 7, Query: "SELECT 1"}, {Query: "SELECT 2"}}, 2)
+	if err != nil {
+		t.Fatalf("QueryHistory() error = %v", err)
+	}
+	pts := h["7"]
+	if len(pts) != 2 || pts[0].Calls != 3 || pts[1].Calls != 2 {
+		t.Errorf("history = %+v, expected calls 3 then 2", pts)
+	}
+	if len(h["SELECT 2"]) != 0 {
+		t.Errorf("unexpected history for unknown query: %+v", h["SELECT 2"])
+	}
+}
+
+// TestXIDRates verifies XID consumption is measured between archived runs of
+// the same target and the current reading, skipping other targets and
+// counter resets.
+func TestXIDRates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "archive.db")
+	ctx := context.Background()
+	started := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+
+	if r, err := XIDRates(ctx, path, "app", collect.XIDClock{NextXID: 1, At: started}, 10); err != nil || r != nil {
+		t.Fatalf("missing archive: rates = %v, err = %v", r, err)
+	}
+
+	runs := []struct {
+		target string
+		xid    int64
+	}{{"app", 1000}, {"app", 500}, {"app", 3000}, {"other", 9000}}
+	for i, run := range runs {
+		var res collect.Result
+		at := started.Add(time.Duration(i) * time.Hour)
+		res.XIDClock = &collect.XIDClock{NextXID: run.xid, At: at}
+		snap := snapshot.New(res, analyze.Analysis{}, collect.Meta{StartedAt: at, Target: run.target})
+		if err := WriteSQLite(ctx, path, snap); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	current := collect.XIDClock{NextXID: 7000, At: started.Add(4 * time.Hour)}
+	rates, err := XIDRates(ctx, path, "app", current, 10)
+	if err != nil {
+		t.Fatalf("XIDRates() error = %v", err)
+	}
+	// 3000 -> 7000 over 2h, then 500 -> 3000 over 1h; 1000 -> 500 went back
+	if len(rates) != 2 || rates[0].XIDs != 4000 || rates[0].PerHour() != 2000 || rates[1].XIDs != 2500 {
+		t.Errorf("rates = %+v", rates)
+	}
+}
+
+// TestPreviousRun verifies that the latest earlier run of the same target is
+// loaded with its findings, table sizes and top statements.
+func TestPreviousRun(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "archive.db")
+	ctx := context.Background()
+	started := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+
+	if b, err := PreviousRun(ctx, path, "app", RunID(started, "app")); err != nil || b != nil {
+		t.Fatalf("missing archive: baseline = %v, err = %v", b, err)
+	}
+
+	for i, target := range []string{"app", "app", "other"} {
+		var res collect.Result
+		res.Tables = []collect.TableStat{{Database: "app", Schema: "public", Name: "orders", SizeBytes: int64(i+1) << 30}}
+		res.Statements.TopByTotalTime = []collect.Statement{{QueryID: 42, Query: "SELECT 1", Calls: 10, MeanTime: float64(i + 1)}}
+		a := analyze.Analysis{Warnings: []analyze.Finding{{Title: fmt.Sprintf("w%d", i), Severity: analyze.SeverityWarning, Code: "c",
+			Evidence: &analyze.Evidence{Objects: []analyze.Object{{Kind: "table", Schema: "public", Name: "orders"}}, Metrics: map[string]float64{"n": float64(i)}}}}}
+		at := started.Add(time.Duration(i) * time.Hour)
+		if err := WriteSQLite(ctx, path, snapshot.New(res, a, collect.Meta{StartedAt: at, Target: target})); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	b, err := PreviousRun(ctx, path, "app", RunID(started.Add(3*time.Hour), "app"))
+	if err != nil {
+		t.Fatalf("PreviousRun() error = %v", err)
+	}
+	if b == nil || b.RunID != RunID(started.Add(time.Hour), "app") || !b.StartedAt.Equal(started.Add(time.Hour)) {
+		t.Fatalf("PreviousRun() = %+v", b)
+	}
+	if len(b.Findings) != 1 || b.Findings[0].Title != "w1" || b.Findings[0].Code != "c" || b.Findings[0].Severity != analyze.SeverityWarning {
+		t.Errorf("findings = %+v", b.Findings)
+	} else if e := b.Findings[0].Evidence; e == nil || len(e.Objects) != 1 || e.Objects[0].ID() != "public.orders" || e.Metrics["n"] != 1 {
+		t.Errorf("evidence = %+v", e)
+	}
+	if len(b.Tables) != 1 || b.Tables[0].SizeBytes != 2<<30 || b.Tables[0].Database != "app" {
+		t.Errorf("tables = %+v", b.Tables)
+	}
+	if len(b.Statements) != 1 || b.Statements[0].QueryID != 42 || b.Statements[0].MeanTime != 2 {
+		t.Errorf("statements = %+v", b.Statements)
+	}
+
+	if b, err := PreviousRun(ctx, path, "app", RunID(started, "app")); err != nil || b != nil {
+		t.Errorf("no earlier run: baseline = %v, err = %v", b, err)
+	}
+}
+
+// TestRecentResults verifies that archived runs of the target come back
+// newest first with the fields objectives are measured on.
+func TestRecentResults(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "archive.db")
+	ctx := context.Background()
+	started := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+
+	for i, target := range []string{"app", "app", "other", "app"} {
+		var res collect.Result
+		res.CacheHitCurrent = 90 + float64(i)
+		res.Statements.TopByTotalTime = []collect.Statement{{QueryID: 42, Query: "SELECT 1", Calls: 10, MeanTime: float64(i)}}
+		res.ReplicationStats = []collect.ReplicationStat{{Name: "r1", ReplayLag: "00:00:0" + fmt.Sprint(i)}}
+		at := started.Add(time.Duration(i) * time.Hour)
+		if err := WriteSQLite(ctx, path, snapshot.New(res, analyze.Analysis{}, collect.Meta{StartedAt: at, Target: target})); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := RecentResults(ctx, path, "app", RunID(started.Add(3*time.Hour), "app"), 10)
+	if err != nil {
+		t.Fatalf("RecentResults() error = %v", err)
+	}
+	if len(got) != 2 || got[0].CacheHitCurrent != 91 || got[1].CacheHitCurrent != 90 {
+		t.Fatalf("RecentResults() = %+v", got)
+	}
+	if st := got[0].Statements.TopByTotalTime; len(st) != 1 || st[0].MeanTime != 1 || st[0].QueryID != 42 || st[0].Query != "SELECT 1" {
+		t.Errorf("statements = %+v", got[0].Statements.TopByTotalTime)
+	}
+	if len(got[0].ReplicationStats) != 1 || got[0].ReplicationStats[0].ReplayLag != "00:00:01" {
+		t.Errorf("replication = %+v", got[0].ReplicationStats)
+	}
+	if got, _ := RecentResults(ctx, path, "app", RunID(started.Add(3*time.Hour), "app"), 1); len(got) != 1 {
+		t.Errorf("limit 1: %d runs", len(got))
+	}
+}
+
+// TestSizeGrowth verifies that database and table growth is fitted over the
+// archived runs of the same target and the current sizes.
+func TestSizeGrowth(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "archive.db")
+	ctx := context.Background()
+	started := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+
+	sized := func(db, table int64) collect.Result {
+		var res collect.Result
+		res.DBs = []collect.Database{{Name: "app", SizeBytes: db << 30}}
+		res.Tables = []collect.TableStat{{Database: "app", Schema: "public", Name: "orders", SizeBytes: table << 30}}
+		res.Indexes = []collect.IndexStat{{Database: "app", Schema: "public", Table: "orders", Name: "orders_pkey", SizeBytes: table << 29}}
+		return res
+	}
+	current := sized(13, 4)
+	at := started.Add(3 * day)
+	if g, err := SizeGrowth(ctx, path, "app", RunID(at, "app"), current, at, 10); err != nil || g != nil {
+		t.Fatalf("missing archive: growth = %v, err = %v", g, err)
+	}
+
+	runs := []struct {
+		target    string
+		day       int
+		db, table int64
+	}{{"app", 0, 10, 1}, {"app", 1, 11, 2}, {"other", 1, 100, 50}, {"app", 2, 12, 3}}
+	for i, run := range runs {
+		when := started.Add(time.Duration(run.day)*day + time.Duration(i)*time.Second)
+		if err := WriteSQLite(ctx, path, snapshot.New(sized(run.db, run.table), analyze.Analysis{}, collect.Meta{StartedAt: when, Target: run.target})); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	growth, err := SizeGrowth(ctx, path, "app", RunID(at, "app"), current, at, 10)
+	if err != nil {
+		t.Fatalf("SizeGrowth() error = %v", err)
+	}
+	if len(growth) != 3 {
+		t.Fatalf("growth = %+v", growth)
+	}
+	for _, g := range growth[:2] {
+		if math.Abs(g.BytesPerDay-(1<<30)) > 1<<20 || g.Runs != 4 || math.Round(g.Days) != 3 {
+			t.Errorf("growth of %q = %+v, want 1 GB/day over 4 runs and 3 days", g.Name, g)
+		}
+	}
+	if !growth[0].IsDatabase() || growth[0].Database != "app" || growth[1].Name != "orders" || growth[1].Bytes != 4<<30 {
+		t.Errorf("growth = %+v", growth)
+	}
+	if ix := growth[2]; !ix.Index || ix.Name != "orders_pkey" || ix.Grown() != 3<<29 || ix.GrownPct() != 300 {
+		t.Errorf("index growth = %+v, want orders_pkey grown by 1.5 GB (300%%)", ix)
+	}
+	if g, _ := SizeGrowth(ctx, path, "app", RunID(at, "app"), current, at, 1); len(g) != 3 || g[0].Runs != 2 {
+		t.Errorf("limit 1: growth = %+v", g)
+	}
+}
+
+// TestConnectionHistory verifies that connections are summed per run, in
+// total and per application, across the archived runs of the same target.
+func TestConnectionHistory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "archive.db")
+	ctx := context.Background()
+	started := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+
+	clients := func(api, worker int) collect.Result {
+		var res collect.Result
+		res.ConnectionsByClient = []collect.ClientConn{
+			{Address: "10.0.0.1", Application: "api", Count: api, Idle: api / 2},
+			{Address: "10.0.0.2", Application: "api", Count: api, Idle: api / 2},
+		}
+		if worker > 0 {
+			res.ConnectionsByClient = append(res.ConnectionsByClient, collect.ClientConn{Address: "10.0.0.3", Application: "worker", Count: worker})
+		}
+		return res
+	}
+	current := clients(40, 0)
+	at := started.Add(2 * day)
+	h, err := ConnectionHistory(ctx, path, "app", RunID(at, "app"), current, at, 10)
+	if err != nil || h == nil || h.Total.Runs != 1 || h.Total.Peak != 80 || h.Total.PerDay != 0 {
+		t.Fatalf("missing archive: history = %+v, err = %v", h, err)
+	}
+
+	for i, target := range []string{"app", "other", "app"} {
+		res := clients(20*(i+1), 5)
+		if target == "other" {
+			res = clients(500, 0)
+		}
+		if err := WriteSQLite(ctx, path, snapshot.New(res, analyze.Analysis{}, collect.Meta{StartedAt: started.Add(time.Duration(i) * day / 2), Target: target})); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	h, err = ConnectionHistory(ctx, path, "app", RunID(at, "app"), current, at, 10)
+	if err != nil {
+		t.Fatalf("ConnectionHistory() error = %v", err)
+	}
+	// 45 connections at day 0, 125 at day 1 (the peak, 60 busy) and 80 now
+	if h.Total.Runs != 3 || h.Total.Peak != 125 || !h.Total.PeakAt.Equal(started.Add(day)) || h.Total.PeakBusy != 65 || h.Total.Current != 80 || h.Total.Days != 2 {
+		t.Errorf("total = %+v", h.Total)
+	}
+	if len(h.Apps) != 1 || h.Apps[0].Application != "api" || h.Apps[0].Peak != 120 || h.Apps[0].PeakBusy != 60 || h.Apps[0].PerDay <= 0 {
+		t.Errorf("apps = %+v", h.Apps)
+	}
+}
+
+// TestStatementDeltas verifies that the statement load is measured since the
+// newest archived run at least a window old, falling back to the oldest run.
+func TestStatementDeltas(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "archive.db")
+	ctx := context.Background()
+	started := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+
+	load := func(calls float64) collect.Result {
+		var res collect.Result
+		res.Statements.Available = true
+		res.Statements.StatsResetTime = started.Add(-time.Hour)
+		res.Statements.TopByTotalTime = []collect.Statement{
+			{QueryID: 1, Query: "select 1", Calls: calls, TotalTime: 2 * calls, SharedBlksRead: calls},
+			{QueryID: 2, Query: "select 2", Calls: 10, TotalTime: 10},
+		}
+		return res
+	}
+	at := started.Add(30 * time.Hour)
+	current := load(1000)
+	if w, err := StatementDeltas(ctx, path, "app", RunID(at, "app"), current, at, 24*time.Hour, 10); err != nil || w != nil {
+		t.Fatalf("missing archive: window = %+v, err = %v", w, err)
+	}
+
+	for i, run := range []struct {
+		target string
+		hours  int
+		calls  float64
+	}{{"app", 0, 100}, {"app", 4, 400}, {"other", 5, 900}, {"app", 20, 800}} {
+		when := started.Add(time.Duration(run.hours)*time.Hour + time.Duration(i)*time.Second)
+		if err := WriteSQLite(ctx, path, snapshot.New(load(run.calls), analyze.Analysis{}, collect.Meta{StartedAt: when, Target: run.target})); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	w, err := StatementDeltas(ctx, path, "app", RunID(at, "app"), current, at, 24*time.Hour, 10)
+	if err != nil {
+		t.Fatalf("StatementDeltas() error = %v", err)
+	}
+	// Compared with the run at hour 4, the newest one a day before
+	if w == nil || w.Duration() != 26*time.Hour-time.Second || len(w.Statements) != 1 {
+		t.Fatalf("window = %+v", w)
+	}
+	if d := w.Statements[0]; d.QueryID != 1 || d.Calls != 600 || d.TotalTime != 1200 || d.SharedBlksRead != 600 || d.MeanTime() != 2 {
+		t.Errorf("delta = %+v, want 600 calls taking 1200 ms", d)
+	}
+
+	// Without a run a day old the oldest one is used
+	early := started.Add(10 * time.Hour)
+	if w, _ := StatementDeltas(ctx, path, "app", RunID(early, "app"), current, early, 24*time.Hour, 10); w == nil || !w.From.Equal(started) || w.Statements[0].Calls != 900 {
+		t.Errorf("early window = %+v", w)
+	}
+}
@@ -0,0 +1,119 @@
+// Package auth provides database credentials obtained at runtime: short-lived
+// passwords (AWS RDS IAM authentication tokens and Azure AD access tokens) and
+// connection strings stored in secret managers. Tokens are cached and
+// refreshed before they expire, so collections that open new connections late
+// in a run keep authenticating.
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Supported -auth methods.
+const (
+	MethodPassword = "password"
+	MethodRDSIAM   = "iam-rds"
+	MethodAzureAD  = "azure-ad"
+)
+
+// refreshMargin renews a cached token this long before it expires.
+const refreshMargin = 2 * time.Minute
+
+// Token is a password valid until Expiry.
+type Token struct {
+	Value  string
+	Expiry time.Time
+}
+
+// fetchFunc obtains a new token.
+type fetchFunc func(ctx context.Context) (Token, error)
+
+// Source hands out the current token, fetching a new one when the cached
+// token is missing or about to expire. It is safe for concurrent use.
+type Source struct {
+	fetch fetchFunc
+	now   func() time.Time
+
+	mu  sync.Mutex
+	tok Token
+}
+
+// newSource returns a caching Source around fetch.
+func newSource(fetch fetchFunc) *Source {
+	return &Source{fetch: fetch, now: time.Now}
+}
+
+// Password returns a valid token, matching collect.Config.Password.
+func (s *Source) Password(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.tok.Value != "" && s.now().Add(refreshMargin).Before(s.tok.Expiry) {
+		return s.tok.Value, nil
+	}
+	tok, err := s.fetch(ctx)
+	if err != nil {
+		return "", err
+	}
+	s.tok = tok
+	return tok.Value, nil
+}
+
+// New returns the token source for method and the connection URL (host, port
+// and user are taken from it). It returns nil for password authentication.
+func New(method, connURL string) (*Source, error) {
+	switch method {
+	case "", MethodPassword:
+		return nil, nil
+	case MethodRDSIAM:
+		host, port, user, err := endpoint(connURL)
+		if err != nil {
+			return nil, err
+		}
+		creds, err := awsCredentialsFromEnv()
+		if err != nil {
+			return nil, err
+		}
+		region, err := rdsRegion(host)
+		if err != nil {
+			return nil, err
+		}
+		return newSource(func(context.Context) (Token, error) {
+			now := time.Now().UTC()
+			return Token{
+				Value:  rdsToken(host, port, user, region, creds, now),
+				Expiry: now.Add(rdsTokenTTL),
+			}, nil
+		}), nil
+	case MethodAzureAD:
+		return newSource(azureToken), nil
+	default:
+		return nil, fmt.Errorf("unsupported auth method %q: use %s, %s or %s", method, MethodPassword, MethodRDSIAM, MethodAzureAD)
+	}
+}
+
+// endpoint extracts host, port and user from a postgres:// URL.
+func endpoint(connURL string) (host string, port int, user string, err error) {
+	u, err := url.Parse(connURL)
+	if err != nil || (u.Scheme != "postgres" && u.Scheme != "postgresql") {
+		return "", 0, "", errors.New("token auth needs a postgres:// URL")
+	}
+	host, port = u.Hostname(), 5432
+	if p := u.Port(); p != "" {
+		if port, err = strconv.Atoi(p); err != nil {
+			return "", 0, "", fmt.Errorf("invalid port %q", p)
+		}
+	}
+	if u.User != nil {
+		user = u.User.Username()
+	}
+	if host == "" || user == "" {
+		return "", 0, "", errors.New("token auth needs a host and user in the connection URL")
+	}
+	return host, port, user, nil
+}
@@ -0,0 +1,138 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/koltyakov/pghealth/internal/sigv4"
+)
+
+// TestRDSToken verifies the presigned token layout.
+func TestRDSToken(t *testing.T) {
+	creds := sigv4.Credentials{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "secret", SessionToken: "session/token"}
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	tok := rdsToken("db.abc.eu-west-1.rds.amazonaws.com", 5432, "app user", "eu-west-1", creds, now)
+
+	prefix := "db.abc.eu-west-1.rds.amazonaws.com:5432/?"
+	if !strings.HasPrefix(tok, prefix) {
+		t.Fatalf("token %q lacks prefix %q", tok, prefix)
+	}
+	if strings.Contains(tok, "+") {
+		t.Errorf("token %q must encode spaces as %%20", tok)
+	}
+	q, err := url.ParseQuery(strings.TrimPrefix(tok, prefix))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checks := map[string]string{
+		"Action":               "connect",
+		"DBUser":               "app user",
+		"X-Amz-Credential":     "AKIDEXAMPLE/20240501/eu-west-1/rds-db/aws4_request",
+		"X-Amz-Date":           "20240501T120000Z",
+		"X-Amz-Expires":        "900",
+		"X-Amz-Security-Token": "session/token",
+	}
+	for k, v := range checks {
+		if q.Get(k) != v {
+			t.Errorf("%s = %q, expected %q", k, q.Get(k), v)
+		}
+	}
+	if len(q.Get("X-Amz-Signature")) != 64 {
+		t.Errorf("X-Amz-Signature = %q, expected 64 hex chars", q.Get("X-Amz-Signature"))
+	}
+	if again := rdsToken("db.abc.eu-west-1.rds.amazonaws.com", 5432, "app user", "eu-west-1", creds, now); again != tok {
+		t.Error("rdsToken() is not deterministic")
+	}
+}
+
+// TestRDSRegion verifies region inference from RDS endpoints.
+func TestRDSRegion(t *testing.T) {
+	t.Setenv("AWS_REGION", "")
+	t.Setenv("AWS_DEFAULT_REGION", "")
+	if r, err := rdsRegion("db.abc123.eu-central-1.rds.amazonaws.com"); err != nil || r != "eu-central-1" {
+		t.Errorf("rdsRegion() = %q, %v", r, err)
+	}
+	if _, err := rdsRegion("db.internal"); err == nil {
+		t.Error("rdsRegion() should fail for non-RDS hosts")
+	}
+	t.Setenv("AWS_REGION", "us-east-2")
+	if r, _ := rdsRegion("db.internal"); r != "us-east-2" {
+		t.Errorf("rdsRegion() = %q, expected AWS_REGION", r)
+	}
+}
+
+// TestSourceRefresh verifies tokens are cached and renewed before expiry.
+func TestSourceRefresh(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	fetches := 0
+	s := newSource(func(context.Context) (Token, error) {
+		fetches++
+		return Token{Value: "t" + string(rune('0'+fetches)), Expiry: now.Add(15 * time.Minute)}, nil
+	})
+	s.now = func() time.Time { return now }
+
+	ctx := context.Background()
+	a, _ := s.Password(ctx)
+	b, _ := s.Password(ctx)
+	if a != "t1" || b != "t1" || fetches != 1 {
+		t.Errorf("cached tokens = %q, %q after %d fetches", a, b, fetches)
+	}
+	now = now.Add(14 * time.Minute)
+	if c, _ := s.Password(ctx); c != "t2" {
+		t.Errorf("token near expiry = %q, expected refresh", c)
+	}
+}
+
+// TestNew verifies method selection and URL requirements.
+func TestNew(t *testing.T) {
+	if s, err := New(MethodPassword, "postgres://u:p@h/db"); s != nil || err != nil {
+		t.Errorf("New(password) = %v, %v", s, err)
+	}
+	if _, err := New("kerberos", "postgres://u@h/db"); err == nil {
+		t.Error("New() should reject unknown methods")
+	}
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKID")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("AWS_REGION", "")
+	t.Setenv("AWS_DEFAULT_REGION", "")
+	if _, err := New(MethodRDSIAM, "postgres://h.x.us-west-2.rds.amazonaws.com/db"); err == nil {
+		t.Error("New(iam-rds) should require a user")
+	}
+	s, err := New(MethodRDSIAM, "postgres://app@h.x.us-west-2.rds.amazonaws.com:6432/db")
+	if err != nil {
+		t.Fatalf("New(iam-rds) error = %v", err)
+	}
+	tok, err := s.Password(context.Background())
+	if err != nil || !strings.HasPrefix(tok, "h.x.us-west-2.rds.amazonaws.com:6432/?") {
+		t.Errorf("iam-rds token = %q, %v", tok, err)
+	}
+}
+
+// TestAzureClientSecretToken verifies the client credentials grant.
+func TestAzureClientSecretToken(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/tenant/oauth2/v2.0/token" || r.FormValue("client_secret") != "s" ||
+			r.FormValue("scope") != azureResource+"/.default" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		_, _ = w.Write([]byte(`{"access_token":"aad-token","expires_in":3599}`))
+	}))
+	defer srv.Close()
+	old := azureLoginURL
+	azureLoginURL = srv.URL
+	defer func() { azureLoginURL = old }()
+
+	t.Setenv("AZURE_TENANT_ID", "tenant")
+	t.Setenv("AZURE_CLIENT_ID", "c")
+	t.Setenv("AZURE_CLIENT_SECRET", "s")
+	tok, err := azureToken(context.Background())
+	if err != nil || tok.Value != "aad-token" || time.Until(tok.Expiry) < 59*time.Minute {
+		t.Errorf("azureToken() = %+v, %v", tok, err)
+	}
+}
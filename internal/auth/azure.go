@@ -0,0 +1,137 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// azureResource is the Azure AD audience of Azure Database for PostgreSQL.
+const azureResource = "https://ossrdbms-aad.database.windows.net"
+
+// imdsTimeout bounds the managed identity probe so machines outside Azure
+// fall through to the az CLI quickly.
+const imdsTimeout = 3 * time.Second
+
+// Azure AD endpoints, variables so tests can point them at a local server.
+var (
+	azureLoginURL = "https://login.microsoftonline.com"
+	azureIMDSURL  = "http://169.254.169.254/metadata/identity/oauth2/token"
+)
+
+// azureTokenResponse covers the AAD v2 and IMDS token responses; IMDS encodes
+// expires_in as a string.
+type azureTokenResponse struct {
+	AccessToken string          `json:"access_token"`
+	ExpiresIn   json.RawMessage `json:"expires_in"`
+}
+
+// azureToken obtains an access token for Azure Database for PostgreSQL from,
+// in order: a service principal (AZURE_TENANT_ID, AZURE_CLIENT_ID,
+// AZURE_CLIENT_SECRET), the VM managed identity, and the az CLI login.
+func azureToken(ctx context.Context) (Token, error) {
+	tenant, client, secret := os.Getenv("AZURE_TENANT_ID"), os.Getenv("AZURE_CLIENT_ID"), os.Getenv("AZURE_CLIENT_SECRET")
+	if tenant != "" && client != "" && secret != "" {
+		return azureClientSecretToken(ctx, tenant, client, secret)
+	}
+	tok, imdsErr := azureManagedIdentityToken(ctx, client)
+	if imdsErr == nil {
+		return tok, nil
+	}
+	tok, cliErr := azureCLIToken(ctx)
+	if cliErr == nil {
+		return tok, nil
+	}
+	return Token{}, fmt.Errorf("azure-ad auth: no credentials (managed identity: %v; az CLI: %v)", imdsErr, cliErr)
+}
+
+// azureClientSecretToken uses the OAuth2 client credentials grant.
+func azureClientSecretToken(ctx context.Context, tenant, client, secret string) (Token, error) {
+	form := url.Values{
+		"grant_type":    {"client_credentials"},
+		"client_id":     {client},
+		"client_secret": {secret},
+		"scope":         {azureResource + "/.default"},
+	}
+	endpoint := azureLoginURL + "/" + url.PathEscape(tenant) + "/oauth2/v2.0/token"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return Token{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return doAzureTokenRequest(req)
+}
+
+// azureManagedIdentityToken queries the instance metadata service.
+func azureManagedIdentityToken(ctx context.Context, client string) (Token, error) {
+	ctx, cancel := context.WithTimeout(ctx, imdsTimeout)
+	defer cancel()
+	q := url.Values{"api-version": {"2018-02-01"}, "resource": {azureResource}}
+	if client != "" {
+		q.Set("client_id", client)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, azureIMDSURL+"?"+q.Encode(), nil)
+	if err != nil {
+		return Token{}, err
+	}
+	req.Header.Set("Metadata", "true")
+	return doAzureTokenRequest(req)
+}
+
+// doAzureTokenRequest sends req and decodes the token response.
+func doAzureTokenRequest(req *http.Request) (Token, error) {
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return Token{}, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return Token{}, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return Token{}, fmt.Errorf("token endpoint returned %s", resp.Status)
+	}
+	var tr azureTokenResponse
+	if err := json.Unmarshal(body, &tr); err != nil {
+		return Token{}, fmt.Errorf("decode token response: %w", err)
+	}
+	if tr.AccessToken == "" {
+		return Token{}, errors.New("token response has no access_token")
+	}
+	secs, err := strconv.Atoi(strings.Trim(string(tr.ExpiresIn), `"`))
+	if err != nil {
+		return Token{}, fmt.Errorf("invalid expires_in %s", tr.ExpiresIn)
+	}
+	return Token{Value: tr.AccessToken, Expiry: time.Now().Add(time.Duration(secs) * time.Second)}, nil
+}
+
+// azureCLIToken asks the az CLI of the logged-in user.
+func azureCLIToken(ctx context.Context) (Token, error) {
+	out, err := exec.CommandContext(ctx, "az", "account", "get-access-token", "--resource-type", "oss-rdbms", "--output", "json").Output()
+	if err != nil {
+		return Token{}, err
+	}
+	var r struct {
+		AccessToken string `json:"accessToken"`
+		ExpiresOn   int64  `json:"expires_on"`
+	}
+	if err := json.Unmarshal(out, &r); err != nil || r.AccessToken == "" {
+		return Token{}, errors.New("unexpected az account get-access-token output")
+	}
+	exp := time.Unix(r.ExpiresOn, 0)
+	if r.ExpiresOn == 0 {
+		// Older CLIs only report a local-time expiresOn; assume the default hour
+		exp = time.Now().Add(time.Hour)
+	}
+	return Token{Value: r.AccessToken, Expiry: exp}, nil
+}
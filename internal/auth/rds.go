@@ -0,0 +1,49 @@
+package auth
+
+import (
+	"errors"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/koltyakov/pghealth/internal/sigv4"
+)
+
+// rdsTokenTTL is how long an RDS IAM authentication token is valid.
+const rdsTokenTTL = 15 * time.Minute
+
+// awsCredentialsFromEnv reads the standard AWS_* credential variables.
+func awsCredentialsFromEnv() (sigv4.Credentials, error) {
+	c := sigv4.FromEnv()
+	if c.AccessKeyID == "" || c.SecretAccessKey == "" {
+		return c, errors.New("iam-rds auth needs AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY (e.g. from aws configure export-credentials)")
+	}
+	return c, nil
+}
+
+// rdsRegion returns AWS_REGION / AWS_DEFAULT_REGION or the region embedded in
+// an RDS endpoint (<name>.<id>.<region>.rds.amazonaws.com).
+func rdsRegion(host string) (string, error) {
+	if r := sigv4.Region(); r != "" {
+		return r, nil
+	}
+	parts := strings.Split(host, ".")
+	for i := len(parts) - 1; i > 0; i-- {
+		if parts[i] == "rds" {
+			return parts[i-1], nil
+		}
+	}
+	return "", errors.New("iam-rds auth: cannot infer the region from the host; set AWS_REGION")
+}
+
+// rdsToken builds an RDS IAM authentication token: a SigV4 presigned
+// "connect" request for the rds-db service, used as the password.
+func rdsToken(host string, port int, user, region string, creds sigv4.Credentials, now time.Time) string {
+	endpoint := net.JoinHostPort(host, strconv.Itoa(port))
+	q := url.Values{}
+	q.Set("Action", "connect")
+	q.Set("DBUser", user)
+	return endpoint + "/?" + sigv4.Presign(endpoint, "/", q, creds, region, "rds-db", rdsTokenTTL, now)
+}
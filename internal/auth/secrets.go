@@ -0,0 +1,230 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2/google"
+
+	"github.com/koltyakov/pghealth/internal/sigv4"
+)
+
+// Secret manager endpoints, variables so tests can point them at a local server.
+var (
+	awsSecretsURL = "https://secretsmanager.%s.amazonaws.com/"
+	gcpSecretsURL = "https://secretmanager.googleapis.com/v1/"
+)
+
+// ResolveURL fetches a connection string from a secret manager:
+//
+//	aws-sm://<secret-id>                       AWS Secrets Manager (AWS_REGION, AWS_* credentials)
+//	gcp-sm://<project>/<secret>[/<version>]    GCP Secret Manager (Application Default Credentials)
+//	vault://<path>[#field]                     HashiCorp Vault KV v1/v2 (VAULT_ADDR, VAULT_TOKEN)
+//
+// The secret is either a postgres:// URL or a JSON object holding one under
+// "url"/"uri", or the username/password/host/port/dbname fields that RDS
+// managed secrets use.
+func ResolveURL(ctx context.Context, ref string) (string, error) {
+	scheme, rest, ok := strings.Cut(ref, "://")
+	if !ok || rest == "" {
+		return "", fmt.Errorf("invalid secret reference %q: expected aws-sm://, gcp-sm:// or vault://", ref)
+	}
+	var (
+		raw   []byte
+		field string
+		err   error
+	)
+	switch scheme {
+	case "aws-sm":
+		raw, err = awsSecret(ctx, rest)
+	case "gcp-sm":
+		raw, err = gcpSecret(ctx, rest)
+	case "vault":
+		rest, field, _ = strings.Cut(rest, "#")
+		raw, err = vaultSecret(ctx, rest)
+	default:
+		return "", fmt.Errorf("unsupported secret scheme %q: use aws-sm, gcp-sm or vault", scheme)
+	}
+	if err != nil {
+		return "", fmt.Errorf("%s secret: %w", scheme, err)
+	}
+	return secretURL(raw, field)
+}
+
+// secretURL extracts the connection string from a secret value.
+func secretURL(raw []byte, field string) (string, error) {
+	s := strings.TrimSpace(string(raw))
+	if strings.HasPrefix(s, "postgres://") || strings.HasPrefix(s, "postgresql://") {
+		return s, nil
+	}
+	var m map[string]any
+	if err := json.Unmarshal([]byte(s), &m); err != nil {
+		return "", errors.New("secret is neither a postgres:// URL nor a JSON object")
+	}
+	str := func(k string) string {
+		switch v := m[k].(type) {
+		case string:
+			return v
+		case float64:
+			return strconv.FormatFloat(v, 'f', -1, 64)
+		}
+		return ""
+	}
+	if field != "" {
+		if v := str(field); v != "" {
+			return v, nil
+		}
+		return "", fmt.Errorf("secret has no field %q", field)
+	}
+	for _, k := range []string{"url", "uri", "database_url"} {
+		if v := str(k); v != "" {
+			return v, nil
+		}
+	}
+	host := str("host")
+	if host == "" {
+		return "", errors.New("secret has no url, uri or host field")
+	}
+	u := url.URL{Scheme: "postgres", Host: host, Path: "/" + FirstNonEmpty(str("dbname"), str("database"), "postgres")}
+	if p := str("port"); p != "" {
+		u.Host = host + ":" + p
+	}
+	if user := FirstNonEmpty(str("username"), str("user")); user != "" {
+		u.User = url.UserPassword(user, str("password"))
+	}
+	return u.String(), nil
+}
+
+// awsSecret calls Secrets Manager GetSecretValue.
+func awsSecret(ctx context.Context, id string) ([]byte, error) {
+	creds, err := awsCredentialsFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	region := sigv4.Region()
+	if region == "" {
+		return nil, errors.New("set AWS_REGION")
+	}
+	body, _ := json.Marshal(map[string]string{"SecretId": id})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, fmt.Sprintf(awsSecretsURL, region), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "secretsmanager.GetSecretValue")
+	sigv4.Sign(req, sigv4.Hash(string(body)), creds, region, "secretsmanager", time.Now().UTC())
+
+	var out struct {
+		SecretString string `json:"SecretString"`
+	}
+	if err := doJSON(req, &out); err != nil {
+		return nil, err
+	}
+	if out.SecretString == "" {
+		return nil, errors.New("secret has no SecretString")
+	}
+	return []byte(out.SecretString), nil
+}
+
+// gcpSecret accesses a Secret Manager secret version (latest by default).
+func gcpSecret(ctx context.Context, ref string) ([]byte, error) {
+	parts := strings.Split(ref, "/")
+	if len(parts) < 2 || len(parts) > 3 || parts[0] == "" || parts[1] == "" {
+		return nil, fmt.Errorf("invalid reference %q: expected <project>/<secret>[/<version>]", ref)
+	}
+	version := "latest"
+	if len(parts) == 3 {
+		version = parts[2]
+	}
+	ts, err := google.DefaultTokenSource(ctx, "https://www.googleapis.com/auth/cloud-platform")
+	if err != nil {
+		return nil, err
+	}
+	tok, err := ts.Token()
+	if err != nil {
+		return nil, err
+	}
+	endpoint := gcpSecretsURL + "projects/" + url.PathEscape(parts[0]) + "/secrets/" + url.PathEscape(parts[1]) +
+		"/versions/" + url.PathEscape(version) + ":access"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	tok.SetAuthHeader(req)
+
+	var out struct {
+		Payload struct {
+			Data string `json:"data"`
+		} `json:"payload"`
+	}
+	if err := doJSON(req, &out); err != nil {
+		return nil, err
+	}
+	return base64.StdEncoding.DecodeString(out.Payload.Data)
+}
+
+// vaultSecret reads a KV secret; KV v2 responses nest the fields in data.data.
+func vaultSecret(ctx context.Context, path string) ([]byte, error) {
+	addr, token := os.Getenv("VAULT_ADDR"), os.Getenv("VAULT_TOKEN")
+	if addr == "" || token == "" {
+		return nil, errors.New("set VAULT_ADDR and VAULT_TOKEN")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimRight(addr, "/")+"/v1/"+strings.TrimLeft(path, "/"), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Vault-Token", token)
+	if ns := os.Getenv("VAULT_NAMESPACE"); ns != "" {
+		req.Header.Set("X-Vault-Namespace", ns)
+	}
+
+	var out struct {
+		Data map[string]json.RawMessage `json:"data"`
+	}
+	if err := doJSON(req, &out); err != nil {
+		return nil, err
+	}
+	if inner, ok := out.Data["data"]; ok && len(out.Data["metadata"]) > 0 {
+		return inner, nil
+	}
+	return json.Marshal(out.Data)
+}
+
+// doJSON sends req and decodes a 200 response into v.
+func doJSON(req *http.Request, v any) error {
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned %s", req.URL.Host, resp.Status)
+	}
+	return json.Unmarshal(body, v)
+}
+
+// FirstNonEmpty returns the first non-empty string of values, or "" when all
+// of them are empty.
+func FirstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
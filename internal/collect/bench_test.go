@@ -0,0 +1,160 @@
+package collect
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// The benchmarks and the performance budget run against a disposable database
+// given by PGHEALTH_BENCH_URL; they create and drop the pghealth_bench schema
+// there and are skipped without it:
+//
+//	PGHEALTH_BENCH_URL=postgres://postgres@localhost/bench go test ./internal/collect -run Budget -bench Run
+const benchURLEnv = "PGHEALTH_BENCH_URL"
+
+// benchSchema holds the seeded tables.
+const benchSchema = "pghealth_bench"
+
+// benchSeedBatch is the tables created per statement, keeping the locks of
+// one transaction well under max_locks_per_transaction.
+const benchSeedBatch = 200
+
+// benchCatalogSizes are the seeded table counts benchmarked.
+var benchCatalogSizes = []int{10, 100, 1000}
+
+// Performance budget on a catalog of budgetTables tables: a whole run must
+// finish within the default -timeout, and each collector within the budget
+// of its class, so a new or changed collector cannot quietly slow down
+// production runs.
+const (
+	budgetTables    = 1000
+	budgetRun       = 30 * time.Second
+	budgetCollector = time.Second     // statistics views (collectorTimeout)
+	budgetHeavy     = 5 * time.Second // catalog scans, EXPLAIN (collectorTimeoutHeavy)
+)
+
+// benchURL returns the benchmark database or skips the test.
+func benchURL(tb testing.TB) string {
+	tb.Helper()
+	url := os.Getenv(benchURLEnv)
+	if url == "" {
+		tb.Skipf("%s is not set", benchURLEnv)
+	}
+	return url
+}
+
+// seedBench recreates benchSchema with n small tables, each with a primary
+// key, a secondary index and 100 rows, and drops it when the test ends.
+func seedBench(tb testing.TB, url string, n int) {
+	tb.Helper()
+	ctx := context.Background()
+	conn, err := pgx.Connect(ctx, url)
+	if err != nil {
+		tb.Fatalf("connect: %v", err)
+	}
+	defer conn.Close(ctx)
+
+	drop := "drop schema if exists " + benchSchema + " cascade"
+	if _, err := conn.Exec(ctx, drop+"; create schema "+benchSchema); err != nil {
+		tb.Fatalf("create schema: %v", err)
+	}
+	for from := 1; from <= n; from += benchSeedBatch {
+		to := min(from+benchSeedBatch-1, n)
+		seed := fmt.Sprintf(`do $$ begin for i in %d..%d loop
+			execute format('create table %s.t%%s (id bigint generated always as identity primary key, a int, b text)', i);
+			execute format('create index on %s.t%%s (a)', i);
+			execute format('insert into %s.t%%s (a, b) select g, md5(g::text) from generate_series(1, 100) g', i);
+		end loop; end $$`, from, to, benchSchema, benchSchema, benchSchema)
+		if _, err := conn.Exec(ctx, seed); err != nil {
+			tb.Fatalf("seed tables %d-%d: %v", from, to, err)
+		}
+	}
+	if _, err := conn.Exec(ctx, "analyze"); err != nil {
+		tb.Fatalf("analyze: %v", err)
+	}
+	tb.Cleanup(func() {
+		conn, err := pgx.Connect(context.Background(), url)
+		if err != nil {
+			tb.Errorf("connect for cleanup: %v", err)
+			return
+		}
+		defer conn.Close(context.Background())
+		if _, err := conn.Exec(context.Background(), drop); err != nil {
+			tb.Errorf("drop schema: %v", err)
+		}
+	})
+}
+
+// benchConfig is the configuration of a default run against url.
+func benchConfig(url string) Config {
+	return Config{URL: url, Timeout: MaxTimeout}
+}
+
+// BenchmarkRun measures a whole run per catalog size, reporting the mean cost
+// of every collector as <name>-ms/op next to the total.
+func BenchmarkRun(b *testing.B) {
+	url := benchURL(b)
+	for _, n := range benchCatalogSizes {
+		b.Run(fmt.Sprintf("tables=%d", n), func(b *testing.B) {
+			seedBench(b, url, n)
+			spent := map[string]time.Duration{}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				res, err := Run(context.Background(), benchConfig(url))
+				if err != nil {
+					b.Fatalf("Run: %v", err)
+				}
+				for _, t := range res.Timings {
+					spent[t.Name] += t.Duration
+				}
+			}
+			b.StopTimer()
+			for name, d := range spent {
+				b.ReportMetric(float64(d.Microseconds())/1000/float64(b.N), name+"-ms/op")
+			}
+		})
+	}
+}
+
+// TestPerformanceBudget runs the collectors on budgetTables tables and fails
+// when the run or a collector exceeds its budget. The first run warms the
+// catalog caches and is not measured.
+func TestPerformanceBudget(t *testing.T) {
+	url := benchURL(t)
+	if testing.Short() {
+		t.Skip("performance budget skipped in short mode")
+	}
+	seedBench(t, url, budgetTables)
+	if _, err := Run(context.Background(), benchConfig(url)); err != nil {
+		t.Fatalf("warm-up run: %v", err)
+	}
+
+	began := time.Now()
+	res, err := Run(context.Background(), benchConfig(url))
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if took := time.Since(began); took > budgetRun {
+		t.Errorf("run took %s on %d tables, budget %s", took.Round(time.Millisecond), budgetTables, budgetRun)
+	}
+	budgets := make(map[string]time.Duration, len(collectors))
+	for _, c := range collectors {
+		budgets[c.name] = budgetCollector
+		if c.heavy {
+			budgets[c.name] = budgetHeavy
+		}
+	}
+	for _, tm := range res.Timings {
+		if tm.Duration > budgets[tm.Name] {
+			t.Errorf("collector %s took %s on %d tables, budget %s", tm.Name, tm.Duration.Round(time.Millisecond), budgetTables, budgets[tm.Name])
+		}
+	}
+	for _, s := range res.Skipped {
+		t.Errorf("collector %s skipped: %s", s.Name, s.Reason)
+	}
+}
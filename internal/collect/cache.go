@@ -0,0 +1,84 @@
+package collect
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// checkpointMaxAge is how old a checkpoint may be to be resumed; older data
+// would no longer describe the same server state.
+const checkpointMaxAge = 24 * time.Hour
+
+// checkpoint is the intermediate state of a run, saved after every collector
+// so an interrupted run can be completed with Config.Resume.
+type checkpoint struct {
+	Target  string    `json:"target"`
+	SavedAt time.Time `json:"saved_at"`
+	Done    []string  `json:"done"`
+	Result  Result    `json:"result"`
+}
+
+// CachePath returns the default checkpoint file for cfg in the user cache
+// directory: one file per target (host:port/db) and set of collector options
+// that change what is collected (e.g. DBs), so a run resumes only the
+// checkpoint of a run that collected the same data.
+func CachePath(cfg Config) string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	replica := ""
+	if cfg.ReplicaURL != "" {
+		replica = TargetName(cfg.ReplicaURL)
+	}
+	key, _ := json.Marshal([]any{
+		TargetName(cfg.URL), replica, cfg.StatsSince, cfg.TopQueries, cfg.MinCalls, cfg.MinMeanTime,
+		cfg.DBs, cfg.RetentionColumns, cfg.AllSettings, cfg.LocalOS, cfg.Delta,
+	})
+	sum := sha256.Sum256(key)
+	return filepath.Join(dir, "pghealth", "run-"+hex.EncodeToString(sum[:8])+".json")
+}
+
+// loadCheckpoint reads the checkpoint for target. A missing, stale or foreign
+// checkpoint yields an empty one.
+func loadCheckpoint(path, target string) (checkpoint, error) {
+	var cp checkpoint
+	b, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return checkpoint{}, nil
+	}
+	if err != nil {
+		return checkpoint{}, err
+	}
+	if err := json.Unmarshal(b, &cp); err != nil {
+		return checkpoint{}, fmt.Errorf("read checkpoint %s: %w", path, err)
+	}
+	if cp.Target != target || time.Since(cp.SavedAt) > checkpointMaxAge {
+		return checkpoint{}, nil
+	}
+	return cp, nil
+}
+
+// save writes the checkpoint atomically; it may contain query texts, so the
+// file is private to the user.
+func (cp checkpoint) save(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	b, err := json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
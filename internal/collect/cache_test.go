@@ -0,0 +1,66 @@
+package collect
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestCheckpoint verifies checkpoints round-trip and that checkpoints of other
+// targets or stale ones are not resumed.
+func TestCheckpoint(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pghealth", "run.json")
+	cp := checkpoint{Target: "db:5432/app", SavedAt: time.Now(), Done: []string{"server", "tables"}}
+	cp.Result.ConnInfo.Version = "PostgreSQL 16.2"
+	cp.Result.Tables = []TableStat{{Name: "orders"}}
+	if err := cp.save(path); err != nil {
+		t.Fatalf("save() error = %v", err)
+	}
+	if fi, err := os.Stat(path); err != nil || fi.Mode().Perm() != 0o600 {
+		t.Errorf("checkpoint mode = %v, %v; expected 0600", fi, err)
+	}
+
+	got, err := loadCheckpoint(path, "db:5432/app")
+	if err != nil {
+		t.Fatalf("loadCheckpoint() error = %v", err)
+	}
+	if len(got.Done) != 2 || got.Result.ConnInfo.Version != "PostgreSQL 16.2" || len(got.Result.Tables) != 1 {
+		t.Errorf("loadCheckpoint() = %+v", got)
+	}
+
+	if other, _ := loadCheckpoint(path, "other:5432/app"); len(other.Done) != 0 {
+		t.Error("checkpoint of another target must not be resumed")
+	}
+	cp.SavedAt = time.Now().Add(-2 * checkpointMaxAge)
+	_ = cp.save(path)
+	if stale, _ := loadCheckpoint(path, "db:5432/app"); len(stale.Done) != 0 {
+		t.Error("stale checkpoint must not be resumed")
+	}
+	if missing, err := loadCheckpoint(filepath.Join(t.TempDir(), "none.json"), "db:5432/app"); err != nil || len(missing.Done) != 0 {
+		t.Errorf("missing checkpoint = %+v, %v", missing, err)
+	}
+}
+
+// TestDoneNames verifies finished collectors are listed in registry order.
+func TestDoneNames(t *testing.T) {
+	got := doneNames(map[string]bool{"tables": true, "server": true, "unknown": true})
+	if len(got) != 2 || got[0] != "server" || got[1] != "tables" {
+		t.Errorf("doneNames() = %v, expected [server tables]", got)
+	}
+}
+
+// TestCachePath verifies checkpoints are per target and collector options and
+// independent of credentials and pacing.
+func TestCachePath(t *testing.T) {
+	a := CachePath(Config{URL: "postgres://u:secret@db:5432/app"})
+	if a != CachePath(Config{URL: "postgres://other@db:5432/app", MaxQPS: 5}) {
+		t.Error("CachePath() must not depend on credentials or pacing")
+	}
+	if a == CachePath(Config{URL: "postgres://u@db:5432/orders"}) {
+		t.Error("CachePath() must differ per database")
+	}
+	if a == CachePath(Config{URL: "postgres://u@db:5432/app", DBs: []string{"orders"}}) {
+		t.Error("CachePath() must differ per -dbs")
+	}
+}
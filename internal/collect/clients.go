@@ -0,0 +1,48 @@
+package collect
+
+import (
+	"context"
+	"regexp"
+	"strings"
+)
+
+// reClientVersion splits an application name into a library and a dotted
+// version: "DBeaver 23.1.0 - Main", "billing/1.4.2", "worker-v2.0".
+var reClientVersion = regexp.MustCompile(`^(.*?[^\s/v-])[\s/-]+v?(\d+(?:\.\d+)+)\b`)
+
+// collectClientFingerprints groups the client backends by application,
+// locality and transport encryption, parsing library versions from the
+// application names.
+func collectClientFingerprints(ctx context.Context, s *session, res *Result) {
+	for _, q := range []string{sqlClientFingerprints, sqlClientFingerprintsNoGSS} {
+		rows, err := s.conn.Query(ctx, q)
+		if err != nil {
+			continue
+		}
+		var out []ClientFingerprint
+		for rows.Next() {
+			var c ClientFingerprint
+			if err := rows.Scan(&c.Application, &c.Local, &c.SSL, &c.SSLVersion, &c.Cipher, &c.GSS, &c.Count); err != nil {
+				continue
+			}
+			c.Library, c.Version = parseClientVersion(c.Application)
+			out = append(out, c)
+		}
+		err = rows.Err()
+		rows.Close()
+		if err == nil {
+			res.ClientFingerprints = out
+			return
+		}
+	}
+}
+
+// parseClientVersion returns the library and version of an application name,
+// or empty strings when it carries no dotted version.
+func parseClientVersion(app string) (library, version string) {
+	m := reClientVersion.FindStringSubmatch(strings.TrimSpace(app))
+	if m == nil {
+		return "", ""
+	}
+	return m[1], m[2]
+}
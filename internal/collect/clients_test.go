@@ -0,0 +1,25 @@
+package collect
+
+import "testing"
+
+// TestParseClientVersion verifies libraries and versions are split from the
+// common application_name shapes.
+func TestParseClientVersion(t *testing.T) {
+	for _, tt := range []struct {
+		app, library, version string
+	}{
+		{"DBeaver 23.1.0 - Main", "DBeaver", "23.1.0"},
+		{"billing/1.4.2", "billing", "1.4.2"},
+		{"worker-v2.0", "worker", "2.0"},
+		{"PostgreSQL JDBC Driver 9.4.1208", "PostgreSQL JDBC Driver", "9.4.1208"},
+		{"psql", "", ""},
+		{"pgAdmin III - Query Tool", "", ""},
+		{"app 2", "", ""},
+		{"", "", ""},
+	} {
+		library, version := parseClientVersion(tt.app)
+		if library != tt.library || version != tt.version {
+			t.Errorf("parseClientVersion(%q) = %q, %q; expected %q, %q", tt.app, library, version, tt.library, tt.version)
+		}
+	}
+}
@@ -0,0 +1,2022 @@
+package collect
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/koltyakov/pghealth/internal/sqlparse"
+)
+
+// Collector timeouts bound each collector on top of the overall run timeout so
+// a single slow catalog query cannot consume the whole budget.
+const (
+	// collectorTimeout applies to collectors reading statistics views.
+	collectorTimeout = 20 * time.Second
+
+	// collectorTimeoutHeavy applies to catalog scans and EXPLAIN, and to
+	// per-database work for each database.
+	collectorTimeoutHeavy = 60 * time.Second
+)
+
+// session is the state shared by collectors during one run.
+type session struct {
+	cfg  Config
+	conn *pgx.Conn
+	host string // host:port serving conn
+	thr  *throttle
+}
+
+// collector is a named unit of collection work. queries lists every statement
+// the collector may execute so the workload can be reviewed without connecting
+// (see DryRun); statements built at runtime use <placeholders>.
+type collector struct {
+	name     string
+	note     string // when the collector runs, if not always
+	heavy    bool   // catalog scans, EXPLAIN and per-database work rather than statistics views
+	perDB    bool   // runs once per database listed in Config.DBs
+	offload  bool   // runs on the standby of Config.ReplicaURL when set: catalog, planner or standby-only data
+	queries  []string
+	requires []requirement // privileges and extensions for complete data (see Doctor)
+	run      func(ctx context.Context, s *session, res *Result)
+}
+
+// collectors run in order; later collectors rely on data gathered earlier
+// (per-database collection and plan advice use tables, indexes and statements).
+var collectors = []collector{
+	{name: "server", run: collectServer,
+		queries: []string{sqlVersion, sqlCurrentDB, sqlCurrentUser, sqlMaxConnections, sqlSSL, sqlPasswordEnc, sqlStartTime, sqlInRecovery, sqlIsSuperuser, sqlHasPgMonitor, sqlClock}},
+	{name: "extensions", run: collectExtensions,
+		queries: []string{sqlPSSExtension, sqlPSSRelation, sqlPSSFunction, sqlPSSProbe, sqlPSSSchema, sqlPGSMSchema, pgsmProbeQuery("")}},
+	{name: "activity", requires: []requirement{reqStatsRole}, run: collectActivity, queries: []string{sqlActivity}},
+	{name: "databases", requires: []requirement{reqStatsRole}, run: collectDatabases, queries: []string{sqlDatabases}},
+	{name: "settings", run: collectSettings, queries: []string{sqlSettings}},
+	{name: "non-default-settings", note: "with -all-settings", run: collectNonDefaultSettings, queries: []string{sqlNonDefaultSettings}},
+	{name: "config-files", note: "superuser, or SELECT on pg_file_settings and pg_hba_file_rules", run: collectConfigFiles,
+		queries: []string{sqlFileSettingErrors, sqlHBAFileErrors}},
+	{name: "tables", heavy: true, run: collectTables,
+		queries: []string{sqlTableStats, sqlTablesBackfill, sqlTablesFallback}},
+	{name: "indexes", heavy: true, run: collectIndexes, queries: []string{sqlIndexStats}},
+	{name: "tablespaces", run: collectTablespaces, queries: []string{sqlTablespaces, sqlRelationTablespaces}},
+	{name: "databases-extra", requires: []requirement{reqConnect}, note: "once per database listed in -dbs", heavy: true, perDB: true, run: collectExtraDatabases,
+		queries: []string{sqlTableStats, sqlIndexStats, sqlRelationTablespaces, sqlIndexUsageLow, sqlTableIndexCounts}},
+	{name: "statements", requires: []requirement{reqPgStatStatements, reqStatsRole}, note: "from pg_stat_monitor (2.0+) when visible, else pg_stat_statements; falls back to total_time/mean_time before PostgreSQL 13", run: collectStatements,
+		queries: append(statementQueries(), monitorQueries()...)},
+	{name: "plans", offload: true, requires: []requirement{reqPgStatStatements}, note: "for top SELECT/WITH statements, without ANALYZE", heavy: true, run: collectPlans,
+		queries: []string{sqlPlanPrepare, sqlPlanExecute, sqlPlanDeallocate, sqlPlanExplain}},
+	{name: "column-stats", offload: true, note: "for the columns top statements filter and join on", run: collectColumnStats,
+		queries: []string{sqlColumnStats}},
+	{name: "retention", note: "for tables of 1 GB and up with a row age column (-retention-columns)", heavy: true, run: collectRetention,
+		queries: []string{sqlRetentionTables}},
+	{name: "connections", requires: []requirement{reqStatsRole}, run: collectConnections,
+		queries: []string{sqlTotalConnections, sqlConnsByClient, sqlRoleIdle, sqlSessionStats}},
+	{name: "client-fingerprints", requires: []requirement{reqStatsRole}, run: collectClientFingerprints,
+		queries: []string{sqlClientFingerprints, sqlClientFingerprintsNoGSS}},
+	{name: "cache-hit", run: collectCacheHit,
+		queries: []string{sqlCacheHitCurrent, sqlCacheHitOverall, sqlCacheHitByDB}},
+	{name: "blocking", requires: []requirement{reqStatsRole}, run: collectBlocking, queries: []string{sqlBlocking}},
+	{name: "long-running", requires: []requirement{reqStatsRole}, run: collectLongRunning, queries: []string{sqlLongRunning}},
+	{name: "autovacuum", requires: []requirement{reqStatsRole}, run: collectAutovacuum, queries: []string{sqlAutovacuum}},
+	{name: "index-usage", run: collectIndexUsage,
+		queries: []string{sqlIndexUsageLow, sqlIndexUsageLowAll}},
+	{name: "index-counts", run: collectIndexCounts, queries: []string{sqlTableIndexCounts}},
+	{name: "table-bloat", run: collectTableBloat, queries: []string{sqlTableBloat}},
+	{name: "index-bloat", run: collectIndexBloat, queries: []string{sqlIndexBloat}},
+	{name: "replication", requires: []requirement{reqStatsRole}, run: collectReplication, queries: []string{sqlReplication}},
+	{name: "standby-conflicts", offload: true, note: "when the server (or -replica-url) is a standby", run: collectStandbyConflicts,
+		queries: []string{sqlInRecovery, sqlStandbyConflicts, sqlStandbySettings}},
+	{name: "wait-events", requires: []requirement{reqStatsRole}, run: collectWaitEvents, queries: []string{sqlWaitEvents}},
+	{name: "functions", run: collectFunctions, queries: []string{sqlFunctions}},
+	{name: "wal", run: collectWAL, queries: []string{sqlHasStatWAL, sqlStatWAL}},
+	{name: "wal-archiving", run: collectWALArchiving, queries: []string{sqlWALArchiver, sqlCurrentWALFile}},
+	{name: "progress", requires: []requirement{reqStatsRole}, run: collectProgress,
+		queries: []string{sqlProgressCreateIndex, sqlProgressAnalyze, sqlProgressCopy, sqlProgressCluster, sqlProgressBasebackup}},
+	{name: "checkpoints", run: collectCheckpoints, queries: []string{sqlCheckpoints}},
+	{name: "memory", requires: []requirement{reqPgBuffercache, reqStatsRole}, run: collectMemory,
+		queries: []string{sqlBgwriterBuffers, sqlBlockSize, sqlSharedBuffers, sqlHasBuffercache, sqlBuffercache, sqlTempFilesDB}},
+	{name: "os-memory", note: "when the server runs on this machine (Linux) or with -local-os", run: collectOSMemory, queries: []string{sqlBackendAddr}},
+	{name: "template1", note: "objects with a connection to template1", run: collectTemplate1,
+		queries: []string{sqlTemplateLocale, sqlTemplateObjects, sqlExtensions}},
+	{name: "default-privileges", run: collectDefaultPrivileges, queries: []string{sqlDefaultPrivileges}},
+	{name: "data-directory", note: "path with superuser or pg_read_all_settings; WAL location when the server runs on this machine", run: collectDataDirectory,
+		queries: []string{sqlDataDirectory, sqlBackendAddr}},
+	{name: "os-cpu", note: "with -local-os (Linux)", run: collectOSCPU, queries: []string{sqlBackendAddr}},
+	{name: "io", run: collectIO, queries: []string{sqlIOStats}},
+	{name: "locks", run: collectLocks, queries: []string{sqlLocks}},
+	{name: "subtransactions", requires: []requirement{reqStatsRole}, note: "per-session counts on PostgreSQL 16+", run: collectSubtransactions,
+		queries: []string{sqlSubtransSLRU, sqlSubxactBackends}},
+	{name: "lock-hotspots", requires: []requirement{reqStatsRole}, run: collectLockHotspots, queries: []string{sqlLockHotspots}},
+	{name: "vacuum-horizon", requires: []requirement{reqStatsRole}, run: collectVacuumHorizon, queries: []string{sqlXminHolders, sqlHorizonSettings}},
+	{name: "replication-slots", run: collectReplicationSlots, queries: []string{sqlReplicationSlots}},
+	{name: "ha-metadata", note: "when the connected database holds repmgr or pg_auto_failover monitor metadata", run: collectHAMetadata,
+		queries: []string{sqlRepmgrNodes, sqlAutoFailoverNodes}},
+	{name: "temp-files", requires: []requirement{reqStatsRole}, run: collectTempFiles, queries: []string{sqlTempFiles}},
+	{name: "extension-stats", run: collectExtensionStats, queries: []string{sqlExtensions}},
+	{name: "extension-stats-extra", requires: []requirement{reqConnect}, note: "once per database listed in -dbs", heavy: true, perDB: true, run: collectExtraExtensionStats,
+		queries: []string{sqlExtensions}},
+	{name: "xid-age", run: collectXIDAge, queries: []string{sqlXIDAge, sqlNextXID}},
+	{name: "freeze-forecast", run: collectFreezeForecast, queries: []string{sqlNextXID, sqlFreezeTables}},
+	{name: "idle-in-transaction", requires: []requirement{reqStatsRole}, run: collectIdleInTransaction, queries: []string{sqlIdleInTransaction}},
+	{name: "stale-stats", run: collectStaleStats, queries: []string{sqlStaleStats}},
+	{name: "duplicate-indexes", heavy: true, run: collectDuplicateIndexes, queries: []string{sqlDuplicateIndexes}},
+	{name: "redundant-indexes", heavy: true, run: collectRedundantIndexes, queries: []string{sqlRedundantIndexes}},
+	{name: "invalid-indexes", offload: true, run: collectInvalidIndexes, queries: []string{sqlInvalidIndexes}},
+	{name: "fk-missing-indexes", offload: true, heavy: true, run: collectFKMissingIndexes, queries: []string{sqlFKMissingIndexes}},
+	{name: "sequences", run: collectSequences, queries: []string{sqlSequences}},
+	{name: "prepared-xacts", run: collectPreparedXacts, queries: []string{sqlPreparedXacts}},
+	{name: "login-roles", note: "password hash types and pg_hba.conf password methods for superusers", run: collectLoginRoles,
+		queries: []string{sqlLoginRoles, sqlRolePasswords, sqlRolePasswordsShadow, sqlHBAPasswordRules}},
+}
+
+// CollectorNames lists the collectors in execution order.
+func CollectorNames() []string {
+	names := make([]string, len(collectors))
+	for i, c := range collectors {
+		names[i] = c.name
+	}
+	return names
+}
+
+// isCollector reports whether name is a registered collector.
+func isCollector(name string) bool {
+	for _, c := range collectors {
+		if c.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// collectorTimeout returns the timeout of c, honoring CollectorTimeouts.
+// Per-database collectors get their class timeout for each database in DBs.
+func (cfg Config) collectorTimeout(c collector) time.Duration {
+	if d, ok := cfg.CollectorTimeouts[c.name]; ok {
+		return d
+	}
+	d := collectorTimeout
+	if c.heavy {
+		d = collectorTimeoutHeavy
+	}
+	if c.perDB {
+		d *= time.Duration(max(1, len(cfg.DBs)))
+	}
+	return d
+}
+
+// topFilter bounds the top statement lists by TopQueries, MinCalls and
+// MinMeanTime.
+func (cfg Config) topFilter() topFilter {
+	f := topFilter{limit: cfg.TopQueries, minCalls: cfg.MinCalls, minMeanMs: float64(cfg.MinMeanTime) / float64(time.Millisecond)}
+	if f.limit <= 0 {
+		f.limit = DefaultTopQueries
+	}
+	return f
+}
+
+// statementQueries lists the pg_stat_statements statements for review, using
+// the PostgreSQL 13+ column names and an unqualified relation.
+func statementQueries() []string {
+	qs := []string{sqlPSSResetInfo, sqlPSSResetDatabase, sqlPSSIOCols, sqlPSSIOColsInSchema, sqlPSSBlockCols, sqlPSSBlockColsInSchema}
+	for _, ord := range []pssOrder{orderByTotal, orderByCPUApprox, orderByIO, orderByIOBlocks, orderByCalls} {
+		qs = append(qs, pssQuery("", "total_exec_time", "mean_exec_time", ord, ord != orderByIOBlocks, true, defaultTopFilter))
+	}
+	return append(qs,
+		pssLoadQuery(qualifiedPSS(""), "total_exec_time", pssGroupByRole),
+		pssLoadQuery(qualifiedPSS(""), "total_exec_time", pssGroupByDatabase),
+		pssAppLoadQuery(qualifiedPSS(""), "total_exec_time"))
+}
+
+// monitorQueries lists the pg_stat_monitor statements used instead of
+// statementQueries when the extension is visible, for the whole bucket range.
+func monitorQueries() []string {
+	rel := pgsmRelation("", 0)
+	qs := []string{sqlPGSMIOCols, sqlPGSMBlockCols, pgsmStartQuery(rel), sqlPGSMQueryMaxLen}
+	for _, ord := range []pssOrder{orderByTotal, orderByCPUApprox, orderByIO, orderByIOBlocks, orderByCalls} {
+		qs = append(qs, pgsmQuery(rel, ord, ord != orderByIOBlocks, true, defaultTopFilter))
+	}
+	return append(qs, pgsmRangeQuery(""), pgsmHistogramQuery(rel),
+		pssLoadQuery(rel, "total_exec_time", pssGroupByRole),
+		pssLoadQuery(rel, "total_exec_time", pssGroupByDatabase),
+		pssAppLoadQuery(rel, "total_exec_time"))
+}
+
+// DryRun writes every statement Run would execute, grouped by collector with
+// its timeout, without connecting. The output is a SQL script annotated with
+// comments so it can be reviewed or whitelisted before access is granted.
+func DryRun(w io.Writer, cfg Config) error {
+	var b strings.Builder
+	b.WriteString("-- pghealth dry run: statements executed per collector (no connection was made)\n")
+	fmt.Fprintf(&b, "-- overall timeout: %s\n", cfg.Timeout)
+	if cfg.StatementTimeout > 0 {
+		fmt.Fprintf(&b, "-- session: statement_timeout = %d (ms)\n", cfg.StatementTimeout.Milliseconds())
+	}
+	if cfg.RepeatableRead {
+		b.WriteString("-- session: default_transaction_isolation = 'repeatable read'\n")
+	}
+	if cfg.SoftDeadline > 0 {
+		fmt.Fprintf(&b, "-- soft deadline: %s (later collectors are skipped)\n", cfg.SoftDeadline)
+	}
+	if cfg.MaxQPS > 0 || cfg.QueryDelay > 0 {
+		fmt.Fprintf(&b, "-- pacing: max %g queries/s, %s delay before each query\n", cfg.MaxQPS, cfg.QueryDelay)
+	}
+	for _, c := range collectors {
+		fmt.Fprintf(&b, "\n-- collector: %s (timeout %s)\n", c.name, cfg.collectorTimeout(c))
+		if c.note != "" {
+			fmt.Fprintf(&b, "-- runs %s\n", c.note)
+		}
+		if c.perDB && len(cfg.DBs) > 0 {
+			fmt.Fprintf(&b, "-- databases: %s\n", strings.Join(cfg.DBs, ", "))
+		}
+		if c.offload && cfg.ReplicaURL != "" {
+			b.WriteString("-- runs on the replica (-replica-url)\n")
+		}
+		for _, q := range c.queries {
+			b.WriteString(q)
+			b.WriteString(";\n")
+		}
+	}
+	if cfg.Delta > 0 {
+		fmt.Fprintf(&b, "\n-- delta sampling: when the run starts and again %s later\n", cfg.Delta)
+		for _, q := range []string{sqlDeltaDatabases, sqlDeltaCheckpointer, sqlDeltaBgwriter, sqlDeltaWAL, sqlPSSSchema,
+			pssDeltaQuery("", "total_exec_time"), pssDeltaQuery("", "total_time")} {
+			b.WriteString(q)
+			b.WriteString(";\n")
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// collectServer reads version, identity and role information.
+func collectServer(ctx context.Context, s *session, res *Result) {
+	conn := s.conn
+	_ = queryRow(ctx, conn, sqlVersion, &res.ConnInfo.Version)
+	_ = queryRow(ctx, conn, sqlCurrentDB, &res.ConnInfo.CurrentDB)
+	_ = queryRow(ctx, conn, sqlCurrentUser, &res.ConnInfo.CurrentUser)
+	_ = queryRow(ctx, conn, sqlMaxConnections, &res.ConnInfo.MaxConnections)
+	_ = queryRow(ctx, conn, sqlSSL, &res.ConnInfo.SSL)
+	_ = queryRow(ctx, conn, sqlPasswordEnc, &res.ConnInfo.PasswordEncryption)
+	_ = queryRow(ctx, conn, sqlStartTime, &res.ConnInfo.StartTime)
+	_ = queryRow(ctx, conn, sqlInRecovery, &res.ConnInfo.InRecovery)
+	res.ConnInfo.Host = s.host
+
+	// Is superuser
+	_ = queryRow(ctx, conn, sqlIsSuperuser, &res.ConnInfo.IsSuperuser)
+
+	// role membership (pg_monitor)
+	var hasMonitor bool
+	_ = queryRow(ctx, conn, sqlHasPgMonitor, &hasMonitor)
+	res.Roles.HasPgMonitor = hasMonitor
+
+	// Clock skew against this machine, taking the round trip midpoint as the
+	// moment the server read its clock
+	ci := &res.ConnInfo
+	var serverNow time.Time
+	sent := time.Now()
+	if err := conn.QueryRow(ctx, sqlClock).Scan(&serverNow, &ci.TimeZone, &ci.LogTimeZone, &ci.TimeZoneOffset, &ci.LogTimeZoneOffset); err == nil {
+		received := time.Now()
+		ci.ClockSkew = serverNow.Sub(sent.Add(received.Sub(sent) / 2))
+		ci.ClientTimeZone, ci.ClientTimeZoneOffset = received.Zone()
+	}
+}
+
+// collectExtensions detects pg_stat_statements and pg_stat_monitor and
+// resolves their schemas.
+func collectExtensions(ctx context.Context, s *session, res *Result) {
+	res.Extensions.PgStatStatements = hasPgStatStatements(ctx, s.conn)
+	if res.Extensions.PgStatStatements {
+		res.Extensions.PgStatStatementsSchema = findPgStatStatementsSchema(ctx, s.conn)
+	}
+	res.Extensions.PgStatMonitorSchema, res.Extensions.PgStatMonitor = findPgStatMonitor(ctx, s.conn)
+}
+
+// collectActivity counts sessions by database and state.
+func collectActivity(ctx context.Context, s *session, res *Result) {
+	rows, err := s.conn.Query(ctx, sqlActivity)
+	if err != nil {
+		return
+	}
+	for rows.Next() {
+		var a Activity
+		_ = rows.Scan(&a.Datname, &a.State, &a.Count)
+		res.Activity = append(res.Activity, a)
+	}
+	rows.Close()
+}
+
+// collectDatabases reads database sizes and connection counts.
+func collectDatabases(ctx context.Context, s *session, res *Result) {
+	rows, err := s.conn.Query(ctx, sqlDatabases)
+	if err != nil {
+		return
+	}
+	for rows.Next() {
+		var db Database
+		_ = rows.Scan(&db.Name, &db.SizeBytes, &db.Tablespaces, &db.ConnCount, &db.Encoding, &db.Collate, &db.Ctype,
+			&db.LocaleProvider, &db.Locale, &db.Applications)
+		res.DBs = append(res.DBs, db)
+	}
+	rows.Close()
+}
+
+// collectSettings reads the subset of settings the analysis looks at.
+func collectSettings(ctx context.Context, s *session, res *Result) {
+	res.Settings = append(res.Settings, querySettings(ctx, s.conn, sqlSettings)...)
+}
+
+// collectNonDefaultSettings reads every setting changed from its default
+// when Config.AllSettings is set.
+func collectNonDefaultSettings(ctx context.Context, s *session, res *Result) {
+	if !s.cfg.AllSettings {
+		return
+	}
+	res.NonDefaultSettings = append(res.NonDefaultSettings, querySettings(ctx, s.conn, sqlNonDefaultSettings)...)
+}
+
+// collectConfigFiles reads configuration file entries that will not apply
+// on the next reload or restart.
+func collectConfigFiles(ctx context.Context, s *session, res *Result) {
+	cf := &ConfigFiles{}
+	if rows, err := s.conn.Query(ctx, sqlFileSettingErrors); err == nil {
+		for rows.Next() {
+			var fs FileSetting
+			if err := rows.Scan(&fs.File, &fs.Line, &fs.Name, &fs.Val, &fs.Applied, &fs.Error); err == nil {
+				cf.Settings = append(cf.Settings, fs)
+			}
+		}
+		rows.Close()
+	}
+	if rows, err := s.conn.Query(ctx, sqlHBAFileErrors); err == nil {
+		for rows.Next() {
+			var r HBARule
+			if err := rows.Scan(&r.Line, &r.Type, &r.Database, &r.User, &r.Address, &r.Method, &r.Error); err == nil {
+				cf.HBARules = append(cf.HBARules, r)
+			}
+		}
+		rows.Close()
+	}
+	if len(cf.Settings) > 0 || len(cf.HBARules) > 0 {
+		res.ConfigFiles = cf
+	}
+}
+
+// querySettings reads pg_settings rows selected by q (see sqlSettings).
+func querySettings(ctx context.Context, conn *pgx.Conn, q string) []Setting {
+	rows, err := conn.Query(ctx, q)
+	if err != nil {
+		return nil
+	}
+	defer rows.Close()
+	var out []Setting
+	for rows.Next() {
+		var st Setting
+		_ = rows.Scan(&st.Name, &st.Val, &st.Unit, &st.Source, &st.BootVal, &st.MinVal, &st.MaxVal, &st.Context, &st.Category, &st.PendingRestart)
+		out = append(out, st)
+	}
+	return out
+}
+
+// collectTables streams table statistics and sizes (excluding system schemas)
+// into bounded rankings, so memory does not grow with the catalog.
+func collectTables(ctx context.Context, s *session, res *Result) {
+	conn := s.conn
+	sink := newTableSink(&res.Catalog)
+	if rows, err := conn.Query(ctx, sqlTableStats); err == nil {
+		for rows.Next() {
+			var t TableStat
+			_ = rows.Scan(&t.Schema, &t.Name, &t.SeqScans, &t.IdxScans, &t.NLiveTup, &t.NDeadTup, &t.SizeBytes)
+			t.Database = res.ConnInfo.CurrentDB
+			// rough bloat heuristic
+			if t.NLiveTup > 0 {
+				t.BloatPct = float64(t.NDeadTup) / float64(t.NLiveTup+t.NDeadTup) * 100
+			}
+			sink.add(t)
+		}
+		rows.Close()
+		// Backfill user tables missing from the statistics views for coverage
+		if rows2, err2 := conn.Query(ctx, sqlTablesBackfill); err2 == nil {
+			for rows2.Next() {
+				var schema, name string
+				var nlive, size int64
+				_ = rows2.Scan(&schema, &name, &nlive, &size)
+				sink.add(TableStat{Database: res.ConnInfo.CurrentDB, Schema: schema, Name: name, SeqScans: 0, IdxScans: 0, NLiveTup: nlive, NDeadTup: 0, SizeBytes: size})
+			}
+			rows2.Close()
+		}
+	}
+
+	// Fallback: if no rows (permissions or empty stats), derive from pg_class/pg_namespace
+	if res.Catalog.Tables == 0 {
+		if rows, err := conn.Query(ctx, sqlTablesFallback); err == nil {
+			for rows.Next() {
+				var t TableStat
+				_ = rows.Scan(&t.Schema, &t.Name, &t.SeqScans, &t.IdxScans, &t.NLiveTup, &t.NDeadTup, &t.SizeBytes)
+				t.Database = res.ConnInfo.CurrentDB
+				sink.add(t)
+			}
+			rows.Close()
+		}
+	}
+	res.Tables = append(res.Tables, sink.tables()...)
+}
+
+// collectIndexes streams index statistics into bounded rankings and derives
+// unused index and missing index hints from them and the table statistics.
+func collectIndexes(ctx context.Context, s *session, res *Result) {
+	sink := newIndexSink(res.Tables, &res.Catalog)
+	if rows, err := s.conn.Query(ctx, sqlIndexStats); err == nil {
+		for rows.Next() {
+			var i IndexStat
+			var primary, unique, exclusion, replIdent bool
+			_ = rows.Scan(&i.Schema, &i.Table, &i.Name, &i.Scans, &i.SizeBytes, &i.DDL, &i.TupRead, &i.TupFetch, &primary, &unique, &exclusion, &replIdent)
+			i.Constraint = indexConstraint(primary, unique, exclusion, replIdent)
+			i.Database = res.ConnInfo.CurrentDB
+			sink.add(i)
+		}
+		rows.Close()
+	}
+	res.Indexes = append(res.Indexes, sink.indexes()...)
+
+	// unused indexes (idx_scan=0 and size > some threshold)
+	res.IndexUnused = append(res.IndexUnused, sink.unusedIndexes()...)
+	res.LowSelectivityIndexes = append(res.LowSelectivityIndexes, sink.lowSelectivityIndexes()...)
+
+	// missing index hints (heuristic based on high seq_scan and low idx_scan)
+	for _, t := range res.Tables {
+		if t.SeqScans > 1000 && t.IdxScans < 100 { // simple heuristic
+			res.MissingIndexes = append(res.MissingIndexes, MissingIndexHint{Schema: t.Schema, Table: t.Name, Columns: unknownColumns, EstBenefit: "High (heuristic)"})
+		}
+	}
+}
+
+// collectTablespaces lists the tablespaces and records which collected tables
+// and indexes of the current database sit outside its default tablespace.
+func collectTablespaces(ctx context.Context, s *session, res *Result) {
+	rows, err := s.conn.Query(ctx, sqlTablespaces)
+	if err != nil {
+		return
+	}
+	for rows.Next() {
+		var t Tablespace
+		if err := rows.Scan(&t.Name, &t.Location, &t.SizeBytes, &t.Options); err == nil {
+			res.Tablespaces = append(res.Tablespaces, t)
+		}
+	}
+	rows.Close()
+	if len(res.Tablespaces) < 2 {
+		return // only pg_default: nothing can sit elsewhere
+	}
+	placed := relationTablespaces(ctx, s.conn)
+	for i := range res.Tables {
+		if t := &res.Tables[i]; t.Database == res.ConnInfo.CurrentDB {
+			t.Tablespace = placed[relationKey("", t.Schema, t.Name)]
+		}
+	}
+	for i := range res.Indexes {
+		if ix := &res.Indexes[i]; ix.Database == res.ConnInfo.CurrentDB {
+			ix.Tablespace = placed[relationKey("", ix.Schema, ix.Name)]
+		}
+	}
+}
+
+// relationTablespaces maps the tables and indexes placed outside the
+// database's default tablespace to their tablespace.
+func relationTablespaces(ctx context.Context, conn *pgx.Conn) map[string]string {
+	out := map[string]string{}
+	rows, err := conn.Query(ctx, sqlRelationTablespaces)
+	if err != nil {
+		return out
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var schema, name, ts string
+		if err := rows.Scan(&schema, &name, &ts); err == nil {
+			out[relationKey("", schema, name)] = ts
+		}
+	}
+	return out
+}
+
+// collectExtraDatabases appends tables and indexes of the databases listed in
+// cfg.DBs by connecting to each of them.
+func collectExtraDatabases(ctx context.Context, s *session, res *Result) {
+	for _, db := range s.cfg.DBs {
+		if ctx.Err() != nil {
+			return
+		}
+		if db == "" || db == res.ConnInfo.CurrentDB {
+			continue
+		}
+		// Build URL for target DB by replacing current_database()
+		targetURL := s.cfg.URL
+		// naive replace: if path component exists, swap last segment; otherwise append
+		// This is a simple heuristic; for complex URLs, users should pass a URL to the target DB directly.
+		if i := strings.LastIndex(targetURL, "/"); i != -1 {
+			targetURL = targetURL[:i+1] + db
+		} else {
+			targetURL += "/" + db
+		}
+		ctxDB, cancelDB := context.WithTimeout(ctx, 10*time.Second)
+		dbConn, err := connect(ctxDB, s.cfg, targetURL, s.thr)
+		cancelDB()
+		if err != nil {
+			res.Errors = append(res.Errors, fmt.Sprintf("db '%s': %v", db, err))
+			continue
+		}
+		// Collect tables (exclude system schemas) and indexes into bounded rankings
+		tables := newTableSink(&res.Catalog)
+		if rows, err := dbConn.Query(ctx, sqlTableStats); err == nil {
+			for rows.Next() {
+				var t TableStat
+				_ = rows.Scan(&t.Schema, &t.Name, &t.SeqScans, &t.IdxScans, &t.NLiveTup, &t.NDeadTup, &t.SizeBytes)
+				t.Database = db
+				if t.NLiveTup > 0 {
+					t.BloatPct = float64(t.NDeadTup) / float64(t.NLiveTup+t.NDeadTup) * 100
+				}
+				tables.add(t)
+			}
+			rows.Close()
+		}
+		kept := tables.tables()
+		indexes := newIndexSink(kept, &res.Catalog)
+		if rows, err := dbConn.Query(ctx, sqlIndexStats); err == nil {
+			for rows.Next() {
+				var i IndexStat
+				var primary, unique, exclusion, replIdent bool
+				_ = rows.Scan(&i.Schema, &i.Table, &i.Name, &i.Scans, &i.SizeBytes, &i.DDL, &i.TupRead, &i.TupFetch, &primary, &unique, &exclusion, &replIdent)
+				i.Constraint = indexConstraint(primary, unique, exclusion, replIdent)
+				i.Database = db
+				indexes.add(i)
+			}
+			rows.Close()
+		}
+		keptIndexes := indexes.indexes()
+		if len(res.Tablespaces) > 1 {
+			placed := relationTablespaces(ctx, dbConn)
+			for i := range kept {
+				kept[i].Tablespace = placed[relationKey("", kept[i].Schema, kept[i].Name)]
+			}
+			for i := range keptIndexes {
+				keptIndexes[i].Tablespace = placed[relationKey("", keptIndexes[i].Schema, keptIndexes[i].Name)]
+			}
+		}
+		res.Tables = append(res.Tables, kept...)
+		res.Indexes = append(res.Indexes, keptIndexes...)
+		// Derive unused indexes for that DB
+		res.IndexUnused = append(res.IndexUnused, indexes.unusedIndexes()...)
+		res.LowSelectivityIndexes = append(res.LowSelectivityIndexes, indexes.lowSelectivityIndexes()...)
+
+		// Collect lowest index usage tables for that DB
+		if rows, err := dbConn.Query(ctx, sqlIndexUsageLow); err == nil {
+			for rows.Next() {
+				var iu IndexUsage
+				_ = rows.Scan(&iu.Schema, &iu.Table, &iu.IndexUsagePct, &iu.Rows)
+				iu.Database = db
+				res.IndexUsageLow = append(res.IndexUsageLow, iu)
+			}
+			rows.Close()
+		}
+
+		// Collect tables with index counts for that DB
+		if rows, err := dbConn.Query(ctx, sqlTableIndexCounts); err == nil {
+			for rows.Next() {
+				var tic TableIndexCount
+				_ = rows.Scan(&tic.Schema, &tic.Name, &tic.IndexCount, &tic.SizeBytes, &tic.RowCount, &tic.DeadRows, &tic.BloatPct)
+				tic.Database = db
+				res.TablesWithIndexCount = append(res.TablesWithIndexCount, tic)
+			}
+			rows.Close()
+		}
+		dbConn.Close(ctx)
+	}
+}
+
+// collectStatements reads the top pg_stat_statements entries and load
+// attribution, or those of pg_stat_monitor when it is visible.
+func collectStatements(ctx context.Context, s *session, res *Result) {
+	f := s.cfg.topFilter()
+	res.Statements.MinCalls, res.Statements.MinMeanTime = f.minCalls, f.minMeanMs
+	if res.Extensions.PgStatMonitor {
+		collectMonitorStatements(ctx, s, res)
+		return
+	}
+	if !res.Extensions.PgStatStatements {
+		return
+	}
+	res.Statements.Source = SourcePgStatStatements
+	conn := s.conn
+	// Get stats reset time
+	var statsReset time.Time
+	// Try pg_stat_statements_info first (PG13+)
+	err := queryRow(ctx, conn, sqlPSSResetInfo, &statsReset)
+	if err != nil {
+		// Fallback to pg_stat_database for older versions
+		_ = queryRow(ctx, conn, sqlPSSResetDatabase, &statsReset)
+	}
+	res.Statements.StatsResetTime = statsReset
+	if !statsReset.IsZero() {
+		res.Statements.StatsDuration = time.Since(statsReset)
+	}
+
+	// Check if a time window filter is configured
+	var sinceFilter time.Time
+	if s.cfg.StatsSince != "" {
+		dur, err := time.ParseDuration(s.cfg.StatsSince)
+		if err == nil {
+			sinceFilter = time.Now().Add(-dur)
+		}
+	}
+
+	// If filter is set and later than stats reset, skip collection
+	if !sinceFilter.IsZero() && !statsReset.IsZero() && sinceFilter.After(statsReset) {
+		res.Statements.SkippedReason = fmt.Sprintf("pg_stat_statements data is older than the requested window (%s).", s.cfg.StatsSince)
+		return
+	}
+
+	schema := res.Extensions.PgStatStatementsSchema
+	hasIO := hasPSSIOCols(ctx, conn, schema)
+	hasBlk := hasPSSBlockCols(ctx, conn, schema)
+	// Top by total execution time
+	if sts, ok := fetchPSS(ctx, conn, s.cfg.topFilter(), schema, orderByTotal, hasIO, hasBlk); ok {
+		res.Statements.TopByTotalTime = sts
+	}
+	// Top by CPU time (approx = total - IO)
+	if hasIO {
+		if sts, ok := fetchPSS(ctx, conn, s.cfg.topFilter(), schema, orderByCPUApprox, hasIO, hasBlk); ok {
+			res.Statements.TopByCPU = sts
+		}
+	}
+	// Top by IO time
+	if hasIO {
+		if sts, ok := fetchPSS(ctx, conn, s.cfg.topFilter(), schema, orderByIO, hasIO, hasBlk); ok {
+			res.Statements.TopByIO = sts
+		}
+	}
+	// Alternative IO ranking by block counts if IO time not available
+	if !hasIO && hasBlk {
+		if sts, ok := fetchPSS(ctx, conn, s.cfg.topFilter(), schema, orderByIOBlocks, false, hasBlk); ok {
+			res.Statements.TopByIOBlocks = sts
+		}
+	}
+	// Top by calls
+	if sts, ok := fetchPSS(ctx, conn, s.cfg.topFilter(), schema, orderByCalls, hasIO, hasBlk); ok {
+		res.Statements.TopByCalls = sts
+	}
+	// Load attribution by role and database
+	rel := qualifiedPSS(schema)
+	res.Statements.ByRole = fetchPSSLoad(ctx, conn, rel, pssGroupByRole)
+	res.Statements.ByDatabase = fetchPSSLoad(ctx, conn, rel, pssGroupByDatabase)
+	res.Statements.ByApp = fetchPSSAppLoad(ctx, conn, rel)
+	finishStatements(&res.Statements)
+}
+
+// collectMonitorStatements reads the top statements and load attribution from
+// pg_stat_monitor, summed over its time buckets. -stats-since limits the
+// buckets read rather than skipping collection, and every listed statement
+// keeps its response time histogram, client addresses and comments.
+func collectMonitorStatements(ctx context.Context, s *session, res *Result) {
+	conn := s.conn
+	var window time.Duration
+	if s.cfg.StatsSince != "" {
+		if dur, err := time.ParseDuration(s.cfg.StatsSince); err == nil {
+			window = dur
+		}
+	}
+	schema := res.Extensions.PgStatMonitorSchema
+	rel := pgsmRelation(schema, window)
+	res.Statements.Source = SourcePgStatMonitor
+
+	// The oldest bucket read starts the window the statistics cover
+	var start time.Time
+	if err := queryRow(ctx, conn, pgsmStartQuery(rel), &start); err == nil {
+		res.Statements.StatsResetTime = start
+		res.Statements.StatsDuration = time.Since(start)
+	}
+
+	hasIO := hasPGSMCols(ctx, conn, sqlPGSMIOCols, schema)
+	hasBlk := hasPGSMCols(ctx, conn, sqlPGSMBlockCols, schema)
+	for _, l := range []struct {
+		ord  pssOrder
+		dst  *[]Statement
+		want bool
+	}{
+		{orderByTotal, &res.Statements.TopByTotalTime, true},
+		{orderByCPUApprox, &res.Statements.TopByCPU, hasIO},
+		{orderByIO, &res.Statements.TopByIO, hasIO},
+		{orderByIOBlocks, &res.Statements.TopByIOBlocks, !hasIO && hasBlk},
+		{orderByCalls, &res.Statements.TopByCalls, true},
+	} {
+		if !l.want {
+			continue
+		}
+		if sts, ok := fetchPGSM(ctx, conn, s.cfg.topFilter(), rel, l.ord, hasIO && l.ord != orderByIOBlocks, hasBlk); ok {
+			*l.dst = sts
+		}
+	}
+	addHistograms(ctx, conn, schema, rel, res.Statements.TopByTotalTime, res.Statements.TopByCPU,
+		res.Statements.TopByCalls, res.Statements.TopByIO, res.Statements.TopByIOBlocks)
+
+	// Texts at the length limit were cut by pg_stat_monitor
+	var maxLen string
+	if err := queryRow(ctx, conn, sqlPGSMQueryMaxLen, &maxLen); err == nil {
+		if n, err := strconv.Atoi(maxLen); err == nil && n > 0 {
+			for _, list := range [][]Statement{res.Statements.TopByTotalTime, res.Statements.TopByCPU, res.Statements.TopByCalls, res.Statements.TopByIO, res.Statements.TopByIOBlocks} {
+				for i := range list {
+					if len(list[i].Query) >= n-1 {
+						list[i].Truncated = true
+					}
+				}
+			}
+		}
+	}
+
+	res.Statements.ByRole = fetchPSSLoad(ctx, conn, rel, pssGroupByRole)
+	res.Statements.ByDatabase = fetchPSSLoad(ctx, conn, rel, pssGroupByDatabase)
+	res.Statements.ByApp = fetchPSSAppLoad(ctx, conn, rel)
+	finishStatements(&res.Statements)
+}
+
+// finishStatements marks the statements available and derives calls per hour
+// for all collected statements.
+func finishStatements(st *Statements) {
+	st.Available = len(st.TopByTotalTime) > 0 || len(st.TopByCalls) > 0
+	if hours := st.StatsDuration.Hours(); hours > 0 {
+		for _, list := range [][]Statement{st.TopByTotalTime, st.TopByCPU, st.TopByCalls, st.TopByIO, st.TopByIOBlocks} {
+			for i := range list {
+				list[i].CallsPerHour = list[i].Calls / hours
+			}
+		}
+	}
+}
+
+// collectPlans collects best-effort EXPLAIN advice per list (slowest and most
+// frequent), each up to planPerListCap.
+func collectPlans(ctx context.Context, s *session, res *Result) {
+	if len(res.Statements.TopByTotalTime) > 0 {
+		res.Statements.TopByTotalTime = adviseStatements(ctx, s.conn, res, res.Statements.TopByTotalTime)
+	}
+	if len(res.Statements.TopByCalls) > 0 {
+		res.Statements.TopByCalls = adviseStatements(ctx, s.conn, res, res.Statements.TopByCalls)
+	}
+}
+
+// reParam matches positional parameters ($1, $2, ...) in normalized statements.
+var reParam = regexp.MustCompile(`\$\d+`)
+
+// adviseStatements plans the statements of one list and attaches highlights and
+// suggestions. Suspect (slow for their volume) statements are always planned.
+func adviseStatements(ctx context.Context, conn *pgx.Conn, res *Result, sts []Statement) []Statement {
+	limit := planPerListCap
+	if len(sts) == 0 {
+		return sts
+	}
+	// Compute medians for frequency baselines (CallsPerHour preferred, else Calls)
+	median := func(vals []float64) float64 {
+		if len(vals) == 0 {
+			return 0
+		}
+		vv := make([]float64, 0, len(vals))
+		vv = append(vv, vals...)
+		// insertion sort is fine for <=20 elements
+		for i := 1; i < len(vv); i++ {
+			x := vv[i]
+			j := i - 1
+			for j >= 0 && vv[j] > x {
+				vv[j+1] = vv[j]
+				j--
+			}
+			vv[j+1] = x
+		}
+		n := len(vv)
+		if n%2 == 1 {
+			return vv[n/2]
+		}
+		return (vv[n/2-1] + vv[n/2]) / 2.0
+	}
+	var callsVals []float64
+	var cphVals []float64
+	for i := range sts {
+		if sts[i].Calls > 0 {
+			callsVals = append(callsVals, sts[i].Calls)
+		}
+		if sts[i].CallsPerHour > 0 {
+			cphVals = append(cphVals, sts[i].CallsPerHour)
+		}
+	}
+	callsMed := median(callsVals)
+	cphMed := median(cphVals)
+
+	// Dynamic threshold for what is considered "slow" based on volume.
+	// Baseline: 1000ms is slow. More calls/rows => stricter (lower) threshold, but never below 200ms.
+	calcThresholdMs := func(s Statement) float64 {
+		base := 1000.0
+		floor := 200.0
+		// Frequency factor relative to medians
+		ratio := 1.0
+		if cphMed > 0 && s.CallsPerHour > 0 {
+			ratio = s.CallsPerHour / cphMed
+		} else if cphMed == 0 && callsMed > 0 && s.Calls > 0 {
+			ratio = s.Calls / callsMed
+		}
+		freqMul := 1.0
+		// tiered tighten: 1.5x -> 0.85x, 3x -> 0.7x, 10x -> 0.5x
+		if ratio >= 10 {
+			freqMul = 0.5
+		} else if ratio >= 3 {
+			freqMul = 0.7
+		} else if ratio >= 1.5 {
+			freqMul = 0.85
+		}
+		// Rows factor (average rows per call)
+		rowsMul := 1.0
+		if s.Rows >= 10000 {
+			rowsMul = 0.7
+		} else if s.Rows >= 1000 {
+			rowsMul = 0.85
+		}
+		thr := base * freqMul * rowsMul
+		if thr < floor {
+			thr = floor
+		}
+		return thr
+	}
+
+	seenLocal := make(map[string]bool)
+	taken := 0
+	// A query is suspect if its mean time exceeds the dynamic threshold
+	isSuspect := func(s Statement) bool {
+		thr := calcThresholdMs(s)
+		return s.MeanTime >= thr
+	}
+	for i := 0; i < len(sts); i++ {
+		if ctx.Err() != nil {
+			// Out of time: leave the remaining statements unplanned
+			break
+		}
+		qTrim := strings.TrimSpace(sts[i].Query)
+		if sts[i].TextProblem() != "" || seenLocal[qTrim] {
+			continue
+		}
+		seenLocal[qTrim] = true
+		// Safe subset only: SELECT, including WITH ... SELECT without data-modifying bodies
+		parsed := sqlparse.Parse(qTrim)
+		if parsed.Command != "select" {
+			continue
+		}
+		suspect := isSuspect(sts[i])
+		// Mark as needs attention up-front if suspect, even if plan collection fails later
+		if suspect {
+			sts[i].NeedsAttention = true
+		}
+		// If over main budget and not suspect, skip planning; suspects are always planned (unlimited overage)
+		if taken >= limit && !suspect {
+			continue
+		}
+		var planRows pgx.Rows
+		var err error
+		// Parameterized query path: use PREPARE/EXPLAIN EXECUTE with NULL args to avoid brittle substitutions
+		if strings.Contains(qTrim, "$") {
+			prepName := fmt.Sprintf("__pghealth_prep_%d", i)
+			ctxPrep, cancelPrep := context.WithTimeout(ctx, 3*time.Second)
+			_, errPrep := conn.Exec(ctxPrep, "PREPARE "+prepName+" AS "+qTrim)
+			cancelPrep()
+			if errPrep == nil {
+				// determine parameter count by max $N occurrence
+				maxParam := 0
+				matches := reParam.FindAllString(qTrim, -1)
+				for _, m := range matches {
+					if len(m) > 1 {
+						// m like $12
+						numStr := m[1:]
+						if n, errN := strconv.Atoi(numStr); errN == nil && n > maxParam {
+							maxParam = n
+						}
+					}
+				}
+				// build NULL argument list matching parameter count
+				argList := ""
+				if maxParam > 0 {
+					nulls := make([]string, maxParam)
+					for k := 0; k < maxParam; k++ {
+						nulls[k] = "NULL"
+					}
+					argList = "(" + strings.Join(nulls, ", ") + ")"
+				}
+				ctxPlan, cancel := context.WithTimeout(ctx, 5*time.Second)
+				planRows, err = conn.Query(ctxPlan, "EXPLAIN EXECUTE "+prepName+argList)
+				cancel()
+				// cleanup
+				ctxDel, cancelDel := context.WithTimeout(ctx, 1*time.Second)
+				_, _ = conn.Exec(ctxDel, "DEALLOCATE "+prepName)
+				cancelDel()
+				if err != nil {
+					// Fallback: replace parameters with NULL for a generic plan
+					qForExplain := reParam.ReplaceAllString(qTrim, "NULL")
+					ctxPlan2, cancel2 := context.WithTimeout(ctx, 5*time.Second)
+					planRows, err = conn.Query(ctxPlan2, "EXPLAIN "+qForExplain)
+					cancel2()
+				}
+			} else {
+				// Fallback: replace parameters with NULL for a generic plan
+				qForExplain := reParam.ReplaceAllString(qTrim, "NULL")
+				ctxPlan, cancel := context.WithTimeout(ctx, 5*time.Second)
+				planRows, err = conn.Query(ctxPlan, "EXPLAIN "+qForExplain)
+				cancel()
+			}
+		} else {
+			// Non-parameterized
+			ctxPlan, cancel := context.WithTimeout(ctx, 5*time.Second)
+			planRows, err = conn.Query(ctxPlan, "EXPLAIN "+qTrim)
+			cancel()
+		}
+		if err != nil {
+			// Plan failed; if it is suspect, keep NeedsAttention as set, but don't count against planning limit
+			continue
+		}
+		var planLines []string
+		var seqOn []string
+		hasSort := false
+		hasJoin := false
+		joinType := ""
+		hasBitmap := false
+		hasParallel := false
+		hasCTE := false
+		for planRows.Next() {
+			var line string
+			_ = planRows.Scan(&line)
+			planLines = append(planLines, line)
+			up := strings.ToUpper(line)
+			if strings.Contains(up, "SEQ SCAN ON ") {
+				idx := strings.Index(up, "SEQ SCAN ON ")
+				if idx >= 0 {
+					rest := strings.TrimSpace(line[idx+len("SEQ SCAN ON "):])
+					name := rest
+					if j := strings.IndexAny(rest, " (\t"); j >= 0 {
+						name = rest[:j]
+					}
+					seqOn = append(seqOn, name)
+				}
+			}
+			if strings.HasPrefix(strings.TrimSpace(up), "SORT ") || strings.Contains(up, " SORT ") {
+				hasSort = true
+			}
+			if strings.Contains(up, "BITMAP ") {
+				hasBitmap = true
+			}
+			if strings.Contains(up, " NESTED LOOP ") {
+				hasJoin = true
+				joinType = "Nested Loop"
+			} else if strings.Contains(up, " HASH JOIN ") {
+				hasJoin = true
+				joinType = "Hash Join"
+			} else if strings.Contains(up, " MERGE JOIN ") {
+				hasJoin = true
+				joinType = "Merge Join"
+			} else if strings.Contains(up, " JOIN ") {
+				hasJoin = true
+				if joinType == "" {
+					joinType = "Join"
+				}
+			}
+			if strings.Contains(up, "PARALLEL ") {
+				hasParallel = true
+			}
+			if strings.Contains(up, "CTE ") || strings.Contains(up, "WITH ") {
+				hasCTE = true
+			}
+		}
+		planRows.Close()
+		advice := &PlanAdvice{}
+		if len(planLines) > 0 {
+			advice.Plan = strings.Join(planLines, "\n")
+		}
+		// Highlights
+		for _, tname := range seqOn {
+			advice.Highlights = append(advice.Highlights, fmt.Sprintf("Seq Scan on %s", tname))
+		}
+		if hasBitmap {
+			advice.Highlights = append(advice.Highlights, "Bitmap scan present")
+		}
+		if hasSort {
+			advice.Highlights = append(advice.Highlights, "Explicit Sort in plan")
+		}
+		if hasJoin {
+			if joinType != "" {
+				advice.Highlights = append(advice.Highlights, joinType)
+			} else {
+				advice.Highlights = append(advice.Highlights, "Join present")
+			}
+		}
+		if hasParallel {
+			advice.Highlights = append(advice.Highlights, parallelHighlight)
+		}
+		if hasCTE {
+			advice.Highlights = append(advice.Highlights, "CTE in plan")
+		}
+		// Suggestions
+		findTable := func(name string) (TableStat, bool) {
+			for _, t := range res.Tables {
+				if strings.EqualFold(t.Name, name) {
+					return t, true
+				}
+			}
+			return TableStat{}, false
+		}
+		hasAnyIndex := func(name string) bool {
+			for _, idx := range res.Indexes {
+				if strings.EqualFold(idx.Table, name) {
+					return true
+				}
+			}
+			return false
+		}
+		// indexFor adds the DDL of an index serving this statement on the
+		// table named in the plan and reports whether there is one
+		indexFor := func(tn string) bool {
+			name := strings.Trim(tn[strings.LastIndexByte(tn, '.')+1:], `"`)
+			schema := ""
+			if ts, ok := findTable(name); ok {
+				name, schema = ts.Name, ts.Schema
+			} else {
+				for _, t := range parsed.Tables {
+					if strings.EqualFold(t.Name, name) {
+						name, schema = t.Name, t.Schema
+						break
+					}
+				}
+			}
+			ddl := indexDDL(schema, name, parsed, res.Indexes)
+			if ddl == "" {
+				return false
+			}
+			if !slices.Contains(advice.IndexDDL, ddl) {
+				advice.IndexDDL = append(advice.IndexDDL, ddl)
+			}
+			return true
+		}
+		if len(seqOn) > 0 {
+			for _, tn := range seqOn {
+				ddl := indexFor(tn)
+				if ts, ok := findTable(tn); ok {
+					if ts.NLiveTup > 100000 { // large table heuristic
+						if ddl {
+							advice.Suggestions = append(advice.Suggestions, fmt.Sprintf("Large table %s scanned sequentially — create the index below on its filter and sort columns.", tn))
+						} else {
+							advice.Suggestions = append(advice.Suggestions, fmt.Sprintf("Large table %s scanned sequentially — consider adding/using an index on predicate/join columns.", tn))
+						}
+						advice.CanBeIndexed = true
+						if !parsed.Limit && sts[i].Calls > 0 && sts[i].Rows/sts[i].Calls >= 1000 {
+							advice.Suggestions = append(advice.Suggestions, fmt.Sprintf("No LIMIT while scanning large table %s and returning %.0f rows per call — paginate or filter if callers do not need every row.", tn, sts[i].Rows/sts[i].Calls))
+							advice.CanBeRefactored = true
+						}
+					} else {
+						advice.Suggestions = append(advice.Suggestions, fmt.Sprintf("Sequential scan on %s — verify if intentional (small table) or add an index.", tn))
+						advice.CanBeIndexed = true
+					}
+					if !hasAnyIndex(tn) {
+						advice.Suggestions = append(advice.Suggestions, fmt.Sprintf("No indexes found on %s — create indexes on frequently filtered or joined columns.", tn))
+						advice.CanBeIndexed = true
+					}
+				} else {
+					if ddl {
+						advice.Suggestions = append(advice.Suggestions, fmt.Sprintf("Sequential scan on %s — create the index below on its predicate columns.", tn))
+					} else {
+						advice.Suggestions = append(advice.Suggestions, fmt.Sprintf("Sequential scan on %s — consider index on predicate columns.", tn))
+					}
+					advice.CanBeIndexed = true
+				}
+			}
+		}
+		if hasBitmap {
+			advice.Suggestions = append(advice.Suggestions, "Consider composite/covering indexes to reduce Bitmap Heap rechecks when appropriate.")
+			advice.CanBeIndexed = true
+		}
+		if hasSort {
+			for _, k := range parsed.OrderBy {
+				if k.Table.Name != "" {
+					indexFor(k.Table.Name)
+				}
+			}
+			advice.Suggestions = append(advice.Suggestions, "Add or adjust an index matching ORDER BY to avoid Sort when appropriate; review work_mem as needed.")
+			advice.CanBeIndexed = true
+		}
+		if hasJoin {
+			advice.Suggestions = append(advice.Suggestions, "Ensure join keys are indexed on both sides (consider composite indexes for multi-column joins).")
+			advice.CanBeIndexed = true
+		}
+		if parsed.SelectStar {
+			advice.Suggestions = append(advice.Suggestions, "Avoid SELECT *: list the columns the caller needs so index-only scans stay possible and rows, sorts and transfers stay narrow.")
+			advice.CanBeRefactored = true
+		}
+		if hasCTE {
+			advice.Suggestions = append(advice.Suggestions, "If CTE is not reused, consider inlining it (PostgreSQL may materialize it depending on version/settings).")
+			advice.CanBeRefactored = true
+		}
+		if !advice.CanBeIndexed && len(seqOn) > 0 {
+			advice.CanBeRefactored = true
+			advice.Suggestions = append(advice.Suggestions, "Query uses sequential scans but no clear index path was found. Consider refactoring the query for better performance.")
+		}
+		if advice.Plan != "" || len(advice.Suggestions) > 0 || len(advice.Highlights) > 0 {
+			sts[i].Advice = advice
+			// Do not set NeedsAttention based on presence of a plan; it's governed by thresholds only.
+			if taken < limit {
+				taken++
+			}
+		}
+	}
+	return sts
+}
+
+// collectConnections reads the overall connection count and connections by
+// client (address, user, application).
+func collectConnections(ctx context.Context, s *session, res *Result) {
+	_ = queryRow(ctx, s.conn, sqlTotalConnections, &res.TotalConnections)
+
+	if rows, err := s.conn.Query(ctx, sqlConnsByClient); err == nil {
+		for rows.Next() {
+			var c ClientConn
+			if err := rows.Scan(&c.Address, &c.User, &c.Application, &c.Count, &c.Idle, &c.AvgAgeSeconds); err == nil {
+				res.ConnectionsByClient = append(res.ConnectionsByClient, c)
+			}
+		}
+		rows.Close()
+	}
+
+	if rows, err := s.conn.Query(ctx, sqlRoleIdle); err == nil {
+		for rows.Next() {
+			var r RoleIdle
+			if err := rows.Scan(&r.Role, &r.Application, &r.Sessions, &r.Idle, &r.IdleMedianSeconds, &r.IdleMaxSeconds,
+				&r.IdleInTx, &r.IdleInTxMaxSeconds, &r.IdleInTxTimeout, &r.IdleSessionTimeout, &r.HasIdleSessionTimeout); err == nil {
+				res.RoleIdle = append(res.RoleIdle, r)
+			}
+		}
+		rows.Close()
+	}
+
+	if rows, err := s.conn.Query(ctx, sqlSessionStats); err == nil {
+		for rows.Next() {
+			var st SessionStat
+			if err := rows.Scan(&st.Datname, &st.Sessions, &st.SessionTimeMs, &st.ActiveTimeMs, &st.IdleInTxMs,
+				&st.Abandoned, &st.Fatal, &st.Killed, &st.WindowSeconds); err == nil {
+				res.SessionStats = append(res.SessionStats, st)
+			}
+		}
+		rows.Close()
+	}
+}
+
+// collectCacheHit reads the cache hit ratio for the current database, overall
+// and per database.
+func collectCacheHit(ctx context.Context, s *session, res *Result) {
+	conn := s.conn
+	var hit, read int64
+	if err := conn.QueryRow(ctx, sqlCacheHitCurrent).Scan(&hit, &read); err == nil {
+		total := hit + read
+		if total > 0 {
+			res.CacheHitCurrent = float64(hit) / float64(total) * 100
+		}
+	}
+	var hitSum, readSum int64
+	if err := conn.QueryRow(ctx, sqlCacheHitOverall).Scan(&hitSum, &readSum); err == nil {
+		total := hitSum + readSum
+		if total > 0 {
+			res.CacheHitOverall = float64(hitSum) / float64(total) * 100
+		}
+	}
+
+	if rows, err := conn.Query(ctx, sqlCacheHitByDB); err == nil {
+		for rows.Next() {
+			var ch CacheHit
+			_ = rows.Scan(&ch.Datname, &ch.BlksHit, &ch.BlksRead, &ch.Ratio)
+			res.CacheHits = append(res.CacheHits, ch)
+		}
+		rows.Close()
+	}
+}
+
+// collectBlocking reads blocked sessions and their blockers.
+func collectBlocking(ctx context.Context, s *session, res *Result) {
+	rows, err := s.conn.Query(ctx, sqlBlocking)
+	if err != nil {
+		return
+	}
+	for rows.Next() {
+		var bl Blocking
+		_ = rows.Scan(&bl.Datname, &bl.BlockedPID, &bl.BlockedDuration, &bl.BlockedQuery, &bl.BlockingPID, &bl.BlockingDuration, &bl.BlockingQuery)
+		res.Blocking = append(res.Blocking, bl)
+	}
+	rows.Close()
+}
+
+// collectLongRunning reads queries active for more than 5 minutes.
+func collectLongRunning(ctx context.Context, s *session, res *Result) {
+	rows, err := s.conn.Query(ctx, sqlLongRunning)
+	if err != nil {
+		return
+	}
+	for rows.Next() {
+		var lq LongQuery
+		_ = rows.Scan(&lq.Datname, &lq.PID, &lq.Duration, &lq.State, &lq.Query)
+		res.LongRunning = append(res.LongRunning, lq)
+	}
+	rows.Close()
+}
+
+// vacuumSampleInterval is the wait between the two pg_stat_progress_vacuum
+// samples the heap scan rate of running vacuums is measured over.
+const vacuumSampleInterval = 2 * time.Second
+
+// collectAutovacuum reads running (auto)vacuum progress. When a vacuum is
+// scanning the heap, progress is sampled twice to measure its scan rate.
+func collectAutovacuum(ctx context.Context, s *session, res *Result) {
+	first, err := queryAutovacuum(ctx, s)
+	if err != nil {
+		return
+	}
+	cur := first
+	if scanningHeap(first) {
+		start := time.Now()
+		timer := time.NewTimer(vacuumSampleInterval)
+		select {
+		case <-timer.C:
+			if second, err := queryAutovacuum(ctx, s); err == nil {
+				measureVacuumRates(first, second, time.Since(start))
+				cur = second
+			}
+		case <-ctx.Done():
+			timer.Stop()
+		}
+	}
+	res.AutoVacuum = append(res.AutoVacuum, cur...)
+}
+
+// queryAutovacuum samples pg_stat_progress_vacuum.
+func queryAutovacuum(ctx context.Context, s *session) ([]AutoVacuum, error) {
+	rows, err := s.conn.Query(ctx, sqlAutovacuum)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []AutoVacuum
+	for rows.Next() {
+		var av AutoVacuum
+		_ = rows.Scan(&av.Datname, &av.PID, &av.Relation, &av.Phase, &av.Scanned, &av.Total, &av.Auto,
+			&av.IndexVacuumCount, &av.MaxDeadTuples, &av.NumDeadTuples, &av.MaxDeadBytes, &av.DeadBytes)
+		out = append(out, av)
+	}
+	return out, rows.Err()
+}
+
+// scanningHeap reports whether any vacuum has heap blocks left to scan.
+func scanningHeap(avs []AutoVacuum) bool {
+	for _, av := range avs {
+		if av.Phase == vacuumPhaseScanHeap && av.Scanned < av.Total {
+			return true
+		}
+	}
+	return false
+}
+
+// measureVacuumRates sets the heap scan rate of the vacuums in cur that were
+// already scanning the same relation in prev, elapsed earlier.
+func measureVacuumRates(prev, cur []AutoVacuum, elapsed time.Duration) {
+	if elapsed <= 0 {
+		return
+	}
+	for i := range cur {
+		c := &cur[i]
+		if c.Phase != vacuumPhaseScanHeap {
+			continue
+		}
+		for _, p := range prev {
+			if p.PID == c.PID && p.Relation == c.Relation && p.Phase == vacuumPhaseScanHeap && c.Scanned > p.Scanned {
+				c.ScanRate = float64(c.Scanned-p.Scanned) / elapsed.Seconds()
+				break
+			}
+		}
+	}
+}
+
+// collectIndexUsage reads the tables with the lowest index usage (prefer user
+// tables; fallback to all non-system).
+func collectIndexUsage(ctx context.Context, s *session, res *Result) {
+	if rows, err := s.conn.Query(ctx, sqlIndexUsageLow); err == nil {
+		for rows.Next() {
+			var iu IndexUsage
+			_ = rows.Scan(&iu.Schema, &iu.Table, &iu.IndexUsagePct, &iu.Rows)
+			iu.Database = res.ConnInfo.CurrentDB
+			res.IndexUsageLow = append(res.IndexUsageLow, iu)
+		}
+		rows.Close()
+	}
+	if len(res.IndexUsageLow) == 0 {
+		if rows, err := s.conn.Query(ctx, sqlIndexUsageLowAll); err == nil {
+			for rows.Next() {
+				var iu IndexUsage
+				_ = rows.Scan(&iu.Schema, &iu.Table, &iu.IndexUsagePct, &iu.Rows)
+				iu.Database = res.ConnInfo.CurrentDB
+				res.IndexUsageLow = append(res.IndexUsageLow, iu)
+			}
+			rows.Close()
+		}
+	}
+}
+
+// collectIndexCounts reads the largest tables with their index counts.
+func collectIndexCounts(ctx context.Context, s *session, res *Result) {
+	rows, err := s.conn.Query(ctx, sqlTableIndexCounts)
+	if err != nil {
+		return
+	}
+	for rows.Next() {
+		var tic TableIndexCount
+		_ = rows.Scan(&tic.Schema, &tic.Name, &tic.IndexCount, &tic.SizeBytes, &tic.RowCount, &tic.DeadRows, &tic.BloatPct)
+		tic.Database = res.ConnInfo.CurrentDB
+		res.TablesWithIndexCount = append(res.TablesWithIndexCount, tic)
+	}
+	rows.Close()
+}
+
+// collectTableBloat estimates wasted space from dead tuples.
+func collectTableBloat(ctx context.Context, s *session, res *Result) {
+	rows, err := s.conn.Query(ctx, sqlTableBloat)
+	if err != nil {
+		return
+	}
+	for rows.Next() {
+		var tbs TableBloatStat
+		var lastVacuum, lastAnalyze *time.Time
+		_ = rows.Scan(&tbs.Schema, &tbs.Name, &tbs.EstimatedBloat, &tbs.WastedBytes, &lastVacuum, &lastAnalyze)
+		tbs.LastVacuum = lastVacuum
+		tbs.LastAnalyze = lastAnalyze
+		res.TableBloatStats = append(res.TableBloatStats, tbs)
+	}
+	rows.Close()
+}
+
+// collectIndexBloat reads large indexes for bloat review.
+func collectIndexBloat(ctx context.Context, s *session, res *Result) {
+	rows, err := s.conn.Query(ctx, sqlIndexBloat)
+	if err != nil {
+		return
+	}
+	for rows.Next() {
+		var ibs IndexBloatStat
+		_ = rows.Scan(&ibs.Schema, &ibs.Table, &ibs.Name, &ibs.EstimatedBloat, &ibs.WastedBytes, &ibs.Scans)
+		res.IndexBloatStats = append(res.IndexBloatStats, ibs)
+	}
+	rows.Close()
+}
+
+// collectStandbyConflicts reads query cancellations caused by recovery
+// conflicts together with the settings governing them. The counters stay zero
+// on a primary, so nothing is recorded there.
+func collectStandbyConflicts(ctx context.Context, s *session, res *Result) {
+	var inRecovery bool
+	if err := queryRow(ctx, s.conn, sqlInRecovery, &inRecovery); err != nil || !inRecovery {
+		return
+	}
+	sc := &StandbyConflicts{Host: s.host}
+	rows, err := s.conn.Query(ctx, sqlStandbyConflicts)
+	if err != nil {
+		return
+	}
+	for rows.Next() {
+		var d DatabaseConflicts
+		if err := rows.Scan(&d.Datname, &d.Tablespace, &d.Lock, &d.Snapshot, &d.BufferPin, &d.Deadlock); err == nil {
+			sc.Databases = append(sc.Databases, d)
+		}
+	}
+	rows.Close()
+	if rows, err := s.conn.Query(ctx, sqlStandbySettings); err == nil {
+		for rows.Next() {
+			var st Setting
+			if err := rows.Scan(&st.Name, &st.Val, &st.Unit, &st.Source); err == nil {
+				sc.Settings = append(sc.Settings, st)
+			}
+		}
+		rows.Close()
+	}
+	res.StandbyConflicts = sc
+}
+
+// collectReplication reads connected replicas and their lag.
+func collectReplication(ctx context.Context, s *session, res *Result) {
+	rows, err := s.conn.Query(ctx, sqlReplication)
+	if err != nil {
+		return
+	}
+	for rows.Next() {
+		var rs ReplicationStat
+		_ = rows.Scan(&rs.Name, &rs.State, &rs.SyncState, &rs.SyncPriority, &rs.WriteLag, &rs.FlushLag, &rs.ReplayLag)
+		res.ReplicationStats = append(res.ReplicationStats, rs)
+	}
+	rows.Close()
+}
+
+// collectWaitEvents reads the top wait events.
+func collectWaitEvents(ctx context.Context, s *session, res *Result) {
+	rows, err := s.conn.Query(ctx, sqlWaitEvents)
+	if err != nil {
+		return
+	}
+	for rows.Next() {
+		var w WaitEventStat
+		_ = rows.Scan(&w.Type, &w.Event, &w.Count)
+		res.WaitEvents = append(res.WaitEvents, w)
+	}
+	rows.Close()
+}
+
+// collectFunctions reads the top functions by total time (if view available).
+func collectFunctions(ctx context.Context, s *session, res *Result) {
+	rows, err := s.conn.Query(ctx, sqlFunctions)
+	if err != nil {
+		return
+	}
+	for rows.Next() {
+		var f FunctionStat
+		_ = rows.Scan(&f.Schema, &f.Name, &f.Calls, &f.TotalTime, &f.SelfTime)
+		res.FunctionStats = append(res.FunctionStats, f)
+	}
+	rows.Close()
+}
+
+// collectWAL reads WAL statistics (if view exists).
+func collectWAL(ctx context.Context, s *session, res *Result) {
+	var hasWAL bool
+	_ = queryRow(ctx, s.conn, sqlHasStatWAL, &hasWAL)
+	if !hasWAL {
+		return
+	}
+	var ws WALStat
+	if err := s.conn.QueryRow(ctx, sqlStatWAL).Scan(&ws.Records, &ws.FullPage, &ws.Bytes, &ws.StatsReset); err == nil {
+		res.WAL = &ws
+	}
+}
+
+// collectWALArchiving reads archive_mode, archive_command and the archiver
+// statistics.
+func collectWALArchiving(ctx context.Context, s *session, res *Result) {
+	var wa WALArchiving
+	var lastArchived, lastFailed *time.Time
+	if err := s.conn.QueryRow(ctx, sqlWALArchiver).Scan(&wa.Mode, &wa.Command, &wa.Library, &wa.SegmentSize,
+		&wa.ArchivedCount, &wa.LastArchivedWAL, &lastArchived, &wa.FailedCount, &wa.LastFailedWAL, &lastFailed); err != nil {
+		return
+	}
+	if lastArchived != nil {
+		wa.LastArchivedAt = *lastArchived
+	}
+	if lastFailed != nil {
+		wa.LastFailedAt = *lastFailed
+	}
+	// Fails on standbys, which do not write WAL of their own
+	_ = queryRow(ctx, s.conn, sqlCurrentWALFile, &wa.CurrentWAL)
+	res.WALArchiving = &wa
+}
+
+// collectProgress reads CREATE INDEX and ANALYZE progress (if views exist).
+func collectProgress(ctx context.Context, s *session, res *Result) {
+	if rows, err := s.conn.Query(ctx, sqlProgressCreateIndex); err == nil {
+		for rows.Next() {
+			var pr ProgressCreateIndex
+			_ = rows.Scan(&pr.Datname, &pr.Relation, &pr.Phase, &pr.BlocksDone, &pr.BlocksTotal, &pr.TuplesDone, &pr.TuplesTotal, &pr.LockersDone, &pr.LockersTotal)
+			res.ProgressCreateIndex = append(res.ProgressCreateIndex, pr)
+		}
+		rows.Close()
+	}
+
+	if rows, err := s.conn.Query(ctx, sqlProgressAnalyze); err == nil {
+		for rows.Next() {
+			var pa ProgressAnalyze
+			_ = rows.Scan(&pa.Datname, &pa.Relation, &pa.Phase, &pa.SampleScans, &pa.SampleTotal)
+			res.ProgressAnalyze = append(res.ProgressAnalyze, pa)
+		}
+		rows.Close()
+	}
+
+	// The views below are missing on older servers; the queries then fail
+	// and the sections stay empty
+	if rows, err := s.conn.Query(ctx, sqlProgressCopy); err == nil {
+		for rows.Next() {
+			var pc ProgressCopy
+			if err := rows.Scan(&pc.Datname, &pc.PID, &pc.Relation, &pc.Command, &pc.Type, &pc.BytesProcessed, &pc.BytesTotal,
+				&pc.TuplesProcessed, &pc.TuplesExcluded, &pc.Seconds); err == nil {
+				res.ProgressCopy = append(res.ProgressCopy, pc)
+			}
+		}
+		rows.Close()
+	}
+
+	if rows, err := s.conn.Query(ctx, sqlProgressCluster); err == nil {
+		for rows.Next() {
+			var pc ProgressCluster
+			if err := rows.Scan(&pc.Datname, &pc.PID, &pc.Relation, &pc.Command, &pc.Phase, &pc.HeapBlksScanned, &pc.HeapBlksTotal,
+				&pc.HeapTuplesWritten, &pc.IndexRebuilds, &pc.Seconds); err == nil {
+				res.ProgressCluster = append(res.ProgressCluster, pc)
+			}
+		}
+		rows.Close()
+	}
+
+	if rows, err := s.conn.Query(ctx, sqlProgressBasebackup); err == nil {
+		for rows.Next() {
+			var pb ProgressBasebackup
+			if err := rows.Scan(&pb.PID, &pb.Client, &pb.Phase, &pb.BytesStreamed, &pb.BytesTotal, &pb.TablespacesStreamed,
+				&pb.TablespacesTotal, &pb.Seconds); err == nil {
+				res.ProgressBasebackup = append(res.ProgressBasebackup, pb)
+			}
+		}
+		rows.Close()
+	}
+}
+
+// collectCheckpoints reads checkpoint statistics.
+func collectCheckpoints(ctx context.Context, s *session, res *Result) {
+	rows, err := s.conn.Query(ctx, sqlCheckpoints)
+	if err != nil {
+		return
+	}
+	if rows.Next() {
+		_ = rows.Scan(&res.CheckpointStats.RequestedCheckpoints, &res.CheckpointStats.ScheduledCheckpoints,
+			&res.CheckpointStats.CheckpointWriteTime, &res.CheckpointStats.CheckpointSyncTime,
+			&res.CheckpointStats.BuffersCheckpoint, &res.CheckpointStats.BuffersWritten)
+	}
+	rows.Close()
+}
+
+// collectMemory reads buffer and temp file statistics.
+func collectMemory(ctx context.Context, s *session, res *Result) {
+	conn := s.conn
+	// 1) bgwriter counters (approximate buffer allocation stats)
+	if rows, err := conn.Query(ctx, sqlBgwriterBuffers); err == nil {
+		if rows.Next() {
+			_ = rows.Scan(&res.MemoryStats.SharedBuffersUsed, &res.MemoryStats.SharedBuffersTotal)
+		}
+		rows.Close()
+	}
+	// 2) block size and shared_buffers config
+	{
+		var blockSizeStr string
+		_ = queryRow(ctx, conn, sqlBlockSize, &blockSizeStr)
+		// Try to parse as integer bytes; if fails and contains kB suffix, strip and multiply
+		if n, err := strconv.ParseInt(strings.TrimSpace(blockSizeStr), 10, 64); err == nil && n > 0 {
+			res.MemoryStats.BlockSizeBytes = n
+		} else {
+			// e.g., "8kB"
+			bs := strings.ToLower(strings.TrimSpace(blockSizeStr))
+			if strings.HasSuffix(bs, "kb") {
+				v := strings.TrimSuffix(bs, "kb")
+				if n2, err2 := strconv.ParseInt(strings.TrimSpace(v), 10, 64); err2 == nil && n2 > 0 {
+					res.MemoryStats.BlockSizeBytes = n2 * 1024
+				}
+			}
+		}
+		var sbCount int64
+		_ = queryRow(ctx, conn, sqlSharedBuffers, &sbCount)
+		res.MemoryStats.SharedBuffersSetting = sbCount
+		if res.MemoryStats.BlockSizeBytes > 0 && sbCount > 0 {
+			res.MemoryStats.SharedBuffersBytes = sbCount * res.MemoryStats.BlockSizeBytes
+		}
+	}
+	// 3) pg_buffercache usage if available
+	{
+		var hasBuffercache bool
+		_ = queryRow(ctx, conn, sqlHasBuffercache, &hasBuffercache)
+		res.MemoryStats.BuffercacheAvailable = hasBuffercache
+		if hasBuffercache {
+			var used int64
+			_ = queryRow(ctx, conn, sqlBuffercache, &used)
+			res.MemoryStats.BuffercacheUsedBuffers = used
+			if res.MemoryStats.BlockSizeBytes > 0 && used > 0 {
+				res.MemoryStats.BuffercacheUsedBytes = used * res.MemoryStats.BlockSizeBytes
+			}
+		}
+	}
+	// 4) Temp file usage from pg_stat_database for current DB
+	{
+		var tf, tb int64
+		if err := conn.QueryRow(ctx, sqlTempFilesDB).Scan(&tf, &tb); err == nil {
+			res.MemoryStats.TempFilesCurrentDB = tf
+			res.MemoryStats.TempBytesCurrentDB = tb
+		}
+	}
+}
+
+// collectIO reads block IO counters for the current database.
+func collectIO(ctx context.Context, s *session, res *Result) {
+	rows, err := s.conn.Query(ctx, sqlIOStats)
+	if err != nil {
+		return
+	}
+	if rows.Next() {
+		_ = rows.Scan(&res.IOStats.HeapBlksRead, &res.IOStats.HeapBlksHit,
+			&res.IOStats.IdxBlksRead, &res.IOStats.IdxBlksHit,
+			&res.IOStats.ToastBlksRead, &res.IOStats.ToastBlksHit,
+			&res.IOStats.TidxBlksRead, &res.IOStats.TidxBlksHit,
+			&res.IOStats.ReadTime, &res.IOStats.WriteTime)
+	}
+	rows.Close()
+}
+
+// collectLocks reads ungranted locks grouped by type and mode.
+func collectLocks(ctx context.Context, s *session, res *Result) {
+	rows, err := s.conn.Query(ctx, sqlLocks)
+	if err != nil {
+		return
+	}
+	for rows.Next() {
+		var ls LockStat
+		_ = rows.Scan(&ls.LockType, &ls.Mode, &ls.Granted, &ls.Count, &ls.WaitingPIDs)
+		res.LockStats = append(res.LockStats, ls)
+	}
+	rows.Close()
+}
+
+// collectLockHotspots reads the tables with waiting or row-level locks.
+func collectLockHotspots(ctx context.Context, s *session, res *Result) {
+	rows, err := s.conn.Query(ctx, sqlLockHotspots)
+	if err != nil {
+		return
+	}
+	for rows.Next() {
+		var h LockHotspot
+		_ = rows.Scan(&h.Schema, &h.Table, &h.Granted, &h.Waiting, &h.TupleLocks, &h.HotRows, &h.StrongLocks, &h.LongestWaitSecs, &h.SampleQuery)
+		res.LockHotspots = append(res.LockHotspots, h)
+	}
+	rows.Close()
+}
+
+// collectSubtransactions reads the Subtrans SLRU counters and, where the
+// server supports it, the sessions holding subtransactions.
+func collectSubtransactions(ctx context.Context, s *session, res *Result) {
+	st := &Subtransactions{}
+	var slru SLRUStat
+	if err := s.conn.QueryRow(ctx, sqlSubtransSLRU).Scan(&slru.BlksHit, &slru.BlksRead, &slru.BlksZeroed, &slru.StatsReset); err == nil {
+		st.SLRU = &slru
+	}
+	if rows, err := s.conn.Query(ctx, sqlSubxactBackends); err == nil {
+		for rows.Next() {
+			var b SubxactBackend
+			if err := rows.Scan(&b.PID, &b.Datname, &b.Usename, &b.Application, &b.Count, &b.Overflowed, &b.Query); err == nil {
+				st.Backends = append(st.Backends, b)
+			}
+		}
+		rows.Close()
+	}
+	if st.SLRU != nil || len(st.Backends) > 0 {
+		res.Subtransactions = st
+	}
+}
+
+// collectVacuumHorizon reads what holds back the xmin horizon vacuum cleans
+// up to: standby feedback, replication slots and the oldest local snapshot.
+func collectVacuumHorizon(ctx context.Context, s *session, res *Result) {
+	vh := &VacuumHorizon{}
+	if rows, err := s.conn.Query(ctx, sqlXminHolders); err == nil {
+		for rows.Next() {
+			var h XminHolder
+			if err := rows.Scan(&h.Kind, &h.Name, &h.Active, &h.XminAge, &h.CatalogXminAge); err == nil {
+				vh.Holders = append(vh.Holders, h)
+			}
+		}
+		rows.Close()
+	}
+	if rows, err := s.conn.Query(ctx, sqlHorizonSettings); err == nil {
+		for rows.Next() {
+			var st Setting
+			if err := rows.Scan(&st.Name, &st.Val, &st.Unit, &st.Source); err == nil {
+				vh.Settings = append(vh.Settings, st)
+			}
+		}
+		rows.Close()
+	}
+	if len(vh.Holders) > 0 || len(vh.Settings) > 0 {
+		res.VacuumHorizon = vh
+	}
+}
+
+// collectReplicationSlots reads the replication slots and the WAL each retains.
+func collectReplicationSlots(ctx context.Context, s *session, res *Result) {
+	rows, err := s.conn.Query(ctx, sqlReplicationSlots)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var sl ReplicationSlot
+		if err := rows.Scan(&sl.Name, &sl.Type, &sl.Plugin, &sl.Database, &sl.Active, &sl.Temporary, &sl.WALStatus,
+			&sl.RetainedBytes, &sl.SafeWALSize, &sl.InactiveSeconds, &sl.Invalidated, &sl.Synced); err == nil {
+			res.ReplicationSlots = append(res.ReplicationSlots, sl)
+		}
+	}
+}
+
+// collectOSMemory reads huge page, transparent huge page and overcommit
+// settings and the cgroup memory limit of the server from /proc and /sys
+// when the server runs on this machine or Config.LocalOS says so.
+func collectOSMemory(ctx context.Context, s *session, res *Result) {
+	var pid int
+	var addr string
+	err := s.conn.QueryRow(ctx, sqlBackendAddr).Scan(&pid, &addr)
+	if !s.cfg.LocalOS && (err != nil || !isLocalBackend(pid, addr)) {
+		return
+	}
+	if res.OSMemory = readOSMemory(); res.OSMemory != nil {
+		_, res.OSMemory.CgroupMemoryLimitBytes = readCgroupLimits(pid)
+	}
+}
+
+// collectOSCPU reads the CPU count, NUMA layout and cgroup limits of the
+// database host when Config.LocalOS is set.
+func collectOSCPU(ctx context.Context, s *session, res *Result) {
+	if !s.cfg.LocalOS {
+		return
+	}
+	var pid int
+	var addr string
+	_ = s.conn.QueryRow(ctx, sqlBackendAddr).Scan(&pid, &addr)
+	res.OSCPU = readOSCPU(pid)
+}
+
+// collectHAMetadata reads repmgr and pg_auto_failover node registrations.
+func collectHAMetadata(ctx context.Context, s *session, res *Result) {
+	ha := &HAMetadata{}
+	if rows, err := s.conn.Query(ctx, sqlRepmgrNodes); err == nil {
+		for rows.Next() {
+			var n RepmgrNode
+			var monitorAge float64
+			if err := rows.Scan(&n.ID, &n.Name, &n.Type, &n.Active, &n.Upstream, &n.Priority, &n.Location, &monitorAge); err == nil {
+				n.MonitorAge = time.Duration(monitorAge * float64(time.Second))
+				ha.RepmgrNodes = append(ha.RepmgrNodes, n)
+			}
+		}
+		rows.Close()
+	}
+	if rows, err := s.conn.Query(ctx, sqlAutoFailoverNodes); err == nil {
+		for rows.Next() {
+			var n AutoFailoverNode
+			var reportAge float64
+			if err := rows.Scan(&n.ID, &n.Name, &n.Host, &n.Port, &n.Formation, &n.Group, &n.GoalState, &n.ReportedState,
+				&n.PgRunning, &n.Health, &reportAge, &n.CandidatePriority, &n.ReplicationQuorum); err == nil {
+				n.ReportAge = time.Duration(reportAge * float64(time.Second))
+				ha.AutoFailoverNodes = append(ha.AutoFailoverNodes, n)
+			}
+		}
+		rows.Close()
+	}
+	if len(ha.RepmgrNodes) > 0 || len(ha.AutoFailoverNodes) > 0 {
+		res.HA = ha
+	}
+}
+
+// collectTempFiles reads sessions using temporary files.
+func collectTempFiles(ctx context.Context, s *session, res *Result) {
+	rows, err := s.conn.Query(ctx, sqlTempFiles)
+	if err != nil {
+		return
+	}
+	for rows.Next() {
+		var tfs TempFileStat
+		_ = rows.Scan(&tfs.Datname, &tfs.PID, &tfs.Files, &tfs.Bytes)
+		res.TempFileStats = append(res.TempFileStats, tfs)
+	}
+	rows.Close()
+}
+
+// collectExtensionStats lists installed extensions of the current database.
+func collectExtensionStats(ctx context.Context, s *session, res *Result) {
+	res.ExtensionStats = append(res.ExtensionStats, queryExtensions(ctx, s.conn, res.ConnInfo.CurrentDB)...)
+}
+
+// collectExtraExtensionStats lists installed extensions of each database in cfg.DBs.
+func collectExtraExtensionStats(ctx context.Context, s *session, res *Result) {
+	for _, db := range s.cfg.DBs {
+		if ctx.Err() != nil {
+			return
+		}
+		// Skip current DB; already collected
+		if db == res.ConnInfo.CurrentDB {
+			continue
+		}
+		// Build URL for target DB (naive last path segment swap)
+		targetURL := swapDBInURL(s.cfg.URL, db)
+		if targetURL == "" {
+			continue
+		}
+		if c2, err := connect(ctx, s.cfg, targetURL, s.thr); err == nil {
+			res.ExtensionStats = append(res.ExtensionStats, queryExtensions(ctx, c2, db)...)
+			c2.Close(ctx)
+		}
+	}
+}
+
+// queryExtensions reads installed extensions through conn, labelled with db.
+func queryExtensions(ctx context.Context, conn *pgx.Conn, db string) []ExtensionStat {
+	rows, err := conn.Query(ctx, sqlExtensions)
+	if err != nil {
+		return nil
+	}
+	defer rows.Close()
+	var out []ExtensionStat
+	for rows.Next() {
+		var es ExtensionStat
+		_ = rows.Scan(&es.Name, &es.Version, &es.Description, &es.Schema)
+		es.Database = db
+		out = append(out, es)
+	}
+	return out
+}
+
+// xidMax is the maximum XID age before wraparound (~2 billion, 2^31 - 1).
+const xidMax = 2147483647
+
+// collectXIDAge reads transaction ID age per database (wraparound risk) and
+// the XID counter, so archived runs can measure XID consumption.
+func collectXIDAge(ctx context.Context, s *session, res *Result) {
+	rows, err := s.conn.Query(ctx, sqlXIDAge)
+	if err != nil {
+		return
+	}
+	for rows.Next() {
+		var x DatabaseXIDAge
+		_ = rows.Scan(&x.Datname, &x.Age, &x.FrozenXID, &x.MinMXID, &x.MinMXIDAge)
+		x.PctToLimit = float64(x.Age) / float64(xidMax) * 100
+		res.XIDAge = append(res.XIDAge, x)
+	}
+	rows.Close()
+
+	var clock XIDClock
+	if err := s.conn.QueryRow(ctx, sqlNextXID).Scan(&clock.NextXID, &clock.At); err == nil {
+		res.XIDClock = &clock
+	}
+}
+
+// xidSampleInterval is the wait between the two next-XID samples the XID
+// consumption rate is measured over.
+const xidSampleInterval = 2 * time.Second
+
+// collectFreezeForecast reads the tables closest to autovacuum_freeze_max_age
+// and measures the XID consumption rate to forecast their anti-wraparound
+// autovacuums.
+func collectFreezeForecast(ctx context.Context, s *session, res *Result) {
+	rows, err := s.conn.Query(ctx, sqlFreezeTables)
+	if err != nil {
+		return
+	}
+	ff := &FreezeForecast{}
+	for rows.Next() {
+		var t FreezeTable
+		if err := rows.Scan(&t.Schema, &t.Name, &t.XIDAge, &t.FreezeMaxAge, &t.SizeBytes); err == nil {
+			ff.Tables = append(ff.Tables, t)
+		}
+	}
+	rows.Close()
+	if len(ff.Tables) == 0 {
+		return
+	}
+	res.FreezeForecast = ff
+
+	var first, second XIDClock
+	if err := s.conn.QueryRow(ctx, sqlNextXID).Scan(&first.NextXID, &first.At); err != nil {
+		return
+	}
+	timer := time.NewTimer(xidSampleInterval)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+		return
+	}
+	if err := s.conn.QueryRow(ctx, sqlNextXID).Scan(&second.NextXID, &second.At); err == nil {
+		if r, ok := NewXIDRate(first, second); ok {
+			ff.XIDRate = r.PerHour()
+		}
+	}
+}
+
+// collectIdleInTransaction reads idle-in-transaction sessions (potential
+// blockers and resource holders).
+func collectIdleInTransaction(ctx context.Context, s *session, res *Result) {
+	rows, err := s.conn.Query(ctx, sqlIdleInTransaction)
+	if err != nil {
+		return
+	}
+	for rows.Next() {
+		var it IdleInTransaction
+		_ = rows.Scan(&it.Datname, &it.PID, &it.User, &it.Application, &it.Duration, &it.Query, &it.WaitEvent)
+		res.IdleInTransaction = append(res.IdleInTransaction, it)
+	}
+	rows.Close()
+}
+
+// collectStaleStats reads tables that haven't been analyzed recently.
+func collectStaleStats(ctx context.Context, s *session, res *Result) {
+	rows, err := s.conn.Query(ctx, sqlStaleStats)
+	if err != nil {
+		return
+	}
+	for rows.Next() {
+		var st StaleStatsTable
+		_ = rows.Scan(&st.Table, &st.Schema, &st.RowEstimate, &st.LastAnalyze, &st.LastAutoAnalyze, &st.ModsSinceAnalyze, &st.DaysSinceAnalyze)
+		// Swap schema/table - query returns schemaname first
+		st.Schema, st.Table = st.Table, st.Schema
+		res.StaleStatsTables = append(res.StaleStatsTables, st)
+	}
+	rows.Close()
+}
+
+// collectDuplicateIndexes reads index pairs with identical column definitions.
+func collectDuplicateIndexes(ctx context.Context, s *session, res *Result) {
+	rows, err := s.conn.Query(ctx, sqlDuplicateIndexes)
+	if err != nil {
+		return
+	}
+	for rows.Next() {
+		var di DuplicateIndex
+		_ = rows.Scan(&di.Schema, &di.Table, &di.Index1, &di.Index2, &di.Columns,
+			&di.Index1Size, &di.Index2Size, &di.Index1Scans, &di.Index2Scans)
+		res.DuplicateIndexes = append(res.DuplicateIndexes, di)
+	}
+	rows.Close()
+}
+
+// collectRedundantIndexes reads indexes covered by the leading columns of a
+// wider index.
+func collectRedundantIndexes(ctx context.Context, s *session, res *Result) {
+	rows, err := s.conn.Query(ctx, sqlRedundantIndexes)
+	if err != nil {
+		return
+	}
+	for rows.Next() {
+		var ri RedundantIndex
+		_ = rows.Scan(&ri.Schema, &ri.Table, &ri.Index, &ri.Columns, &ri.SizeBytes, &ri.Scans,
+			&ri.CoveredBy, &ri.CoveredByColumns, &ri.CoveredBySize, &ri.CoveredByScans)
+		res.RedundantIndexes = append(res.RedundantIndexes, ri)
+	}
+	rows.Close()
+}
+
+// collectInvalidIndexes reads indexes left behind by failed concurrent builds.
+func collectInvalidIndexes(ctx context.Context, s *session, res *Result) {
+	rows, err := s.conn.Query(ctx, sqlInvalidIndexes)
+	if err != nil {
+		return
+	}
+	for rows.Next() {
+		var ii InvalidIndex
+		_ = rows.Scan(&ii.Schema, &ii.Table, &ii.Name, &ii.SizeBytes, &ii.DDL, &ii.Reason)
+		res.InvalidIndexes = append(res.InvalidIndexes, ii)
+	}
+	rows.Close()
+}
+
+// collectFKMissingIndexes reads foreign keys without a supporting index.
+func collectFKMissingIndexes(ctx context.Context, s *session, res *Result) {
+	rows, err := s.conn.Query(ctx, sqlFKMissingIndexes)
+	if err != nil {
+		return
+	}
+	for rows.Next() {
+		var fk FKMissingIndex
+		_ = rows.Scan(&fk.Schema, &fk.Table, &fk.Constraint, &fk.Columns, &fk.RefTable, &fk.RefColumns, &fk.TableRows, &fk.SuggestedDDL)
+		res.FKMissingIndexes = append(res.FKMissingIndexes, fk)
+	}
+	rows.Close()
+}
+
+// collectSequences reads sequences past half of their range.
+func collectSequences(ctx context.Context, s *session, res *Result) {
+	rows, err := s.conn.Query(ctx, sqlSequences)
+	if err != nil {
+		return
+	}
+	for rows.Next() {
+		var sq SequenceHealth
+		_ = rows.Scan(&sq.Schema, &sq.Name, &sq.LastValue, &sq.MaxValue, &sq.Increment, &sq.PctUsed, &sq.CallsLeft)
+		res.SequenceHealth = append(res.SequenceHealth, sq)
+	}
+	rows.Close()
+}
+
+// collectPreparedXacts reads prepared (2PC) transactions, which can block
+// vacuum and hold locks.
+func collectPreparedXacts(ctx context.Context, s *session, res *Result) {
+	rows, err := s.conn.Query(ctx, sqlPreparedXacts)
+	if err != nil {
+		return
+	}
+	for rows.Next() {
+		var px PreparedXact
+		_ = rows.Scan(&px.Transaction, &px.GID, &px.Owner, &px.Database, &px.Prepared, &px.Age)
+		res.PreparedXacts = append(res.PreparedXacts, px)
+	}
+	rows.Close()
+}
@@ -0,0 +1,205 @@
+package collect
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestCollectorsRegistry verifies collectors are uniquely named and complete.
+func TestCollectorsRegistry(t *testing.T) {
+	seen := map[string]bool{}
+	for _, c := range collectors {
+		if c.name == "" || seen[c.name] {
+			t.Errorf("collector name %q is empty or duplicated", c.name)
+		}
+		seen[c.name] = true
+		if c.run == nil || len(c.queries) == 0 {
+			t.Errorf("collector %q must have run and queries", c.name)
+		}
+		if c.perDB && !strings.HasPrefix(c.note, "once per database") {
+			t.Errorf("per-database collector %q must say so in its note", c.name)
+		}
+	}
+}
+
+// TestDryRun verifies the dry run lists every collector with its statements.
+func TestDryRun(t *testing.T) {
+	var b strings.Builder
+	cfg := Config{Timeout: time.Minute, DBs: []string{"orders"}, StatementTimeout: 5 * time.Second, RepeatableRead: true,
+		CollectorTimeouts: map[string]time.Duration{"tables": 2 * time.Minute}, SoftDeadline: 45 * time.Second,
+		ReplicaURL: "postgres://replica/app"}
+	if err := DryRun(&b, cfg); err != nil {
+		t.Fatalf("DryRun() error = %v", err)
+	}
+	out := b.String()
+	for _, want := range []string{
+		"-- collector: server (timeout 20s)",
+		"-- collector: plans (timeout 1m0s)\n-- runs for top SELECT/WITH statements, without ANALYZE\n-- runs on the replica (-replica-url)",
+		"-- collector: tables (timeout 2m0s)",
+		"-- soft deadline: 45s",
+		"-- databases: orders",
+		"statement_timeout = 5000 (ms)",
+		"repeatable read",
+		sqlVersion + ";",
+		"from pg_stat_statements order by total_exec_time desc nulls last limit 20;",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("DryRun() output missing %q", want)
+		}
+	}
+	if n := strings.Count(out, "-- collector: "); n != len(collectors) {
+		t.Errorf("DryRun() listed %d collectors, expected %d", n, len(collectors))
+	}
+}
+
+// TestCollectorTimeout verifies per-database collectors get their timeout for
+// each database and that overrides take precedence.
+func TestCollectorTimeout(t *testing.T) {
+	byName := map[string]collector{}
+	for _, c := range collectors {
+		byName[c.name] = c
+	}
+	cfg := Config{DBs: []string{"a", "b", "c", "d", "e", "f", "g"}}
+	for name, want := range map[string]time.Duration{
+		"server":          collectorTimeout,
+		"tables":          collectorTimeoutHeavy,
+		"databases-extra": 7 * collectorTimeoutHeavy,
+	} {
+		if got := cfg.collectorTimeout(byName[name]); got != want {
+			t.Errorf("collectorTimeout(%s) = %s, expected %s", name, got, want)
+		}
+	}
+	if got := (Config{}).collectorTimeout(byName["databases-extra"]); got != collectorTimeoutHeavy {
+		t.Errorf("collectorTimeout(databases-extra) without -dbs = %s", got)
+	}
+	cfg.CollectorTimeouts = map[string]time.Duration{"databases-extra": time.Minute}
+	if got := cfg.collectorTimeout(byName["databases-extra"]); got != time.Minute {
+		t.Errorf("overridden collectorTimeout(databases-extra) = %s", got)
+	}
+}
+
+// TestOffloadedCollectors verifies only collectors independent of per-server
+// cumulative statistics run on the replica.
+func TestOffloadedCollectors(t *testing.T) {
+	var offloaded []string
+	for _, c := range collectors {
+		if c.offload {
+			offloaded = append(offloaded, c.name)
+		}
+	}
+	if got := strings.Join(offloaded, ","); got != "plans,column-stats,standby-conflicts,invalid-indexes,fk-missing-indexes" {
+		t.Errorf("offloaded collectors = %s", got)
+	}
+}
+
+// TestMeasureVacuumRates verifies the heap scan rate and ETA derived from two
+// progress samples, and that vacuums past the heap scan get no estimate.
+func TestMeasureVacuumRates(t *testing.T) {
+	prev := []AutoVacuum{
+		{PID: 10, Relation: "public.orders", Phase: vacuumPhaseScanHeap, Scanned: 1000, Total: 101000},
+		{PID: 11, Relation: "public.items", Phase: "vacuuming indexes", Scanned: 500, Total: 500},
+	}
+	cur := []AutoVacuum{
+		{PID: 10, Relation: "public.orders", Phase: vacuumPhaseScanHeap, Scanned: 21000, Total: 101000},
+		{PID: 11, Relation: "public.items", Phase: "vacuuming indexes", Scanned: 500, Total: 500},
+		{PID: 12, Relation: "public.users", Phase: vacuumPhaseScanHeap, Scanned: 10, Total: 100},
+	}
+	measureVacuumRates(prev, cur, 2*time.Second)
+
+	if cur[0].ScanRate != 10000 {
+		t.Errorf("ScanRate = %v, want 10000", cur[0].ScanRate)
+	}
+	if got := cur[0].ETA(); got != 8*time.Second {
+		t.Errorf("ETA() = %v, want 8s", got)
+	}
+	if got := cur[0].Progress(); got < 20.79 || got > 20.8 {
+		t.Errorf("Progress() = %v, want ~20.8", got)
+	}
+	for _, av := range cur[1:] {
+		if av.ScanRate != 0 || av.ETA() != 0 {
+			t.Errorf("%s: unexpected rate %v / ETA %v", av.Relation, av.ScanRate, av.ETA())
+		}
+	}
+	if !scanningHeap(cur) || scanningHeap(cur[1:2]) {
+		t.Errorf("scanningHeap mismatch")
+	}
+}
+
+// TestVacuumDeadMemory verifies multi-pass vacuums are recognized and the
+// memory fitting them in one pass is extrapolated, for tuple counts and for
+// the byte counters of PostgreSQL 17.
+func TestVacuumDeadMemory(t *testing.T) {
+	tests := []struct {
+		name   string
+		av     AutoVacuum
+		multi  bool
+		limit  int64
+		needed int64
+	}{
+		{"single pass", AutoVacuum{Phase: vacuumPhaseScanHeap, Scanned: 50, Total: 100, MaxDeadTuples: 1000, NumDeadTuples: 100}, false, 6000, 1200},
+		{"second fill while scanning", AutoVacuum{Phase: vacuumPhaseScanHeap, Scanned: 50, Total: 100, IndexVacuumCount: 1, MaxDeadTuples: 1000, NumDeadTuples: 500}, true, 6000, 18000},
+		{"two passes done", AutoVacuum{Phase: "vacuuming indexes", Scanned: 100, Total: 100, IndexVacuumCount: 2, MaxDeadTuples: 1000, NumDeadTuples: 1000}, true, 6000, 18000},
+		{"capped", AutoVacuum{Phase: "vacuuming heap", IndexVacuumCount: 3, MaxDeadTuples: deadTIDMaxBytes / deadTIDBytes}, true, deadTIDMaxBytes / deadTIDBytes * deadTIDBytes, 3 * (deadTIDMaxBytes / deadTIDBytes * deadTIDBytes)},
+		{"bytes", AutoVacuum{Phase: "vacuuming heap", IndexVacuumCount: 2, MaxDeadBytes: 64 << 20, DeadBytes: 32 << 20}, true, 64 << 20, 160 << 20},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.av.MultiPass(); got != tt.multi {
+				t.Errorf("MultiPass() = %v, want %v", got, tt.multi)
+			}
+			if got := tt.av.DeadMemoryLimit(); got != tt.limit {
+				t.Errorf("DeadMemoryLimit() = %d, want %d", got, tt.limit)
+			}
+			if got := tt.av.DeadMemoryNeeded(); got != tt.needed {
+				t.Errorf("DeadMemoryNeeded() = %d, want %d", got, tt.needed)
+			}
+		})
+	}
+	if !(AutoVacuum{MaxDeadTuples: deadTIDMaxBytes / deadTIDBytes}).MemoryCapped() {
+		t.Error("a 1GB tuple array should be capped")
+	}
+	if (AutoVacuum{MaxDeadBytes: 2 << 30}).MemoryCapped() {
+		t.Error("PostgreSQL 17 memory is not capped")
+	}
+}
+
+// TestTopFilter verifies -top-queries, -min-calls and -min-mean-time shape the
+// top statements queries of pg_stat_statements and pg_stat_monitor.
+func TestTopFilter(t *testing.T) {
+	f := Config{}.topFilter()
+	if f != defaultTopFilter {
+		t.Errorf("default filter = %+v, want %+v", f, defaultTopFilter)
+	}
+	if q := pssQuery("", "total_exec_time", "mean_exec_time", orderByTotal, true, true, f); strings.Contains(q, "where") || !strings.HasSuffix(q, "limit 20") {
+		t.Errorf("default query = %s", q)
+	}
+
+	f = Config{TopQueries: 100, MinCalls: 10, MinMeanTime: 5 * time.Millisecond}.topFilter()
+	q := pssQuery("", "total_exec_time", "mean_exec_time", orderByCalls, true, true, f)
+	if !strings.Contains(q, " where calls >= 10 and mean_exec_time >= 5 order by calls") || !strings.HasSuffix(q, "limit 100") {
+		t.Errorf("filtered query = %s", q)
+	}
+	q = pgsmQuery("pg_stat_monitor", orderByTotal, true, true, Config{MinCalls: 3}.topFilter())
+	if !strings.Contains(q, " having sum(calls) >= 3 order by") || !strings.HasSuffix(q, "limit 20") {
+		t.Errorf("filtered pg_stat_monitor query = %s", q)
+	}
+}
+
+// TestStatementsUnique verifies every statement is listed once across the top
+// lists, in list order.
+func TestStatementsUnique(t *testing.T) {
+	sts := Statements{
+		TopByTotalTime: []Statement{{QueryID: 1}, {QueryID: 2}},
+		TopByCPU:       []Statement{{QueryID: 2}, {QueryID: 3}},
+		TopByCalls:     []Statement{{QueryID: 4}, {QueryID: 1}},
+		TopByIO:        []Statement{{Query: "select 5"}, {QueryID: 3}},
+	}
+	var ids []string
+	for _, st := range sts.Unique() {
+		ids = append(ids, st.Key())
+	}
+	if got := strings.Join(ids, ","); got != "1,2,3,4,select 5" {
+		t.Errorf("Unique() = %s", got)
+	}
+}
@@ -0,0 +1,92 @@
+package collect
+
+import (
+	"context"
+	"sort"
+
+	"github.com/koltyakov/pghealth/internal/sqlparse"
+)
+
+const (
+	// skewMinRows is the live rows below which a table's column statistics are
+	// not read: skew on small tables costs little.
+	skewMinRows = 10000
+
+	// skewMaxColumns caps the columns whose statistics are read, most called
+	// first.
+	skewMaxColumns = 50
+)
+
+// collectColumnStats reads pg_stats for the columns the top statements filter
+// or join on, for collected tables of the current database with at least
+// skewMinRows rows.
+func collectColumnStats(ctx context.Context, s *session, res *Result) {
+	cols := filterColumns(res.Tables, res.ConnInfo.CurrentDB, parseTopStatements(res.Statements))
+	if len(cols) == 0 {
+		return
+	}
+	schemas := make([]string, len(cols))
+	tables := make([]string, len(cols))
+	names := make([]string, len(cols))
+	calls := make(map[string]float64, len(cols))
+	for i, c := range cols {
+		schemas[i], tables[i], names[i] = c.Schema, c.Table, c.Column
+		calls[columnKey(c.Schema, c.Table, c.Column)] = c.Calls
+	}
+	rows, err := s.conn.Query(ctx, sqlColumnStats, schemas, tables, names)
+	if err != nil {
+		return
+	}
+	for rows.Next() {
+		c := ColumnStat{Database: res.ConnInfo.CurrentDB}
+		if err := rows.Scan(&c.Schema, &c.Table, &c.Column, &c.HotValue, &c.HotFreq, &c.NullFrac, &c.NDistinct); err != nil {
+			continue
+		}
+		c.Calls = calls[columnKey(c.Schema, c.Table, c.Column)]
+		res.ColumnStats = append(res.ColumnStats, c)
+	}
+	rows.Close()
+	sort.SliceStable(res.ColumnStats, func(i, j int) bool { return res.ColumnStats[i].Calls > res.ColumnStats[j].Calls })
+}
+
+// filterColumns lists the columns of tables in db with at least skewMinRows
+// rows that parsed statements compare by equality or join on, with the calls
+// of those statements, most called first and at most skewMaxColumns.
+// Unqualified table references match every schema holding the table.
+func filterColumns(tables []TableStat, db string, parsed []parsedStatement) []ColumnStat {
+	byKey := map[string]*ColumnStat{}
+	for _, t := range tables {
+		if t.Database != db || t.NLiveTup < skewMinRows {
+			continue
+		}
+		for _, p := range parsed {
+			for _, f := range p.stmt.Filters {
+				if f.Kind == sqlparse.Range || !f.Table.Matches(t.Schema, t.Name) {
+					continue
+				}
+				k := columnKey(t.Schema, t.Name, f.Column)
+				if byKey[k] == nil {
+					byKey[k] = &ColumnStat{Database: db, Schema: t.Schema, Table: t.Name, Column: f.Column}
+				}
+				byKey[k].Calls += p.calls
+			}
+		}
+	}
+	weights := make(map[string]float64, len(byKey))
+	for k, c := range byKey {
+		weights[k] = c.Calls
+	}
+	var out []ColumnStat
+	for _, k := range byWeight(weights) {
+		if len(out) == skewMaxColumns {
+			break
+		}
+		out = append(out, *byKey[k])
+	}
+	return out
+}
+
+// columnKey identifies a column of the current database.
+func columnKey(schema, table, column string) string {
+	return schema + "." + table + "." + column
+}
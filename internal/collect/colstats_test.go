@@ -0,0 +1,31 @@
+package collect
+
+import (
+	"fmt"
+	"testing"
+)
+
+// TestFilterColumns verifies the columns whose statistics are read: equality
+// and join columns of large tables in the current database, most called
+// first, without range columns or small tables.
+func TestFilterColumns(t *testing.T) {
+	tables := []TableStat{
+		{Database: "app", Schema: "public", Name: "orders", NLiveTup: 1_000_000},
+		{Database: "app", Schema: "public", Name: "customers", NLiveTup: 50_000},
+		{Database: "app", Schema: "public", Name: "flags", NLiveTup: 10},
+		{Database: "other", Schema: "public", Name: "orders", NLiveTup: 1_000_000},
+	}
+	res := Result{Statements: Statements{TopByTotalTime: []Statement{
+		{QueryID: 1, Calls: 100, Query: "SELECT * FROM orders o JOIN customers c ON c.id = o.customer_id WHERE o.status = $1 AND o.created_at > $2"},
+		{QueryID: 2, Calls: 500, Query: "SELECT * FROM public.orders WHERE status = $1 AND tenant_id = $2"},
+		{QueryID: 3, Calls: 900, Query: "SELECT * FROM flags WHERE name = $1"},
+	}}}
+	var got []string
+	for _, c := range filterColumns(tables, "app", parseTopStatements(res.Statements)) {
+		got = append(got, fmt.Sprintf("%s.%s.%s=%.0f", c.Database, c.Table, c.Column, c.Calls))
+	}
+	want := fmt.Sprint([]string{"app.orders.status=600", "app.orders.tenant_id=500", "app.customers.id=100", "app.orders.customer_id=100"})
+	if fmt.Sprint(got) != want {
+		t.Errorf("filterColumns() = %v, expected %s", got, want)
+	}
+}
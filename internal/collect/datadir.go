@@ -0,0 +1,97 @@
+package collect
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// managedServices maps custom setting prefixes to the managed service that
+// defines them, and whether the service always encrypts storage at rest.
+var managedServices = []struct {
+	prefix    string
+	name      string
+	encrypted bool
+}{
+	{"rds", "Amazon RDS", false},
+	{"cloudsql", "Google Cloud SQL", true},
+	{"alloydb", "Google AlloyDB", true},
+	{"azure", "Azure Database for PostgreSQL", true},
+	{"neon", "Neon", true},
+}
+
+// collectDataDirectory reads the data directory, data checksums, the managed
+// service and at-rest encryption indicators, and where pg_wal points when the
+// server runs on this machine.
+func collectDataDirectory(ctx context.Context, s *session, res *Result) {
+	d := &DataDirectory{}
+	var aurora, edbTDE bool
+	var prefixes, preload string
+	if err := s.conn.QueryRow(ctx, sqlDataDirectory).Scan(&d.Path, &d.Checksums, &aurora, &edbTDE, &prefixes, &preload); err != nil {
+		return
+	}
+	d.Managed, d.Encryption = storageEncryption(strings.Split(prefixes, ","), aurora, edbTDE, preload)
+
+	var pid int
+	var addr string
+	if d.Path != "" && s.conn.QueryRow(ctx, sqlBackendAddr).Scan(&pid, &addr) == nil && (s.cfg.LocalOS || isLocalBackend(pid, addr)) {
+		d.WALPath = walPath(d.Path)
+	}
+	res.DataDirectory = d
+}
+
+// storageEncryption names the managed service from the custom setting
+// prefixes and how storage is encrypted at rest, where that is derivable.
+func storageEncryption(prefixes []string, aurora, edbTDE bool, preload string) (managed, encryption string) {
+	switch {
+	case preloads(preload, "pg_tde"):
+		encryption = "pg_tde (transparent data encryption)"
+	case edbTDE:
+		encryption = "EDB transparent data encryption"
+	}
+	if aurora {
+		return "Amazon Aurora", encryption
+	}
+	for _, m := range managedServices {
+		if slices.Contains(prefixes, m.prefix) {
+			if m.encrypted && encryption == "" {
+				encryption = "by the provider (always on)"
+			}
+			return m.name, encryption
+		}
+	}
+	return "", encryption
+}
+
+// preloads reports whether a comma-separated library list such as
+// shared_preload_libraries names lib.
+func preloads(list, lib string) bool {
+	for _, l := range strings.Split(list, ",") {
+		if strings.Trim(strings.TrimSpace(l), `"`) == lib {
+			return true
+		}
+	}
+	return false
+}
+
+// walPath resolves pg_wal of a local data directory; empty when unreadable.
+func walPath(dataDir string) string {
+	p := filepath.Join(procRoot, dataDir, "pg_wal")
+	fi, err := os.Lstat(p)
+	if err != nil {
+		return ""
+	}
+	if fi.Mode()&os.ModeSymlink == 0 {
+		return filepath.Join(dataDir, "pg_wal")
+	}
+	target, err := os.Readlink(p)
+	if err != nil {
+		return ""
+	}
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(dataDir, target)
+	}
+	return target
+}
@@ -0,0 +1,60 @@
+package collect
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestStorageEncryption verifies managed services and TDE modules are
+// recognized from setting prefixes and preloaded libraries.
+func TestStorageEncryption(t *testing.T) {
+	for _, tt := range []struct {
+		prefixes            []string
+		aurora, edb         bool
+		preload             string
+		managed, encryption string
+	}{
+		{[]string{"rds", "pg_stat_statements"}, false, false, "rdsutils,pg_stat_statements", "Amazon RDS", ""},
+		{[]string{"rds", "apg_plan_mgmt"}, true, false, "", "Amazon Aurora", ""},
+		{[]string{"cloudsql"}, false, false, "", "Google Cloud SQL", "by the provider (always on)"},
+		{[]string{"auto_explain"}, false, false, "auto_explain, pg_tde", "", "pg_tde (transparent data encryption)"},
+		{nil, false, true, "", "", "EDB transparent data encryption"},
+		{nil, false, false, "", "", ""},
+	} {
+		managed, encryption := storageEncryption(tt.prefixes, tt.aurora, tt.edb, tt.preload)
+		if managed != tt.managed || encryption != tt.encryption {
+			t.Errorf("storageEncryption(%v, %v, %v, %q) = %q, %q; expected %q, %q", tt.prefixes, tt.aurora, tt.edb, tt.preload, managed, encryption, tt.managed, tt.encryption)
+		}
+	}
+}
+
+// TestWALPath verifies pg_wal is resolved through a symlink and reported
+// separate only then.
+func TestWALPath(t *testing.T) {
+	root := t.TempDir()
+	old := procRoot
+	procRoot = root
+	defer func() { procRoot = old }()
+
+	for _, dir := range []string{"data/main/pg_wal", "data/other", "wal/other"} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink("/wal/other", filepath.Join(root, "data/other/pg_wal")); err != nil {
+		t.Skip(err)
+	}
+
+	d := DataDirectory{Path: "/data/main", WALPath: walPath("/data/main")}
+	if d.WALPath != "/data/main/pg_wal" || d.WALSeparate() {
+		t.Errorf("plain pg_wal: WALPath = %q, separate = %v", d.WALPath, d.WALSeparate())
+	}
+	d = DataDirectory{Path: "/data/other", WALPath: walPath("/data/other")}
+	if d.WALPath != "/wal/other" || !d.WALSeparate() {
+		t.Errorf("linked pg_wal: WALPath = %q, separate = %v", d.WALPath, d.WALSeparate())
+	}
+	if got := walPath("/missing"); got != "" {
+		t.Errorf("missing data directory: WALPath = %q", got)
+	}
+}
@@ -0,0 +1,137 @@
+package collect
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// deltaMaxStatements caps the statements listed by delta sampling.
+const deltaMaxStatements = 20
+
+// counterSample is one reading of the cumulative statistics counters.
+type counterSample struct {
+	at          time.Time
+	databases   map[string]DatabaseDelta // counters since the last reset
+	checkpoints *CheckpointDelta
+	walBytes    float64 // WAL position; 0 when unreadable
+	statements  []Statement
+	pss         bool // statements were read
+}
+
+// sampleCounters reads pg_stat_database, the background writer and
+// checkpointer, the WAL position and pg_stat_statements. Views that cannot be
+// read are left out of the sample.
+func sampleCounters(ctx context.Context, s *session) *counterSample {
+	c := &counterSample{at: time.Now(), databases: map[string]DatabaseDelta{}}
+	if rows, err := s.conn.Query(ctx, sqlDeltaDatabases); err == nil {
+		for rows.Next() {
+			var d DatabaseDelta
+			if err := rows.Scan(&d.Name, &d.Commits, &d.Rollbacks, &d.BlksRead, &d.BlksHit, &d.TupReturned, &d.TupFetched,
+				&d.TupInserted, &d.TupUpdated, &d.TupDeleted, &d.TempBytes, &d.Deadlocks); err == nil {
+				c.databases[d.Name] = d
+			}
+		}
+		rows.Close()
+	}
+	for _, q := range []string{sqlDeltaCheckpointer, sqlDeltaBgwriter} {
+		var cp CheckpointDelta
+		err := s.conn.QueryRow(ctx, q).Scan(&cp.Timed, &cp.Requested, &cp.BuffersCheckpoint, &cp.BuffersClean, &cp.BuffersBackend, &cp.BuffersAlloc)
+		if err == nil {
+			c.checkpoints = &cp
+			break
+		}
+	}
+	_ = queryRow(ctx, s.conn, sqlDeltaWAL, &c.walBytes)
+
+	var schema string
+	if err := queryRow(ctx, s.conn, sqlPSSSchema, &schema); err != nil {
+		return c
+	}
+	for _, q := range []string{pssDeltaQuery(schema, "total_exec_time"), pssDeltaQuery(schema, "total_time")} {
+		rows, err := s.conn.Query(ctx, q)
+		if err != nil {
+			continue
+		}
+		var sts []Statement
+		for rows.Next() {
+			var st Statement
+			if err := rows.Scan(&st.QueryID, &st.Query, &st.Calls, &st.TotalTime, &st.Rows, &st.SharedBlksRead, &st.SharedBlksWrite, &st.TempBlksWrite); err == nil {
+				sts = append(sts, st)
+			}
+		}
+		err = rows.Err()
+		rows.Close()
+		if err == nil {
+			c.statements, c.pss = sts, true
+			break
+		}
+	}
+	return c
+}
+
+// finishDelta waits until Config.Delta has passed since the first sample,
+// samples the counters again and records the difference in res.Delta. An
+// interrupted wait is noted and leaves res.Delta unset.
+func finishDelta(ctx context.Context, s *session, first *counterSample, res *Result) {
+	timer := time.NewTimer(time.Until(first.at.Add(s.cfg.Delta)))
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		res.Errors = append(res.Errors, fmt.Sprintf("delta: run ended before the %s sampling interval", s.cfg.Delta))
+		return
+	case <-timer.C:
+	}
+	res.Delta = newDeltaStats(first, sampleCounters(ctx, s))
+}
+
+// newDeltaStats subtracts two samples. Databases and checkpoint counters that
+// went back (a statistics reset between the samples) are left out; a
+// statement that is new in the second sample counts in full.
+func newDeltaStats(from, to *counterSample) *DeltaStats {
+	d := &DeltaStats{From: from.at, To: to.at}
+	for name, b := range to.databases {
+		a, ok := from.databases[name]
+		if !ok || b.Commits < a.Commits || b.BlksRead < a.BlksRead {
+			continue
+		}
+		d.Databases = append(d.Databases, DatabaseDelta{
+			Name: name, Commits: b.Commits - a.Commits, Rollbacks: b.Rollbacks - a.Rollbacks,
+			BlksRead: b.BlksRead - a.BlksRead, BlksHit: b.BlksHit - a.BlksHit,
+			TupReturned: b.TupReturned - a.TupReturned, TupFetched: b.TupFetched - a.TupFetched,
+			TupInserted: b.TupInserted - a.TupInserted, TupUpdated: b.TupUpdated - a.TupUpdated, TupDeleted: b.TupDeleted - a.TupDeleted,
+			TempBytes: b.TempBytes - a.TempBytes, Deadlocks: b.Deadlocks - a.Deadlocks,
+		})
+	}
+	sort.Slice(d.Databases, func(i, j int) bool {
+		if d.Databases[i].Commits != d.Databases[j].Commits {
+			return d.Databases[i].Commits > d.Databases[j].Commits
+		}
+		return d.Databases[i].Name < d.Databases[j].Name
+	})
+	if a, b := from.checkpoints, to.checkpoints; a != nil && b != nil && b.Timed >= a.Timed && b.BuffersAlloc >= a.BuffersAlloc {
+		d.Checkpoints = &CheckpointDelta{
+			Timed: b.Timed - a.Timed, Requested: b.Requested - a.Requested,
+			BuffersCheckpoint: b.BuffersCheckpoint - a.BuffersCheckpoint, BuffersClean: b.BuffersClean - a.BuffersClean,
+			BuffersBackend: b.BuffersBackend - a.BuffersBackend, BuffersAlloc: b.BuffersAlloc - a.BuffersAlloc,
+		}
+	}
+	if from.walBytes > 0 && to.walBytes >= from.walBytes {
+		d.WALBytes, d.HasWAL = int64(to.walBytes-from.walBytes), true
+	}
+	if from.pss && to.pss {
+		base := from.statements
+		known := make(map[int64]bool, len(base))
+		for _, st := range base {
+			known[st.QueryID] = true
+		}
+		for _, st := range to.statements {
+			if !known[st.QueryID] {
+				base = append(base, Statement{QueryID: st.QueryID})
+			}
+		}
+		d.Statements = NewStatementWindow(base, from.at, Statements{TopByTotalTime: to.statements}, to.at, deltaMaxStatements)
+	}
+	return d
+}
@@ -0,0 +1,61 @@
+package collect
+
+import (
+	"testing"
+	"time"
+)
+
+// TestNewDeltaStats verifies the counters are subtracted, resets are left out
+// and statements new in the second sample count in full.
+func TestNewDeltaStats(t *testing.T) {
+	at := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	from := &counterSample{
+		at: at,
+		databases: map[string]DatabaseDelta{
+			"app":   {Name: "app", Commits: 1000, BlksRead: 100, BlksHit: 900},
+			"reset": {Name: "reset", Commits: 5000, BlksRead: 50},
+		},
+		checkpoints: &CheckpointDelta{Timed: 10, BuffersCheckpoint: 500, BuffersAlloc: 100},
+		walBytes:    1 << 30,
+		statements:  []Statement{{QueryID: 1, Query: "select 1", Calls: 100, TotalTime: 100}},
+		pss:         true,
+	}
+	to := &counterSample{
+		at: at.Add(time.Minute),
+		databases: map[string]DatabaseDelta{
+			"app":   {Name: "app", Commits: 7000, BlksRead: 200, BlksHit: 10800},
+			"reset": {Name: "reset", Commits: 20},
+			"new":   {Name: "new", Commits: 5},
+		},
+		checkpoints: &CheckpointDelta{Timed: 11, BuffersCheckpoint: 800, BuffersAlloc: 160},
+		walBytes:    1<<30 + 60<<20,
+		statements: []Statement{
+			{QueryID: 1, Query: "select 1", Calls: 700, TotalTime: 400},
+			{QueryID: 2, Query: "select 2", Calls: 60, TotalTime: 600},
+		},
+		pss: true,
+	}
+
+	d := newDeltaStats(from, to)
+	if d.Duration() != time.Minute || len(d.Databases) != 1 {
+		t.Fatalf("newDeltaStats() = %+v", d)
+	}
+	if db := d.Databases[0]; db.Name != "app" || d.PerSecond(db.Commits) != 100 || db.HitRatio() != 99 {
+		t.Errorf("database delta = %+v, want 100 commits/s at a 99%% hit ratio", db)
+	}
+	if cp := d.Checkpoints; cp == nil || cp.Timed != 1 || cp.BuffersCheckpoint != 300 || cp.BuffersAlloc != 60 {
+		t.Errorf("checkpoints = %+v", cp)
+	}
+	if !d.HasWAL || d.PerSecond(d.WALBytes) != 1<<20 {
+		t.Errorf("WAL = %d bytes, want 1 MB/s", d.WALBytes)
+	}
+	w := d.Statements
+	if w == nil || len(w.Statements) != 2 || w.Statements[0].QueryID != 2 || w.Statements[0].Calls != 60 || w.Statements[1].Calls != 600 {
+		t.Errorf("statements = %+v", w)
+	}
+
+	from.pss, from.walBytes, from.checkpoints = false, 0, nil
+	if d := newDeltaStats(from, to); d.Statements != nil || d.HasWAL || d.Checkpoints != nil {
+		t.Errorf("unread views: delta = %+v", d)
+	}
+}
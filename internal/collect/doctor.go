@@ -0,0 +1,222 @@
+package collect
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// requirement is a privilege or extension a collector needs for complete data.
+type requirement int
+
+const (
+	// reqStatsRole is membership in pg_read_all_stats (granted through
+	// pg_monitor): without it other sessions' queries, states and client
+	// details are hidden and several views return only the caller's rows.
+	reqStatsRole requirement = iota + 1
+	// reqPgStatStatements is a visible pg_stat_statements view, or a
+	// pg_stat_monitor view in its place.
+	reqPgStatStatements
+	// reqPgBuffercache is the pg_buffercache extension (optional memory detail).
+	reqPgBuffercache
+	// reqConnect is CONNECT on every database listed in -dbs.
+	reqConnect
+)
+
+// Collector statuses reported by Doctor.
+const (
+	StatusOK          = "ok"
+	StatusLimited     = "limited"
+	StatusUnavailable = "unavailable"
+)
+
+// Doctor probes used to determine capabilities.
+const (
+	sqlDoctorRole        = `select current_user, version(), coalesce((select rolsuper from pg_roles where rolname = current_user), false)`
+	sqlDoctorMember      = `select case when exists(select 1 from pg_roles where rolname = $1) then pg_has_role(current_user, $1, 'member') else false end`
+	sqlDoctorPreloaded   = `select coalesce(current_setting('shared_preload_libraries', true), '') like '%pg_stat_statements%'`
+	sqlDoctorConnectPriv = `select has_database_privilege($1, 'CONNECT')`
+)
+
+// capabilities are the privileges and extensions the collectors depend on.
+type capabilities struct {
+	role             string
+	superuser        bool
+	statsRole        bool
+	pgStatStatements bool
+	pssPreloaded     bool
+	pgStatMonitor    bool
+	pgBuffercache    bool
+	noConnect        []string // -dbs entries the role cannot connect to
+}
+
+// CollectorStatus tells whether a collector will return complete data.
+type CollectorStatus struct {
+	Name    string
+	Status  string   // StatusOK, StatusLimited or StatusUnavailable
+	Reasons []string // why the status is not ok
+}
+
+// Diagnosis is the result of Doctor: what the current role can collect and
+// the statements that unlock the rest.
+type Diagnosis struct {
+	Target           string
+	Version          string
+	Role             string
+	Superuser        bool
+	StatsRole        bool // member of pg_read_all_stats (e.g. through pg_monitor)
+	PgStatStatements bool
+	PgStatMonitor    bool
+	PgBuffercache    bool
+	Collectors       []CollectorStatus
+	Fixes            []string // GRANT / CREATE EXTENSION statements, run as a superuser
+}
+
+// Doctor connects with cfg and reports which collectors will work with the
+// current role, without running the collectors themselves.
+func Doctor(ctx context.Context, cfg Config) (Diagnosis, error) {
+	conn, err := connect(ctx, cfg, cfg.URL, nil)
+	if err != nil {
+		return Diagnosis{}, err
+	}
+	defer conn.Close(ctx)
+
+	var caps capabilities
+	var version string
+	if err := conn.QueryRow(ctx, sqlDoctorRole).Scan(&caps.role, &version, &caps.superuser); err != nil {
+		return Diagnosis{}, fmt.Errorf("read role: %w", err)
+	}
+	for _, r := range []string{"pg_read_all_stats", "pg_monitor"} {
+		var member bool
+		if err := conn.QueryRow(ctx, sqlDoctorMember, r).Scan(&member); err == nil && member {
+			caps.statsRole = true
+		}
+	}
+	caps.pgStatStatements = hasPgStatStatements(ctx, conn)
+	_ = queryRow(ctx, conn, sqlDoctorPreloaded, &caps.pssPreloaded)
+	_, caps.pgStatMonitor = findPgStatMonitor(ctx, conn)
+	_ = queryRow(ctx, conn, sqlHasBuffercache, &caps.pgBuffercache)
+	for _, db := range cfg.DBs {
+		var ok bool
+		if err := conn.QueryRow(ctx, sqlDoctorConnectPriv, db).Scan(&ok); err != nil || !ok {
+			caps.noConnect = append(caps.noConnect, db)
+		}
+	}
+
+	var current string
+	_ = queryRow(ctx, conn, sqlCurrentDB, &current)
+	d := diagnose(caps, current)
+	d.Target = TargetName(cfg.URL)
+	d.Version = version
+	return d, nil
+}
+
+// diagnose maps capabilities onto the collector registry and lists the fixes
+// for anything missing. db is the database pg_stat_statements is created in.
+func diagnose(caps capabilities, db string) Diagnosis {
+	d := Diagnosis{
+		Role:             caps.role,
+		Superuser:        caps.superuser,
+		StatsRole:        caps.statsRole || caps.superuser,
+		PgStatStatements: caps.pgStatStatements,
+		PgStatMonitor:    caps.pgStatMonitor,
+		PgBuffercache:    caps.pgBuffercache,
+	}
+	needed := map[requirement]bool{}
+	for _, c := range collectors {
+		st := CollectorStatus{Name: c.name, Status: StatusOK}
+		for _, req := range c.requires {
+			switch req {
+			case reqStatsRole:
+				if !d.StatsRole {
+					st.Reasons = append(st.Reasons, "other sessions' details are hidden without pg_read_all_stats")
+					st.Status = worseStatus(st.Status, StatusLimited)
+					needed[req] = true
+				}
+			case reqPgStatStatements:
+				if !caps.pgStatStatements && !caps.pgStatMonitor {
+					st.Reasons = append(st.Reasons, "pg_stat_statements is not installed or not visible")
+					st.Status = StatusUnavailable
+					needed[req] = true
+				}
+			case reqPgBuffercache:
+				if !caps.pgBuffercache {
+					st.Reasons = append(st.Reasons, "pg_buffercache is not installed (shared buffer usage is skipped)")
+					st.Status = worseStatus(st.Status, StatusLimited)
+					needed[req] = true
+				}
+			case reqConnect:
+				if len(caps.noConnect) > 0 {
+					st.Reasons = append(st.Reasons, "no CONNECT on "+strings.Join(caps.noConnect, ", "))
+					st.Status = worseStatus(st.Status, StatusLimited)
+					needed[req] = true
+				}
+			}
+		}
+		d.Collectors = append(d.Collectors, st)
+	}
+
+	role := quoteIdent(caps.role)
+	if needed[reqStatsRole] {
+		d.Fixes = append(d.Fixes, "GRANT pg_monitor TO "+role+";")
+	}
+	if needed[reqPgStatStatements] {
+		if !caps.pssPreloaded {
+			d.Fixes = append(d.Fixes, "ALTER SYSTEM SET shared_preload_libraries = 'pg_stat_statements'; -- keep existing entries; requires a restart")
+		}
+		d.Fixes = append(d.Fixes, "CREATE EXTENSION IF NOT EXISTS pg_stat_statements; -- in database "+quoteIdent(db))
+	}
+	if needed[reqPgBuffercache] {
+		d.Fixes = append(d.Fixes, "CREATE EXTENSION IF NOT EXISTS pg_buffercache; -- optional, in database "+quoteIdent(db))
+	}
+	if needed[reqConnect] {
+		for _, name := range caps.noConnect {
+			d.Fixes = append(d.Fixes, "GRANT CONNECT ON DATABASE "+quoteIdent(name)+" TO "+role+";")
+		}
+	}
+	return d
+}
+
+// worseStatus returns the more severe of two statuses.
+func worseStatus(a, b string) string {
+	rank := map[string]int{StatusOK: 0, StatusLimited: 1, StatusUnavailable: 2}
+	if rank[b] > rank[a] {
+		return b
+	}
+	return a
+}
+
+// WriteText prints the diagnosis as a plain-text checklist.
+func (d Diagnosis) WriteText(w io.Writer) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "pghealth doctor: %s\n", d.Target)
+	if d.Version != "" {
+		fmt.Fprintf(&b, "server: %s\n", d.Version)
+	}
+	yesNo := func(v bool) string {
+		if v {
+			return "yes"
+		}
+		return "no"
+	}
+	fmt.Fprintf(&b, "role: %s (superuser: %s, pg_read_all_stats/pg_monitor: %s)\n", d.Role, yesNo(d.Superuser), yesNo(d.StatsRole))
+	fmt.Fprintf(&b, "extensions: pg_stat_statements: %s, pg_stat_monitor: %s, pg_buffercache: %s\n\n", yesNo(d.PgStatStatements), yesNo(d.PgStatMonitor), yesNo(d.PgBuffercache))
+	for _, c := range d.Collectors {
+		fmt.Fprintf(&b, "%-12s %s", strings.ToUpper(c.Status), c.Name)
+		if len(c.Reasons) > 0 {
+			fmt.Fprintf(&b, " — %s", strings.Join(c.Reasons, "; "))
+		}
+		b.WriteString("\n")
+	}
+	if len(d.Fixes) == 0 {
+		b.WriteString("\nAll collectors have the privileges and extensions they need.\n")
+	} else {
+		b.WriteString("\nTo unlock the missing sections, run as a superuser:\n\n")
+		for _, f := range d.Fixes {
+			fmt.Fprintf(&b, "  %s\n", f)
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,65 @@
+package collect
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestDiagnose verifies missing privileges map to collector statuses and fixes.
+func TestDiagnose(t *testing.T) {
+	status := func(d Diagnosis, name string) string {
+		for _, c := range d.Collectors {
+			if c.Name == name {
+				return c.Status
+			}
+		}
+		return ""
+	}
+
+	full := diagnose(capabilities{role: "pghealth", statsRole: true, pgStatStatements: true, pgBuffercache: true}, "app")
+	if len(full.Fixes) != 0 {
+		t.Errorf("diagnose(full) fixes = %v, expected none", full.Fixes)
+	}
+	for _, c := range full.Collectors {
+		if c.Status != StatusOK {
+			t.Errorf("diagnose(full) %s = %s, expected ok", c.Name, c.Status)
+		}
+	}
+
+	monitor := diagnose(capabilities{role: "pghealth", statsRole: true, pgStatMonitor: true, pgBuffercache: true}, "app")
+	if got := status(monitor, "statements"); got != StatusOK {
+		t.Errorf("diagnose(pg_stat_monitor) statements = %q, expected ok", got)
+	}
+
+	bare := diagnose(capabilities{role: "app", noConnect: []string{"orders"}}, "app")
+	tests := map[string]string{
+		"server":          StatusOK,
+		"activity":        StatusLimited,
+		"statements":      StatusUnavailable,
+		"plans":           StatusUnavailable,
+		"memory":          StatusLimited,
+		"databases-extra": StatusLimited,
+	}
+	for name, want := range tests {
+		if got := status(bare, name); got != want {
+			t.Errorf("diagnose(bare) %s = %q, expected %q", name, got, want)
+		}
+	}
+	fixes := strings.Join(bare.Fixes, "\n")
+	for _, want := range []string{
+		`GRANT pg_monitor TO "app";`,
+		"shared_preload_libraries",
+		"CREATE EXTENSION IF NOT EXISTS pg_stat_statements;",
+		`GRANT CONNECT ON DATABASE "orders" TO "app";`,
+	} {
+		if !strings.Contains(fixes, want) {
+			t.Errorf("diagnose(bare) fixes missing %q:\n%s", want, fixes)
+		}
+	}
+
+	// Superusers see everything regardless of role membership.
+	su := diagnose(capabilities{role: "postgres", superuser: true, pgStatStatements: true, pssPreloaded: true, pgBuffercache: true}, "app")
+	if len(su.Fixes) != 0 {
+		t.Errorf("diagnose(superuser) fixes = %v, expected none", su.Fixes)
+	}
+}
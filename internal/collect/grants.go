@@ -0,0 +1,83 @@
+package collect
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// DefaultRole is the name of the dedicated monitoring role created by RoleScript.
+const DefaultRole = "pghealth"
+
+// RoleOptions configures the role bootstrap script.
+type RoleOptions struct {
+	Role            string   // role name; DefaultRole when empty
+	DBs             []string // databases the role may connect to
+	PSSSchema       string   // schema holding pg_stat_statements; "public" when empty
+	ConnectionLimit int      // connection limit for the role; 0 = unlimited
+	SelectSchemas   []string // schemas whose tables the role may read, for query plans
+}
+
+// RoleScript returns a psql script that creates a dedicated login role with
+// the privileges the collectors use: pg_monitor (which includes
+// pg_read_all_stats and pg_read_all_settings), CONNECT on each listed database
+// and USAGE on the pg_stat_statements schema in each of them. The plans
+// collector also needs SELECT on the tables its queries read to PREPARE and
+// EXPLAIN them; that is granted only for SelectSchemas, which covers the
+// tables existing when the script runs. The script is idempotent and keeps
+// the role read-only and unprivileged otherwise.
+func RoleScript(opts RoleOptions) (string, error) {
+	role := opts.Role
+	if role == "" {
+		role = DefaultRole
+	}
+	schema := opts.PSSSchema
+	if schema == "" {
+		schema = "public"
+	}
+	if len(opts.DBs) == 0 {
+		return "", errors.New("at least one database is required")
+	}
+	if opts.ConnectionLimit < 0 {
+		return "", errors.New("connection limit must not be negative")
+	}
+	limit := opts.ConnectionLimit
+	if limit == 0 {
+		limit = -1
+	}
+	r := quoteIdent(role)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "-- pghealth monitoring role %s\n", r)
+	b.WriteString("-- Run with psql as a superuser:\n")
+	b.WriteString("--   psql \"$ADMIN_URL\" -f pghealth-role.sql\n\n")
+	b.WriteString("DO $$\nBEGIN\n")
+	fmt.Fprintf(&b, "\tIF NOT EXISTS (SELECT 1 FROM pg_roles WHERE rolname = %s) THEN\n", quoteLiteral(role))
+	fmt.Fprintf(&b, "\t\tCREATE ROLE %s LOGIN;\n", r)
+	b.WriteString("\tEND IF;\nEND\n$$;\n\n")
+	fmt.Fprintf(&b, "ALTER ROLE %s LOGIN NOSUPERUSER NOCREATEDB NOCREATEROLE NOREPLICATION NOBYPASSRLS CONNECTION LIMIT %d;\n", r, limit)
+	fmt.Fprintf(&b, "ALTER ROLE %s SET default_transaction_read_only = on;\n", r)
+	fmt.Fprintf(&b, "GRANT pg_monitor TO %s;\n", r)
+	for _, db := range opts.DBs {
+		fmt.Fprintf(&b, "GRANT CONNECT ON DATABASE %s TO %s;\n", quoteIdent(db), r)
+	}
+	b.WriteString("\n-- pg_stat_statements lives in a schema of each database\n")
+	if len(opts.SelectSchemas) > 0 {
+		b.WriteString("-- SELECT on application tables lets top queries be EXPLAINed; rerun for new tables\n")
+	}
+	for _, db := range opts.DBs {
+		fmt.Fprintf(&b, "\\connect %s\n", quoteIdent(db))
+		fmt.Fprintf(&b, "GRANT USAGE ON SCHEMA %s TO %s;\n", quoteIdent(schema), r)
+		for _, sel := range opts.SelectSchemas {
+			fmt.Fprintf(&b, "GRANT USAGE ON SCHEMA %s TO %s;\n", quoteIdent(sel), r)
+			fmt.Fprintf(&b, "GRANT SELECT ON ALL TABLES IN SCHEMA %s TO %s;\n", quoteIdent(sel), r)
+		}
+	}
+	fmt.Fprintf(&b, "\n-- Set a password interactively (not stored in this file):\n--   \\password %s\n", r)
+	return b.String(), nil
+}
+
+// quoteLiteral quotes s as a SQL string literal.
+func quoteLiteral(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
@@ -0,0 +1,44 @@
+package collect
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestRoleScript verifies the bootstrap script grants what collectors use,
+// and SELECT on application tables only when asked.
+func TestRoleScript(t *testing.T) {
+	script, err := RoleScript(RoleOptions{DBs: []string{"app", "Orders"}, PSSSchema: "monitoring", ConnectionLimit: 2})
+	if err != nil {
+		t.Fatalf("RoleScript() error = %v", err)
+	}
+	for _, want := range []string{
+		"WHERE rolname = 'pghealth'",
+		`CREATE ROLE "pghealth" LOGIN;`,
+		"NOSUPERUSER NOCREATEDB NOCREATEROLE NOREPLICATION NOBYPASSRLS CONNECTION LIMIT 2;",
+		`GRANT pg_monitor TO "pghealth";`,
+		`GRANT CONNECT ON DATABASE "Orders" TO "pghealth";`,
+		"\\connect \"app\"\nGRANT USAGE ON SCHEMA \"monitoring\" TO \"pghealth\";",
+	} {
+		if !strings.Contains(script, want) {
+			t.Errorf("RoleScript() missing %q:\n%s", want, script)
+		}
+	}
+	if strings.Contains(script, "PASSWORD") {
+		t.Error("RoleScript() must not embed a password")
+	}
+	if strings.Contains(script, "GRANT SELECT") {
+		t.Error("RoleScript() must not grant SELECT without SelectSchemas")
+	}
+	script, _ = RoleScript(RoleOptions{DBs: []string{"app"}, SelectSchemas: []string{"sales"}})
+	if !strings.Contains(script, "\\connect \"app\"\nGRANT USAGE ON SCHEMA \"public\" TO \"pghealth\";\nGRANT USAGE ON SCHEMA \"sales\" TO \"pghealth\";\nGRANT SELECT ON ALL TABLES IN SCHEMA \"sales\" TO \"pghealth\";") {
+		t.Errorf("RoleScript() with SelectSchemas:\n%s", script)
+	}
+
+	if _, err := RoleScript(RoleOptions{}); err == nil {
+		t.Error("RoleScript() without databases should fail")
+	}
+	if script, _ := RoleScript(RoleOptions{Role: "o'brien", DBs: []string{"app"}}); !strings.Contains(script, "'o''brien'") {
+		t.Errorf("RoleScript() did not escape the role literal:\n%s", script)
+	}
+}
@@ -0,0 +1,254 @@
+package collect
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/koltyakov/pghealth/internal/sqlparse"
+)
+
+// unknownColumns marks a missing index hint without column evidence.
+const unknownColumns = "(unknown)"
+
+// suggestIndexColumns fills the columns of missing index hints from the
+// predicates of the collected top statements. Hints stay "(unknown)" when no
+// statement filters or sorts the table by a plain column.
+func suggestIndexColumns(res *Result) {
+	if len(res.MissingIndexes) == 0 {
+		return
+	}
+	parsed := parseTopStatements(res.Statements)
+	for i := range res.MissingIndexes {
+		h := &res.MissingIndexes[i]
+		if cols := indexColumns(h.Schema, h.Table, parsed); len(cols) > 0 {
+			h.Columns = strings.Join(cols, ", ")
+		}
+	}
+}
+
+// parsedStatement is a top statement with its call count as weight.
+type parsedStatement struct {
+	calls float64
+	stmt  sqlparse.Statement
+}
+
+// parseTopStatements parses the statements of all top lists once each,
+// skipping those whose text cannot be trusted.
+func parseTopStatements(sts Statements) []parsedStatement {
+	var parsed []parsedStatement
+	for _, st := range sts.Unique() {
+		if st.TextProblem() == "" {
+			parsed = append(parsed, parsedStatement{calls: st.Calls, stmt: sqlparse.Parse(st.Query)})
+		}
+	}
+	return parsed
+}
+
+// indexColumns orders the columns of a composite index on schema.table:
+// equality (and join) columns first, most called first, then the most called
+// range column, then the sort columns of the most called statement ordering
+// by the table. Columns appear once.
+func indexColumns(schema, table string, parsed []parsedStatement) []string {
+	eq := map[string]float64{}
+	rng := map[string]float64{}
+	var sortCols []string
+	sortWeight := 0.0
+	for _, p := range parsed {
+		for _, f := range p.stmt.Filters {
+			if !f.Table.Matches(schema, table) {
+				continue
+			}
+			if f.Kind == sqlparse.Range {
+				rng[f.Column] += p.calls
+			} else {
+				eq[f.Column] += p.calls
+			}
+		}
+		var cols []string
+		for _, k := range p.stmt.OrderBy {
+			if k.Table.Matches(schema, table) {
+				cols = append(cols, k.Column)
+			}
+		}
+		if len(cols) > 0 && (sortCols == nil || p.calls > sortWeight) {
+			sortCols, sortWeight = cols, p.calls
+		}
+	}
+
+	var out []string
+	used := map[string]bool{}
+	add := func(c string) {
+		if !used[c] {
+			used[c] = true
+			out = append(out, c)
+		}
+	}
+	for _, c := range byWeight(eq) {
+		add(c)
+	}
+	if r := byWeight(rng); len(r) > 0 {
+		add(r[0])
+	}
+	for _, c := range sortCols {
+		add(c)
+	}
+	return out
+}
+
+// byWeight returns the keys of m, heaviest first, by name on ties.
+func byWeight(m map[string]float64) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if m[keys[i]] != m[keys[j]] {
+			return m[keys[i]] > m[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+	return keys
+}
+
+// indexDDL builds a CREATE INDEX CONCURRENTLY statement serving the filters
+// and sort of one statement on schema.table: columns compared to parameters
+// or joined first, then either the ORDER BY columns with their direction,
+// when the sort is on this table alone and does not conflict with a range
+// filter, or the first range column. Equalities to constants and IS NULL
+// become the partial index predicate. Empty without usable columns or when
+// an index of the table already starts with the same columns.
+func indexDDL(schema, table string, st sqlparse.Statement, indexes []IndexStat) string {
+	var keys, partial, constCols []string
+	used := map[string]bool{}
+	add := func(c string) {
+		if !used[c] {
+			used[c] = true
+			keys = append(keys, sqlIdent(c))
+		}
+	}
+	rng := ""
+	for _, f := range st.Filters {
+		if !f.Table.Matches(schema, table) {
+			continue
+		}
+		switch {
+		case f.Value == "NULL":
+			partial = append(partial, sqlIdent(f.Column)+" IS NULL")
+			constCols = append(constCols, f.Column)
+		case f.Value != "":
+			partial = append(partial, sqlIdent(f.Column)+" = "+f.Value)
+			constCols = append(constCols, f.Column)
+		case f.Kind == sqlparse.Range:
+			if rng == "" {
+				rng = f.Column
+			}
+		default:
+			add(f.Column)
+		}
+	}
+	sorted := len(st.OrderBy) > 0
+	for _, k := range st.OrderBy {
+		sorted = sorted && k.Table.Matches(schema, table)
+	}
+	if sorted && (rng == "" || rng == st.OrderBy[0].Column) {
+		for _, k := range st.OrderBy {
+			if !used[k.Column] {
+				used[k.Column] = true
+				if k.Desc {
+					keys = append(keys, sqlIdent(k.Column)+" DESC")
+				} else {
+					keys = append(keys, sqlIdent(k.Column))
+				}
+			}
+		}
+	} else if rng != "" {
+		add(rng)
+	}
+	if len(keys) == 0 {
+		// Only constant filters: index them too, e.g. a queue polled by status
+		for _, c := range constCols {
+			add(c)
+		}
+	}
+	if len(keys) == 0 || indexCovers(schema, table, keys, indexes) {
+		return ""
+	}
+
+	var cols []string
+	for _, k := range keys {
+		cols = append(cols, strings.Trim(strings.TrimSuffix(k, " DESC"), `"`))
+	}
+	name := table + "_" + strings.Join(cols, "_") + "_idx"
+	if len(name) > 63 {
+		name = name[:59] + "_idx"
+	}
+	rel := sqlIdent(table)
+	if schema != "" {
+		rel = sqlIdent(schema) + "." + rel
+	}
+	ddl := fmt.Sprintf("CREATE INDEX CONCURRENTLY %s ON %s (%s)", sqlIdent(name), rel, strings.Join(keys, ", "))
+	if len(partial) > 0 {
+		ddl += " WHERE " + strings.Join(partial, " AND ")
+	}
+	return ddl + ";"
+}
+
+// indexCovers reports whether an index of schema.table starts with keys, as
+// read from its definition.
+func indexCovers(schema, table string, keys []string, indexes []IndexStat) bool {
+	for _, ix := range indexes {
+		if ix.Schema != schema || ix.Table != table {
+			continue
+		}
+		have := indexKeys(ix.DDL)
+		if len(have) < len(keys) {
+			continue
+		}
+		match := true
+		for i, k := range keys {
+			match = match && strings.TrimSuffix(have[i], " DESC") == strings.TrimSuffix(k, " DESC")
+		}
+		if match {
+			return true
+		}
+	}
+	return false
+}
+
+// sqlIdent quotes an identifier for SQL unless it is a plain lowercase one.
+func sqlIdent(name string) string {
+	for i, r := range name {
+		if !(r >= 'a' && r <= 'z' || r == '_' || i > 0 && (r >= '0' && r <= '9' || r == '$')) {
+			return quoteIdent(name)
+		}
+	}
+	return name
+}
+
+// indexKeys returns the key column list of an index definition as printed
+// by pg_get_indexdef, e.g. ["tenant_id", "created_at DESC"].
+func indexKeys(def string) []string {
+	at := strings.Index(def, " USING ")
+	if at < 0 {
+		return nil
+	}
+	open := strings.IndexByte(def[at:], '(')
+	if open < 0 {
+		return nil
+	}
+	start := at + open + 1
+	depth := 1
+	for i := start; i < len(def); i++ {
+		switch def[i] {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return strings.Split(def[start:i], ", ")
+			}
+		}
+	}
+	return nil
+}
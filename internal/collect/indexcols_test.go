@@ -0,0 +1,80 @@
+package collect
+
+import (
+	"testing"
+
+	"github.com/koltyakov/pghealth/internal/sqlparse"
+)
+
+// TestSuggestIndexColumns verifies hint columns are ordered equality first,
+// then range, then sort, weighted by calls across top statements.
+func TestSuggestIndexColumns(t *testing.T) {
+	res := Result{
+		MissingIndexes: []MissingIndexHint{
+			{Schema: "public", Table: "events", Columns: unknownColumns},
+			{Schema: "public", Table: "audit", Columns: unknownColumns},
+		},
+		Statements: Statements{
+			TopByTotalTime: []Statement{
+				{QueryID: 1, Calls: 100, Query: "SELECT * FROM public.events WHERE created_at >= $1 AND tenant_id = $2 ORDER BY created_at DESC LIMIT $3"},
+				{QueryID: 2, Calls: 500, Query: "SELECT * FROM events e WHERE e.kind = $1 AND e.tenant_id = $2 AND e.score > $3 ORDER BY e.id"},
+			},
+			TopByCalls: []Statement{
+				{QueryID: 2, Calls: 500, Query: "SELECT * FROM events e WHERE e.kind = $1 AND e.tenant_id = $2 AND e.score > $3 ORDER BY e.id"},
+			},
+		},
+	}
+	suggestIndexColumns(&res)
+	if got := res.MissingIndexes[0].Columns; got != "tenant_id, kind, score, id" {
+		t.Errorf("events columns = %q", got)
+	}
+	if got := res.MissingIndexes[1].Columns; got != unknownColumns {
+		t.Errorf("audit columns = %q, expected %q", got, unknownColumns)
+	}
+}
+
+// TestIndexDDL verifies the generated index puts parameter equalities first,
+// follows a compatible ORDER BY, turns constants into the partial predicate
+// and is skipped when an existing index already starts with its columns.
+func TestIndexDDL(t *testing.T) {
+	existing := []IndexStat{{Schema: "public", Table: "jobs", Name: "jobs_queue_idx", DDL: "CREATE INDEX jobs_queue_idx ON public.jobs USING btree (queue, run_at) WHERE (done = false)"}}
+	tests := []struct {
+		name, schema, table, sql, expected string
+	}{
+		{
+			name: "equality, sort and partial", schema: "public", table: "events",
+			sql:      "SELECT * FROM events WHERE tenant_id = $1 AND status = 'open' AND created_at >= $2 ORDER BY created_at DESC LIMIT $3",
+			expected: "CREATE INDEX CONCURRENTLY events_tenant_id_created_at_idx ON public.events (tenant_id, created_at DESC) WHERE status = 'open';",
+		},
+		{
+			name: "range conflicting with sort", schema: "public", table: "events",
+			sql:      "SELECT * FROM events e WHERE e.kind = $1 AND e.score > $2 AND e.archived_at IS NULL ORDER BY e.id",
+			expected: "CREATE INDEX CONCURRENTLY events_kind_score_idx ON public.events (kind, score) WHERE archived_at IS NULL;",
+		},
+		{
+			name: "join side only", schema: "", table: "Items",
+			sql:      `SELECT 1 FROM orders o JOIN "Items" i ON i.order_id = o.id WHERE o.id = $1`,
+			expected: `CREATE INDEX CONCURRENTLY "Items_order_id_idx" ON "Items" (order_id);`,
+		},
+		{
+			name: "constants only", schema: "public", table: "outbox",
+			sql:      "SELECT id FROM outbox WHERE sent = false LIMIT $1",
+			expected: "CREATE INDEX CONCURRENTLY outbox_sent_idx ON public.outbox (sent) WHERE sent = false;",
+		},
+		{
+			name: "covered by an existing index", schema: "public", table: "jobs",
+			sql: "SELECT * FROM jobs WHERE queue = $1 AND done = false ORDER BY run_at",
+		},
+		{
+			name: "no usable columns", schema: "public", table: "events",
+			sql: "SELECT count(*) FROM events WHERE lower(kind) = $1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := indexDDL(tt.schema, tt.table, sqlparse.Parse(tt.sql), existing); got != tt.expected {
+				t.Errorf("indexDDL = %q, expected %q", got, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,130 @@
+package collect
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// unlimitedCgroupMemory is the smallest cgroup v1 limit treated as no limit
+// (v1 reports "no limit" as a page-aligned maximum int64).
+const unlimitedCgroupMemory = 1 << 62
+
+// readOSCPU reads the CPU count, NUMA layout and cgroup limits of the host.
+// pid is the server backend whose cgroup applies; 0 (or a pid not visible
+// here) falls back to the cgroup of pghealth itself.
+func readOSCPU(pid int) *OSCPU {
+	c := &OSCPU{}
+	if b, err := os.ReadFile(filepath.Join(procRoot, "sys/devices/system/cpu/online")); err == nil {
+		c.CPUs = cpuListLen(strings.TrimSpace(string(b)))
+	}
+	if c.CPUs == 0 {
+		c.CPUs = runtime.NumCPU()
+	}
+
+	nodes, _ := filepath.Glob(filepath.Join(procRoot, "sys/devices/system/node/node[0-9]*"))
+	for _, dir := range nodes {
+		id, err := strconv.Atoi(strings.TrimPrefix(filepath.Base(dir), "node"))
+		if err != nil {
+			continue
+		}
+		n := NUMANode{ID: id}
+		if b, err := os.ReadFile(filepath.Join(dir, "cpulist")); err == nil {
+			n.CPUs = cpuListLen(strings.TrimSpace(string(b)))
+		}
+		if b, err := os.ReadFile(filepath.Join(dir, "meminfo")); err == nil {
+			// "Node 0 MemTotal:       32768000 kB"
+			sc := bufio.NewScanner(strings.NewReader(string(b)))
+			for sc.Scan() {
+				f := strings.Fields(sc.Text())
+				if len(f) >= 4 && f[2] == "MemTotal:" {
+					kb, _ := strconv.ParseInt(f[3], 10, 64)
+					n.MemTotalBytes = kb * 1024
+				}
+			}
+		}
+		c.NUMANodes = append(c.NUMANodes, n)
+	}
+	sort.Slice(c.NUMANodes, func(i, j int) bool { return c.NUMANodes[i].ID < c.NUMANodes[j].ID })
+	if b, err := os.ReadFile(filepath.Join(procRoot, "proc/sys/vm/zone_reclaim_mode")); err == nil {
+		c.ZoneReclaimMode, _ = strconv.Atoi(strings.TrimSpace(string(b)))
+	}
+
+	c.CPUQuota, c.MemoryLimitBytes = readCgroupLimits(pid)
+	return c
+}
+
+// readCgroupLimits returns the CPU quota (in CPUs) and memory limit of the
+// cgroup of pid, zero when unlimited. Both cgroup v2 and v1 are read.
+func readCgroupLimits(pid int) (cpus float64, memory int64) {
+	b, err := os.ReadFile(filepath.Join(procRoot, "proc", strconv.Itoa(pid), "cgroup"))
+	if err != nil {
+		if b, err = os.ReadFile(filepath.Join(procRoot, "proc/self/cgroup")); err != nil {
+			return 0, 0
+		}
+	}
+	read := func(path ...string) string {
+		b, err := os.ReadFile(filepath.Join(append([]string{procRoot, "sys/fs/cgroup"}, path...)...))
+		if err != nil {
+			return ""
+		}
+		return strings.TrimSpace(string(b))
+	}
+	// Lines are "hierarchy-id:controllers:path"; v2 has a single "0::path"
+	sc := bufio.NewScanner(strings.NewReader(string(b)))
+	for sc.Scan() {
+		parts := strings.SplitN(sc.Text(), ":", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		path := parts[2]
+		switch {
+		case parts[0] == "0" && parts[1] == "":
+			if f := strings.Fields(read(path, "cpu.max")); len(f) == 2 && f[0] != "max" {
+				quota, _ := strconv.ParseFloat(f[0], 64)
+				period, _ := strconv.ParseFloat(f[1], 64)
+				if period > 0 {
+					cpus = quota / period
+				}
+			}
+			if v := read(path, "memory.max"); v != "" && v != "max" {
+				memory, _ = strconv.ParseInt(v, 10, 64)
+			}
+		case strings.Contains(parts[1], "cpu") && !strings.Contains(parts[1], "cpuset"):
+			quota, _ := strconv.ParseFloat(read(parts[1], path, "cpu.cfs_quota_us"), 64)
+			period, _ := strconv.ParseFloat(read(parts[1], path, "cpu.cfs_period_us"), 64)
+			if quota > 0 && period > 0 {
+				cpus = quota / period
+			}
+		case parts[1] == "memory":
+			if v, err := strconv.ParseInt(read("memory", path, "memory.limit_in_bytes"), 10, 64); err == nil && v < unlimitedCgroupMemory {
+				memory = v
+			}
+		}
+	}
+	return cpus, memory
+}
+
+// cpuListLen counts the CPUs of a kernel CPU list such as "0-3,8-11".
+func cpuListLen(list string) int {
+	n := 0
+	for _, r := range strings.Split(list, ",") {
+		lo, hi, ok := strings.Cut(r, "-")
+		a, err := strconv.Atoi(lo)
+		if err != nil {
+			continue
+		}
+		if !ok {
+			n++
+			continue
+		}
+		if b, err := strconv.Atoi(hi); err == nil && b >= a {
+			n += b - a + 1
+		}
+	}
+	return n
+}
@@ -0,0 +1,49 @@
+package collect
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestReadOSCPU verifies CPUs, NUMA nodes and cgroup v2 limits are read from
+// /proc and /sys.
+func TestReadOSCPU(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"sys/devices/system/cpu/online":                            "0-15\n",
+		"sys/devices/system/node/node0/cpulist":                    "0-7\n",
+		"sys/devices/system/node/node0/meminfo":                    "Node 0 MemTotal:       33554432 kB\nNode 0 MemFree:        1024 kB\n",
+		"sys/devices/system/node/node1/cpulist":                    "8-15\n",
+		"sys/devices/system/node/node1/meminfo":                    "Node 1 MemTotal:       33554432 kB\n",
+		"proc/sys/vm/zone_reclaim_mode":                            "1\n",
+		"proc/4242/cgroup":                                         "0::/system.slice/postgresql.service\n",
+		"sys/fs/cgroup/system.slice/postgresql.service/cpu.max":    "400000 100000\n",
+		"sys/fs/cgroup/system.slice/postgresql.service/memory.max": "17179869184\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	defer func(prev string) { procRoot = prev }(procRoot)
+	procRoot = root
+
+	c := readOSCPU(4242)
+	if c.CPUs != 16 || c.CPUQuota != 4 || c.EffectiveCPUs() != 4 || c.MemoryLimitBytes != 16<<30 || c.ZoneReclaimMode != 1 {
+		t.Errorf("readOSCPU() = %+v", c)
+	}
+	if len(c.NUMANodes) != 2 || c.NUMANodes[1] != (NUMANode{ID: 1, CPUs: 8, MemTotalBytes: 32 << 30}) {
+		t.Errorf("NUMANodes = %+v", c.NUMANodes)
+	}
+
+	for list, want := range map[string]int{"0": 1, "0-3,8-11": 8, "0,2,4": 3, "": 0} {
+		if got := cpuListLen(list); got != want {
+			t.Errorf("cpuListLen(%q) = %d, want %d", list, got, want)
+		}
+	}
+}
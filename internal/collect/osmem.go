@@ -0,0 +1,105 @@
+package collect
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// procRoot is where /proc and /sys are read from (replaced in tests).
+var procRoot = "/"
+
+// isLocalBackend reports whether the backend serving the connection runs on
+// this machine: the server was reached through a socket or loopback address
+// and the backend pid is a postgres process here. Tunnels to remote servers
+// also connect through loopback, hence the process check.
+func isLocalBackend(pid int, serverAddr string) bool {
+	switch serverAddr {
+	case "", "127.0.0.1", "::1":
+	default:
+		return false
+	}
+	comm, err := os.ReadFile(filepath.Join(procRoot, "proc", strconv.Itoa(pid), "comm"))
+	return err == nil && strings.HasPrefix(strings.TrimSpace(string(comm)), "postgres")
+}
+
+// readOSMemory reads the Linux memory settings relevant to PostgreSQL. Files
+// that cannot be read leave their fields empty; nil means none could be read.
+func readOSMemory() *OSMemory {
+	m := &OSMemory{}
+	found := false
+	read := func(path string) (string, bool) {
+		b, err := os.ReadFile(filepath.Join(procRoot, path))
+		if err != nil {
+			return "", false
+		}
+		found = true
+		return strings.TrimSpace(string(b)), true
+	}
+	if v, ok := read("sys/kernel/mm/transparent_hugepage/enabled"); ok {
+		m.THPEnabled = selectedOption(v)
+	}
+	if v, ok := read("sys/kernel/mm/transparent_hugepage/defrag"); ok {
+		m.THPDefrag = selectedOption(v)
+	}
+	if v, ok := read("proc/sys/vm/overcommit_memory"); ok {
+		m.OvercommitMemory, _ = strconv.Atoi(v)
+	}
+	if v, ok := read("proc/sys/vm/overcommit_ratio"); ok {
+		m.OvercommitRatio, _ = strconv.Atoi(v)
+	}
+	if v, ok := read("proc/sys/vm/swappiness"); ok {
+		m.Swappiness, _ = strconv.Atoi(v)
+	}
+	if v, ok := read("proc/meminfo"); ok {
+		parseMeminfo(v, m)
+	}
+	if !found {
+		return nil
+	}
+	return m
+}
+
+// selectedOption returns the bracketed choice of a sysfs option list such as
+// "always [madvise] never".
+func selectedOption(v string) string {
+	start, end := strings.IndexByte(v, '['), strings.IndexByte(v, ']')
+	if start < 0 || end < start {
+		return v
+	}
+	return v[start+1 : end]
+}
+
+// parseMeminfo fills the memory and huge page totals from /proc/meminfo.
+func parseMeminfo(v string, m *OSMemory) {
+	sc := bufio.NewScanner(strings.NewReader(v))
+	for sc.Scan() {
+		key, rest, ok := strings.Cut(sc.Text(), ":")
+		if !ok {
+			continue
+		}
+		fields := strings.Fields(rest)
+		if len(fields) == 0 {
+			continue
+		}
+		n, err := strconv.ParseInt(fields[0], 10, 64)
+		if err != nil {
+			continue
+		}
+		if len(fields) > 1 && fields[1] == "kB" {
+			n *= 1024
+		}
+		switch key {
+		case "MemTotal":
+			m.MemTotalBytes = n
+		case "HugePages_Total":
+			m.HugePagesTotal = n
+		case "HugePages_Free":
+			m.HugePagesFree = n
+		case "Hugepagesize":
+			m.HugePageSizeBytes = n
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package collect
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestReadOSMemory verifies kernel memory settings are read from /proc and /sys.
+func TestReadOSMemory(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"sys/kernel/mm/transparent_hugepage/enabled": "always [madvise] never\n",
+		"sys/kernel/mm/transparent_hugepage/defrag":  "always defer defer+madvise [madvise] never\n",
+		"proc/sys/vm/overcommit_memory":              "0\n",
+		"proc/sys/vm/overcommit_ratio":               "50\n",
+		"proc/sys/vm/swappiness":                     "60\n",
+		"proc/meminfo":                               "MemTotal:       65856364 kB\nHugePages_Total:    4200\nHugePages_Free:      100\nHugepagesize:       2048 kB\n",
+		"proc/4242/comm":                             "postgres\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	defer func(prev string) { procRoot = prev }(procRoot)
+	procRoot = root
+
+	got := readOSMemory()
+	want := OSMemory{THPEnabled: "madvise", THPDefrag: "madvise", OvercommitRatio: 50, Swappiness: 60,
+		MemTotalBytes: 65856364 * 1024, HugePagesTotal: 4200, HugePagesFree: 100, HugePageSizeBytes: 2 << 20}
+	if got == nil || *got != want {
+		t.Errorf("readOSMemory() = %+v, want %+v", got, want)
+	}
+
+	tests := []struct {
+		pid  int
+		addr string
+		want bool
+	}{
+		{4242, "", true},
+		{4242, "127.0.0.1", true},
+		{4242, "10.0.0.5", false},
+		{4243, "::1", false},
+	}
+	for _, tt := range tests {
+		if got := isLocalBackend(tt.pid, tt.addr); got != tt.want {
+			t.Errorf("isLocalBackend(%d, %q) = %v, want %v", tt.pid, tt.addr, got, tt.want)
+		}
+	}
+
+	procRoot = t.TempDir()
+	if got := readOSMemory(); got != nil {
+		t.Errorf("readOSMemory() without /proc = %+v, want nil", got)
+	}
+}
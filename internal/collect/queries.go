@@ -0,0 +1,1117 @@
+package collect
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SQL executed by the collectors. Statements live here rather than inline so
+// that Run and DryRun share the exact same text: what a DBA reviews with
+// -dry-run is what pghealth sends to the server.
+
+// server
+const (
+	sqlVersion        = `select version()`
+	sqlCurrentDB      = `select current_database()`
+	sqlCurrentUser    = `select current_user`
+	sqlMaxConnections = `select setting::int from pg_settings where name='max_connections'`
+	sqlSSL            = `show ssl`
+	sqlPasswordEnc    = `show password_encryption`
+	sqlStartTime      = `select pg_postmaster_start_time()`
+	sqlInRecovery     = `select pg_is_in_recovery()`
+	sqlIsSuperuser    = `select rolsuper from pg_roles where rolname = current_user`
+	sqlHasPgMonitor   = `select exists(select 1 from pg_auth_members m join pg_roles r on r.oid=m.roleid where r.rolname='pg_monitor' and m.member=(select oid from pg_roles where rolname=current_user))`
+)
+
+// server clock and time zones; offsets are seconds east of UTC
+const sqlClock = `select clock_timestamp(), current_setting('TimeZone'), current_setting('log_timezone'),
+	extract(timezone from now())::int,
+	extract(epoch from (now() at time zone current_setting('log_timezone')) - (now() at time zone 'UTC'))::int`
+
+// sqlBackendAddr identifies the backend serving the session; the address is
+// empty over a Unix socket
+const sqlBackendAddr = `select pg_backend_pid(), coalesce(host(inet_server_addr()), '')`
+
+// pg_stat_statements detection
+const (
+	sqlPSSExtension = `select exists(select 1 from pg_extension where extname='pg_stat_statements')`
+	sqlPSSRelation  = `select exists(
+		select 1 from pg_class c join pg_namespace n on n.oid=c.relnamespace
+		where c.relname='pg_stat_statements'
+	)`
+	sqlPSSFunction = `select exists(
+		select 1 from pg_proc p join pg_namespace n on n.oid=p.pronamespace
+		where p.proname in ('pg_stat_statements_reset','pg_stat_statements')
+	)`
+	sqlPSSProbe  = `select 1 from pg_stat_statements limit 1`
+	sqlPSSSchema = `select n.nspname from pg_class c join pg_namespace n on n.oid=c.relnamespace where c.relname='pg_stat_statements' limit 1`
+)
+
+const sqlActivity = `select datname, coalesce(state,'unknown') as state, count(*) from pg_stat_activity group by 1,2 order by 1,2`
+
+// locale providers (PostgreSQL 15+) and the ICU or builtin locale (daticulocale,
+// datlocale from 17) are read through to_jsonb so older servers report libc
+const sqlDatabases = `select d.datname, pg_database_size(d.datname), coalesce(t.spcname,'pg_default'), coalesce(a.cnt,0),
+		pg_encoding_to_char(d.encoding), d.datcollate, d.datctype,
+		case j->>'datlocprovider' when 'i' then 'icu' when 'b' then 'builtin' else 'libc' end,
+		coalesce(j->>'datlocale', j->>'daticulocale', ''),
+		coalesce(a.apps, '{}')
+	from pg_database d
+	cross join lateral to_jsonb(d) j
+	left join pg_tablespace t on t.oid = d.dattablespace
+	left join (select datname, count(*) cnt,
+			array_agg(distinct application_name) filter (where application_name <> '') apps
+		from pg_stat_activity group by 1) a on a.datname = d.datname
+	where not d.datistemplate
+	order by pg_database_size(d.datname) desc`
+
+// settings; values set by pghealth's own session (client, session) are not
+// server configuration and are left out of the non-default list
+const sqlNonDefaultSettings = `select name, setting, coalesce(unit, ''), source, coalesce(boot_val, ''),
+		coalesce(min_val, ''), coalesce(max_val, ''), context, category, pending_restart
+	from pg_settings
+	where source not in ('default', 'client', 'session')
+	order by category, name`
+
+const sqlSettings = `select name, setting, coalesce(unit, ''), source, coalesce(boot_val, ''),
+		coalesce(min_val, ''), coalesce(max_val, ''), context, category, pending_restart
+	from pg_settings where name in (
+	'shared_buffers','work_mem','maintenance_work_mem','effective_cache_size','max_connections','max_parallel_workers','wal_buffers','wal_level','max_wal_size','min_wal_size','checkpoint_timeout','checkpoint_completion_target','random_page_cost','seq_page_cost','effective_io_concurrency','default_statistics_target','autovacuum','autovacuum_naptime','autovacuum_max_workers','autovacuum_vacuum_scale_factor','autovacuum_vacuum_cost_limit','track_io_timing','track_functions','track_counts','track_activities','track_activity_query_size','max_worker_processes','max_parallel_workers_per_gather','huge_pages','huge_page_size','huge_pages_status','shared_memory_type','shared_memory_size_in_huge_pages','shared_preload_libraries','session_preload_libraries','synchronous_standby_names','synchronous_commit','jit','jit_above_cost')
+	or name like 'auto_explain.%' or name like 'pgaudit.%' order by name`
+
+// configuration files: pg_file_settings and pg_hba_file_rules are readable
+// by superusers only unless granted, so both fail quietly otherwise
+const (
+	// sqlFileSettingErrors lists postgresql.conf entries that do not apply:
+	// invalid ones (error set) and ones overridden by a later entry
+	sqlFileSettingErrors = `select coalesce(sourcefile, ''), coalesce(sourceline, 0), coalesce(name, ''),
+		coalesce(setting, ''), applied, coalesce(error, '')
+	from pg_file_settings
+	where error is not null or not applied
+	order by sourcefile, sourceline`
+	sqlHBAFileErrors = `select line_number, coalesce(type, ''), coalesce(array_to_string(database, ','), ''),
+		coalesce(array_to_string(user_name, ','), ''), coalesce(address, ''), coalesce(auth_method, ''), error
+	from pg_hba_file_rules
+	where error is not null
+	order by line_number`
+)
+
+// tables
+const (
+	sqlTableStats = `select schemaname, relname, seq_scan, idx_scan, n_live_tup, n_dead_tup,
+		pg_total_relation_size(format('%I.%I', schemaname, relname)) as size_bytes
+		from pg_stat_all_tables
+		where schemaname not in ('pg_catalog','information_schema')
+			and schemaname not like 'pg_toast%'
+			and schemaname not like 'pg_temp_%'`
+
+	// sqlTablesBackfill adds user tables missing from the statistics views.
+	sqlTablesBackfill = `select n.nspname as schemaname,
+			c.relname,
+			coalesce(c.reltuples::bigint, 0) as n_live_tup,
+			pg_total_relation_size(c.oid) as size_bytes
+		from pg_class c
+		join pg_namespace n on n.oid = c.relnamespace
+		where c.relkind in ('r','m','p')
+		  and n.nspname not in ('pg_catalog','information_schema')
+		  and n.nspname not like 'pg_toast%'
+		  and n.nspname not like 'pg_temp_%'
+		  and not exists (select 1 from pg_stat_all_tables s where s.relid = c.oid)`
+
+	// sqlTablesFallback derives tables from the catalog when statistics are not visible.
+	sqlTablesFallback = `select n.nspname as schemaname,
+			c.relname,
+			0::bigint as seq_scan,
+			0::bigint as idx_scan,
+			coalesce(c.reltuples::bigint, 0) as n_live_tup,
+			0::bigint as n_dead_tup,
+			pg_total_relation_size(c.oid) as size_bytes
+		from pg_class c
+		join pg_namespace n on n.oid = c.relnamespace
+		where c.relkind in ('r','m','p')
+		  and n.nspname not in ('pg_catalog','information_schema')
+		  and n.nspname not like 'pg_toast%'
+		  and n.nspname not like 'pg_temp_%'
+		order by size_bytes desc
+		limit 1000`
+)
+
+const sqlIndexStats = `select s.schemaname, s.relname, s.indexrelname, s.idx_scan,
+	pg_relation_size(format('%I.%I', s.schemaname, s.indexrelname)),
+	pg_get_indexdef(ci.oid), s.idx_tup_read, s.idx_tup_fetch,
+	x.indisprimary, x.indisunique, x.indisexclusion, x.indisreplident
+	from pg_stat_all_indexes s
+	join pg_class ci on ci.relname = s.indexrelname
+	join pg_namespace n on n.oid = ci.relnamespace and n.nspname = s.schemaname
+	join pg_index x on x.indexrelid = ci.oid`
+
+// tablespaces: pg_tablespace_size needs CREATE on the tablespace or
+// pg_read_all_stats, except for the database's default tablespace
+const (
+	sqlTablespaces = `select t.spcname, coalesce(pg_tablespace_location(t.oid), ''),
+		case when t.oid = (select dattablespace from pg_database where datname = current_database())
+			or has_tablespace_privilege(t.oid, 'CREATE') or pg_has_role('pg_read_all_stats', 'usage')
+			then pg_tablespace_size(t.oid) else -1 end,
+		coalesce(array_to_string(t.spcoptions, ','), '')
+	from pg_tablespace t
+	where t.spcname <> 'pg_global'
+	order by t.spcname`
+
+	// sqlRelationTablespaces lists user tables and indexes placed outside the
+	// database's default tablespace (reltablespace 0)
+	sqlRelationTablespaces = `select n.nspname, c.relname, t.spcname
+	from pg_class c
+	join pg_namespace n on n.oid = c.relnamespace
+	join pg_tablespace t on t.oid = c.reltablespace
+	where c.reltablespace <> 0 and c.relkind in ('r','m','p','i','I')
+		and n.nspname not in ('pg_catalog','information_schema')
+		and n.nspname not like 'pg_toast%'`
+)
+
+// pg_stat_statements
+const (
+	sqlPSSResetInfo     = `SELECT stats_reset FROM pg_stat_statements_info`
+	sqlPSSResetDatabase = `SELECT stats_reset FROM pg_stat_database WHERE datname = current_database()`
+
+	sqlPSSIOCols = `select exists(
+		select 1 from information_schema.columns
+		where table_name='pg_stat_statements' and column_name in ('blk_read_time','blk_write_time')
+		group by table_name having count(*)=2)`
+	sqlPSSIOColsInSchema = `select exists(
+		select 1 from information_schema.columns
+		where table_schema=$1 and table_name='pg_stat_statements' and column_name in ('blk_read_time','blk_write_time')
+		group by table_schema, table_name having count(*)=2)`
+	sqlPSSBlockCols = `select exists(
+		select 1 from information_schema.columns
+		where table_name='pg_stat_statements' and column_name in ('shared_blks_read','shared_blks_written','local_blks_read','local_blks_written','temp_blks_read','temp_blks_written')
+		group by table_name having count(*)=6)`
+	sqlPSSBlockColsInSchema = `select exists(
+		select 1 from information_schema.columns
+		where table_schema=$1 and table_name='pg_stat_statements' and column_name in ('shared_blks_read','shared_blks_written','local_blks_read','local_blks_written','temp_blks_read','temp_blks_written')
+		group by table_schema, table_name having count(*)=6)`
+)
+
+// topFilter bounds a top statements list: limit rows, leaving out statements
+// with fewer calls or a lower mean time (ms) than the minimums.
+type topFilter struct {
+	limit     int
+	minCalls  float64
+	minMeanMs float64
+}
+
+// defaultTopFilter lists DefaultTopQueries statements without minimums.
+var defaultTopFilter = topFilter{limit: DefaultTopQueries}
+
+// conditions renders the minimums on the calls and mean expressions, joined
+// by "and"; empty without minimums.
+func (f topFilter) conditions(calls, mean string) string {
+	var conds []string
+	if f.minCalls > 0 {
+		conds = append(conds, fmt.Sprintf("%s >= %g", calls, f.minCalls))
+	}
+	if f.minMeanMs > 0 {
+		conds = append(conds, fmt.Sprintf("%s >= %g", mean, f.minMeanMs))
+	}
+	return strings.Join(conds, " and ")
+}
+
+// pssQuery builds the top statements query for one ordering. colTotal/colMean
+// are total_exec_time/mean_exec_time on PostgreSQL 13+ and total_time/mean_time
+// before; the min, max and stddev columns follow the same naming.
+func pssQuery(schema, colTotal, colMean string, ord pssOrder, includeIO bool, includeBlk bool, f topFilter) string {
+	orderExpr := ""
+	switch ord {
+	case orderByTotal:
+		orderExpr = colTotal
+	case orderByCPUApprox:
+		if includeIO {
+			orderExpr = fmt.Sprintf("(%s - blk_read_time - blk_write_time)", colTotal)
+		} else {
+			orderExpr = colTotal
+		}
+	case orderByIO:
+		if includeIO {
+			orderExpr = "(blk_read_time + blk_write_time)"
+		} else {
+			orderExpr = colTotal
+		}
+	case orderByCalls:
+		orderExpr = "calls"
+	case orderByIOBlocks:
+		if includeBlk {
+			orderExpr = "(coalesce(shared_blks_read,0)+coalesce(shared_blks_written,0)+coalesce(local_blks_read,0)+coalesce(local_blks_written,0)+coalesce(temp_blks_read,0)+coalesce(temp_blks_written,0))"
+		} else {
+			orderExpr = colTotal
+		}
+	}
+	selectIO := ""
+	if includeIO {
+		selectIO = ", blk_read_time, blk_write_time"
+	}
+	selectBlk := ""
+	if includeBlk {
+		selectBlk = ", shared_blks_read, shared_blks_written, local_blks_read, local_blks_written, temp_blks_read, temp_blks_written"
+	}
+	spread := fmt.Sprintf("%s as min_time, %s as max_time, %s as stddev_time",
+		strings.Replace(colMean, "mean", "min", 1), strings.Replace(colMean, "mean", "max", 1), strings.Replace(colMean, "mean", "stddev", 1))
+	where := ""
+	if conds := f.conditions("calls", colMean); conds != "" {
+		where = " where " + conds
+	}
+	return fmt.Sprintf(`select coalesce(queryid, 0), coalesce(query, ''), calls, %s as total_time, %s as mean_time, %s, rows%s%s from %s%s order by %s desc nulls last limit %d`, colTotal, colMean, spread, selectIO, selectBlk, qualifiedPSS(schema), where, orderExpr, f.limit)
+}
+
+// pssLoadQuery builds the load attribution query for a role or database
+// grouping over rel, pg_stat_statements or the pg_stat_monitor buckets read.
+func pssLoadQuery(rel, colTotal string, group pssGroup) string {
+	nameExpr, join := "coalesce(r.rolname, s.userid::text)", "left join pg_roles r on r.oid = s.userid"
+	if group == pssGroupByDatabase {
+		nameExpr, join = "coalesce(d.datname, s.dbid::text)", "left join pg_database d on d.oid = s.dbid"
+	}
+	return fmt.Sprintf(`select %s as name, sum(s.calls)::float8, sum(s.%s)::float8,
+		coalesce(sum(s.%s) / nullif(sum(sum(s.%s)) over (), 0), 0)::float8
+		from %s s %s group by 1 order by 3 desc nulls last limit 20`,
+		nameExpr, colTotal, colTotal, colTotal, rel, join)
+}
+
+// pssAppLoadQuery builds the query grouping statements of rel by the
+// application tag of their leading comment: the first of appTagKeys present,
+// extracted before grouping so other tags do not split the groups.
+func pssAppLoadQuery(rel, colTotal string) string {
+	tags := make([]string, len(appTagKeys))
+	for i, k := range appTagKeys {
+		tags[i] = "substring(c from '" + strings.ReplaceAll(appTagPattern(k), "'", "''") + "')"
+	}
+	return fmt.Sprintf(`with s as (select query, calls, %s as total from %s q),
+		a as (select coalesce(%s) as app, calls, total
+			from (select substring(query from '/\*(.*?)\*/') as c, calls, total from s where query like '%%/*%%*/%%') t)
+		select app, sum(calls)::float8, sum(total)::float8,
+			coalesce(sum(total) / nullif((select sum(total) from s), 0), 0)::float8
+		from a where app is not null group by 1 order by 3 desc nulls last limit 500`,
+		colTotal, rel, strings.Join(tags, ", "))
+}
+
+// pg_stat_monitor (2.0+): one row per statement, client and time bucket,
+// with the pg_stat_statements column names of PostgreSQL 13
+const (
+	sqlPGSMSchema = `select n.nspname from pg_class c join pg_namespace n on n.oid=c.relnamespace where c.relname='pg_stat_monitor' limit 1`
+
+	sqlPGSMIOCols = `select exists(
+		select 1 from information_schema.columns
+		where table_schema=$1 and table_name='pg_stat_monitor' and column_name in ('blk_read_time','blk_write_time')
+		group by table_schema, table_name having count(*)=2)`
+	sqlPGSMBlockCols = `select exists(
+		select 1 from information_schema.columns
+		where table_schema=$1 and table_name='pg_stat_monitor' and column_name in ('shared_blks_read','shared_blks_written','local_blks_read','local_blks_written','temp_blks_read','temp_blks_written')
+		group by table_schema, table_name having count(*)=6)`
+)
+
+// sqlPGSMQueryMaxLen reads the length pg_stat_monitor cuts query texts at.
+const sqlPGSMQueryMaxLen = `select coalesce(current_setting('pg_stat_monitor.pgsm_query_max_len', true), '')`
+
+// pgsmMaxClients caps the client addresses kept per statement.
+const pgsmMaxClients = 10
+
+// pgsmQuery builds the top statements query over rel, the pg_stat_monitor
+// buckets read, for one ordering. Rows are summed per queryid; the mean is
+// recomputed from the sums and the standard deviation pooled across rows.
+func pgsmQuery(rel string, ord pssOrder, includeIO bool, includeBlk bool, f topFilter) string {
+	orderExpr := "total_time"
+	switch ord {
+	case orderByCPUApprox:
+		if includeIO {
+			orderExpr = "sum(total_exec_time - blk_read_time - blk_write_time)"
+		}
+	case orderByIO:
+		if includeIO {
+			orderExpr = "sum(blk_read_time + blk_write_time)"
+		}
+	case orderByCalls:
+		orderExpr = "calls"
+	case orderByIOBlocks:
+		if includeBlk {
+			orderExpr = "sum(coalesce(shared_blks_read,0)+coalesce(shared_blks_written,0)+coalesce(local_blks_read,0)+coalesce(local_blks_written,0)+coalesce(temp_blks_read,0)+coalesce(temp_blks_written,0))"
+		}
+	}
+	selectIO := ""
+	if includeIO {
+		selectIO = ", sum(blk_read_time)::float8, sum(blk_write_time)::float8"
+	}
+	selectBlk := ""
+	if includeBlk {
+		selectBlk = ", sum(shared_blks_read)::float8, sum(shared_blks_written)::float8, sum(local_blks_read)::float8, sum(local_blks_written)::float8, sum(temp_blks_read)::float8, sum(temp_blks_written)::float8"
+	}
+	mean := "(sum(total_exec_time) / nullif(sum(calls), 0))"
+	having := ""
+	if conds := f.conditions("sum(calls)", mean); conds != "" {
+		having = " having " + conds
+	}
+	return fmt.Sprintf(`select coalesce(queryid, 0), coalesce(min(query), ''), sum(calls)::float8 as calls, sum(total_exec_time)::float8 as total_time,
+		coalesce(%s, 0)::float8 as mean_time, coalesce(min(min_exec_time), 0)::float8 as min_time, coalesce(max(max_exec_time), 0)::float8 as max_time,
+		coalesce(sqrt(greatest(sum(calls * (stddev_exec_time^2 + mean_exec_time^2)) / nullif(sum(calls), 0) - %s^2, 0)), 0)::float8 as stddev_time,
+		sum(rows)::float8%s%s,
+		coalesce((array_agg(distinct host(client_ip)) filter (where client_ip is not null))[1:%d], '{}'),
+		coalesce(array_agg(distinct comments) filter (where comments <> ''), '{}')
+		from %s group by coalesce(queryid, 0)%s order by %s desc nulls last limit %d`,
+		mean, mean, selectIO, selectBlk, pgsmMaxClients, rel, having, orderExpr, f.limit)
+}
+
+// pgsmProbeQuery fails when pg_stat_monitor is not readable, e.g. when its
+// library is not preloaded.
+func pgsmProbeQuery(schema string) string {
+	return fmt.Sprintf(`select 1 from %s limit 1`, pgsmRelation(schema, 0))
+}
+
+// pgsmStartQuery reads the start of the oldest pg_stat_monitor bucket of rel.
+func pgsmStartQuery(rel string) string {
+	return fmt.Sprintf(`select min(bucket_start_time)::timestamptz from %s`, rel)
+}
+
+// pgsmRangeQuery reads the bounds of the response time histogram buckets.
+func pgsmRangeQuery(schema string) string {
+	if schema == "" {
+		return `select range()`
+	}
+	return fmt.Sprintf(`select %s.range()`, quoteIdent(schema))
+}
+
+// pgsmHistogramQuery reads the response time histograms of the statements
+// with the queryids in $1, one row per pg_stat_monitor row of rel.
+func pgsmHistogramQuery(rel string) string {
+	return fmt.Sprintf(`select queryid, resp_calls::text[] from %s where queryid = any($1)`, rel)
+}
+
+// EXPLAIN advice; <query> is the text of a top SELECT/WITH statement.
+const (
+	sqlPlanPrepare    = `PREPARE __pghealth_prep_<n> AS <query>`
+	sqlPlanExecute    = `EXPLAIN EXECUTE __pghealth_prep_<n>(NULL, ...)`
+	sqlPlanDeallocate = `DEALLOCATE __pghealth_prep_<n>`
+	sqlPlanExplain    = `EXPLAIN <query>`
+)
+
+// connections
+const (
+	sqlTotalConnections = `select count(*) from pg_stat_activity`
+	sqlConnsByClient    = `select
+		coalesce(host(client_addr), 'local') as client_addr,
+		coalesce(usename, '') as usename,
+		coalesce(application_name, '') as application_name,
+		count(*) as cnt,
+		count(*) filter (where state = 'idle') as idle,
+		coalesce(avg(extract(epoch from now() - backend_start)), 0)::float8 as avg_age
+	from pg_stat_activity
+	where usename is not null
+	group by 1, 2, 3
+	order by cnt desc`
+	// sqlRoleIdle measures the idle times of client backends by role and
+	// application, with the idle timeouts applying to each role: its own
+	// cluster-wide setting, else the server's
+	sqlRoleIdle = `select a.usename, coalesce(a.application_name, ''), count(*),
+		count(*) filter (where a.state = 'idle'),
+		coalesce(percentile_cont(0.5) within group (order by extract(epoch from now() - a.state_change))
+			filter (where a.state = 'idle'), 0)::float8,
+		coalesce(max(extract(epoch from now() - a.state_change)) filter (where a.state = 'idle'), 0)::float8,
+		count(*) filter (where a.state like 'idle in transaction%'),
+		coalesce(max(extract(epoch from now() - a.state_change)) filter (where a.state like 'idle in transaction%'), 0)::float8,
+		coalesce(max(cfg.iit), nullif(current_setting('idle_in_transaction_session_timeout'), '0'), ''),
+		coalesce(max(cfg.ist), nullif(current_setting('idle_session_timeout', true), '0'), ''),
+		current_setting('idle_session_timeout', true) is not null
+	from pg_stat_activity a
+	join pg_roles r on r.rolname = a.usename
+	left join lateral (select
+			max(split_part(c, '=', 2)) filter (where c like 'idle_in_transaction_session_timeout=%') iit,
+			max(split_part(c, '=', 2)) filter (where c like 'idle_session_timeout=%') ist
+		from pg_db_role_setting s cross join unnest(s.setconfig) c
+		where s.setrole = r.oid and s.setdatabase = 0) cfg on true
+	where a.usename is not null and a.pid <> pg_backend_pid()
+	group by 1, 2
+	order by 3 desc`
+	// session counters are new in PostgreSQL 14; read through to_jsonb so older
+	// servers return no rows rather than an error
+	sqlSessionStats = `select d.datname,
+		(j->>'sessions')::bigint, (j->>'session_time')::float8, (j->>'active_time')::float8,
+		(j->>'idle_in_transaction_time')::float8, (j->>'sessions_abandoned')::bigint,
+		(j->>'sessions_fatal')::bigint, (j->>'sessions_killed')::bigint,
+		extract(epoch from now() - coalesce(d.stats_reset, pg_postmaster_start_time()))::float8
+	from pg_stat_database d cross join lateral to_jsonb(d) j
+	where d.datname is not null and j ? 'sessions'
+	order by 2 desc`
+)
+
+// cache hit
+const (
+	sqlCacheHitCurrent = `select coalesce(blks_hit,0), coalesce(blks_read,0) from pg_stat_database where datname=current_database()`
+	sqlCacheHitOverall = `select coalesce(sum(blks_hit),0), coalesce(sum(blks_read),0) from pg_stat_database`
+	sqlCacheHitByDB    = `select datname, blks_hit, blks_read,
+		coalesce(round(100.0 * blks_hit / nullif(blks_hit + blks_read, 0), 2), 0.0) as cache_hit_ratio
+	from pg_stat_database
+	order by cache_hit_ratio asc`
+)
+
+const sqlBlocking = `select a.datname, a.pid as blocked_pid, (now()-a.query_start)::text as blocked_for, a.query as blocked_query,
+	b.pid as blocking_pid, (now()-b.query_start)::text as blocking_for, b.query as blocking_query
+	from pg_stat_activity a
+	join lateral unnest(pg_blocking_pids(a.pid)) as blocked_by(pid) on true
+	join pg_stat_activity b on b.pid = blocked_by.pid
+	order by (now()-a.query_start) desc limit 20`
+
+const sqlLongRunning = `select datname, pid, (now()-query_start)::text as duration, state, query
+	from pg_stat_activity where state='active' and now()-query_start > interval '5 minutes'
+	order by (now()-query_start) desc limit 20`
+
+// sqlAutovacuum reads the dead tuple counters through to_jsonb: PostgreSQL 17
+// replaced max_dead_tuples and num_dead_tuples with max_dead_tuple_bytes,
+// dead_tuple_bytes and num_dead_item_ids
+const sqlAutovacuum = `select a.datname, p.pid, p.relid::regclass::text as relation, p.phase,
+	p.heap_blks_scanned, p.heap_blks_total, coalesce(a.backend_type = 'autovacuum worker', false),
+	p.index_vacuum_count,
+	coalesce((to_jsonb(p)->>'max_dead_tuples')::bigint, 0),
+	coalesce((to_jsonb(p)->>'num_dead_tuples')::bigint, (to_jsonb(p)->>'num_dead_item_ids')::bigint, 0),
+	coalesce((to_jsonb(p)->>'max_dead_tuple_bytes')::bigint, 0),
+	coalesce((to_jsonb(p)->>'dead_tuple_bytes')::bigint, 0)
+	from pg_stat_progress_vacuum p
+	join pg_stat_activity a on a.pid = p.pid
+	order by a.datname, relation`
+
+// index usage
+const (
+	sqlIndexUsageLow = `select schemaname, relname,
+			coalesce(100.0 * idx_scan / nullif(seq_scan + idx_scan, 0), 0.0) as index_usage_pct,
+			n_live_tup
+		  from pg_stat_user_tables
+		  where n_live_tup > 10000
+		  order by index_usage_pct asc nulls last
+		  limit 50`
+	sqlIndexUsageLowAll = `select schemaname, relname,
+			coalesce(100.0 * idx_scan / nullif(seq_scan + idx_scan, 0), 0.0) as index_usage_pct,
+			n_live_tup
+		  from pg_stat_all_tables
+		  where schemaname not in ('pg_catalog','information_schema') and n_live_tup > 10000
+		  order by index_usage_pct asc nulls last
+		  limit 50`
+)
+
+const sqlTableIndexCounts = `select t.schemaname, t.relname,
+		count(i.indexrelid) as index_count,
+		pg_total_relation_size(format('%I.%I', t.schemaname, t.relname)) as size_bytes,
+		t.n_live_tup,
+		t.n_dead_tup,
+		coalesce(100.0 * t.n_dead_tup / nullif(t.n_live_tup + t.n_dead_tup, 0), 0.0) as bloat_pct
+	from pg_stat_user_tables t
+	left join pg_stat_user_indexes i on i.schemaname = t.schemaname and i.relname = t.relname
+	group by t.schemaname, t.relname, t.n_live_tup, t.n_dead_tup
+	order by size_bytes desc
+	limit 100`
+
+const sqlTableBloat = `select schemaname, relname,
+		coalesce(100.0 * n_dead_tup / nullif(n_live_tup + n_dead_tup, 0), 0.0) as bloat_pct,
+		pg_total_relation_size(format('%I.%I', schemaname, relname)) *
+		coalesce(n_dead_tup::float8 / nullif(n_live_tup + n_dead_tup, 0), 0.0) as wasted_bytes,
+		last_vacuum, last_analyze
+	from pg_stat_user_tables
+	where n_live_tup + n_dead_tup > 10000
+	order by wasted_bytes desc
+	limit 50`
+
+const sqlIndexBloat = `select s.schemaname, s.relname, s.indexrelname,
+		0.0 as estimated_bloat, -- Placeholder for actual bloat calculation
+		pg_relation_size(s.indexrelid) as size_bytes,
+		s.idx_scan
+	from pg_stat_user_indexes s
+	where pg_relation_size(s.indexrelid) > 10485760 -- > 10MB
+	order by size_bytes desc
+	limit 50`
+
+const sqlReplication = `select application_name, state, sync_state, sync_priority,
+		coalesce(write_lag::text, '00:00:00') as write_lag,
+		coalesce(flush_lag::text, '00:00:00') as flush_lag,
+		coalesce(replay_lag::text, '00:00:00') as replay_lag
+	from pg_stat_replication
+	order by sync_priority desc`
+
+// standby conflicts (counters are only maintained on standbys)
+const (
+	sqlStandbyConflicts = `select datname, confl_tablespace, confl_lock, confl_snapshot, confl_bufferpin, confl_deadlock
+	from pg_stat_database_conflicts
+	where datname is not null
+	order by confl_tablespace + confl_lock + confl_snapshot + confl_bufferpin + confl_deadlock desc, datname`
+	sqlStandbySettings = `select name, setting, coalesce(unit, ''), source from pg_settings where name in (
+	'max_standby_streaming_delay','max_standby_archive_delay','hot_standby_feedback','vacuum_defer_cleanup_age') order by name`
+)
+
+const sqlWaitEvents = `select coalesce(wait_event_type,'none') as type, coalesce(wait_event,'none') as event, count(*)
+	from pg_stat_activity
+	where wait_event is not null
+	group by 1,2
+	order by 3 desc
+	limit 20`
+
+const sqlFunctions = `select schemaname, funcname, calls, coalesce(total_time,0), coalesce(self_time,0)
+	from pg_stat_user_functions
+	order by total_time desc nulls last limit 20`
+
+// WAL
+const (
+	sqlHasStatWAL = `select exists(select 1 from pg_catalog.pg_class c join pg_catalog.pg_namespace n on n.oid=c.relnamespace where n.nspname='pg_catalog' and c.relname='pg_stat_wal')`
+	sqlStatWAL    = `select wal_records, wal_fpi, wal_bytes, stats_reset from pg_stat_wal`
+
+	// sqlWALArchiver reads the archiving setup and pg_stat_archiver;
+	// wal_segment_size is reported in 8kB units before PostgreSQL 11
+	sqlWALArchiver = `select current_setting('archive_mode'), current_setting('archive_command'),
+		coalesce(current_setting('archive_library', true), ''),
+		(select setting::bigint * case unit when '8kB' then 8192 else 1 end from pg_settings where name = 'wal_segment_size'),
+		archived_count, coalesce(last_archived_wal, ''), last_archived_time,
+		failed_count, coalesce(last_failed_wal, ''), last_failed_time
+	from pg_stat_archiver`
+	sqlCurrentWALFile = `select pg_walfile_name(pg_current_wal_lsn())`
+)
+
+// subtransactions
+const (
+	// The SLRU is named Subtrans before PostgreSQL 17 and Subtransaction since
+	sqlSubtransSLRU = `select blks_hit, blks_read, blks_zeroed, coalesce(stats_reset, pg_postmaster_start_time())
+	from pg_stat_slru where lower(name) in ('subtrans', 'subtransaction')`
+	// PostgreSQL 16+: per-backend subtransaction counts of the local backend status table
+	sqlSubxactBackends = `select a.pid, coalesce(a.datname, ''), coalesce(a.usename, ''), coalesce(a.application_name, ''),
+		s.subxact_count, s.subxact_overflowed, coalesce(left(a.query, 500), '')
+	from pg_stat_get_backend_idset() as b(id)
+	cross join lateral pg_stat_get_backend_subxact(b.id) as s
+	join pg_stat_activity a on a.pid = pg_stat_get_backend_pid(b.id)
+	where s.subxact_count > 0
+	order by s.subxact_overflowed desc, s.subxact_count desc
+	limit 20`
+)
+
+// vacuum horizon: xmin held back by standbys (hot_standby_feedback), replication
+// slots and, for comparison, the oldest local snapshot
+const (
+	sqlXminHolders = `select kind, name, active, xmin_age, catalog_xmin_age from (
+		select 'standby' as kind, coalesce(nullif(application_name, ''), host(client_addr), pid::text) as name, true as active,
+			age(backend_xmin)::bigint as xmin_age, 0::bigint as catalog_xmin_age
+		from pg_stat_replication
+		where backend_xmin is not null
+		union all
+		select 'slot', slot_name::text, active, coalesce(age(xmin), 0)::bigint, coalesce(age(catalog_xmin), 0)::bigint
+		from pg_replication_slots
+		where xmin is not null or catalog_xmin is not null
+		union all
+		(select 'session', pid::text || coalesce(' (' || nullif(application_name, '') || ')', ''), true, age(backend_xmin)::bigint, 0::bigint
+		from pg_stat_activity
+		where backend_xmin is not null and pid <> pg_backend_pid()
+		order by age(backend_xmin) desc
+		limit 1)
+	) h
+	order by greatest(xmin_age, catalog_xmin_age) desc`
+	sqlHorizonSettings = `select name, setting, coalesce(unit, ''), source from pg_settings where name in (
+	'hot_standby_feedback','vacuum_defer_cleanup_age') order by name`
+)
+
+// sqlReplicationSlots reads the columns added after PostgreSQL 10 through
+// to_jsonb so one statement serves every version; retained WAL is measured
+// from the replay position on a standby
+const sqlReplicationSlots = `select s.slot_name::text, s.slot_type, coalesce(s.plugin::text, ''), coalesce(s.database::text, ''),
+		s.active, s.temporary, coalesce(to_jsonb(s)->>'wal_status', ''),
+		coalesce(pg_wal_lsn_diff(case when pg_is_in_recovery() then pg_last_wal_replay_lsn() else pg_current_wal_lsn() end, s.restart_lsn), 0)::bigint,
+		coalesce((to_jsonb(s)->>'safe_wal_size')::bigint, -1),
+		coalesce(extract(epoch from now() - (to_jsonb(s)->>'inactive_since')::timestamptz), -1)::float8,
+		coalesce(to_jsonb(s)->>'invalidation_reason', case when (to_jsonb(s)->>'conflicting')::boolean then 'conflict' end, ''),
+		coalesce((to_jsonb(s)->>'synced')::boolean, false)
+	from pg_replication_slots s
+	order by 8 desc, 1`
+
+// HA tooling metadata: repmgr and the pg_auto_failover monitor keep it in
+// the database they manage; the queries fail quietly elsewhere
+const (
+	// sqlRepmgrNodes lists repmgr node registrations with the age of the
+	// latest repmgrd monitoring sample (-1 without monitoring_history)
+	sqlRepmgrNodes = `select n.node_id, n.node_name, n.type, n.active, coalesce(u.node_name, ''), n.priority,
+		coalesce(n.location, ''),
+		coalesce((select extract(epoch from now() - max(m.last_monitor_time))
+			from repmgr.monitoring_history m where m.standby_node_id = n.node_id), -1)::float8
+	from repmgr.nodes n
+	left join repmgr.nodes u on u.node_id = n.upstream_node_id
+	order by n.node_id`
+	sqlAutoFailoverNodes = `select nodeid, nodename, nodehost, nodeport, formationid, groupid,
+		goalstate::text, reportedstate::text, reportedpgisrunning, health,
+		extract(epoch from now() - reporttime)::float8, candidatepriority, replicationquorum
+	from pgautofailover.node
+	order by formationid, groupid, nodeid`
+)
+
+// progress
+const (
+	sqlProgressCreateIndex = `select a.datname, p.relid::regclass::text as relation, p.phase,
+	coalesce(p.blocks_done,0), coalesce(p.blocks_total,0), coalesce(p.tuples_done,0), coalesce(p.tuples_total,0),
+	coalesce(p.lockers_done,0), coalesce(p.lockers_total,0)
+	from pg_stat_progress_create_index p join pg_stat_activity a on a.pid=p.pid
+	order by a.datname, relation`
+	sqlProgressAnalyze = `select a.datname, p.relid::regclass::text as relation, p.phase,
+	coalesce(p.sample_blks_scanned,0), coalesce(p.sample_blks_total,0)
+	from pg_stat_progress_analyze p join pg_stat_activity a on a.pid=p.pid
+	order by a.datname, relation`
+	// sqlProgressCopy needs PostgreSQL 14+; a COPY of a query has no relid
+	sqlProgressCopy = `select coalesce(a.datname, ''), p.pid, coalesce(p.relid::regclass::text, ''), p.command, p.type,
+	p.bytes_processed, p.bytes_total, p.tuples_processed, p.tuples_excluded,
+	coalesce(extract(epoch from now() - a.query_start), 0)::float8
+	from pg_stat_progress_copy p join pg_stat_activity a on a.pid=p.pid
+	order by a.query_start`
+	// sqlProgressCluster needs PostgreSQL 12+
+	sqlProgressCluster = `select coalesce(a.datname, ''), p.pid, p.relid::regclass::text, p.command, p.phase,
+	p.heap_blks_scanned, p.heap_blks_total, p.heap_tuples_written, p.index_rebuild_count,
+	coalesce(extract(epoch from now() - a.query_start), 0)::float8
+	from pg_stat_progress_cluster p join pg_stat_activity a on a.pid=p.pid
+	order by a.query_start`
+	// sqlProgressBasebackup needs PostgreSQL 13+; backup_total is null
+	// without a size estimate
+	sqlProgressBasebackup = `select p.pid, coalesce(nullif(a.application_name, ''), host(a.client_addr), ''), p.phase,
+	p.backup_streamed, coalesce(p.backup_total, 0), p.tablespaces_streamed, p.tablespaces_total,
+	coalesce(extract(epoch from now() - a.backend_start), 0)::float8
+	from pg_stat_progress_basebackup p join pg_stat_activity a on a.pid=p.pid
+	order by a.backend_start`
+)
+
+const sqlCheckpoints = `select checkpoints_req, checkpoints_timed,
+		checkpoint_write_time, checkpoint_sync_time,
+		buffers_checkpoint, buffers_clean
+	from pg_stat_bgwriter`
+
+// memory
+const (
+	sqlBgwriterBuffers = `select buffers_alloc, buffers_checkpoint + buffers_clean + buffers_backend
+	from pg_stat_bgwriter`
+	sqlBlockSize      = `select current_setting('block_size')`
+	sqlSharedBuffers  = `select setting::bigint from pg_settings where name='shared_buffers'`
+	sqlHasBuffercache = `select exists(select 1 from pg_extension where extname='pg_buffercache')`
+	sqlBuffercache    = `select count(*) from pg_buffercache`
+	sqlTempFilesDB    = `select coalesce(temp_files,0), coalesce(temp_bytes,0) from pg_stat_database where datname=current_database()`
+)
+
+const sqlIOStats = `select heap_blks_read, heap_blks_hit, idx_blks_read, idx_blks_hit,
+		toast_blks_read, toast_blks_hit, tidx_blks_read, tidx_blks_hit,
+		coalesce(blk_read_time, 0), coalesce(blk_write_time, 0)
+	from pg_stat_database
+	where datname = current_database()`
+
+const sqlLocks = `select locktype, mode, granted, count(*) as count,
+		array_agg(pid) as waiting_pids
+	from pg_locks
+	where not granted
+	group by locktype, mode, granted
+	order by count desc
+	limit 20`
+
+// sqlLockHotspots aggregates the current database's relation and tuple locks
+// per table. Tuple locks only appear while sessions queue for a row, so they
+// count hot rows; waiters are correlated with pg_stat_activity for the
+// longest wait and a sample of the waiting statements.
+const sqlLockHotspots = `select n.nspname, c.relname,
+		count(*) filter (where l.granted) as granted,
+		count(*) filter (where not l.granted) as waiting,
+		count(*) filter (where l.locktype = 'tuple') as tuple_locks,
+		count(distinct (l.page, l.tuple)) filter (where l.locktype = 'tuple') as hot_rows,
+		count(*) filter (where l.locktype = 'relation' and l.granted
+			and l.mode in ('ShareLock', 'ShareRowExclusiveLock', 'ExclusiveLock', 'AccessExclusiveLock')) as strong_locks,
+		coalesce(extract(epoch from max(now() - a.state_change) filter (where not l.granted)), 0)::float8 as longest_wait_secs,
+		coalesce((array_agg(left(a.query, 500) order by a.state_change) filter (where not l.granted and a.query is not null))[1], '') as sample_query
+	from pg_locks l
+	join pg_class c on c.oid = l.relation
+	join pg_namespace n on n.oid = c.relnamespace
+	left join pg_stat_activity a on a.pid = l.pid
+	where l.database = (select oid from pg_database where datname = current_database())
+	  and l.pid <> pg_backend_pid()
+	  and n.nspname not in ('pg_catalog', 'information_schema')
+	group by n.nspname, c.relname
+	having count(*) filter (where not l.granted) > 0 or count(*) filter (where l.locktype = 'tuple') > 0
+	order by waiting desc, tuple_locks desc
+	limit 20`
+
+const sqlTempFiles = `select datname, pid, temp_files, temp_bytes
+	from pg_stat_activity
+	where temp_files > 0 or temp_bytes > 0
+	order by temp_bytes desc
+	limit 20`
+
+const sqlExtensions = `select e.extname, e.extversion, obj_description(e.oid, 'pg_extension'),
+		n.nspname
+	from pg_extension e
+	left join pg_namespace n on n.oid = e.extnamespace
+	order by e.extname`
+
+const sqlXIDAge = `SELECT datname,
+		age(datfrozenxid) as xid_age,
+		datfrozenxid::text::bigint as frozen_xid,
+		datminmxid::text::bigint as min_mxid,
+		mxid_age(datminmxid) as mxid_age
+	FROM pg_database
+	WHERE datallowconn
+	ORDER BY age(datfrozenxid) DESC`
+
+// anti-wraparound vacuum forecast: the next XID (read through a snapshot, so
+// no XID is assigned) and the tables closest to their freeze age limit
+const (
+	sqlNextXID = `select txid_snapshot_xmax(txid_current_snapshot()), clock_timestamp()`
+	// The effective limit is the lower of the server setting and a table's
+	// autovacuum_freeze_max_age storage parameter; TOAST tables count with their table.
+	sqlFreezeTables = `select n.nspname, c.relname,
+		greatest(age(c.relfrozenxid), coalesce(age(t.relfrozenxid), 0))::bigint as xid_age,
+		least(current_setting('autovacuum_freeze_max_age')::bigint,
+			coalesce((select o.option_value::bigint from pg_options_to_table(c.reloptions) o
+				where o.option_name = 'autovacuum_freeze_max_age'), current_setting('autovacuum_freeze_max_age')::bigint)) as freeze_max_age,
+		pg_total_relation_size(c.oid) as size_bytes
+	from pg_class c
+	join pg_namespace n on n.oid = c.relnamespace
+	left join pg_class t on t.oid = c.reltoastrelid
+	where c.relkind in ('r', 'm') and n.nspname not in ('pg_catalog', 'information_schema')
+	order by xid_age::float8 / freeze_max_age desc, size_bytes desc
+	limit 20`
+)
+
+const sqlIdleInTransaction = `SELECT datname, pid, usename, application_name,
+		(now() - state_change)::text as duration,
+		left(query, 200) as query,
+		coalesce(wait_event, '') as wait_event
+	FROM pg_stat_activity
+	WHERE state = 'idle in transaction'
+	  AND (now() - state_change) > interval '5 minutes'
+	ORDER BY (now() - state_change) DESC
+	LIMIT 20`
+
+const sqlStaleStats = `SELECT schemaname, relname,
+		n_live_tup as row_estimate,
+		last_analyze,
+		last_autoanalyze,
+		n_mod_since_analyze as mods_since_analyze,
+		COALESCE(
+			EXTRACT(epoch FROM (now() - COALESCE(last_analyze, last_autoanalyze)))::int / 86400,
+			999
+		) as days_since_analyze
+	FROM pg_stat_user_tables
+	WHERE n_live_tup > 1000
+	  AND (last_analyze IS NULL AND last_autoanalyze IS NULL
+	       OR COALESCE(last_analyze, last_autoanalyze) < now() - interval '7 days')
+	ORDER BY n_live_tup DESC
+	LIMIT 50`
+
+// sqlRetentionTables lists the tables of at least $2 bytes with a
+// timestamp or date column named in $1 or following the physical row order
+// (correlation of 0.9 and up), with the histogram of the column and the write
+// counters of the table.
+const sqlRetentionTables = `SELECT DISTINCT ON (n.nspname, c.relname, a.attname)
+		n.nspname, c.relname, COALESCE(p.relname, ''), a.attname, a.attname = ANY($1),
+		COALESCE(s.correlation, 0)::float8,
+		pg_total_relation_size(c.oid), GREATEST(c.reltuples, 0)::bigint,
+		COALESCE(t.n_tup_ins, 0), COALESCE(t.n_tup_upd, 0), COALESCE(t.n_tup_del, 0),
+		s.histogram_bounds::text::timestamptz[], now()
+	FROM pg_class c
+	JOIN pg_namespace n ON n.oid = c.relnamespace
+	JOIN pg_attribute a ON a.attrelid = c.oid AND a.attnum > 0 AND NOT a.attisdropped
+	JOIN pg_stats s ON s.schemaname = n.nspname AND s.tablename = c.relname AND s.attname = a.attname
+	LEFT JOIN pg_inherits i ON i.inhrelid = c.oid
+	LEFT JOIN pg_class p ON p.oid = i.inhparent AND p.relkind = 'p'
+	LEFT JOIN pg_stat_user_tables t ON t.relid = c.oid
+	WHERE c.relkind = 'r'
+	  AND n.nspname NOT IN ('pg_catalog', 'information_schema')
+	  AND n.nspname NOT LIKE 'pg_toast%'
+	  AND a.atttypid IN ('timestamp'::regtype, 'timestamptz'::regtype, 'date'::regtype)
+	  AND (a.attname = ANY($1) OR s.correlation >= 0.9)
+	  AND s.histogram_bounds IS NOT NULL
+	  AND pg_total_relation_size(c.oid) >= $2
+	ORDER BY n.nspname, c.relname, a.attname, s.inherited`
+
+// sqlClientFingerprints groups the client backends by application name,
+// locality and transport encryption; sqlClientFingerprintsNoGSS serves
+// servers before PostgreSQL 12, which lack pg_stat_gssapi.
+const (
+	sqlClientFingerprints = `select coalesce(a.application_name, ''), a.client_addr is null,
+		coalesce(s.ssl, false), coalesce(s.version, ''), coalesce(s.cipher, ''), coalesce(g.encrypted, false),
+		count(*)
+	from pg_stat_activity a
+	left join pg_stat_ssl s on s.pid = a.pid
+	left join pg_stat_gssapi g on g.pid = a.pid
+	where a.usename is not null and a.pid <> pg_backend_pid()
+	group by 1, 2, 3, 4, 5, 6
+	order by 7 desc`
+	sqlClientFingerprintsNoGSS = `select coalesce(a.application_name, ''), a.client_addr is null,
+		coalesce(s.ssl, false), coalesce(s.version, ''), coalesce(s.cipher, ''), false,
+		count(*)
+	from pg_stat_activity a
+	left join pg_stat_ssl s on s.pid = a.pid
+	where a.usename is not null and a.pid <> pg_backend_pid()
+	group by 1, 2, 3, 4, 5, 6
+	order by 7 desc`
+)
+
+// sqlColumnStats reads the most common value of the columns in $1-$3
+// (schema, table, column), preferring the statistics of the table itself over
+// those including its inheritance children.
+const sqlColumnStats = `SELECT DISTINCT ON (s.schemaname, s.tablename, s.attname)
+		s.schemaname, s.tablename, s.attname,
+		COALESCE((s.most_common_vals::text::text[])[1], ''),
+		COALESCE(s.most_common_freqs[1], 0)::float8,
+		s.null_frac::float8,
+		s.n_distinct::float8
+	FROM pg_stats s
+	JOIN unnest($1::text[], $2::text[], $3::text[]) AS c(schemaname, tablename, attname)
+	  ON c.schemaname = s.schemaname AND c.tablename = s.tablename AND c.attname = s.attname
+	ORDER BY s.schemaname, s.tablename, s.attname, s.inherited`
+
+const sqlDuplicateIndexes = `WITH index_cols AS (
+		SELECT n.nspname as schema,
+			   t.relname as table_name,
+			   i.relname as index_name,
+			   pg_get_indexdef(i.oid) as index_def,
+			   array_to_string(array_agg(a.attname ORDER BY x.n), ', ') as columns,
+			   pg_relation_size(i.oid) as size_bytes,
+			   COALESCE(s.idx_scan, 0) as scans
+		FROM pg_index ix
+		JOIN pg_class i ON i.oid = ix.indexrelid
+		JOIN pg_class t ON t.oid = ix.indrelid
+		JOIN pg_namespace n ON n.oid = t.relnamespace
+		LEFT JOIN pg_stat_user_indexes s ON s.indexrelid = i.oid
+		CROSS JOIN LATERAL unnest(ix.indkey) WITH ORDINALITY AS x(attnum, n)
+		JOIN pg_attribute a ON a.attrelid = t.oid AND a.attnum = x.attnum
+		WHERE n.nspname NOT IN ('pg_catalog', 'information_schema')
+		GROUP BY n.nspname, t.relname, i.relname, i.oid, s.idx_scan
+	)
+	SELECT a.schema, a.table_name, a.index_name, b.index_name,
+		   a.columns, a.size_bytes, b.size_bytes, a.scans, b.scans
+	FROM index_cols a
+	JOIN index_cols b ON a.schema = b.schema
+		AND a.table_name = b.table_name
+		AND a.columns = b.columns
+		AND a.index_name < b.index_name
+	ORDER BY a.size_bytes + b.size_bytes DESC
+	LIMIT 20`
+
+// sqlRedundantIndexes finds btree indexes whose key columns are a leading
+// prefix of a wider index on the same table with the same opclasses,
+// collations and sort options. The narrower index must not enforce anything,
+// have INCLUDE columns, expressions or a predicate; the wider one must not be
+// partial, so it covers every row.
+const sqlRedundantIndexes = `WITH idx AS (
+		SELECT ix.indrelid,
+			   ix.indexrelid,
+			   n.nspname as schema,
+			   t.relname as table_name,
+			   i.relname as index_name,
+			   (string_to_array(ix.indkey::text, ' ')::int[])[1:ix.indnkeyatts] as keys,
+			   (string_to_array(ix.indclass::text, ' ')::oid[])[1:ix.indnkeyatts] as opclasses,
+			   (string_to_array(ix.indcollation::text, ' ')::oid[])[1:ix.indnkeyatts] as collations,
+			   (string_to_array(ix.indoption::text, ' ')::int[])[1:ix.indnkeyatts] as options,
+			   ix.indisunique OR ix.indisprimary OR ix.indisexclusion OR ix.indisreplident as enforces,
+			   ix.indnatts > ix.indnkeyatts as has_include,
+			   ix.indexprs IS NOT NULL as has_exprs,
+			   ix.indpred IS NOT NULL as is_partial,
+			   pg_relation_size(i.oid) as size_bytes,
+			   COALESCE(s.idx_scan, 0) as scans
+		FROM pg_index ix
+		JOIN pg_class i ON i.oid = ix.indexrelid
+		JOIN pg_class t ON t.oid = ix.indrelid
+		JOIN pg_namespace n ON n.oid = t.relnamespace
+		JOIN pg_am am ON am.oid = i.relam AND am.amname = 'btree'
+		LEFT JOIN pg_stat_user_indexes s ON s.indexrelid = i.oid
+		WHERE n.nspname NOT IN ('pg_catalog', 'information_schema')
+		  AND ix.indisvalid
+	), cols AS (
+		SELECT idx.*,
+			   array_to_string(ARRAY(
+				   SELECT a.attname FROM unnest(idx.keys) WITH ORDINALITY AS k(attnum, n)
+				   JOIN pg_attribute a ON a.attrelid = idx.indrelid AND a.attnum = k.attnum
+				   ORDER BY k.n), ', ') as columns
+		FROM idx
+	)
+	SELECT a.schema, a.table_name, a.index_name, a.columns, a.size_bytes, a.scans,
+		   b.index_name, b.columns, b.size_bytes, b.scans
+	FROM cols a
+	JOIN cols b ON b.indrelid = a.indrelid
+		AND b.indexrelid <> a.indexrelid
+		AND cardinality(a.keys) < cardinality(b.keys)
+		AND a.keys = b.keys[1:cardinality(a.keys)]
+		AND a.opclasses = b.opclasses[1:cardinality(a.keys)]
+		AND a.collations = b.collations[1:cardinality(a.keys)]
+		AND a.options = b.options[1:cardinality(a.keys)]
+	WHERE NOT a.enforces AND NOT a.has_include AND NOT a.has_exprs AND NOT a.is_partial
+	  AND NOT b.is_partial
+	ORDER BY a.size_bytes DESC, a.schema, a.index_name, b.index_name
+	LIMIT 20`
+
+const sqlInvalidIndexes = `SELECT n.nspname as schema,
+		t.relname as table_name,
+		i.relname as index_name,
+		pg_relation_size(i.oid) as size_bytes,
+		pg_get_indexdef(i.oid) as ddl,
+		CASE WHEN NOT ix.indisvalid THEN 'invalid'
+			 WHEN NOT ix.indisready THEN 'not ready'
+			 ELSE 'unknown' END as reason
+	FROM pg_index ix
+	JOIN pg_class i ON i.oid = ix.indexrelid
+	JOIN pg_class t ON t.oid = ix.indrelid
+	JOIN pg_namespace n ON n.oid = t.relnamespace
+	WHERE (NOT ix.indisvalid OR NOT ix.indisready)
+	  AND n.nspname NOT IN ('pg_catalog', 'information_schema')
+	ORDER BY pg_relation_size(i.oid) DESC`
+
+const sqlFKMissingIndexes = `WITH fk_columns AS (
+		SELECT c.conname as constraint_name,
+			   n.nspname as schema,
+			   t.relname as table_name,
+			   array_to_string(array_agg(a.attname ORDER BY x.n), ', ') as columns,
+			   t2.relname as ref_table,
+			   array_to_string(array_agg(a2.attname ORDER BY x.n), ', ') as ref_columns,
+			   t.reltuples::bigint as table_rows,
+			   t.oid as table_oid
+		FROM pg_constraint c
+		JOIN pg_class t ON t.oid = c.conrelid
+		JOIN pg_class t2 ON t2.oid = c.confrelid
+		JOIN pg_namespace n ON n.oid = t.relnamespace
+		CROSS JOIN LATERAL unnest(c.conkey, c.confkey) WITH ORDINALITY AS x(attnum, ref_attnum, n)
+		JOIN pg_attribute a ON a.attrelid = t.oid AND a.attnum = x.attnum
+		JOIN pg_attribute a2 ON a2.attrelid = t2.oid AND a2.attnum = x.ref_attnum
+		WHERE c.contype = 'f'
+		  AND n.nspname NOT IN ('pg_catalog', 'information_schema')
+		GROUP BY c.conname, n.nspname, t.relname, t2.relname, t.reltuples, t.oid
+	)
+	SELECT f.schema, f.table_name, f.constraint_name, f.columns, f.ref_table, f.ref_columns, f.table_rows,
+		   'CREATE INDEX ON ' || quote_ident(f.schema) || '.' || quote_ident(f.table_name) ||
+		   ' (' || f.columns || ')' as suggested_ddl
+	FROM fk_columns f
+	WHERE NOT EXISTS (
+		SELECT 1 FROM pg_index ix
+		JOIN pg_class ci ON ci.oid = ix.indexrelid
+		WHERE ix.indrelid = f.table_oid
+		  AND (
+			  -- Check if FK columns are a prefix of index columns
+			  string_to_array(f.columns, ', ') <@ (
+				  SELECT array_agg(a.attname ORDER BY x.n)
+				  FROM unnest(ix.indkey) WITH ORDINALITY AS x(attnum, n)
+				  JOIN pg_attribute a ON a.attrelid = ix.indrelid AND a.attnum = x.attnum
+			  )
+		  )
+	)
+	ORDER BY f.table_rows DESC
+	LIMIT 30`
+
+// sqlSequences uses the pg_sequences view (PG10+).
+const sqlSequences = `SELECT schemaname, sequencename,
+		last_value,
+		max_value,
+		increment_by,
+		CASE WHEN max_value > 0 AND last_value > 0
+			 THEN (last_value::float8 / max_value::float8 * 100)
+			 ELSE 0 END as pct_used,
+		CASE WHEN increment_by > 0
+			 THEN ((max_value - last_value) / increment_by)
+			 ELSE 0 END as calls_left
+	FROM pg_sequences
+	WHERE last_value IS NOT NULL
+	  AND max_value > 0
+	  AND (last_value::float8 / max_value::float8) > 0.5
+	ORDER BY (last_value::float8 / max_value::float8) DESC
+	LIMIT 20`
+
+const sqlPreparedXacts = `SELECT transaction::text, gid, owner, database,
+		prepared,
+		(now() - prepared)::text as age
+	FROM pg_prepared_xacts
+	ORDER BY prepared ASC`
+
+// sqlLoginRoles lists the login roles with their connection limit and their
+// cluster-wide statement_timeout setting; pg_roles is readable by everyone.
+const sqlLoginRoles = `select r.rolname, r.rolsuper, r.rolreplication, r.rolconnlimit,
+		coalesce((select split_part(c, '=', 2)
+			from pg_db_role_setting s cross join unnest(s.setconfig) c
+			where s.setrole = r.oid and s.setdatabase = 0 and c like 'statement_timeout=%'
+			limit 1), '')
+	from pg_roles r
+	where r.rolcanlogin and r.rolname !~ '^pg_'
+	order by r.rolname`
+
+// password hashes are readable by superusers only, from pg_authid or the
+// older pg_shadow view; the hash type is recognized by its prefix
+const (
+	sqlRolePasswords = `select rolname, case
+			when rolpassword is null then 'none'
+			when rolpassword like 'SCRAM-SHA-256$%' then 'scram-sha-256'
+			when rolpassword ~ '^md5[0-9a-f]{32}$' then 'md5'
+			else 'plain' end
+	from pg_authid where rolcanlogin`
+	sqlRolePasswordsShadow = `select usename, case
+			when passwd is null then 'none'
+			when passwd like 'SCRAM-SHA-256$%' then 'scram-sha-256'
+			when passwd ~ '^md5[0-9a-f]{32}$' then 'md5'
+			else 'plain' end
+	from pg_shadow`
+)
+
+// sqlHBAPasswordRules lists the pg_hba.conf lines authenticating with md5 or
+// cleartext passwords; pg_hba_file_rules is readable by superusers only
+// unless granted.
+const sqlHBAPasswordRules = `select line_number, coalesce(type, ''), coalesce(array_to_string(database, ','), ''),
+		coalesce(array_to_string(user_name, ','), ''), coalesce(address, ''), auth_method, ''
+	from pg_hba_file_rules
+	where error is null and auth_method in ('md5', 'password')
+	order by line_number`
+
+// data directory: data_directory is hidden from roles without superuser or
+// pg_read_all_settings, so it comes back empty for them. Setting prefixes
+// and functions identify managed services and TDE modules.
+const sqlDataDirectory = `select coalesce((select setting from pg_settings where name = 'data_directory'), ''),
+		current_setting('data_checksums') = 'on',
+		to_regproc('aurora_version') is not null,
+		coalesce((select setting from pg_settings where name = 'data_encryption_key_unwrap_command'), '') <> '',
+		coalesce((select string_agg(distinct split_part(name, '.', 1), ',') from pg_settings where name like '%.%'), ''),
+	current_setting('shared_preload_libraries')`
+
+// template1 is what CREATE DATABASE copies by default: its locale is the
+// default of new databases and its objects end up in each of them
+const (
+	sqlTemplateLocale = `select pg_encoding_to_char(encoding), datcollate, datctype, datallowconn
+	from pg_database where datname = 'template1'`
+	sqlTemplateObjects = `select case c.relkind when 'r' then 'table' when 'p' then 'table' when 'v' then 'view'
+			when 'm' then 'materialized view' when 'S' then 'sequence' when 'f' then 'foreign table' end,
+			n.nspname || '.' || c.relname
+		from pg_class c join pg_namespace n on n.oid = c.relnamespace
+		where c.relkind in ('r', 'p', 'v', 'm', 'S', 'f')
+			and n.nspname not in ('pg_catalog', 'information_schema') and n.nspname !~ '^pg_toast'
+		union all
+		select 'function', n.nspname || '.' || p.proname
+		from pg_proc p join pg_namespace n on n.oid = p.pronamespace
+		where n.nspname not in ('pg_catalog', 'information_schema')
+			and not exists (select 1 from pg_depend d where d.classid = 'pg_proc'::regclass and d.objid = p.oid and d.deptype = 'e')
+		order by 1, 2`
+)
+
+// sqlDefaultPrivileges lists the ALTER DEFAULT PRIVILEGES entries of the
+// current database; an empty schema applies to every schema.
+const sqlDefaultPrivileges = `select pg_get_userbyid(d.defaclrole), coalesce(n.nspname, ''),
+		case d.defaclobjtype when 'r' then 'tables' when 'S' then 'sequences' when 'f' then 'functions'
+			when 'T' then 'types' when 'n' then 'schemas' else d.defaclobjtype::text end,
+		coalesce(array_to_string(d.defaclacl, ','), '')
+	from pg_default_acl d
+	left join pg_namespace n on n.oid = d.defaclnamespace
+	order by 1, 2, 3`
+
+// delta sampling (-delta): cumulative counters read at the start and the end
+// of the run; the checkpointer counters moved to pg_stat_checkpointer in 17
+const (
+	sqlDeltaDatabases = `select datname, xact_commit, xact_rollback, blks_read, blks_hit,
+		tup_returned, tup_fetched, tup_inserted, tup_updated, tup_deleted,
+		coalesce(temp_bytes, 0), coalesce(deadlocks, 0)
+	from pg_stat_database where datname is not null`
+
+	sqlDeltaBgwriter = `select checkpoints_timed, checkpoints_req, buffers_checkpoint, buffers_clean, buffers_backend, buffers_alloc
+	from pg_stat_bgwriter`
+
+	sqlDeltaCheckpointer = `select c.num_timed, c.num_requested, c.buffers_written, b.buffers_clean, 0::bigint, b.buffers_alloc
+	from pg_stat_checkpointer c, pg_stat_bgwriter b`
+
+	sqlDeltaWAL = `select pg_wal_lsn_diff(case when pg_is_in_recovery() then pg_last_wal_replay_lsn() else pg_current_wal_lsn() end, '0/0')::float8`
+)
+
+// pssDeltaQuery sums the counters of pg_stat_statements per queryid across
+// roles and databases; colTotal is total_exec_time, or total_time before 13.
+func pssDeltaQuery(schema, colTotal string) string {
+	return fmt.Sprintf(`select queryid, min(query), sum(calls)::float8, sum(%s)::float8, sum(rows)::float8,
+		sum(shared_blks_read)::float8, sum(shared_blks_written)::float8, sum(temp_blks_written)::float8
+	from %s where queryid is not null group by queryid`, colTotal, qualifiedPSS(schema))
+}
@@ -0,0 +1,56 @@
+package collect
+
+import "context"
+
+// retentionMinBytes is the smallest table considered for retention: below
+// it, old rows cost too little to be worth a policy.
+const retentionMinBytes = 1 << 30
+
+// DefaultRetentionColumns are the conventional names of a column holding the
+// time a row was created.
+var DefaultRetentionColumns = []string{"created_at", "created", "created_on", "inserted_at", "insert_time", "event_time", "logged_at", "timestamp", "ts"}
+
+// collectRetention finds the large tables of the current database with a row
+// age column, keeping one column per table: a conventional name first, else
+// the one most correlated with the physical row order.
+func collectRetention(ctx context.Context, s *session, res *Result) {
+	names := s.cfg.RetentionColumns
+	if len(names) == 0 {
+		names = DefaultRetentionColumns
+	}
+	rows, err := s.conn.Query(ctx, sqlRetentionTables, names, int64(retentionMinBytes))
+	if err != nil {
+		return
+	}
+	var found []RetentionTable
+	for rows.Next() {
+		r := RetentionTable{Database: res.ConnInfo.CurrentDB}
+		if err := rows.Scan(&r.Schema, &r.Table, &r.Parent, &r.Column, &r.Convention, &r.Correlation, &r.SizeBytes, &r.Rows,
+			&r.Inserts, &r.Updates, &r.Deletes, &r.Bounds, &r.CheckedAt); err != nil {
+			continue
+		}
+		found = append(found, r)
+	}
+	rows.Close()
+	res.RetentionTables = append(res.RetentionTables, retentionColumns(found)...)
+}
+
+// retentionColumns keeps the preferred row age column of each table, in the
+// order the tables first appear.
+func retentionColumns(found []RetentionTable) []RetentionTable {
+	var out []RetentionTable
+	at := map[string]int{}
+	for _, r := range found {
+		k := relationKey(r.Database, r.Schema, r.Table)
+		i, ok := at[k]
+		if !ok {
+			at[k] = len(out)
+			out = append(out, r)
+			continue
+		}
+		if cur := out[i]; (r.Convention && !cur.Convention) || (r.Convention == cur.Convention && r.Correlation > cur.Correlation) {
+			out[i] = r
+		}
+	}
+	return out
+}
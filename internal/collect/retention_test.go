@@ -0,0 +1,46 @@
+package collect
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// TestRetentionOlderThan verifies the share of old rows is interpolated from
+// the equal-frequency histogram buckets.
+func TestRetentionOlderThan(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC).AddDate(0, 0, d) }
+	r := RetentionTable{Bounds: []time.Time{day(0), day(100), day(110), day(120), day(130)}}
+	for _, tt := range []struct {
+		at   time.Time
+		want float64
+	}{
+		{day(-1), 0},
+		{day(0), 0},
+		{day(50), 0.125},
+		{day(100), 0.25},
+		{day(115), 0.625},
+		{day(200), 1},
+	} {
+		if got := r.OlderThan(tt.at); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("OlderThan(%s) = %.3f, expected %.3f", tt.at.Format("2006-01-02"), got, tt.want)
+		}
+	}
+	if got := (RetentionTable{Bounds: []time.Time{day(0)}}).OlderThan(day(10)); got != 0 {
+		t.Errorf("single bound: OlderThan = %.3f, expected 0", got)
+	}
+}
+
+// TestRetentionColumns verifies one column is kept per table: a conventional
+// name over a correlated column, else the most correlated one.
+func TestRetentionColumns(t *testing.T) {
+	got := retentionColumns([]RetentionTable{
+		{Schema: "public", Table: "events", Column: "id_ts", Correlation: 0.99},
+		{Schema: "public", Table: "events", Column: "created_at", Convention: true, Correlation: 0.5},
+		{Schema: "public", Table: "logs", Column: "a", Correlation: 0.91},
+		{Schema: "public", Table: "logs", Column: "b", Correlation: 0.97},
+	})
+	if len(got) != 2 || got[0].Column != "created_at" || got[1].Column != "b" {
+		t.Errorf("retentionColumns() = %+v", got)
+	}
+}
@@ -0,0 +1,106 @@
+package collect
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Retry defaults. Delays grow exponentially from the base up to
+// maxRetryBackoff, with jitter so fleets of scheduled runs hitting the
+// same switchover do not reconnect in lockstep.
+const (
+	// DefaultRetries is the default number of retries after a transient failure.
+	DefaultRetries = 2
+
+	// DefaultRetryBackoff is the default delay before the first retry.
+	DefaultRetryBackoff = 500 * time.Millisecond
+
+	// MaxRetries is the maximum of Config.Retries.
+	MaxRetries = 10
+
+	// maxRetryBackoff caps the delay between two attempts.
+	maxRetryBackoff = 10 * time.Second
+)
+
+// jitter returns a random duration in [0, d); replaced in tests.
+var jitter = func(d time.Duration) time.Duration {
+	if d <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(d)))
+}
+
+// retryable reports whether err is a transient connection failure worth
+// retrying: network errors (DNS, refused or reset connections), connection
+// exceptions and server shutdowns or restarts. Authentication failures,
+// missing databases, permission errors and context expiry are final.
+func retryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch pgErr.Code {
+		case "57P01", // admin_shutdown
+			"57P02", // crash_shutdown
+			"57P03", // cannot_connect_now (starting up, recovering)
+			"53300", // too_many_connections (pooler restart)
+			"40001": // serialization_failure (standby conflict)
+			return true
+		}
+		// Class 08 is connection exception; class 28 (auth) and the rest are final
+		return strings.HasPrefix(pgErr.Code, "08")
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	return errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET)
+}
+
+// backoff is the delay before retry attempt (1-based) with base delay base.
+func backoff(attempt int, base time.Duration) time.Duration {
+	d := base
+	for i := 1; i < attempt && d < maxRetryBackoff; i++ {
+		d *= 2
+	}
+	if d > maxRetryBackoff {
+		d = maxRetryBackoff
+	}
+	return d/2 + jitter(d/2)
+}
+
+// sleepBackoff waits before retry attempt or until ctx is done. It reports
+// whether the retry should proceed.
+func sleepBackoff(ctx context.Context, attempt int, base time.Duration) bool {
+	timer := time.NewTimer(backoff(attempt, base))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// withRetry calls fn until it succeeds, fails with an error that is not
+// retryable, retries are exhausted or ctx is done.
+func withRetry(ctx context.Context, retries int, base time.Duration, fn func() error) error {
+	err := fn()
+	for attempt := 1; attempt <= retries && retryable(err); attempt++ {
+		if !sleepBackoff(ctx, attempt, base) {
+			return err
+		}
+		err = fn()
+	}
+	return err
+}
@@ -0,0 +1,88 @@
+package collect
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// TestRetryable verifies transient failures are retried and auth failures are not.
+func TestRetryable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"refused", &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}, true},
+		{"dns", &net.DNSError{Err: "no such host", Name: "db.internal", IsTemporary: true}, true},
+		{"eof", fmt.Errorf("read: %w", io.EOF), true},
+		{"shutdown", &pgconn.PgError{Code: "57P01"}, true},
+		{"starting up", &pgconn.PgError{Code: "57P03"}, true},
+		{"connection exception", &pgconn.PgError{Code: "08006"}, true},
+		{"bad password", &pgconn.PgError{Code: "28P01"}, false},
+		{"no pg_hba entry", &pgconn.PgError{Code: "28000"}, false},
+		{"missing database", &pgconn.PgError{Code: "3D000"}, false},
+		{"deadline", context.DeadlineExceeded, false},
+		{"other", errors.New("x509: certificate signed by unknown authority"), false},
+	}
+	for _, tt := range tests {
+		if got := retryable(tt.err); got != tt.want {
+			t.Errorf("retryable(%s) = %v, expected %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// TestBackoff verifies delays double per attempt, stay within half to full
+// of the step and are capped.
+func TestBackoff(t *testing.T) {
+	for attempt, step := range map[int]time.Duration{1: 100 * time.Millisecond, 2: 200 * time.Millisecond, 4: 800 * time.Millisecond, 20: maxRetryBackoff} {
+		for i := 0; i < 20; i++ {
+			if d := backoff(attempt, 100*time.Millisecond); d < step/2 || d >= step {
+				t.Errorf("backoff(%d) = %v, expected within [%v, %v)", attempt, d, step/2, step)
+			}
+		}
+	}
+}
+
+// TestWithRetry verifies transient errors are retried up to the limit and
+// final errors stop immediately.
+func TestWithRetry(t *testing.T) {
+	refused := &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}
+	calls := 0
+	err := withRetry(context.Background(), 2, time.Millisecond, func() error {
+		calls++
+		if calls < 3 {
+			return refused
+		}
+		return nil
+	})
+	if err != nil || calls != 3 {
+		t.Errorf("withRetry() = %v after %d calls, expected success after 3", err, calls)
+	}
+
+	calls = 0
+	err = withRetry(context.Background(), 2, time.Millisecond, func() error {
+		calls++
+		return &pgconn.PgError{Code: "28P01"}
+	})
+	if err == nil || calls != 1 {
+		t.Errorf("auth failure retried: %d calls, err %v", calls, err)
+	}
+
+	calls = 0
+	_ = withRetry(context.Background(), 3, time.Millisecond, func() error {
+		calls++
+		return refused
+	})
+	if calls != 4 {
+		t.Errorf("withRetry() made %d calls, expected 4", calls)
+	}
+}
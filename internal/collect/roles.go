@@ -0,0 +1,55 @@
+package collect
+
+import "context"
+
+// collectLoginRoles lists the roles that can log in with their password hash
+// types, and the pg_hba.conf lines authenticating with md5 or cleartext
+// passwords. Hashes and rules need superuser (or granted) access and are left
+// out otherwise.
+func collectLoginRoles(ctx context.Context, s *session, res *Result) {
+	rows, err := s.conn.Query(ctx, sqlLoginRoles)
+	if err != nil {
+		return
+	}
+	for rows.Next() {
+		var r LoginRole
+		if err := rows.Scan(&r.Name, &r.Superuser, &r.Replication, &r.ConnLimit, &r.StatementTimeout); err == nil {
+			res.LoginRoles = append(res.LoginRoles, r)
+		}
+	}
+	rows.Close()
+
+	var passwords map[string]string
+	for _, q := range []string{sqlRolePasswords, sqlRolePasswordsShadow} {
+		rows, err := s.conn.Query(ctx, q)
+		if err != nil {
+			continue
+		}
+		passwords = map[string]string{}
+		for rows.Next() {
+			var name, typ string
+			if err := rows.Scan(&name, &typ); err == nil {
+				passwords[name] = typ
+			}
+		}
+		err = rows.Err()
+		rows.Close()
+		if err == nil {
+			break
+		}
+		passwords = nil
+	}
+	for i := range res.LoginRoles {
+		res.LoginRoles[i].Password = passwords[res.LoginRoles[i].Name]
+	}
+
+	if rows, err := s.conn.Query(ctx, sqlHBAPasswordRules); err == nil {
+		for rows.Next() {
+			var r HBARule
+			if err := rows.Scan(&r.Line, &r.Type, &r.Database, &r.User, &r.Address, &r.Method, &r.Error); err == nil {
+				res.PasswordHBARules = append(res.PasswordHBARules, r)
+			}
+		}
+		rows.Close()
+	}
+}
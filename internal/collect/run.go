@@ -2,13 +2,22 @@ package collect
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"math"
+	"net/url"
+	"os"
+	"path/filepath"
 	"regexp"
+	"slices"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/jackc/pgx/v5"
+
+	"github.com/koltyakov/pghealth/internal/sqlparse"
 )
 
 // Collection constants define thresholds and limits for data gathering.
@@ -40,1857 +49,2618 @@ const (
 
 	// maxLongRunningRows limits long-running query results.
 	maxLongRunningRows = 20
+
+	// selectivityMinScans is the minimum idx_scan to judge an index's selectivity.
+	selectivityMinScans = 100
+
+	// selectivityMinTuplesPerScan is the average entries read per scan above
+	// which an index is considered unselective.
+	selectivityMinTuplesPerScan = 1000
+
+	// selectivityMinReadPerFetch is the entries read per fetched row above
+	// which an index is considered unselective.
+	selectivityMinReadPerFetch = 10
 )
 
 // Result contains all collected PostgreSQL metrics and statistics.
 // Fields are populated based on available permissions and extensions.
 type Result struct {
 	// Connection and server information
-	ConnInfo   ConnInfo   // Basic connection and server details
-	Extensions Extensions // Installed PostgreSQL extensions
-	Roles      Roles      // Role memberships for the connected user
+	ConnInfo   ConnInfo   `json:"ConnInfo"`   // Basic connection and server details
+	Extensions Extensions `json:"Extensions"` // Installed PostgreSQL extensions
+	Roles      Roles      `json:"Roles"`      // Role memberships for the connected user
 
 	// Database-level metrics
-	DBs      []Database // List of databases with sizes and connections
-	Activity []Activity // Connection activity by database and state
-	Settings []Setting  // PostgreSQL configuration settings
+	DBs                []Database `json:"DBs"`                // List of databases with sizes and connections
+	Activity           []Activity `json:"Activity"`           // Connection activity by database and state
+	Settings           []Setting  `json:"Settings"`           // PostgreSQL configuration settings
+	NonDefaultSettings []Setting  `json:"NonDefaultSettings"` // Every setting changed from its default (with Config.AllSettings)
 
 	// Table and index statistics
-	Tables         []TableStat        // Table-level statistics
-	Indexes        []IndexStat        // Index usage and size statistics
-	IndexUnused    []IndexUnused      // Indexes with zero scans
-	MissingIndexes []MissingIndexHint // Tables that may benefit from indexes
+	Tables                []TableStat        `json:"Tables"`                // Table-level statistics (top tables per ranking)
+	Indexes               []IndexStat        `json:"Indexes"`               // Index usage and size statistics (top indexes and those of kept tables)
+	Catalog               CatalogTotals      `json:"Catalog"`               // Counts and sizes of all tables and indexes
+	IndexUnused           []IndexUnused      `json:"IndexUnused"`           // Indexes with zero scans
+	LowSelectivityIndexes []IndexStat        `json:"LowSelectivityIndexes"` // Indexes reading many entries per row returned (see IndexStat.LowSelectivity)
+	MissingIndexes        []MissingIndexHint `json:"MissingIndexes"`        // Tables that may benefit from indexes
+	Tablespaces           []Tablespace       `json:"Tablespaces"`           // Tablespaces other than pg_global (see TableStat.Tablespace)
+
+	// Report rankings selected from Tables after collection (see RankTables)
+	TopTablesBySize []TableStat `json:"TopTablesBySize"` // Largest tables across databases
+	TopTablesByRows []TableStat `json:"TopTablesByRows"` // Tables with the most live rows
+	SizeTree        *SizeNode   `json:"SizeTree"`        // Storage hierarchy: database → schema → table/index (nil without database sizes)
 
 	// Query performance (requires pg_stat_statements)
-	Statements Statements // Top queries by various metrics
+	Statements Statements `json:"Statements"` // Top queries by various metrics
 
 	// Collection errors (non-fatal)
-	Errors []string // Errors encountered during collection
+	Errors []string `json:"Errors"` // Errors encountered during collection
 
 	// Health check metrics
-	CacheHitCurrent     float64      // Cache hit ratio for current database
-	CacheHitOverall     float64      // Cluster-wide cache hit ratio
-	TotalConnections    int          // Total active connections
-	ConnectionsByClient []ClientConn // Connections grouped by client
-	Blocking            []Blocking   // Currently blocked queries
-	LongRunning         []LongQuery  // Queries running > 5 minutes
-	AutoVacuum          []AutoVacuum // Active autovacuum workers
+	CacheHitCurrent     float64             `json:"CacheHitCurrent"`     // Cache hit ratio for current database
+	CacheHitOverall     float64             `json:"CacheHitOverall"`     // Cluster-wide cache hit ratio
+	TotalConnections    int                 `json:"TotalConnections"`    // Total active connections
+	ConnectionsByClient []ClientConn        `json:"ConnectionsByClient"` // Connections grouped by client
+	RoleIdle            []RoleIdle          `json:"RoleIdle"`            // Idle times of client backends by role and application
+	LoginRoles          []LoginRole         `json:"LoginRoles"`          // Roles that can log in, with their safeguards
+	PasswordHBARules    []HBARule           `json:"PasswordHBARules"`    // pg_hba.conf lines authenticating with md5 or cleartext passwords
+	SessionStats        []SessionStat       `json:"SessionStats"`        // Per-database session counters (PostgreSQL 14+)
+	ClientFingerprints  []ClientFingerprint `json:"ClientFingerprints"`  // Client backends by application, locality and transport encryption
+	Blocking            []Blocking          `json:"Blocking"`            // Currently blocked queries
+	LongRunning         []LongQuery         `json:"LongRunning"`         // Queries running > 5 minutes
+	AutoVacuum          []AutoVacuum        `json:"AutoVacuum"`          // Active autovacuum workers
 
 	// Detailed statistics
-	CacheHits            []CacheHit        // Cache hit ratio per database
-	IndexUsageLow        []IndexUsage      // Tables with low index usage
-	TablesWithIndexCount []TableIndexCount // Tables with index counts
-	TableBloatStats      []TableBloatStat  // Estimated table bloat
-	IndexBloatStats      []IndexBloatStat  // Estimated index bloat
-	ReplicationStats     []ReplicationStat // Streaming replication status
-	CheckpointStats      CheckpointStats   // Checkpoint activity
-	MemoryStats          MemoryStats       // Memory usage statistics
-	IOStats              IOStats           // I/O statistics
-	LockStats            []LockStat        // Lock contention statistics
-	TempFileStats        []TempFileStat    // Temporary file usage
-	ExtensionStats       []ExtensionStat   // Installed extensions details
-	MemoryContexts       []MemoryContext   // Memory context information
+	CacheHits            []CacheHit         `json:"CacheHits"`            // Cache hit ratio per database
+	IndexUsageLow        []IndexUsage       `json:"IndexUsageLow"`        // Tables with low index usage
+	TablesWithIndexCount []TableIndexCount  `json:"TablesWithIndexCount"` // Tables with index counts
+	TableBloatStats      []TableBloatStat   `json:"TableBloatStats"`      // Estimated table bloat
+	IndexBloatStats      []IndexBloatStat   `json:"IndexBloatStats"`      // Estimated index bloat
+	ReplicationStats     []ReplicationStat  `json:"ReplicationStats"`     // Streaming replication status
+	CheckpointStats      CheckpointStats    `json:"CheckpointStats"`      // Checkpoint activity
+	MemoryStats          MemoryStats        `json:"MemoryStats"`          // Memory usage statistics
+	OSMemory             *OSMemory          `json:"OSMemory"`             // Kernel memory settings (nil unless the server runs on this machine)
+	OSCPU                *OSCPU             `json:"OSCPU"`                // CPU, NUMA and cgroup limits of the host (nil without Config.LocalOS)
+	DataDirectory        *DataDirectory     `json:"DataDirectory"`        // Data and WAL directories, checksums and at-rest encryption indicators
+	Template1            *Template1         `json:"Template1"`            // Locale and user objects of template1
+	DefaultPrivileges    []DefaultPrivilege `json:"DefaultPrivileges"`    // ALTER DEFAULT PRIVILEGES entries of the current database
+	IOStats              IOStats            `json:"IOStats"`              // I/O statistics
+	LockStats            []LockStat         `json:"LockStats"`            // Lock contention statistics
+	LockHotspots         []LockHotspot      `json:"LockHotspots"`         // Tables with waiting or row-level (tuple) locks
+	TempFileStats        []TempFileStat     `json:"TempFileStats"`        // Temporary file usage
+	ExtensionStats       []ExtensionStat    `json:"ExtensionStats"`       // Installed extensions details
+	MemoryContexts       []MemoryContext    `json:"MemoryContexts"`       // Memory context information
 
 	// Advanced metrics (may require pg_monitor role)
-	WaitEvents          []WaitEventStat       // Wait event statistics
-	FunctionStats       []FunctionStat        // User function statistics
-	WAL                 *WALStat              // WAL statistics (PG13+)
-	ProgressCreateIndex []ProgressCreateIndex // In-progress index builds
-	ProgressAnalyze     []ProgressAnalyze     // In-progress ANALYZE operations
+	WaitEvents          []WaitEventStat       `json:"WaitEvents"`          // Wait event statistics
+	FunctionStats       []FunctionStat        `json:"FunctionStats"`       // User function statistics
+	WAL                 *WALStat              `json:"WAL"`                 // WAL statistics (PG13+)
+	ProgressCreateIndex []ProgressCreateIndex `json:"ProgressCreateIndex"` // In-progress index builds
+	ProgressAnalyze     []ProgressAnalyze     `json:"ProgressAnalyze"`     // In-progress ANALYZE operations
+	ProgressCopy        []ProgressCopy        `json:"ProgressCopy"`        // In-progress COPY (PostgreSQL 14+)
+	ProgressCluster     []ProgressCluster     `json:"ProgressCluster"`     // In-progress CLUSTER and VACUUM FULL (PostgreSQL 12+)
+	ProgressBasebackup  []ProgressBasebackup  `json:"ProgressBasebackup"`  // In-progress base backups (PostgreSQL 13+)
 
 	// Additional health checks
-	XIDAge            []DatabaseXIDAge    // Transaction ID age per database
-	IdleInTransaction []IdleInTransaction // Long idle-in-transaction sessions
-	StaleStatsTables  []StaleStatsTable   // Tables with outdated statistics
-	DuplicateIndexes  []DuplicateIndex    // Indexes with identical definitions
-	InvalidIndexes    []InvalidIndex      // Failed/invalid indexes
-	FKMissingIndexes  []FKMissingIndex    // Foreign keys without supporting index
-	SequenceHealth    []SequenceHealth    // Sequences approaching exhaustion
-	PreparedXacts     []PreparedXact      // Orphaned prepared transactions
+	XIDAge            []DatabaseXIDAge    `json:"XIDAge"`            // Transaction ID age per database
+	FreezeForecast    *FreezeForecast     `json:"FreezeForecast"`    // Anti-wraparound autovacuum forecast per table (nil when unavailable)
+	XIDClock          *XIDClock           `json:"XIDClock"`          // Next XID and server time when read
+	XIDRates          []XIDRate           `json:"XIDRates"`          // XID consumption between archived runs and this one, newest first (filled from the archive)
+	SizeGrowth        []SizeGrowth        `json:"SizeGrowth"`        // Database, table and index growth over archived runs and this one (filled from the archive)
+	DiskSizeBytes     int64               `json:"DiskSizeBytes"`     // Capacity of the volume holding the databases (filled from -disk-size); 0 when unknown
+	ConnectionHistory *ConnectionHistory  `json:"ConnectionHistory"` // Client connections over archived runs and this one (filled from the archive)
+	StatementWindow   *StatementWindow    `json:"StatementWindow"`   // Statement load since an archived run about a day earlier (filled from the archive)
+	Delta             *DeltaStats         `json:"Delta"`             // Counter rates between samples at the start and the end of the run (-delta)
+	IdleInTransaction []IdleInTransaction `json:"IdleInTransaction"` // Long idle-in-transaction sessions
+	StaleStatsTables  []StaleStatsTable   `json:"StaleStatsTables"`  // Tables with outdated statistics
+	ColumnStats       []ColumnStat        `json:"ColumnStats"`       // pg_stats of the columns top statements filter and join on
+	RetentionTables   []RetentionTable    `json:"RetentionTables"`   // Large tables with a row age column, for retention and archival
+	DuplicateIndexes  []DuplicateIndex    `json:"DuplicateIndexes"`  // Indexes with identical definitions
+	RedundantIndexes  []RedundantIndex    `json:"RedundantIndexes"`  // Indexes covered by a wider index's leading columns
+	InvalidIndexes    []InvalidIndex      `json:"InvalidIndexes"`    // Failed/invalid indexes
+	FKMissingIndexes  []FKMissingIndex    `json:"FKMissingIndexes"`  // Foreign keys without supporting index
+	SequenceHealth    []SequenceHealth    `json:"SequenceHealth"`    // Sequences approaching exhaustion
+	PreparedXacts     []PreparedXact      `json:"PreparedXacts"`     // Orphaned prepared transactions
+	StandbyConflicts  *StandbyConflicts   `json:"StandbyConflicts"`  // Recovery conflict cancellations (nil unless a standby was checked)
+	Subtransactions   *Subtransactions    `json:"Subtransactions"`   // Subtrans SLRU and per-backend subtransaction counts (nil when unavailable)
+	VacuumHorizon     *VacuumHorizon      `json:"VacuumHorizon"`     // Replicas, slots and sessions holding back vacuum (nil when unavailable)
+	ReplicationSlots  []ReplicationSlot   `json:"ReplicationSlots"`  // Replication slots with the WAL they retain, largest first
+	ConfigFiles       *ConfigFiles        `json:"ConfigFiles"`       // postgresql.conf and pg_hba.conf entries that will not apply (nil when none or unreadable)
+	Patroni           *PatroniCluster     `json:"Patroni"`           // Patroni view of the cluster (filled from -patroni-url)
+	HA                *HAMetadata         `json:"HA"`                // repmgr / pg_auto_failover node registrations (nil unless present)
+	WALArchiving      *WALArchiving       `json:"WALArchiving"`      // archive_mode/archive_command with pg_stat_archiver (nil when unavailable)
+	RestoreCheck      *RestoreCheck       `json:"RestoreCheck"`      // Restore verification hook result (filled from -verify-restore-cmd)
+	SLOs              []SLOStatus         `json:"SLOs"`              // Service level objectives evaluated on this run and archived runs (filled from -slo)
+
+	// Collection completeness
+	Skipped []SkippedCollector `json:"Skipped"` // Collectors that did not run, with the reason
+	Timings []CollectorTiming  `json:"Timings"` // Time taken by each finished collector, in run order
+}
+
+// Completion is the percentage of collectors that ran to completion: 100
+// unless collection was truncated by a deadline or cancellation.
+func (r Result) Completion() float64 {
+	if len(r.Skipped) == 0 {
+		return 100
+	}
+	total := len(collectors)
+	ran := total - len(r.Skipped)
+	if ran < 0 {
+		ran = 0
+	}
+	return float64(ran) / float64(total) * 100
+}
+
+// XIDRatePerHour is the XID consumption per hour across XIDRates, or the
+// rate measured during the run when no archived runs are available; 0 when
+// unknown.
+func (r Result) XIDRatePerHour() float64 {
+	var xids int64
+	var d time.Duration
+	for _, x := range r.XIDRates {
+		xids += x.XIDs
+		d += x.To.Sub(x.From)
+	}
+	if d > 0 {
+		return float64(xids) / d.Hours()
+	}
+	if r.FreezeForecast != nil {
+		return r.FreezeForecast.XIDRate
+	}
+	return 0
+}
+
+// WraparoundIn forecasts the time until a database at XID age reaches the
+// wraparound limit at XIDRatePerHour, assuming vacuum does not advance its
+// datfrozenxid meanwhile; 0 when the rate is unknown.
+func (r Result) WraparoundIn(age int64) time.Duration {
+	rate := r.XIDRatePerHour()
+	if rate <= 0 || age >= xidMax {
+		return 0
+	}
+	return time.Duration(float64(xidMax-age) / rate * float64(time.Hour))
+}
+
+// CollectorTiming is the time a collector took, retries included.
+type CollectorTiming struct {
+	Name     string        `json:"Name"`
+	Duration time.Duration `json:"Duration"`
+}
+
+// SkippedCollector names a collector that did not run and why.
+type SkippedCollector struct {
+	Name   string `json:"Name"`
+	Reason string `json:"Reason"`
 }
 
 type ConnInfo struct {
-	Version        string
-	CurrentDB      string
-	CurrentUser    string
-	IsSuperuser    bool
-	MaxConnections int
-	SSL            string
-	StartTime      time.Time
+	Version            string    `json:"Version"`
+	CurrentDB          string    `json:"CurrentDB"`
+	CurrentUser        string    `json:"CurrentUser"`
+	IsSuperuser        bool      `json:"IsSuperuser"`
+	MaxConnections     int       `json:"MaxConnections"`
+	SSL                string    `json:"SSL"`
+	StartTime          time.Time `json:"StartTime"`
+	PasswordEncryption string    `json:"PasswordEncryption"` // hash for new passwords: scram-sha-256 or md5
+	Host               string    `json:"Host"`               // host:port that served the run (multi-host URLs pick one)
+	InRecovery         bool      `json:"InRecovery"`         // served by a standby
+	ReplicaHost        string    `json:"ReplicaHost"`        // host:port of Config.ReplicaURL serving offloaded collectors
+
+	// Clock and time zones; offsets are seconds east of UTC
+	ClockSkew            time.Duration `json:"ClockSkew"` // server clock minus the clock of the machine running pghealth
+	TimeZone             string        `json:"TimeZone"`  // server TimeZone (now() and timestamptz output)
+	TimeZoneOffset       int           `json:"TimeZoneOffset"`
+	LogTimeZone          string        `json:"LogTimeZone"` // server log_timezone (log line timestamps)
+	LogTimeZoneOffset    int           `json:"LogTimeZoneOffset"`
+	ClientTimeZone       string        `json:"ClientTimeZone"` // zone of the machine running pghealth (report timestamps)
+	ClientTimeZoneOffset int           `json:"ClientTimeZoneOffset"`
 }
 
 type Extensions struct {
-	PgStatStatements       bool
-	PgStatStatementsSchema string
+	PgStatStatements       bool   `json:"PgStatStatements"`
+	PgStatStatementsSchema string `json:"PgStatStatementsSchema"`
+	PgStatMonitor          bool   `json:"PgStatMonitor"` // pg_stat_monitor is visible; preferred for statements
+	PgStatMonitorSchema    string `json:"PgStatMonitorSchema"`
+}
+
+// HasQueryStats reports whether per-statement statistics are available from
+// pg_stat_statements or pg_stat_monitor.
+func (e Extensions) HasQueryStats() bool {
+	return e.PgStatStatements || e.PgStatMonitor
 }
 
 type Roles struct {
-	HasPgMonitor bool
+	HasPgMonitor bool `json:"HasPgMonitor"`
 }
 
 type Database struct {
-	Name        string
-	SizeBytes   int64
-	Tablespaces string
-	ConnCount   int
+	Name        string `json:"Name"`
+	SizeBytes   int64  `json:"SizeBytes"`
+	Tablespaces string `json:"Tablespaces"`
+	ConnCount   int    `json:"ConnCount"`
+	Encoding    string `json:"Encoding"`
+	Collate     string `json:"Collate"` // LC_COLLATE
+	Ctype       string `json:"Ctype"`   // LC_CTYPE
+
+	// LocaleProvider is libc, icu or builtin (PostgreSQL 15+, libc before);
+	// Locale is the ICU or builtin locale, empty with libc.
+	LocaleProvider string `json:"LocaleProvider"`
+	Locale         string `json:"Locale"`
+
+	Applications []string `json:"Applications"` // application names connected to it now
+}
+
+// Collation names the collation text sorts by: the ICU or builtin locale,
+// else LC_COLLATE.
+func (d Database) Collation() string {
+	if d.LocaleProvider != "libc" && d.LocaleProvider != "" && d.Locale != "" {
+		return d.LocaleProvider + " " + d.Locale
+	}
+	return d.Collate
+}
+
+// Tablespace is a tablespace of the cluster.
+type Tablespace struct {
+	Name      string `json:"Name"`
+	Location  string `json:"Location"`  // directory; empty for pg_default, which lives in the data directory
+	SizeBytes int64  `json:"SizeBytes"` // -1 when the size is not readable (needs CREATE on it or pg_read_all_stats)
+	Options   string `json:"Options"`   // spcoptions, such as random_page_cost=1.1
+}
+
+// RandomPageCost returns the random_page_cost set on the tablespace; a low
+// value marks fast (SSD or NVMe) storage.
+func (t Tablespace) RandomPageCost() (float64, bool) {
+	for _, opt := range strings.Split(t.Options, ",") {
+		if k, v, ok := strings.Cut(opt, "="); ok && strings.TrimSpace(k) == "random_page_cost" {
+			f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+			return f, err == nil
+		}
+	}
+	return 0, false
+}
+
+// EffectiveTablespace names the tablespace holding a relation of database db
+// placed in ts: ts itself, or the database's default tablespace when ts is
+// empty.
+func (r *Result) EffectiveTablespace(db, ts string) string {
+	if ts != "" {
+		return ts
+	}
+	if db == "" {
+		db = r.ConnInfo.CurrentDB
+	}
+	for _, d := range r.DBs {
+		if d.Name == db && d.Tablespaces != "" {
+			return d.Tablespaces
+		}
+	}
+	return "pg_default"
 }
 
 type Activity struct {
-	Datname string
-	State   string
-	Count   int
+	Datname string `json:"Datname"`
+	State   string `json:"State"`
+	Count   int    `json:"Count"`
 }
 
 type Setting struct {
-	Name   string
-	Val    string
-	Unit   string
-	Source string
+	Name   string `json:"Name"`
+	Val    string `json:"Val"`
+	Unit   string `json:"Unit"`
+	Source string `json:"Source"` // where the value comes from: default, configuration file, command line, ...
+
+	// Collected for the settings subset only
+	BootVal        string `json:"BootVal"` // compiled-in default
+	MinVal         string `json:"MinVal"`
+	MaxVal         string `json:"MaxVal"`
+	Context        string `json:"Context"`        // when a change applies: postmaster, sighup, user, ...
+	Category       string `json:"Category"`       // pg_settings category, e.g. "Resource Usage / Memory"
+	PendingRestart bool   `json:"PendingRestart"` // changed in the configuration file, waiting for a restart
+}
+
+// AppliedBy tells how a change of the setting takes effect: "restart",
+// "reload", "session" or the raw pg_settings context.
+func (s Setting) AppliedBy() string {
+	switch s.Context {
+	case "postmaster":
+		return "restart"
+	case "sighup":
+		return "reload"
+	case "user", "superuser":
+		return "session"
+	}
+	return s.Context
 }
 
 type TableStat struct {
-	Database  string
-	Schema    string
-	Name      string
-	SeqScans  int64
-	IdxScans  int64
-	NLiveTup  int64
-	NDeadTup  int64
-	SizeBytes int64
-	BloatPct  float64 // heuristic
+	Database  string  `json:"Database"`
+	Schema    string  `json:"Schema"`
+	Name      string  `json:"Name"`
+	SeqScans  int64   `json:"SeqScans"`
+	IdxScans  int64   `json:"IdxScans"`
+	NLiveTup  int64   `json:"NLiveTup"`
+	NDeadTup  int64   `json:"NDeadTup"`
+	SizeBytes int64   `json:"SizeBytes"`
+	BloatPct  float64 `json:"BloatPct"` // heuristic
+	// Tablespace holds the table when it is not the database's default
+	// tablespace; see Result.EffectiveTablespace.
+	Tablespace string `json:"Tablespace"`
 }
 
 type IndexStat struct {
-	Database  string
-	Schema    string
-	Table     string
-	Name      string
-	Scans     int64
-	SizeBytes int64
-	DDL       string
+	Database  string `json:"Database"`
+	Schema    string `json:"Schema"`
+	Table     string `json:"Table"`
+	Name      string `json:"Name"`
+	Scans     int64  `json:"Scans"`
+	SizeBytes int64  `json:"SizeBytes"`
+	DDL       string `json:"DDL"`
+	TupRead   int64  `json:"TupRead"`  // idx_tup_read: index entries returned by scans
+	TupFetch  int64  `json:"TupFetch"` // idx_tup_fetch: live heap rows fetched by simple index scans
+	// Constraint is what the index enforces beyond lookups ("primary key",
+	// "unique", "exclusion", "replica identity"); empty for plain indexes.
+	Constraint string `json:"Constraint"`
+	Tablespace string `json:"Tablespace"` // see TableStat.Tablespace
+}
+
+// TuplesPerScan is the average number of index entries read per scan.
+func (i IndexStat) TuplesPerScan() float64 {
+	if i.Scans == 0 {
+		return 0
+	}
+	return float64(i.TupRead) / float64(i.Scans)
+}
+
+// ReadPerFetch is the number of index entries read per heap row fetched, or 0
+// when no rows were fetched.
+func (i IndexStat) ReadPerFetch() float64 {
+	if i.TupFetch == 0 {
+		return 0
+	}
+	return float64(i.TupRead) / float64(i.TupFetch)
+}
+
+// LowSelectivity reports whether the index is scanned often but each scan
+// reads many entries per row it returns: the condition it serves matches far
+// more than the query needs, so a composite or partial index fits better.
+// Bitmap and index-only scans read entries without fetching heap rows, so
+// indexes fetching nothing are not judged.
+func (i IndexStat) LowSelectivity() bool {
+	return i.Scans >= selectivityMinScans && i.TupFetch > 0 &&
+		i.TuplesPerScan() >= selectivityMinTuplesPerScan && i.ReadPerFetch() >= selectivityMinReadPerFetch
+}
+
+// indexConstraint describes what an index enforces from its pg_index flags.
+// Unique indexes without a constraint still enforce uniqueness (and may be
+// referenced by foreign keys), so they count as well.
+func indexConstraint(primary, unique, exclusion, replIdent bool) string {
+	var parts []string
+	switch {
+	case primary:
+		parts = append(parts, "primary key")
+	case unique:
+		parts = append(parts, "unique")
+	}
+	if exclusion {
+		parts = append(parts, "exclusion")
+	}
+	if replIdent {
+		parts = append(parts, "replica identity")
+	}
+	return strings.Join(parts, ", ")
+}
+
+// IndexConstraint returns the Constraint of the collected index
+// schema.name in the current database, or "" when it is unknown.
+func (r *Result) IndexConstraint(schema, name string) string {
+	for _, i := range r.Indexes {
+		if i.Schema == schema && i.Name == name && (i.Database == "" || i.Database == r.ConnInfo.CurrentDB) {
+			return i.Constraint
+		}
+	}
+	for _, i := range r.IndexUnused {
+		if i.Schema == schema && i.Name == name && (i.Database == "" || i.Database == r.ConnInfo.CurrentDB) {
+			return i.Constraint
+		}
+	}
+	return ""
+}
+
+// QualifiedName is the identity of a table or index across databases:
+// db.schema.name, or schema.name when the database is unknown. With -dbs the
+// same schema.name can exist in several databases.
+func QualifiedName(db, schema, name string) string {
+	if db = strings.TrimSpace(db); db == "" {
+		return schema + "." + name
+	}
+	return db + "." + schema + "." + name
+}
+
+// ID is the fully-qualified name of the table.
+func (t TableStat) ID() string { return QualifiedName(t.Database, t.Schema, t.Name) }
+
+// ID is the fully-qualified name of the index.
+func (i IndexStat) ID() string { return QualifiedName(i.Database, i.Schema, i.Name) }
+
+// ID is the fully-qualified name of the index.
+func (i IndexUnused) ID() string { return QualifiedName(i.Database, i.Schema, i.Name) }
+
+// ID is the fully-qualified name of the table.
+func (i IndexUsage) ID() string { return QualifiedName(i.Database, i.Schema, i.Table) }
+
+// ID is the fully-qualified name of the table.
+func (t TableIndexCount) ID() string { return QualifiedName(t.Database, t.Schema, t.Name) }
+
+// MultiDatabase reports whether the collected tables and indexes span more
+// than one database, as with -dbs. Rows without a database belong to the
+// current one.
+func (r *Result) MultiDatabase() bool {
+	first := ""
+	differs := func(db string) bool {
+		if db = strings.TrimSpace(db); db == "" {
+			db = r.ConnInfo.CurrentDB
+		}
+		if first == "" {
+			first = db
+		}
+		return db != first
+	}
+	for _, t := range r.Tables {
+		if differs(t.Database) {
+			return true
+		}
+	}
+	for _, i := range r.Indexes {
+		if differs(i.Database) {
+			return true
+		}
+	}
+	for _, i := range r.IndexUnused {
+		if differs(i.Database) {
+			return true
+		}
+	}
+	for _, t := range r.TablesWithIndexCount {
+		if differs(t.Database) {
+			return true
+		}
+	}
+	return false
+}
+
+// ObjectNamer returns a function naming tables and indexes for display:
+// schema.name, or db.schema.name when objects of several databases were
+// collected and schema.name alone is ambiguous.
+func (r *Result) ObjectNamer() func(db, schema, name string) string {
+	if !r.MultiDatabase() {
+		return func(_, schema, name string) string { return schema + "." + name }
+	}
+	current := r.ConnInfo.CurrentDB
+	return func(db, schema, name string) string {
+		if strings.TrimSpace(db) == "" {
+			db = current
+		}
+		return QualifiedName(db, schema, name)
+	}
+}
+
+// CatalogTotals counts every table and index streamed during collection,
+// including those not kept in Result.Tables and Result.Indexes.
+type CatalogTotals struct {
+	Tables     int            `json:"Tables"`
+	TableBytes int64          `json:"TableBytes"`
+	Indexes    int            `json:"Indexes"`
+	IndexBytes int64          `json:"IndexBytes"`
+	Schemas    []SchemaTotals `json:"Schemas"` // per schema, largest first
+
+	schemaIdx map[string]int // Schemas position by relationKey(db, schema, "")
+}
+
+// schema returns the totals of a schema, adding them on first use.
+func (c *CatalogTotals) schema(db, name string) *SchemaTotals {
+	if c.schemaIdx == nil {
+		c.schemaIdx = make(map[string]int, len(c.Schemas))
+		for i, st := range c.Schemas {
+			c.schemaIdx[relationKey(st.Database, st.Schema, "")] = i
+		}
+	}
+	k := relationKey(db, name, "")
+	i, ok := c.schemaIdx[k]
+	if !ok {
+		i = len(c.Schemas)
+		c.Schemas = append(c.Schemas, SchemaTotals{Database: db, Schema: name})
+		c.schemaIdx[k] = i
+	}
+	return &c.Schemas[i]
+}
+
+// sortSchemas orders the schema totals largest first.
+func (c *CatalogTotals) sortSchemas() {
+	sort.SliceStable(c.Schemas, func(i, j int) bool {
+		a, b := c.Schemas[i], c.Schemas[j]
+		if a.TableBytes != b.TableBytes {
+			return a.TableBytes > b.TableBytes
+		}
+		return relationKey(a.Database, a.Schema, "") < relationKey(b.Database, b.Schema, "")
+	})
+	c.schemaIdx = nil
+}
+
+// SchemaTotals rolls up the tables and indexes of one schema, so owners of a
+// schema in a shared database can find their slice.
+type SchemaTotals struct {
+	Database   string `json:"Database"`
+	Schema     string `json:"Schema"`
+	Tables     int    `json:"Tables"`
+	TableBytes int64  `json:"TableBytes"` // including indexes and TOAST
+	Indexes    int    `json:"Indexes"`
+	IndexBytes int64  `json:"IndexBytes"`
+	LiveTup    int64  `json:"LiveTup"`
+	DeadTup    int64  `json:"DeadTup"`
+	SeqScans   int64  `json:"SeqScans"`
+	IdxScans   int64  `json:"IdxScans"`
+}
+
+// BloatPct is the dead share of the schema's tuples.
+func (s SchemaTotals) BloatPct() float64 {
+	if s.LiveTup+s.DeadTup == 0 {
+		return 0
+	}
+	return float64(s.DeadTup) / float64(s.LiveTup+s.DeadTup) * 100
+}
+
+// IndexUsagePct is the share of the schema's table scans that used an index.
+func (s SchemaTotals) IndexUsagePct() float64 {
+	if s.SeqScans+s.IdxScans == 0 {
+		return 0
+	}
+	return float64(s.IdxScans) / float64(s.SeqScans+s.IdxScans) * 100
 }
 
 type IndexUnused struct {
-	Database  string
-	Schema    string
-	Table     string
-	Name      string
-	SizeBytes int64
+	Database   string `json:"Database"`
+	Schema     string `json:"Schema"`
+	Table      string `json:"Table"`
+	Name       string `json:"Name"`
+	SizeBytes  int64  `json:"SizeBytes"`
+	Constraint string `json:"Constraint"` // see IndexStat.Constraint; such indexes cannot simply be dropped
 }
 
 type MissingIndexHint struct {
-	Schema     string
-	Table      string
-	Columns    string
-	EstBenefit string
+	Schema     string `json:"Schema"`
+	Table      string `json:"Table"`
+	Columns    string `json:"Columns"`
+	EstBenefit string `json:"EstBenefit"`
 }
 
 type Statements struct {
-	Available      bool
-	TopByTotalTime []Statement
-	TopByCPU       []Statement
-	TopByCalls     []Statement
-	TopByIO        []Statement
-	TopByIOBlocks  []Statement
-	StatsResetTime time.Time
-	StatsDuration  time.Duration
-	SkippedReason  string
+	Available      bool            `json:"Available"`
+	TopByTotalTime []Statement     `json:"TopByTotalTime"`
+	TopByCPU       []Statement     `json:"TopByCPU"`
+	TopByCalls     []Statement     `json:"TopByCalls"`
+	TopByIO        []Statement     `json:"TopByIO"`
+	TopByIOBlocks  []Statement     `json:"TopByIOBlocks"`
+	ByRole         []StatementLoad `json:"ByRole"`     // Execution time attributed to roles
+	ByDatabase     []StatementLoad `json:"ByDatabase"` // Execution time attributed to databases
+	ByApp          []StatementLoad `json:"ByApp"`      // Execution time attributed to query comment app tags
+	StatsResetTime time.Time       `json:"StatsResetTime"`
+	StatsDuration  time.Duration   `json:"StatsDuration"`
+	SkippedReason  string          `json:"SkippedReason"`
+	Source         string          `json:"Source"`      // view the statements were read from: SourcePgStatStatements or SourcePgStatMonitor
+	MinCalls       float64         `json:"MinCalls"`    // statements called fewer times were left out of the lists (-min-calls)
+	MinMeanTime    float64         `json:"MinMeanTime"` // ms; statements with a lower mean time were left out of the lists (-min-mean-time)
+	Ignored        int             `json:"Ignored"`     // statements left out of the lists by their text (-ignore-query)
 }
 
+// Statement sources.
+const (
+	SourcePgStatStatements = "pg_stat_statements"
+	SourcePgStatMonitor    = "pg_stat_monitor"
+)
+
 type Statement struct {
-	Query           string
-	Calls           float64
-	CallsPerHour    float64
-	TotalTime       float64
-	MeanTime        float64
-	Rows            float64
-	BlkReadTime     float64
-	BlkWriteTime    float64
-	CPUTime         float64 // approx: total - read - write
-	IOTime          float64 // read + write
-	SharedBlksRead  float64
-	SharedBlksWrite float64
-	LocalBlksRead   float64
-	LocalBlksWrite  float64
-	TempBlksRead    float64
-	TempBlksWrite   float64
-	Advice          *PlanAdvice
-	NeedsAttention  bool
+	QueryID         int64           `json:"QueryID"` // pg_stat_statements queryid; 0 when hidden or unavailable
+	Query           string          `json:"Query"`
+	Calls           float64         `json:"Calls"`
+	CallsPerHour    float64         `json:"CallsPerHour"`
+	TotalTime       float64         `json:"TotalTime"`
+	MeanTime        float64         `json:"MeanTime"`
+	MinTime         float64         `json:"MinTime"`    // fastest execution; min/max/stddev are 0 when not tracked
+	MaxTime         float64         `json:"MaxTime"`    // slowest execution
+	StddevTime      float64         `json:"StddevTime"` // population standard deviation of execution times
+	Rows            float64         `json:"Rows"`
+	BlkReadTime     float64         `json:"BlkReadTime"`
+	BlkWriteTime    float64         `json:"BlkWriteTime"`
+	CPUTime         float64         `json:"CPUTime"` // approx: total - read - write
+	IOTime          float64         `json:"IOTime"`  // read + write
+	SharedBlksRead  float64         `json:"SharedBlksRead"`
+	SharedBlksWrite float64         `json:"SharedBlksWrite"`
+	LocalBlksRead   float64         `json:"LocalBlksRead"`
+	LocalBlksWrite  float64         `json:"LocalBlksWrite"`
+	TempBlksRead    float64         `json:"TempBlksRead"`
+	TempBlksWrite   float64         `json:"TempBlksWrite"`
+	Histogram       []LatencyBucket `json:"Histogram"` // pg_stat_monitor response time histogram
+	ClientIPs       []string        `json:"ClientIPs"` // pg_stat_monitor client addresses, capped at pgsmMaxClients
+	Comments        []string        `json:"Comments"`  // pg_stat_monitor SQL comments of the statement
+	Truncated       bool            `json:"Truncated"` // query text ends mid-statement or at the pg_stat_monitor length limit
+	Advice          *PlanAdvice     `json:"Advice"`
+	NeedsAttention  bool            `json:"NeedsAttention"`
+}
+
+// LatencyBucket counts the executions of a statement that took at most
+// UpperMs and more than the previous bucket's bound. UpperMs is 0 for the
+// open-ended last bucket.
+type LatencyBucket struct {
+	UpperMs float64 `json:"UpperMs"`
+	Calls   float64 `json:"Calls"`
+}
+
+// StatementLoad aggregates pg_stat_statements totals for a role or database.
+type StatementLoad struct {
+	Name      string  `json:"Name"`
+	Calls     float64 `json:"Calls"`
+	TotalTime float64 `json:"TotalTime"` // ms
+	Share     float64 `json:"Share"`     // fraction of total execution time across all groups
+}
+
+// Latency quantile z-scores of the normal distribution.
+const (
+	zP95 = 1.645
+	zP99 = 2.326
+)
+
+// HasLatencySpread reports whether min/max/stddev execution times were
+// collected for the statement.
+func (s Statement) HasLatencySpread() bool {
+	return s.MaxTime > 0
+}
+
+// HasHistogram reports whether the percentiles are read from a pg_stat_monitor
+// histogram rather than estimated from the mean and standard deviation.
+func (s Statement) HasHistogram() bool {
+	_, ok := s.histogramQuantile(0.5)
+	return ok
+}
+
+// LatencyP95 estimates the 95th percentile execution time (ms) from the
+// histogram when collected, otherwise from the mean and standard deviation,
+// capped at the slowest execution.
+func (s Statement) LatencyP95() float64 {
+	return s.latencyQuantile(0.95, zP95)
+}
+
+// LatencyP99 estimates the 99th percentile execution time (ms) like LatencyP95.
+func (s Statement) LatencyP99() float64 {
+	return s.latencyQuantile(0.99, zP99)
+}
+
+// latencyQuantile otherwise assumes normally distributed execution times.
+// Real latency is skewed to the right, so the estimate is a lower bound for
+// heavy tails; pg_stat_statements keeps no per-call times to do better.
+func (s Statement) latencyQuantile(q, z float64) float64 {
+	if v, ok := s.histogramQuantile(q); ok {
+		return v
+	}
+	if !s.HasLatencySpread() {
+		return s.MeanTime
+	}
+	return max(s.MeanTime, min(s.MeanTime+z*s.StddevTime, s.MaxTime))
+}
+
+// histogramQuantile is the upper bound of the histogram bucket holding
+// quantile q, capped at the slowest execution. The open-ended last bucket is
+// bounded by the slowest execution only.
+func (s Statement) histogramQuantile(q float64) (float64, bool) {
+	var total float64
+	for _, b := range s.Histogram {
+		total += b.Calls
+	}
+	if total == 0 {
+		return 0, false
+	}
+	var seen float64
+	for _, b := range s.Histogram {
+		seen += b.Calls
+		if b.Calls == 0 || seen < total*q {
+			continue
+		}
+		switch {
+		case b.UpperMs == 0:
+			return s.MaxTime, s.MaxTime > 0
+		case s.MaxTime > 0:
+			return min(b.UpperMs, s.MaxTime), true
+		}
+		return b.UpperMs, true
+	}
+	return 0, false
+}
+
+// HiddenQueryText replaces the text of other roles' statements for roles
+// without pg_read_all_stats.
+const HiddenQueryText = "<insufficient privilege>"
+
+// TextProblem explains why the query text cannot be analyzed, or is empty
+// when it can: plan, shape and index advice are skipped for such statements.
+func (s Statement) TextProblem() string {
+	switch {
+	case s.Query == HiddenQueryText:
+		return "query text hidden: the role lacks pg_read_all_stats"
+	case strings.TrimSpace(s.Query) == "":
+		return "query text unavailable"
+	case s.Truncated:
+		return "query text truncated"
+	}
+	return ""
+}
+
+// Unique returns the statements of all top lists once each, in list order:
+// by total time, CPU, calls, I/O time and I/O blocks.
+func (s Statements) Unique() []Statement {
+	var out []Statement
+	seen := map[string]bool{}
+	for _, list := range [][]Statement{s.TopByTotalTime, s.TopByCPU, s.TopByCalls, s.TopByIO, s.TopByIOBlocks} {
+		for _, st := range list {
+			if !seen[st.Key()] {
+				seen[st.Key()] = true
+				out = append(out, st)
+			}
+		}
+	}
+	return out
+}
+
+// Key returns a stable identifier for linking a statement across runs: the
+// queryid when known, otherwise the normalized query text.
+func (s Statement) Key() string {
+	if s.QueryID != 0 {
+		return strconv.FormatInt(s.QueryID, 10)
+	}
+	return s.Query
 }
 
 // PlanAdvice contains collected EXPLAIN plan text, highlights and human suggestions
 type PlanAdvice struct {
-	Plan            string
-	Highlights      []string
-	Suggestions     []string
-	CanBeIndexed    bool
-	CanBeRefactored bool
+	Plan            string   `json:"Plan"`
+	Highlights      []string `json:"Highlights"`
+	Suggestions     []string `json:"Suggestions"`
+	IndexDDL        []string `json:"IndexDDL"` // CREATE INDEX CONCURRENTLY statements derived from the query predicates
+	CanBeIndexed    bool     `json:"CanBeIndexed"`
+	CanBeRefactored bool     `json:"CanBeRefactored"`
+}
+
+// parallelHighlight marks plans with parallel operations.
+const parallelHighlight = "Parallel operation(s)"
+
+// Parallel reports whether the plan has parallel operations.
+func (p *PlanAdvice) Parallel() bool {
+	return p != nil && slices.Contains(p.Highlights, parallelHighlight)
 }
 
 // Healthcheck types
 type ClientConn struct {
-	Address     string
-	User        string
-	Application string
-	Count       int
+	Address       string  `json:"Address"`
+	User          string  `json:"User"`
+	Application   string  `json:"Application"`
+	Count         int     `json:"Count"`
+	Idle          int     `json:"Idle"`          // backends idle between transactions
+	AvgAgeSeconds float64 `json:"AvgAgeSeconds"` // mean time since the backends connected
+}
+
+// RoleIdle holds how long the client backends of a role and application sit
+// idle, measured since their last state change, and the idle timeouts that
+// apply to the role.
+type RoleIdle struct {
+	Role               string  `json:"Role"`
+	Application        string  `json:"Application"`
+	Sessions           int     `json:"Sessions"`
+	Idle               int     `json:"Idle"` // backends idle between transactions
+	IdleMedianSeconds  float64 `json:"IdleMedianSeconds"`
+	IdleMaxSeconds     float64 `json:"IdleMaxSeconds"`
+	IdleInTx           int     `json:"IdleInTx"` // backends idle inside a transaction, aborted ones included
+	IdleInTxMaxSeconds float64 `json:"IdleInTxMaxSeconds"`
+
+	// IdleInTxTimeout and IdleSessionTimeout are the timeouts applying to the
+	// role, from its own settings or else the server's; empty when disabled.
+	IdleInTxTimeout    string `json:"IdleInTxTimeout"`
+	IdleSessionTimeout string `json:"IdleSessionTimeout"`
+
+	// HasIdleSessionTimeout tells the server has idle_session_timeout
+	// (PostgreSQL 14+).
+	HasIdleSessionTimeout bool `json:"HasIdleSessionTimeout"`
+}
+
+// LoginRole is a role that can log in, with the safeguards limiting what a
+// runaway session of it can take.
+type LoginRole struct {
+	Name             string `json:"Name"`
+	Superuser        bool   `json:"Superuser"`
+	Replication      bool   `json:"Replication"`
+	ConnLimit        int    `json:"ConnLimit"`        // -1 when unlimited
+	StatementTimeout string `json:"StatementTimeout"` // cluster-wide role setting; empty when unset
+
+	// Password is the type of the stored password hash: scram-sha-256, md5,
+	// plain or none; empty when hashes are not readable (superuser only).
+	Password string `json:"Password"`
+}
+
+// ClientFingerprint counts the client backends sharing an application name,
+// locality and transport encryption. Library and Version are parsed from
+// application names such as "DBeaver 23.1.0 - Main" or "billing/1.4.2".
+type ClientFingerprint struct {
+	Application string `json:"Application"`
+	Library     string `json:"Library"` // application name without its version; empty when it has none
+	Version     string `json:"Version"`
+	Local       bool   `json:"Local"` // connected over a Unix socket
+	SSL         bool   `json:"SSL"`
+	SSLVersion  string `json:"SSLVersion"` // TLS protocol, e.g. TLSv1.3
+	Cipher      string `json:"Cipher"`
+	GSS         bool   `json:"GSS"` // GSSAPI encrypted (PostgreSQL 12+)
+	Count       int    `json:"Count"`
+}
+
+// Encrypted reports whether the connections are encrypted in transit.
+func (c ClientFingerprint) Encrypted() bool { return c.SSL || c.GSS }
+
+// SessionStat holds the session counters of pg_stat_database (PostgreSQL
+// 14+) since the statistics were reset, or since the server started when they
+// never were.
+type SessionStat struct {
+	Datname       string  `json:"Datname"`
+	Sessions      int64   `json:"Sessions"`
+	SessionTimeMs float64 `json:"SessionTimeMs"` // time spent connected
+	ActiveTimeMs  float64 `json:"ActiveTimeMs"`  // time spent running statements
+	IdleInTxMs    float64 `json:"IdleInTxMs"`    // time spent idle in a transaction
+	Abandoned     int64   `json:"Abandoned"`     // sessions whose client went away without closing
+	Fatal         int64   `json:"Fatal"`         // sessions ended by a fatal error
+	Killed        int64   `json:"Killed"`        // sessions terminated by an operator
+	WindowSeconds float64 `json:"WindowSeconds"` // seconds the counters cover
+}
+
+// AvgSessionMs is the mean session lifetime in milliseconds.
+func (s SessionStat) AvgSessionMs() float64 {
+	if s.Sessions == 0 {
+		return 0
+	}
+	return s.SessionTimeMs / float64(s.Sessions)
+}
+
+// PerHour is the rate of new sessions.
+func (s SessionStat) PerHour() float64 {
+	if s.WindowSeconds <= 0 {
+		return 0
+	}
+	return float64(s.Sessions) / s.WindowSeconds * 3600
+}
+
+// ActivePct is the share of connected time spent running statements.
+func (s SessionStat) ActivePct() float64 {
+	if s.SessionTimeMs <= 0 {
+		return 0
+	}
+	return s.ActiveTimeMs / s.SessionTimeMs * 100
+}
+
+// AvgActive is the mean number of sessions running a statement at once: the
+// server connections a transaction pool needs on average.
+func (s SessionStat) AvgActive() float64 {
+	if s.WindowSeconds <= 0 {
+		return 0
+	}
+	return s.ActiveTimeMs / 1000 / s.WindowSeconds
 }
 
 type Blocking struct {
-	Datname          string
-	BlockedPID       int
-	BlockingPID      int
-	BlockedDuration  string
-	BlockingDuration string
-	BlockedQuery     string
-	BlockingQuery    string
+	Datname          string `json:"Datname"`
+	BlockedPID       int    `json:"BlockedPID"`
+	BlockingPID      int    `json:"BlockingPID"`
+	BlockedDuration  string `json:"BlockedDuration"`
+	BlockingDuration string `json:"BlockingDuration"`
+	BlockedQuery     string `json:"BlockedQuery"`
+	BlockingQuery    string `json:"BlockingQuery"`
 }
 
 type LongQuery struct {
-	Datname  string
-	PID      int
-	Duration string
-	State    string
-	Query    string
+	Datname  string `json:"Datname"`
+	PID      int    `json:"PID"`
+	Duration string `json:"Duration"`
+	State    string `json:"State"`
+	Query    string `json:"Query"`
 }
 
+// vacuumPhaseScanHeap is the pg_stat_progress_vacuum phase advancing heap_blks_scanned.
+const vacuumPhaseScanHeap = "scanning heap"
+
+// Before PostgreSQL 17 vacuum keeps dead tuple identifiers in an array of
+// deadTIDBytes per tuple, capped at deadTIDMaxBytes whatever the setting.
+const (
+	deadTIDBytes    = 6
+	deadTIDMaxBytes = 1 << 30
+)
+
 type AutoVacuum struct {
-	Datname  string
-	PID      int
-	Relation string
-	Phase    string
-	Scanned  int64
-	Total    int64
+	Datname  string  `json:"Datname"`
+	PID      int     `json:"PID"`
+	Relation string  `json:"Relation"`
+	Phase    string  `json:"Phase"`
+	Scanned  int64   `json:"Scanned"`
+	Total    int64   `json:"Total"`
+	ScanRate float64 `json:"ScanRate"` // heap blocks scanned per second between two samples (0 when not measured)
+	Auto     bool    `json:"Auto"`     // an autovacuum worker rather than a manual VACUUM
+
+	// Dead tuple memory: once full, vacuum pauses the heap scan to vacuum
+	// every index and then resumes, so a large table may take several index
+	// passes. Before PostgreSQL 17 it is counted in tuples, since in bytes.
+	IndexVacuumCount int64 `json:"IndexVacuumCount"`
+	MaxDeadTuples    int64 `json:"MaxDeadTuples"`
+	NumDeadTuples    int64 `json:"NumDeadTuples"`
+	MaxDeadBytes     int64 `json:"MaxDeadBytes"` // PostgreSQL 17+
+	DeadBytes        int64 `json:"DeadBytes"`    // PostgreSQL 17+
+}
+
+// DeadMemoryLimit is the dead tuple memory of the vacuum in bytes: the
+// autovacuum_work_mem or maintenance_work_mem it runs with.
+func (a AutoVacuum) DeadMemoryLimit() int64 {
+	if a.MaxDeadBytes > 0 {
+		return a.MaxDeadBytes
+	}
+	return a.MaxDeadTuples * deadTIDBytes
+}
+
+// DeadMemoryFill is the share of the dead tuple memory in use, in percent.
+func (a AutoVacuum) DeadMemoryFill() float64 {
+	if a.MaxDeadBytes > 0 {
+		return progressPct(a.DeadBytes, a.MaxDeadBytes)
+	}
+	return progressPct(a.NumDeadTuples, a.MaxDeadTuples)
+}
+
+// MultiPass reports whether the dead tuple memory ran out before the heap
+// scan finished: indexes were vacuumed more than once, or already once while
+// heap blocks are left to scan.
+func (a AutoVacuum) MultiPass() bool {
+	return a.IndexVacuumCount > 1 || (a.IndexVacuumCount == 1 && a.Phase == vacuumPhaseScanHeap && a.Scanned < a.Total)
+}
+
+// DeadMemoryNeeded estimates the dead tuple memory that would let the vacuum
+// finish with a single index pass: the memory filled by its passes so far,
+// extrapolated to the whole heap while it is still scanning.
+func (a AutoVacuum) DeadMemoryNeeded() int64 {
+	limit := a.DeadMemoryLimit()
+	used := int64(float64(limit) * a.DeadMemoryFill() / 100)
+	found := limit*a.IndexVacuumCount + used
+	if a.Phase == vacuumPhaseScanHeap && a.Scanned > 0 && a.Total > a.Scanned {
+		return int64(float64(found) * float64(a.Total) / float64(a.Scanned))
+	}
+	return found
+}
+
+// MemoryCapped reports whether the dead tuple memory is at the 1GB limit
+// that applies before PostgreSQL 17, so raising the setting does not help.
+func (a AutoVacuum) MemoryCapped() bool {
+	return a.MaxDeadBytes == 0 && a.MaxDeadTuples >= deadTIDMaxBytes/deadTIDBytes
+}
+
+// Progress is the share of heap blocks scanned, in percent.
+func (a AutoVacuum) Progress() float64 {
+	if a.Total <= 0 {
+		return 0
+	}
+	return 100 * float64(a.Scanned) / float64(a.Total)
+}
+
+// ETA estimates the time left to finish the heap scan at ScanRate, or 0 when
+// unknown. Index and heap vacuuming phases that follow the scan are not included.
+func (a AutoVacuum) ETA() time.Duration {
+	if a.Phase != vacuumPhaseScanHeap || a.ScanRate <= 0 || a.Scanned >= a.Total {
+		return 0
+	}
+	return time.Duration(float64(a.Total-a.Scanned) / a.ScanRate * float64(time.Second))
 }
 
 type CacheHit struct {
-	Datname  string
-	BlksHit  int64
-	BlksRead int64
-	Ratio    float64 // percent 0..100
+	Datname  string  `json:"Datname"`
+	BlksHit  int64   `json:"BlksHit"`
+	BlksRead int64   `json:"BlksRead"`
+	Ratio    float64 `json:"Ratio"` // percent 0..100
 }
 
 type IndexUsage struct {
-	Database      string
-	Schema        string
-	Table         string
-	IndexUsagePct float64
-	Rows          int64
+	Database      string  `json:"Database"`
+	Schema        string  `json:"Schema"`
+	Table         string  `json:"Table"`
+	IndexUsagePct float64 `json:"IndexUsagePct"`
+	Rows          int64   `json:"Rows"`
 }
 
 type TableIndexCount struct {
-	Database   string
-	Schema     string
-	Name       string
-	IndexCount int
-	SizeBytes  int64
-	RowCount   int64
-	DeadRows   int64
-	BloatPct   float64
+	Database   string  `json:"Database"`
+	Schema     string  `json:"Schema"`
+	Name       string  `json:"Name"`
+	IndexCount int     `json:"IndexCount"`
+	SizeBytes  int64   `json:"SizeBytes"`
+	RowCount   int64   `json:"RowCount"`
+	DeadRows   int64   `json:"DeadRows"`
+	BloatPct   float64 `json:"BloatPct"`
 }
 
 type TableBloatStat struct {
-	Schema         string
-	Name           string
-	EstimatedBloat float64 // percentage
-	WastedBytes    int64
-	LastVacuum     *time.Time
-	LastAnalyze    *time.Time
+	Schema         string     `json:"Schema"`
+	Name           string     `json:"Name"`
+	EstimatedBloat float64    `json:"EstimatedBloat"` // percentage
+	WastedBytes    int64      `json:"WastedBytes"`
+	LastVacuum     *time.Time `json:"LastVacuum"`
+	LastAnalyze    *time.Time `json:"LastAnalyze"`
 }
 
 type IndexBloatStat struct {
-	Schema         string
-	Table          string
-	Name           string
-	EstimatedBloat float64
-	WastedBytes    int64
-	Scans          int64
+	Schema         string  `json:"Schema"`
+	Table          string  `json:"Table"`
+	Name           string  `json:"Name"`
+	EstimatedBloat float64 `json:"EstimatedBloat"`
+	WastedBytes    int64   `json:"WastedBytes"`
+	Scans          int64   `json:"Scans"`
 }
 
 type ReplicationStat struct {
-	Name         string
-	State        string
-	SyncState    string
-	SyncPriority int
-	ReplayLag    string
-	WriteLag     string
-	FlushLag     string
+	Name         string `json:"Name"`
+	State        string `json:"State"`
+	SyncState    string `json:"SyncState"`
+	SyncPriority int    `json:"SyncPriority"`
+	ReplayLag    string `json:"ReplayLag"`
+	WriteLag     string `json:"WriteLag"`
+	FlushLag     string `json:"FlushLag"`
+}
+
+// SyncStandbys is a parsed synchronous_standby_names: commits wait for Num
+// of Names, by priority (FIRST, or the plain list form) or as a quorum (ANY).
+type SyncStandbys struct {
+	Quorum bool
+	Num    int
+	Names  []string // may hold "*", matching any standby
+}
+
+// ParseSyncStandbys parses synchronous_standby_names; an empty value yields
+// a zero SyncStandbys (asynchronous replication).
+func ParseSyncStandbys(v string) (SyncStandbys, error) {
+	var ss SyncStandbys
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return ss, nil
+	}
+	list := v
+	if open := strings.IndexByte(v, '('); open >= 0 {
+		if !strings.HasSuffix(v, ")") {
+			return ss, fmt.Errorf("synchronous_standby_names %q: unbalanced parentheses", v)
+		}
+		head := strings.Fields(v[:open])
+		if len(head) == 2 {
+			switch strings.ToUpper(head[0]) {
+			case "ANY":
+				ss.Quorum = true
+			case "FIRST":
+			default:
+				return ss, fmt.Errorf("synchronous_standby_names %q: expected FIRST or ANY, got %s", v, head[0])
+			}
+			head = head[1:]
+		}
+		if len(head) != 1 {
+			return ss, fmt.Errorf("synchronous_standby_names %q: expected [FIRST|ANY] num_sync (names)", v)
+		}
+		n, err := strconv.Atoi(head[0])
+		if err != nil || n < 1 {
+			return ss, fmt.Errorf("synchronous_standby_names %q: invalid num_sync %s", v, head[0])
+		}
+		ss.Num = n
+		list = v[open+1 : len(v)-1]
+	} else {
+		ss.Num = 1
+	}
+	for _, name := range strings.Split(list, ",") {
+		name = strings.TrimSpace(name)
+		if len(name) >= 2 && strings.HasPrefix(name, `"`) && strings.HasSuffix(name, `"`) {
+			name = strings.ReplaceAll(name[1:len(name)-1], `""`, `"`)
+		}
+		if name == "" {
+			return ss, fmt.Errorf("synchronous_standby_names %q: empty standby name", v)
+		}
+		ss.Names = append(ss.Names, name)
+	}
+	return ss, nil
+}
+
+// Matches reports whether a standby's application_name is listed. Names
+// compare case-insensitively, quoted or not, as PostgreSQL does.
+func (ss SyncStandbys) Matches(application string) bool {
+	for _, n := range ss.Names {
+		if n == "*" || strings.EqualFold(n, application) {
+			return true
+		}
+	}
+	return false
+}
+
+// Wildcard reports whether any standby can be synchronous.
+func (ss SyncStandbys) Wildcard() bool {
+	for _, n := range ss.Names {
+		if n == "*" {
+			return true
+		}
+	}
+	return false
 }
 
 type CheckpointStats struct {
-	RequestedCheckpoints int64
-	ScheduledCheckpoints int64
-	CheckpointWriteTime  time.Duration
-	CheckpointSyncTime   time.Duration
-	BuffersWritten       int64
-	BuffersCheckpoint    int64
+	RequestedCheckpoints int64         `json:"RequestedCheckpoints"`
+	ScheduledCheckpoints int64         `json:"ScheduledCheckpoints"`
+	CheckpointWriteTime  time.Duration `json:"CheckpointWriteTime"`
+	CheckpointSyncTime   time.Duration `json:"CheckpointSyncTime"`
+	BuffersWritten       int64         `json:"BuffersWritten"`
+	BuffersCheckpoint    int64         `json:"BuffersCheckpoint"`
+}
+
+// OSMemory holds the Linux memory settings of the server host, read from
+// /proc and /sys on local runs.
+type OSMemory struct {
+	THPEnabled        string `json:"THPEnabled"` // transparent huge pages: always, madvise or never
+	THPDefrag         string `json:"THPDefrag"`
+	OvercommitMemory  int    `json:"OvercommitMemory"` // vm.overcommit_memory: 0 heuristic, 1 always, 2 strict
+	OvercommitRatio   int    `json:"OvercommitRatio"`  // vm.overcommit_ratio (percent of RAM with strict overcommit)
+	Swappiness        int    `json:"Swappiness"`       // vm.swappiness
+	MemTotalBytes     int64  `json:"MemTotalBytes"`
+	HugePagesTotal    int64  `json:"HugePagesTotal"` // vm.nr_hugepages
+	HugePagesFree     int64  `json:"HugePagesFree"`
+	HugePageSizeBytes int64  `json:"HugePageSizeBytes"`
+
+	CgroupMemoryLimitBytes int64 `json:"CgroupMemoryLimitBytes"` // memory limit of the server's cgroup, e.g. a container (0 = none)
+}
+
+// OSCPU holds the CPU and NUMA layout and cgroup limits of the database
+// host, read from /proc and /sys with Config.LocalOS.
+type OSCPU struct {
+	CPUs             int        `json:"CPUs"`
+	CPUQuota         float64    `json:"CPUQuota"`         // cgroup CPU limit in CPUs (0 = none)
+	MemoryLimitBytes int64      `json:"MemoryLimitBytes"` // cgroup memory limit (0 = none)
+	NUMANodes        []NUMANode `json:"NUMANodes"`
+	ZoneReclaimMode  int        `json:"ZoneReclaimMode"` // vm.zone_reclaim_mode
+}
+
+// Template1 describes template1, which CREATE DATABASE copies by default.
+type Template1 struct {
+	Encoding string `json:"Encoding"`
+	Collate  string `json:"Collate"`
+	Ctype    string `json:"Ctype"`
+
+	// Objects are the user tables, views, sequences and functions as
+	// "kind schema.name", and Extensions the extensions besides plpgsql;
+	// both empty when template1 does not accept connections.
+	Objects    []string `json:"Objects"`
+	Extensions []string `json:"Extensions"`
+}
+
+// DefaultPrivilege is an ALTER DEFAULT PRIVILEGES entry: the grants objects
+// of a type get when Role creates them in Schema (every schema when empty).
+type DefaultPrivilege struct {
+	Role       string `json:"Role"`
+	Schema     string `json:"Schema"`
+	ObjectType string `json:"ObjectType"` // tables, sequences, functions, types or schemas
+	ACL        string `json:"ACL"`        // aclitems, e.g. reporting=r/app,=r/app for PUBLIC
+}
+
+// Public reports whether the entry grants to PUBLIC.
+func (d DefaultPrivilege) Public() bool {
+	for _, item := range strings.Split(d.ACL, ",") {
+		if strings.HasPrefix(item, "=") {
+			return true
+		}
+	}
+	return false
+}
+
+// DataDirectory describes where and how the cluster stores its data, for the
+// storage items of security questionnaires.
+type DataDirectory struct {
+	Path      string `json:"Path"`      // data_directory; empty unless superuser or pg_read_all_settings
+	WALPath   string `json:"WALPath"`   // directory pg_wal resolves to; empty unless the server runs on this machine
+	Checksums bool   `json:"Checksums"` // data_checksums
+
+	// Managed is the managed service hosting the cluster, recognized by its
+	// custom settings, e.g. "Amazon RDS"; empty when self-hosted or unknown.
+	Managed string `json:"Managed"`
+
+	// Encryption is how data is encrypted at rest when derivable: by the
+	// provider of a managed service, or a TDE module; empty when unknown, as
+	// disk and filesystem encryption are invisible to PostgreSQL.
+	Encryption string `json:"Encryption"`
+}
+
+// WALSeparate reports whether pg_wal is a link out of the data directory,
+// typically to a separate volume.
+func (d DataDirectory) WALSeparate() bool {
+	return d.WALPath != "" && d.Path != "" && d.WALPath != filepath.Join(d.Path, "pg_wal")
+}
+
+// NUMANode is one NUMA node of the host.
+type NUMANode struct {
+	ID            int   `json:"ID"`
+	CPUs          int   `json:"CPUs"`
+	MemTotalBytes int64 `json:"MemTotalBytes"`
+}
+
+// EffectiveCPUs is the CPU count, capped by the cgroup CPU quota.
+func (c OSCPU) EffectiveCPUs() float64 {
+	if c.CPUQuota > 0 && c.CPUQuota < float64(c.CPUs) {
+		return c.CPUQuota
+	}
+	return float64(c.CPUs)
 }
 
 type MemoryStats struct {
 	// Config and runtime metrics
-	SharedBuffersUsed      int64 // buffers allocated since start (approx), from bgwriter
-	SharedBuffersTotal     int64 // buffers total allocated (approx), from bgwriter
-	SharedBuffersSetting   int64 // configured shared_buffers (number of buffers)
-	SharedBuffersBytes     int64 // configured shared_buffers in bytes
-	BlockSizeBytes         int64 // PostgreSQL block size in bytes
-	BuffercacheAvailable   bool  // whether pg_buffercache is available
-	BuffercacheUsedBuffers int64 // current number of buffers in use (from pg_buffercache)
-	BuffercacheUsedBytes   int64 // BuffercacheUsedBuffers * BlockSizeBytes
-	TempBytesCurrentDB     int64 // pg_stat_database.temp_bytes for current DB
-	TempFilesCurrentDB     int64 // pg_stat_database.temp_files for current DB
-	WorkMemUsed            int64 // placeholder (not available without sampling)
-	MaintenanceWorkMem     int64 // placeholder (not available without sampling)
-	TempBuffersUsed        int64 // placeholder
-	LocalBuffersUsed       int64 // placeholder
+	SharedBuffersUsed      int64 `json:"SharedBuffersUsed"`      // buffers allocated since start (approx), from bgwriter
+	SharedBuffersTotal     int64 `json:"SharedBuffersTotal"`     // buffers total allocated (approx), from bgwriter
+	SharedBuffersSetting   int64 `json:"SharedBuffersSetting"`   // configured shared_buffers (number of buffers)
+	SharedBuffersBytes     int64 `json:"SharedBuffersBytes"`     // configured shared_buffers in bytes
+	BlockSizeBytes         int64 `json:"BlockSizeBytes"`         // PostgreSQL block size in bytes
+	BuffercacheAvailable   bool  `json:"BuffercacheAvailable"`   // whether pg_buffercache is available
+	BuffercacheUsedBuffers int64 `json:"BuffercacheUsedBuffers"` // current number of buffers in use (from pg_buffercache)
+	BuffercacheUsedBytes   int64 `json:"BuffercacheUsedBytes"`   // BuffercacheUsedBuffers * BlockSizeBytes
+	TempBytesCurrentDB     int64 `json:"TempBytesCurrentDB"`     // pg_stat_database.temp_bytes for current DB
+	TempFilesCurrentDB     int64 `json:"TempFilesCurrentDB"`     // pg_stat_database.temp_files for current DB
+	WorkMemUsed            int64 `json:"WorkMemUsed"`            // placeholder (not available without sampling)
+	MaintenanceWorkMem     int64 `json:"MaintenanceWorkMem"`     // placeholder (not available without sampling)
+	TempBuffersUsed        int64 `json:"TempBuffersUsed"`        // placeholder
+	LocalBuffersUsed       int64 `json:"LocalBuffersUsed"`       // placeholder
 }
 
 type IOStats struct {
-	HeapBlksRead  int64
-	HeapBlksHit   int64
-	IdxBlksRead   int64
-	IdxBlksHit    int64
-	ToastBlksRead int64
-	ToastBlksHit  int64
-	TidxBlksRead  int64
-	TidxBlksHit   int64
-	ReadTime      time.Duration
-	WriteTime     time.Duration
+	HeapBlksRead  int64         `json:"HeapBlksRead"`
+	HeapBlksHit   int64         `json:"HeapBlksHit"`
+	IdxBlksRead   int64         `json:"IdxBlksRead"`
+	IdxBlksHit    int64         `json:"IdxBlksHit"`
+	ToastBlksRead int64         `json:"ToastBlksRead"`
+	ToastBlksHit  int64         `json:"ToastBlksHit"`
+	TidxBlksRead  int64         `json:"TidxBlksRead"`
+	TidxBlksHit   int64         `json:"TidxBlksHit"`
+	ReadTime      time.Duration `json:"ReadTime"`
+	WriteTime     time.Duration `json:"WriteTime"`
 }
 
 type LockStat struct {
-	LockType    string
-	Mode        string
-	Granted     bool
-	Count       int
-	WaitingPIDs []int
+	LockType    string `json:"LockType"`
+	Mode        string `json:"Mode"`
+	Granted     bool   `json:"Granted"`
+	Count       int    `json:"Count"`
+	WaitingPIDs []int  `json:"WaitingPIDs"`
+}
+
+// LockHotspot aggregates the locks held and awaited on one table.
+type LockHotspot struct {
+	Schema          string  `json:"Schema"`
+	Table           string  `json:"Table"`
+	Granted         int     `json:"Granted"`
+	Waiting         int     `json:"Waiting"`
+	TupleLocks      int     `json:"TupleLocks"`      // sessions queued for specific rows
+	HotRows         int     `json:"HotRows"`         // distinct rows with queued sessions
+	StrongLocks     int     `json:"StrongLocks"`     // granted table-level locks that conflict with writes
+	LongestWaitSecs float64 `json:"LongestWaitSecs"` // longest wait among waiters, by state change
+	SampleQuery     string  `json:"SampleQuery"`     // earliest waiting statement
+}
+
+// LongestWait is LongestWaitSecs as a duration.
+func (h LockHotspot) LongestWait() time.Duration {
+	return time.Duration(h.LongestWaitSecs * float64(time.Second))
 }
 
 type TempFileStat struct {
-	Datname string
-	PID     int
-	Files   int64
-	Bytes   int64
+	Datname string `json:"Datname"`
+	PID     int    `json:"PID"`
+	Files   int64  `json:"Files"`
+	Bytes   int64  `json:"Bytes"`
 }
 
 type ExtensionStat struct {
-	Database    string
-	Name        string
-	Version     string
-	Description string
-	Schema      string
+	Database    string `json:"Database"`
+	Name        string `json:"Name"`
+	Version     string `json:"Version"`
+	Description string `json:"Description"`
+	Schema      string `json:"Schema"`
 }
 
 // MemoryContext represents a row from pg_backend_memory_contexts for the current backend
 type MemoryContext struct {
-	Name       string
-	Ident      string
-	Parent     string
-	Level      int
-	TotalBytes int64
-	FreeBytes  int64
-	UsedBytes  int64
-	NBlocks    int64
-	FreeChunks int64
+	Name       string `json:"Name"`
+	Ident      string `json:"Ident"`
+	Parent     string `json:"Parent"`
+	Level      int    `json:"Level"`
+	TotalBytes int64  `json:"TotalBytes"`
+	FreeBytes  int64  `json:"FreeBytes"`
+	UsedBytes  int64  `json:"UsedBytes"`
+	NBlocks    int64  `json:"NBlocks"`
+	FreeChunks int64  `json:"FreeChunks"`
 }
 
 // WaitEventStat summarizes waits from pg_stat_activity
 type WaitEventStat struct {
-	Type  string
-	Event string
-	Count int
+	Type  string `json:"Type"`
+	Event string `json:"Event"`
+	Count int    `json:"Count"`
 }
 
 // FunctionStat from pg_stat_user_functions
 type FunctionStat struct {
-	Schema    string
-	Name      string
-	Calls     int64
-	TotalTime float64
-	SelfTime  float64
+	Schema    string  `json:"Schema"`
+	Name      string  `json:"Name"`
+	Calls     int64   `json:"Calls"`
+	TotalTime float64 `json:"TotalTime"`
+	SelfTime  float64 `json:"SelfTime"`
 }
 
 // WALStat from pg_stat_wal
 type WALStat struct {
-	Records    int64
-	FullPage   int64
-	Bytes      int64
-	StatsReset time.Time
+	Records    int64     `json:"Records"`
+	FullPage   int64     `json:"FullPage"`
+	Bytes      int64     `json:"Bytes"`
+	StatsReset time.Time `json:"StatsReset"`
 }
 
 // ProgressCreateIndex from pg_stat_progress_create_index
 type ProgressCreateIndex struct {
-	Datname      string
-	Relation     string
-	Phase        string
-	BlocksDone   int64
-	BlocksTotal  int64
-	TuplesDone   int64
-	TuplesTotal  int64
-	LockersDone  int64
-	LockersTotal int64
+	Datname      string `json:"Datname"`
+	Relation     string `json:"Relation"`
+	Phase        string `json:"Phase"`
+	BlocksDone   int64  `json:"BlocksDone"`
+	BlocksTotal  int64  `json:"BlocksTotal"`
+	TuplesDone   int64  `json:"TuplesDone"`
+	TuplesTotal  int64  `json:"TuplesTotal"`
+	LockersDone  int64  `json:"LockersDone"`
+	LockersTotal int64  `json:"LockersTotal"`
 }
 
 // ProgressAnalyze from pg_stat_progress_analyze
 type ProgressAnalyze struct {
-	Datname     string
-	Relation    string
-	Phase       string
-	SampleScans int64
-	SampleTotal int64
+	Datname     string `json:"Datname"`
+	Relation    string `json:"Relation"`
+	Phase       string `json:"Phase"`
+	SampleScans int64  `json:"SampleScans"`
+	SampleTotal int64  `json:"SampleTotal"`
 }
 
-// DatabaseXIDAge tracks transaction ID age for wraparound risk assessment
-type DatabaseXIDAge struct {
-	Datname    string
-	Age        int64   // age(datfrozenxid)
-	PctToLimit float64 // percentage toward 2^31 wraparound
-	FrozenXID  int64   // datfrozenxid value
-	MinMXID    int64   // datminmxid for multixact
-	MinMXIDAge int64   // age of oldest multixact
+// ProgressCopy from pg_stat_progress_copy
+type ProgressCopy struct {
+	Datname         string  `json:"Datname"`
+	PID             int     `json:"PID"`
+	Relation        string  `json:"Relation"` // empty for COPY (query) TO
+	Command         string  `json:"Command"`  // COPY FROM or COPY TO
+	Type            string  `json:"Type"`     // FILE, PROGRAM, PIPE or CALLBACK
+	BytesProcessed  int64   `json:"BytesProcessed"`
+	BytesTotal      int64   `json:"BytesTotal"` // size of the source file; 0 when unknown (PIPE, PROGRAM)
+	TuplesProcessed int64   `json:"TuplesProcessed"`
+	TuplesExcluded  int64   `json:"TuplesExcluded"` // rows filtered out by a WHERE clause
+	Seconds         float64 `json:"Seconds"`
 }
 
-// IdleInTransaction tracks sessions stuck in idle-in-transaction state
-type IdleInTransaction struct {
-	Datname     string
-	PID         int
-	User        string
-	Application string
-	Duration    string
-	Query       string // last query before going idle
-	WaitEvent   string
+// Progress is the share of bytes processed in percent, or 0 when the total
+// is unknown.
+func (p ProgressCopy) Progress() float64 { return progressPct(p.BytesProcessed, p.BytesTotal) }
+
+// ProgressCluster from pg_stat_progress_cluster: CLUSTER and VACUUM FULL
+// rewrite a table under an ACCESS EXCLUSIVE lock.
+type ProgressCluster struct {
+	Datname           string  `json:"Datname"`
+	PID               int     `json:"PID"`
+	Relation          string  `json:"Relation"`
+	Command           string  `json:"Command"` // CLUSTER or VACUUM FULL
+	Phase             string  `json:"Phase"`
+	HeapBlksScanned   int64   `json:"HeapBlksScanned"`
+	HeapBlksTotal     int64   `json:"HeapBlksTotal"`
+	HeapTuplesWritten int64   `json:"HeapTuplesWritten"`
+	IndexRebuilds     int64   `json:"IndexRebuilds"`
+	Seconds           float64 `json:"Seconds"`
 }
 
-// StaleStatsTable tracks tables with outdated statistics
-type StaleStatsTable struct {
-	Schema           string
-	Table            string
-	RowEstimate      int64
-	LastAnalyze      *time.Time
-	LastAutoAnalyze  *time.Time
-	ModsSinceAnalyze int64
-	DaysSinceAnalyze int
+// Progress is the share of heap blocks scanned in percent; index scans and
+// sorts report no block totals, so it stays 0 for them.
+func (p ProgressCluster) Progress() float64 { return progressPct(p.HeapBlksScanned, p.HeapBlksTotal) }
+
+// ProgressBasebackup from pg_stat_progress_basebackup
+type ProgressBasebackup struct {
+	PID                 int     `json:"PID"`
+	Client              string  `json:"Client"` // application_name, or the client address
+	Phase               string  `json:"Phase"`
+	BytesStreamed       int64   `json:"BytesStreamed"`
+	BytesTotal          int64   `json:"BytesTotal"` // estimate; 0 when the backup runs with --no-estimate-size
+	TablespacesStreamed int64   `json:"TablespacesStreamed"`
+	TablespacesTotal    int64   `json:"TablespacesTotal"`
+	Seconds             float64 `json:"Seconds"`
 }
 
-// DuplicateIndex identifies indexes with redundant column definitions
-type DuplicateIndex struct {
-	Schema      string
-	Table       string
-	Index1      string
-	Index2      string
-	Columns     string
-	Index1Size  int64
-	Index2Size  int64
-	Index1Scans int64
-	Index2Scans int64
+// Progress is the share of the estimated size streamed in percent, or 0
+// without an estimate.
+func (p ProgressBasebackup) Progress() float64 { return progressPct(p.BytesStreamed, p.BytesTotal) }
+
+// progressPct is done out of total in percent, capped at 100; 0 when total is
+// unknown.
+func progressPct(done, total int64) float64 {
+	if total <= 0 {
+		return 0
+	}
+	return min(float64(done)/float64(total)*100, 100)
 }
 
-// InvalidIndex identifies indexes that failed to build
-type InvalidIndex struct {
-	Schema    string
-	Table     string
-	Name      string
-	SizeBytes int64
-	DDL       string
-	Reason    string // "invalid" or "not ready"
+// XIDClock is a reading of the XID counter: the next XID to be assigned
+// (epoch-extended, so it does not wrap) and the server time it was read at.
+type XIDClock struct {
+	NextXID int64     `json:"NextXID"`
+	At      time.Time `json:"At"`
 }
 
-// FKMissingIndex identifies foreign keys without supporting indexes
-type FKMissingIndex struct {
-	Schema       string
-	Table        string
-	Constraint   string
-	Columns      string
-	RefTable     string
-	RefColumns   string
-	TableRows    int64
-	SuggestedDDL string
+// XIDRate is the XID consumption between two XID counter readings.
+type XIDRate struct {
+	From time.Time `json:"From"`
+	To   time.Time `json:"To"`
+	XIDs int64     `json:"XIDs"`
 }
 
-// SequenceHealth tracks sequences approaching exhaustion
-type SequenceHealth struct {
-	Schema    string
-	Name      string
-	LastValue int64
-	MaxValue  int64
-	Increment int64
-	PctUsed   float64
-	CallsLeft int64 // remaining increments before exhaustion
+// NewXIDRate measures the XIDs consumed from one reading to a later one. It
+// reports false when the readings are out of order or the counter went back
+// (e.g. a restored cluster).
+func NewXIDRate(from, to XIDClock) (XIDRate, bool) {
+	if !to.At.After(from.At) || to.NextXID < from.NextXID {
+		return XIDRate{}, false
+	}
+	return XIDRate{From: from.At, To: to.At, XIDs: to.NextXID - from.NextXID}, true
 }
 
-// PreparedXact tracks prepared (2PC) transactions that may be orphaned
-type PreparedXact struct {
-	Transaction string
-	GID         string
-	Owner       string
-	Database    string
-	Prepared    time.Time
-	Age         string // duration since prepared
+// PerHour is the consumption in XIDs per hour.
+func (x XIDRate) PerHour() float64 {
+	h := x.To.Sub(x.From).Hours()
+	if h <= 0 {
+		return 0
+	}
+	return float64(x.XIDs) / h
 }
 
-func Run(ctx context.Context, cfg Config) (Result, error) {
-	var res Result
+// SizePoint is the size of a database or table at some time.
+type SizePoint struct {
+	At    time.Time
+	Bytes int64
+}
 
-	conn, err := pgx.Connect(ctx, cfg.URL)
-	if err != nil {
-		return res, err
+// SizeGrowth is the growth of a database, table or index fitted over archived
+// runs and the current one.
+type SizeGrowth struct {
+	Database    string  `json:"Database"`
+	Schema      string  `json:"Schema"`      // empty for a database
+	Name        string  `json:"Name"`        // table or index name; empty for a database
+	Index       bool    `json:"Index"`       // Name is an index
+	Bytes       int64   `json:"Bytes"`       // current size
+	StartBytes  int64   `json:"StartBytes"`  // size at the earliest run fitted
+	BytesPerDay float64 `json:"BytesPerDay"` // least-squares slope of the size over time; negative when shrinking
+	Runs        int     `json:"Runs"`        // sizes fitted, the current one included
+	Days        float64 `json:"Days"`        // time covered by the fit
+}
+
+// Grown is the size change over the runs fitted.
+func (g SizeGrowth) Grown() int64 { return g.Bytes - g.StartBytes }
+
+// GrownPct is Grown as a percentage of the earliest size.
+func (g SizeGrowth) GrownPct() float64 {
+	if g.StartBytes <= 0 {
+		return 0
 	}
-	defer conn.Close(ctx)
+	return float64(g.Grown()) / float64(g.StartBytes) * 100
+}
 
-	// basic info
-	_ = queryRow(ctx, conn, `select version()`, &res.ConnInfo.Version)
-	_ = queryRow(ctx, conn, `select current_database()`, &res.ConnInfo.CurrentDB)
-	_ = queryRow(ctx, conn, `select current_user`, &res.ConnInfo.CurrentUser)
-	_ = queryRow(ctx, conn, `select setting::int from pg_settings where name='max_connections'`, &res.ConnInfo.MaxConnections)
-	_ = queryRow(ctx, conn, `show ssl`, &res.ConnInfo.SSL)
-	_ = queryRow(ctx, conn, `select pg_postmaster_start_time()`, &res.ConnInfo.StartTime)
+// IsDatabase reports whether the growth is of a whole database.
+func (g SizeGrowth) IsDatabase() bool { return g.Name == "" }
+
+// FitGrowth fits a straight line through the sizes of one object, in any
+// order, the last one being current. It reports false with fewer than two
+// sizes at distinct times.
+func FitGrowth(points []SizePoint) (SizeGrowth, bool) {
+	at := make([]time.Time, len(points))
+	v := make([]float64, len(points))
+	for i, p := range points {
+		at[i], v[i] = p.At, float64(p.Bytes)
+	}
+	slope, days, ok := fitLine(at, v)
+	if !ok {
+		return SizeGrowth{}, false
+	}
+	first := points[0]
+	for _, p := range points[1:] {
+		if p.At.Before(first.At) {
+			first = p
+		}
+	}
+	return SizeGrowth{
+		Bytes:       points[len(points)-1].Bytes,
+		StartBytes:  first.Bytes,
+		BytesPerDay: slope,
+		Runs:        len(points),
+		Days:        days,
+	}, true
+}
 
-	// Is superuser
-	_ = queryRow(ctx, conn, `select rolsuper from pg_roles where rolname = current_user`, &res.ConnInfo.IsSuperuser)
+// ConnectionPoint is the client connections of an application, or of all
+// clients, at some time.
+type ConnectionPoint struct {
+	At    time.Time
+	Count int
+	Busy  int // not idle: running a statement or inside a transaction
+}
 
-	// role membership (pg_monitor)
-	var hasMonitor bool
-	_ = queryRow(ctx, conn, `select exists(select 1 from pg_auth_members m join pg_roles r on r.oid=m.roleid where r.rolname='pg_monitor' and m.member=(select oid from pg_roles where rolname=current_user))`, &hasMonitor)
-	res.Roles.HasPgMonitor = hasMonitor
+// ConnectionTrend is the client connections of an application, or of all
+// clients, over archived runs and the current one.
+type ConnectionTrend struct {
+	Application string    `json:"Application"` // application_name; empty for all clients or clients setting none
+	Current     int       `json:"Current"`
+	Peak        int       `json:"Peak"` // most connections in a run
+	PeakAt      time.Time `json:"PeakAt"`
+	PeakBusy    int       `json:"PeakBusy"` // most non-idle connections in a run
+	PerDay      float64   `json:"PerDay"`   // least-squares slope of the connections over time; 0 with a single run
+	Runs        int       `json:"Runs"`
+	Days        float64   `json:"Days"` // time covered by the runs
+}
 
-	// extensions - robust detection and schema resolution
-	res.Extensions.PgStatStatements = hasPgStatStatements(ctx, conn)
-	if res.Extensions.PgStatStatements {
-		res.Extensions.PgStatStatementsSchema = findPgStatStatementsSchema(ctx, conn)
-	}
+// ConnectionHistory is the client connections over archived runs and the
+// current one.
+type ConnectionHistory struct {
+	Total ConnectionTrend   `json:"Total"`
+	Apps  []ConnectionTrend `json:"Apps"` // per application_name, highest peak first
+}
 
-	// activity counts by state
-	rows, err := conn.Query(ctx, `select datname, coalesce(state,'unknown') as state, count(*) from pg_stat_activity group by 1,2 order by 1,2`)
-	if err == nil {
-		for rows.Next() {
-			var a Activity
-			_ = rows.Scan(&a.Datname, &a.State, &a.Count)
-			res.Activity = append(res.Activity, a)
+// FitConnections summarizes the connections of one application, or of all
+// clients, in any order, the last one being current.
+func FitConnections(points []ConnectionPoint) ConnectionTrend {
+	var tr ConnectionTrend
+	if len(points) == 0 {
+		return tr
+	}
+	at := make([]time.Time, len(points))
+	v := make([]float64, len(points))
+	for i, p := range points {
+		at[i], v[i] = p.At, float64(p.Count)
+		if i == 0 || p.Count > tr.Peak {
+			tr.Peak, tr.PeakAt = p.Count, p.At
+		}
+		tr.PeakBusy = max(tr.PeakBusy, p.Busy)
+	}
+	tr.Current = points[len(points)-1].Count
+	tr.Runs = len(points)
+	tr.PerDay, tr.Days, _ = fitLine(at, v)
+	return tr
+}
+
+// fitLine fits a least-squares line through the values v measured at at,
+// returning its slope per day and the days covered. It reports false with
+// fewer than two values at distinct times.
+func fitLine(at []time.Time, v []float64) (slope, days float64, ok bool) {
+	if len(at) < 2 {
+		return 0, 0, false
+	}
+	first, last := at[0], at[0]
+	for _, t := range at {
+		if t.Before(first) {
+			first = t
 		}
-		rows.Close()
+		if t.After(last) {
+			last = t
+		}
+	}
+	days = last.Sub(first).Hours() / 24
+	if days <= 0 {
+		return 0, 0, false
 	}
+	var sx, sy, sxx, sxy float64
+	n := float64(len(at))
+	for i, t := range at {
+		x := t.Sub(first).Hours() / 24
+		sx += x
+		sy += v[i]
+		sxx += x * x
+		sxy += x * v[i]
+	}
+	return (n*sxy - sx*sy) / (n*sxx - sx*sx), days, true
+}
 
-	// databases size and connections
-	rows, err = conn.Query(ctx, `select d.datname, pg_database_size(d.datname), coalesce(t.spcname,'pg_default'), coalesce(a.cnt,0)
-        from pg_database d
-        left join pg_tablespace t on t.oid = d.dattablespace
-        left join (select datname, count(*) cnt from pg_stat_activity group by 1) a on a.datname = d.datname
-        where not d.datistemplate
-        order by pg_database_size(d.datname) desc`)
-	if err == nil {
-		for rows.Next() {
-			var db Database
-			_ = rows.Scan(&db.Name, &db.SizeBytes, &db.Tablespaces, &db.ConnCount)
-			res.DBs = append(res.DBs, db)
-		}
-		rows.Close()
+// StatementDelta is the work a statement did between an archived run and the
+// current one.
+type StatementDelta struct {
+	QueryID         int64   `json:"QueryID"`
+	Query           string  `json:"Query"`
+	Calls           float64 `json:"Calls"`
+	TotalTime       float64 `json:"TotalTime"` // ms
+	Rows            float64 `json:"Rows"`
+	SharedBlksRead  float64 `json:"SharedBlksRead"`
+	SharedBlksWrite float64 `json:"SharedBlksWrite"`
+	TempBlksWrite   float64 `json:"TempBlksWrite"`
+	IOTime          float64 `json:"IOTime"` // ms reading and writing blocks
+	Reset           bool    `json:"Reset"`  // counters went back (evicted or reset), so the totals since then are counted
+}
+
+// MeanTime is the average execution time over the window.
+func (d StatementDelta) MeanTime() float64 {
+	if d.Calls <= 0 {
+		return 0
 	}
+	return d.TotalTime / d.Calls
+}
 
-	// settings of interest (subset)
-	rows, err = conn.Query(ctx, `select name, setting, unit, source from pg_settings where name in (
-		'shared_buffers','work_mem','maintenance_work_mem','effective_cache_size','max_connections','max_parallel_workers','wal_buffers','wal_level','max_wal_size','checkpoint_timeout','random_page_cost','seq_page_cost','effective_io_concurrency','autovacuum','autovacuum_naptime','track_io_timing','track_functions') order by name`)
-	if err == nil {
-		for rows.Next() {
-			var s Setting
-			_ = rows.Scan(&s.Name, &s.Val, &s.Unit, &s.Source)
-			res.Settings = append(res.Settings, s)
-		}
-		rows.Close()
+// StatementWindow is the pg_stat_statements load between an archived run and
+// the current one, rather than since the statistics were last reset.
+type StatementWindow struct {
+	From       time.Time        `json:"From"` // start of the archived run compared with
+	To         time.Time        `json:"To"`
+	Reset      bool             `json:"Reset"`      // statistics were reset after From; counts cover the time since the reset
+	Calls      float64          `json:"Calls"`      // across all compared statements, before the list is capped
+	TotalTime  float64          `json:"TotalTime"`  // ms, across all compared statements
+	Statements []StatementDelta `json:"Statements"` // most time first
+}
+
+// Duration is the time the window covers.
+func (w StatementWindow) Duration() time.Duration { return w.To.Sub(w.From) }
+
+// PerHour converts a count over the window into a rate per hour.
+func (w StatementWindow) PerHour(v float64) float64 {
+	h := w.Duration().Hours()
+	if h <= 0 {
+		return 0
 	}
+	return v / h
+}
 
-	// table stats (exclude system schemas) with table size
-	rows, err = conn.Query(ctx, `select schemaname, relname, seq_scan, idx_scan, n_live_tup, n_dead_tup,
-				pg_total_relation_size(format('%I.%I', schemaname, relname)) as size_bytes
-				from pg_stat_all_tables
-				where schemaname not in ('pg_catalog','information_schema')
-					and schemaname not like 'pg_toast%'
-					and schemaname not like 'pg_temp_%'`)
-	if err == nil {
-		for rows.Next() {
-			var t TableStat
-			_ = rows.Scan(&t.Schema, &t.Name, &t.SeqScans, &t.IdxScans, &t.NLiveTup, &t.NDeadTup, &t.SizeBytes)
-			t.Database = res.ConnInfo.CurrentDB
-			// rough bloat heuristic
-			if t.NLiveTup > 0 {
-				t.BloatPct = float64(t.NDeadTup) / float64(t.NLiveTup+t.NDeadTup) * 100
+// PerSecond converts a count over the window into a rate per second.
+func (w StatementWindow) PerSecond(v float64) float64 { return w.PerHour(v) / 3600 }
+
+// NewStatementWindow subtracts the statements archived by a run started at
+// from from the current ones read at to, keeping the limit statements that
+// took the most time. Statements missing from the archived top lists are left
+// out since their earlier counters are unknown; statements whose counters
+// went back, or all of them after a statistics reset, count their current
+// totals. It returns nil when no statement ran in the window.
+func NewStatementWindow(base []Statement, from time.Time, cur Statements, to time.Time, limit int) *StatementWindow {
+	if !to.After(from) {
+		return nil
+	}
+	w := &StatementWindow{From: from, To: to, Reset: cur.StatsResetTime.After(from)}
+	byKey := make(map[string]Statement, len(base))
+	byText := make(map[string]Statement, len(base))
+	for _, b := range base {
+		byKey[b.Key()] = b
+		byText[b.Query] = b
+	}
+	for _, st := range cur.Unique() {
+		d := StatementDelta{
+			QueryID: st.QueryID, Query: st.Query, Calls: st.Calls, TotalTime: st.TotalTime, Rows: st.Rows,
+			SharedBlksRead: st.SharedBlksRead, SharedBlksWrite: st.SharedBlksWrite, TempBlksWrite: st.TempBlksWrite,
+			IOTime: st.IOTime, Reset: w.Reset,
+		}
+		if !w.Reset {
+			// Archives written before queryid was collected only match by text
+			b, ok := byKey[st.Key()]
+			if !ok {
+				b, ok = byText[st.Query]
 			}
-			res.Tables = append(res.Tables, t)
-		}
-		rows.Close()
-		// Backfill any missing user tables from pg_class for coverage
-		present := make(map[string]struct{}, len(res.Tables))
-		for _, t := range res.Tables {
-			if t.Database == res.ConnInfo.CurrentDB {
-				present[t.Schema+"."+t.Name] = struct{}{}
-			}
-		}
-		if rows2, err2 := conn.Query(ctx, `select n.nspname as schemaname,
-				c.relname,
-				coalesce(c.reltuples::bigint, 0) as n_live_tup,
-				pg_total_relation_size(c.oid) as size_bytes
-			from pg_class c
-			join pg_namespace n on n.oid = c.relnamespace
-			where c.relkind in ('r','m','p')
-			  and n.nspname not in ('pg_catalog','information_schema')
-			  and n.nspname not like 'pg_toast%'
-			  and n.nspname not like 'pg_temp_%'`); err2 == nil {
-			for rows2.Next() {
-				var schema, name string
-				var nlive, size int64
-				_ = rows2.Scan(&schema, &name, &nlive, &size)
-				key := schema + "." + name
-				if _, ok := present[key]; ok {
-					continue
-				}
-				res.Tables = append(res.Tables, TableStat{Database: res.ConnInfo.CurrentDB, Schema: schema, Name: name, SeqScans: 0, IdxScans: 0, NLiveTup: nlive, NDeadTup: 0, SizeBytes: size})
+			if !ok {
+				continue
 			}
-			rows2.Close()
-		}
-	}
-
-	// Fallback: if no rows (permissions or empty stats), derive from pg_class/pg_namespace
-	if len(res.Tables) == 0 {
-		if rows, err := conn.Query(ctx, `select n.nspname as schemaname,
-				c.relname,
-				0::bigint as seq_scan,
-				0::bigint as idx_scan,
-				coalesce(c.reltuples::bigint, 0) as n_live_tup,
-				0::bigint as n_dead_tup,
-				pg_total_relation_size(c.oid) as size_bytes
-			from pg_class c
-			join pg_namespace n on n.oid = c.relnamespace
-			where c.relkind in ('r','m','p')
-			  and n.nspname not in ('pg_catalog','information_schema')
-			  and n.nspname not like 'pg_toast%'
-			  and n.nspname not like 'pg_temp_%'
-			order by size_bytes desc
-			limit 1000`); err == nil {
-			for rows.Next() {
-				var t TableStat
-				_ = rows.Scan(&t.Schema, &t.Name, &t.SeqScans, &t.IdxScans, &t.NLiveTup, &t.NDeadTup, &t.SizeBytes)
-				t.Database = res.ConnInfo.CurrentDB
-				res.Tables = append(res.Tables, t)
+			if st.Calls >= b.Calls && st.TotalTime >= b.TotalTime {
+				d.Calls -= b.Calls
+				d.TotalTime -= b.TotalTime
+				d.Rows = max(d.Rows-b.Rows, 0)
+				d.SharedBlksRead = max(d.SharedBlksRead-b.SharedBlksRead, 0)
+				d.SharedBlksWrite = max(d.SharedBlksWrite-b.SharedBlksWrite, 0)
+				d.TempBlksWrite = max(d.TempBlksWrite-b.TempBlksWrite, 0)
+				d.IOTime = max(d.IOTime-b.IOTime, 0)
+			} else {
+				d.Reset = true
 			}
-			rows.Close()
 		}
-	}
-
-	// index stats and size
-	rows, err = conn.Query(ctx, `select s.schemaname, s.relname, s.indexrelname, s.idx_scan,
-		pg_relation_size(format('%I.%I', s.schemaname, s.indexrelname)),
-		pg_get_indexdef(ci.oid)
-		from pg_stat_all_indexes s
-		join pg_class ci on ci.relname = s.indexrelname
-		join pg_namespace n on n.oid = ci.relnamespace and n.nspname = s.schemaname`)
-	if err == nil {
-		for rows.Next() {
-			var i IndexStat
-			_ = rows.Scan(&i.Schema, &i.Table, &i.Name, &i.Scans, &i.SizeBytes, &i.DDL)
-			i.Database = res.ConnInfo.CurrentDB
-			res.Indexes = append(res.Indexes, i)
+		if d.Calls <= 0 {
+			continue
 		}
-		rows.Close()
+		w.Calls += d.Calls
+		w.TotalTime += d.TotalTime
+		w.Statements = append(w.Statements, d)
+	}
+	if len(w.Statements) == 0 {
+		return nil
 	}
+	sort.SliceStable(w.Statements, func(i, j int) bool { return w.Statements[i].TotalTime > w.Statements[j].TotalTime })
+	if limit > 0 && len(w.Statements) > limit {
+		w.Statements = w.Statements[:limit]
+	}
+	return w
+}
 
-	// unused indexes (idx_scan=0 and size > some threshold)
-	for _, idx := range res.Indexes {
-		if idx.Scans == 0 && idx.SizeBytes > 8*1024*1024 { // >8MB
-			res.IndexUnused = append(res.IndexUnused, IndexUnused{Database: idx.Database, Schema: idx.Schema, Table: idx.Table, Name: idx.Name, SizeBytes: idx.SizeBytes})
-		}
+// DeltaStats is the activity between two readings of the cumulative
+// statistics counters at the start and the end of the run (-delta), free of
+// the ambiguity of counters accumulated since an unknown reset.
+type DeltaStats struct {
+	From        time.Time        `json:"From"`
+	To          time.Time        `json:"To"`
+	Databases   []DatabaseDelta  `json:"Databases"`   // most commits first
+	Checkpoints *CheckpointDelta `json:"Checkpoints"` // nil when the counters were unreadable or reset
+	WALBytes    int64            `json:"WALBytes"`
+	HasWAL      bool             `json:"HasWAL"`     // WALBytes was measured
+	Statements  *StatementWindow `json:"Statements"` // nil without pg_stat_statements or statements run
+}
+
+// Duration is the sampling interval.
+func (d DeltaStats) Duration() time.Duration { return d.To.Sub(d.From) }
+
+// PerSecond converts a count over the interval into a rate per second.
+func (d DeltaStats) PerSecond(v int64) float64 {
+	s := d.Duration().Seconds()
+	if s <= 0 {
+		return 0
 	}
+	return float64(v) / s
+}
 
-	// missing index hints (heuristic based on high seq_scan and low idx_scan)
-	for _, t := range res.Tables {
-		if t.SeqScans > 1000 && t.IdxScans < 100 { // simple heuristic
-			res.MissingIndexes = append(res.MissingIndexes, MissingIndexHint{Schema: t.Schema, Table: t.Name, Columns: "(unknown)", EstBenefit: "High (heuristic)"})
-		}
+// WALPerSecond is the WAL written per second over the interval.
+func (d DeltaStats) WALPerSecond() int64 { return int64(d.PerSecond(d.WALBytes)) }
+
+// DatabaseDelta is the pg_stat_database activity of a database.
+type DatabaseDelta struct {
+	Name        string `json:"Name"`
+	Commits     int64  `json:"Commits"`
+	Rollbacks   int64  `json:"Rollbacks"`
+	BlksRead    int64  `json:"BlksRead"`
+	BlksHit     int64  `json:"BlksHit"`
+	TupReturned int64  `json:"TupReturned"`
+	TupFetched  int64  `json:"TupFetched"`
+	TupInserted int64  `json:"TupInserted"`
+	TupUpdated  int64  `json:"TupUpdated"`
+	TupDeleted  int64  `json:"TupDeleted"`
+	TempBytes   int64  `json:"TempBytes"`
+	Deadlocks   int64  `json:"Deadlocks"`
+}
+
+// HitRatio is the buffer cache hit percentage; 0 without block reads.
+func (d DatabaseDelta) HitRatio() float64 {
+	if d.BlksRead+d.BlksHit == 0 {
+		return 0
 	}
+	return float64(d.BlksHit) / float64(d.BlksRead+d.BlksHit) * 100
+}
 
-	// If cfg.DBs provided, append per-DB tables/indexes by connecting to each DB
-	if len(cfg.DBs) > 0 {
-		baseURL := cfg.URL
-		for _, db := range cfg.DBs {
-			if db == "" || db == res.ConnInfo.CurrentDB {
-				continue
-			}
-			// Build URL for target DB by replacing current_database()
-			targetURL := baseURL
-			// naive replace: if path component exists, swap last segment; otherwise append
-			// This is a simple heuristic; for complex URLs, users should pass a URL to the target DB directly.
-			if i := strings.LastIndex(targetURL, "/"); i != -1 {
-				targetURL = targetURL[:i+1] + db
-			} else {
-				targetURL += "/" + db
-			}
-			ctxDB, cancelDB := context.WithTimeout(ctx, 10*time.Second)
-			dbConn, err := pgx.Connect(ctxDB, targetURL)
-			cancelDB()
-			if err != nil {
-				res.Errors = append(res.Errors, fmt.Sprintf("db '%s': %v", db, err))
-				continue
-			}
-			// Collect tables (exclude system schemas)
-			if rows, err := dbConn.Query(ctx, `select schemaname, relname, seq_scan, idx_scan, n_live_tup, n_dead_tup,
-								pg_total_relation_size(format('%I.%I', schemaname, relname)) as size_bytes
-								from pg_stat_all_tables
-								where schemaname not in ('pg_catalog','information_schema')
-									and schemaname not like 'pg_toast%'
-									and schemaname not like 'pg_temp_%'`); err == nil {
-				for rows.Next() {
-					var t TableStat
-					_ = rows.Scan(&t.Schema, &t.Name, &t.SeqScans, &t.IdxScans, &t.NLiveTup, &t.NDeadTup, &t.SizeBytes)
-					t.Database = db
-					if t.NLiveTup > 0 {
-						t.BloatPct = float64(t.NDeadTup) / float64(t.NLiveTup+t.NDeadTup) * 100
-					}
-					res.Tables = append(res.Tables, t)
-				}
-				rows.Close()
-			}
-			// Collect indexes
-			if rows, err := dbConn.Query(ctx, `select s.schemaname, s.relname, s.indexrelname, s.idx_scan,
-				pg_relation_size(format('%I.%I', s.schemaname, s.indexrelname)),
-				pg_get_indexdef(ci.oid)
-				from pg_stat_all_indexes s
-				join pg_class ci on ci.relname = s.indexrelname
-				join pg_namespace n on n.oid = ci.relnamespace and n.nspname = s.schemaname`); err == nil {
-				for rows.Next() {
-					var i IndexStat
-					_ = rows.Scan(&i.Schema, &i.Table, &i.Name, &i.Scans, &i.SizeBytes, &i.DDL)
-					i.Database = db
-					res.Indexes = append(res.Indexes, i)
-				}
-				rows.Close()
-			}
-			// Derive unused indexes for that DB
-			for _, idx := range res.Indexes {
-				if idx.Database == db && idx.Scans == 0 && idx.SizeBytes > 8*1024*1024 {
-					res.IndexUnused = append(res.IndexUnused, IndexUnused{Database: db, Schema: idx.Schema, Table: idx.Table, Name: idx.Name, SizeBytes: idx.SizeBytes})
-				}
-			}
+// CheckpointDelta is the checkpointer and background writer activity.
+type CheckpointDelta struct {
+	Timed             int64 `json:"Timed"`
+	Requested         int64 `json:"Requested"`
+	BuffersCheckpoint int64 `json:"BuffersCheckpoint"`
+	BuffersClean      int64 `json:"BuffersClean"`
+	BuffersBackend    int64 `json:"BuffersBackend"` // written by backends themselves; 0 from PostgreSQL 17
+	BuffersAlloc      int64 `json:"BuffersAlloc"`
+}
 
-			// Collect lowest index usage tables for that DB
-			{
-				q := `select schemaname, relname,
-					coalesce(100.0 * idx_scan / nullif(seq_scan + idx_scan, 0), 0.0) as index_usage_pct,
-					n_live_tup
-				  from pg_stat_user_tables
-				  where n_live_tup > 10000
-				  order by index_usage_pct asc nulls last
-				  limit 50`
-				if rows, err := dbConn.Query(ctx, q); err == nil {
-					for rows.Next() {
-						var iu IndexUsage
-						_ = rows.Scan(&iu.Schema, &iu.Table, &iu.IndexUsagePct, &iu.Rows)
-						iu.Database = db
-						res.IndexUsageLow = append(res.IndexUsageLow, iu)
-					}
-					rows.Close()
-				}
-			}
+// FreezeForecast predicts when autovacuum_freeze_max_age forces an
+// anti-wraparound autovacuum on the tables with the oldest relfrozenxid.
+type FreezeForecast struct {
+	XIDRate float64       `json:"XIDRate"` // XIDs consumed per hour (0 when idle or not measured)
+	Tables  []FreezeTable `json:"Tables"`  // closest to their limit first
+}
 
-			// Collect tables with index counts for that DB
-			if rows, err := dbConn.Query(ctx, `select t.schemaname, t.relname,
-				count(i.indexrelid) as index_count,
-				pg_total_relation_size(format('%I.%I', t.schemaname, t.relname)) as size_bytes,
-				t.n_live_tup,
-				t.n_dead_tup,
-				coalesce(100.0 * t.n_dead_tup / nullif(t.n_live_tup + t.n_dead_tup, 0), 0.0) as bloat_pct
-			from pg_stat_user_tables t
-			left join pg_stat_user_indexes i on i.schemaname = t.schemaname and i.relname = t.relname
-			group by t.schemaname, t.relname, t.n_live_tup, t.n_dead_tup
-			order by size_bytes desc
-			limit 100`); err == nil {
-				for rows.Next() {
-					var tic TableIndexCount
-					_ = rows.Scan(&tic.Schema, &tic.Name, &tic.IndexCount, &tic.SizeBytes, &tic.RowCount, &tic.DeadRows, &tic.BloatPct)
-					tic.Database = db
-					res.TablesWithIndexCount = append(res.TablesWithIndexCount, tic)
-				}
-				rows.Close()
-			}
-			dbConn.Close(ctx)
-		}
+// FreezeTable is a table's relfrozenxid age against its freeze age limit.
+type FreezeTable struct {
+	Schema       string `json:"Schema"`
+	Name         string `json:"Name"`
+	XIDAge       int64  `json:"XIDAge"`       // age(relfrozenxid), the older of the table and its TOAST table
+	FreezeMaxAge int64  `json:"FreezeMaxAge"` // effective autovacuum_freeze_max_age (storage parameter can only lower it)
+	SizeBytes    int64  `json:"SizeBytes"`
+}
+
+// Due reports whether an anti-wraparound autovacuum is already due.
+func (t FreezeTable) Due() bool { return t.XIDAge >= t.FreezeMaxAge }
+
+// DueIn forecasts the time until t reaches its freeze age limit at the
+// measured XID rate; 0 when already due or the rate is unknown.
+func (f FreezeForecast) DueIn(t FreezeTable) time.Duration {
+	if f.XIDRate <= 0 || t.Due() {
+		return 0
 	}
+	return time.Duration(float64(t.FreezeMaxAge-t.XIDAge) / f.XIDRate * float64(time.Hour))
+}
 
-	// pg_stat_statements if available
-	if res.Extensions.PgStatStatements {
-		// Get stats reset time
-		var statsReset time.Time
-		// Try pg_stat_statements_info first (PG13+)
-		err := queryRow(ctx, conn, `SELECT stats_reset FROM pg_stat_statements_info`, &statsReset)
-		if err != nil {
-			// Fallback to pg_stat_database for older versions
-			_ = queryRow(ctx, conn, `SELECT stats_reset FROM pg_stat_database WHERE datname = current_database()`, &statsReset)
-		}
-		res.Statements.StatsResetTime = statsReset
-		if !statsReset.IsZero() {
-			res.Statements.StatsDuration = time.Since(statsReset)
-		}
+// DatabaseXIDAge tracks transaction ID age for wraparound risk assessment
+type DatabaseXIDAge struct {
+	Datname    string  `json:"Datname"`
+	Age        int64   `json:"Age"`        // age(datfrozenxid)
+	PctToLimit float64 `json:"PctToLimit"` // percentage toward 2^31 wraparound
+	FrozenXID  int64   `json:"FrozenXID"`  // datfrozenxid value
+	MinMXID    int64   `json:"MinMXID"`    // datminmxid for multixact
+	MinMXIDAge int64   `json:"MinMXIDAge"` // age of oldest multixact
+}
 
-		// Check if a time window filter is configured
-		var sinceFilter time.Time
-		if cfg.StatsSince != "" {
-			dur, err := time.ParseDuration(cfg.StatsSince)
-			if err == nil {
-				sinceFilter = time.Now().Add(-dur)
-			}
-		}
+// IdleInTransaction tracks sessions stuck in idle-in-transaction state
+type IdleInTransaction struct {
+	Datname     string `json:"Datname"`
+	PID         int    `json:"PID"`
+	User        string `json:"User"`
+	Application string `json:"Application"`
+	Duration    string `json:"Duration"`
+	Query       string `json:"Query"` // last query before going idle
+	WaitEvent   string `json:"WaitEvent"`
+}
 
-		// If filter is set and later than stats reset, skip collection
-		if !sinceFilter.IsZero() && !statsReset.IsZero() && sinceFilter.After(statsReset) {
-			res.Statements.SkippedReason = fmt.Sprintf("pg_stat_statements data is older than the requested window (%s).", cfg.StatsSince)
-		} else {
-			hasIO := hasPSSIOCols(ctx, conn, res.Extensions.PgStatStatementsSchema)
-			hasBlk := hasPSSBlockCols(ctx, conn, res.Extensions.PgStatStatementsSchema)
-			// Top by total execution time
-			if sts, ok := fetchPSS(ctx, conn, res.Extensions.PgStatStatementsSchema, orderByTotal, hasIO, hasBlk); ok {
-				res.Statements.TopByTotalTime = sts
-			}
-			// Top by CPU time (approx = total - IO)
-			if hasIO {
-				if sts, ok := fetchPSS(ctx, conn, res.Extensions.PgStatStatementsSchema, orderByCPUApprox, hasIO, hasBlk); ok {
-					res.Statements.TopByCPU = sts
-				}
-			}
-			// Top by IO time
-			if hasIO {
-				if sts, ok := fetchPSS(ctx, conn, res.Extensions.PgStatStatementsSchema, orderByIO, hasIO, hasBlk); ok {
-					res.Statements.TopByIO = sts
-				}
-			}
-			// Alternative IO ranking by block counts if IO time not available
-			if !hasIO && hasBlk {
-				if sts, ok := fetchPSS(ctx, conn, res.Extensions.PgStatStatementsSchema, orderByIOBlocks, false, hasBlk); ok {
-					res.Statements.TopByIOBlocks = sts
-				}
-			}
-			// Top by calls
-			if sts, ok := fetchPSS(ctx, conn, res.Extensions.PgStatStatementsSchema, orderByCalls, hasIO, hasBlk); ok {
-				res.Statements.TopByCalls = sts
-			}
-			res.Statements.Available = len(res.Statements.TopByTotalTime) > 0 || len(res.Statements.TopByCalls) > 0
-
-			// Calculate calls per hour for all collected statements
-			if hours := res.Statements.StatsDuration.Hours(); hours > 0 {
-				for i := range res.Statements.TopByTotalTime {
-					res.Statements.TopByTotalTime[i].CallsPerHour = res.Statements.TopByTotalTime[i].Calls / hours
-				}
-				for i := range res.Statements.TopByCPU {
-					res.Statements.TopByCPU[i].CallsPerHour = res.Statements.TopByCPU[i].Calls / hours
-				}
-				for i := range res.Statements.TopByCalls {
-					res.Statements.TopByCalls[i].CallsPerHour = res.Statements.TopByCalls[i].Calls / hours
-				}
-				for i := range res.Statements.TopByIO {
-					res.Statements.TopByIO[i].CallsPerHour = res.Statements.TopByIO[i].Calls / hours
-				}
-				for i := range res.Statements.TopByIOBlocks {
-					res.Statements.TopByIOBlocks[i].CallsPerHour = res.Statements.TopByIOBlocks[i].Calls / hours
-				}
-			}
-		}
-	}
+// ColumnStat is the planner statistics of a column that top statements
+// filter or join on, from pg_stats: its most common value and how skewed the
+// distribution is towards it.
+type ColumnStat struct {
+	Database  string  `json:"Database"`
+	Schema    string  `json:"Schema"`
+	Table     string  `json:"Table"`
+	Column    string  `json:"Column"`
+	HotValue  string  `json:"HotValue"` // most common value, as text
+	HotFreq   float64 `json:"HotFreq"`  // fraction of rows holding HotValue
+	NullFrac  float64 `json:"NullFrac"`
+	NDistinct float64 `json:"NDistinct"` // distinct values; negative is a fraction of the rows (see pg_stats)
+	Calls     float64 `json:"Calls"`     // calls of the top statements using the column
+}
 
-	// Best-effort EXPLAIN plan collection per list (slowest and most frequent), each up to planPerListCap
-	reParam := regexp.MustCompile(`\$\d+`)
-	collectAdvice := func(sts []Statement) []Statement {
-		limit := planPerListCap
-		if len(sts) == 0 {
-			return sts
-		}
-		// Compute medians for frequency baselines (CallsPerHour preferred, else Calls)
-		median := func(vals []float64) float64 {
-			if len(vals) == 0 {
-				return 0
-			}
-			vv := make([]float64, 0, len(vals))
-			vv = append(vv, vals...)
-			// insertion sort is fine for <=20 elements
-			for i := 1; i < len(vv); i++ {
-				x := vv[i]
-				j := i - 1
-				for j >= 0 && vv[j] > x {
-					vv[j+1] = vv[j]
-					j--
-				}
-				vv[j+1] = x
-			}
-			n := len(vv)
-			if n%2 == 1 {
-				return vv[n/2]
-			}
-			return (vv[n/2-1] + vv[n/2]) / 2.0
-		}
-		var callsVals []float64
-		var cphVals []float64
-		for i := range sts {
-			if sts[i].Calls > 0 {
-				callsVals = append(callsVals, sts[i].Calls)
-			}
-			if sts[i].CallsPerHour > 0 {
-				cphVals = append(cphVals, sts[i].CallsPerHour)
-			}
-		}
-		callsMed := median(callsVals)
-		cphMed := median(cphVals)
-
-		// Dynamic threshold for what is considered "slow" based on volume.
-		// Baseline: 1000ms is slow. More calls/rows => stricter (lower) threshold, but never below 200ms.
-		calcThresholdMs := func(s Statement) float64 {
-			base := 1000.0
-			floor := 200.0
-			// Frequency factor relative to medians
-			ratio := 1.0
-			if cphMed > 0 && s.CallsPerHour > 0 {
-				ratio = s.CallsPerHour / cphMed
-			} else if cphMed == 0 && callsMed > 0 && s.Calls > 0 {
-				ratio = s.Calls / callsMed
-			}
-			freqMul := 1.0
-			// tiered tighten: 1.5x -> 0.85x, 3x -> 0.7x, 10x -> 0.5x
-			if ratio >= 10 {
-				freqMul = 0.5
-			} else if ratio >= 3 {
-				freqMul = 0.7
-			} else if ratio >= 1.5 {
-				freqMul = 0.85
-			}
-			// Rows factor (average rows per call)
-			rowsMul := 1.0
-			if s.Rows >= 10000 {
-				rowsMul = 0.7
-			} else if s.Rows >= 1000 {
-				rowsMul = 0.85
-			}
-			thr := base * freqMul * rowsMul
-			if thr < floor {
-				thr = floor
-			}
-			return thr
-		}
+// DistinctFrac is the distinct values as a fraction of the rows when pg_stats
+// reports them so (a negative n_distinct, used when they grow with the table),
+// else 0.
+func (c ColumnStat) DistinctFrac() float64 {
+	return max(-c.NDistinct, 0)
+}
 
-		seenLocal := make(map[string]bool)
-		taken := 0
-		// A query is suspect if its mean time exceeds the dynamic threshold
-		isSuspect := func(s Statement) bool {
-			thr := calcThresholdMs(s)
-			return s.MeanTime >= thr
-		}
-		for i := 0; i < len(sts); i++ {
-			qTrim := strings.TrimSpace(sts[i].Query)
-			if qTrim == "" || seenLocal[qTrim] {
-				continue
-			}
-			seenLocal[qTrim] = true
-			qUp := strings.ToUpper(qTrim)
-			// Safe subset only: allow SELECT and WITH (CTE) queries
-			if !(strings.HasPrefix(qUp, "SELECT") || strings.HasPrefix(qUp, "WITH")) {
-				continue
-			}
-			suspect := isSuspect(sts[i])
-			// Mark as needs attention up-front if suspect, even if plan collection fails later
-			if suspect {
-				sts[i].NeedsAttention = true
-			}
-			// If over main budget and not suspect, skip planning; suspects are always planned (unlimited overage)
-			if taken >= limit && !suspect {
-				continue
-			}
-			var planRows pgx.Rows
-			var err error
-			// Parameterized query path: use PREPARE/EXPLAIN EXECUTE with NULL args to avoid brittle substitutions
-			if strings.Contains(qTrim, "$") {
-				prepName := fmt.Sprintf("__pghealth_prep_%d", i)
-				ctxPrep, cancelPrep := context.WithTimeout(ctx, 3*time.Second)
-				_, errPrep := conn.Exec(ctxPrep, "PREPARE "+prepName+" AS "+qTrim)
-				cancelPrep()
-				if errPrep == nil {
-					// determine parameter count by max $N occurrence
-					maxParam := 0
-					matches := reParam.FindAllString(qTrim, -1)
-					for _, m := range matches {
-						if len(m) > 1 {
-							// m like $12
-							numStr := m[1:]
-							if n, errN := strconv.Atoi(numStr); errN == nil && n > maxParam {
-								maxParam = n
-							}
-						}
-					}
-					// build NULL argument list matching parameter count
-					argList := ""
-					if maxParam > 0 {
-						nulls := make([]string, maxParam)
-						for k := 0; k < maxParam; k++ {
-							nulls[k] = "NULL"
-						}
-						argList = "(" + strings.Join(nulls, ", ") + ")"
-					}
-					ctxPlan, cancel := context.WithTimeout(ctx, 5*time.Second)
-					planRows, err = conn.Query(ctxPlan, "EXPLAIN EXECUTE "+prepName+argList)
-					cancel()
-					// cleanup
-					ctxDel, cancelDel := context.WithTimeout(ctx, 1*time.Second)
-					_, _ = conn.Exec(ctxDel, "DEALLOCATE "+prepName)
-					cancelDel()
-					if err != nil {
-						// Fallback: replace parameters with NULL for a generic plan
-						qForExplain := reParam.ReplaceAllString(qTrim, "NULL")
-						ctxPlan2, cancel2 := context.WithTimeout(ctx, 5*time.Second)
-						planRows, err = conn.Query(ctxPlan2, "EXPLAIN "+qForExplain)
-						cancel2()
-					}
-				} else {
-					// Fallback: replace parameters with NULL for a generic plan
-					qForExplain := reParam.ReplaceAllString(qTrim, "NULL")
-					ctxPlan, cancel := context.WithTimeout(ctx, 5*time.Second)
-					planRows, err = conn.Query(ctxPlan, "EXPLAIN "+qForExplain)
-					cancel()
-				}
-			} else {
-				// Non-parameterized
-				ctxPlan, cancel := context.WithTimeout(ctx, 5*time.Second)
-				planRows, err = conn.Query(ctxPlan, "EXPLAIN "+qTrim)
-				cancel()
-			}
-			if err != nil {
-				// Plan failed; if it is suspect, keep NeedsAttention as set, but don't count against planning limit
-				continue
-			}
-			var planLines []string
-			var seqOn []string
-			hasSort := false
-			hasJoin := false
-			joinType := ""
-			hasBitmap := false
-			hasParallel := false
-			hasCTE := false
-			for planRows.Next() {
-				var line string
-				_ = planRows.Scan(&line)
-				planLines = append(planLines, line)
-				up := strings.ToUpper(line)
-				if strings.Contains(up, "SEQ SCAN ON ") {
-					idx := strings.Index(up, "SEQ SCAN ON ")
-					if idx >= 0 {
-						rest := strings.TrimSpace(line[idx+len("SEQ SCAN ON "):])
-						name := rest
-						if j := strings.IndexAny(rest, " (\t"); j >= 0 {
-							name = rest[:j]
-						}
-						seqOn = append(seqOn, name)
-					}
-				}
-				if strings.HasPrefix(strings.TrimSpace(up), "SORT ") || strings.Contains(up, " SORT ") {
-					hasSort = true
-				}
-				if strings.Contains(up, "BITMAP ") {
-					hasBitmap = true
-				}
-				if strings.Contains(up, " NESTED LOOP ") {
-					hasJoin = true
-					joinType = "Nested Loop"
-				} else if strings.Contains(up, " HASH JOIN ") {
-					hasJoin = true
-					joinType = "Hash Join"
-				} else if strings.Contains(up, " MERGE JOIN ") {
-					hasJoin = true
-					joinType = "Merge Join"
-				} else if strings.Contains(up, " JOIN ") {
-					hasJoin = true
-					if joinType == "" {
-						joinType = "Join"
-					}
-				}
-				if strings.Contains(up, "PARALLEL ") {
-					hasParallel = true
-				}
-				if strings.Contains(up, "CTE ") || strings.Contains(up, "WITH ") {
-					hasCTE = true
-				}
-			}
-			planRows.Close()
-			advice := &PlanAdvice{}
-			if len(planLines) > 0 {
-				advice.Plan = strings.Join(planLines, "\n")
-			}
-			// Highlights
-			for _, tname := range seqOn {
-				advice.Highlights = append(advice.Highlights, fmt.Sprintf("Seq Scan on %s", tname))
-			}
-			if hasBitmap {
-				advice.Highlights = append(advice.Highlights, "Bitmap scan present")
-			}
-			if hasSort {
-				advice.Highlights = append(advice.Highlights, "Explicit Sort in plan")
-			}
-			if hasJoin {
-				if joinType != "" {
-					advice.Highlights = append(advice.Highlights, joinType)
-				} else {
-					advice.Highlights = append(advice.Highlights, "Join present")
-				}
-			}
-			if hasParallel {
-				advice.Highlights = append(advice.Highlights, "Parallel operation(s)")
-			}
-			if hasCTE {
-				advice.Highlights = append(advice.Highlights, "CTE in plan")
-			}
-			// Suggestions
-			findTable := func(name string) (TableStat, bool) {
-				for _, t := range res.Tables {
-					if strings.EqualFold(t.Name, name) {
-						return t, true
-					}
-				}
-				return TableStat{}, false
-			}
-			hasAnyIndex := func(name string) bool {
-				for _, idx := range res.Indexes {
-					if strings.EqualFold(idx.Table, name) {
-						return true
-					}
-				}
-				return false
-			}
-			if len(seqOn) > 0 {
-				for _, tn := range seqOn {
-					if ts, ok := findTable(tn); ok {
-						if ts.NLiveTup > 100000 { // large table heuristic
-							advice.Suggestions = append(advice.Suggestions, fmt.Sprintf("Large table %s scanned sequentially — consider adding/using an index on predicate/join columns.", tn))
-							advice.CanBeIndexed = true
-						} else {
-							advice.Suggestions = append(advice.Suggestions, fmt.Sprintf("Sequential scan on %s — verify if intentional (small table) or add an index.", tn))
-							advice.CanBeIndexed = true
-						}
-						if !hasAnyIndex(tn) {
-							advice.Suggestions = append(advice.Suggestions, fmt.Sprintf("No indexes found on %s — create indexes on frequently filtered or joined columns.", tn))
-							advice.CanBeIndexed = true
-						}
-					} else {
-						advice.Suggestions = append(advice.Suggestions, fmt.Sprintf("Sequential scan on %s — consider index on predicate columns.", tn))
-						advice.CanBeIndexed = true
-					}
-				}
-			}
-			if hasBitmap {
-				advice.Suggestions = append(advice.Suggestions, "Consider composite/covering indexes to reduce Bitmap Heap rechecks when appropriate.")
-				advice.CanBeIndexed = true
-			}
-			if hasSort {
-				advice.Suggestions = append(advice.Suggestions, "Add or adjust an index matching ORDER BY to avoid Sort when appropriate; review work_mem as needed.")
-				advice.CanBeIndexed = true
-			}
-			if hasJoin {
-				advice.Suggestions = append(advice.Suggestions, "Ensure join keys are indexed on both sides (consider composite indexes for multi-column joins).")
-				advice.CanBeIndexed = true
-			}
-			if hasCTE {
-				advice.Suggestions = append(advice.Suggestions, "If CTE is not reused, consider inlining it (PostgreSQL may materialize it depending on version/settings).")
-				advice.CanBeRefactored = true
-			}
-			if !advice.CanBeIndexed && len(seqOn) > 0 {
-				advice.CanBeRefactored = true
-				advice.Suggestions = append(advice.Suggestions, "Query uses sequential scans but no clear index path was found. Consider refactoring the query for better performance.")
-			}
-			if advice.Plan != "" || len(advice.Suggestions) > 0 || len(advice.Highlights) > 0 {
-				sts[i].Advice = advice
-				// Do not set NeedsAttention based on presence of a plan; it's governed by thresholds only.
-				if taken < limit {
-					taken++
-				}
+// RetentionTable is a large table of the current database with a timestamp
+// column telling the age of its rows, from the pg_stats histogram of the
+// column, and the write counters telling whether it is append-only.
+type RetentionTable struct {
+	Database    string      `json:"Database"`
+	Schema      string      `json:"Schema"`
+	Table       string      `json:"Table"`
+	Parent      string      `json:"Parent"` // partitioned table when Table is a partition
+	Column      string      `json:"Column"`
+	Convention  bool        `json:"Convention"` // Column is one of Config.RetentionColumns, rather than found by its correlation
+	Correlation float64     `json:"Correlation"`
+	SizeBytes   int64       `json:"SizeBytes"`
+	Rows        int64       `json:"Rows"` // planner estimate
+	Inserts     int64       `json:"Inserts"`
+	Updates     int64       `json:"Updates"`
+	Deletes     int64       `json:"Deletes"`
+	Bounds      []time.Time `json:"Bounds"`    // histogram bounds of Column, oldest first
+	CheckedAt   time.Time   `json:"CheckedAt"` // server time the bounds were read
+}
+
+// OlderThan estimates the fraction of rows whose Column is before t from the
+// histogram, whose buckets hold equal shares of the rows.
+func (r RetentionTable) OlderThan(t time.Time) float64 {
+	n := len(r.Bounds)
+	if n < 2 {
+		return 0
+	}
+	// Bounds split the rows into n-1 equal buckets; interpolate inside the
+	// bucket holding t
+	for i := 1; i < n; i++ {
+		if !r.Bounds[i].Before(t) {
+			lo, hi := r.Bounds[i-1], r.Bounds[i]
+			part := 0.0
+			if span := hi.Sub(lo); span > 0 && t.After(lo) {
+				part = float64(t.Sub(lo)) / float64(span)
 			}
+			return (float64(i-1) + part) / float64(n-1)
 		}
-		return sts
-	}
-	if len(res.Statements.TopByTotalTime) > 0 {
-		res.Statements.TopByTotalTime = collectAdvice(res.Statements.TopByTotalTime)
-	}
-	if len(res.Statements.TopByCalls) > 0 {
-		res.Statements.TopByCalls = collectAdvice(res.Statements.TopByCalls)
 	}
+	return 1
+}
 
-	// Healthchecks collection
-	// Overall connection count
-	_ = queryRow(ctx, conn, `select count(*) from pg_stat_activity`, &res.TotalConnections)
+// StaleStatsTable tracks tables with outdated statistics
+type StaleStatsTable struct {
+	Schema           string     `json:"Schema"`
+	Table            string     `json:"Table"`
+	RowEstimate      int64      `json:"RowEstimate"`
+	LastAnalyze      *time.Time `json:"LastAnalyze"`
+	LastAutoAnalyze  *time.Time `json:"LastAutoAnalyze"`
+	ModsSinceAnalyze int64      `json:"ModsSinceAnalyze"`
+	DaysSinceAnalyze int        `json:"DaysSinceAnalyze"`
+}
 
-	// Connections by client (address, user, application)
-	if rows, err := conn.Query(ctx, `select
-			coalesce(host(client_addr), 'local') as client_addr,
-			coalesce(usename, '') as usename,
-			coalesce(application_name, '') as application_name,
-			count(*) as cnt
-		from pg_stat_activity
-		where usename is not null
-		group by 1, 2, 3
-		order by cnt desc`); err == nil {
-		for rows.Next() {
-			var c ClientConn
-			if err := rows.Scan(&c.Address, &c.User, &c.Application, &c.Count); err == nil {
-				res.ConnectionsByClient = append(res.ConnectionsByClient, c)
-			}
-		}
-		rows.Close()
-	}
+// DuplicateIndex identifies indexes with redundant column definitions
+type DuplicateIndex struct {
+	Schema      string `json:"Schema"`
+	Table       string `json:"Table"`
+	Index1      string `json:"Index1"`
+	Index2      string `json:"Index2"`
+	Columns     string `json:"Columns"`
+	Index1Size  int64  `json:"Index1Size"`
+	Index2Size  int64  `json:"Index2Size"`
+	Index1Scans int64  `json:"Index1Scans"`
+	Index2Scans int64  `json:"Index2Scans"`
+}
 
-	// Cache hit ratio (current DB and overall)
-	{
-		var hit, read int64
-		if err := conn.QueryRow(ctx, `select coalesce(blks_hit,0), coalesce(blks_read,0) from pg_stat_database where datname=current_database()`).Scan(&hit, &read); err == nil {
-			total := hit + read
-			if total > 0 {
-				res.CacheHitCurrent = float64(hit) / float64(total) * 100
-			}
-		}
-		var hitSum, readSum int64
-		if err := conn.QueryRow(ctx, `select coalesce(sum(blks_hit),0), coalesce(sum(blks_read),0) from pg_stat_database`).Scan(&hitSum, &readSum); err == nil {
-			total := hitSum + readSum
-			if total > 0 {
-				res.CacheHitOverall = float64(hitSum) / float64(total) * 100
-			}
-		}
-	}
+// RedundantIndex is an index whose key columns are a leading prefix of a
+// wider index on the same table, which can serve the same lookups
+type RedundantIndex struct {
+	Schema           string `json:"Schema"`
+	Table            string `json:"Table"`
+	Index            string `json:"Index"`
+	Columns          string `json:"Columns"`
+	SizeBytes        int64  `json:"SizeBytes"`
+	Scans            int64  `json:"Scans"`
+	CoveredBy        string `json:"CoveredBy"`
+	CoveredByColumns string `json:"CoveredByColumns"`
+	CoveredBySize    int64  `json:"CoveredBySize"`
+	CoveredByScans   int64  `json:"CoveredByScans"`
+}
 
-	// Blocking queries
-	if rows, err := conn.Query(ctx, `select a.datname, a.pid as blocked_pid, (now()-a.query_start)::text as blocked_for, a.query as blocked_query,
-			b.pid as blocking_pid, (now()-b.query_start)::text as blocking_for, b.query as blocking_query
-			from pg_stat_activity a
-			join lateral unnest(pg_blocking_pids(a.pid)) as blocked_by(pid) on true
-			join pg_stat_activity b on b.pid = blocked_by.pid
-			order by (now()-a.query_start) desc limit 20`); err == nil {
-		for rows.Next() {
-			var bl Blocking
-			_ = rows.Scan(&bl.Datname, &bl.BlockedPID, &bl.BlockedDuration, &bl.BlockedQuery, &bl.BlockingPID, &bl.BlockingDuration, &bl.BlockingQuery)
-			res.Blocking = append(res.Blocking, bl)
-		}
-		rows.Close()
-	}
+// InvalidIndex identifies indexes that failed to build
+type InvalidIndex struct {
+	Schema    string `json:"Schema"`
+	Table     string `json:"Table"`
+	Name      string `json:"Name"`
+	SizeBytes int64  `json:"SizeBytes"`
+	DDL       string `json:"DDL"`
+	Reason    string `json:"Reason"` // "invalid" or "not ready"
+}
 
-	// Long running queries (> 5 minutes)
-	if rows, err := conn.Query(ctx, `select datname, pid, (now()-query_start)::text as duration, state, query
-			from pg_stat_activity where state='active' and now()-query_start > interval '5 minutes'
-			order by (now()-query_start) desc limit 20`); err == nil {
-		for rows.Next() {
-			var lq LongQuery
-			_ = rows.Scan(&lq.Datname, &lq.PID, &lq.Duration, &lq.State, &lq.Query)
-			res.LongRunning = append(res.LongRunning, lq)
-		}
-		rows.Close()
+// FKMissingIndex identifies foreign keys without supporting indexes
+type FKMissingIndex struct {
+	Schema       string `json:"Schema"`
+	Table        string `json:"Table"`
+	Constraint   string `json:"Constraint"`
+	Columns      string `json:"Columns"`
+	RefTable     string `json:"RefTable"`
+	RefColumns   string `json:"RefColumns"`
+	TableRows    int64  `json:"TableRows"`
+	SuggestedDDL string `json:"SuggestedDDL"`
+}
+
+// SequenceHealth tracks sequences approaching exhaustion
+type SequenceHealth struct {
+	Schema    string  `json:"Schema"`
+	Name      string  `json:"Name"`
+	LastValue int64   `json:"LastValue"`
+	MaxValue  int64   `json:"MaxValue"`
+	Increment int64   `json:"Increment"`
+	PctUsed   float64 `json:"PctUsed"`
+	CallsLeft int64   `json:"CallsLeft"` // remaining increments before exhaustion
+}
+
+// Subtransactions holds the Subtrans SLRU counters (PG13+) and, on PG16+,
+// sessions currently using subtransactions (savepoints, PL/pgSQL EXCEPTION
+// blocks). A backend caches up to 64 subtransaction IDs; beyond that it is
+// overflowed and every snapshot check may read the SLRU.
+type Subtransactions struct {
+	SLRU     *SLRUStat        `json:"SLRU"`
+	Backends []SubxactBackend `json:"Backends"`
+}
+
+// SLRUStat counts buffer hits, reads and zeroed (newly created) pages of an SLRU.
+type SLRUStat struct {
+	BlksHit    int64     `json:"BlksHit"`
+	BlksRead   int64     `json:"BlksRead"`
+	BlksZeroed int64     `json:"BlksZeroed"`
+	StatsReset time.Time `json:"StatsReset"`
+}
+
+// HitRatio is the share of SLRU page accesses served from its buffers.
+func (s SLRUStat) HitRatio() float64 {
+	if s.BlksHit+s.BlksRead == 0 {
+		return 1
 	}
+	return float64(s.BlksHit) / float64(s.BlksHit+s.BlksRead)
+}
 
-	// Autovacuum activities
-	if rows, err := conn.Query(ctx, `select a.datname, p.pid, p.relid::regclass::text as relation, p.phase,
-			p.heap_blks_scanned, p.heap_blks_total
-			from pg_stat_progress_vacuum p
-			join pg_stat_activity a on a.pid = p.pid
-			order by a.datname, relation`); err == nil {
-		for rows.Next() {
-			var av AutoVacuum
-			_ = rows.Scan(&av.Datname, &av.PID, &av.Relation, &av.Phase, &av.Scanned, &av.Total)
-			res.AutoVacuum = append(res.AutoVacuum, av)
-		}
-		rows.Close()
+// SubxactBackend is a session with open subtransactions.
+type SubxactBackend struct {
+	PID         int    `json:"PID"`
+	Datname     string `json:"Datname"`
+	Usename     string `json:"Usename"`
+	Application string `json:"Application"`
+	Count       int    `json:"Count"`
+	Overflowed  bool   `json:"Overflowed"`
+	Query       string `json:"Query"`
+}
+
+// WALArchiving describes continuous archiving and, with -wal-archive-url,
+// whether the latest archived segments are present in the archive.
+type WALArchiving struct {
+	Mode            string    `json:"Mode"` // archive_mode: off, on or always
+	Command         string    `json:"Command"`
+	Library         string    `json:"Library"`     // archive_library (PostgreSQL 15+)
+	SegmentSize     int64     `json:"SegmentSize"` // wal_segment_size in bytes
+	ArchivedCount   int64     `json:"ArchivedCount"`
+	LastArchivedWAL string    `json:"LastArchivedWAL"`
+	LastArchivedAt  time.Time `json:"LastArchivedAt"`
+	FailedCount     int64     `json:"FailedCount"`
+	LastFailedWAL   string    `json:"LastFailedWAL"`
+	LastFailedAt    time.Time `json:"LastFailedAt"`
+	CurrentWAL      string    `json:"CurrentWAL"` // segment being written (empty on standbys)
+
+	Check *WALArchiveCheck `json:"Check"` // nil unless verification was requested
+}
+
+// Failing reports whether the latest archiving attempt failed.
+func (w WALArchiving) Failing() bool {
+	return !w.LastFailedAt.IsZero() && w.LastFailedAt.After(w.LastArchivedAt)
+}
+
+// WALArchiveCheck is the result of looking up recently archived segments
+// in the archive.
+type WALArchiveCheck struct {
+	Tool        string   `json:"Tool"`        // wal-g, pgbackrest, barman-cloud or copy
+	Destination string   `json:"Destination"` // s3://bucket/prefix or a local directory
+	Checked     []string `json:"Checked"`     // segments looked up, newest first
+	Missing     []string `json:"Missing"`     // segments absent from the archive, newest first
+	Error       string   `json:"Error"`       // why the archive could not be verified
+}
+
+// RestoreCheck is the result of the -verify-restore-cmd hook.
+type RestoreCheck struct {
+	Command  string        `json:"Command"`
+	Started  time.Time     `json:"Started"`
+	Duration time.Duration `json:"Duration"`
+	ExitCode int           `json:"ExitCode"` // -1 when the command did not run to completion
+	Output   string        `json:"Output"`   // combined stdout and stderr, truncated to the tail
+	Error    string        `json:"Error"`    // why the command did not complete
+}
+
+// Passed reports whether the hook exited with status 0.
+func (r RestoreCheck) Passed() bool {
+	return r.Error == "" && r.ExitCode == 0
+}
+
+// SLOStatus is a service level objective evaluated on the current run and
+// the archived runs of the same target.
+type SLOStatus struct {
+	Name      string  `json:"Name"`
+	Metric    string  `json:"Metric"`
+	Target    string  `json:"Target"`   // e.g. ">= 99"
+	Value     float64 `json:"Value"`    // current value; meaningless unless Measured
+	Measured  bool    `json:"Measured"` // the metric was available in this run
+	Met       bool    `json:"Met"`      // Value meets the target
+	Runs      int     `json:"Runs"`     // runs with the metric in the window, this one included
+	MetRuns   int     `json:"MetRuns"`  // runs meeting the target
+	Goal      float64 `json:"Goal"`     // percentage of runs that must meet the target
+	BurnRate  float64 `json:"BurnRate"` // error budget consumption: 1 spends exactly the budget over the window
+	Trend     float64 `json:"Trend"`    // Value minus the median of the archived runs; 0 without history
+	HasTrend  bool    `json:"HasTrend"`
+	LowerIsOK bool    `json:"LowerIsOK"` // smaller values are better (a max target)
+}
+
+// ValueText formats Value with at most two decimals.
+func (s SLOStatus) ValueText() string {
+	return strconv.FormatFloat(math.Round(s.Value*100)/100, 'f', -1, 64)
+}
+
+// TrendText formats Trend with its sign, e.g. "+1.5".
+func (s SLOStatus) TrendText() string {
+	t := strconv.FormatFloat(math.Round(s.Trend*100)/100, 'f', -1, 64)
+	if s.Trend > 0 {
+		t = "+" + t
 	}
+	return t
+}
 
-	// Cache hit ratio by database
-	if rows, err := conn.Query(ctx, `select datname, blks_hit, blks_read,
-			coalesce(round(100.0 * blks_hit / nullif(blks_hit + blks_read, 0), 2), 0.0) as cache_hit_ratio
-		from pg_stat_database
-		order by cache_hit_ratio asc`); err == nil {
-		for rows.Next() {
-			var ch CacheHit
-			_ = rows.Scan(&ch.Datname, &ch.BlksHit, &ch.BlksRead, &ch.Ratio)
-			res.CacheHits = append(res.CacheHits, ch)
-		}
-		rows.Close()
+// Worsening reports whether the trend moves away from the target.
+func (s SLOStatus) Worsening() bool {
+	if s.LowerIsOK {
+		return s.Trend > 0
 	}
+	return s.Trend < 0
+}
 
-	// Lowest index usage tables (prefer user tables; fallback to all non-system)
-	{
-		q := `select schemaname, relname,
-				coalesce(100.0 * idx_scan / nullif(seq_scan + idx_scan, 0), 0.0) as index_usage_pct,
-				n_live_tup
-			  from pg_stat_user_tables
-			  where n_live_tup > 10000
-			  order by index_usage_pct asc nulls last
-			  limit 50`
-		if rows, err := conn.Query(ctx, q); err == nil {
-			for rows.Next() {
-				var iu IndexUsage
-				_ = rows.Scan(&iu.Schema, &iu.Table, &iu.IndexUsagePct, &iu.Rows)
-				iu.Database = res.ConnInfo.CurrentDB
-				res.IndexUsageLow = append(res.IndexUsageLow, iu)
-			}
-			rows.Close()
-		}
-		if len(res.IndexUsageLow) == 0 {
-			if rows, err := conn.Query(ctx, `select schemaname, relname,
-					coalesce(100.0 * idx_scan / nullif(seq_scan + idx_scan, 0), 0.0) as index_usage_pct,
-					n_live_tup
-				  from pg_stat_all_tables
-				  where schemaname not in ('pg_catalog','information_schema') and n_live_tup > 10000
-				  order by index_usage_pct asc nulls last
-				  limit 50`); err == nil {
-				for rows.Next() {
-					var iu IndexUsage
-					_ = rows.Scan(&iu.Schema, &iu.Table, &iu.IndexUsagePct, &iu.Rows)
-					iu.Database = res.ConnInfo.CurrentDB
-					res.IndexUsageLow = append(res.IndexUsageLow, iu)
-				}
-				rows.Close()
-			}
-		}
+// Compliance is the percentage of runs in the window meeting the target.
+func (s SLOStatus) Compliance() float64 {
+	if s.Runs == 0 {
+		return 0
 	}
+	return float64(s.MetRuns) / float64(s.Runs) * 100
+}
+
+// HAMetadata holds node registrations of HA tooling that keeps its metadata
+// in the database: repmgr and the pg_auto_failover monitor.
+type HAMetadata struct {
+	RepmgrNodes       []RepmgrNode       `json:"RepmgrNodes"`
+	AutoFailoverNodes []AutoFailoverNode `json:"AutoFailoverNodes"`
+}
+
+// RepmgrNode is a row of repmgr.nodes.
+type RepmgrNode struct {
+	ID         int           `json:"ID"`
+	Name       string        `json:"Name"` // also the application_name of its replication connection
+	Type       string        `json:"Type"` // primary, standby, witness or bdr
+	Active     bool          `json:"Active"`
+	Upstream   string        `json:"Upstream"` // upstream node name
+	Priority   int           `json:"Priority"`
+	Location   string        `json:"Location"`
+	MonitorAge time.Duration `json:"MonitorAge"` // since the latest repmgrd monitoring sample (negative = none recorded)
+}
+
+// AutoFailoverNode is a node registered with the pg_auto_failover monitor.
+type AutoFailoverNode struct {
+	ID                int64         `json:"ID"`
+	Name              string        `json:"Name"`
+	Host              string        `json:"Host"`
+	Port              int           `json:"Port"`
+	Formation         string        `json:"Formation"`
+	Group             int           `json:"Group"`
+	GoalState         string        `json:"GoalState"`     // state assigned by the monitor
+	ReportedState     string        `json:"ReportedState"` // state last reported by the node's keeper
+	PgRunning         bool          `json:"PgRunning"`
+	Health            int           `json:"Health"`    // monitor health check: 1 good, 0 bad, -1 unknown
+	ReportAge         time.Duration `json:"ReportAge"` // since the keeper last reported
+	CandidatePriority int           `json:"CandidatePriority"`
+	ReplicationQuorum bool          `json:"ReplicationQuorum"`
+}
+
+// PatroniCluster is the cluster state reported by the Patroni REST API.
+type PatroniCluster struct {
+	Paused  bool              `json:"Paused"` // maintenance mode: automatic failover is off
+	Members []PatroniMember   `json:"Members"`
+	History []PatroniFailover `json:"History"` // newest first
+}
+
+// PatroniMember is a cluster member as Patroni sees it.
+type PatroniMember struct {
+	Name     string `json:"Name"`  // also the application_name of its replication connection
+	Role     string `json:"Role"`  // leader, standby_leader, sync_standby, quorum_standby or replica
+	State    string `json:"State"` // running, streaming, stopped, ...
+	Host     string `json:"Host"`
+	Port     int    `json:"Port"`
+	Timeline int64  `json:"Timeline"`
+	Lag      int64  `json:"Lag"` // replication lag in bytes (-1 = unknown)
+}
 
-	// Tables with index counts
-	if rows, err := conn.Query(ctx, `select t.schemaname, t.relname,
-			count(i.indexrelid) as index_count,
-			pg_total_relation_size(format('%I.%I', t.schemaname, t.relname)) as size_bytes,
-			t.n_live_tup,
-			t.n_dead_tup,
-			coalesce(100.0 * t.n_dead_tup / nullif(t.n_live_tup + t.n_dead_tup, 0), 0.0) as bloat_pct
-		from pg_stat_user_tables t
-		left join pg_stat_user_indexes i on i.schemaname = t.schemaname and i.relname = t.relname
-		group by t.schemaname, t.relname, t.n_live_tup, t.n_dead_tup
-		order by size_bytes desc
-		limit 100`); err == nil {
-		for rows.Next() {
-			var tic TableIndexCount
-			_ = rows.Scan(&tic.Schema, &tic.Name, &tic.IndexCount, &tic.SizeBytes, &tic.RowCount, &tic.DeadRows, &tic.BloatPct)
-			tic.Database = res.ConnInfo.CurrentDB
-			res.TablesWithIndexCount = append(res.TablesWithIndexCount, tic)
-		}
-		rows.Close()
+// IsStandby reports whether the member replicates from the leader.
+func (m PatroniMember) IsStandby() bool {
+	switch m.Role {
+	case "replica", "sync_standby", "quorum_standby":
+		return true
 	}
+	return false
+}
 
-	// Advanced table bloat analysis
-	if rows, err := conn.Query(ctx, `select schemaname, relname,
-			coalesce(100.0 * n_dead_tup / nullif(n_live_tup + n_dead_tup, 0), 0.0) as bloat_pct,
-			pg_total_relation_size(format('%I.%I', schemaname, relname)) * 
-			coalesce(n_dead_tup::float8 / nullif(n_live_tup + n_dead_tup, 0), 0.0) as wasted_bytes,
-			last_vacuum, last_analyze
-		from pg_stat_user_tables
-		where n_live_tup + n_dead_tup > 10000
-		order by wasted_bytes desc
-		limit 50`); err == nil {
-		for rows.Next() {
-			var tbs TableBloatStat
-			var lastVacuum, lastAnalyze *time.Time
-			_ = rows.Scan(&tbs.Schema, &tbs.Name, &tbs.EstimatedBloat, &tbs.WastedBytes, &lastVacuum, &lastAnalyze)
-			tbs.LastVacuum = lastVacuum
-			tbs.LastAnalyze = lastAnalyze
-			res.TableBloatStats = append(res.TableBloatStats, tbs)
+// Leader returns the member holding the leader key.
+func (c PatroniCluster) Leader() (PatroniMember, bool) {
+	for _, m := range c.Members {
+		if m.Role == "leader" || m.Role == "standby_leader" {
+			return m, true
 		}
-		rows.Close()
 	}
+	return PatroniMember{}, false
+}
 
-	// Index bloat analysis
-	if rows, err := conn.Query(ctx, `select s.schemaname, s.relname, s.indexrelname,
-			0.0 as estimated_bloat, -- Placeholder for actual bloat calculation
-			pg_relation_size(s.indexrelid) as size_bytes,
-			s.idx_scan
-		from pg_stat_user_indexes s
-		where pg_relation_size(s.indexrelid) > 10485760 -- > 10MB
-		order by size_bytes desc
-		limit 50`); err == nil {
-		for rows.Next() {
-			var ibs IndexBloatStat
-			_ = rows.Scan(&ibs.Schema, &ibs.Table, &ibs.Name, &ibs.EstimatedBloat, &ibs.WastedBytes, &ibs.Scans)
-			res.IndexBloatStats = append(res.IndexBloatStats, ibs)
-		}
-		rows.Close()
-	}
+// PatroniFailover is a timeline switch from the Patroni history.
+type PatroniFailover struct {
+	Timeline  int64     `json:"Timeline"` // timeline that ended
+	LSN       int64     `json:"LSN"`      // position where it ended
+	Reason    string    `json:"Reason"`
+	At        time.Time `json:"At"`        // zero before Patroni 1.6
+	NewLeader string    `json:"NewLeader"` // empty before Patroni 2.0
+}
 
-	// Replication statistics
-	if rows, err := conn.Query(ctx, `select application_name, state, sync_state, sync_priority,
-			coalesce(write_lag::text, '00:00:00') as write_lag,
-			coalesce(flush_lag::text, '00:00:00') as flush_lag,
-			coalesce(replay_lag::text, '00:00:00') as replay_lag
-		from pg_stat_replication
-		order by sync_priority desc`); err == nil {
-		for rows.Next() {
-			var rs ReplicationStat
-			_ = rows.Scan(&rs.Name, &rs.State, &rs.SyncState, &rs.SyncPriority, &rs.WriteLag, &rs.FlushLag, &rs.ReplayLag)
-			res.ReplicationStats = append(res.ReplicationStats, rs)
-		}
-		rows.Close()
-	}
+// ConfigFiles holds configuration file entries the server rejects or ignores
+// on the next reload or restart.
+type ConfigFiles struct {
+	Settings []FileSetting `json:"Settings"` // pg_file_settings rows with an error or not applied
+	HBARules []HBARule     `json:"HBARules"` // pg_hba_file_rules rows with an error
+}
 
-	// Wait events (top)
-	if rows, err := conn.Query(ctx, `select coalesce(wait_event_type,'none') as type, coalesce(wait_event,'none') as event, count(*)
-		from pg_stat_activity
-		where wait_event is not null
-		group by 1,2
-		order by 3 desc
-		limit 20`); err == nil {
-		for rows.Next() {
-			var w WaitEventStat
-			_ = rows.Scan(&w.Type, &w.Event, &w.Count)
-			res.WaitEvents = append(res.WaitEvents, w)
-		}
-		rows.Close()
-	}
+// FileSetting is a postgresql.conf (or included file) entry that does not apply.
+type FileSetting struct {
+	File    string `json:"File"`
+	Line    int    `json:"Line"`
+	Name    string `json:"Name"`
+	Val     string `json:"Val"`
+	Applied bool   `json:"Applied"`
+	Error   string `json:"Error"` // e.g. "setting could not be applied"; empty when a later entry overrides it
+}
 
-	// Top functions by total time (if view available)
-	if rows, err := conn.Query(ctx, `select schemaname, funcname, calls, coalesce(total_time,0), coalesce(self_time,0)
-		from pg_stat_user_functions
-		order by total_time desc nulls last limit 20`); err == nil {
-		for rows.Next() {
-			var f FunctionStat
-			_ = rows.Scan(&f.Schema, &f.Name, &f.Calls, &f.TotalTime, &f.SelfTime)
-			res.FunctionStats = append(res.FunctionStats, f)
-		}
-		rows.Close()
-	}
+// Invalid reports whether the server rejected the entry, as opposed to a
+// later entry for the same setting overriding it.
+func (f FileSetting) Invalid() bool {
+	return f.Error != ""
+}
 
-	// WAL statistics (if view exists)
-	{
-		var hasWAL bool
-		_ = queryRow(ctx, conn, `select exists(select 1 from pg_catalog.pg_class c join pg_catalog.pg_namespace n on n.oid=c.relnamespace where n.nspname='pg_catalog' and c.relname='pg_stat_wal')`, &hasWAL)
-		if hasWAL {
-			var ws WALStat
-			if err := conn.QueryRow(ctx, `select wal_records, wal_fpi, wal_bytes, stats_reset from pg_stat_wal`).Scan(&ws.Records, &ws.FullPage, &ws.Bytes, &ws.StatsReset); err == nil {
-				res.WAL = &ws
-			}
+// Errors returns the rejected entries.
+func (c ConfigFiles) Errors() []FileSetting {
+	var out []FileSetting
+	for _, f := range c.Settings {
+		if f.Invalid() {
+			out = append(out, f)
 		}
 	}
+	return out
+}
 
-	// Progress: CREATE INDEX (if view exists)
-	if rows, err := conn.Query(ctx, `select a.datname, p.relid::regclass::text as relation, p.phase,
-		coalesce(p.blocks_done,0), coalesce(p.blocks_total,0), coalesce(p.tuples_done,0), coalesce(p.tuples_total,0),
-		coalesce(p.lockers_done,0), coalesce(p.lockers_total,0)
-		from pg_stat_progress_create_index p join pg_stat_activity a on a.pid=p.pid
-		order by a.datname, relation`); err == nil {
-		for rows.Next() {
-			var pr ProgressCreateIndex
-			_ = rows.Scan(&pr.Datname, &pr.Relation, &pr.Phase, &pr.BlocksDone, &pr.BlocksTotal, &pr.TuplesDone, &pr.TuplesTotal, &pr.LockersDone, &pr.LockersTotal)
-			res.ProgressCreateIndex = append(res.ProgressCreateIndex, pr)
+// Overridden returns the entries shadowed by a later entry for the same setting.
+func (c ConfigFiles) Overridden() []FileSetting {
+	var out []FileSetting
+	for _, f := range c.Settings {
+		if !f.Invalid() {
+			out = append(out, f)
 		}
-		rows.Close()
 	}
+	return out
+}
 
-	// Progress: ANALYZE (if view exists)
-	if rows, err := conn.Query(ctx, `select a.datname, p.relid::regclass::text as relation, p.phase,
-		coalesce(p.sample_blks_scanned,0), coalesce(p.sample_blks_total,0)
-		from pg_stat_progress_analyze p join pg_stat_activity a on a.pid=p.pid
-		order by a.datname, relation`); err == nil {
-		for rows.Next() {
-			var pa ProgressAnalyze
-			_ = rows.Scan(&pa.Datname, &pa.Relation, &pa.Phase, &pa.SampleScans, &pa.SampleTotal)
-			res.ProgressAnalyze = append(res.ProgressAnalyze, pa)
-		}
-		rows.Close()
+// HBARule is a pg_hba.conf line, one the server cannot load when Error is
+// set.
+type HBARule struct {
+	Line     int    `json:"Line"`
+	Type     string `json:"Type"`
+	Database string `json:"Database"` // comma-separated
+	User     string `json:"User"`     // comma-separated
+	Address  string `json:"Address"`
+	Method   string `json:"Method"`
+	Error    string `json:"Error"` // empty for valid lines
+}
+
+// VacuumHorizon lists what holds back the oldest xmin vacuum must keep rows
+// for, with the primary settings that extend it.
+type VacuumHorizon struct {
+	Holders  []XminHolder `json:"Holders"`  // oldest first
+	Settings []Setting    `json:"Settings"` // hot_standby_feedback, vacuum_defer_cleanup_age (before PG16)
+}
+
+// XminHolder is a standby, replication slot or session pinning an xmin.
+type XminHolder struct {
+	Kind           string `json:"Kind"` // "standby" (hot_standby_feedback), "slot" or "session" (oldest local snapshot)
+	Name           string `json:"Name"`
+	Active         bool   `json:"Active"`
+	XminAge        int64  `json:"XminAge"`        // transactions since the held xmin
+	CatalogXminAge int64  `json:"CatalogXminAge"` // logical slots: transactions since the held catalog xmin
+}
+
+// Age is the older of the xmin and catalog xmin ages.
+func (h XminHolder) Age() int64 {
+	return max(h.XminAge, h.CatalogXminAge)
+}
+
+// SlotRetainMinBytes is the retained WAL from which an inactive replication
+// slot is a drop candidate.
+const SlotRetainMinBytes = 1 << 30
+
+// ReplicationSlot is a row of pg_replication_slots.
+type ReplicationSlot struct {
+	Name          string `json:"Name"`
+	Type          string `json:"Type"`     // physical or logical
+	Plugin        string `json:"Plugin"`   // output plugin of a logical slot
+	Database      string `json:"Database"` // database of a logical slot
+	Active        bool   `json:"Active"`
+	Temporary     bool   `json:"Temporary"`
+	WALStatus     string `json:"WALStatus"`     // reserved, extended, unreserved or lost (PostgreSQL 13+)
+	RetainedBytes int64  `json:"RetainedBytes"` // WAL kept for restart_lsn
+	SafeWALSize   int64  `json:"SafeWALSize"`   // bytes writable before the slot is lost to max_slot_wal_keep_size; -1 without a limit
+	// InactiveSeconds is how long the slot has had no consumer (PostgreSQL
+	// 17+); -1 when unknown or active.
+	InactiveSeconds float64 `json:"InactiveSeconds"`
+	Invalidated     string  `json:"Invalidated"` // invalidation reason (PostgreSQL 17+), or "conflict" for a conflicting logical slot (16)
+	Synced          bool    `json:"Synced"`      // synchronized from the primary for failover (PostgreSQL 17+); dropping it on a standby is not possible
+}
+
+// Lost reports whether the slot can no longer be used: its WAL was removed
+// or it was invalidated. Its consumer must be set up again in any case.
+func (s ReplicationSlot) Lost() bool {
+	return s.WALStatus == "lost" || s.Invalidated != ""
+}
+
+// DropCandidate reports whether the slot is worth dropping: lost, or without
+// a consumer while retaining SlotRetainMinBytes of WAL. Temporary slots go
+// away with their session and synced slots are managed by the primary.
+func (s ReplicationSlot) DropCandidate() bool {
+	if s.Temporary || s.Synced {
+		return false
 	}
+	return s.Lost() || (!s.Active && s.RetainedBytes >= SlotRetainMinBytes)
+}
 
-	// Checkpoint statistics
-	if rows, err := conn.Query(ctx, `select checkpoints_req, checkpoints_timed,
-			checkpoint_write_time, checkpoint_sync_time,
-			buffers_checkpoint, buffers_clean
-		from pg_stat_bgwriter`); err == nil {
-		if rows.Next() {
-			_ = rows.Scan(&res.CheckpointStats.RequestedCheckpoints, &res.CheckpointStats.ScheduledCheckpoints,
-				&res.CheckpointStats.CheckpointWriteTime, &res.CheckpointStats.CheckpointSyncTime,
-				&res.CheckpointStats.BuffersCheckpoint, &res.CheckpointStats.BuffersWritten)
-		}
-		rows.Close()
+// DropRisk describes what dropping the slot breaks.
+func (s ReplicationSlot) DropRisk() string {
+	switch {
+	case s.Lost():
+		return "none left: the slot is unusable, its consumer must be re-created or re-seeded anyway"
+	case s.Type == "logical":
+		return "the subscriber or CDC consumer loses every change not yet decoded and must be re-synchronized from a fresh snapshot"
 	}
+	return "the standby or WAL receiver using it can no longer resume; it needs a new base backup unless the WAL it needs is archived"
+}
+
+// DropCommand is the statement dropping the slot.
+func (s ReplicationSlot) DropCommand() string {
+	return "SELECT pg_drop_replication_slot('" + strings.ReplaceAll(s.Name, "'", "''") + "');"
+}
 
-	// Memory statistics
-	// 1) bgwriter counters (approximate buffer allocation stats)
-	if rows, err := conn.Query(ctx, `select buffers_alloc, buffers_checkpoint + buffers_clean + buffers_backend
-		from pg_stat_bgwriter`); err == nil {
-		if rows.Next() {
-			_ = rows.Scan(&res.MemoryStats.SharedBuffersUsed, &res.MemoryStats.SharedBuffersTotal)
+// DropCandidates returns the slots worth dropping, most urgent first: lost
+// slots, then inactive ones by retained WAL.
+func DropCandidates(slots []ReplicationSlot) []ReplicationSlot {
+	var out []ReplicationSlot
+	for _, s := range slots {
+		if s.DropCandidate() {
+			out = append(out, s)
 		}
-		rows.Close()
 	}
-	// 2) block size and shared_buffers config
-	{
-		var blockSizeStr string
-		_ = queryRow(ctx, conn, `select current_setting('block_size')`, &blockSizeStr)
-		// Try to parse as integer bytes; if fails and contains kB suffix, strip and multiply
-		if n, err := strconv.ParseInt(strings.TrimSpace(blockSizeStr), 10, 64); err == nil && n > 0 {
-			res.MemoryStats.BlockSizeBytes = n
-		} else {
-			// e.g., "8kB"
-			bs := strings.ToLower(strings.TrimSpace(blockSizeStr))
-			if strings.HasSuffix(bs, "kb") {
-				v := strings.TrimSuffix(bs, "kb")
-				if n2, err2 := strconv.ParseInt(strings.TrimSpace(v), 10, 64); err2 == nil && n2 > 0 {
-					res.MemoryStats.BlockSizeBytes = n2 * 1024
-				}
-			}
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].Lost() != out[j].Lost() {
+			return out[i].Lost()
 		}
-		var sbCount int64
-		_ = queryRow(ctx, conn, `select setting::bigint from pg_settings where name='shared_buffers'`, &sbCount)
-		res.MemoryStats.SharedBuffersSetting = sbCount
-		if res.MemoryStats.BlockSizeBytes > 0 && sbCount > 0 {
-			res.MemoryStats.SharedBuffersBytes = sbCount * res.MemoryStats.BlockSizeBytes
-		}
-	}
-	// 3) pg_buffercache usage if available
-	{
-		var hasBuffercache bool
-		_ = queryRow(ctx, conn, `select exists(select 1 from pg_extension where extname='pg_buffercache')`, &hasBuffercache)
-		res.MemoryStats.BuffercacheAvailable = hasBuffercache
-		if hasBuffercache {
-			var used int64
-			_ = queryRow(ctx, conn, `select count(*) from pg_buffercache`, &used)
-			res.MemoryStats.BuffercacheUsedBuffers = used
-			if res.MemoryStats.BlockSizeBytes > 0 && used > 0 {
-				res.MemoryStats.BuffercacheUsedBytes = used * res.MemoryStats.BlockSizeBytes
-			}
-		}
-	}
-	// 4) Temp file usage from pg_stat_database for current DB
-	{
-		var tf, tb int64
-		if err := conn.QueryRow(ctx, `select coalesce(temp_files,0), coalesce(temp_bytes,0) from pg_stat_database where datname=current_database()`).Scan(&tf, &tb); err == nil {
-			res.MemoryStats.TempFilesCurrentDB = tf
-			res.MemoryStats.TempBytesCurrentDB = tb
+		return out[i].RetainedBytes > out[j].RetainedBytes
+	})
+	return out
+}
+
+// Setting returns the named setting, if collected.
+func (v VacuumHorizon) Setting(name string) (Setting, bool) {
+	for _, st := range v.Settings {
+		if st.Name == name {
+			return st, true
 		}
 	}
+	return Setting{}, false
+}
+
+// StandbyConflicts holds pg_stat_database_conflicts of a standby with the
+// settings that decide when conflicting queries are cancelled.
+type StandbyConflicts struct {
+	Host      string              `json:"Host"` // standby the counters were read from
+	Databases []DatabaseConflicts `json:"Databases"`
+	Settings  []Setting           `json:"Settings"` // max_standby_*_delay, hot_standby_feedback
+}
+
+// DatabaseConflicts counts queries cancelled per recovery conflict type.
+type DatabaseConflicts struct {
+	Datname    string `json:"Datname"`
+	Tablespace int64  `json:"Tablespace"` // dropped tablespaces
+	Lock       int64  `json:"Lock"`       // lock timeouts (ACCESS EXCLUSIVE from DDL or vacuum truncation)
+	Snapshot   int64  `json:"Snapshot"`   // old snapshots (vacuum cleanup of rows still visible)
+	BufferPin  int64  `json:"BufferPin"`  // pinned buffers
+	Deadlock   int64  `json:"Deadlock"`   // deadlocks with the startup process
+}
+
+// Total is the number of cancelled queries of all conflict types.
+func (d DatabaseConflicts) Total() int64 {
+	return d.Tablespace + d.Lock + d.Snapshot + d.BufferPin + d.Deadlock
+}
 
-	// IO statistics
-	if rows, err := conn.Query(ctx, `select heap_blks_read, heap_blks_hit, idx_blks_read, idx_blks_hit,
-			toast_blks_read, toast_blks_hit, tidx_blks_read, tidx_blks_hit,
-			coalesce(blk_read_time, 0), coalesce(blk_write_time, 0)
-		from pg_stat_database
-		where datname = current_database()`); err == nil {
-		if rows.Next() {
-			_ = rows.Scan(&res.IOStats.HeapBlksRead, &res.IOStats.HeapBlksHit,
-				&res.IOStats.IdxBlksRead, &res.IOStats.IdxBlksHit,
-				&res.IOStats.ToastBlksRead, &res.IOStats.ToastBlksHit,
-				&res.IOStats.TidxBlksRead, &res.IOStats.TidxBlksHit,
-				&res.IOStats.ReadTime, &res.IOStats.WriteTime)
+// Setting returns the named setting, if collected.
+func (s StandbyConflicts) Setting(name string) (Setting, bool) {
+	for _, st := range s.Settings {
+		if st.Name == name {
+			return st, true
 		}
-		rows.Close()
 	}
+	return Setting{}, false
+}
 
-	// Lock statistics
-	if rows, err := conn.Query(ctx, `select locktype, mode, granted, count(*) as count,
-			array_agg(pid) as waiting_pids
-		from pg_locks
-		where not granted
-		group by locktype, mode, granted
-		order by count desc
-		limit 20`); err == nil {
-		for rows.Next() {
-			var ls LockStat
-			_ = rows.Scan(&ls.LockType, &ls.Mode, &ls.Granted, &ls.Count, &ls.WaitingPIDs)
-			res.LockStats = append(res.LockStats, ls)
+// PreparedXact tracks prepared (2PC) transactions that may be orphaned
+type PreparedXact struct {
+	Transaction string    `json:"Transaction"`
+	GID         string    `json:"GID"`
+	Owner       string    `json:"Owner"`
+	Database    string    `json:"Database"`
+	Prepared    time.Time `json:"Prepared"`
+	Age         string    `json:"Age"` // duration since prepared
+}
+
+// Run connects to the database and executes the collectors in order. Collector
+// failures are not fatal: missing data is left empty and the report degrades
+// gracefully. An error is returned only when the connection cannot be made.
+// Transient connection failures are retried per Config.Retries; a collector
+// whose connection drops is rerun on a new connection.
+//
+// With Config.CacheFile set, the result is checkpointed after every collector
+// and the file is removed once all collectors finished. Config.Resume loads a
+// checkpoint left by an interrupted run and only runs the missing collectors.
+// Once Config.SoftDeadline has passed, remaining collectors are skipped and
+// listed in Result.Skipped so the report can still be produced in time. When
+// ctx is done, Run stops after the current collector, which is listed as
+// skipped together with the remaining ones, and returns what was collected;
+// a ctx done before the connection is made skips all of them.
+func Run(ctx context.Context, cfg Config) (Result, error) {
+	var res Result
+
+	target := TargetName(cfg.URL)
+	done := map[string]bool{}
+	if cfg.CacheFile != "" && cfg.Resume {
+		cp, err := loadCheckpoint(cfg.CacheFile, target)
+		if err != nil {
+			return res, err
+		}
+		res = cp.Result
+		res.Skipped = nil
+		for _, name := range cp.Done {
+			done[name] = true
 		}
-		rows.Close()
 	}
 
-	// Temporary file statistics
-	if rows, err := conn.Query(ctx, `select datname, pid, temp_files, temp_bytes
-		from pg_stat_activity
-		where temp_files > 0 or temp_bytes > 0
-		order by temp_bytes desc
-		limit 20`); err == nil {
-		for rows.Next() {
-			var tfs TempFileStat
-			_ = rows.Scan(&tfs.Datname, &tfs.PID, &tfs.Files, &tfs.Bytes)
-			res.TempFileStats = append(res.TempFileStats, tfs)
+	thr := newThrottle(cfg)
+	conn, host, err := connectServed(ctx, cfg, cfg.URL, thr)
+	if err != nil {
+		if ctx.Err() != nil {
+			// Cancelled before connecting: every collector left is skipped, so
+			// the report still says what is missing
+			res.Skipped = skipRemaining(collectors, done, cancelReason(ctx))
 		}
-		rows.Close()
+		return res, err
 	}
 
-	// Extension statistics for current DB
-	if rows, err := conn.Query(ctx, `select e.extname, e.extversion, obj_description(e.oid, 'pg_extension'),
-			n.nspname
-		from pg_extension e
-		left join pg_namespace n on n.oid = e.extnamespace
-		order by e.extname`); err == nil {
-		for rows.Next() {
-			var es ExtensionStat
-			_ = rows.Scan(&es.Name, &es.Version, &es.Description, &es.Schema)
-			es.Database = res.ConnInfo.CurrentDB
-			res.ExtensionStats = append(res.ExtensionStats, es)
+	start := time.Now()
+	s := &session{cfg: cfg, conn: conn, host: host, thr: thr}
+	defer func() { s.conn.Close(context.Background()) }()
+	var first *counterSample
+	if cfg.Delta > 0 {
+		first = sampleCounters(ctx, s)
+	}
+	rs := s // session of offloaded collectors
+	if cfg.ReplicaURL != "" {
+		if r, err := openReplica(ctx, cfg, thr, &res); err == nil {
+			rs = r
+			defer func() { rs.conn.Close(context.Background()) }()
+		} else {
+			res.Errors = append(res.Errors, "replica: "+err.Error()+"; all collectors run on the primary")
 		}
-		rows.Close()
 	}
-
-	// Per-DB extensions: if cfg.DBs provided, check each DB for installed extensions
-	if len(cfg.DBs) > 0 {
-		baseURL := cfg.URL
-		for _, db := range cfg.DBs {
-			// Skip current DB; already collected
-			if db == res.ConnInfo.CurrentDB {
-				continue
+	for i, c := range collectors {
+		if done[c.name] {
+			continue
+		}
+		if ctx.Err() != nil {
+			res.Skipped = append(res.Skipped, skipRemaining(collectors[i:], done, cancelReason(ctx))...)
+			break
+		}
+		if cfg.SoftDeadline > 0 && time.Since(start) >= cfg.SoftDeadline {
+			res.Skipped = append(res.Skipped, SkippedCollector{
+				Name:   c.name,
+				Reason: fmt.Sprintf("soft deadline of %s reached", cfg.SoftDeadline),
+			})
+			continue
+		}
+		cs := s
+		if c.offload {
+			cs = rs
+		}
+		cctx, cancel := context.WithTimeout(ctx, cfg.collectorTimeout(c))
+		began := time.Now()
+		before := res
+		c.run(cctx, cs, &res)
+		for attempt := 1; attempt <= cfg.Retries && cs.conn.IsClosed() && cctx.Err() == nil; attempt++ {
+			// The connection dropped (failover, pooler restart): reconnect and
+			// run the collector again from its initial state
+			if !sleepBackoff(cctx, attempt, cfg.RetryBackoff) {
+				break
 			}
-			// Build URL for target DB (naive last path segment swap)
-			targetURL := swapDBInURL(baseURL, db)
-			if targetURL == "" {
-				continue
+			note, err := cs.reconnect(cctx)
+			if err != nil {
+				break
+			}
+			res = before
+			if note != "" {
+				res.Errors = append(res.Errors, note)
 			}
-			if c2, err := pgx.Connect(ctx, targetURL); err == nil {
-				if rows, err := c2.Query(ctx, `select e.extname, e.extversion, obj_description(e.oid, 'pg_extension'),
-					n.nspname
-				from pg_extension e
-				left join pg_namespace n on n.oid = e.extnamespace
-				order by e.extname`); err == nil {
-					for rows.Next() {
-						var es ExtensionStat
-						_ = rows.Scan(&es.Name, &es.Version, &es.Description, &es.Schema)
-						es.Database = db
-						res.ExtensionStats = append(res.ExtensionStats, es)
-					}
-					rows.Close()
-				}
-				c2.Close(ctx)
+			c.run(cctx, cs, &res)
+		}
+		cancel()
+		if ctx.Err() != nil {
+			// Interrupted by the run timeout: the collector may be incomplete
+			// and must run again on resume, like the ones after it
+			res.Skipped = append(res.Skipped, skipRemaining(collectors[i:], done, cancelReason(ctx))...)
+			break
+		}
+		done[c.name] = true
+		res.Timings = append(res.Timings, CollectorTiming{Name: c.name, Duration: time.Since(began)})
+		if cs.conn.IsClosed() {
+			// pgx closes the connection when a query outlives its context: reconnect
+			// so a collector timeout does not fail every following collector
+			if note, err := cs.reconnect(ctx); err != nil {
+				res.Errors = append(res.Errors, "reconnect: "+err.Error())
+			} else if note != "" {
+				res.Errors = append(res.Errors, note)
 			}
 		}
-	}
-
-	// ============================================================
-	// Additional Health Checks
-	// ============================================================
-
-	// 1. XID Wraparound Risk - Transaction ID age per database
-	// Maximum XID age before wraparound is ~2 billion (2^31)
-	const xidMax = 2147483647 // 2^31 - 1
-	if rows, err := conn.Query(ctx, `SELECT datname,
-			age(datfrozenxid) as xid_age,
-			datfrozenxid::text::bigint as frozen_xid,
-			datminmxid::text::bigint as min_mxid,
-			mxid_age(datminmxid) as mxid_age
-		FROM pg_database
-		WHERE datallowconn
-		ORDER BY age(datfrozenxid) DESC`); err == nil {
-		for rows.Next() {
-			var x DatabaseXIDAge
-			_ = rows.Scan(&x.Datname, &x.Age, &x.FrozenXID, &x.MinMXID, &x.MinMXIDAge)
-			x.PctToLimit = float64(x.Age) / float64(xidMax) * 100
-			res.XIDAge = append(res.XIDAge, x)
+		if cfg.CacheFile != "" {
+			cp := checkpoint{Target: target, SavedAt: time.Now(), Done: doneNames(done), Result: res}
+			if err := cp.save(cfg.CacheFile); err != nil {
+				res.Errors = append(res.Errors, "checkpoint: "+err.Error())
+				cfg.CacheFile = ""
+			}
 		}
-		rows.Close()
 	}
 
-	// 2. Idle-in-Transaction sessions (potential blockers and resource holders)
-	if rows, err := conn.Query(ctx, `SELECT datname, pid, usename, application_name,
-			(now() - state_change)::text as duration,
-			left(query, 200) as query,
-			coalesce(wait_event, '') as wait_event
-		FROM pg_stat_activity
-		WHERE state = 'idle in transaction'
-		  AND (now() - state_change) > interval '5 minutes'
-		ORDER BY (now() - state_change) DESC
-		LIMIT 20`); err == nil {
-		for rows.Next() {
-			var it IdleInTransaction
-			_ = rows.Scan(&it.Datname, &it.PID, &it.User, &it.Application, &it.Duration, &it.Query, &it.WaitEvent)
-			res.IdleInTransaction = append(res.IdleInTransaction, it)
-		}
-		rows.Close()
+	if first != nil && ctx.Err() == nil {
+		finishDelta(ctx, s, first, &res)
 	}
-
-	// 3. Stale Statistics - Tables that haven't been analyzed recently
-	if rows, err := conn.Query(ctx, `SELECT schemaname, relname,
-			n_live_tup as row_estimate,
-			last_analyze,
-			last_autoanalyze,
-			n_mod_since_analyze as mods_since_analyze,
-			COALESCE(
-				EXTRACT(epoch FROM (now() - COALESCE(last_analyze, last_autoanalyze)))::int / 86400,
-				999
-			) as days_since_analyze
-		FROM pg_stat_user_tables
-		WHERE n_live_tup > 1000
-		  AND (last_analyze IS NULL AND last_autoanalyze IS NULL
-		       OR COALESCE(last_analyze, last_autoanalyze) < now() - interval '7 days')
-		ORDER BY n_live_tup DESC
-		LIMIT 50`); err == nil {
-		for rows.Next() {
-			var st StaleStatsTable
-			_ = rows.Scan(&st.Table, &st.Schema, &st.RowEstimate, &st.LastAnalyze, &st.LastAutoAnalyze, &st.ModsSinceAnalyze, &st.DaysSinceAnalyze)
-			// Swap schema/table - query returns schemaname first
-			st.Schema, st.Table = st.Table, st.Schema
-			res.StaleStatsTables = append(res.StaleStatsTables, st)
-		}
-		rows.Close()
+	if cfg.CacheFile != "" && len(done) == len(collectors) {
+		_ = os.Remove(cfg.CacheFile)
 	}
+	RankTables(&res)
+	res.Catalog.sortSchemas()
+	res.SizeTree = buildSizeTree(&res)
+	suggestIndexColumns(&res)
+	return res, nil
+}
 
-	// 4. Duplicate Indexes - Indexes with identical column definitions
-	if rows, err := conn.Query(ctx, `WITH index_cols AS (
-			SELECT n.nspname as schema,
-				   t.relname as table_name,
-				   i.relname as index_name,
-				   pg_get_indexdef(i.oid) as index_def,
-				   array_to_string(array_agg(a.attname ORDER BY x.n), ', ') as columns,
-				   pg_relation_size(i.oid) as size_bytes,
-				   COALESCE(s.idx_scan, 0) as scans
-			FROM pg_index ix
-			JOIN pg_class i ON i.oid = ix.indexrelid
-			JOIN pg_class t ON t.oid = ix.indrelid
-			JOIN pg_namespace n ON n.oid = t.relnamespace
-			LEFT JOIN pg_stat_user_indexes s ON s.indexrelid = i.oid
-			CROSS JOIN LATERAL unnest(ix.indkey) WITH ORDINALITY AS x(attnum, n)
-			JOIN pg_attribute a ON a.attrelid = t.oid AND a.attnum = x.attnum
-			WHERE n.nspname NOT IN ('pg_catalog', 'information_schema')
-			GROUP BY n.nspname, t.relname, i.relname, i.oid, s.idx_scan
-		)
-		SELECT a.schema, a.table_name, a.index_name, b.index_name,
-			   a.columns, a.size_bytes, b.size_bytes, a.scans, b.scans
-		FROM index_cols a
-		JOIN index_cols b ON a.schema = b.schema
-			AND a.table_name = b.table_name
-			AND a.columns = b.columns
-			AND a.index_name < b.index_name
-		ORDER BY a.size_bytes + b.size_bytes DESC
-		LIMIT 20`); err == nil {
-		for rows.Next() {
-			var di DuplicateIndex
-			_ = rows.Scan(&di.Schema, &di.Table, &di.Index1, &di.Index2, &di.Columns,
-				&di.Index1Size, &di.Index2Size, &di.Index1Scans, &di.Index2Scans)
-			res.DuplicateIndexes = append(res.DuplicateIndexes, di)
-		}
-		rows.Close()
+// openReplica connects to cfg.ReplicaURL for offloaded collectors and records
+// the serving host. A replica that is not in recovery is used anyway but
+// reported, since the point of offloading is to spare the primary.
+func openReplica(ctx context.Context, cfg Config, thr *throttle, res *Result) (*session, error) {
+	rcfg := cfg
+	rcfg.URL, rcfg.Password = cfg.ReplicaURL, cfg.ReplicaPassword
+	conn, host, err := connectServed(ctx, rcfg, rcfg.URL, thr)
+	if err != nil {
+		return nil, err
+	}
+	res.ConnInfo.ReplicaHost = host
+	var inRecovery bool
+	if err := queryRow(ctx, conn, sqlInRecovery, &inRecovery); err == nil && !inRecovery {
+		res.Errors = append(res.Errors, fmt.Sprintf("replica: %s is not a standby; offloaded collectors still run there", host))
 	}
+	return &session{cfg: rcfg, conn: conn, host: host, thr: thr}, nil
+}
 
-	// 5. Invalid Indexes - Failed concurrent index builds
-	if rows, err := conn.Query(ctx, `SELECT n.nspname as schema,
-			t.relname as table_name,
-			i.relname as index_name,
-			pg_relation_size(i.oid) as size_bytes,
-			pg_get_indexdef(i.oid) as ddl,
-			CASE WHEN NOT ix.indisvalid THEN 'invalid'
-				 WHEN NOT ix.indisready THEN 'not ready'
-				 ELSE 'unknown' END as reason
-		FROM pg_index ix
-		JOIN pg_class i ON i.oid = ix.indexrelid
-		JOIN pg_class t ON t.oid = ix.indrelid
-		JOIN pg_namespace n ON n.oid = t.relnamespace
-		WHERE (NOT ix.indisvalid OR NOT ix.indisready)
-		  AND n.nspname NOT IN ('pg_catalog', 'information_schema')
-		ORDER BY pg_relation_size(i.oid) DESC`); err == nil {
-		for rows.Next() {
-			var ii InvalidIndex
-			_ = rows.Scan(&ii.Schema, &ii.Table, &ii.Name, &ii.SizeBytes, &ii.DDL, &ii.Reason)
-			res.InvalidIndexes = append(res.InvalidIndexes, ii)
-		}
-		rows.Close()
+// reconnect replaces the session connection after it was closed. A multi-host
+// URL may land on another host (e.g. after a failover): the returned note says
+// so, since the result then mixes data of both servers.
+func (s *session) reconnect(ctx context.Context) (string, error) {
+	conn, host, err := connectServed(ctx, s.cfg, s.cfg.URL, s.thr)
+	if err != nil {
+		return "", err
+	}
+	note := ""
+	if host != s.host {
+		note = fmt.Sprintf("reconnected to %s instead of %s; later sections come from that host", host, s.host)
+		s.host = host
 	}
+	s.conn = conn
+	return note, nil
+}
 
-	// 6. Foreign Keys Missing Indexes - FK columns without supporting index
-	if rows, err := conn.Query(ctx, `WITH fk_columns AS (
-			SELECT c.conname as constraint_name,
-				   n.nspname as schema,
-				   t.relname as table_name,
-				   array_to_string(array_agg(a.attname ORDER BY x.n), ', ') as columns,
-				   t2.relname as ref_table,
-				   array_to_string(array_agg(a2.attname ORDER BY x.n), ', ') as ref_columns,
-				   t.reltuples::bigint as table_rows,
-				   t.oid as table_oid
-			FROM pg_constraint c
-			JOIN pg_class t ON t.oid = c.conrelid
-			JOIN pg_class t2 ON t2.oid = c.confrelid
-			JOIN pg_namespace n ON n.oid = t.relnamespace
-			CROSS JOIN LATERAL unnest(c.conkey, c.confkey) WITH ORDINALITY AS x(attnum, ref_attnum, n)
-			JOIN pg_attribute a ON a.attrelid = t.oid AND a.attnum = x.attnum
-			JOIN pg_attribute a2 ON a2.attrelid = t2.oid AND a2.attnum = x.ref_attnum
-			WHERE c.contype = 'f'
-			  AND n.nspname NOT IN ('pg_catalog', 'information_schema')
-			GROUP BY c.conname, n.nspname, t.relname, t2.relname, t.reltuples, t.oid
-		)
-		SELECT f.schema, f.table_name, f.constraint_name, f.columns, f.ref_table, f.ref_columns, f.table_rows,
-			   'CREATE INDEX ON ' || quote_ident(f.schema) || '.' || quote_ident(f.table_name) ||
-			   ' (' || f.columns || ')' as suggested_ddl
-		FROM fk_columns f
-		WHERE NOT EXISTS (
-			SELECT 1 FROM pg_index ix
-			JOIN pg_class ci ON ci.oid = ix.indexrelid
-			WHERE ix.indrelid = f.table_oid
-			  AND (
-				  -- Check if FK columns are a prefix of index columns
-				  string_to_array(f.columns, ', ') <@ (
-					  SELECT array_agg(a.attname ORDER BY x.n)
-					  FROM unnest(ix.indkey) WITH ORDINALITY AS x(attnum, n)
-					  JOIN pg_attribute a ON a.attrelid = ix.indrelid AND a.attnum = x.attnum
-				  )
-			  )
-		)
-		ORDER BY f.table_rows DESC
-		LIMIT 30`); err == nil {
-		for rows.Next() {
-			var fk FKMissingIndex
-			_ = rows.Scan(&fk.Schema, &fk.Table, &fk.Constraint, &fk.Columns, &fk.RefTable, &fk.RefColumns, &fk.TableRows, &fk.SuggestedDDL)
-			res.FKMissingIndexes = append(res.FKMissingIndexes, fk)
+// skipRemaining marks the collectors of rest that have not finished as skipped.
+func skipRemaining(rest []collector, done map[string]bool, reason string) []SkippedCollector {
+	var out []SkippedCollector
+	for _, c := range rest {
+		if !done[c.name] {
+			out = append(out, SkippedCollector{Name: c.name, Reason: reason})
 		}
-		rows.Close()
 	}
+	return out
+}
 
-	// 7. Sequence Exhaustion Risk
-	// Note: pg_sequences view available in PG10+
-	if rows, err := conn.Query(ctx, `SELECT schemaname, sequencename,
-			last_value,
-			max_value,
-			increment_by,
-			CASE WHEN max_value > 0 AND last_value > 0
-				 THEN (last_value::float8 / max_value::float8 * 100)
-				 ELSE 0 END as pct_used,
-			CASE WHEN increment_by > 0
-				 THEN ((max_value - last_value) / increment_by)
-				 ELSE 0 END as calls_left
-		FROM pg_sequences
-		WHERE last_value IS NOT NULL
-		  AND max_value > 0
-		  AND (last_value::float8 / max_value::float8) > 0.5
-		ORDER BY (last_value::float8 / max_value::float8) DESC
-		LIMIT 20`); err == nil {
-		for rows.Next() {
-			var sq SequenceHealth
-			_ = rows.Scan(&sq.Schema, &sq.Name, &sq.LastValue, &sq.MaxValue, &sq.Increment, &sq.PctUsed, &sq.CallsLeft)
-			res.SequenceHealth = append(res.SequenceHealth, sq)
-		}
-		rows.Close()
+// cancelReason describes why the run context ended.
+func cancelReason(ctx context.Context) string {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return "run timeout reached"
 	}
+	return "run cancelled"
+}
 
-	// 8. Prepared Transactions (2PC) - Can block vacuum and hold locks
-	if rows, err := conn.Query(ctx, `SELECT transaction::text, gid, owner, database,
-			prepared,
-			(now() - prepared)::text as age
-		FROM pg_prepared_xacts
-		ORDER BY prepared ASC`); err == nil {
-		for rows.Next() {
-			var px PreparedXact
-			_ = rows.Scan(&px.Transaction, &px.GID, &px.Owner, &px.Database, &px.Prepared, &px.Age)
-			res.PreparedXacts = append(res.PreparedXacts, px)
+// doneNames lists finished collectors in registry order.
+func doneNames(done map[string]bool) []string {
+	names := make([]string, 0, len(done))
+	for _, c := range collectors {
+		if done[c.name] {
+			names = append(names, c.name)
 		}
-		rows.Close()
 	}
-
-	return res, nil
+	return names
 }
 
 func hasPgStatStatements(ctx context.Context, conn *pgx.Conn) bool {
 	// 1) check installed extension in current DB
 	var hasExt bool
-	_ = queryRow(ctx, conn, `select exists(select 1 from pg_extension where extname='pg_stat_statements')`, &hasExt)
+	_ = queryRow(ctx, conn, sqlPSSExtension, &hasExt)
 	if hasExt {
 		return true
 	}
 	// 2) check relation exists in any schema
 	var hasRel bool
-	_ = queryRow(ctx, conn, `select exists(
-		select 1 from pg_class c join pg_namespace n on n.oid=c.relnamespace
-		where c.relname='pg_stat_statements'
-	)`, &hasRel)
+	_ = queryRow(ctx, conn, sqlPSSRelation, &hasRel)
 	if hasRel {
 		return true
 	}
 	// 3) check function exists
 	var hasProc bool
-	_ = queryRow(ctx, conn, `select exists(
-		select 1 from pg_proc p join pg_namespace n on n.oid=p.pronamespace
-		where p.proname in ('pg_stat_statements_reset','pg_stat_statements')
-	)`, &hasProc)
+	_ = queryRow(ctx, conn, sqlPSSFunction, &hasProc)
 	if hasProc {
 		return true
 	}
 	// 4) last resort: probe a select
-	if _, err := conn.Exec(ctx, `select 1 from pg_stat_statements limit 1`); err == nil {
+	if _, err := conn.Exec(ctx, sqlPSSProbe); err == nil {
 		return true
 	}
 	return false
@@ -1941,54 +2711,69 @@ const (
 	orderByIOBlocks
 )
 
-// fetchPSS tries new (total_exec_time/mean_exec_time) first, then old (total_time/mean_time)
-func fetchPSS(ctx context.Context, conn *pgx.Conn, schema string, ord pssOrder, includeIO bool, includeBlk bool) ([]Statement, bool) {
-	if sts, ok := fetchPSSVariant(ctx, conn, schema, "total_exec_time", "mean_exec_time", ord, includeIO, includeBlk); ok {
+// fetchPSS tries new (total_exec_time/mean_exec_time) first, then old (total_time/mean_time).
+// Both carry min/max/stddev execution times (9.5+).
+func fetchPSS(ctx context.Context, conn *pgx.Conn, f topFilter, schema string, ord pssOrder, includeIO bool, includeBlk bool) ([]Statement, bool) {
+	if sts, ok := fetchPSSVariant(ctx, conn, f, schema, "total_exec_time", "mean_exec_time", ord, includeIO, includeBlk); ok {
 		return sts, true
 	}
-	if sts, ok := fetchPSSVariant(ctx, conn, schema, "total_time", "mean_time", ord, includeIO, includeBlk); ok {
+	if ctx.Err() != nil {
+		return nil, false
+	}
+	if sts, ok := fetchPSSVariant(ctx, conn, f, schema, "total_time", "mean_time", ord, includeIO, includeBlk); ok {
 		return sts, true
 	}
 	return nil, false
 }
 
-func fetchPSSVariant(ctx context.Context, conn *pgx.Conn, schema, colTotal, colMean string, ord pssOrder, includeIO bool, includeBlk bool) ([]Statement, bool) {
-	orderExpr := ""
-	switch ord {
-	case orderByTotal:
-		orderExpr = colTotal
-	case orderByCPUApprox:
-		if includeIO {
-			orderExpr = fmt.Sprintf("(%s - blk_read_time - blk_write_time)", colTotal)
-		} else {
-			orderExpr = colTotal
-		}
-	case orderByIO:
+func fetchPSSVariant(ctx context.Context, conn *pgx.Conn, f topFilter, schema, colTotal, colMean string, ord pssOrder, includeIO bool, includeBlk bool) ([]Statement, bool) {
+	q := pssQuery(schema, colTotal, colMean, ord, includeIO, includeBlk, f)
+	rows, err := conn.Query(ctx, q)
+	if err != nil {
+		return nil, false
+	}
+	defer rows.Close()
+	var out []Statement
+	for rows.Next() {
+		var st Statement
+		// Build scan targets dynamically based on selected columns
+		scanArgs := []any{&st.QueryID, &st.Query, &st.Calls, &st.TotalTime, &st.MeanTime, &st.MinTime, &st.MaxTime, &st.StddevTime, &st.Rows}
 		if includeIO {
-			orderExpr = "(blk_read_time + blk_write_time)"
-		} else {
-			orderExpr = colTotal
+			scanArgs = append(scanArgs, &st.BlkReadTime, &st.BlkWriteTime)
 		}
-	case orderByCalls:
-		orderExpr = "calls"
-	case orderByIOBlocks:
 		if includeBlk {
-			orderExpr = "(coalesce(shared_blks_read,0)+coalesce(shared_blks_written,0)+coalesce(local_blks_read,0)+coalesce(local_blks_written,0)+coalesce(temp_blks_read,0)+coalesce(temp_blks_written,0))"
-		} else {
-			orderExpr = colTotal
+			scanArgs = append(scanArgs, &st.SharedBlksRead, &st.SharedBlksWrite, &st.LocalBlksRead, &st.LocalBlksWrite, &st.TempBlksRead, &st.TempBlksWrite)
+		}
+		if err := rows.Scan(scanArgs...); err != nil {
+			continue
+		}
+		if keepStatement(&st, includeIO) {
+			out = append(out, st)
 		}
 	}
-	fromRel := qualifiedPSS(schema)
-	selectIO := ""
+	return out, true
+}
+
+// keepStatement splits the execution time of st into CPU and I/O time and
+// reports whether it is worth listing: trivial utility statements are not.
+func keepStatement(st *Statement, includeIO bool) bool {
 	if includeIO {
-		selectIO = ", blk_read_time, blk_write_time"
-	}
-	selectBlk := ""
-	if includeBlk {
-		selectBlk = ", shared_blks_read, shared_blks_written, local_blks_read, local_blks_written, temp_blks_read, temp_blks_written"
-	}
-	q := fmt.Sprintf(`select query, calls, %s as total_time, %s as mean_time, rows%s%s from %s order by %s desc nulls last limit 20`, colTotal, colMean, selectIO, selectBlk, fromRel, orderExpr)
-	rows, err := conn.Query(ctx, q)
+		st.IOTime = st.BlkReadTime + st.BlkWriteTime
+		st.CPUTime = st.TotalTime - st.IOTime
+	} else {
+		st.IOTime = 0
+		st.CPUTime = st.TotalTime
+	}
+	st.Truncated = sqlparse.Truncated(st.Query)
+	// Filter out trivial utility statements
+	q := strings.ToUpper(strings.TrimSpace(st.Query))
+	return !strings.HasPrefix(q, "COMMIT") && !strings.HasPrefix(q, "BEGIN") && !strings.HasPrefix(q, "DISCARD ALL")
+}
+
+// fetchPGSM reads one top statements list from rel, the pg_stat_monitor
+// buckets read, summed per statement.
+func fetchPGSM(ctx context.Context, conn *pgx.Conn, f topFilter, rel string, ord pssOrder, includeIO bool, includeBlk bool) ([]Statement, bool) {
+	rows, err := conn.Query(ctx, pgsmQuery(rel, ord, includeIO, includeBlk, f))
 	if err != nil {
 		return nil, false
 	}
@@ -1996,32 +2781,222 @@ func fetchPSSVariant(ctx context.Context, conn *pgx.Conn, schema, colTotal, colM
 	var out []Statement
 	for rows.Next() {
 		var st Statement
-		// Build scan targets dynamically based on selected columns
-		scanArgs := []any{&st.Query, &st.Calls, &st.TotalTime, &st.MeanTime, &st.Rows}
+		scanArgs := []any{&st.QueryID, &st.Query, &st.Calls, &st.TotalTime, &st.MeanTime, &st.MinTime, &st.MaxTime, &st.StddevTime, &st.Rows}
 		if includeIO {
 			scanArgs = append(scanArgs, &st.BlkReadTime, &st.BlkWriteTime)
 		}
 		if includeBlk {
 			scanArgs = append(scanArgs, &st.SharedBlksRead, &st.SharedBlksWrite, &st.LocalBlksRead, &st.LocalBlksWrite, &st.TempBlksRead, &st.TempBlksWrite)
 		}
+		scanArgs = append(scanArgs, &st.ClientIPs, &st.Comments)
 		if err := rows.Scan(scanArgs...); err != nil {
 			continue
 		}
-		if includeIO {
-			st.IOTime = st.BlkReadTime + st.BlkWriteTime
-			st.CPUTime = st.TotalTime - st.IOTime
-		} else {
-			st.IOTime = 0
-			st.CPUTime = st.TotalTime
+		if keepStatement(&st, includeIO) {
+			out = append(out, st)
+		}
+	}
+	return out, rows.Err() == nil
+}
+
+// reNumber matches the numbers of a pg_stat_monitor histogram range.
+var reNumber = regexp.MustCompile(`[0-9]+(?:\.[0-9]+)?`)
+
+// parseHistogramBounds returns the upper bound of every range reported by
+// pg_stat_monitor's range(), e.g. "{{0.000 - 3.000}" or "(3 - 10}". A range
+// with a single number is the open-ended last bucket (bound 0). Nil means
+// the ranges could not be parsed.
+func parseHistogramBounds(ranges []string) []float64 {
+	if len(ranges) == 0 {
+		return nil
+	}
+	out := make([]float64, len(ranges))
+	for i, r := range ranges {
+		nums := reNumber.FindAllString(r, -1)
+		switch {
+		case len(nums) == 0:
+			return nil
+		case len(nums) == 1 && i == len(ranges)-1:
+			out[i] = 0
+		default:
+			v, err := strconv.ParseFloat(nums[len(nums)-1], 64)
+			if err != nil {
+				return nil
+			}
+			out[i] = v
+		}
+	}
+	return out
+}
+
+// addHistograms attaches the response time histogram of every listed
+// statement, summed over the pg_stat_monitor rows of rel. Without readable
+// bucket bounds the lists are left as they are and the percentiles are
+// estimated from the mean and standard deviation.
+func addHistograms(ctx context.Context, conn *pgx.Conn, schema, rel string, lists ...[]Statement) {
+	var ranges []string
+	if err := queryRow(ctx, conn, pgsmRangeQuery(schema), &ranges); err != nil {
+		return
+	}
+	bounds := parseHistogramBounds(ranges)
+	if bounds == nil {
+		return
+	}
+	seen := map[int64]bool{}
+	var ids []int64
+	for _, list := range lists {
+		for _, st := range list {
+			if st.QueryID != 0 && !seen[st.QueryID] {
+				seen[st.QueryID] = true
+				ids = append(ids, st.QueryID)
+			}
+		}
+	}
+	if len(ids) == 0 {
+		return
+	}
+	rows, err := conn.Query(ctx, pgsmHistogramQuery(rel), ids)
+	if err != nil {
+		return
+	}
+	sums := map[int64][]float64{}
+	for rows.Next() {
+		var id int64
+		var calls []string
+		if err := rows.Scan(&id, &calls); err != nil || len(calls) != len(bounds) {
+			continue
+		}
+		sum := sums[id]
+		if sum == nil {
+			sum = make([]float64, len(bounds))
+			sums[id] = sum
+		}
+		for i, c := range calls {
+			if n, err := strconv.ParseFloat(strings.TrimSpace(c), 64); err == nil {
+				sum[i] += n
+			}
+		}
+	}
+	rows.Close()
+	for _, list := range lists {
+		for i := range list {
+			sum, ok := sums[list[i].QueryID]
+			if !ok {
+				continue
+			}
+			h := make([]LatencyBucket, len(bounds))
+			for j, b := range bounds {
+				h[j] = LatencyBucket{UpperMs: b, Calls: sum[j]}
+			}
+			list[i].Histogram = h
 		}
-		// Filter out trivial utility statements
-		q := strings.ToUpper(strings.TrimSpace(st.Query))
-		if strings.HasPrefix(q, "COMMIT") || strings.HasPrefix(q, "BEGIN") || strings.HasPrefix(q, "DISCARD ALL") {
+	}
+}
+
+// pssGroup selects the dimension used to attribute pg_stat_statements load.
+type pssGroup int
+
+const (
+	pssGroupByRole pssGroup = iota
+	pssGroupByDatabase
+)
+
+// fetchPSSLoad sums calls and execution time per role or database. Shares are
+// computed over all groups before the limit applies.
+func fetchPSSLoad(ctx context.Context, conn *pgx.Conn, rel string, group pssGroup) []StatementLoad {
+	for _, colTotal := range []string{"total_exec_time", "total_time"} {
+		q := pssLoadQuery(rel, colTotal, group)
+		rows, err := conn.Query(ctx, q)
+		if err != nil {
 			continue
 		}
-		out = append(out, st)
+		var out []StatementLoad
+		for rows.Next() {
+			var l StatementLoad
+			if err := rows.Scan(&l.Name, &l.Calls, &l.TotalTime, &l.Share); err == nil {
+				out = append(out, l)
+			}
+		}
+		err = rows.Err()
+		rows.Close()
+		if err == nil {
+			return out
+		}
 	}
-	return out, true
+	return nil
+}
+
+// appTagKeys are comment tag names that identify the application, in order of preference.
+var appTagKeys = []string{"app", "application", "application_name", "service"}
+
+// fetchPSSAppLoad aggregates pg_stat_statements load by the application tag of
+// marginalia/sqlcommenter style query comments (/*app:checkout,controller:cart*/).
+// The tag value is extracted in SQL before grouping, so per-request tags
+// such as sqlcommenter's traceparent do not split an application into
+// separate rows; values are decoded here. Statements without an app tag are
+// not listed, so shares may sum to less than 100%.
+func fetchPSSAppLoad(ctx context.Context, conn *pgx.Conn, rel string) []StatementLoad {
+	for _, colTotal := range []string{"total_exec_time", "total_time"} {
+		q := pssAppLoadQuery(rel, colTotal)
+		rows, err := conn.Query(ctx, q)
+		if err != nil {
+			continue
+		}
+		byApp := map[string]*StatementLoad{}
+		for rows.Next() {
+			var tag string
+			var l StatementLoad
+			if err := rows.Scan(&tag, &l.Calls, &l.TotalTime, &l.Share); err != nil {
+				continue
+			}
+			app := tagValue(tag)
+			if app == "" {
+				continue
+			}
+			agg, ok := byApp[app]
+			if !ok {
+				agg = &StatementLoad{Name: app}
+				byApp[app] = agg
+			}
+			agg.Calls += l.Calls
+			agg.TotalTime += l.TotalTime
+			agg.Share += l.Share
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			continue
+		}
+		out := make([]StatementLoad, 0, len(byApp))
+		for _, l := range byApp {
+			out = append(out, *l)
+		}
+		sort.Slice(out, func(i, j int) bool {
+			if out[i].TotalTime != out[j].TotalTime {
+				return out[i].TotalTime > out[j].TotalTime
+			}
+			return out[i].Name < out[j].Name
+		})
+		return out
+	}
+	return nil
+}
+
+// appTagPattern matches tag key of a query comment body in marginalia
+// (app:checkout,controller:cart) or sqlcommenter (app='checkout') format and
+// captures its raw value.
+func appTagPattern(key string) string {
+	return `(?i)(?:^|,)\s*` + key + `\s*[:=]\s*['"]?([^,'"]+)`
+}
+
+// tagValue unquotes a raw comment tag value and URL-decodes it when possible,
+// as sqlcommenter encodes values.
+func tagValue(raw string) string {
+	val := strings.Trim(strings.TrimSpace(raw), `'"`)
+	if dec, err := url.QueryUnescape(val); err == nil {
+		val = dec
+	}
+	return strings.TrimSpace(val)
 }
 
 func qualifiedPSS(schema string) string {
@@ -2031,6 +3006,19 @@ func qualifiedPSS(schema string) string {
 	return quoteIdent(schema) + ".pg_stat_statements"
 }
 
+// pgsmRelation is the pg_stat_monitor view, limited to the time buckets that
+// started within window when window is set.
+func pgsmRelation(schema string, window time.Duration) string {
+	rel := "pg_stat_monitor"
+	if schema != "" {
+		rel = quoteIdent(schema) + ".pg_stat_monitor"
+	}
+	if window <= 0 {
+		return rel
+	}
+	return fmt.Sprintf("(select * from %s where bucket_start_time >= now() - interval '%d seconds')", rel, int64(window.Seconds()))
+}
+
 func quoteIdent(s string) string {
 	out := `"`
 	for i := 0; i < len(s); i++ {
@@ -2045,28 +3033,43 @@ func quoteIdent(s string) string {
 
 func findPgStatStatementsSchema(ctx context.Context, conn *pgx.Conn) string {
 	var schema string
-	_ = queryRow(ctx, conn, `select n.nspname from pg_class c join pg_namespace n on n.oid=c.relnamespace where c.relname='pg_stat_statements' limit 1`, &schema)
+	_ = queryRow(ctx, conn, sqlPSSSchema, &schema)
 	return schema
 }
 
+// findPgStatMonitor returns the schema of a readable pg_stat_monitor view.
+// The view errors out when the library is not preloaded, so it is probed.
+func findPgStatMonitor(ctx context.Context, conn *pgx.Conn) (string, bool) {
+	var schema string
+	if err := queryRow(ctx, conn, sqlPGSMSchema, &schema); err != nil || schema == "" {
+		return "", false
+	}
+	if _, err := conn.Exec(ctx, pgsmProbeQuery(schema)); err != nil {
+		return "", false
+	}
+	return schema, true
+}
+
+// hasPGSMCols runs one of the pg_stat_monitor column checks for schema.
+func hasPGSMCols(ctx context.Context, conn *pgx.Conn, sql, schema string) bool {
+	var has bool
+	ctx2, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	_ = conn.QueryRow(ctx2, sql, schema).Scan(&has)
+	return has
+}
+
 func hasPSSIOCols(ctx context.Context, conn *pgx.Conn, schema string) bool {
 	// Check whether blk_read_time and blk_write_time exist in the view
 	var has bool
 	if schema == "" {
-		_ = queryRow(ctx, conn, `select exists(
-			select 1 from information_schema.columns
-			where table_name='pg_stat_statements' and column_name in ('blk_read_time','blk_write_time')
-			group by table_name having count(*)=2)`, &has)
+		_ = queryRow(ctx, conn, sqlPSSIOCols, &has)
 		return has
 	}
 	// schema-qualified check
-	q := `select exists(
-			select 1 from information_schema.columns
-			where table_schema=$1 and table_name='pg_stat_statements' and column_name in ('blk_read_time','blk_write_time')
-			group by table_schema, table_name having count(*)=2)`
 	ctx2, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
-	row := conn.QueryRow(ctx2, q, schema)
+	row := conn.QueryRow(ctx2, sqlPSSIOColsInSchema, schema)
 	_ = row.Scan(&has)
 	return has
 }
@@ -2075,18 +3078,12 @@ func hasPSSBlockCols(ctx context.Context, conn *pgx.Conn, schema string) bool {
 	// Check for block counters columns presence
 	var has bool
 	if schema == "" {
-		_ = queryRow(ctx, conn, `select exists(
-			select 1 from information_schema.columns
-			where table_name='pg_stat_statements' and column_name in ('shared_blks_read','shared_blks_written','local_blks_read','local_blks_written','temp_blks_read','temp_blks_written')
-			group by table_name having count(*)=6)`, &has)
+		_ = queryRow(ctx, conn, sqlPSSBlockCols, &has)
 		return has
 	}
 	ctx2, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
-	row := conn.QueryRow(ctx2, `select exists(
-		select 1 from information_schema.columns
-		where table_schema=$1 and table_name='pg_stat_statements' and column_name in ('shared_blks_read','shared_blks_written','local_blks_read','local_blks_written','temp_blks_read','temp_blks_written')
-		group by table_schema, table_name having count(*)=6)`, schema)
+	row := conn.QueryRow(ctx2, sqlPSSBlockColsInSchema, schema)
 	_ = row.Scan(&has)
 	return has
 }
@@ -0,0 +1,170 @@
+package collect
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// connect opens a collector session using the safety settings of cfg:
+// statement_timeout and default_transaction_isolation are sent as startup
+// parameters so they apply to every query, including catalog-heavy ones, and
+// thr (when non-nil) paces queries across all sessions of a run. cfg.Dial
+// routes connections through a tunnel, proxy or cloud connector and
+// cfg.Password supplies fresh auth tokens. Transient failures (DNS blips,
+// refused connections, server restarts) are retried per cfg.Retries.
+func connect(ctx context.Context, cfg Config, url string, thr *throttle) (*pgx.Conn, error) {
+	conn, _, err := connectServed(ctx, cfg, url, thr)
+	return conn, err
+}
+
+// connectServed is connect that also returns the host:port that accepted the
+// session. Multi-host URLs (postgres://h1,h2/db?target_session_attrs=primary)
+// are tried in order until a host matches target_session_attrs, so the
+// serving host is only known once connected.
+func connectServed(ctx context.Context, cfg Config, url string, thr *throttle) (*pgx.Conn, string, error) {
+	pc, err := pgx.ParseConfig(url)
+	if err != nil {
+		return nil, "", err
+	}
+	if cfg.StatementTimeout > 0 {
+		pc.RuntimeParams["statement_timeout"] = strconv.FormatInt(cfg.StatementTimeout.Milliseconds(), 10)
+	}
+	if cfg.RepeatableRead {
+		pc.RuntimeParams["default_transaction_isolation"] = "repeatable read"
+	}
+	if thr != nil {
+		pc.Tracer = thr
+	}
+	if cfg.Password != nil {
+		pw, err := cfg.Password(ctx)
+		if err != nil {
+			return nil, "", fmt.Errorf("auth token: %w", err)
+		}
+		pc.Password = pw
+	}
+	if cfg.Dial != nil {
+		pc.DialFunc = cfg.Dial
+		// Leave name resolution to the remote side of the tunnel
+		pc.LookupFunc = func(_ context.Context, host string) ([]string, error) {
+			return []string{host}, nil
+		}
+		if cfg.DialSecure {
+			pc.TLSConfig = nil
+			pc.Fallbacks = nil
+		}
+	}
+	served := trackServedHost(pc)
+	var conn *pgx.Conn
+	err = withRetry(ctx, cfg.Retries, cfg.RetryBackoff, func() error {
+		var err error
+		conn, err = pgx.ConnectConfig(ctx, pc)
+		return err
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return conn, served(), nil
+}
+
+// trackServedHost wraps the lookup and dial functions of pc to remember the
+// last address dialed, mapped back to the host name it was resolved from.
+// pgconn stops at the first host that passes target_session_attrs, so after a
+// successful connect that address is the serving host.
+func trackServedHost(pc *pgx.ConnConfig) func() string {
+	var mu sync.Mutex
+	names := map[string]string{} // resolved address -> host name
+	last := ""
+
+	lookup := pc.LookupFunc
+	pc.LookupFunc = func(ctx context.Context, host string) ([]string, error) {
+		addrs, err := lookup(ctx, host)
+		mu.Lock()
+		for _, a := range addrs {
+			names[a] = host
+		}
+		mu.Unlock()
+		return addrs, err
+	}
+	dial := pc.DialFunc
+	pc.DialFunc = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		c, err := dial(ctx, network, addr)
+		if err == nil {
+			mu.Lock()
+			last = addr
+			mu.Unlock()
+		}
+		return c, err
+	}
+	return func() string {
+		mu.Lock()
+		defer mu.Unlock()
+		host, port, err := net.SplitHostPort(last)
+		if err != nil {
+			return last // unix socket path
+		}
+		if name, ok := names[host]; ok {
+			host = name
+		}
+		return net.JoinHostPort(host, port)
+	}
+}
+
+// throttle spaces out collector queries to honor Config.MaxQPS and
+// Config.QueryDelay. It is installed as a pgx query tracer so every query of
+// every session is paced without touching individual collectors.
+type throttle struct {
+	mu       sync.Mutex
+	interval time.Duration // minimum spacing derived from MaxQPS
+	delay    time.Duration // fixed pause before each query
+	next     time.Time     // earliest start of the next query
+}
+
+// newThrottle returns a throttle for cfg, or nil when no pacing is configured.
+func newThrottle(cfg Config) *throttle {
+	t := &throttle{delay: cfg.QueryDelay}
+	if cfg.MaxQPS > 0 {
+		t.interval = time.Duration(float64(time.Second) / cfg.MaxQPS)
+	}
+	if t.interval <= 0 && t.delay <= 0 {
+		return nil
+	}
+	return t
+}
+
+// wait blocks until the next query may start or ctx is done.
+func (t *throttle) wait(ctx context.Context) {
+	t.mu.Lock()
+	now := time.Now()
+	start := now.Add(t.delay)
+	if start.Before(t.next) {
+		start = t.next
+	}
+	t.next = start.Add(t.interval)
+	t.mu.Unlock()
+
+	d := start.Sub(now)
+	if d <= 0 {
+		return
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+}
+
+// TraceQueryStart implements pgx.QueryTracer.
+func (t *throttle) TraceQueryStart(ctx context.Context, _ *pgx.Conn, _ pgx.TraceQueryStartData) context.Context {
+	t.wait(ctx)
+	return ctx
+}
+
+// TraceQueryEnd implements pgx.QueryTracer.
+func (t *throttle) TraceQueryEnd(context.Context, *pgx.Conn, pgx.TraceQueryEndData) {}
@@ -0,0 +1,82 @@
+package collect
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// TestNewThrottle verifies pacing is only enabled when configured.
+func TestNewThrottle(t *testing.T) {
+	if thr := newThrottle(Config{}); thr != nil {
+		t.Errorf("newThrottle() = %+v, expected nil without limits", thr)
+	}
+	thr := newThrottle(Config{MaxQPS: 4})
+	if thr == nil || thr.interval != 250*time.Millisecond {
+		t.Errorf("newThrottle(MaxQPS: 4) = %+v, expected 250ms interval", thr)
+	}
+	if thr := newThrottle(Config{QueryDelay: time.Millisecond}); thr == nil || thr.delay != time.Millisecond {
+		t.Errorf("newThrottle(QueryDelay) = %+v", thr)
+	}
+}
+
+// TestThrottleWait verifies consecutive queries are spaced by the interval.
+func TestThrottleWait(t *testing.T) {
+	thr := newThrottle(Config{MaxQPS: 50}) // 20ms spacing
+	ctx := context.Background()
+
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		thr.wait(ctx)
+	}
+	// The first query starts immediately, the next three wait 20ms each.
+	if elapsed := time.Since(start); elapsed < 55*time.Millisecond {
+		t.Errorf("4 queries at 50 QPS took %v, expected at least 60ms", elapsed)
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	thr = newThrottle(Config{QueryDelay: time.Hour})
+	done := make(chan struct{})
+	go func() {
+		thr.wait(cancelled)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("wait() did not return after context cancellation")
+	}
+}
+
+// TestTrackServedHost verifies the last dialed address is reported under the
+// host name it was resolved from.
+func TestTrackServedHost(t *testing.T) {
+	pc, err := pgx.ParseConfig("postgres://u@pg-a:5432,pg-b:5433/app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pc.LookupFunc = func(_ context.Context, host string) ([]string, error) {
+		return map[string][]string{"pg-a": {"10.0.0.1"}, "pg-b": {"10.0.0.2"}}[host], nil
+	}
+	pc.DialFunc = func(_ context.Context, _, addr string) (net.Conn, error) {
+		c, s := net.Pipe()
+		_ = s.Close()
+		return c, nil
+	}
+	served := trackServedHost(pc)
+	ctx := context.Background()
+	for _, h := range []string{"pg-a", "pg-b"} {
+		if _, err := pc.LookupFunc(ctx, h); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, _ = pc.DialFunc(ctx, "tcp", "10.0.0.1:5432")
+	_, _ = pc.DialFunc(ctx, "tcp", "10.0.0.2:5433")
+	if got := served(); got != "pg-b:5433" {
+		t.Errorf("served() = %q, expected pg-b:5433", got)
+	}
+}
@@ -0,0 +1,102 @@
+package collect
+
+import "sort"
+
+// sizeTreeMaxChildren caps the children kept per database and schema; the
+// rest are folded into one "other" node so the tree stays readable.
+const sizeTreeMaxChildren = 20
+
+// Size tree node kinds.
+const (
+	SizeCluster  = "cluster"
+	SizeDatabase = "database"
+	SizeSchema   = "schema"
+	SizeTable    = "table" // heap and TOAST, without the indexes listed beside it
+	SizeIndex    = "index"
+	SizeOther    = "other" // catalogs, unlisted relations and relations folded by the caps
+)
+
+// SizeNode is a node of the storage hierarchy: cluster → database → schema →
+// table and index. Bytes of a node cover its children; children are sorted
+// largest first, with any "other" node last.
+type SizeNode struct {
+	Name     string     `json:"Name"`
+	Kind     string     `json:"Kind"`
+	Bytes    int64      `json:"Bytes"`
+	Children []SizeNode `json:"Children,omitempty"`
+}
+
+// buildSizeTree assembles the storage hierarchy from the database sizes, the
+// schema totals and the collected tables and indexes; nil without database
+// sizes. Databases whose tables were not collected are leaves.
+func buildSizeTree(res *Result) *SizeNode {
+	root := &SizeNode{Kind: SizeCluster}
+	for _, db := range res.DBs {
+		if db.SizeBytes <= 0 {
+			continue
+		}
+		node := SizeNode{Name: db.Name, Kind: SizeDatabase, Bytes: db.SizeBytes}
+		for _, s := range res.Catalog.Schemas {
+			if s.Database == db.Name && s.TableBytes > 0 {
+				node.Children = append(node.Children, schemaSizeNode(s, res.Tables, res.Indexes))
+			}
+		}
+		node.Children = capSizeNodes(node.Children, node.Bytes)
+		root.Bytes += node.Bytes
+		root.Children = append(root.Children, node)
+	}
+	if len(root.Children) == 0 {
+		return nil
+	}
+	sortSizeNodes(root.Children)
+	return root
+}
+
+// schemaSizeNode lists the collected tables and indexes of a schema. A table
+// node holds its size less the indexes listed beside it.
+func schemaSizeNode(s SchemaTotals, tables []TableStat, indexes []IndexStat) SizeNode {
+	node := SizeNode{Name: s.Schema, Kind: SizeSchema, Bytes: s.TableBytes}
+	listed := map[string]int64{}
+	for _, ix := range indexes {
+		if ix.Database == s.Database && ix.Schema == s.Schema && ix.SizeBytes > 0 {
+			listed[ix.Table] += ix.SizeBytes
+			node.Children = append(node.Children, SizeNode{Name: ix.Name, Kind: SizeIndex, Bytes: ix.SizeBytes})
+		}
+	}
+	for _, t := range tables {
+		if t.Database == s.Database && t.Schema == s.Schema {
+			if b := t.SizeBytes - listed[t.Name]; b > 0 {
+				node.Children = append(node.Children, SizeNode{Name: t.Name, Kind: SizeTable, Bytes: b})
+			}
+		}
+	}
+	node.Children = capSizeNodes(node.Children, node.Bytes)
+	return node
+}
+
+// capSizeNodes keeps the sizeTreeMaxChildren largest nodes and adds an
+// "other" node for the rest of total.
+func capSizeNodes(nodes []SizeNode, total int64) []SizeNode {
+	sortSizeNodes(nodes)
+	if len(nodes) > sizeTreeMaxChildren {
+		nodes = nodes[:sizeTreeMaxChildren]
+	}
+	rest := total
+	for _, n := range nodes {
+		rest -= n.Bytes
+	}
+	if rest > 0 && len(nodes) > 0 {
+		nodes = append(nodes, SizeNode{Kind: SizeOther, Bytes: rest})
+	}
+	return nodes
+}
+
+// sortSizeNodes orders nodes largest first, by name on ties.
+func sortSizeNodes(nodes []SizeNode) {
+	sort.SliceStable(nodes, func(i, j int) bool {
+		if nodes[i].Bytes != nodes[j].Bytes {
+			return nodes[i].Bytes > nodes[j].Bytes
+		}
+		return nodes[i].Name < nodes[j].Name
+	})
+}
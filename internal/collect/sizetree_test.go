@@ -0,0 +1,56 @@
+package collect
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// TestBuildSizeTree verifies the storage hierarchy: table nodes exclude the
+// indexes listed beside them, the remainder of each level is an "other" node
+// and databases without collected tables are leaves.
+func TestBuildSizeTree(t *testing.T) {
+	res := Result{
+		DBs: []Database{{Name: "app", SizeBytes: 1000}, {Name: "logs", SizeBytes: 300}, {Name: "empty"}},
+		Catalog: CatalogTotals{Schemas: []SchemaTotals{
+			{Database: "app", Schema: "public", TableBytes: 700},
+			{Database: "app", Schema: "audit", TableBytes: 200},
+		}},
+		Tables: []TableStat{
+			{Database: "app", Schema: "public", Name: "orders", SizeBytes: 500},
+			{Database: "app", Schema: "audit", Name: "events", SizeBytes: 200},
+		},
+		Indexes: []IndexStat{{Database: "app", Schema: "public", Table: "orders", Name: "orders_pkey", SizeBytes: 100}},
+	}
+	var lines []string
+	var walk func(n SizeNode, depth int)
+	walk = func(n SizeNode, depth int) {
+		lines = append(lines, fmt.Sprintf("%s%s %s %d", strings.Repeat(" ", depth), n.Kind, n.Name, n.Bytes))
+		for _, c := range n.Children {
+			walk(c, depth+1)
+		}
+	}
+	tree := buildSizeTree(&res)
+	if tree == nil {
+		t.Fatal("expected a size tree")
+	}
+	walk(*tree, 0)
+	want := []string{
+		"cluster  1300",
+		" database app 1000",
+		"  schema public 700",
+		"   table orders 400",
+		"   index orders_pkey 100",
+		"   other  200",
+		"  schema audit 200",
+		"   table events 200",
+		"  other  100",
+		" database logs 300",
+	}
+	if got := strings.Join(lines, "\n"); got != strings.Join(want, "\n") {
+		t.Errorf("size tree:\n%s\nexpected:\n%s", got, strings.Join(want, "\n"))
+	}
+	if buildSizeTree(&Result{}) != nil {
+		t.Error("expected no tree without database sizes")
+	}
+}
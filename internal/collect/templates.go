@@ -0,0 +1,56 @@
+package collect
+
+import "context"
+
+// collectTemplate1 reads the locale of template1 and, connecting to it, the
+// user objects and extensions every new database would copy.
+func collectTemplate1(ctx context.Context, s *session, res *Result) {
+	t := &Template1{}
+	var allowConn bool
+	if err := s.conn.QueryRow(ctx, sqlTemplateLocale).Scan(&t.Encoding, &t.Collate, &t.Ctype, &allowConn); err != nil {
+		return
+	}
+	res.Template1 = t
+	if !allowConn || res.ConnInfo.CurrentDB == "template1" {
+		return
+	}
+	url := swapDBInURL(s.cfg.URL, "template1")
+	if url == "" {
+		return
+	}
+	conn, err := connect(ctx, s.cfg, url, s.thr)
+	if err != nil {
+		return
+	}
+	defer conn.Close(ctx)
+	if rows, err := conn.Query(ctx, sqlTemplateObjects); err == nil {
+		for rows.Next() {
+			var kind, name string
+			if err := rows.Scan(&kind, &name); err == nil {
+				t.Objects = append(t.Objects, kind+" "+name)
+			}
+		}
+		rows.Close()
+	}
+	for _, e := range queryExtensions(ctx, conn, "template1") {
+		if e.Name != "plpgsql" {
+			t.Extensions = append(t.Extensions, e.Name)
+		}
+	}
+}
+
+// collectDefaultPrivileges lists the ALTER DEFAULT PRIVILEGES entries of the
+// current database.
+func collectDefaultPrivileges(ctx context.Context, s *session, res *Result) {
+	rows, err := s.conn.Query(ctx, sqlDefaultPrivileges)
+	if err != nil {
+		return
+	}
+	for rows.Next() {
+		var d DefaultPrivilege
+		if err := rows.Scan(&d.Role, &d.Schema, &d.ObjectType, &d.ACL); err == nil {
+			res.DefaultPrivileges = append(res.DefaultPrivileges, d)
+		}
+	}
+	rows.Close()
+}
@@ -0,0 +1,241 @@
+package collect
+
+import "sort"
+
+// relationTopN bounds how many tables and indexes are kept per ranking (size,
+// rows, dead tuples, scans), so memory stays flat on catalogs with hundreds of
+// thousands of relations. Reports show far fewer rows than this.
+const relationTopN = 500
+
+// reportTopN is how many tables the precomputed report rankings keep.
+const reportTopN = 100
+
+// topN keeps the n highest-ranked items seen so far in a min-heap: adding is
+// O(log n) and memory is O(n) regardless of how many items are streamed.
+type topN[T any] struct {
+	n    int
+	less func(a, b T) bool // a ranks below b
+	h    []T
+}
+
+// newTopN returns an empty topN keeping at most n items ranked by less.
+func newTopN[T any](n int, less func(a, b T) bool) *topN[T] {
+	return &topN[T]{n: n, less: less}
+}
+
+// add offers v; it is kept when fewer than n items are held or it outranks
+// the lowest-ranked one.
+func (t *topN[T]) add(v T) {
+	if len(t.h) < t.n {
+		t.h = append(t.h, v)
+		t.up(len(t.h) - 1)
+		return
+	}
+	if t.n > 0 && t.less(t.h[0], v) {
+		t.h[0] = v
+		t.down(0)
+	}
+}
+
+// items returns the kept items, highest-ranked first.
+func (t *topN[T]) items() []T {
+	h := &topN[T]{n: t.n, less: t.less, h: append([]T(nil), t.h...)}
+	out := make([]T, len(h.h))
+	for i := len(out) - 1; i >= 0; i-- {
+		out[i] = h.h[0]
+		last := len(h.h) - 1
+		h.h[0] = h.h[last]
+		h.h = h.h[:last]
+		h.down(0)
+	}
+	return out
+}
+
+func (t *topN[T]) up(i int) {
+	for i > 0 {
+		p := (i - 1) / 2
+		if !t.less(t.h[i], t.h[p]) {
+			return
+		}
+		t.h[i], t.h[p] = t.h[p], t.h[i]
+		i = p
+	}
+}
+
+func (t *topN[T]) down(i int) {
+	for {
+		l, m := 2*i+1, i
+		if l < len(t.h) && t.less(t.h[l], t.h[m]) {
+			m = l
+		}
+		if r := l + 1; r < len(t.h) && t.less(t.h[r], t.h[m]) {
+			m = r
+		}
+		if m == i {
+			return
+		}
+		t.h[i], t.h[m] = t.h[m], t.h[i]
+		i = m
+	}
+}
+
+// relationKey identifies a table or index across databases.
+func relationKey(db, schema, name string) string {
+	return db + "\x00" + schema + "\x00" + name
+}
+
+// byInt64 ranks by a metric with the relation key as a stable tie-breaker, so
+// equal values keep the same rows on every run.
+func byInt64[T any](metric func(T) int64, key func(T) string) func(a, b T) bool {
+	return func(a, b T) bool {
+		if ma, mb := metric(a), metric(b); ma != mb {
+			return ma < mb
+		}
+		return key(a) > key(b)
+	}
+}
+
+func tableKey(t TableStat) string { return relationKey(t.Database, t.Schema, t.Name) }
+func indexKey(i IndexStat) string { return relationKey(i.Database, i.Schema, i.Name) }
+
+// tableSink aggregates streamed table rows into bounded rankings and totals.
+type tableSink struct {
+	bySize, byRows, byDead, bySeqScan *topN[TableStat]
+	totals                            *CatalogTotals
+}
+
+func newTableSink(totals *CatalogTotals) *tableSink {
+	return &tableSink{
+		bySize:    newTopN(relationTopN, byInt64(func(t TableStat) int64 { return t.SizeBytes }, tableKey)),
+		byRows:    newTopN(relationTopN, byInt64(func(t TableStat) int64 { return t.NLiveTup }, tableKey)),
+		byDead:    newTopN(relationTopN, byInt64(func(t TableStat) int64 { return t.NDeadTup }, tableKey)),
+		bySeqScan: newTopN(relationTopN, byInt64(func(t TableStat) int64 { return t.SeqScans }, tableKey)),
+		totals:    totals,
+	}
+}
+
+func (s *tableSink) add(t TableStat) {
+	s.totals.Tables++
+	s.totals.TableBytes += t.SizeBytes
+	sc := s.totals.schema(t.Database, t.Schema)
+	sc.Tables++
+	sc.TableBytes += t.SizeBytes
+	sc.LiveTup += t.NLiveTup
+	sc.DeadTup += t.NDeadTup
+	sc.SeqScans += t.SeqScans
+	sc.IdxScans += t.IdxScans
+	s.bySize.add(t)
+	s.byRows.add(t)
+	s.byDead.add(t)
+	s.bySeqScan.add(t)
+}
+
+// tables returns the union of all rankings, largest first.
+func (s *tableSink) tables() []TableStat {
+	return unionRanked(tableKey, s.bySize.items(), s.byRows.items(), s.byDead.items(), s.bySeqScan.items())
+}
+
+// indexSink aggregates streamed index rows: the largest indexes, the largest
+// unused ones, and all indexes of kept tables (for per-query drill-downs), which
+// is bounded by the number of kept tables.
+type indexSink struct {
+	keepTables     map[string]bool
+	bySize         *topN[IndexStat]
+	unused         *topN[IndexStat]
+	lowSelectivity *topN[IndexStat]
+	ofKeptTables   []IndexStat
+	totals         *CatalogTotals
+}
+
+func newIndexSink(tables []TableStat, totals *CatalogTotals) *indexSink {
+	keep := make(map[string]bool, len(tables))
+	for _, t := range tables {
+		keep[tableKey(t)] = true
+	}
+	bySize := byInt64(func(i IndexStat) int64 { return i.SizeBytes }, indexKey)
+	return &indexSink{
+		keepTables: keep,
+		bySize:     newTopN(relationTopN, bySize),
+		unused:     newTopN(relationTopN, bySize),
+		// Ranked by index entries read without returning a row
+		lowSelectivity: newTopN(maxResultRows, byInt64(func(i IndexStat) int64 { return i.TupRead - i.TupFetch }, indexKey)),
+		totals:         totals,
+	}
+}
+
+func (s *indexSink) add(i IndexStat) {
+	s.totals.Indexes++
+	s.totals.IndexBytes += i.SizeBytes
+	sc := s.totals.schema(i.Database, i.Schema)
+	sc.Indexes++
+	sc.IndexBytes += i.SizeBytes
+	if s.keepTables[relationKey(i.Database, i.Schema, i.Table)] {
+		s.ofKeptTables = append(s.ofKeptTables, i)
+	} else {
+		s.bySize.add(i)
+	}
+	if i.Scans == 0 && i.SizeBytes > unusedIndexMinSize {
+		s.unused.add(i)
+	}
+	if i.LowSelectivity() {
+		s.lowSelectivity.add(i)
+	}
+}
+
+// lowSelectivityIndexes returns the indexes wasting the most reads on entries
+// that do not produce a row.
+func (s *indexSink) lowSelectivityIndexes() []IndexStat {
+	return s.lowSelectivity.items()
+}
+
+// indexes returns the kept indexes, largest first.
+func (s *indexSink) indexes() []IndexStat {
+	kept := append([]IndexStat(nil), s.ofKeptTables...)
+	sortRanked(kept, func(i IndexStat) int64 { return i.SizeBytes }, indexKey)
+	return unionRanked(indexKey, kept, s.bySize.items())
+}
+
+// unusedIndexes returns the largest unused index candidates.
+func (s *indexSink) unusedIndexes() []IndexUnused {
+	items := s.unused.items()
+	out := make([]IndexUnused, len(items))
+	for n, i := range items {
+		out[n] = IndexUnused{Database: i.Database, Schema: i.Schema, Table: i.Table, Name: i.Name, SizeBytes: i.SizeBytes, Constraint: i.Constraint}
+	}
+	return out
+}
+
+// RankTables fills res.TopTablesBySize and res.TopTablesByRows from res.Tables
+// by bounded selection with stable tie-breakers. Run calls it after collection;
+// it is exported for results assembled elsewhere (e.g. decoded snapshots).
+func RankTables(res *Result) {
+	bySize := newTopN(reportTopN, byInt64(func(t TableStat) int64 { return t.SizeBytes }, tableKey))
+	byRows := newTopN(reportTopN, byInt64(func(t TableStat) int64 { return t.NLiveTup }, tableKey))
+	for _, t := range res.Tables {
+		bySize.add(t)
+		byRows.add(t)
+	}
+	res.TopTablesBySize = bySize.items()
+	res.TopTablesByRows = byRows.items()
+}
+
+// unionRanked concatenates ranked lists, dropping repeated relations.
+func unionRanked[T any](key func(T) string, lists ...[]T) []T {
+	seen := map[string]bool{}
+	var out []T
+	for _, l := range lists {
+		for _, v := range l {
+			if k := key(v); !seen[k] {
+				seen[k] = true
+				out = append(out, v)
+			}
+		}
+	}
+	return out
+}
+
+// sortRanked sorts items by metric descending with the key as tie-breaker.
+func sortRanked[T any](items []T, metric func(T) int64, key func(T) string) {
+	less := byInt64(metric, key)
+	sort.Slice(items, func(a, b int) bool { return less(items[b], items[a]) })
+}
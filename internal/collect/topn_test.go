@@ -0,0 +1,159 @@
+package collect
+
+import (
+	"fmt"
+	"testing"
+)
+
+// TestTopN verifies only the highest-ranked items are kept, in rank order.
+func TestTopN(t *testing.T) {
+	top := newTopN(3, func(a, b int) bool { return a < b })
+	for _, v := range []int{5, 1, 9, 3, 7, 2, 8} {
+		top.add(v)
+	}
+	got := top.items()
+	if fmt.Sprint(got) != "[9 8 7]" {
+		t.Errorf("items() = %v, expected [9 8 7]", got)
+	}
+	if fmt.Sprint(top.items()) != "[9 8 7]" {
+		t.Error("items() must not consume the heap")
+	}
+}
+
+// TestTableSink verifies rankings are bounded, combined without duplicates and
+// deterministic on ties, while totals count every table.
+func TestTableSink(t *testing.T) {
+	var totals CatalogTotals
+	sink := newTableSink(&totals)
+	n := relationTopN * 4
+	for i := 0; i < n; i++ {
+		sink.add(TableStat{Database: "app", Schema: "public", Name: fmt.Sprintf("t%05d", i), SizeBytes: int64(i), NLiveTup: 1})
+	}
+	got := sink.tables()
+	if totals.Tables != n {
+		t.Errorf("totals.Tables = %d, expected %d", totals.Tables, n)
+	}
+	if len(got) > 4*relationTopN {
+		t.Errorf("kept %d tables, expected at most %d", len(got), 4*relationTopN)
+	}
+	if got[0].Name != fmt.Sprintf("t%05d", n-1) {
+		t.Errorf("largest table = %s", got[0].Name)
+	}
+	seen := map[string]bool{}
+	for _, tb := range got {
+		if seen[tb.Name] {
+			t.Fatalf("table %s listed twice", tb.Name)
+		}
+		seen[tb.Name] = true
+	}
+	// All tables tie on rows: the same (lowest-keyed) ones must win every time
+	if !seen["t00000"] {
+		t.Error("ties on rows should keep the lowest relation keys")
+	}
+}
+
+// TestSchemaTotals verifies every streamed table and index is rolled up into
+// its schema, across databases, largest schema first.
+func TestSchemaTotals(t *testing.T) {
+	var totals CatalogTotals
+	tables := newTableSink(&totals)
+	for i := 0; i < relationTopN*2; i++ {
+		tables.add(TableStat{Database: "app", Schema: "billing", Name: fmt.Sprintf("t%05d", i), SizeBytes: 10, NLiveTup: 3, NDeadTup: 1, SeqScans: 1, IdxScans: 3})
+	}
+	tables.add(TableStat{Database: "app", Schema: "public", Name: "events", SizeBytes: 100})
+	tables.add(TableStat{Database: "crm", Schema: "billing", Name: "accounts", SizeBytes: 50})
+	indexes := newIndexSink(tables.tables(), &totals)
+	indexes.add(IndexStat{Database: "app", Schema: "billing", Table: "t00000", Name: "t00000_pkey", SizeBytes: 4})
+	indexes.add(IndexStat{Database: "app", Schema: "billing", Table: "t00001", Name: "t00001_pkey", SizeBytes: 4})
+	totals.sortSchemas()
+
+	var got []string
+	for _, s := range totals.Schemas {
+		got = append(got, fmt.Sprintf("%s.%s:%d/%d/%d/%d", s.Database, s.Schema, s.Tables, s.TableBytes, s.Indexes, s.IndexBytes))
+	}
+	want := fmt.Sprint([]string{"app.billing:1000/10000/2/8", "app.public:1/100/0/0", "crm.billing:1/50/0/0"})
+	if fmt.Sprint(got) != want {
+		t.Errorf("schemas = %v, expected %s", got, want)
+	}
+	if b := totals.Schemas[0]; b.BloatPct() != 25 || b.IndexUsagePct() != 75 {
+		t.Errorf("billing bloat = %.1f%%, index usage = %.1f%%, expected 25%% and 75%%", b.BloatPct(), b.IndexUsagePct())
+	}
+}
+
+// TestIndexSink verifies indexes of kept tables are retained and unused
+// candidates are ranked by size.
+func TestIndexSink(t *testing.T) {
+	var totals CatalogTotals
+	kept := []TableStat{{Database: "app", Schema: "public", Name: "orders"}}
+	sink := newIndexSink(kept, &totals)
+	sink.add(IndexStat{Database: "app", Schema: "public", Table: "orders", Name: "orders_pkey", Scans: 10, SizeBytes: 1})
+	for i := 0; i < relationTopN+10; i++ {
+		sink.add(IndexStat{Database: "app", Schema: "public", Table: "big", Name: fmt.Sprintf("big_%d", i), SizeBytes: int64(i+1) * unusedIndexMinSize})
+	}
+	idx := sink.indexes()
+	found := false
+	for _, i := range idx {
+		found = found || i.Name == "orders_pkey"
+	}
+	if !found {
+		t.Error("index of a kept table was dropped")
+	}
+	if len(idx) != relationTopN+1 {
+		t.Errorf("kept %d indexes, expected %d", len(idx), relationTopN+1)
+	}
+	unused := sink.unusedIndexes()
+	if len(unused) != relationTopN || unused[0].Name != fmt.Sprintf("big_%d", relationTopN+9) {
+		t.Errorf("unused = %d items, first %+v", len(unused), unused[0])
+	}
+	if totals.Indexes != relationTopN+11 {
+		t.Errorf("totals.Indexes = %d", totals.Indexes)
+	}
+}
+
+// TestRankTables verifies report rankings are capped and ordered
+// deterministically when tables tie.
+func TestRankTables(t *testing.T) {
+	var res Result
+	for i := reportTopN + 20; i > 0; i-- {
+		res.Tables = append(res.Tables, TableStat{Database: "app", Schema: "public", Name: fmt.Sprintf("t%04d", i), NLiveTup: 1, SizeBytes: int64(i % 3)})
+	}
+	RankTables(&res)
+	if len(res.TopTablesBySize) != reportTopN || len(res.TopTablesByRows) != reportTopN {
+		t.Fatalf("rankings = %d/%d, expected %d", len(res.TopTablesBySize), len(res.TopTablesByRows), reportTopN)
+	}
+	if res.TopTablesByRows[0].Name != "t0001" {
+		t.Errorf("first by rows = %s, expected t0001", res.TopTablesByRows[0].Name)
+	}
+	first := res.TopTablesBySize[0]
+	if first.SizeBytes != 2 || first.Name != "t0002" {
+		t.Errorf("first by size = %s (%d), expected t0002 (2)", first.Name, first.SizeBytes)
+	}
+}
+
+// TestIndexLowSelectivity verifies which indexes count as unselective and that
+// the sink keeps them.
+func TestIndexLowSelectivity(t *testing.T) {
+	tests := []struct {
+		name string
+		ix   IndexStat
+		want bool
+	}{
+		{"unselective", IndexStat{Scans: 1000, TupRead: 5_000_000, TupFetch: 20_000}, true},
+		{"selective", IndexStat{Scans: 1000, TupRead: 5_000, TupFetch: 5_000}, false},
+		{"rarely scanned", IndexStat{Scans: 10, TupRead: 5_000_000, TupFetch: 100}, false},
+		{"index-only or bitmap", IndexStat{Scans: 1000, TupRead: 5_000_000}, false},
+		{"many rows returned", IndexStat{Scans: 1000, TupRead: 5_000_000, TupFetch: 4_000_000}, false},
+	}
+	var totals CatalogTotals
+	sink := newIndexSink(nil, &totals)
+	for _, tt := range tests {
+		if got := tt.ix.LowSelectivity(); got != tt.want {
+			t.Errorf("%s: LowSelectivity() = %v, expected %v", tt.name, got, tt.want)
+		}
+		tt.ix.Name = tt.name
+		sink.add(tt.ix)
+	}
+	if got := sink.lowSelectivityIndexes(); len(got) != 1 || got[0].Name != "unselective" {
+		t.Errorf("lowSelectivityIndexes() = %+v", got)
+	}
+}
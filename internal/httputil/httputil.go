@@ -0,0 +1,18 @@
+// Package httputil holds the HTTP helpers shared by the clients pghealth
+// talks to without an SDK (webhooks, Patroni, issue trackers, S3).
+package httputil
+
+import (
+	"io"
+	"strings"
+)
+
+// maxErrorBody limits how much of an error response is included in errors.
+const maxErrorBody = 512
+
+// ReadErrorBody returns the start of an error response body, trimmed, for
+// the error message of a failed request.
+func ReadErrorBody(r io.Reader) string {
+	msg, _ := io.ReadAll(io.LimitReader(r, maxErrorBody))
+	return strings.TrimSpace(string(msg))
+}
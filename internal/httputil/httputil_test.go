@@ -0,0 +1,16 @@
+package httputil
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestReadErrorBody verifies the body is trimmed and cut at maxErrorBody.
+func TestReadErrorBody(t *testing.T) {
+	if got := ReadErrorBody(strings.NewReader("  not found\n")); got != "not found" {
+		t.Errorf("ReadErrorBody() = %q, expected %q", got, "not found")
+	}
+	if got := ReadErrorBody(strings.NewReader(strings.Repeat("x", 2*maxErrorBody))); len(got) != maxErrorBody {
+		t.Errorf("ReadErrorBody() length = %d, expected %d", len(got), maxErrorBody)
+	}
+}
@@ -0,0 +1,283 @@
+// Package hub implements the central aggregation server behind `pghealth hub`.
+//
+// The hub receives snapshots posted with -post-url, stores them in PostgreSQL,
+// and serves a fleet dashboard with per-host trends and the findings that recur
+// most often across the estate.
+package hub
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/koltyakov/pghealth/internal/snapshot"
+	"github.com/koltyakov/pghealth/internal/webhook"
+)
+
+//go:embed dashboard.html
+var tmplFS embed.FS
+
+// Server defaults.
+const (
+	// DefaultAddr is the listen address when none is configured.
+	DefaultAddr = ":8080"
+
+	// maxSnapshotSize bounds accepted request bodies.
+	maxSnapshotSize = 64 << 20
+
+	// trendRuns is how many recent runs are shown on a host page.
+	trendRuns = 50
+
+	// topFindings is how many recurring findings the dashboard lists.
+	topFindings = 20
+
+	// shutdownTimeout bounds graceful shutdown.
+	shutdownTimeout = 10 * time.Second
+)
+
+// Config holds the hub server configuration.
+type Config struct {
+	// Addr is the HTTP listen address.
+	Addr string
+
+	// DSN is the PostgreSQL connection string for snapshot storage.
+	DSN string
+
+	// Secret requires a valid webhook signature on every ingest.
+	Secret string
+
+	// Insecure accepts unsigned snapshots when Secret is empty.
+	Insecure bool
+}
+
+// Validate checks that the configuration is usable.
+func (c Config) Validate() error {
+	if c.DSN == "" {
+		return errors.New("hub database is required: use -db flag or set PGHEALTH_HUB_DB")
+	}
+	if c.Secret == "" && !c.Insecure {
+		return errors.New("hub secret is required: use -secret flag or set PGHEALTH_POST_SECRET, or -insecure to accept unsigned snapshots")
+	}
+	return nil
+}
+
+// Server serves the ingest endpoint and dashboard.
+type Server struct {
+	store  Store
+	secret string
+	tmpl   *template.Template
+}
+
+// NewServer creates a hub server backed by store.
+func NewServer(store Store, secret string) (*Server, error) {
+	tmpl, err := template.New("dashboard.html").Funcs(template.FuncMap{
+		"pct":       func(v float64) string { return fmt.Sprintf("%.1f%%", v*100) },
+		"ts":        func(t time.Time) string { return t.Local().Format("2006-01-02 15:04") },
+		"sparkline": sparkline,
+		"warnSeries": func(runs []RunSummary) []float64 {
+			return series(runs, func(r RunSummary) float64 { return float64(r.Warnings) })
+		},
+		"recSeries": func(runs []RunSummary) []float64 {
+			return series(runs, func(r RunSummary) float64 { return float64(r.Recommendations) })
+		},
+		"cacheSeries": func(runs []RunSummary) []float64 {
+			return series(runs, func(r RunSummary) float64 { return r.CacheHit * 100 })
+		},
+	}).ParseFS(tmplFS, "dashboard.html")
+	if err != nil {
+		return nil, fmt.Errorf("parse dashboard template: %w", err)
+	}
+	return &Server{store: store, secret: secret, tmpl: tmpl}, nil
+}
+
+// Handler returns the HTTP routes of the hub.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ingest", s.handleIngest)
+	mux.HandleFunc("/host", s.handleHost)
+	mux.HandleFunc("/", s.handleIndex)
+	return mux
+}
+
+func (s *Server) handleIngest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxSnapshotSize))
+	if err != nil {
+		http.Error(w, "read body: "+err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+	if s.secret != "" && !webhook.Verify(body, s.secret, r.Header.Get(webhook.SignatureHeader)) {
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return
+	}
+	snap, err := snapshot.Decode(bytes.NewReader(body))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := s.store.Save(r.Context(), snap); err != nil {
+		log.Printf("hub: save snapshot: %v", err)
+		http.Error(w, "failed to store snapshot", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	labels, err := labelFilter(r.URL.Query()["label"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	hosts, err := s.store.Hosts(r.Context(), labels)
+	if err != nil {
+		s.fail(w, err)
+		return
+	}
+	findings, err := s.store.TopFindings(r.Context(), labels, topFindings)
+	if err != nil {
+		s.fail(w, err)
+		return
+	}
+	s.render(w, map[string]any{"Hosts": hosts, "Findings": findings, "Labels": labels})
+}
+
+// labelFilter parses ?label=key=value parameters selecting the runs shown.
+func labelFilter(params []string) (map[string]string, error) {
+	labels := map[string]string{}
+	for _, p := range params {
+		k, v, ok := strings.Cut(p, "=")
+		if !ok || k == "" {
+			return nil, fmt.Errorf("invalid label filter %q: expected key=value", p)
+		}
+		labels[k] = v
+	}
+	return labels, nil
+}
+
+func (s *Server) handleHost(w http.ResponseWriter, r *http.Request) {
+	target := r.URL.Query().Get("target")
+	if target == "" {
+		http.Error(w, "target is required", http.StatusBadRequest)
+		return
+	}
+	runs, err := s.store.Runs(r.Context(), target, trendRuns)
+	if err != nil {
+		s.fail(w, err)
+		return
+	}
+	if len(runs) == 0 {
+		http.NotFound(w, r)
+		return
+	}
+	s.render(w, map[string]any{"Target": target, "Runs": runs})
+}
+
+func (s *Server) render(w http.ResponseWriter, data map[string]any) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := s.tmpl.Execute(w, data); err != nil {
+		log.Printf("hub: render: %v", err)
+	}
+}
+
+func (s *Server) fail(w http.ResponseWriter, err error) {
+	log.Printf("hub: %v", err)
+	http.Error(w, "internal error", http.StatusInternalServerError)
+}
+
+// Run opens the store and serves until ctx is cancelled.
+func Run(ctx context.Context, cfg Config) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	if cfg.Addr == "" {
+		cfg.Addr = DefaultAddr
+	}
+
+	store, err := OpenPostgres(ctx, cfg.DSN)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	srv, err := NewServer(store, cfg.Secret)
+	if err != nil {
+		return err
+	}
+	httpSrv := &http.Server{
+		Addr:              cfg.Addr,
+		Handler:           srv.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	errCh := make(chan error, 1)
+	go func() { errCh <- httpSrv.ListenAndServe() }()
+	log.Printf("hub listening on %s", cfg.Addr)
+	if cfg.Secret == "" {
+		log.Printf("warning: -insecure is set, unsigned snapshots are accepted")
+	}
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+		shutCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		return httpSrv.Shutdown(shutCtx)
+	}
+}
+
+// series maps runs (newest first) to values in chronological order.
+func series(runs []RunSummary, f func(RunSummary) float64) []float64 {
+	out := make([]float64, len(runs))
+	for i, r := range runs {
+		out[len(runs)-1-i] = f(r)
+	}
+	return out
+}
+
+// sparkline renders values as SVG polyline points in a 120x24 box.
+func sparkline(vals []float64) string {
+	if len(vals) == 0 {
+		return ""
+	}
+	const w, h = 120.0, 24.0
+	lo, hi := vals[0], vals[0]
+	for _, v := range vals {
+		lo = min(lo, v)
+		hi = max(hi, v)
+	}
+	span := hi - lo
+	var b strings.Builder
+	for i, v := range vals {
+		x := 0.0
+		if len(vals) > 1 {
+			x = float64(i) * w / float64(len(vals)-1)
+		}
+		y := h / 2
+		if span > 0 {
+			y = h - (v-lo)/span*h
+		}
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		fmt.Fprintf(&b, "%.1f,%.1f", x, y)
+	}
+	return b.String()
+}
@@ -0,0 +1,187 @@
+package hub
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/koltyakov/pghealth/internal/analyze"
+	"github.com/koltyakov/pghealth/internal/collect"
+	"github.com/koltyakov/pghealth/internal/snapshot"
+	"github.com/koltyakov/pghealth/internal/webhook"
+)
+
+// memStore is an in-memory Store for handler tests.
+type memStore struct {
+	saved []snapshot.Snapshot
+}
+
+func (m *memStore) Save(_ context.Context, s snapshot.Snapshot) error {
+	m.saved = append(m.saved, s)
+	return nil
+}
+
+func (m *memStore) Hosts(_ context.Context, labels map[string]string) ([]HostSummary, error) {
+	var out []HostSummary
+	for _, s := range m.saved {
+		if r := summarize(s); hasLabels(r, labels) {
+			out = append(out, HostSummary{Latest: r, Runs: 1})
+		}
+	}
+	return out, nil
+}
+
+func (m *memStore) Runs(_ context.Context, target string, _ int) ([]RunSummary, error) {
+	var out []RunSummary
+	for _, s := range m.saved {
+		if r := summarize(s); r.Target == target {
+			out = append(out, r)
+		}
+	}
+	return out, nil
+}
+
+func (m *memStore) TopFindings(context.Context, map[string]string, int) ([]FindingCount, error) {
+	return []FindingCount{{Code: "cache-hit", Title: "Low cache hit", Severity: "warn", Hosts: 2, Occurrences: 5}}, nil
+}
+
+func (m *memStore) Close() {}
+
+// hasLabels reports whether r carries every pair of labels.
+func hasLabels(r RunSummary, labels map[string]string) bool {
+	for k, v := range labels {
+		if got, ok := r.Labels[k]; !ok || got != v {
+			return false
+		}
+	}
+	return true
+}
+
+func testSnapshot(t *testing.T) []byte {
+	t.Helper()
+	a := analyze.Analysis{Warnings: []analyze.Finding{{Title: "w", Code: "c", Severity: analyze.SeverityWarning}}}
+	meta := collect.Meta{StartedAt: time.Now(), Version: "v1", Target: "db1:5432/app", Labels: map[string]string{"env": "prod"}}
+	b, err := snapshot.New(collect.Result{}, a, meta).Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// TestIngest verifies signature enforcement and storage of posted snapshots.
+func TestIngest(t *testing.T) {
+	store := &memStore{}
+	srv, err := NewServer(store, "k")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := srv.Handler()
+	body := testSnapshot(t)
+
+	tests := []struct {
+		name     string
+		method   string
+		sig      string
+		body     []byte
+		expected int
+	}{
+		{"wrong method", http.MethodGet, "", nil, http.StatusMethodNotAllowed},
+		{"missing signature", http.MethodPost, "", body, http.StatusUnauthorized},
+		{"bad signature", http.MethodPost, webhook.Sign(body, "other"), body, http.StatusUnauthorized},
+		{"invalid json", http.MethodPost, webhook.Sign([]byte("{"), "k"), []byte("{"), http.StatusBadRequest},
+		{"valid", http.MethodPost, webhook.Sign(body, "k"), body, http.StatusAccepted},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/ingest", bytes.NewReader(tt.body))
+			if tt.sig != "" {
+				req.Header.Set(webhook.SignatureHeader, tt.sig)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			if rec.Code != tt.expected {
+				t.Errorf("status = %d, expected %d (%s)", rec.Code, tt.expected, rec.Body.String())
+			}
+		})
+	}
+	if len(store.saved) != 1 || store.saved[0].Meta.Target != "db1:5432/app" {
+		t.Errorf("expected one stored snapshot, got %+v", store.saved)
+	}
+}
+
+// TestDashboard verifies the index and host pages render stored data.
+func TestDashboard(t *testing.T) {
+	store := &memStore{}
+	srv, err := NewServer(store, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := srv.Handler()
+
+	req := httptest.NewRequest(http.MethodPost, "/ingest", bytes.NewReader(testSnapshot(t)))
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	for path, want := range map[string]string{
+		"/":                         "Low cache hit",
+		"/?label=env%3Dprod":        "Runs labelled <code>env=prod</code>",
+		"/host?target=db1:5432/app": "<polyline",
+	} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), want) {
+			t.Errorf("GET %s = %d, missing %q", path, rec.Code, want)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/?label=env%3Dstaging", nil))
+	if strings.Contains(rec.Body.String(), "/host?target=") {
+		t.Errorf("label filter env=staging listed a prod host")
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/host?target=unknown", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("unknown host status = %d, expected 404", rec.Code)
+	}
+}
+
+// TestSparkline verifies point scaling for flat and varying series.
+func TestSparkline(t *testing.T) {
+	tests := []struct {
+		vals     []float64
+		expected string
+	}{
+		{nil, ""},
+		{[]float64{5}, "0.0,12.0"},
+		{[]float64{1, 1}, "0.0,12.0 120.0,12.0"},
+		{[]float64{0, 10}, "0.0,24.0 120.0,0.0"},
+	}
+	for _, tt := range tests {
+		if got := sparkline(tt.vals); got != tt.expected {
+			t.Errorf("sparkline(%v) = %q, expected %q", tt.vals, got, tt.expected)
+		}
+	}
+}
+
+// TestConfigValidate verifies a secret is required unless -insecure is set.
+func TestConfigValidate(t *testing.T) {
+	tests := []struct {
+		cfg       Config
+		expectErr bool
+	}{
+		{Config{}, true},
+		{Config{DSN: "postgres://hub"}, true},
+		{Config{DSN: "postgres://hub", Secret: "s"}, false},
+		{Config{DSN: "postgres://hub", Insecure: true}, false},
+	}
+	for _, tt := range tests {
+		if err := tt.cfg.Validate(); (err != nil) != tt.expectErr {
+			t.Errorf("Validate(%+v) error = %v, expectErr = %v", tt.cfg, err, tt.expectErr)
+		}
+	}
+}
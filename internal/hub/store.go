@@ -0,0 +1,254 @@
+package hub
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/koltyakov/pghealth/internal/analyze"
+	"github.com/koltyakov/pghealth/internal/snapshot"
+)
+
+// RunSummary is the per-run row shown on the dashboard and used for trends.
+type RunSummary struct {
+	ID              int64
+	Target          string
+	Database        string
+	StartedAt       time.Time
+	Version         string
+	Warnings        int
+	Recommendations int
+	Infos           int
+	CacheHit        float64
+	Connections     int
+	MaxConnections  int
+	Labels          map[string]string // -label pairs of the run
+}
+
+// HostSummary is the latest run of a target plus the number of stored runs.
+type HostSummary struct {
+	Latest RunSummary
+	Runs   int
+}
+
+// FindingCount aggregates a finding across the estate.
+type FindingCount struct {
+	Code        string
+	Title       string
+	Severity    string
+	Hosts       int // Distinct targets that reported the finding
+	Occurrences int // Total runs that reported the finding
+}
+
+// Store persists posted snapshots and answers dashboard queries.
+type Store interface {
+	Save(ctx context.Context, s snapshot.Snapshot) error
+	// Hosts and TopFindings only consider runs carrying all of labels.
+	Hosts(ctx context.Context, labels map[string]string) ([]HostSummary, error)
+	Runs(ctx context.Context, target string, limit int) ([]RunSummary, error)
+	TopFindings(ctx context.Context, labels map[string]string, limit int) ([]FindingCount, error)
+	Close()
+}
+
+// summarize extracts the dashboard row from a snapshot.
+func summarize(s snapshot.Snapshot) RunSummary {
+	target := s.Meta.Target
+	if target == "" {
+		target = s.Result.ConnInfo.CurrentDB
+	}
+	return RunSummary{
+		Target:          target,
+		Database:        s.Result.ConnInfo.CurrentDB,
+		StartedAt:       s.Meta.StartedAt,
+		Version:         s.Meta.Version,
+		Warnings:        len(s.Analysis.Warnings),
+		Recommendations: len(s.Analysis.Recommendations),
+		Infos:           len(s.Analysis.Infos),
+		CacheHit:        s.Result.CacheHitCurrent,
+		Connections:     s.Result.TotalConnections,
+		MaxConnections:  s.Result.ConnInfo.MaxConnections,
+		Labels:          s.Meta.Labels,
+	}
+}
+
+// findingKey groups findings by code, falling back to the title for uncoded ones.
+func findingKey(f analyze.Finding) string {
+	if f.Code != "" {
+		return f.Code
+	}
+	return f.Title
+}
+
+// schemaSQL creates the hub tables; it is idempotent and runs on startup.
+const schemaSQL = `
+CREATE TABLE IF NOT EXISTS pghealth_runs (
+	id              bigserial PRIMARY KEY,
+	target          text NOT NULL,
+	database        text NOT NULL DEFAULT '',
+	started_at      timestamptz NOT NULL,
+	received_at     timestamptz NOT NULL DEFAULT now(),
+	version         text NOT NULL DEFAULT '',
+	warnings        int NOT NULL DEFAULT 0,
+	recommendations int NOT NULL DEFAULT 0,
+	infos           int NOT NULL DEFAULT 0,
+	cache_hit       double precision NOT NULL DEFAULT 0,
+	connections     int NOT NULL DEFAULT 0,
+	max_connections int NOT NULL DEFAULT 0,
+	snapshot        jsonb NOT NULL
+);
+ALTER TABLE pghealth_runs ADD COLUMN IF NOT EXISTS labels jsonb NOT NULL DEFAULT '{}';
+CREATE INDEX IF NOT EXISTS pghealth_runs_target_started_idx ON pghealth_runs (target, started_at DESC);
+CREATE TABLE IF NOT EXISTS pghealth_findings (
+	run_id   bigint NOT NULL REFERENCES pghealth_runs (id) ON DELETE CASCADE,
+	severity text NOT NULL,
+	code     text NOT NULL,
+	title    text NOT NULL
+);
+CREATE INDEX IF NOT EXISTS pghealth_findings_run_idx ON pghealth_findings (run_id);
+`
+
+// pgStore keeps snapshots in PostgreSQL.
+type pgStore struct {
+	pool *pgxpool.Pool
+}
+
+// OpenPostgres connects to the hub database and ensures the schema exists.
+func OpenPostgres(ctx context.Context, dsn string) (Store, error) {
+	pool, err := pgxpool.New(ctx, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("connect hub database: %w", err)
+	}
+	if _, err := pool.Exec(ctx, schemaSQL); err != nil {
+		pool.Close()
+		return nil, fmt.Errorf("create hub schema: %w", err)
+	}
+	return &pgStore{pool: pool}, nil
+}
+
+func (p *pgStore) Save(ctx context.Context, s snapshot.Snapshot) error {
+	payload, err := s.Marshal()
+	if err != nil {
+		return err
+	}
+	sum := summarize(s)
+
+	return pgx.BeginFunc(ctx, p.pool, func(tx pgx.Tx) error {
+		var id int64
+		err := tx.QueryRow(ctx, `INSERT INTO pghealth_runs
+			(target, database, started_at, version, warnings, recommendations, infos, cache_hit, connections, max_connections, labels, snapshot)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+			RETURNING id`,
+			sum.Target, sum.Database, sum.StartedAt, sum.Version, sum.Warnings, sum.Recommendations,
+			sum.Infos, sum.CacheHit, sum.Connections, sum.MaxConnections, labelsJSON(sum.Labels), payload,
+		).Scan(&id)
+		if err != nil {
+			return fmt.Errorf("insert run: %w", err)
+		}
+
+		var rows [][]any
+		for _, list := range [][]analyze.Finding{s.Analysis.Warnings, s.Analysis.Recommendations, s.Analysis.Infos} {
+			for _, f := range list {
+				rows = append(rows, []any{id, f.Severity, findingKey(f), f.Title})
+			}
+		}
+		if len(rows) == 0 {
+			return nil
+		}
+		_, err = tx.CopyFrom(ctx, pgx.Identifier{"pghealth_findings"},
+			[]string{"run_id", "severity", "code", "title"}, pgx.CopyFromRows(rows))
+		if err != nil {
+			return fmt.Errorf("insert findings: %w", err)
+		}
+		return nil
+	})
+}
+
+// runColumns is the column list scanned by scanRun.
+const runColumns = `id, target, database, started_at, version, warnings, recommendations, infos, cache_hit, connections, max_connections, labels`
+
+func scanRun(row pgx.Row, extra ...any) (RunSummary, error) {
+	var r RunSummary
+	dest := append([]any{&r.ID, &r.Target, &r.Database, &r.StartedAt, &r.Version, &r.Warnings,
+		&r.Recommendations, &r.Infos, &r.CacheHit, &r.Connections, &r.MaxConnections, &r.Labels}, extra...)
+	err := row.Scan(dest...)
+	return r, err
+}
+
+func (p *pgStore) Hosts(ctx context.Context, labels map[string]string) ([]HostSummary, error) {
+	rows, err := p.pool.Query(ctx, `SELECT DISTINCT ON (target) `+runColumns+`, count(*) OVER (PARTITION BY target)
+		FROM pghealth_runs WHERE labels @> $1 ORDER BY target, started_at DESC`, labelsJSON(labels))
+	if err != nil {
+		return nil, fmt.Errorf("query hosts: %w", err)
+	}
+	defer rows.Close()
+
+	var out []HostSummary
+	for rows.Next() {
+		var h HostSummary
+		if h.Latest, err = scanRun(rows, &h.Runs); err != nil {
+			return nil, fmt.Errorf("scan host: %w", err)
+		}
+		out = append(out, h)
+	}
+	return out, rows.Err()
+}
+
+func (p *pgStore) Runs(ctx context.Context, target string, limit int) ([]RunSummary, error) {
+	rows, err := p.pool.Query(ctx, `SELECT `+runColumns+` FROM pghealth_runs
+		WHERE target = $1 ORDER BY started_at DESC LIMIT $2`, target, limit)
+	if err != nil {
+		return nil, fmt.Errorf("query runs: %w", err)
+	}
+	defer rows.Close()
+
+	var out []RunSummary
+	for rows.Next() {
+		r, err := scanRun(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan run: %w", err)
+		}
+		out = append(out, r)
+	}
+	return out, rows.Err()
+}
+
+func (p *pgStore) TopFindings(ctx context.Context, labels map[string]string, limit int) ([]FindingCount, error) {
+	rows, err := p.pool.Query(ctx, `SELECT f.code, max(f.title), max(f.severity), count(DISTINCT r.target), count(*)
+		FROM pghealth_findings f JOIN pghealth_runs r ON r.id = f.run_id
+		WHERE f.severity <> $1 AND r.labels @> $2
+		GROUP BY f.code
+		ORDER BY 4 DESC, 5 DESC, 1
+		LIMIT $3`, analyze.SeverityInfo, labelsJSON(labels), limit)
+	if err != nil {
+		return nil, fmt.Errorf("query findings: %w", err)
+	}
+	defer rows.Close()
+
+	var out []FindingCount
+	for rows.Next() {
+		var f FindingCount
+		if err := rows.Scan(&f.Code, &f.Title, &f.Severity, &f.Hosts, &f.Occurrences); err != nil {
+			return nil, fmt.Errorf("scan finding: %w", err)
+		}
+		out = append(out, f)
+	}
+	return out, rows.Err()
+}
+
+// labelsJSON encodes labels as a jsonb object; no labels is {} rather than null
+// so containment filters match every run.
+func labelsJSON(labels map[string]string) string {
+	if len(labels) == 0 {
+		return "{}"
+	}
+	b, _ := json.Marshal(labels)
+	return string(b)
+}
+
+func (p *pgStore) Close() {
+	p.pool.Close()
+}
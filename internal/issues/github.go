@@ -0,0 +1,112 @@
+package issues
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/koltyakov/pghealth/internal/httputil"
+)
+
+// githubPageSize is the number of issues requested per page.
+const githubPageSize = 100
+
+// github manages issues of one repository through the REST API.
+// GITHUB_API_URL selects a GitHub Enterprise server.
+type github struct {
+	api   string
+	repo  string // owner/repo
+	token string
+}
+
+func newGitHub(owner, repo string) *github {
+	api := os.Getenv("GITHUB_API_URL")
+	if api == "" {
+		api = "https://api.github.com"
+	}
+	return &github{api: strings.TrimRight(api, "/"), repo: owner + "/" + repo, token: os.Getenv("GITHUB_TOKEN")}
+}
+
+func (g *github) open(ctx context.Context) ([]issue, error) {
+	var out []issue
+	for page := 1; ; page++ {
+		var list []struct {
+			Number      int             `json:"number"`
+			Body        string          `json:"body"`
+			PullRequest json.RawMessage `json:"pull_request"`
+		}
+		path := fmt.Sprintf("/repos/%s/issues?state=open&labels=%s&per_page=%d&page=%d", g.repo, Label, githubPageSize, page)
+		if err := g.do(ctx, http.MethodGet, path, nil, &list); err != nil {
+			return nil, err
+		}
+		for _, is := range list {
+			if k := parseKey(is.Body); k != "" && is.PullRequest == nil {
+				out = append(out, issue{ID: strconv.Itoa(is.Number), Key: k})
+			}
+		}
+		if len(list) < githubPageSize {
+			return out, nil
+		}
+	}
+}
+
+func (g *github) create(ctx context.Context, title, body string) error {
+	return g.do(ctx, http.MethodPost, "/repos/"+g.repo+"/issues",
+		map[string]any{"title": title, "body": body, "labels": []string{Label}}, nil)
+}
+
+func (g *github) update(ctx context.Context, id, title, body string) error {
+	return g.do(ctx, http.MethodPatch, "/repos/"+g.repo+"/issues/"+id, map[string]any{"title": title, "body": body}, nil)
+}
+
+func (g *github) resolve(ctx context.Context, id, comment string) error {
+	if err := g.do(ctx, http.MethodPost, "/repos/"+g.repo+"/issues/"+id+"/comments", map[string]any{"body": comment}, nil); err != nil {
+		return err
+	}
+	return g.do(ctx, http.MethodPatch, "/repos/"+g.repo+"/issues/"+id, map[string]any{"state": "closed", "state_reason": "completed"}, nil)
+}
+
+// do sends a JSON request and decodes the response into out when not nil.
+func (g *github) do(ctx context.Context, method, path string, in, out any) error {
+	if g.token == "" {
+		return fmt.Errorf("github issues need GITHUB_TOKEN")
+	}
+	var body io.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return fmt.Errorf("encode request: %w", err)
+		}
+		body = bytes.NewReader(b)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, g.api+path, body)
+	if err != nil {
+		return fmt.Errorf("build request: %w", err)
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("Authorization", "Bearer "+g.token)
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("github: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("github %s %s: %s: %s", method, req.URL.Path, resp.Status, httputil.ReadErrorBody(resp.Body))
+	}
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return fmt.Errorf("github %s: %w", req.URL.Path, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,202 @@
+// Package issues opens one tracker issue per warning, keyed by finding code
+// and target, and keeps it up to date on later runs: an open issue with the
+// same key is updated instead of duplicated, and issues of findings no longer
+// reported for the target are resolved.
+//
+// Supported trackers are GitHub Issues (github:owner/repo) and Jira
+// (jira:https://example.atlassian.net/PROJ).
+package issues
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/koltyakov/pghealth/internal/analyze"
+	"github.com/koltyakov/pghealth/internal/collect"
+)
+
+const (
+	// DefaultTimeout bounds a complete sync.
+	DefaultTimeout = time.Minute
+
+	// Label marks issues managed by pghealth.
+	Label = "pghealth"
+)
+
+var (
+	// keyRe finds the issue key marker in an issue body.
+	keyRe = regexp.MustCompile(`pghealth-key: ([^\s<]+)`)
+	// objectRe finds the schema-qualified object a finding title names.
+	objectRe = regexp.MustCompile(`[A-Za-z_][A-Za-z0-9_$]*\.[A-Za-z_][A-Za-z0-9_$]*`)
+)
+
+// issue is an open issue managed by pghealth.
+type issue struct {
+	ID  string // GitHub issue number or Jira issue key
+	Key string // code[:object]@target
+}
+
+// tracker is an issue tracker holding pghealth issues.
+type tracker interface {
+	// open lists the open issues labelled Label.
+	open(ctx context.Context) ([]issue, error)
+	create(ctx context.Context, title, body string) error
+	update(ctx context.Context, id, title, body string) error
+	// resolve closes the issue, or comments when closing is not possible.
+	resolve(ctx context.Context, id, comment string) error
+}
+
+// Summary counts the issues changed by Sync.
+type Summary struct {
+	Created, Updated, Resolved int
+}
+
+// Validate checks an -issues destination.
+func Validate(spec string) error {
+	_, err := newTracker(spec)
+	return err
+}
+
+// newTracker returns the tracker of an -issues destination: github:owner/repo
+// or jira:<base URL>/<project key>. Credentials come from GITHUB_TOKEN, or
+// JIRA_EMAIL with JIRA_API_TOKEN (JIRA_TOKEN for a personal access token).
+func newTracker(spec string) (tracker, error) {
+	kind, dest, _ := strings.Cut(spec, ":")
+	switch kind {
+	case "github":
+		owner, repo, ok := strings.Cut(dest, "/")
+		if !ok || owner == "" || repo == "" || strings.Contains(repo, "/") {
+			return nil, fmt.Errorf("invalid issues destination %q: expected github:owner/repo", spec)
+		}
+		return newGitHub(owner, repo), nil
+	case "jira":
+		u, err := url.Parse(dest)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("invalid issues destination %q: expected jira:https://host/PROJECT", spec)
+		}
+		base, project, _ := strings.Cut(strings.Trim(u.Path, "/"), "/")
+		if project == "" {
+			base, project = "", base
+		}
+		if project == "" {
+			return nil, fmt.Errorf("invalid issues destination %q: the Jira project key is missing", spec)
+		}
+		u.Path = "/" + base
+		return newJira(strings.TrimRight(u.String(), "/"), project), nil
+	}
+	return nil, fmt.Errorf("unsupported issues destination %q: use github:owner/repo or jira:https://host/PROJECT", spec)
+}
+
+// Sync opens or updates an issue for every warning of a run against the
+// target in meta, and resolves open issues of the target whose finding is no
+// longer reported.
+func Sync(ctx context.Context, spec string, a analyze.Analysis, meta collect.Meta) (Summary, error) {
+	t, err := newTracker(spec)
+	if err != nil {
+		return Summary{}, err
+	}
+	return reconcile(ctx, t, a, meta)
+}
+
+func reconcile(ctx context.Context, t tracker, a analyze.Analysis, meta collect.Meta) (Summary, error) {
+	var sum Summary
+	if meta.Target == "" {
+		return sum, errors.New("the run has no target name to key issues by")
+	}
+	existing, err := t.open(ctx)
+	if err != nil {
+		return sum, err
+	}
+	byKey := map[string]string{}
+	for _, is := range existing {
+		byKey[is.Key] = is.ID
+	}
+
+	reported := map[string]bool{}
+	for _, f := range a.Warnings {
+		k := key(f, meta.Target)
+		if reported[k] {
+			continue
+		}
+		reported[k] = true
+		title := fmt.Sprintf("%s on %s", f.Title, meta.Target)
+		body := issueBody(f, k, meta)
+		if id, ok := byKey[k]; ok {
+			if err := t.update(ctx, id, title, body); err != nil {
+				return sum, err
+			}
+			sum.Updated++
+			continue
+		}
+		if err := t.create(ctx, title, body); err != nil {
+			return sum, err
+		}
+		sum.Created++
+	}
+
+	// Only this target's issues are resolved; other targets share the tracker
+	suffix := "@" + meta.Target
+	for _, is := range existing {
+		if !strings.HasSuffix(is.Key, suffix) || reported[is.Key] {
+			continue
+		}
+		msg := fmt.Sprintf("No longer reported by pghealth on %s (run of %s).", meta.Target, meta.StartedAt.UTC().Format(time.RFC3339))
+		if err := t.resolve(ctx, is.ID, msg); err != nil {
+			return sum, err
+		}
+		sum.Resolved++
+	}
+	return sum, nil
+}
+
+// key identifies a finding on a target across runs by its code and the
+// object its title names, if any.
+func key(f analyze.Finding, target string) string {
+	k := f.Code
+	if k == "" {
+		k = strings.ToLower(strings.Join(strings.Fields(f.Title), "-"))
+	}
+	if obj := objectRe.FindString(f.Title); obj != "" {
+		k += ":" + obj
+	}
+	return k + "@" + target
+}
+
+// issueBody is the Markdown body of a finding's issue; the key marker lets
+// later runs find it.
+func issueBody(f analyze.Finding, k string, meta collect.Meta) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s\n\n", f.Description)
+	if f.Action != "" {
+		fmt.Fprintf(&b, "**Action:** %s\n\n", f.Action)
+	}
+	if f.Runbook != "" {
+		fmt.Fprintf(&b, "**Runbook:** %s\n\n", f.Runbook)
+	}
+	if f.Docs != "" {
+		fmt.Fprintf(&b, "**Docs:** %s\n\n", f.Docs)
+	}
+	fmt.Fprintf(&b, "Target: %s\n", meta.Target)
+	if len(f.Owners) > 0 {
+		fmt.Fprintf(&b, "Owners: %s\n", strings.Join(f.Owners, ", "))
+	}
+	for _, l := range meta.LabelPairs() {
+		fmt.Fprintf(&b, "Label: %s\n", l)
+	}
+	fmt.Fprintf(&b, "Last reported: %s by pghealth %s\n\n", meta.StartedAt.UTC().Format(time.RFC3339), meta.Version)
+	fmt.Fprintf(&b, "pghealth-key: %s\n", k)
+	return b.String()
+}
+
+// parseKey returns the key marker of an issue body.
+func parseKey(body string) string {
+	if m := keyRe.FindStringSubmatch(body); m != nil {
+		return m[1]
+	}
+	return ""
+}
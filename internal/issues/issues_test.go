@@ -0,0 +1,212 @@
+package issues
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/koltyakov/pghealth/internal/analyze"
+	"github.com/koltyakov/pghealth/internal/collect"
+)
+
+// TestNewTracker verifies -issues destination parsing.
+func TestNewTracker(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    string // base URL and project or repository
+		wantErr bool
+	}{
+		{spec: "github:acme/db", want: "acme/db"},
+		{spec: "github:acme", wantErr: true},
+		{spec: "github:acme/db/x", wantErr: true},
+		{spec: "jira:https://acme.atlassian.net/OPS", want: "https://acme.atlassian.net OPS"},
+		{spec: "jira:https://jira.acme.com/jira/OPS", want: "https://jira.acme.com/jira OPS"},
+		{spec: "jira:https://acme.atlassian.net", wantErr: true},
+		{spec: "jira:OPS", wantErr: true},
+		{spec: "gitlab:acme/db", wantErr: true},
+	}
+	for _, tt := range tests {
+		tr, err := newTracker(tt.spec)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("newTracker(%q) error = %v, wantErr %v", tt.spec, err, tt.wantErr)
+			continue
+		}
+		var got string
+		switch tr := tr.(type) {
+		case *github:
+			got = tr.repo
+		case *jira:
+			got = tr.base + " " + tr.project
+		}
+		if got != tt.want {
+			t.Errorf("newTracker(%q) = %q, want %q", tt.spec, got, tt.want)
+		}
+	}
+}
+
+// TestKey verifies that issue keys combine the code, the named object and
+// the target, and survive a round trip through the issue body.
+func TestKey(t *testing.T) {
+	f := analyze.Finding{Title: "Sequence public.orders_id_seq near exhaustion", Code: "sequence-exhaustion"}
+	k := key(f, "db1:5432/app")
+	if k != "sequence-exhaustion:public.orders_id_seq@db1:5432/app" {
+		t.Errorf("key() = %q", k)
+	}
+	if got := key(analyze.Finding{Title: "Low  cache hit"}, "db1:5432/app"); got != "low-cache-hit@db1:5432/app" {
+		t.Errorf("key() without code = %q", got)
+	}
+	if got := parseKey(issueBody(f, k, collect.Meta{Target: "db1:5432/app"})); got != k {
+		t.Errorf("parseKey(issueBody()) = %q, want %q", got, k)
+	}
+}
+
+// TestSyncGitHub verifies that a run creates issues for new warnings, updates
+// open ones and closes issues of its target that are no longer reported.
+func TestSyncGitHub(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		calls []string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer tok" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		mu.Lock()
+		calls = append(calls, r.Method+" "+r.URL.Path)
+		mu.Unlock()
+		switch {
+		case r.Method == http.MethodGet:
+			if r.URL.Query().Get("labels") != Label {
+				t.Errorf("issues listed without the %s label: %s", Label, r.URL.RawQuery)
+			}
+			_ = json.NewEncoder(w).Encode([]map[string]any{
+				{"number": 1, "body": "...\npghealth-key: xid-age-warning@db1:5432/app\n"},
+				{"number": 2, "body": "pghealth-key: replication-lag@db1:5432/app"},
+				{"number": 3, "body": "pghealth-key: replication-lag@db2:5432/app"},
+				{"number": 4, "body": "pghealth-key: replication-lag@db1:5432/app", "pull_request": map[string]any{}},
+			})
+		case r.Method == http.MethodPost && r.URL.Path == "/repos/acme/db/issues":
+			var in struct {
+				Title  string   `json:"title"`
+				Body   string   `json:"body"`
+				Labels []string `json:"labels"`
+			}
+			_ = json.NewDecoder(r.Body).Decode(&in)
+			if in.Title != "Too many connections on db1:5432/app" || !strings.Contains(in.Body, "pghealth-key: connections-high@db1:5432/app") || len(in.Labels) != 1 {
+				t.Errorf("unexpected issue created: %+v", in)
+			}
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte("{}"))
+		default:
+			_, _ = w.Write([]byte("{}"))
+		}
+	}))
+	defer srv.Close()
+	t.Setenv("GITHUB_API_URL", srv.URL)
+	t.Setenv("GITHUB_TOKEN", "tok")
+
+	a := analyze.Analysis{
+		Warnings: []analyze.Finding{
+			{Title: "Transaction ID age high", Code: "xid-age-warning", Description: "d"},
+			{Title: "Too many connections", Code: "connections-high", Description: "d"},
+		},
+		Recommendations: []analyze.Finding{{Title: "Tune work_mem", Code: "work-mem-low"}},
+	}
+	meta := collect.Meta{Target: "db1:5432/app", StartedAt: time.Now()}
+	sum, err := Sync(context.Background(), "github:acme/db", a, meta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sum != (Summary{Created: 1, Updated: 1, Resolved: 1}) {
+		t.Errorf("Sync() = %+v", sum)
+	}
+	want := []string{
+		"GET /repos/acme/db/issues",
+		"PATCH /repos/acme/db/issues/1",
+		"POST /repos/acme/db/issues",
+		"POST /repos/acme/db/issues/2/comments",
+		"PATCH /repos/acme/db/issues/2",
+	}
+	if strings.Join(calls, "\n") != strings.Join(want, "\n") {
+		t.Errorf("calls:\n%s\nwant:\n%s", strings.Join(calls, "\n"), strings.Join(want, "\n"))
+	}
+
+	t.Setenv("GITHUB_TOKEN", "")
+	if _, err := Sync(context.Background(), "github:acme/db", a, meta); err == nil || !strings.Contains(err.Error(), "GITHUB_TOKEN") {
+		t.Errorf("Sync() without token error = %v", err)
+	}
+}
+
+// TestSyncJira verifies searching, creating, updating and commenting on Jira
+// issues, including search paging.
+func TestSyncJira(t *testing.T) {
+	var calls []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if u, p, ok := r.BasicAuth(); !ok || u != "ops@acme.com" || p != "secret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		calls = append(calls, r.Method+" "+r.URL.Path)
+		switch r.Method {
+		case http.MethodGet:
+			if !strings.Contains(r.URL.Query().Get("jql"), `project = "OPS"`) {
+				t.Errorf("unexpected JQL: %s", r.URL.Query().Get("jql"))
+			}
+			desc := "pghealth-key: xid-age-warning@db1:5432/app"
+			if r.URL.Query().Get("startAt") != "0" {
+				desc = "pghealth-key: replication-lag@db1:5432/app"
+			}
+			_ = json.NewEncoder(w).Encode(map[string]any{
+				"total":  101,
+				"issues": []map[string]any{{"key": "OPS-" + r.URL.Query().Get("startAt"), "fields": map[string]any{"description": desc}}},
+			})
+		case http.MethodPost:
+			if r.URL.Path == "/jira/rest/api/2/issue" {
+				var in struct {
+					Fields map[string]any `json:"fields"`
+				}
+				_ = json.NewDecoder(r.Body).Decode(&in)
+				if in.Fields["issuetype"].(map[string]any)["name"] != "Bug" {
+					t.Errorf("unexpected issue type: %v", in.Fields["issuetype"])
+				}
+			}
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte("{}"))
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer srv.Close()
+	t.Setenv("JIRA_EMAIL", "ops@acme.com")
+	t.Setenv("JIRA_API_TOKEN", "secret")
+	t.Setenv("JIRA_TOKEN", "")
+	t.Setenv("JIRA_ISSUE_TYPE", "Bug")
+
+	a := analyze.Analysis{Warnings: []analyze.Finding{
+		{Title: "Transaction ID age high", Code: "xid-age-warning"},
+		{Title: "Too many connections", Code: "connections-high"},
+	}}
+	sum, err := Sync(context.Background(), "jira:"+srv.URL+"/jira/OPS", a, collect.Meta{Target: "db1:5432/app"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sum != (Summary{Created: 1, Updated: 1, Resolved: 1}) {
+		t.Errorf("Sync() = %+v", sum)
+	}
+	want := []string{
+		"GET /jira/rest/api/2/search",
+		"GET /jira/rest/api/2/search",
+		"PUT /jira/rest/api/2/issue/OPS-0",
+		"POST /jira/rest/api/2/issue",
+		"POST /jira/rest/api/2/issue/OPS-100/comment",
+	}
+	if strings.Join(calls, "\n") != strings.Join(want, "\n") {
+		t.Errorf("calls:\n%s\nwant:\n%s", strings.Join(calls, "\n"), strings.Join(want, "\n"))
+	}
+}
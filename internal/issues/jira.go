@@ -0,0 +1,134 @@
+package issues
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+
+	"github.com/koltyakov/pghealth/internal/httputil"
+)
+
+// jiraPageSize is the number of issues requested per search page.
+const jiraPageSize = 100
+
+// jira manages issues of one project through the REST API v2. Issues are
+// created with type JIRA_ISSUE_TYPE (default Task). Resolved findings are
+// only commented on: workflows and their transitions differ per project.
+type jira struct {
+	base      string
+	project   string
+	issueType string
+	email     string
+	token     string
+	bearer    string
+}
+
+func newJira(base, project string) *jira {
+	j := &jira{
+		base:      base,
+		project:   project,
+		issueType: os.Getenv("JIRA_ISSUE_TYPE"),
+		email:     os.Getenv("JIRA_EMAIL"),
+		token:     os.Getenv("JIRA_API_TOKEN"),
+		bearer:    os.Getenv("JIRA_TOKEN"),
+	}
+	if j.issueType == "" {
+		j.issueType = "Task"
+	}
+	return j
+}
+
+func (j *jira) open(ctx context.Context) ([]issue, error) {
+	jql := fmt.Sprintf("project = %q AND labels = %q AND statusCategory != Done", j.project, Label)
+	var out []issue
+	for start := 0; ; start += jiraPageSize {
+		var page struct {
+			Total  int `json:"total"`
+			Issues []struct {
+				Key    string `json:"key"`
+				Fields struct {
+					Description string `json:"description"`
+				} `json:"fields"`
+			} `json:"issues"`
+		}
+		q := url.Values{"jql": {jql}, "fields": {"description"}, "startAt": {fmt.Sprint(start)}, "maxResults": {fmt.Sprint(jiraPageSize)}}
+		if err := j.do(ctx, http.MethodGet, "/rest/api/2/search?"+q.Encode(), nil, &page); err != nil {
+			return nil, err
+		}
+		for _, is := range page.Issues {
+			if k := parseKey(is.Fields.Description); k != "" {
+				out = append(out, issue{ID: is.Key, Key: k})
+			}
+		}
+		if len(page.Issues) == 0 || start+len(page.Issues) >= page.Total {
+			return out, nil
+		}
+	}
+}
+
+func (j *jira) create(ctx context.Context, title, body string) error {
+	fields := map[string]any{
+		"project":     map[string]string{"key": j.project},
+		"issuetype":   map[string]string{"name": j.issueType},
+		"summary":     title,
+		"description": body,
+		"labels":      []string{Label},
+	}
+	return j.do(ctx, http.MethodPost, "/rest/api/2/issue", map[string]any{"fields": fields}, nil)
+}
+
+func (j *jira) update(ctx context.Context, id, title, body string) error {
+	return j.do(ctx, http.MethodPut, "/rest/api/2/issue/"+url.PathEscape(id),
+		map[string]any{"fields": map[string]any{"summary": title, "description": body}}, nil)
+}
+
+func (j *jira) resolve(ctx context.Context, id, comment string) error {
+	return j.do(ctx, http.MethodPost, "/rest/api/2/issue/"+url.PathEscape(id)+"/comment", map[string]any{"body": comment}, nil)
+}
+
+// do sends a JSON request and decodes the response into out when not nil.
+func (j *jira) do(ctx context.Context, method, path string, in, out any) error {
+	if j.bearer == "" && (j.email == "" || j.token == "") {
+		return fmt.Errorf("jira issues need JIRA_EMAIL and JIRA_API_TOKEN, or JIRA_TOKEN")
+	}
+	var body io.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return fmt.Errorf("encode request: %w", err)
+		}
+		body = bytes.NewReader(b)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, j.base+path, body)
+	if err != nil {
+		return fmt.Errorf("build request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if j.bearer != "" {
+		req.Header.Set("Authorization", "Bearer "+j.bearer)
+	} else {
+		req.SetBasicAuth(j.email, j.token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("jira: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("jira %s %s: %s: %s", method, req.URL.Path, resp.Status, httputil.ReadErrorBody(resp.Body))
+	}
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return fmt.Errorf("jira %s: %w", req.URL.Path, err)
+		}
+	}
+	return nil
+}
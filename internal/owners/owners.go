@@ -0,0 +1,204 @@
+// Package owners routes findings to the teams owning the affected schemas,
+// tables or finding codes, so one report of a shared cluster splits into
+// per-team action lists.
+//
+// A rules file lists teams with glob patterns:
+//
+//	default: dba
+//	teams:
+//	  - name: payments
+//	    schemas: [billing]
+//	    tables: ["public.invoice*"]
+//	    codes: [sequence-exhaustion]
+//
+// A finding is assigned to every team with a matching code pattern or owning
+// a relation named in the finding; findings no team matches go to the
+// default team, if any.
+package owners
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/koltyakov/pghealth/internal/analyze"
+	"github.com/koltyakov/pghealth/internal/collect"
+)
+
+// Team owns the objects and finding codes matching its patterns.
+type Team struct {
+	Name    string   `yaml:"name"`
+	Schemas []string `yaml:"schemas"` // schema name patterns
+	Tables  []string `yaml:"tables"`  // schema.table patterns
+	Codes   []string `yaml:"codes"`   // finding code patterns
+}
+
+// Rules is the ownership configuration.
+type Rules struct {
+	Default string `yaml:"default"` // team receiving unmatched findings
+	Teams   []Team `yaml:"teams"`
+}
+
+// qualifiedNameRe matches schema.object names in finding text.
+var qualifiedNameRe = regexp.MustCompile(`[A-Za-z_][A-Za-z0-9_$]*\.[A-Za-z_][A-Za-z0-9_$]*`)
+
+// Load reads and validates a rules file.
+func Load(file string) (Rules, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return Rules{}, fmt.Errorf("read owners: %w", err)
+	}
+	var r Rules
+	if err := yaml.Unmarshal(b, &r); err != nil {
+		return Rules{}, fmt.Errorf("parse owners %s: %w", file, err)
+	}
+	if len(r.Teams) == 0 && r.Default == "" {
+		return Rules{}, fmt.Errorf("%s defines no teams", file)
+	}
+	for i, t := range r.Teams {
+		if t.Name == "" {
+			return Rules{}, fmt.Errorf("%s: team %d has no name", file, i+1)
+		}
+		for _, p := range append(append(append([]string{}, t.Schemas...), t.Tables...), t.Codes...) {
+			if _, err := path.Match(p, ""); err != nil {
+				return Rules{}, fmt.Errorf("%s: team %s: invalid pattern %q", file, t.Name, p)
+			}
+		}
+	}
+	return r, nil
+}
+
+// object is a relation a finding refers to; Table is empty when only the
+// schema is known.
+type object struct {
+	Schema, Table string
+}
+
+// Assign sets the owning teams of every finding in a. Relations are
+// recognized in the finding text by their schema-qualified names; indexes
+// belong to the owner of their table.
+func (r Rules) Assign(res collect.Result, a analyze.Analysis) analyze.Analysis {
+	known := relations(res)
+	for _, list := range [][]analyze.Finding{a.Warnings, a.Recommendations, a.Infos} {
+		for i := range list {
+			list[i].Owners = r.owners(list[i], known)
+		}
+	}
+	return a
+}
+
+// TeamNames returns the team names in configuration order, the default last.
+func (r Rules) TeamNames() []string {
+	var out []string
+	for _, t := range r.Teams {
+		out = appendUnique(out, t.Name)
+	}
+	if r.Default != "" {
+		out = appendUnique(out, r.Default)
+	}
+	return out
+}
+
+func (r Rules) owners(f analyze.Finding, known map[string]object) []string {
+	objects := referenced(f, known)
+	var out []string
+	for _, t := range r.Teams {
+		if t.owns(f.Code, objects) {
+			out = appendUnique(out, t.Name)
+		}
+	}
+	if len(out) == 0 && r.Default != "" {
+		out = []string{r.Default}
+	}
+	return out
+}
+
+func (t Team) owns(code string, objects []object) bool {
+	if code != "" && matchAny(t.Codes, code) {
+		return true
+	}
+	for _, o := range objects {
+		if matchAny(t.Schemas, o.Schema) || (o.Table != "" && matchAny(t.Tables, o.Schema+"."+o.Table)) {
+			return true
+		}
+	}
+	return false
+}
+
+// relations maps schema-qualified table and index names to their table.
+// Bare schema names map to a schema-only object.
+func relations(res collect.Result) map[string]object {
+	known := map[string]object{}
+	add := func(schema, name, table string) {
+		if schema == "" || name == "" {
+			return
+		}
+		known[schema+"."+name] = object{Schema: schema, Table: table}
+		if _, ok := known[schema]; !ok {
+			known[schema] = object{Schema: schema}
+		}
+	}
+	for _, t := range res.Tables {
+		add(t.Schema, t.Name, t.Name)
+	}
+	for _, ix := range res.Indexes {
+		add(ix.Schema, ix.Table, ix.Table)
+		add(ix.Schema, ix.Name, ix.Table)
+	}
+	for _, ix := range res.IndexUnused {
+		add(ix.Schema, ix.Table, ix.Table)
+		add(ix.Schema, ix.Name, ix.Table)
+	}
+	return known
+}
+
+// referenced returns the relations in the finding evidence and named in its
+// text. A qualified name that is not a known relation still counts for its
+// schema when the schema is known, e.g. a sequence or a view.
+func referenced(f analyze.Finding, known map[string]object) []object {
+	var out []object
+	seen := map[object]bool{}
+	names := qualifiedNameRe.FindAllString(f.Title+"\n"+f.Description+"\n"+f.Action, -1)
+	if f.Evidence != nil {
+		for _, o := range f.Evidence.Objects {
+			if o.Schema != "" {
+				names = append(names, o.Schema+"."+o.Name)
+			}
+		}
+	}
+	for _, name := range names {
+		o, ok := known[name]
+		if !ok {
+			schema, _, _ := strings.Cut(name, ".")
+			if o, ok = known[schema]; !ok {
+				continue
+			}
+		}
+		if !seen[o] {
+			seen[o] = true
+			out = append(out, o)
+		}
+	}
+	return out
+}
+
+func matchAny(patterns []string, s string) bool {
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, s); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func appendUnique(list []string, s string) []string {
+	if slices.Contains(list, s) {
+		return list
+	}
+	return append(list, s)
+}
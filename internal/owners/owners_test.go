@@ -0,0 +1,86 @@
+package owners
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/koltyakov/pghealth/internal/analyze"
+	"github.com/koltyakov/pghealth/internal/collect"
+)
+
+// TestLoad verifies parsing and validation of the rules file.
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, body string) string {
+		p := filepath.Join(dir, name)
+		if err := os.WriteFile(p, []byte(body), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+
+	r, err := Load(write("ok.yaml", "default: dba\nteams:\n  - name: payments\n    schemas: [billing]\n    codes: [seq-*]\n"))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if got, want := r.TeamNames(), []string{"payments", "dba"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("TeamNames = %v, want %v", got, want)
+	}
+
+	for name, body := range map[string]string{
+		"empty.yaml":   "teams: []\n",
+		"noname.yaml":  "teams:\n  - schemas: [a]\n",
+		"pattern.yaml": "teams:\n  - name: x\n    tables: ['public.[a']\n",
+		"bad.yaml":     "teams: [\n",
+	} {
+		if _, err := Load(write(name, body)); err == nil {
+			t.Errorf("Load(%s): want an error", name)
+		}
+	}
+}
+
+// TestAssign verifies routing by finding code, schema, table pattern and
+// index-to-table mapping, with the default team for the rest.
+func TestAssign(t *testing.T) {
+	rules := Rules{
+		Default: "dba",
+		Teams: []Team{
+			{Name: "payments", Schemas: []string{"billing"}, Tables: []string{"public.invoice*"}},
+			{Name: "search", Tables: []string{"public.documents"}, Codes: []string{"gin-*"}},
+		},
+	}
+	res := collect.Result{
+		Tables: []collect.TableStat{
+			{Schema: "billing", Name: "charges"},
+			{Schema: "public", Name: "invoices"},
+			{Schema: "public", Name: "documents"},
+		},
+		Indexes: []collect.IndexStat{{Schema: "public", Table: "documents", Name: "documents_body_idx"}},
+	}
+	a := analyze.Analysis{
+		Warnings: []analyze.Finding{
+			{Title: "Bloat", Code: "bloat", Description: "Tables with high dead tuple ratio: billing.charges, public.invoices"},
+			{Title: "Pending list", Code: "gin-pending-list", Description: "GIN pending list is large"},
+		},
+		Recommendations: []analyze.Finding{
+			{Title: "Unused index", Code: "unused-index", Description: "public.documents_body_idx is never scanned"},
+			{Title: "Sequence", Code: "sequence", Description: "billing.charges_id_seq is 80% used"},
+			{Title: "Settings", Code: "work-mem", Description: "work_mem is low (e.g. for sorts)"},
+			{Title: "Hot rows", Code: "lock-hot-rows", Description: "Sessions queue to update the same rows",
+				Evidence: &analyze.Evidence{Objects: []analyze.Object{{Kind: "table", Schema: "public", Name: "invoices"}}}},
+		},
+	}
+	a = rules.Assign(res, a)
+	want := [][]string{{"payments"}, {"search"}, {"search"}, {"payments"}, {"dba"}, {"payments"}}
+	var got [][]string
+	for _, list := range [][]analyze.Finding{a.Warnings, a.Recommendations} {
+		for _, f := range list {
+			got = append(got, f.Owners)
+		}
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("owners = %v, want %v", got, want)
+	}
+}
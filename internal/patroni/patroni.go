@@ -0,0 +1,103 @@
+// Package patroni reads cluster state from the Patroni REST API: members
+// with their roles and lag, the pause flag and the failover history.
+package patroni
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/koltyakov/pghealth/internal/collect"
+	"github.com/koltyakov/pghealth/internal/httputil"
+)
+
+const (
+	// DefaultTimeout bounds reading the cluster state.
+	DefaultTimeout = 10 * time.Second
+)
+
+// cluster is the GET /cluster response.
+type cluster struct {
+	Members []struct {
+		Name     string          `json:"name"`
+		Role     string          `json:"role"`
+		State    string          `json:"state"`
+		Host     string          `json:"host"`
+		Port     int             `json:"port"`
+		Timeline int64           `json:"timeline"`
+		Lag      json.RawMessage `json:"lag"` // bytes, or "unknown"
+	} `json:"members"`
+	Pause bool `json:"pause"`
+}
+
+// Fetch reads the cluster topology from GET /cluster and the failover
+// history from GET /history of any member's REST API at baseURL. Credentials
+// in baseURL are sent as basic auth.
+func Fetch(ctx context.Context, baseURL string) (*collect.PatroniCluster, error) {
+	base := strings.TrimRight(baseURL, "/")
+	var c cluster
+	if err := get(ctx, base+"/cluster", &c); err != nil {
+		return nil, err
+	}
+	pc := &collect.PatroniCluster{Paused: c.Pause}
+	for _, m := range c.Members {
+		pm := collect.PatroniMember{Name: m.Name, Role: m.Role, State: m.State, Host: m.Host, Port: m.Port, Timeline: m.Timeline, Lag: -1}
+		_ = json.Unmarshal(m.Lag, &pm.Lag)
+		pc.Members = append(pc.Members, pm)
+	}
+
+	// [timeline, lsn, reason, timestamp, new leader]; the last two were
+	// added in Patroni 1.6 and 2.0
+	var history [][]json.RawMessage
+	if err := get(ctx, base+"/history", &history); err != nil {
+		return pc, err
+	}
+	for _, h := range history {
+		var f collect.PatroniFailover
+		for i, v := range h {
+			switch i {
+			case 0:
+				_ = json.Unmarshal(v, &f.Timeline)
+			case 1:
+				_ = json.Unmarshal(v, &f.LSN)
+			case 2:
+				_ = json.Unmarshal(v, &f.Reason)
+			case 3:
+				var ts string
+				if json.Unmarshal(v, &ts) == nil {
+					f.At, _ = time.Parse(time.RFC3339Nano, ts)
+				}
+			case 4:
+				_ = json.Unmarshal(v, &f.NewLeader)
+			}
+		}
+		pc.History = append(pc.History, f)
+	}
+	sort.Slice(pc.History, func(i, j int) bool { return pc.History[i].Timeline > pc.History[j].Timeline })
+	return pc, nil
+}
+
+// get decodes the JSON response of url into v.
+func get(ctx context.Context, url string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return fmt.Errorf("build request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("patroni: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("patroni %s: %s: %s", req.URL.Path, resp.Status, httputil.ReadErrorBody(resp.Body))
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("patroni %s: %w", req.URL.Path, err)
+	}
+	return nil
+}
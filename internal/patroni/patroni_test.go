@@ -0,0 +1,56 @@
+package patroni
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// TestFetch verifies members, pause state and history are read from the
+// REST API, including unknown lag and short history entries.
+func TestFetch(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if u, p, ok := r.BasicAuth(); !ok || u != "admin" || p != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/cluster":
+			_, _ = w.Write([]byte(`{"members":[
+				{"name":"pg1","role":"leader","state":"running","host":"10.0.0.1","port":5432,"timeline":3},
+				{"name":"pg2","role":"sync_standby","state":"streaming","host":"10.0.0.2","port":5432,"timeline":3,"lag":0},
+				{"name":"pg3","role":"replica","state":"stopped","host":"10.0.0.3","port":5432,"lag":"unknown"}],
+				"pause":true}`))
+		case "/history":
+			_, _ = w.Write([]byte(`[[1,25623960,"no recovery target specified"],
+				[2,50331808,"no recovery target specified","2026-10-10T08:00:00+00:00","pg1"]]`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	pc, err := Fetch(context.Background(), "http://admin:secret@"+srv.Listener.Addr().String()+"/")
+	if err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+	if !pc.Paused || len(pc.Members) != 3 {
+		t.Fatalf("Fetch() = %+v", pc)
+	}
+	if l, ok := pc.Leader(); !ok || l.Name != "pg1" {
+		t.Errorf("Leader() = %+v, %v", l, ok)
+	}
+	if pc.Members[1].Lag != 0 || pc.Members[2].Lag != -1 {
+		t.Errorf("lag = %d, %d, want 0 and -1 (unknown)", pc.Members[1].Lag, pc.Members[2].Lag)
+	}
+	if len(pc.History) != 2 || pc.History[0].Timeline != 2 || pc.History[0].NewLeader != "pg1" ||
+		!pc.History[0].At.Equal(time.Date(2026, 10, 10, 8, 0, 0, 0, time.UTC)) || !pc.History[1].At.IsZero() {
+		t.Errorf("History = %+v", pc.History)
+	}
+
+	if _, err := Fetch(context.Background(), srv.URL); err == nil {
+		t.Error("Fetch() without credentials succeeded")
+	}
+}
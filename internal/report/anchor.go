@@ -0,0 +1,324 @@
+package report
+
+import (
+	"strings"
+
+	"github.com/koltyakov/pghealth/internal/analyze"
+	"github.com/koltyakov/pghealth/internal/collect"
+)
+
+// Link sets the anchor of the report section detailing each finding in its
+// evidence, so JSON and archive consumers can point at the section. Findings
+// whose section is not rendered keep no anchor.
+func Link(res collect.Result, a analyze.Analysis) analyze.Analysis {
+	link := func(list []analyze.Finding) []analyze.Finding {
+		out := make([]analyze.Finding, len(list))
+		for i, f := range list {
+			if anchor := sectionAnchor(res, f.Code, f.Title); anchor != "" {
+				ev := analyze.Evidence{}
+				if f.Evidence != nil {
+					ev = *f.Evidence
+				}
+				ev.Anchor = anchor
+				f.Evidence = &ev
+			}
+			out[i] = f
+		}
+		return out
+	}
+	a.Warnings = link(a.Warnings)
+	a.Recommendations = link(a.Recommendations)
+	a.Infos = link(a.Infos)
+	return a
+}
+
+// sectionAnchor maps a finding to the anchor of its report section, or ""
+// when the section is not rendered (no details) so the card is not a link.
+func sectionAnchor(res collect.Result, code, title string) string {
+	// Helpers for availability
+	hasWaits := len(res.WaitEvents) > 0
+	hasWal := res.WAL != nil
+	hasTemp := len(res.TempFileStats) > 0
+	hasExtList := len(res.ExtensionStats) > 0
+	hasFuncs := len(res.FunctionStats) > 0
+	hasCI := len(res.ProgressCreateIndex) > 0
+	hasPSSLists := res.Extensions.HasQueryStats() && res.Statements.SkippedReason == ""
+	hasUnusedIdx := len(res.IndexUnused) > 0
+	hasRepl := len(res.ReplicationStats) > 0
+
+	switch code {
+	case "io-waits", "lock-waits", "bufferpin-waits":
+		if hasWaits {
+			return "#hdr-waits"
+		}
+		return ""
+	case "high-wal", "wal-fpi", "wal-fpi-high":
+		if hasWal {
+			return "#hdr-wal"
+		}
+		return ""
+	case "unused-indexes", "unused-constraint-indexes":
+		if hasUnusedIdx {
+			return "#hdr-index-unused"
+		}
+		return ""
+	case "too-many-indexes", "table-bloat-heuristic":
+		return "#hdr-index-counts"
+	case "missing-indexes":
+		return "#hdr-index-usage-low"
+	case "pooler-detected", "connection-limit-forecast", "connection-peaks", "connection-growth":
+		return "#hdr-connections-clients"
+	case "client-outdated-driver", "client-tls-outdated", "client-unencrypted", "client-versions-mixed":
+		if len(res.ClientFingerprints) > 0 {
+			return "#hdr-client-libraries"
+		}
+		return ""
+	case "sql-ascii-database", "mixed-collations", "database-locale-mismatch":
+		if len(res.DBs) > 0 {
+			return "#hdr-databases"
+		}
+		return ""
+	case "default-privileges-public":
+		return "#hdr-default-privileges"
+	case "md5-passwords", "hba-cleartext-password", "hba-md5-method":
+		if res.DataDirectory != nil {
+			return "#hdr-security"
+		}
+		return ""
+	case "role-idle-timeouts", "roles-without-limits":
+		return "#hdr-tuning-script"
+	case "connection-churn":
+		if len(res.SessionStats) > 0 {
+			return "#hdr-sessions"
+		}
+		return ""
+	case "sync-standby-missing", "sync-quorum-mismatch":
+		if hasRepl {
+			return "#hdr-replication"
+		}
+		return ""
+	case "replication-slot-cleanup":
+		return "#hdr-replication-slots"
+	case "vacuum-index-passes":
+		return "#hdr-autovacuum"
+	case "tablespace-imbalance":
+		return "#hdr-tablespaces"
+	case "storage-full-forecast", "storage-forecast", "storage-growth":
+		return "#hdr-databases"
+	case "copy-in-progress", "rewrite-in-progress", "base-backup-in-progress":
+		if len(res.ProgressCopy)+len(res.ProgressCluster)+len(res.ProgressBasebackup) > 0 {
+			return "#hdr-operations"
+		}
+		return ""
+	case "slow-index-improve", "slow-refactor", "slow-sorts", "slow-joins", "slow-seq-scans":
+		if hasPSSLists {
+			return "#hdr-queries-total-time"
+		}
+		return ""
+	case "lock-hot-rows", "lock-table-queue":
+		if len(res.LockHotspots) > 0 {
+			return "#hdr-lock-hotspots"
+		}
+		return ""
+	case "subxact-overflow", "subtrans-slru-misses":
+		if res.Subtransactions != nil {
+			return "#hdr-subtransactions"
+		}
+		return ""
+	case "parallel-workers-cpus", "parallel-gather-cpus", "numa-zone-reclaim", "cgroup-memory-limit", "cgroup-effective-cache-size":
+		if res.OSCPU != nil {
+			return "#hdr-os-cpu"
+		}
+		return ""
+	case "worker-processes-low":
+		return "#hdr-settings"
+	case "huge-pages", "transparent-huge-pages", "vm-overcommit":
+		if res.OSMemory != nil {
+			return "#hdr-os-memory"
+		}
+		return "#hdr-settings"
+	case "patroni-paused", "patroni-no-leader", "patroni-failovers", "patroni-replica-missing", "patroni-sync-mismatch", "patroni-unknown-standby":
+		if res.Patroni != nil {
+			return "#hdr-patroni"
+		}
+		return ""
+	case "repmgr-standby-not-streaming", "repmgr-monitoring-gap", "repmgr-inactive-node", "autofailover-unhealthy", "autofailover-state-mismatch":
+		if res.HA != nil {
+			return "#hdr-ha"
+		}
+		return ""
+	case "slo-breached", "slo-budget-burn":
+		if len(res.SLOs) > 0 {
+			return "#hdr-slo"
+		}
+		return ""
+	case "restore-verify-failed", "restore-verified":
+		if res.RestoreCheck != nil {
+			return "#hdr-restore"
+		}
+		return ""
+	case "wal-archiving-failing", "wal-archive-gap", "wal-archive-unverified", "wal-archive-verified":
+		if res.WALArchiving != nil {
+			return "#hdr-wal-archiving"
+		}
+		return ""
+	case "file-settings-errors", "file-settings-overridden", "hba-file-errors":
+		if res.ConfigFiles != nil {
+			return "#hdr-config-files"
+		}
+		return ""
+	case "replica-xmin-horizon", "vacuum-defer-cleanup-age":
+		if res.VacuumHorizon != nil {
+			return "#hdr-vacuum-horizon"
+		}
+		return ""
+	case "freeze-vacuum-forecast":
+		if res.FreezeForecast != nil {
+			return "#hdr-freeze-forecast"
+		}
+		return ""
+	case "query-tail-latency", "query-text-unavailable":
+		if hasPSSLists && len(res.Statements.TopByTotalTime) > 0 {
+			return "#hdr-queries-total-time"
+		}
+		return ""
+	case "n-plus-one":
+		if hasPSSLists && len(res.Statements.TopByCalls) > 0 {
+			return "#hdr-queries-calls"
+		}
+		return ""
+	case "long-running":
+		return "#hdr-long-running"
+	case "load-by-role":
+		if hasPSSLists && len(res.Statements.ByRole) > 0 {
+			return "#hdr-query-load"
+		}
+		return ""
+	case "ci-wait-lockers":
+		if hasCI {
+			return "#hdr-progress-ci"
+		}
+		return ""
+	case "hot-function", "hot-functions-multi":
+		if hasFuncs {
+			return "#hdr-functions"
+		}
+		return ""
+	case "track-counts-off", "track-activities-off", "track-functions-off", "track-activity-query-size":
+		return "#hdr-settings"
+	case "install-pgss", "auto-explain", "auto-explain-noisy", "auto-explain-timing",
+		"pgaudit-not-loaded", "pgaudit-inactive", "pgaudit-gaps", "pgaudit-coverage":
+		return "#hdr-settings"
+	case "missing-extensions":
+		if hasExtList {
+			return "#hdr-extensions"
+		}
+		return ""
+	case "enable-track-io", "wal-level-minimal", "checkpoint-timeout-low", "ecs-low-vs-sb", "high-max-connections", "autovacuum-naptime-high", "maintenance-work-mem-low", "random-page-cost-default", "no-statement-timeout", "no-idle-tx-timeout", "ssl-off", "shared-buffers-low", "max-wal-size-low", "wal-buffers-low", "parallel-workers-low", "work-mem-low",
+		"jit-oltp", "jit-analytical", "parallel-oltp", "parallel-analytical":
+		return "#hdr-settings"
+	case "workload-class":
+		if hasPSSLists {
+			return "#hdr-queries-total-time"
+		}
+		return ""
+	case "cache-overall", "cache-hit-trend":
+		return "#hdr-cache-hit"
+	case "relation-growth":
+		return "#hdr-growth"
+	case "query-regression":
+		if hasPSSLists {
+			return "#hdr-queries-total-time"
+		}
+		return ""
+	// New health check anchors
+	case "xid-wraparound-critical", "xid-age-warning", "xid-wraparound-forecast":
+		if len(res.XIDAge) > 0 {
+			return "#hdr-xid-age"
+		}
+		return ""
+	case "idle-in-transaction":
+		if len(res.IdleInTransaction) > 0 {
+			return "#hdr-idle-in-transaction"
+		}
+		return ""
+	case "stale-statistics":
+		if len(res.StaleStatsTables) > 0 {
+			return "#hdr-stale-statistics"
+		}
+		return ""
+	case "retention-candidates":
+		return "#hdr-tables-by-size"
+	case "skewed-filter-columns":
+		if len(res.ColumnStats) > 0 {
+			return "#hdr-column-stats"
+		}
+		return ""
+	case "duplicate-indexes":
+		if len(res.DuplicateIndexes) > 0 {
+			return "#hdr-duplicate-indexes"
+		}
+		return ""
+	case "redundant-indexes":
+		if len(res.RedundantIndexes) > 0 {
+			return "#hdr-redundant-indexes"
+		}
+		return ""
+	case "index-low-selectivity":
+		if len(res.LowSelectivityIndexes) > 0 {
+			return "#hdr-index-low-selectivity"
+		}
+		return ""
+	case "invalid-indexes":
+		if len(res.InvalidIndexes) > 0 {
+			return "#hdr-invalid-indexes"
+		}
+		return ""
+	case "fk-missing-index":
+		if len(res.FKMissingIndexes) > 0 {
+			return "#hdr-fk-missing-indexes"
+		}
+		return ""
+	case "sequence-exhaustion-critical", "sequence-exhaustion-warning":
+		if len(res.SequenceHealth) > 0 {
+			return "#hdr-sequence-health"
+		}
+		return ""
+	case "prepared-transactions":
+		if len(res.PreparedXacts) > 0 {
+			return "#hdr-prepared-xacts"
+		}
+		return ""
+	case "standby-delay-unlimited", "standby-conflict-snapshot", "standby-conflict-lock", "standby-conflict-bufferpin", "standby-conflict-tablespace":
+		if res.StandbyConflicts != nil {
+			return "#hdr-standby-conflicts"
+		}
+		return ""
+	}
+	// Fallback by keywords in title when code missing
+	lt := strings.ToLower(title)
+	switch {
+	case strings.Contains(lt, "wait"):
+		if hasWaits {
+			return "#hdr-waits"
+		}
+		return ""
+	case strings.Contains(lt, "block"):
+		return "#hdr-blocking" // always present
+	case strings.Contains(lt, "autovac"):
+		return "#hdr-autovacuum" // always present
+	case strings.Contains(lt, "replication"):
+		if hasRepl {
+			return "#hdr-replication"
+		}
+		return ""
+	case strings.Contains(lt, "temp"):
+		if hasTemp {
+			return "#hdr-temp-files"
+		}
+		return ""
+	case strings.Contains(lt, "cache hit"):
+		return "#hdr-cache-hit" // always present
+	}
+	return ""
+}
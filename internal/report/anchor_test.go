@@ -0,0 +1,35 @@
+package report
+
+import (
+	"testing"
+
+	"github.com/koltyakov/pghealth/internal/analyze"
+	"github.com/koltyakov/pghealth/internal/collect"
+)
+
+// TestLink verifies findings get the anchor of their rendered report section
+// in their evidence, leaving the input analysis untouched.
+func TestLink(t *testing.T) {
+	res := collect.Result{FunctionStats: []collect.FunctionStat{{Name: "f"}}}
+	kept := &analyze.Evidence{Metrics: map[string]float64{"calls": 1}}
+	a := analyze.Analysis{
+		Warnings: []analyze.Finding{{Title: "Long-running queries", Code: "long-running"}},
+		Recommendations: []analyze.Finding{
+			{Title: "Hot function", Code: "hot-function", Evidence: kept},
+			{Title: "Lock waits", Code: "lock-waits"}, // no wait events collected
+		},
+	}
+	got := Link(res, a)
+	if e := got.Warnings[0].Evidence; e == nil || e.Anchor != "#hdr-long-running" {
+		t.Errorf("long-running evidence = %+v", e)
+	}
+	if e := got.Recommendations[0].Evidence; e == nil || e.Anchor != "#hdr-functions" || e.Metrics["calls"] != 1 {
+		t.Errorf("hot-function evidence = %+v", e)
+	}
+	if e := got.Recommendations[1].Evidence; e != nil {
+		t.Errorf("lock-waits evidence = %+v, want none without a waits section", e)
+	}
+	if kept.Anchor != "" || a.Warnings[0].Evidence != nil {
+		t.Error("Link modified its input")
+	}
+}
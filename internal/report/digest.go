@@ -0,0 +1,244 @@
+package report
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/koltyakov/pghealth/internal/analyze"
+	"github.com/koltyakov/pghealth/internal/archive"
+	"github.com/koltyakov/pghealth/internal/collect"
+)
+
+const (
+	// digestMinGrowth is the smallest table growth worth a digest line.
+	digestMinGrowth = 100 << 20
+
+	// digestMaxItems caps the tables and queries listed per digest line group.
+	digestMaxItems = 5
+
+	// digestQueryLen caps the query text quoted in the digest.
+	digestQueryLen = 80
+)
+
+// WriteDigest writes a short Markdown summary of what changed since prev, the
+// previous archived run of the target: new and resolved findings, grown
+// tables and regressed top queries. It suits chat and email notifications
+// where the full report is noise. A nil prev marks the first run. Path "-"
+// writes to stdout.
+func WriteDigest(path string, res collect.Result, a analyze.Analysis, meta collect.Meta, prev *archive.Baseline) error {
+	if path == "" {
+		return fmt.Errorf("output path cannot be empty")
+	}
+	md := digestMarkdown(res, a, meta, prev)
+	if path == "-" {
+		_, err := io.WriteString(os.Stdout, md)
+		return err
+	}
+	if err := os.WriteFile(path, []byte(md), summaryFilePerms); err != nil {
+		return fmt.Errorf("write digest: %w", err)
+	}
+	return nil
+}
+
+// digestMarkdown renders the digest of a run.
+func digestMarkdown(res collect.Result, a analyze.Analysis, meta collect.Meta, prev *archive.Baseline) string {
+	var b strings.Builder
+	target := meta.Target
+	if target == "" {
+		target = res.ConnInfo.CurrentDB
+	}
+	fmt.Fprintf(&b, "*pghealth digest: %s*", target)
+	for _, l := range meta.LabelPairs() {
+		fmt.Fprintf(&b, " · `%s`", l)
+	}
+	b.WriteString("\n")
+
+	if prev == nil {
+		fmt.Fprintf(&b, "First archived run: %s, %s.\n", plural(len(a.Warnings), "warning"), plural(len(a.Recommendations), "recommendation"))
+		return b.String()
+	}
+	lines := digestChanges(res, a, prev)
+	if len(lines) == 0 {
+		fmt.Fprintf(&b, "No changes since %s.\n", prev.StartedAt.UTC().Format("2006-01-02 15:04 UTC"))
+		return b.String()
+	}
+	fmt.Fprintf(&b, "Changes since %s:\n", prev.StartedAt.UTC().Format("2006-01-02 15:04 UTC"))
+	for _, l := range lines {
+		fmt.Fprintf(&b, "- %s\n", l)
+	}
+	return b.String()
+}
+
+// digestChanges lists the changes between prev and the current run, most
+// important first.
+func digestChanges(res collect.Result, a analyze.Analysis, prev *archive.Baseline) []string {
+	var out []string
+
+	before := map[string]bool{}
+	// Objects of the previous findings, for archives with evidence
+	objects := map[string]map[string]bool{}
+	for _, f := range prev.Findings {
+		id := findingIdentity(f)
+		before[id] = true
+		if f.Evidence == nil {
+			continue
+		}
+		if objects[id] == nil {
+			objects[id] = map[string]bool{}
+		}
+		for _, o := range f.Evidence.Objects {
+			objects[id][o.ID()] = true
+		}
+	}
+	current := map[string]bool{}
+	var spread []string
+	for _, group := range []struct {
+		noun string
+		list []analyze.Finding
+	}{{"warning", a.Warnings}, {"recommendation", a.Recommendations}} {
+		var titles []string
+		for _, f := range group.list {
+			id := findingIdentity(f)
+			current[id] = true
+			if !before[id] {
+				titles = append(titles, f.Title)
+			} else if added := newObjects(f, objects[id]); len(added) > 0 {
+				spread = append(spread, fmt.Sprintf("%s now also affects %s", f.Title, listTitles(added)))
+			}
+		}
+		if len(titles) > 0 {
+			out = append(out, fmt.Sprintf("%s: %s", plural(len(titles), "new "+group.noun), listTitles(titles)))
+		}
+	}
+	out = append(out, spread...)
+	for _, f := range a.Infos {
+		current[findingIdentity(f)] = true
+	}
+	var resolved []string
+	for _, f := range prev.Findings {
+		if f.Severity != analyze.SeverityInfo && !current[findingIdentity(f)] {
+			resolved = append(resolved, f.Title)
+		}
+	}
+	if len(resolved) > 0 {
+		out = append(out, fmt.Sprintf("%s resolved: %s", plural(len(resolved), "finding"), listTitles(resolved)))
+	}
+
+	out = append(out, tableGrowth(res.Tables, prev.Tables, res.ObjectNamer())...)
+	out = append(out, queryRegressions(res.Statements.TopByTotalTime, prev.Statements)...)
+	return out
+}
+
+// findingIdentity matches a finding across runs: by code, or by title for
+// findings without one.
+func findingIdentity(f analyze.Finding) string {
+	if f.Code != "" {
+		return f.Severity + "/" + f.Code
+	}
+	return f.Severity + "/" + f.Title
+}
+
+// newObjects lists the objects in the evidence of f that are not in was, the
+// objects of the same finding in the previous run; nil when the previous run
+// recorded no evidence for it. Backends are skipped: process ids do not carry
+// over between runs.
+func newObjects(f analyze.Finding, was map[string]bool) []string {
+	if was == nil || f.Evidence == nil {
+		return nil
+	}
+	var out []string
+	for _, o := range f.Evidence.Objects {
+		if id := o.ID(); o.Kind != "backend" && !was[id] {
+			out = append(out, id)
+		}
+	}
+	return out
+}
+
+// tableGrowth describes tables that grew by at least digestMinGrowth, largest
+// growth first, naming them with name.
+func tableGrowth(cur, prev []collect.TableStat, name func(db, schema, table string) string) []string {
+	type growth struct {
+		t     collect.TableStat
+		delta int64
+		was   int64
+	}
+	was := make(map[string]int64, len(prev))
+	for _, t := range prev {
+		was[t.ID()] = t.SizeBytes
+	}
+	var grown []growth
+	for _, t := range cur {
+		old, ok := was[t.ID()]
+		if ok && t.SizeBytes-old >= digestMinGrowth {
+			grown = append(grown, growth{t, t.SizeBytes - old, old})
+		}
+	}
+	sort.Slice(grown, func(i, j int) bool { return grown[i].delta > grown[j].delta })
+
+	var out []string
+	for i, g := range grown {
+		if i == digestMaxItems {
+			out = append(out, fmt.Sprintf("%d more table(s) grew by over %s", len(grown)-i, fmtBytesStr(digestMinGrowth)))
+			break
+		}
+		out = append(out, fmt.Sprintf("%s grew %s (%s → %s)", name(g.t.Database, g.t.Schema, g.t.Name), fmtBytesStr(g.delta), fmtBytesStr(g.was), fmtBytesStr(g.t.SizeBytes)))
+	}
+	return out
+}
+
+// queryRegressions describes top queries whose mean time grew by at least
+// regressionFactor since the previous run, worst first.
+func queryRegressions(cur, prev []collect.Statement) []string {
+	type regression struct {
+		s      collect.Statement
+		was    float64
+		factor float64
+	}
+	was := make(map[string]float64, len(prev))
+	for _, s := range prev {
+		was[s.Key()] = s.MeanTime
+	}
+	var slow []regression
+	for _, s := range cur {
+		old := was[s.Key()]
+		if old > 0 && s.MeanTime >= old*regressionFactor {
+			slow = append(slow, regression{s, old, s.MeanTime / old})
+		}
+	}
+	sort.Slice(slow, func(i, j int) bool { return slow[i].factor > slow[j].factor })
+
+	var out []string
+	for i, r := range slow {
+		if i == digestMaxItems {
+			out = append(out, fmt.Sprintf("%d more top statement(s) regressed", len(slow)-i))
+			break
+		}
+		name := "query"
+		if r.s.QueryID != 0 {
+			name = fmt.Sprintf("query %d", r.s.QueryID)
+		}
+		text := truncateRunes(strings.Join(strings.Fields(r.s.Query), " "), digestQueryLen)
+		out = append(out, fmt.Sprintf("%s regressed %.1f× (%s → %s mean): `%s`", name, r.factor, fmtMs(r.was), fmtMs(r.s.MeanTime), text))
+	}
+	return out
+}
+
+// listTitles joins finding titles, shortening long lists.
+func listTitles(titles []string) string {
+	if len(titles) > digestMaxItems {
+		return strings.Join(titles[:digestMaxItems], "; ") + fmt.Sprintf("; and %d more", len(titles)-digestMaxItems)
+	}
+	return strings.Join(titles, "; ")
+}
+
+// plural formats a count with its noun, adding an s when not one.
+func plural(n int, noun string) string {
+	if n == 1 {
+		return "1 " + noun
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
@@ -0,0 +1,94 @@
+package report
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/koltyakov/pghealth/internal/analyze"
+	"github.com/koltyakov/pghealth/internal/archive"
+	"github.com/koltyakov/pghealth/internal/collect"
+)
+
+// TestDigest verifies that the digest lists new and resolved findings, grown
+// tables and regressed queries, and stays short when nothing changed.
+func TestDigest(t *testing.T) {
+	prev := &archive.Baseline{
+		StartedAt: time.Date(2024, 1, 15, 6, 0, 0, 0, time.UTC),
+		Findings: []analyze.Finding{
+			{Title: "Old warning", Severity: analyze.SeverityWarning, Code: "old"},
+			{Title: "12 tables bloated", Severity: analyze.SeverityWarning, Code: "bloat"},
+			{Title: "Info", Severity: analyze.SeverityInfo, Code: "info"},
+		},
+		Tables: []collect.TableStat{
+			{Database: "app", Schema: "public", Name: "orders", SizeBytes: 30 << 30},
+			{Database: "app", Schema: "public", Name: "users", SizeBytes: 1 << 30},
+		},
+		Statements: []collect.Statement{{QueryID: 7, Query: "SELECT * FROM orders", MeanTime: 10}, {Query: "SELECT 1", MeanTime: 1}},
+	}
+	var res collect.Result
+	res.Tables = []collect.TableStat{
+		{Database: "app", Schema: "public", Name: "orders", SizeBytes: 42 << 30},
+		{Database: "app", Schema: "public", Name: "users", SizeBytes: 1<<30 + 1<<20},
+		{Database: "app", Schema: "public", Name: "new_table", SizeBytes: 5 << 30},
+	}
+	res.Statements.TopByTotalTime = []collect.Statement{{QueryID: 7, Query: "SELECT *\n  FROM orders", MeanTime: 30}, {Query: "SELECT 1", MeanTime: 1.2}}
+	a := analyze.Analysis{Warnings: []analyze.Finding{
+		{Title: "13 tables bloated", Severity: analyze.SeverityWarning, Code: "bloat"},
+		{Title: "Replication lag", Severity: analyze.SeverityWarning, Code: "lag"},
+		{Title: "XID age", Severity: analyze.SeverityWarning, Code: "xid"},
+	}}
+	meta := collect.Meta{Target: "db1:5432/app", Labels: map[string]string{"env": "prod"}}
+
+	got := digestMarkdown(res, a, meta, prev)
+	for _, want := range []string{
+		"*pghealth digest: db1:5432/app* · `env=prod`",
+		"Changes since 2024-01-15 06:00 UTC:",
+		"- 2 new warnings: Replication lag; XID age",
+		"- 1 finding resolved: Old warning",
+		"- public.orders grew 12.00 GB (30.00 GB → 42.00 GB)",
+		"- query 7 regressed 3.0× (",
+		"`SELECT * FROM orders`",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("digest misses %q:\n%s", want, got)
+		}
+	}
+	for _, unwanted := range []string{"users", "new_table", "bloated", "SELECT 1", "Info"} {
+		if strings.Contains(got, unwanted) {
+			t.Errorf("digest should not mention %q:\n%s", unwanted, got)
+		}
+	}
+
+	if got := digestMarkdown(collect.Result{}, analyze.Analysis{}, meta, &archive.Baseline{StartedAt: prev.StartedAt}); !strings.Contains(got, "No changes since 2024-01-15 06:00 UTC.") {
+		t.Errorf("unchanged digest:\n%s", got)
+	}
+	if got := digestMarkdown(res, a, meta, nil); !strings.Contains(got, "First archived run: 3 warnings, 0 recommendations.") {
+		t.Errorf("first run digest:\n%s", got)
+	}
+}
+
+// TestDigestNewObjects verifies that a finding reported again names the
+// objects it newly affects when the previous run archived its evidence.
+func TestDigestNewObjects(t *testing.T) {
+	index := func(names ...string) *analyze.Evidence {
+		e := &analyze.Evidence{}
+		for _, n := range names {
+			e.Objects = append(e.Objects, analyze.Object{Kind: "index", Schema: "public", Name: n})
+		}
+		e.Objects = append(e.Objects, analyze.Object{Kind: "backend", Name: names[0]})
+		return e
+	}
+	prev := &archive.Baseline{Findings: []analyze.Finding{
+		{Title: "Unused indexes", Severity: analyze.SeverityRec, Code: "unused-indexes", Evidence: index("a_idx")},
+		{Title: "Duplicate indexes", Severity: analyze.SeverityRec, Code: "duplicate-indexes"},
+	}}
+	a := analyze.Analysis{Recommendations: []analyze.Finding{
+		{Title: "Unused indexes", Severity: analyze.SeverityRec, Code: "unused-indexes", Evidence: index("a_idx", "b_idx")},
+		{Title: "Duplicate indexes", Severity: analyze.SeverityRec, Code: "duplicate-indexes", Evidence: index("c_idx")},
+	}}
+	got := digestChanges(collect.Result{}, a, prev)
+	if len(got) != 1 || got[0] != "Unused indexes now also affects public.b_idx" {
+		t.Errorf("changes = %q", got)
+	}
+}
@@ -0,0 +1,65 @@
+package report
+
+import (
+	"math"
+	"sort"
+
+	"github.com/koltyakov/pghealth/internal/collect"
+)
+
+const (
+	// growthLeaders caps each growth leaderboard.
+	growthLeaders = 20
+
+	// growthPctMinBytes is the earliest size a relation needs to be ranked by
+	// percentage growth, so small tables doubling do not crowd out the list.
+	growthPctMinBytes = 10 << 20
+)
+
+// growthRow is a table or index of a growth leaderboard.
+type growthRow struct {
+	Kind        string // "table" or "index"
+	Name        string
+	Bytes       int64
+	Grown       int64
+	GrownPct    float64
+	BytesPerDay int64 // fitted trend
+	Days        float64
+}
+
+// growthLeaderboards ranks the tables and indexes that grew over the archived
+// runs by absolute and by percentage growth, at most growthLeaders each.
+func growthLeaderboards(res collect.Result) (byBytes, byPct []growthRow) {
+	name := res.ObjectNamer()
+	var rows []growthRow
+	for _, g := range res.SizeGrowth {
+		if g.IsDatabase() || g.Grown() <= 0 {
+			continue
+		}
+		kind := "table"
+		if g.Index {
+			kind = "index"
+		}
+		rows = append(rows, growthRow{Kind: kind, Name: name(g.Database, g.Schema, g.Name), Bytes: g.Bytes,
+			Grown: g.Grown(), GrownPct: g.GrownPct(), BytesPerDay: int64(math.Round(g.BytesPerDay)), Days: g.Days})
+	}
+	byBytes = append([]growthRow(nil), rows...)
+	sort.Slice(byBytes, func(i, j int) bool {
+		if byBytes[i].Grown != byBytes[j].Grown {
+			return byBytes[i].Grown > byBytes[j].Grown
+		}
+		return byBytes[i].Name < byBytes[j].Name
+	})
+	for _, r := range rows {
+		if r.Bytes-r.Grown >= growthPctMinBytes {
+			byPct = append(byPct, r)
+		}
+	}
+	sort.Slice(byPct, func(i, j int) bool {
+		if byPct[i].GrownPct != byPct[j].GrownPct {
+			return byPct[i].GrownPct > byPct[j].GrownPct
+		}
+		return byPct[i].Name < byPct[j].Name
+	})
+	return byBytes[:min(len(byBytes), growthLeaders)], byPct[:min(len(byPct), growthLeaders)]
+}
@@ -0,0 +1,53 @@
+package report
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/koltyakov/pghealth/internal/analyze"
+	"github.com/koltyakov/pghealth/internal/collect"
+)
+
+// TestGrowthLeaderboards verifies relations are ranked by absolute and by
+// percentage growth, leaving out databases, shrinking relations and, from
+// the percentage ranking, relations that started small.
+func TestGrowthLeaderboards(t *testing.T) {
+	res := collect.Result{SizeGrowth: []collect.SizeGrowth{
+		{Database: "app", Bytes: 100 << 30, StartBytes: 50 << 30},
+		{Database: "app", Schema: "public", Name: "events", Bytes: 30 << 30, StartBytes: 20 << 30, BytesPerDay: 1 << 30, Days: 10},
+		{Database: "app", Schema: "public", Name: "events_pkey", Index: true, Bytes: 2 << 30, StartBytes: 1 << 30},
+		{Database: "app", Schema: "public", Name: "tiny", Bytes: 8 << 20, StartBytes: 1 << 20},
+		{Database: "app", Schema: "public", Name: "archive", Bytes: 1 << 30, StartBytes: 2 << 30},
+	}}
+	byBytes, byPct := growthLeaderboards(res)
+	list := func(rows []growthRow) string {
+		var out []string
+		for _, r := range rows {
+			out = append(out, fmt.Sprintf("%s %s %.0f%%", r.Kind, r.Name, r.GrownPct))
+		}
+		return strings.Join(out, ", ")
+	}
+	if got := list(byBytes); got != "table public.events 50%, index public.events_pkey 100%, table public.tiny 700%" {
+		t.Errorf("by bytes = %s", got)
+	}
+	if got := list(byPct); got != "index public.events_pkey 100%, table public.events 50%" {
+		t.Errorf("by pct = %s", got)
+	}
+
+	out := filepath.Join(t.TempDir(), "report.html")
+	if err := WriteHTML(out, res, analyze.Analysis{}, collect.Meta{}); err != nil {
+		t.Fatalf("WriteHTML failed: %v", err)
+	}
+	b, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`id="hdr-growth"`, "<td>public.events</td>", "<td>+10.00 GB</td>", "<td>1.00 GB/day</td>"} {
+		if !strings.Contains(string(b), want) {
+			t.Errorf("report missing %q", want)
+		}
+	}
+}
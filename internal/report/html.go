@@ -16,6 +16,7 @@ import (
 	"time"
 
 	"github.com/koltyakov/pghealth/internal/analyze"
+	"github.com/koltyakov/pghealth/internal/archive"
 	"github.com/koltyakov/pghealth/internal/collect"
 )
 
@@ -47,11 +48,16 @@ const (
 //   - meta is for display only and may be partially populated
 //
 // Returns an error if the file cannot be created or the template fails to execute.
-func WriteHTML(path string, res collect.Result, a analyze.Analysis, meta collect.Meta) error {
+func WriteHTML(path string, res collect.Result, a analyze.Analysis, meta collect.Meta, opts ...Option) error {
 	if path == "" {
 		return fmt.Errorf("output path cannot be empty")
 	}
 
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
 	// Defensive: ensure slice fields are non-nil to prevent template panics
 	if res.DBs == nil {
 		res.DBs = []collect.Database{}
@@ -92,7 +98,12 @@ func WriteHTML(path string, res collect.Result, a analyze.Analysis, meta collect
 	}()
 
 	// Sort numerical metrics descending so greater numbers show on top
-	sort.Slice(res.DBs, func(i, j int) bool { return res.DBs[i].SizeBytes > res.DBs[j].SizeBytes })
+	sort.Slice(res.DBs, func(i, j int) bool {
+		if res.DBs[i].SizeBytes != res.DBs[j].SizeBytes {
+			return res.DBs[i].SizeBytes > res.DBs[j].SizeBytes
+		}
+		return res.DBs[i].Name < res.DBs[j].Name
+	})
 	sort.Slice(res.Activity, func(i, j int) bool {
 		if res.Activity[i].Count == res.Activity[j].Count {
 			if res.Activity[i].Datname == res.Activity[j].Datname {
@@ -102,8 +113,34 @@ func WriteHTML(path string, res collect.Result, a analyze.Analysis, meta collect
 		}
 		return res.Activity[i].Count > res.Activity[j].Count
 	})
-	sort.Slice(res.IndexUnused, func(i, j int) bool { return res.IndexUnused[i].SizeBytes > res.IndexUnused[j].SizeBytes })
-	sort.Slice(res.Indexes, func(i, j int) bool { return res.Indexes[i].SizeBytes > res.Indexes[j].SizeBytes })
+	sort.Slice(res.IndexUnused, func(i, j int) bool { return unusedBefore(res.IndexUnused[i], res.IndexUnused[j]) })
+	sort.Slice(res.Indexes, func(i, j int) bool {
+		a, b := res.Indexes[i], res.Indexes[j]
+		if a.SizeBytes != b.SizeBytes {
+			return a.SizeBytes > b.SizeBytes
+		}
+		if a.Database != b.Database {
+			return a.Database < b.Database
+		}
+		if a.Schema != b.Schema {
+			return a.Schema < b.Schema
+		}
+		return a.Name < b.Name
+	})
+	// Low-selectivity indexes of all databases: most wasted reads first
+	sort.Slice(res.LowSelectivityIndexes, func(i, j int) bool {
+		a, b := res.LowSelectivityIndexes[i], res.LowSelectivityIndexes[j]
+		if wa, wb := a.TupRead-a.TupFetch, b.TupRead-b.TupFetch; wa != wb {
+			return wa > wb
+		}
+		if a.Database != b.Database {
+			return a.Database < b.Database
+		}
+		if a.Schema != b.Schema {
+			return a.Schema < b.Schema
+		}
+		return a.Name < b.Name
+	})
 	// Sort "Tables with index counts" by estimated bloat bytes (Size * Bloat%) desc, then by overall size desc
 	sort.Slice(res.TablesWithIndexCount, func(i, j int) bool {
 		a, b := res.TablesWithIndexCount[i], res.TablesWithIndexCount[j]
@@ -129,13 +166,13 @@ func WriteHTML(path string, res collect.Result, a analyze.Analysis, meta collect
 		}
 		return a.Name < b.Name
 	})
-	// Prepare sorted copies for top tables by rows and by size
-	tablesBySize := make([]collect.TableStat, len(res.Tables))
-	copy(tablesBySize, res.Tables)
-	sort.Slice(tablesBySize, func(i, j int) bool { return tablesBySize[i].SizeBytes > tablesBySize[j].SizeBytes })
-	tablesByRows := make([]collect.TableStat, len(res.Tables))
-	copy(tablesByRows, res.Tables)
-	sort.Slice(tablesByRows, func(i, j int) bool { return tablesByRows[i].NLiveTup > tablesByRows[j].NLiveTup })
+	// Top tables by rows and by size are selected during collection; results
+	// assembled elsewhere (older snapshots, tests) are ranked here
+	if res.TopTablesBySize == nil && res.TopTablesByRows == nil {
+		collect.RankTables(&res)
+	}
+	tablesBySize := res.TopTablesBySize
+	tablesByRows := res.TopTablesByRows
 
 	// Aggregate estimated reclaimable space (via VACUUM) per database using table bloat heuristic
 	reclaimByDB := map[string]int64{}
@@ -162,7 +199,12 @@ func WriteHTML(path string, res collect.Result, a analyze.Analysis, meta collect
 			Bytes    int64
 		}{Database: k, Bytes: v})
 	}
-	sort.Slice(reclaimList, func(i, j int) bool { return reclaimList[i].Bytes > reclaimList[j].Bytes })
+	sort.Slice(reclaimList, func(i, j int) bool {
+		if reclaimList[i].Bytes != reclaimList[j].Bytes {
+			return reclaimList[i].Bytes > reclaimList[j].Bytes
+		}
+		return reclaimList[i].Database < reclaimList[j].Database
+	})
 
 	// Build a combined set of unused indexes from both sources (candidates + bloat view), deduped
 	combined := make(map[string]collect.IndexUnused)
@@ -182,7 +224,7 @@ func WriteHTML(path string, res collect.Result, a analyze.Analysis, meta collect
 			db := strings.TrimSpace(res.ConnInfo.CurrentDB)
 			key := db + "|" + ib.Schema + "." + ib.Name
 			if prev, ok := combined[key]; !ok || ib.WastedBytes > prev.SizeBytes {
-				combined[key] = collect.IndexUnused{Database: res.ConnInfo.CurrentDB, Schema: ib.Schema, Table: ib.Table, Name: ib.Name, SizeBytes: ib.WastedBytes}
+				combined[key] = collect.IndexUnused{Database: res.ConnInfo.CurrentDB, Schema: ib.Schema, Table: ib.Table, Name: ib.Name, SizeBytes: ib.WastedBytes, Constraint: res.IndexConstraint(ib.Schema, ib.Name)}
 			}
 		}
 	}
@@ -192,46 +234,20 @@ func WriteHTML(path string, res collect.Result, a analyze.Analysis, meta collect
 		for _, v := range combined {
 			merged = append(merged, v)
 		}
-		sort.Slice(merged, func(i, j int) bool { return merged[i].SizeBytes > merged[j].SizeBytes })
+		sort.Slice(merged, func(i, j int) bool { return unusedBefore(merged[i], merged[j]) })
 		res.IndexUnused = merged
 	}
 
-	// Whether to show Database column in various sections
-	showDBTablesByRows := false
-	for _, t := range tablesByRows {
-		if strings.TrimSpace(t.Database) != "" {
-			showDBTablesByRows = true
-			break
-		}
-	}
-	showDBTablesBySize := false
-	for _, t := range tablesBySize {
-		if strings.TrimSpace(t.Database) != "" {
-			showDBTablesBySize = true
-			break
-		}
-	}
-	showDBIndexUnused := false
-	for _, iu := range res.IndexUnused {
-		if strings.TrimSpace(iu.Database) != "" {
-			showDBIndexUnused = true
-			break
-		}
-	}
-	showDBIndexUsageLow := false
-	for _, iu := range res.IndexUsageLow {
-		if strings.TrimSpace(iu.Database) != "" {
-			showDBIndexUsageLow = true
-			break
-		}
-	}
-	showDBIndexCounts := false
-	for _, ic := range res.TablesWithIndexCount {
-		if strings.TrimSpace(ic.Database) != "" {
-			showDBIndexCounts = true
-			break
-		}
-	}
+	// Show the Database column, and the toggle grouping rows by it, only when
+	// a section mixes rows of several databases (-dbs); otherwise identical
+	// schema.name rows cannot be told apart
+	current := res.ConnInfo.CurrentDB
+	showDBTablesByRows := spansDatabases(tablesByRows, current, func(t collect.TableStat) string { return t.Database })
+	showDBTablesBySize := spansDatabases(tablesBySize, current, func(t collect.TableStat) string { return t.Database })
+	showDBIndexUnused := spansDatabases(res.IndexUnused, current, func(i collect.IndexUnused) string { return i.Database })
+	showDBIndexUsageLow := spansDatabases(res.IndexUsageLow, current, func(i collect.IndexUsage) string { return i.Database })
+	showDBIndexCounts := spansDatabases(res.TablesWithIndexCount, current, func(t collect.TableIndexCount) string { return t.Database })
+	showDBSchemas := spansDatabases(res.Catalog.Schemas, current, func(s collect.SchemaTotals) string { return s.Database })
 
 	// Top queries are not shown with DB scope
 
@@ -298,20 +314,31 @@ func WriteHTML(path string, res collect.Result, a analyze.Analysis, meta collect
 		if total == 0 {
 			return "Healthy: no unused indexes detected."
 		}
-		// count large ones (>100MB)
-		large := 0
+		// count large ones (>100MB) and those backing constraints
+		large, constrained := 0, 0
 		for _, iu := range res.IndexUnused {
+			if iu.Constraint != "" {
+				constrained++
+				continue
+			}
 			if iu.SizeBytes > 100*1024*1024 {
 				large++
 			}
 		}
+		note := ""
+		if constrained > 0 {
+			note = fmt.Sprintf(" %d back a constraint or replica identity and must be kept.", constrained)
+		}
+		if total == constrained {
+			return fmt.Sprintf("No droppable unused indexes.%s", note)
+		}
 		if large > 0 {
-			return fmt.Sprintf("%d unused indexes (%d > 100MB). Validate with workload owners before dropping.", total, large)
+			return fmt.Sprintf("%d unused indexes (%d > 100MB). Validate with workload owners before dropping.%s", total, large, note)
 		}
 		if total == 1 {
 			return "1 unused index detected; validate and consider dropping."
 		}
-		return fmt.Sprintf("%d unused indexes detected; validate with workload owners before dropping.", total)
+		return fmt.Sprintf("%d unused indexes detected; validate with workload owners before dropping.%s", total, note)
 	}()
 	indexUsageSummary := func() string {
 		if len(res.IndexUsageLow) == 0 {
@@ -418,12 +445,36 @@ func WriteHTML(path string, res collect.Result, a analyze.Analysis, meta collect
 		if len(res.AutoVacuum) == 0 {
 			return "Healthy: no autovacuum workers active now."
 		}
-		return fmt.Sprintf("Autovacuum workers: %d active. Ensure cost settings aren’t throttling large tables.", len(res.AutoVacuum))
+		return fmt.Sprintf("Autovacuum workers: %d active. Ensure cost settings aren’t throttling large tables. The ETA extrapolates the heap scan rate measured during the run; index and heap vacuuming phases come after it. A vacuum whose dead tuple memory fills up before the heap scan ends vacuums every index once per fill (multi-pass).", len(res.AutoVacuum))
 	}()
 
 	// Brief explanation for Bloat in "Tables with index counts"
 	bloatPctNote := "Bloat is estimated from dead tuple share: Bloat % ≈ n_dead_tup / (n_live_tup + n_dead_tup). 'Bloat (est.)' shows wasted bytes = table size × Bloat %. Rows over ~20% are highlighted. Use VACUUM to reclaim space; for severe bloat (>50%), consider VACUUM FULL or pg_repack and tune autovacuum (scale_factor, naptime, cost limits)."
 
+	// One-line attribution of the heaviest role, e.g. "role x accounts for 62% ..."
+	queryLoadSummary := ""
+	if len(res.Statements.ByRole) > 0 && res.Statements.ByRole[0].Share > 0 {
+		top := res.Statements.ByRole[0]
+		queryLoadSummary = fmt.Sprintf("Role %s accounts for %.0f%% of total execution time.", top.Name, top.Share*100)
+		if len(res.Statements.ByDatabase) > 1 {
+			db := res.Statements.ByDatabase[0]
+			queryLoadSummary += fmt.Sprintf(" Database %s accounts for %.0f%%.", db.Name, db.Share*100)
+		}
+	}
+
+	// Tagged vs untagged share of execution time for the application breakdown
+	appLoadSummary := ""
+	if len(res.Statements.ByApp) > 0 {
+		tagged := 0.0
+		for _, l := range res.Statements.ByApp {
+			tagged += l.Share
+		}
+		appLoadSummary = fmt.Sprintf("Tagged statements cover %.0f%% of total execution time; the rest carries no application tag.", math.Min(tagged, 1)*100)
+	}
+
+	// Per-query drill-down sections linked from the top query tables
+	queryDetails, queryAnchors := buildQueryDetails(res, o.queryHistory, archive.RunID(meta.StartedAt, meta.Target))
+
 	funcMap := template.FuncMap{
 		"since":    func(t time.Time) string { return time.Since(t).String() },
 		"add":      func(a, b int64) int64 { return a + b },
@@ -434,19 +485,11 @@ func WriteHTML(path string, res collect.Result, a analyze.Analysis, meta collect
 			}
 			return t.Local().Format("2006-01-02 15:04:05 MST")
 		},
-		"fmtDur": func(d time.Duration) string { return humanizeDuration(d) },
+		"fmtDur":  func(d time.Duration) string { return humanizeDuration(d) },
+		"fmtSecs": func(s float64) string { return humanizeDuration(seconds(s)) },
 		// fmtMs converts milliseconds (float64) into a compact human duration.
 		// For < 1000ms, render with two decimals (e.g., 12.34ms). For >= 1s, use humanized units.
-		"fmtMs": func(ms float64) string {
-			if ms <= 0 {
-				return "0ms"
-			}
-			if ms < 1000 {
-				return fmt.Sprintf("%.2fms", ms)
-			}
-			d := time.Duration(ms * float64(time.Millisecond))
-			return humanizeDuration(d)
-		},
+		"fmtMs": fmtMs,
 		"fmtUptime": func(t time.Time) string {
 			if t.IsZero() {
 				return "n/a"
@@ -463,143 +506,13 @@ func WriteHTML(path string, res collect.Result, a analyze.Analysis, meta collect
 			}
 			return template.HTMLEscapeString((func() string { return fmtFloatPrecSep(f, 2) + " " + units[i] })())
 		},
-		"fmtInt": func(n int) string { return addThousands(strconv.FormatInt(int64(n), 10)) },
-		"fmtI64": func(n int64) string { return addThousands(strconv.FormatInt(n, 10)) },
-		"fmtF0":  func(f float64) string { return fmtFloatPrecSep(f, 0) },
-		"fmtF1":  func(f float64) string { return fmtFloatPrecSep(f, 1) },
-		// Map analyzer finding to a section anchor if available. If the section
-		// isn’t rendered (no details), return empty so the card isn’t a link.
-		"findingAnchor": func(code, title string) string {
-			// Helpers for availability
-			hasWaits := len(res.WaitEvents) > 0
-			hasWal := res.WAL != nil
-			hasTemp := len(res.TempFileStats) > 0
-			hasExtList := len(res.ExtensionStats) > 0
-			hasFuncs := len(res.FunctionStats) > 0
-			hasCI := len(res.ProgressCreateIndex) > 0
-			hasPSSLists := res.Extensions.PgStatStatements && res.Statements.SkippedReason == ""
-			hasUnusedIdx := len(res.IndexUnused) > 0
-			hasRepl := len(res.ReplicationStats) > 0
-
-			switch code {
-			case "io-waits", "lock-waits", "bufferpin-waits":
-				if hasWaits {
-					return "#hdr-waits"
-				}
-				return ""
-			case "high-wal", "wal-fpi", "wal-fpi-high":
-				if hasWal {
-					return "#hdr-wal"
-				}
-				return ""
-			case "unused-indexes":
-				if hasUnusedIdx {
-					return "#hdr-index-unused"
-				}
-				return ""
-			case "too-many-indexes", "table-bloat-heuristic":
-				return "#hdr-index-counts"
-			case "missing-indexes":
-				return "#hdr-index-usage-low"
-			case "slow-index-improve", "slow-refactor", "slow-sorts", "slow-joins", "slow-seq-scans":
-				if hasPSSLists {
-					return "#hdr-queries-total-time"
-				}
-				return ""
-			case "long-running":
-				return "#hdr-long-running"
-			case "ci-wait-lockers":
-				if hasCI {
-					return "#hdr-progress-ci"
-				}
-				return ""
-			case "hot-function", "hot-functions-multi":
-				if hasFuncs {
-					return "#hdr-functions"
-				}
-				return ""
-			case "install-pgss":
-				return "#hdr-settings"
-			case "missing-extensions":
-				if hasExtList {
-					return "#hdr-extensions"
-				}
-				return ""
-			case "enable-track-io", "wal-level-minimal", "checkpoint-timeout-low", "ecs-low-vs-sb", "high-max-connections", "autovacuum-naptime-high", "maintenance-work-mem-low", "random-page-cost-default", "no-statement-timeout", "no-idle-tx-timeout", "ssl-off", "shared-buffers-low", "max-wal-size-low", "wal-buffers-low", "parallel-workers-low", "work-mem-low":
-				return "#hdr-settings"
-			case "cache-overall":
-				return "#hdr-cache-hit"
-			// New health check anchors
-			case "xid-wraparound-critical", "xid-age-warning":
-				if len(res.XIDAge) > 0 {
-					return "#hdr-xid-age"
-				}
-				return ""
-			case "idle-in-transaction":
-				if len(res.IdleInTransaction) > 0 {
-					return "#hdr-idle-in-transaction"
-				}
-				return ""
-			case "stale-statistics":
-				if len(res.StaleStatsTables) > 0 {
-					return "#hdr-stale-statistics"
-				}
-				return ""
-			case "duplicate-indexes":
-				if len(res.DuplicateIndexes) > 0 {
-					return "#hdr-duplicate-indexes"
-				}
-				return ""
-			case "invalid-indexes":
-				if len(res.InvalidIndexes) > 0 {
-					return "#hdr-invalid-indexes"
-				}
-				return ""
-			case "fk-missing-index":
-				if len(res.FKMissingIndexes) > 0 {
-					return "#hdr-fk-missing-indexes"
-				}
-				return ""
-			case "sequence-exhaustion-critical", "sequence-exhaustion-warning":
-				if len(res.SequenceHealth) > 0 {
-					return "#hdr-sequence-health"
-				}
-				return ""
-			case "prepared-transactions":
-				if len(res.PreparedXacts) > 0 {
-					return "#hdr-prepared-xacts"
-				}
-				return ""
-			}
-			// Fallback by keywords in title when code missing
-			lt := strings.ToLower(title)
-			switch {
-			case strings.Contains(lt, "wait"):
-				if hasWaits {
-					return "#hdr-waits"
-				}
-				return ""
-			case strings.Contains(lt, "block"):
-				return "#hdr-blocking" // always present
-			case strings.Contains(lt, "autovac"):
-				return "#hdr-autovacuum" // always present
-			case strings.Contains(lt, "replication"):
-				if hasRepl {
-					return "#hdr-replication"
-				}
-				return ""
-			case strings.Contains(lt, "temp"):
-				if hasTemp {
-					return "#hdr-temp-files"
-				}
-				return ""
-			case strings.Contains(lt, "cache hit"):
-				return "#hdr-cache-hit" // always present
-			}
-			return ""
-		},
-		"fmtF2":        func(f float64) string { return fmtFloatPrecSep(f, 2) },
-		"fmtThousands": func(n int64) string { return addThousands(strconv.FormatInt(n, 10)) },
+		"fmtInt":        func(n int) string { return addThousands(strconv.FormatInt(int64(n), 10)) },
+		"fmtI64":        func(n int64) string { return addThousands(strconv.FormatInt(n, 10)) },
+		"fmtF0":         func(f float64) string { return fmtFloatPrecSep(f, 0) },
+		"fmtF1":         func(f float64) string { return fmtFloatPrecSep(f, 1) },
+		"findingAnchor": func(code, title string) string { return sectionAnchor(res, code, title) },
+		"fmtF2":         func(f float64) string { return fmtFloatPrecSep(f, 2) },
+		"fmtThousands":  func(n int64) string { return addThousands(strconv.FormatInt(n, 10)) },
 		// bloatBytes estimates wasted bytes from size and percent
 		"bloatBytes": func(size int64, pct float64) int64 {
 			if size <= 0 || pct <= 0 {
@@ -607,6 +520,10 @@ func WriteHTML(path string, res collect.Result, a analyze.Analysis, meta collect
 			}
 			return int64(math.Round(float64(size) * pct / 100.0))
 		},
+		// queryAnchor links a top query row to its drill-down section
+		"queryAnchor": func(s collect.Statement) string { return queryAnchors[s.Key()] },
+		"joinStr":     strings.Join,
+		"fmtPct":      func(f float64) string { return fmt.Sprintf("%.1f%%", f*100) },
 	}
 
 	// Parse embedded report template
@@ -704,6 +621,7 @@ func WriteHTML(path string, res collect.Result, a analyze.Analysis, meta collect
 			}
 		}
 	}
+	growthByBytes, growthByPct := growthLeaderboards(res)
 	data := struct {
 		Res                 collect.Result
 		A                   analyze.Analysis
@@ -716,6 +634,10 @@ func WriteHTML(path string, res collect.Result, a analyze.Analysis, meta collect
 		ShowDBIndexUnused   bool
 		ShowDBIndexUsageLow bool
 		ShowDBIndexCounts   bool
+		ShowDBSchemas       bool
+		Treemap             template.HTML
+		GrowthByBytes       []growthRow
+		GrowthByPct         []growthRow
 		ReclaimByDB         []struct {
 			Database string
 			Bytes    int64
@@ -736,18 +658,71 @@ func WriteHTML(path string, res collect.Result, a analyze.Analysis, meta collect
 		// attention lists
 		AttentionTotalTime []attnItem
 		AttentionCalls     []attnItem
+		QueryLoadSummary   string
+		AppLoadSummary     string
+		// per-query drill-down
+		QueryDetails    []queryDetail
+		HasQueryHistory bool
+		SettingGroups   []settingGroup
+		Assignments     []teamAssignment
+		Priorities      []priorityQuadrant
+		Tablespaces     []tablespaceRow
+		Operations      []operationRow
+		SlotCleanup     string
+		TuningScript    string
+		Skipped         []skipGroup
 	}{Res: res, A: a, Meta: meta, Activity: activity, TablesByRows: tablesByRows, TablesBySize: tablesBySize,
-		ShowDBTablesByRows: showDBTablesByRows, ShowDBTablesBySize: showDBTablesBySize, ShowDBIndexUnused: showDBIndexUnused, ShowDBIndexUsageLow: showDBIndexUsageLow, ShowDBIndexCounts: showDBIndexCounts,
+		ShowDBTablesByRows: showDBTablesByRows, ShowDBTablesBySize: showDBTablesBySize, ShowDBIndexUnused: showDBIndexUnused, ShowDBIndexUsageLow: showDBIndexUsageLow, ShowDBIndexCounts: showDBIndexCounts, ShowDBSchemas: showDBSchemas, Treemap: treemapSVG(res.SizeTree),
+		GrowthByBytes: growthByBytes, GrowthByPct: growthByPct,
 		ReclaimByDB: reclaimList, ReclaimTotal: reclaimTotal,
 		ConnSummary: connSummary, DBsSummary: dbsSummary, CacheHitsSummary: cacheHitsSummary, IndexUnusedSummary: indexUnusedSummary,
 		IndexUsageSummary: indexUsageSummary, ClientsSummary: clientsSummary, BlockingSummary: blockingSummary, LongRunningSummary: longRunningSummary, AutovacSummary: autovacSummary, WaitsSummary: waitsSummary,
 		BloatPctNote:       bloatPctNote,
 		AttentionTotalTime: attentionTotalTime,
 		AttentionCalls:     attentionCalls,
+		QueryLoadSummary:   queryLoadSummary,
+		AppLoadSummary:     appLoadSummary,
+		QueryDetails:       queryDetails,
+		HasQueryHistory:    len(o.queryHistory) > 0,
+		SettingGroups:      settingGroups(res.Settings),
+		Assignments:        assignments(a),
+		Priorities:         priorities(a),
+		Tablespaces:        tablespacePlacement(res),
+		Operations:         operations(res),
+		SlotCleanup:        slotCleanupScript(res.ReplicationSlots),
+		TuningScript:       tuningScript(a),
+		Skipped:            skippedByReason(res.Skipped),
 	}
 	return tmpl.Execute(f, data)
 }
 
+// unusedBefore orders unused indexes by size, largest first, with the
+// relation name as a stable tie-breaker.
+func unusedBefore(a, b collect.IndexUnused) bool {
+	if a.SizeBytes != b.SizeBytes {
+		return a.SizeBytes > b.SizeBytes
+	}
+	if a.Database != b.Database {
+		return a.Database < b.Database
+	}
+	if a.Schema != b.Schema {
+		return a.Schema < b.Schema
+	}
+	return a.Name < b.Name
+}
+
+// fmtMs formats milliseconds, switching to a humanized duration from one second
+func fmtMs(ms float64) string {
+	if ms <= 0 {
+		return "0ms"
+	}
+	if ms < 1000 {
+		return fmt.Sprintf("%.2fms", ms)
+	}
+	d := time.Duration(ms * float64(time.Millisecond))
+	return humanizeDuration(d)
+}
+
 // fmtFloat previously trimmed trailing zeros; replaced by fmtFloatPrecSep
 
 // fmtFloatPrecSep formats a float with fixed precision and thousands separators in the integer part
@@ -854,6 +829,24 @@ func humanizeDuration(d time.Duration) string {
 	return strings.Join(parts, " ")
 }
 
+// spansDatabases reports whether rows belong to more than one database; rows
+// without a database belong to current.
+func spansDatabases[T any](rows []T, current string, db func(T) string) bool {
+	first := ""
+	for _, r := range rows {
+		d := strings.TrimSpace(db(r))
+		if d == "" {
+			d = current
+		}
+		if first == "" {
+			first = d
+		} else if d != first {
+			return true
+		}
+	}
+	return false
+}
+
 // fmtBytesStr converts bytes into a human readable string with units (B, KB, MB, GB, TB)
 func fmtBytesStr(b int64) string {
 	units := []string{"B", "KB", "MB", "GB", "TB"}
@@ -1,10 +1,14 @@
 package report
 
 import (
+	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/koltyakov/pghealth/internal/analyze"
+	"github.com/koltyakov/pghealth/internal/archive"
 	"github.com/koltyakov/pghealth/internal/collect"
 )
 
@@ -21,3 +25,413 @@ func TestTemplateExec(t *testing.T) {
 		t.Fatalf("WriteHTML failed: %v", err)
 	}
 }
+
+// TestTemplateExecSkipped ensures collectors skipped at the soft deadline are noted.
+func TestTemplateExecSkipped(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "report.html")
+	var res collect.Result
+	res.Skipped = []collect.SkippedCollector{{Name: "plans", Reason: "soft deadline of 4m0s reached"}, {Name: "wal", Reason: "soft deadline of 4m0s reached"}}
+	if err := WriteHTML(out, res, analyze.Analysis{}, collect.Meta{}); err != nil {
+		t.Fatalf("WriteHTML failed: %v", err)
+	}
+	b, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "<code>plans</code>, <code>wal</code> did not run (soft deadline of 4m0s reached)"; !strings.Contains(string(b), want) {
+		t.Errorf("report missing %q", want)
+	}
+}
+
+// TestTemplateExecPartial ensures an interrupted run is banner-marked as
+// partial with the skipped collectors grouped by reason.
+func TestTemplateExecPartial(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "report.html")
+	var res collect.Result
+	res.Skipped = []collect.SkippedCollector{{Name: "plans", Reason: "soft deadline of 4m0s reached"},
+		{Name: "wal", Reason: "run cancelled"}, {Name: "locks", Reason: "run cancelled"}}
+	if err := WriteHTML(out, res, analyze.Analysis{}, collect.Meta{}); err != nil {
+		t.Fatalf("WriteHTML failed: %v", err)
+	}
+	b, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"<title>[Partial] PostgreSQL Health Check Report</title>", "<strong>Partial report:</strong>",
+		"<li><code>plans</code> did not run (soft deadline of 4m0s reached)</li>", "<li><code>wal</code>, <code>locks</code> did not run (run cancelled)</li>"} {
+		if !strings.Contains(string(b), want) {
+			t.Errorf("report missing %q", want)
+		}
+	}
+}
+
+// TestDeterministicOutput ensures a run without timing metadata renders no
+// durations and that the report and prompt are byte-identical across renders.
+func TestDeterministicOutput(t *testing.T) {
+	var res collect.Result
+	for _, db := range []string{"sales", "billing", "audit"} {
+		for _, schema := range []string{"public", "archive", "app"} {
+			res.Tables = append(res.Tables, collect.TableStat{Database: db, Schema: schema, Name: "events", NLiveTup: 1e9})
+		}
+	}
+	render := func() (string, string) {
+		out := filepath.Join(t.TempDir(), "report.html")
+		if err := WriteHTML(out, res, analyze.Analysis{}, collect.Meta{Version: "v1.0.0"}); err != nil {
+			t.Fatalf("WriteHTML failed: %v", err)
+		}
+		promptPath, err := WritePrompt(out, res, collect.Meta{})
+		if err != nil {
+			t.Fatalf("WritePrompt failed: %v", err)
+		}
+		html, err := os.ReadFile(out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		prompt, err := os.ReadFile(promptPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(html), string(prompt)
+	}
+	html, prompt := render()
+	for _, unwanted := range []string{"Duration:", "n/a in "} {
+		if strings.Contains(html, unwanted) {
+			t.Errorf("report contains %q", unwanted)
+		}
+	}
+	for i := 0; i < 5; i++ {
+		h, p := render()
+		if h != html {
+			t.Fatal("report differs between renders")
+		}
+		if p != prompt {
+			t.Fatal("prompt differs between renders")
+		}
+	}
+}
+
+// TestTemplateExecQueryDetails ensures drill-down sections render and are linked.
+func TestTemplateExecQueryDetails(t *testing.T) {
+	dir := t.TempDir()
+	out := filepath.Join(dir, "report.html")
+
+	var res collect.Result
+	res.Extensions.PgStatStatements = true
+	res.Tables = []collect.TableStat{{Schema: "public", Name: "orders", NLiveTup: 10}}
+	res.Indexes = []collect.IndexStat{{Schema: "public", Table: "orders", Name: "orders_pkey"}}
+	q := collect.Statement{QueryID: 42, Query: "SELECT * FROM orders WHERE id = $1", Calls: 5, MeanTime: 30, Advice: &collect.PlanAdvice{Plan: "Seq Scan on orders", IndexDDL: []string{"CREATE INDEX CONCURRENTLY orders_status_idx ON public.orders (status);"}}}
+	res.Statements.TopByTotalTime = []collect.Statement{q}
+	res.Statements.TopByCalls = []collect.Statement{q}
+	res.Statements.ByRole = []collect.StatementLoad{{Name: "analytics_ro", Calls: 10, TotalTime: 620, Share: 0.62}}
+	res.Statements.ByApp = []collect.StatementLoad{{Name: "checkout", Calls: 4, TotalTime: 400, Share: 0.4}}
+	hist := map[string][]archive.QueryPoint{"42": {{RunID: "2024-01-15T10:30:00Z", Calls: 3, MeanTime: 10}}}
+
+	if err := WriteHTML(out, res, analyze.Analysis{}, collect.Meta{}, WithQueryHistory(hist)); err != nil {
+		t.Fatalf("WriteHTML failed: %v", err)
+	}
+	b, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	html := string(b)
+	for _, want := range []string{`href="#query-42"`, `id="query-42"`, "#1 by total time, #1 by calls", "queryid 42", "orders_pkey", "2024-01-15T10:30:00Z", "Seq Scan on orders", "CREATE INDEX CONCURRENTLY orders_status_idx ON public.orders (status);", "3.0× the baseline", `id="hdr-query-load"`, "Role analytics_ro accounts for 62% of total execution time.", `id="hdr-query-load-app"`, "Tagged statements cover 40%"} {
+		if !strings.Contains(html, want) {
+			t.Errorf("report missing %q", want)
+		}
+	}
+	if strings.Count(html, `class="query-detail"`) != 1 {
+		t.Error("duplicate query should share one detail section")
+	}
+}
+
+// TestTemplateExecMonitor ensures statements read from pg_stat_monitor render
+// with their source, histogram percentiles, clients and comments.
+func TestTemplateExecMonitor(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "report.html")
+	var res collect.Result
+	res.Extensions.PgStatMonitor = true
+	res.Statements.Source = collect.SourcePgStatMonitor
+	res.Statements.StatsResetTime = time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	res.Statements.StatsDuration = time.Hour
+	q := collect.Statement{QueryID: 7, Query: "SELECT 1", Calls: 100, MeanTime: 12, MaxTime: 400,
+		Histogram: []collect.LatencyBucket{{UpperMs: 10, Calls: 90}, {UpperMs: 100, Calls: 10}},
+		ClientIPs: []string{"10.0.0.5", "10.0.0.6"}, Comments: []string{"/* app:checkout */"}}
+	res.Statements.TopByTotalTime = []collect.Statement{q}
+	if err := WriteHTML(out, res, analyze.Analysis{}, collect.Meta{}); err != nil {
+		t.Fatalf("WriteHTML failed: %v", err)
+	}
+	b, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	html := string(b)
+	for _, want := range []string{"Data from pg_stat_monitor, covering the last", "pg_stat_monitor response time histogram", "Clients: 10.0.0.5, 10.0.0.6", "<code>/* app:checkout */</code>"} {
+		if !strings.Contains(html, want) {
+			t.Errorf("report missing %q", want)
+		}
+	}
+	if strings.Contains(html, "pg_stat_statements is not enabled") {
+		t.Error("pg_stat_monitor should count as query statistics")
+	}
+}
+
+// TestTemplateExecMultiDatabase ensures the Database column and the grouping
+// toggle appear only when rows of several databases are mixed.
+func TestTemplateExecMultiDatabase(t *testing.T) {
+	tests := []struct {
+		name string
+		dbs  []string
+		want bool
+	}{
+		{"single database", []string{"app", "app"}, false},
+		{"several databases", []string{"app", "billing"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := filepath.Join(t.TempDir(), "report.html")
+			res := collect.Result{ConnInfo: collect.ConnInfo{CurrentDB: "app"}}
+			for _, db := range tt.dbs {
+				res.IndexUnused = append(res.IndexUnused, collect.IndexUnused{Database: db, Schema: "public", Table: "orders", Name: "orders_status_idx"})
+			}
+			if err := WriteHTML(out, res, analyze.Analysis{}, collect.Meta{}); err != nil {
+				t.Fatalf("WriteHTML failed: %v", err)
+			}
+			b, err := os.ReadFile(out)
+			if err != nil {
+				t.Fatal(err)
+			}
+			html := string(b)
+			if got := strings.Contains(html, `data-target="#table-index-unused">Group by database`); got != tt.want {
+				t.Errorf("grouping toggle shown = %v, want %v", got, tt.want)
+			}
+			if got := strings.Contains(html, `<tr data-db="billing">`); got != tt.want {
+				t.Errorf("database rows = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestTemplateExecOperations ensures ongoing operations render with a
+// progress bar sized by their progress.
+func TestTemplateExecOperations(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "report.html")
+	var res collect.Result
+	res.ProgressCopy = []collect.ProgressCopy{{Datname: "app", PID: 10, Relation: "public.events", Command: "COPY FROM", Type: "FILE",
+		BytesProcessed: 512 << 20, BytesTotal: 2 << 30}}
+	if err := WriteHTML(out, res, analyze.Analysis{}, collect.Meta{}); err != nil {
+		t.Fatalf("WriteHTML failed: %v", err)
+	}
+	b, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	html := string(b)
+	for _, want := range []string{`id="hdr-operations"`, `<span style="width: 25.0%">`} {
+		if !strings.Contains(html, want) {
+			t.Errorf("report missing %q", want)
+		}
+	}
+}
+
+// TestTemplateExecReplicationSlots ensures the slots section renders with the
+// cleanup script when a slot is worth dropping.
+func TestTemplateExecReplicationSlots(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "report.html")
+	var res collect.Result
+	res.ReplicationSlots = []collect.ReplicationSlot{
+		{Name: "standby1", Type: "physical", Active: true, WALStatus: "reserved", SafeWALSize: -1, InactiveSeconds: -1},
+		{Name: "cdc", Type: "logical", Plugin: "pgoutput", Database: "app", WALStatus: "extended", RetainedBytes: 4 << 30, SafeWALSize: -1, InactiveSeconds: 86400},
+	}
+	if err := WriteHTML(out, res, analyze.Analysis{}, collect.Meta{}); err != nil {
+		t.Fatalf("WriteHTML failed: %v", err)
+	}
+	b, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	html := string(b)
+	for _, want := range []string{`id="hdr-replication-slots"`, "Drop candidate", "-- SELECT pg_drop_replication_slot(&#39;cdc&#39;);"} {
+		if !strings.Contains(html, want) {
+			t.Errorf("report missing %q", want)
+		}
+	}
+}
+
+// TestTemplateExecSettings ensures settings render grouped by tuning area
+// with the non-default configuration listed when collected.
+func TestTemplateExecSettings(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "report.html")
+	var res collect.Result
+	res.Settings = []collect.Setting{{Name: "shared_buffers", Val: "16384", Unit: "8kB", Source: "configuration file", BootVal: "16384",
+		MinVal: "16", MaxVal: "1073741823", Context: "postmaster", Category: "Resource Usage / Memory", PendingRestart: true}}
+	res.NonDefaultSettings = []collect.Setting{{Name: "log_min_duration_statement", Val: "500", Unit: "ms", Source: "configuration file",
+		BootVal: "-1", Context: "superuser", Category: "Reporting and Logging / When to Log"}}
+	if err := WriteHTML(out, res, analyze.Analysis{}, collect.Meta{}); err != nil {
+		t.Fatalf("WriteHTML failed: %v", err)
+	}
+	b, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	html := string(b)
+	for _, want := range []string{"<h3>Memory</h3>", "16384 8kB <span class=\"badge-attn\">Pending restart</span>", "16 &ndash; 1073741823", "<td>restart</td>",
+		`id="hdr-settings-non-default"`, "<td>log_min_duration_statement</td>", "<td>session</td>"} {
+		if !strings.Contains(html, want) {
+			t.Errorf("report missing %q", want)
+		}
+	}
+}
+
+// TestTemplateExecSchemas ensures the schema rollup renders once tables span
+// several schemas, with the database column only across databases.
+func TestTemplateExecSchemas(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "report.html")
+	var res collect.Result
+	res.ConnInfo.CurrentDB = "app"
+	res.Catalog.Schemas = []collect.SchemaTotals{
+		{Database: "app", Schema: "billing", Tables: 12, TableBytes: 3 << 30, Indexes: 20, IndexBytes: 1 << 30, LiveTup: 900, DeadTup: 100, SeqScans: 10, IdxScans: 90},
+		{Database: "app", Schema: "public", Tables: 3, TableBytes: 1 << 20},
+	}
+	if err := WriteHTML(out, res, analyze.Analysis{}, collect.Meta{}); err != nil {
+		t.Fatalf("WriteHTML failed: %v", err)
+	}
+	b, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	html := string(b)
+	for _, want := range []string{`id="hdr-schemas"`, "<td>billing</td>", "<td>100 (10.0%)</td>", "<td>90.0%</td>"} {
+		if !strings.Contains(html, want) {
+			t.Errorf("report missing %q", want)
+		}
+	}
+	if strings.Contains(html, `data-target="#table-schemas">Group by database`) {
+		t.Error("database grouping shown for a single database")
+	}
+}
+
+// TestTemplateExecSecurity verifies the Security section renders the storage
+// items and names what cannot be derived.
+func TestTemplateExecSecurity(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "report.html")
+	var res collect.Result
+	res.ConnInfo.SSL = "on"
+	res.ConnInfo.PasswordEncryption = "md5"
+	res.DataDirectory = &collect.DataDirectory{Path: "/var/lib/postgresql/16/main", WALPath: "/wal/16", Checksums: true}
+	if err := WriteHTML(out, res, analyze.Analysis{}, collect.Meta{}); err != nil {
+		t.Fatalf("WriteHTML failed: %v", err)
+	}
+	b, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	html := string(b)
+	for _, want := range []string{`id="hdr-security"`, "<code>/var/lib/postgresql/16/main</code>", "<code>/wal/16</code> (linked out of the data directory)",
+		"not derivable: disk and filesystem encryption are invisible to PostgreSQL", `<span class="badge-attn">md5</span>`} {
+		if !strings.Contains(html, want) {
+			t.Errorf("report missing %q", want)
+		}
+	}
+}
+
+// TestTemplateExecStatementWindow verifies the recent load section renders
+// the statement deltas and their hourly rate.
+func TestTemplateExecStatementWindow(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "report.html")
+	var res collect.Result
+	res.Extensions.PgStatStatements = true
+	from := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	res.StatementWindow = &collect.StatementWindow{
+		From: from, To: from.Add(24 * time.Hour), Calls: 4800, TotalTime: 9600,
+		Statements: []collect.StatementDelta{{QueryID: 1, Query: "select * from orders", Calls: 4800, TotalTime: 9600, Reset: true}},
+	}
+	if err := WriteHTML(out, res, analyze.Analysis{}, collect.Meta{}); err != nil {
+		t.Fatalf("WriteHTML failed: %v", err)
+	}
+	b, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	html := string(b)
+	for _, want := range []string{`id="hdr-query-window"`, "Load in the last 1d", "select * from orders", "<td class=\"nowrap\">200.0</td>", "(reset)"} {
+		if !strings.Contains(html, want) {
+			t.Errorf("report missing %q", want)
+		}
+	}
+}
+
+// TestTemplateExecDelta verifies the -delta section renders per-second rates
+// of the databases, WAL and statements.
+func TestTemplateExecDelta(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "report.html")
+	var res collect.Result
+	from := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	res.Delta = &collect.DeltaStats{
+		From: from, To: from.Add(time.Minute), WALBytes: 60 << 20, HasWAL: true,
+		Databases:   []collect.DatabaseDelta{{Name: "app", Commits: 6000, BlksRead: 100, BlksHit: 9900}},
+		Checkpoints: &collect.CheckpointDelta{Timed: 1, BuffersCheckpoint: 300},
+		Statements: &collect.StatementWindow{From: from, To: from.Add(time.Minute), Calls: 120, TotalTime: 60,
+			Statements: []collect.StatementDelta{{QueryID: 1, Query: "select * from orders", Calls: 120, TotalTime: 60}}},
+	}
+	if err := WriteHTML(out, res, analyze.Analysis{}, collect.Meta{}); err != nil {
+		t.Fatalf("WriteHTML failed: %v", err)
+	}
+	b, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	html := string(b)
+	for _, want := range []string{`id="hdr-delta"`, "Rates over 1m", "60.00 MB written, 1.00 MB/s", "<td class=\"nowrap\">100.0</td>", "99.00%",
+		`id="hdr-delta-statements"`, "<td class=\"nowrap\">2.0</td>", "select * from orders"} {
+		if !strings.Contains(html, want) {
+			t.Errorf("report missing %q", want)
+		}
+	}
+}
+
+// TestTemplateExecExecutiveSummary ensures the executive summary opens the
+// report, ahead of the priorities and the finding cards.
+func TestTemplateExecExecutiveSummary(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "report.html")
+	a := analyze.Analysis{
+		Warnings: []analyze.Finding{{Title: "Transaction ID wraparound near", Severity: analyze.SeverityWarning, EstimatedImpact: analyze.LevelHigh, Effort: analyze.LevelMedium}},
+	}
+	a.Summary = analyze.Summarize(a, nil)
+	if err := WriteHTML(out, collect.Result{}, a, collect.Meta{}); err != nil {
+		t.Fatalf("WriteHTML failed: %v", err)
+	}
+	b, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	html := string(b)
+	at := strings.Index(html, `id="hdr-summary"`)
+	if at < 0 || at > strings.Index(html, `id="hdr-priorities"`) {
+		t.Error("executive summary should render above the priorities")
+	}
+	if !strings.Contains(html, "<li>Biggest risks: Transaction ID wraparound near.</li>") {
+		t.Error("executive summary missing the biggest risk")
+	}
+}
+
+// TestTemplateExecRunbookLinks verifies finding cards link to the runbook
+// and the public documentation of their code.
+func TestTemplateExecRunbookLinks(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "report.html")
+	a := analyze.Analysis{Warnings: []analyze.Finding{{Title: "SSL disabled", Code: "ssl-off", Severity: analyze.SeverityWarning, Docs: analyze.DocsURL("ssl-off")}}}
+	a = analyze.LinkRunbooks(a, "https://wiki.example.com/pg/{code}")
+	if err := WriteHTML(out, collect.Result{}, a, collect.Meta{}); err != nil {
+		t.Fatalf("WriteHTML failed: %v", err)
+	}
+	b, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	html := string(b)
+	if !strings.Contains(html, `<a href="https://wiki.example.com/pg/ssl-off" target="_blank" rel="noopener">Runbook</a>`) {
+		t.Error("finding card missing the runbook link")
+	}
+	if !strings.Contains(html, `<a href="https://www.postgresql.org/docs/current/ssl-tcp.html" target="_blank" rel="noopener">📖 Docs</a>`) {
+		t.Error("finding card missing the docs link")
+	}
+}
@@ -0,0 +1,37 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/koltyakov/pghealth/internal/analyze"
+	"github.com/koltyakov/pghealth/internal/collect"
+	"github.com/koltyakov/pghealth/internal/snapshot"
+)
+
+// WriteJSON writes the run as an indented JSON document for machine
+// consumption; "-" writes to stdout. The document is the snapshot layout
+// (see snapshot.Snapshot): "schema" is snapshot.SchemaVersion, bumped on
+// incompatible changes, "meta" describes the run, "result" holds every
+// collected metric and "analysis" the findings. Result and analysis keys are
+// the Go field names, pinned by json tags; durations are nanoseconds and
+// times RFC 3339.
+func WriteJSON(path string, res collect.Result, a analyze.Analysis, meta collect.Meta) error {
+	if path == "" {
+		return fmt.Errorf("output path cannot be empty")
+	}
+	b, err := json.MarshalIndent(snapshot.New(res, a, meta), "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode report: %w", err)
+	}
+	b = append(b, '\n')
+	if path == "-" {
+		_, err := os.Stdout.Write(b)
+		return err
+	}
+	if err := os.WriteFile(path, b, summaryFilePerms); err != nil {
+		return fmt.Errorf("write report: %w", err)
+	}
+	return nil
+}
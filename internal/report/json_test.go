@@ -0,0 +1,42 @@
+package report
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/koltyakov/pghealth/internal/analyze"
+	"github.com/koltyakov/pghealth/internal/collect"
+	"github.com/koltyakov/pghealth/internal/snapshot"
+)
+
+// TestWriteJSON verifies the JSON report decodes back as a snapshot with the
+// result, analysis and metadata of the run.
+func TestWriteJSON(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "report.json")
+	res := collect.Result{Tables: []collect.TableStat{{Schema: "public", Name: "orders", NLiveTup: 10}}}
+	a := analyze.Analysis{Warnings: []analyze.Finding{{Title: "Cache hit ratio is low", Code: "cache-hit-low"}}}
+	meta := collect.Meta{Target: "db.example:5432/app", Version: "1.2.3"}
+
+	if err := WriteJSON(out, res, a, meta); err != nil {
+		t.Fatalf("WriteJSON failed: %v", err)
+	}
+	f, err := os.Open(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	s, err := snapshot.Decode(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Schema != snapshot.SchemaVersion || s.Meta.Target != meta.Target || s.Meta.Version != meta.Version {
+		t.Errorf("schema/meta = %d/%+v", s.Schema, s.Meta)
+	}
+	if len(s.Result.Tables) != 1 || s.Result.Tables[0].Name != "orders" {
+		t.Errorf("tables = %+v", s.Result.Tables)
+	}
+	if len(s.Analysis.Warnings) != 1 || s.Analysis.Warnings[0].Code != "cache-hit-low" {
+		t.Errorf("warnings = %+v", s.Analysis.Warnings)
+	}
+}
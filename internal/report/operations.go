@@ -0,0 +1,71 @@
+package report
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/koltyakov/pghealth/internal/collect"
+)
+
+// operationRow is an ongoing bulk operation of the "Operations in progress"
+// section: a COPY, a CLUSTER or VACUUM FULL rewrite, or a base backup.
+type operationRow struct {
+	Operation string
+	Database  string
+	PID       int
+	Target    string // relation, or the client of a base backup
+	Phase     string
+	Pct       float64
+	HasPct    bool // false when the total is unknown
+	Done      string
+	Running   time.Duration
+}
+
+// operations lists the ongoing COPY, CLUSTER/VACUUM FULL and base backup
+// operations, longest running first within each kind.
+func operations(res collect.Result) []operationRow {
+	var out []operationRow
+	for _, p := range res.ProgressCopy {
+		target := p.Relation
+		if target == "" {
+			target = "(query)"
+		}
+		done := fmt.Sprintf("%s, %s rows", fmtBytesStr(p.BytesProcessed), addThousands(fmt.Sprint(p.TuplesProcessed)))
+		if p.BytesTotal > 0 {
+			done = fmt.Sprintf("%s of %s, %s rows", fmtBytesStr(p.BytesProcessed), fmtBytesStr(p.BytesTotal), addThousands(fmt.Sprint(p.TuplesProcessed)))
+		}
+		if p.TuplesExcluded > 0 {
+			done += fmt.Sprintf(" (%s excluded)", addThousands(fmt.Sprint(p.TuplesExcluded)))
+		}
+		out = append(out, operationRow{Operation: p.Command, Database: p.Datname, PID: p.PID, Target: target, Phase: p.Type,
+			Pct: p.Progress(), HasPct: p.BytesTotal > 0, Done: done, Running: seconds(p.Seconds)})
+	}
+	for _, p := range res.ProgressCluster {
+		done := fmt.Sprintf("%s rows written", addThousands(fmt.Sprint(p.HeapTuplesWritten)))
+		if p.HeapBlksTotal > 0 {
+			done = fmt.Sprintf("%s of %s blocks, %s", addThousands(fmt.Sprint(p.HeapBlksScanned)), addThousands(fmt.Sprint(p.HeapBlksTotal)), done)
+		}
+		if p.IndexRebuilds > 0 {
+			done += fmt.Sprintf(", %d index(es) rebuilt", p.IndexRebuilds)
+		}
+		out = append(out, operationRow{Operation: p.Command, Database: p.Datname, PID: p.PID, Target: p.Relation, Phase: p.Phase,
+			Pct: p.Progress(), HasPct: p.HeapBlksTotal > 0, Done: done, Running: seconds(p.Seconds)})
+	}
+	for _, p := range res.ProgressBasebackup {
+		done := fmtBytesStr(p.BytesStreamed)
+		if p.BytesTotal > 0 {
+			done += " of ~" + fmtBytesStr(p.BytesTotal)
+		}
+		if p.TablespacesTotal > 0 {
+			done += fmt.Sprintf(", %d/%d tablespace(s)", p.TablespacesStreamed, p.TablespacesTotal)
+		}
+		out = append(out, operationRow{Operation: "BASE BACKUP", PID: p.PID, Target: p.Client, Phase: p.Phase,
+			Pct: p.Progress(), HasPct: p.BytesTotal > 0, Done: done, Running: seconds(p.Seconds)})
+	}
+	return out
+}
+
+// seconds converts a duration in seconds to time.Duration.
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second)).Round(time.Second)
+}
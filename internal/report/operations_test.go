@@ -0,0 +1,45 @@
+package report
+
+import (
+	"testing"
+	"time"
+
+	"github.com/koltyakov/pghealth/internal/collect"
+)
+
+// TestOperations verifies ongoing COPY, rewrite and base backup operations
+// are listed with progress only when their total is known.
+func TestOperations(t *testing.T) {
+	res := collect.Result{
+		ProgressCopy: []collect.ProgressCopy{
+			{Datname: "app", PID: 10, Relation: "public.events", Command: "COPY FROM", Type: "FILE", BytesProcessed: 512 << 20, BytesTotal: 2 << 30, TuplesProcessed: 1500000, Seconds: 90},
+			{Datname: "app", PID: 11, Command: "COPY TO", Type: "PIPE", BytesProcessed: 1 << 20, TuplesProcessed: 10},
+		},
+		ProgressCluster:    []collect.ProgressCluster{{Datname: "app", PID: 12, Relation: "public.orders", Command: "VACUUM FULL", Phase: "seq scanning heap", HeapBlksScanned: 50, HeapBlksTotal: 200}},
+		ProgressBasebackup: []collect.ProgressBasebackup{{PID: 13, Client: "pg_basebackup", Phase: "streaming database files", BytesStreamed: 3 << 30}},
+	}
+	rows := operations(res)
+	want := []struct {
+		op, target string
+		pct        float64
+		hasPct     bool
+		done       string
+	}{
+		{"COPY FROM", "public.events", 25, true, "512.00 MB of 2.00 GB, 1,500,000 rows"},
+		{"COPY TO", "(query)", 0, false, "1.00 MB, 10 rows"},
+		{"VACUUM FULL", "public.orders", 25, true, "50 of 200 blocks, 0 rows written"},
+		{"BASE BACKUP", "pg_basebackup", 0, false, "3.00 GB"},
+	}
+	if len(rows) != len(want) {
+		t.Fatalf("got %d operations, want %d", len(rows), len(want))
+	}
+	for i, w := range want {
+		r := rows[i]
+		if r.Operation != w.op || r.Target != w.target || r.Pct != w.pct || r.HasPct != w.hasPct || r.Done != w.done {
+			t.Errorf("operation %d = %+v, want %+v", i, r, w)
+		}
+	}
+	if rows[0].Running != 90*time.Second {
+		t.Errorf("running = %v, want 1m30s", rows[0].Running)
+	}
+}
@@ -0,0 +1,121 @@
+package report
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/koltyakov/pghealth/internal/analyze"
+	"github.com/koltyakov/pghealth/internal/collect"
+)
+
+// teamDigestInfix separates the report name from the team in digest files:
+// report.html -> report.team-payments.md.
+const teamDigestInfix = ".team-"
+
+// teamAssignment lists the actionable findings assigned to one team.
+type teamAssignment struct {
+	Team            string
+	Warnings        int
+	Recommendations int
+	Findings        []analyze.Finding
+}
+
+// hasAssignments reports whether -owners rules assigned any finding.
+func hasAssignments(a analyze.Analysis) bool {
+	for _, list := range [][]analyze.Finding{a.Warnings, a.Recommendations, a.Infos} {
+		for _, f := range list {
+			if len(f.Owners) > 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// assignments groups warnings and recommendations by owning team, in the
+// order teams first appear.
+func assignments(a analyze.Analysis) []teamAssignment {
+	var out []teamAssignment
+	idx := map[string]int{}
+	for _, list := range [][]analyze.Finding{a.Warnings, a.Recommendations} {
+		for _, f := range list {
+			for _, team := range f.Owners {
+				i, ok := idx[team]
+				if !ok {
+					i = len(out)
+					idx[team] = i
+					out = append(out, teamAssignment{Team: team})
+				}
+				if f.Severity == analyze.SeverityWarning {
+					out[i].Warnings++
+				} else {
+					out[i].Recommendations++
+				}
+				out[i].Findings = append(out[i].Findings, f)
+			}
+		}
+	}
+	return out
+}
+
+// teamFindings keeps the findings assigned to team.
+func teamFindings(a analyze.Analysis, team string) analyze.Analysis {
+	keep := func(list []analyze.Finding) []analyze.Finding {
+		var out []analyze.Finding
+		for _, f := range list {
+			for _, o := range f.Owners {
+				if o == team {
+					out = append(out, f)
+					break
+				}
+			}
+		}
+		return out
+	}
+	return analyze.Analysis{Warnings: keep(a.Warnings), Recommendations: keep(a.Recommendations), Infos: keep(a.Infos), Workload: a.Workload}
+}
+
+// WriteTeamDigests writes one Markdown digest per team with findings assigned
+// by -owners rules, next to the HTML report, and returns their paths.
+func WriteTeamDigests(htmlOutPath string, teams []string, res collect.Result, a analyze.Analysis, meta collect.Meta) ([]string, error) {
+	if htmlOutPath == "-" || strings.TrimSpace(htmlOutPath) == "" {
+		return nil, nil
+	}
+	base := strings.TrimSuffix(htmlOutPath, filepath.Ext(htmlOutPath))
+	var paths []string
+	for _, team := range teams {
+		ta := teamFindings(a, team)
+		if len(ta.Warnings)+len(ta.Recommendations)+len(ta.Infos) == 0 {
+			continue
+		}
+		p := base + teamDigestInfix + fileSlug(team) + ".md"
+		f, err := os.Create(p)
+		if err != nil {
+			return paths, fmt.Errorf("create team digest: %w", err)
+		}
+		err = writeMarkdown(f, "PostgreSQL health check: "+team, res, ta, meta)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return paths, fmt.Errorf("write team digest %s: %w", p, err)
+		}
+		paths = append(paths, p)
+	}
+	return paths, nil
+}
+
+// fileSlug lowercases s and replaces characters unsafe in file names.
+func fileSlug(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '-', r == '_':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r + ('a' - 'A')
+		}
+		return '-'
+	}, s)
+}
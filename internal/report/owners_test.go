@@ -0,0 +1,55 @@
+package report
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/koltyakov/pghealth/internal/analyze"
+	"github.com/koltyakov/pghealth/internal/collect"
+)
+
+// TestTeamDigests verifies the assignment table, the Assigned summary column
+// and one digest per team holding only its findings.
+func TestTeamDigests(t *testing.T) {
+	a := analyze.Analysis{
+		Warnings: []analyze.Finding{
+			{Title: "Bloat", Severity: analyze.SeverityWarning, Owners: []string{"payments", "Search Team"}},
+		},
+		Recommendations: []analyze.Finding{
+			{Title: "Unused index", Severity: analyze.SeverityRec, Owners: []string{"Search Team"}},
+			{Title: "work_mem", Severity: analyze.SeverityRec},
+		},
+	}
+
+	got := assignments(a)
+	if len(got) != 2 || got[0].Team != "payments" || got[1].Warnings != 1 || got[1].Recommendations != 1 {
+		t.Errorf("assignments = %+v", got)
+	}
+
+	out := filepath.Join(t.TempDir(), "report.html")
+	if err := WriteHTML(out, collect.Result{}, a, collect.Meta{}); err != nil {
+		t.Fatal(err)
+	}
+	html, _ := os.ReadFile(out)
+	if !strings.Contains(string(html), `id="hdr-assignments"`) || !strings.Contains(string(html), "Assigned to: <strong>payments</strong>") {
+		t.Error("report has no assignments")
+	}
+
+	paths, err := WriteTeamDigests(out, []string{"payments", "Search Team", "dba"}, collect.Result{}, a, collect.Meta{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) != 2 || filepath.Base(paths[1]) != "report.team-search-team.md" {
+		t.Fatalf("digests = %v", paths)
+	}
+	b, _ := os.ReadFile(paths[1])
+	md := string(b)
+	if !strings.Contains(md, "## PostgreSQL health check: Search Team") || !strings.Contains(md, "Unused index") || strings.Contains(md, "work_mem") {
+		t.Errorf("search digest = %s", md)
+	}
+	if !strings.Contains(md, "| Assigned |") {
+		t.Errorf("digest lacks the Assigned column: %s", md)
+	}
+}
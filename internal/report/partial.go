@@ -0,0 +1,24 @@
+package report
+
+import "github.com/koltyakov/pghealth/internal/collect"
+
+// skipGroup lists the collectors skipped for one reason.
+type skipGroup struct {
+	Reason string
+	Names  []string
+}
+
+// skippedByReason groups skipped collectors by reason in collection order, so
+// a partial report tells collectors cut by a soft deadline from those lost
+// to the run timeout or an interrupt.
+func skippedByReason(skipped []collect.SkippedCollector) []skipGroup {
+	var out []skipGroup
+	for _, s := range skipped {
+		if n := len(out); n > 0 && out[n-1].Reason == s.Reason {
+			out[n-1].Names = append(out[n-1].Names, s.Name)
+			continue
+		}
+		out = append(out, skipGroup{Reason: s.Reason, Names: []string{s.Name}})
+	}
+	return out
+}
@@ -0,0 +1,36 @@
+package report
+
+import "github.com/koltyakov/pghealth/internal/analyze"
+
+// priorityQuadrant is a cell of the effort/impact matrix at the top of the
+// report.
+type priorityQuadrant struct {
+	Name     string
+	Note     string
+	Findings []analyze.Finding
+}
+
+// priorities places the rated warnings and recommendations in the quick
+// wins, major projects and low value quadrants, warnings first; nil when no
+// finding is rated.
+func priorities(a analyze.Analysis) []priorityQuadrant {
+	out := []priorityQuadrant{
+		{Name: "Quick wins", Note: "medium or high impact, configuration change"},
+		{Name: "Major projects", Note: "medium or high impact, index build, maintenance or refactor"},
+		{Name: "Low value", Note: "low impact"},
+	}
+	cell := map[string]int{analyze.QuadrantQuickWins: 0, analyze.QuadrantMajor: 1, analyze.QuadrantLowValue: 2}
+	rated := false
+	for _, list := range [][]analyze.Finding{a.Warnings, a.Recommendations} {
+		for _, f := range list {
+			if i, ok := cell[f.Quadrant()]; ok {
+				out[i].Findings = append(out[i].Findings, f)
+				rated = true
+			}
+		}
+	}
+	if !rated {
+		return nil
+	}
+	return out
+}
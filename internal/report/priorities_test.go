@@ -0,0 +1,53 @@
+package report
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/koltyakov/pghealth/internal/analyze"
+	"github.com/koltyakov/pghealth/internal/collect"
+)
+
+// TestPriorities verifies rated findings land in their quadrant, warnings
+// first, and the matrix renders above the finding cards.
+func TestPriorities(t *testing.T) {
+	a := analyze.Analysis{
+		Warnings: []analyze.Finding{{Title: "Seq scans on orders", Severity: analyze.SeverityWarning, EstimatedImpact: analyze.LevelHigh, Effort: analyze.LevelMedium, EffortKind: "index build", Confidence: analyze.LevelHigh}},
+		Recommendations: []analyze.Finding{
+			{Title: "Raise work_mem", Severity: analyze.SeverityRec, EstimatedImpact: analyze.LevelMedium, Effort: analyze.LevelLow, EffortKind: "config change", Confidence: analyze.LevelMedium},
+			{Title: "Vacuum audit_log", Severity: analyze.SeverityRec, EstimatedImpact: analyze.LevelLow, Effort: analyze.LevelMedium, EffortKind: "maintenance", Confidence: analyze.LevelHigh},
+			{Title: "Unrated", Severity: analyze.SeverityRec},
+		},
+	}
+	p := priorities(a)
+	if len(p) != 3 {
+		t.Fatalf("quadrants = %d", len(p))
+	}
+	for i, want := range []string{"Raise work_mem", "Seq scans on orders", "Vacuum audit_log"} {
+		if len(p[i].Findings) != 1 || p[i].Findings[0].Title != want {
+			t.Errorf("%s = %+v, expected %s", p[i].Name, p[i].Findings, want)
+		}
+	}
+	if priorities(analyze.Analysis{Recommendations: a.Recommendations[3:]}) != nil {
+		t.Error("unrated findings should leave the matrix out")
+	}
+
+	out := filepath.Join(t.TempDir(), "report.html")
+	if err := WriteHTML(out, collect.Result{}, a, collect.Meta{}); err != nil {
+		t.Fatalf("WriteHTML failed: %v", err)
+	}
+	b, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	html := string(b)
+	at := strings.Index(html, `id="hdr-priorities"`)
+	if at < 0 || at > strings.Index(html, `class="card warn"`) {
+		t.Error("priorities should render above the finding cards")
+	}
+	if !strings.Contains(html, "config change &middot; impact medium &middot; confidence medium") {
+		t.Error("quick win missing its effort, impact and confidence")
+	}
+}
@@ -42,6 +42,8 @@ type promptQuery struct {
 	TotalTime float64 `json:"total_time,omitempty"`
 	Calls     float64 `json:"calls,omitempty"`
 	MeanTime  float64 `json:"mean_time,omitempty"`
+	MaxTime   float64 `json:"max_time,omitempty"`
+	P99Time   float64 `json:"p99_time_est,omitempty"`
 	Rows      float64 `json:"rows,omitempty"`
 	Plan      string  `json:"plan,omitempty"`
 }
@@ -100,6 +102,9 @@ func WritePrompt(htmlOutPath string, res collect.Result, meta collect.Meta) (str
 			MeanTime:  s.MeanTime,
 			Rows:      s.Rows,
 		}
+		if s.HasLatencySpread() {
+			pq.MaxTime, pq.P99Time = s.MaxTime, s.LatencyP99()
+		}
 		if s.Advice != nil {
 			pq.Plan = trimLong(s.Advice.Plan, maxPlanLen)
 		}
@@ -109,10 +114,10 @@ func WritePrompt(htmlOutPath string, res collect.Result, meta collect.Meta) (str
 	type qwrap struct{ s collect.Statement }
 	uniq := map[string]qwrap{}
 	insertOrPromote := func(s collect.Statement) {
-		qt := strings.TrimSpace(s.Query)
-		if qt == "" {
+		if strings.TrimSpace(s.Query) == "" {
 			return
 		}
+		qt := s.Key()
 		if existing, ok := uniq[qt]; ok {
 			// prefer one with advice; otherwise higher total time, then higher calls
 			if (s.Advice != nil && existing.s.Advice == nil) ||
@@ -131,7 +136,7 @@ func WritePrompt(htmlOutPath string, res collect.Result, meta collect.Meta) (str
 	for _, s := range res.Statements.TopByCalls {
 		insertOrPromote(s)
 	}
-	// Convert to slice and sort: NeedsAttention first, then by TotalTime desc, then Calls desc
+	// Convert to slice and sort: NeedsAttention first, then by TotalTime desc, then Calls desc, then key
 	list := make([]collect.Statement, 0, len(uniq))
 	for _, w := range uniq {
 		list = append(list, w.s)
@@ -145,7 +150,10 @@ func WritePrompt(htmlOutPath string, res collect.Result, meta collect.Meta) (str
 		if ai.TotalTime != aj.TotalTime {
 			return ai.TotalTime > aj.TotalTime
 		}
-		return ai.Calls > aj.Calls
+		if ai.Calls != aj.Calls {
+			return ai.Calls > aj.Calls
+		}
+		return ai.Key() < aj.Key()
 	})
 	// Add all (no artificial cap)
 	for _, s := range list {
@@ -170,11 +178,12 @@ func WritePrompt(htmlOutPath string, res collect.Result, meta collect.Meta) (str
 
 	// Build DB->Schema->Tables with indexes DDL
 	// Include tables only if large-by-rows OR referenced in top query plans
-	// map schema.table -> []DDL (deduped)
+	// map db.schema.table -> []DDL (deduped); with -dbs the same schema.table
+	// can exist in several databases
 	idxDDL := map[string][]string{}
 	seenDDL := map[string]struct{}{}
 	for _, idx := range res.Indexes {
-		key := strings.ToLower(idx.Schema + "." + idx.Table)
+		key := strings.ToLower(collect.QualifiedName(valueOr(res.ConnInfo.CurrentDB, idx.Database), idx.Schema, idx.Table))
 		ddl := strings.TrimSpace(idx.DDL)
 		if ddl == "" {
 			continue
@@ -207,7 +216,7 @@ func WritePrompt(htmlOutPath string, res collect.Result, meta collect.Meta) (str
 			}
 			if shouldIncludeTable(t.Schema, t.Name, t.RowCount) {
 				pt := promptTable{Name: t.Name, SizeBytes: t.SizeBytes, BloatPct: t.BloatPct, RowCount: t.RowCount, DeadRows: t.DeadRows}
-				key := strings.ToLower(t.Schema + "." + t.Name)
+				key := strings.ToLower(collect.QualifiedName(dbName, t.Schema, t.Name))
 				pt.Indexes = append(pt.Indexes, idxDDL[key]...)
 				byDB[dbName][t.Schema] = append(byDB[dbName][t.Schema], pt)
 			}
@@ -220,20 +229,22 @@ func WritePrompt(htmlOutPath string, res collect.Result, meta collect.Meta) (str
 			}
 			if shouldIncludeTable(t.Schema, t.Name, t.NLiveTup) {
 				pt := promptTable{Name: t.Name, SizeBytes: t.SizeBytes, BloatPct: t.BloatPct, RowCount: t.NLiveTup, DeadRows: t.NDeadTup}
-				key := strings.ToLower(t.Schema + "." + t.Name)
+				key := strings.ToLower(collect.QualifiedName(dbName, t.Schema, t.Name))
 				pt.Indexes = append(pt.Indexes, idxDDL[key]...)
 				byDB[dbName][t.Schema] = append(byDB[dbName][t.Schema], pt)
 			}
 		}
 	}
-	// materialize hierarchy
+	// materialize hierarchy, ordered by name so the prompt is stable
 	for dbName, schemas := range byDB {
 		pdb := promptDB{Name: dbName}
 		for schemaName, tables := range schemas {
 			pdb.Schemas = append(pdb.Schemas, promptSchema{Name: schemaName, Tables: tables})
 		}
+		sort.Slice(pdb.Schemas, func(i, j int) bool { return pdb.Schemas[i].Name < pdb.Schemas[j].Name })
 		pd.DBs = append(pd.DBs, pdb)
 	}
+	sort.Slice(pd.DBs, func(i, j int) bool { return pd.DBs[i].Name < pd.DBs[j].Name })
 
 	// Unused indexes (already unified upstream)
 	pd.UnusedIndexes = append(pd.UnusedIndexes, res.IndexUnused...)
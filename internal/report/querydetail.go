@@ -0,0 +1,175 @@
+package report
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/koltyakov/pghealth/internal/archive"
+	"github.com/koltyakov/pghealth/internal/collect"
+	"github.com/koltyakov/pghealth/internal/sqlparse"
+)
+
+// Option customizes report rendering.
+type Option func(*options)
+
+// options holds optional report inputs that are not part of a single run.
+type options struct {
+	queryHistory map[string][]archive.QueryPoint
+}
+
+// WithQueryHistory adds past observations of top queries, keyed by
+// collect.Statement.Key, to the per-query drill-down sections.
+func WithQueryHistory(h map[string][]archive.QueryPoint) Option {
+	return func(o *options) { o.queryHistory = h }
+}
+
+// regressionFactor is the mean time growth over the archived baseline that
+// marks a query as regressed.
+const regressionFactor = 1.5
+
+// queryDetail is a per-query drill-down section linked from the top query tables.
+type queryDetail struct {
+	ID         string
+	Num        int
+	Ranks      []string
+	Stmt       collect.Statement
+	Tables     []collect.TableStat
+	Indexes    []collect.IndexStat
+	History    []archive.QueryPoint
+	Regression string
+	Shape      string // statement classification, see queryShape
+}
+
+// buildQueryDetails collects the distinct top queries (by total time, then by
+// calls) with the table and index statistics of the relations they reference.
+// Queries are identified by collect.Statement.Key so sections keep the same
+// anchor (query-<queryid>) across runs. It also returns a map from statement
+// key to section anchor. runID identifies the current run within history.
+func buildQueryDetails(res collect.Result, history map[string][]archive.QueryPoint, runID string) ([]queryDetail, map[string]string) {
+	var details []queryDetail
+	anchors := map[string]string{}
+	byKey := map[string]int{}
+
+	add := func(list []collect.Statement, label string) {
+		for i, s := range list {
+			rank := fmt.Sprintf("#%d by %s", i+1, label)
+			key := s.Key()
+			if idx, ok := byKey[key]; ok {
+				details[idx].Ranks = append(details[idx].Ranks, rank)
+				continue
+			}
+			id := fmt.Sprintf("query-%d", s.QueryID)
+			if s.QueryID == 0 {
+				id = fmt.Sprintf("query-n%d", len(details)+1)
+			}
+			byKey[key] = len(details)
+			anchors[key] = id
+			details = append(details, queryDetail{
+				ID: id, Num: len(details) + 1, Ranks: []string{rank}, Stmt: s,
+				History: history[key], Regression: queryRegression(s, history[key], runID),
+			})
+		}
+	}
+	add(res.Statements.TopByTotalTime, "total time")
+	add(res.Statements.TopByCalls, "calls")
+	add(res.Statements.TopByCPU, "CPU time")
+	add(res.Statements.TopByIO, "I/O time")
+	add(res.Statements.TopByIOBlocks, "blocks")
+
+	for i := range details {
+		if p := details[i].Stmt.TextProblem(); p != "" {
+			details[i].Shape = strings.ToUpper(p[:1]) + p[1:] + "; shape, tables and plan advice are not derived from it."
+			continue
+		}
+		parsed := sqlparse.Parse(details[i].Stmt.Query)
+		details[i].Shape = queryShape(parsed)
+		details[i].Tables = queryTables(parsed, res)
+		for _, t := range details[i].Tables {
+			for _, ix := range res.Indexes {
+				if sameDB(ix.Database, t.Database) && ix.Schema == t.Schema && ix.Table == t.Name {
+					details[i].Indexes = append(details[i].Indexes, ix)
+				}
+			}
+		}
+	}
+	return details, anchors
+}
+
+// queryRegression compares the current mean time with the median mean time of
+// earlier archived runs and describes a slowdown beyond regressionFactor.
+func queryRegression(s collect.Statement, hist []archive.QueryPoint, runID string) string {
+	var prior []float64
+	for _, p := range hist {
+		if p.RunID != runID && p.MeanTime > 0 {
+			prior = append(prior, p.MeanTime)
+		}
+	}
+	if len(prior) == 0 || s.MeanTime <= 0 {
+		return ""
+	}
+	sort.Float64s(prior)
+	base := prior[len(prior)/2]
+	if len(prior)%2 == 0 {
+		base = (prior[len(prior)/2-1] + base) / 2
+	}
+	if s.MeanTime < base*regressionFactor {
+		return ""
+	}
+	return fmt.Sprintf("Mean time %.1f× the baseline of %d earlier run(s): %s → %s", s.MeanTime/base, len(prior), fmtMs(base), fmtMs(s.MeanTime))
+}
+
+// queryTables resolves the relations referenced by a parsed query to collected
+// table statistics of the current database. Unqualified names match any schema.
+func queryTables(parsed sqlparse.Statement, res collect.Result) []collect.TableStat {
+	var out []collect.TableStat
+	seen := map[string]bool{}
+	for _, ref := range parsed.Tables {
+		for _, t := range res.Tables {
+			if !sameDB(t.Database, res.ConnInfo.CurrentDB) || !ref.Matches(t.Schema, t.Name) {
+				continue
+			}
+			key := t.Schema + "." + t.Name
+			if !seen[key] {
+				seen[key] = true
+				out = append(out, t)
+			}
+		}
+	}
+	return out
+}
+
+// queryShape summarizes a parsed query: read or write, relations, joins and
+// select list and LIMIT remarks.
+func queryShape(parsed sqlparse.Statement) string {
+	if parsed.Command == "" {
+		return ""
+	}
+	kind := "Read"
+	if parsed.Writes() {
+		kind = "Write"
+	}
+	parts := []string{fmt.Sprintf("%s (%s)", kind, strings.ToUpper(parsed.Command))}
+	switch n := len(parsed.Tables); n {
+	case 0:
+	case 1:
+		parts = append(parts, "1 table")
+	default:
+		parts = append(parts, fmt.Sprintf("%d tables", n))
+	}
+	if parsed.Joins > 0 {
+		parts = append(parts, fmt.Sprintf("%d join(s)", parsed.Joins))
+	}
+	if parsed.SelectStar {
+		parts = append(parts, "SELECT *")
+	}
+	if parsed.Command == "select" && !parsed.Limit {
+		parts = append(parts, "no LIMIT")
+	}
+	return strings.Join(parts, " · ")
+}
+
+// sameDB treats an empty database name as the current database.
+func sameDB(a, b string) bool {
+	return a == "" || b == "" || a == b
+}
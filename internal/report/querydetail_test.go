@@ -0,0 +1,109 @@
+package report
+
+import (
+	"testing"
+
+	"github.com/koltyakov/pghealth/internal/archive"
+	"github.com/koltyakov/pghealth/internal/collect"
+	"github.com/koltyakov/pghealth/internal/sqlparse"
+)
+
+// TestQueryTables verifies relation extraction and matching against collected tables.
+func TestQueryTables(t *testing.T) {
+	res := collect.Result{
+		ConnInfo: collect.ConnInfo{CurrentDB: "app"},
+		Tables: []collect.TableStat{
+			{Schema: "public", Name: "orders"},
+			{Schema: "sales", Name: "orders"},
+			{Schema: "public", Name: "Users"},
+			{Database: "other", Schema: "public", Name: "items"},
+			{Schema: "public", Name: "items"},
+		},
+	}
+
+	tests := []struct {
+		query    string
+		expected []string
+	}{
+		{"SELECT * FROM sales.orders WHERE id = $1", []string{"sales.orders"}},
+		{"select * from orders o join items i on i.order_id = o.id", []string{"public.orders", "sales.orders", "public.items"}},
+		{`UPDATE "Users" SET name = $1`, []string{"public.Users"}},
+		{"SELECT 1", nil},
+	}
+	for _, tt := range tests {
+		got := queryTables(sqlparse.Parse(tt.query), res)
+		if len(got) != len(tt.expected) {
+			t.Errorf("queryTables(%q) = %v, expected %v", tt.query, got, tt.expected)
+			continue
+		}
+		for i, tbl := range got {
+			if name := tbl.Schema + "." + tbl.Name; name != tt.expected[i] {
+				t.Errorf("queryTables(%q)[%d] = %s, expected %s", tt.query, i, name, tt.expected[i])
+			}
+		}
+	}
+}
+
+// TestQueryRegression verifies the baseline excludes the current run and uses the median.
+func TestQueryRegression(t *testing.T) {
+	hist := []archive.QueryPoint{
+		{RunID: "now", MeanTime: 40},
+		{RunID: "r3", MeanTime: 10},
+		{RunID: "r2", MeanTime: 100},
+		{RunID: "r1", MeanTime: 12},
+	}
+	tests := []struct {
+		mean      float64
+		hist      []archive.QueryPoint
+		regressed bool
+	}{
+		{40, hist, true},      // baseline 12ms
+		{15, hist, false},     // within factor
+		{40, hist[:1], false}, // only the current run
+		{0, hist, false},
+	}
+	for _, tt := range tests {
+		got := queryRegression(collect.Statement{MeanTime: tt.mean}, tt.hist, "now")
+		if (got != "") != tt.regressed {
+			t.Errorf("queryRegression(mean=%v) = %q, expected regressed=%v", tt.mean, got, tt.regressed)
+		}
+	}
+}
+
+// TestQueryShape verifies the classification line of query drill-downs.
+func TestQueryShape(t *testing.T) {
+	tests := []struct {
+		query    string
+		expected string
+	}{
+		{"SELECT * FROM orders o JOIN items i ON i.order_id = o.id", "Read (SELECT) · 2 tables · 1 join(s) · SELECT * · no LIMIT"},
+		{"select id from orders where id = $1 limit $2", "Read (SELECT) · 1 table"},
+		{"UPDATE orders SET status = $1 WHERE id = $2", "Write (UPDATE) · 1 table"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := queryShape(sqlparse.Parse(tt.query)); got != tt.expected {
+			t.Errorf("queryShape(%q) = %q, expected %q", tt.query, got, tt.expected)
+		}
+	}
+}
+
+// TestBuildQueryDetailsDedup verifies a statement ranked in several lists gets
+// one section listing all its ranks, the CPU and I/O lists included.
+func TestBuildQueryDetailsDedup(t *testing.T) {
+	var res collect.Result
+	res.Statements.TopByTotalTime = []collect.Statement{{QueryID: 1, Query: "select 1"}}
+	res.Statements.TopByCalls = []collect.Statement{{QueryID: 2, Query: "select 2"}, {QueryID: 1, Query: "select 1"}}
+	res.Statements.TopByCPU = []collect.Statement{{QueryID: 1, Query: "select 1"}}
+	res.Statements.TopByIO = []collect.Statement{{QueryID: 3, Query: "select 3"}}
+	details, anchors := buildQueryDetails(res, nil, "")
+	if len(details) != 3 || len(anchors) != 3 {
+		t.Fatalf("details = %+v", details)
+	}
+	if r := details[0].Ranks; len(r) != 3 || r[1] != "#2 by calls" || r[2] != "#1 by CPU time" {
+		t.Errorf("ranks of query 1 = %v", r)
+	}
+	if d := details[2]; d.ID != "query-3" || d.Ranks[0] != "#1 by I/O time" {
+		t.Errorf("I/O detail = %+v", d)
+	}
+}
@@ -0,0 +1,66 @@
+package report
+
+import (
+	"strings"
+
+	"github.com/koltyakov/pghealth/internal/collect"
+)
+
+// settingGroup is a tuning category of the settings table.
+type settingGroup struct {
+	Name     string
+	Settings []collect.Setting
+}
+
+// settingCategories maps pg_settings category prefixes to tuning groups, in
+// display order. Categories not listed fall into "Other".
+var settingCategories = []struct {
+	group    string
+	prefixes []string
+}{
+	{"Memory", []string{"Resource Usage / Memory"}},
+	{"WAL and checkpoints", []string{"Write-Ahead Log"}},
+	{"Autovacuum", []string{"Autovacuum", "Vacuuming / Automatic Vacuuming"}},
+	{"Planner", []string{"Query Tuning"}},
+	{"Connections", []string{"Connections and Authentication"}},
+}
+
+// settingGroups groups settings by tuning category, keeping their order
+// within a group and dropping empty groups.
+func settingGroups(settings []collect.Setting) []settingGroup {
+	groups := make([]settingGroup, len(settingCategories)+1)
+	for i, c := range settingCategories {
+		groups[i].Name = c.group
+	}
+	other := len(settingCategories)
+	groups[other].Name = "Other"
+
+	for _, s := range settings {
+		i := settingCategory(s.Category)
+		if i < 0 {
+			i = other
+		}
+		groups[i].Settings = append(groups[i].Settings, s)
+	}
+
+	out := groups[:0]
+	for _, g := range groups {
+		if len(g.Settings) > 0 {
+			out = append(out, g)
+		}
+	}
+	return out
+}
+
+// settingCategory returns the settingCategories index of a pg_settings
+// category, or -1.
+func settingCategory(category string) int {
+	for i, c := range settingCategories {
+		for _, p := range c.prefixes {
+			if strings.HasPrefix(category, p) {
+				return i
+			}
+		}
+	}
+	return -1
+}
@@ -0,0 +1,48 @@
+package report
+
+import (
+	"testing"
+
+	"github.com/koltyakov/pghealth/internal/collect"
+)
+
+// TestSettingGroups verifies settings are grouped by tuning category in
+// display order, with unknown categories under "Other".
+func TestSettingGroups(t *testing.T) {
+	settings := []collect.Setting{
+		{Name: "autovacuum", Category: "Autovacuum"},
+		{Name: "autovacuum_naptime", Category: "Vacuuming / Automatic Vacuuming"},
+		{Name: "max_wal_size", Category: "Write-Ahead Log / Checkpoints"},
+		{Name: "random_page_cost", Category: "Query Tuning / Planner Cost Constants"},
+		{Name: "shared_buffers", Category: "Resource Usage / Memory"},
+		{Name: "track_io_timing", Category: "Statistics / Cumulative Query and Index Statistics"},
+		{Name: "work_mem", Category: "Resource Usage / Memory"},
+	}
+	groups := settingGroups(settings)
+
+	want := []struct {
+		name     string
+		settings []string
+	}{
+		{"Memory", []string{"shared_buffers", "work_mem"}},
+		{"WAL and checkpoints", []string{"max_wal_size"}},
+		{"Autovacuum", []string{"autovacuum", "autovacuum_naptime"}},
+		{"Planner", []string{"random_page_cost"}},
+		{"Other", []string{"track_io_timing"}},
+	}
+	if len(groups) != len(want) {
+		t.Fatalf("groups = %+v, want %d groups", groups, len(want))
+	}
+	for i, w := range want {
+		g := groups[i]
+		if g.Name != w.name || len(g.Settings) != len(w.settings) {
+			t.Errorf("group %d = %s with %d settings, want %s with %d", i, g.Name, len(g.Settings), w.name, len(w.settings))
+			continue
+		}
+		for j, name := range w.settings {
+			if g.Settings[j].Name != name {
+				t.Errorf("%s[%d] = %s, want %s", g.Name, j, g.Settings[j].Name, name)
+			}
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package report
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/koltyakov/pghealth/internal/collect"
+)
+
+// slotCleanupScript is the drop list of the replication slots worth dropping,
+// most urgent first, with every statement commented out behind its risk so
+// nothing runs by accident when pasted.
+func slotCleanupScript(slots []collect.ReplicationSlot) string {
+	drop := collect.DropCandidates(slots)
+	if len(drop) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("-- Replication slots to drop, most urgent first. A dropped slot cannot be restored:\n")
+	b.WriteString("-- confirm its consumer is gone, then uncomment the statement.\n")
+	for i, sl := range drop {
+		state := "inactive"
+		switch {
+		case sl.Lost():
+			state = "lost"
+			if sl.Invalidated != "" {
+				state += ": " + sl.Invalidated
+			}
+		case sl.InactiveSeconds >= 0:
+			state = "inactive for " + humanizeDuration(seconds(sl.InactiveSeconds))
+		}
+		kind := sl.Type
+		if sl.Type == "logical" && sl.Plugin != "" {
+			kind += " " + sl.Plugin + " on " + sl.Database
+		}
+		fmt.Fprintf(&b, "\n-- %d. %s (%s, %s, %s of WAL retained)\n", i+1, sl.Name, kind, state, fmtBytesStr(sl.RetainedBytes))
+		fmt.Fprintf(&b, "--    Risk: %s.\n", sl.DropRisk())
+		fmt.Fprintf(&b, "-- %s\n", sl.DropCommand())
+	}
+	return b.String()
+}
@@ -0,0 +1,39 @@
+package report
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/koltyakov/pghealth/internal/collect"
+)
+
+// TestSlotCleanupScript verifies lost slots come first, then inactive slots
+// by retained WAL, and every drop statement is commented out.
+func TestSlotCleanupScript(t *testing.T) {
+	slots := []collect.ReplicationSlot{
+		{Name: "busy", Type: "physical", Active: true, RetainedBytes: 50 << 30},
+		{Name: "old_standby", Type: "physical", RetainedBytes: 2 << 30, InactiveSeconds: -1},
+		{Name: "cdc", Type: "logical", Plugin: "pgoutput", Database: "app", RetainedBytes: 8 << 30, InactiveSeconds: 7200},
+		{Name: "gone", Type: "physical", WALStatus: "lost", InactiveSeconds: -1},
+		{Name: "small", Type: "physical", RetainedBytes: 10 << 20, InactiveSeconds: -1},
+		{Name: "tmp", Type: "physical", Temporary: true, RetainedBytes: 8 << 30},
+	}
+	script := slotCleanupScript(slots)
+	var order []string
+	for _, line := range strings.Split(script, "\n") {
+		if strings.HasPrefix(line, "-- SELECT pg_drop_replication_slot('") {
+			order = append(order, strings.TrimSuffix(strings.TrimPrefix(line, "-- SELECT pg_drop_replication_slot('"), "');"))
+		} else if strings.Contains(line, "pg_drop_replication_slot") {
+			t.Errorf("uncommented statement: %q", line)
+		}
+	}
+	if got, want := strings.Join(order, ","), "gone,cdc,old_standby"; got != want {
+		t.Errorf("drop order = %s, want %s", got, want)
+	}
+	if !strings.Contains(script, "cdc (logical pgoutput on app, inactive for 2h") {
+		t.Errorf("script missing logical slot details:\n%s", script)
+	}
+	if slotCleanupScript(slots[:1]) != "" {
+		t.Error("an active slot should not be listed")
+	}
+}
@@ -0,0 +1,233 @@
+package report
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/koltyakov/pghealth/internal/analyze"
+	"github.com/koltyakov/pghealth/internal/collect"
+)
+
+// Summary output constants.
+const (
+	// summaryFilePerms is the file permissions for summary files.
+	summaryFilePerms = 0o644
+
+	// maxAnnotationLen caps annotation messages; CI runners truncate long ones anyway.
+	maxAnnotationLen = 400
+)
+
+// severityBadges maps finding severities to Markdown badges used in step summaries.
+var severityBadges = map[string]string{
+	analyze.SeverityWarning: "🔴 warn",
+	analyze.SeverityRec:     "🟡 rec",
+	analyze.SeverityInfo:    "🔵 info",
+}
+
+// WriteGitHubSummary appends a Markdown step summary to path. GitHub Actions
+// renders the file referenced by GITHUB_STEP_SUMMARY on the run page; the same
+// Markdown works as a GitLab job artifact or merge request note.
+//
+// The file is opened in append mode because step summary files are shared
+// between steps of the same job.
+func WriteGitHubSummary(path string, res collect.Result, a analyze.Analysis, meta collect.Meta) error {
+	if path == "" {
+		return fmt.Errorf("output path cannot be empty")
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, summaryFilePerms)
+	if err != nil {
+		return fmt.Errorf("open summary file: %w", err)
+	}
+	if err := writeSummaryMarkdown(f, res, a, meta); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// writeSummaryMarkdown renders the summary Markdown into w.
+func writeSummaryMarkdown(w io.Writer, res collect.Result, a analyze.Analysis, meta collect.Meta) error {
+	return writeMarkdown(w, "PostgreSQL health check", res, a, meta)
+}
+
+// writeMarkdown renders the findings Markdown under heading.
+func writeMarkdown(w io.Writer, heading string, res collect.Result, a analyze.Analysis, meta collect.Meta) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "## %s\n\n", mdEscape(heading))
+
+	target := res.ConnInfo.CurrentDB
+	if target == "" {
+		target = "n/a"
+	}
+	fmt.Fprintf(&b, "Database: `%s`", mdEscape(target))
+	if v := shortServerVersion(res.ConnInfo.Version); v != "" {
+		fmt.Fprintf(&b, " · Server: %s", mdEscape(v))
+	}
+	if res.ConnInfo.Host != "" {
+		role := "primary"
+		if res.ConnInfo.InRecovery {
+			role = "standby"
+		}
+		fmt.Fprintf(&b, " · Host: `%s` (%s)", res.ConnInfo.Host, role)
+	}
+	if w := a.Workload.Label(); w != "" {
+		fmt.Fprintf(&b, " · Workload: %s", w)
+	}
+	if meta.Version != "" {
+		fmt.Fprintf(&b, " · pghealth %s", mdEscape(meta.Version))
+	}
+	for _, l := range meta.LabelPairs() {
+		fmt.Fprintf(&b, " · `%s`", l)
+	}
+	b.WriteString("\n\n")
+
+	for _, line := range a.Summary {
+		fmt.Fprintf(&b, "- %s\n", mdEscape(line))
+	}
+	if len(a.Summary) > 0 {
+		b.WriteString("\n")
+	}
+
+	fmt.Fprintf(&b, "| Severity | Count |\n|---|---|\n")
+	fmt.Fprintf(&b, "| %s | %d |\n", severityBadges[analyze.SeverityWarning], len(a.Warnings))
+	fmt.Fprintf(&b, "| %s | %d |\n", severityBadges[analyze.SeverityRec], len(a.Recommendations))
+	fmt.Fprintf(&b, "| %s | %d |\n\n", severityBadges[analyze.SeverityInfo], len(a.Infos))
+
+	assigned := hasAssignments(a)
+	writeFindings := func(title string, list []analyze.Finding) {
+		if len(list) == 0 {
+			return
+		}
+		fmt.Fprintf(&b, "### %s\n\n", title)
+		b.WriteString("| Severity | Finding | Details | Action |")
+		if assigned {
+			b.WriteString(" Assigned |\n|---|---|---|---|---|\n")
+		} else {
+			b.WriteString("\n|---|---|---|---|\n")
+		}
+		for _, f := range list {
+			badge := severityBadges[f.Severity]
+			if badge == "" {
+				badge = mdEscape(f.Severity)
+			}
+			if f.Confidence != "" {
+				badge += "<br><sub>impact " + f.EstimatedImpact + ", confidence " + f.Confidence
+				if f.EffortKind != "" {
+					badge += ", " + f.EffortKind
+				}
+				badge += "</sub>"
+			}
+			title := mdEscape(f.Title)
+			if f.Code != "" {
+				title += " <sub>`" + mdEscape(f.Code) + "`</sub>"
+			}
+			action := mdEscape(f.Action)
+			if f.Runbook != "" {
+				action += " [Runbook](" + f.Runbook + ")"
+			}
+			if f.Docs != "" {
+				action += " [Docs](" + f.Docs + ")"
+			}
+			fmt.Fprintf(&b, "| %s | %s | %s | %s |", badge, title, mdEscape(f.Description), action)
+			if assigned {
+				fmt.Fprintf(&b, " %s |", mdEscape(strings.Join(f.Owners, ", ")))
+			}
+			b.WriteString("\n")
+		}
+		b.WriteString("\n")
+	}
+	writeFindings("Warnings", a.Warnings)
+	writeFindings("Recommendations", a.Recommendations)
+
+	if len(a.Infos) > 0 {
+		b.WriteString("<details><summary>Informational findings</summary>\n\n")
+		for _, f := range a.Infos {
+			fmt.Fprintf(&b, "- **%s**: %s\n", mdEscape(f.Title), mdEscape(f.Description))
+		}
+		b.WriteString("\n</details>\n\n")
+	}
+	if len(res.Errors) > 0 {
+		fmt.Fprintf(&b, "> %d collection error(s); some sections may be incomplete.\n\n", len(res.Errors))
+	}
+	if len(res.Skipped) > 0 {
+		fmt.Fprintf(&b, "> Collection truncated at %.0f%%: **partial report**.\n", res.Completion())
+		for _, g := range skippedByReason(res.Skipped) {
+			names := make([]string, len(g.Names))
+			for i, n := range g.Names {
+				names[i] = "`" + n + "`"
+			}
+			fmt.Fprintf(&b, "> Skipped collectors (%s): %s.\n", g.Reason, strings.Join(names, ", "))
+		}
+		b.WriteString("\n")
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// WriteAnnotations prints workflow commands so CI runners surface findings as
+// annotations: warnings become ::warning and recommendations ::notice.
+// Informational findings are not annotated.
+func WriteAnnotations(w io.Writer, a analyze.Analysis) error {
+	emit := func(level string, f analyze.Finding) error {
+		msg := f.Description
+		if f.Action != "" {
+			msg += " — " + f.Action
+		}
+		_, err := fmt.Fprintf(w, "::%s title=%s::%s\n", level, annotationEscape(f.Title, true), annotationEscape(truncateRunes(msg, maxAnnotationLen), false))
+		return err
+	}
+	for _, f := range a.Warnings {
+		if err := emit("warning", f); err != nil {
+			return err
+		}
+	}
+	for _, f := range a.Recommendations {
+		if err := emit("notice", f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// annotationEscape escapes data for GitHub workflow commands. Properties
+// (title=...) additionally need ':' and ',' encoded.
+func annotationEscape(s string, property bool) string {
+	r := strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A")
+	s = r.Replace(s)
+	if property {
+		s = strings.NewReplacer(":", "%3A", ",", "%2C").Replace(s)
+	}
+	return s
+}
+
+// mdEscape makes a string safe to place inside a Markdown table cell.
+func mdEscape(s string) string {
+	s = strings.ReplaceAll(s, "\r", "")
+	s = strings.ReplaceAll(s, "\n", " ")
+	s = strings.ReplaceAll(s, "|", "\\|")
+	return strings.TrimSpace(s)
+}
+
+// shortServerVersion trims version() output to the "PostgreSQL 16.2" prefix.
+func shortServerVersion(v string) string {
+	v = strings.TrimSpace(v)
+	if i := strings.Index(v, " on "); i > 0 {
+		v = v[:i]
+	}
+	if i := strings.Index(v, ","); i > 0 {
+		v = v[:i]
+	}
+	return v
+}
+
+// truncateRunes shortens s to at most n runes, appending an ellipsis when cut.
+func truncateRunes(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n]) + "…"
+}
@@ -0,0 +1,62 @@
+package report
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/koltyakov/pghealth/internal/analyze"
+	"github.com/koltyakov/pghealth/internal/collect"
+)
+
+// TestWriteSummaryMarkdown verifies badges, counts and table escaping.
+func TestWriteSummaryMarkdown(t *testing.T) {
+	a := analyze.Analysis{
+		Warnings: []analyze.Finding{{Title: "Low cache hit", Severity: analyze.SeverityWarning, Code: "cache-hit", Description: "a | b", Confidence: analyze.LevelMedium, EstimatedImpact: analyze.LevelHigh}},
+		Workload: analyze.Workload{Class: analyze.WorkloadOLTP},
+		Summary:  []string{"Overall grade B (88/100): 1 warning and 0 recommendations."},
+	}
+	var buf bytes.Buffer
+	if err := writeSummaryMarkdown(&buf, collect.Result{}, a, collect.Meta{Version: "v1"}); err != nil {
+		t.Fatalf("writeSummaryMarkdown failed: %v", err)
+	}
+	out := buf.String()
+	for _, want := range []string{"| 🔴 warn | 1 |", "### Warnings", "`cache-hit`", `a \| b`, "impact high, confidence medium", "- Overall grade B (88/100): 1 warning and 0 recommendations.\n", "pghealth v1", "Workload: OLTP"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("summary missing %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "### Recommendations") {
+		t.Error("empty recommendations section should be omitted")
+	}
+
+	buf.Reset()
+	res := collect.Result{Skipped: []collect.SkippedCollector{{Name: "plans", Reason: "soft deadline of 4m0s reached"}, {Name: "wal", Reason: "soft deadline of 4m0s reached"}}}
+	if err := writeSummaryMarkdown(&buf, res, analyze.Analysis{}, collect.Meta{}); err != nil {
+		t.Fatal(err)
+	}
+	if want := "> Skipped collectors (soft deadline of 4m0s reached): `plans`, `wal`."; !strings.Contains(buf.String(), want) {
+		t.Errorf("summary missing %q:\n%s", want, buf.String())
+	}
+	if want := "> Collection truncated at "; !strings.Contains(buf.String(), want) {
+		t.Errorf("summary missing %q:\n%s", want, buf.String())
+	}
+}
+
+// TestWriteAnnotations verifies workflow command formatting and escaping.
+func TestWriteAnnotations(t *testing.T) {
+	a := analyze.Analysis{
+		Warnings:        []analyze.Finding{{Title: "Disk: 90%, full", Description: "line1\nline2"}},
+		Recommendations: []analyze.Finding{{Title: "Add index", Description: "seq scans", Action: "CREATE INDEX"}},
+		Infos:           []analyze.Finding{{Title: "info"}},
+	}
+	var buf bytes.Buffer
+	if err := WriteAnnotations(&buf, a); err != nil {
+		t.Fatalf("WriteAnnotations failed: %v", err)
+	}
+	expected := "::warning title=Disk%3A 90%25%2C full::line1%0Aline2\n" +
+		"::notice title=Add index::seq scans — CREATE INDEX\n"
+	if buf.String() != expected {
+		t.Errorf("WriteAnnotations() = %q, expected %q", buf.String(), expected)
+	}
+}
@@ -0,0 +1,60 @@
+package report
+
+import (
+	"sort"
+
+	"github.com/koltyakov/pghealth/internal/collect"
+)
+
+// tablespaceListed caps the largest relations listed per tablespace.
+const tablespaceListed = 5
+
+// tablespaceRow is a tablespace of the placement table with the largest
+// collected tables and indexes it holds.
+type tablespaceRow struct {
+	collect.Tablespace
+	Relations []placedRelation
+	More      int // relations held beyond Relations
+}
+
+// placedRelation is a table or index held by a tablespace.
+type placedRelation struct {
+	Kind      string // "table" or "index"
+	Name      string
+	SizeBytes int64
+	Scans     int64
+}
+
+// tablespacePlacement lists the tablespaces with the largest collected
+// tables and indexes on each, or nil when the cluster only has pg_default.
+func tablespacePlacement(res collect.Result) []tablespaceRow {
+	if len(res.Tablespaces) < 2 {
+		return nil
+	}
+	name := res.ObjectNamer()
+	held := map[string][]placedRelation{}
+	for _, t := range res.Tables {
+		ts := res.EffectiveTablespace(t.Database, t.Tablespace)
+		held[ts] = append(held[ts], placedRelation{"table", name(t.Database, t.Schema, t.Name), t.SizeBytes, t.SeqScans + t.IdxScans})
+	}
+	for _, ix := range res.Indexes {
+		ts := res.EffectiveTablespace(ix.Database, ix.Tablespace)
+		held[ts] = append(held[ts], placedRelation{"index", name(ix.Database, ix.Schema, ix.Name), ix.SizeBytes, ix.Scans})
+	}
+	out := make([]tablespaceRow, 0, len(res.Tablespaces))
+	for _, ts := range res.Tablespaces {
+		rels := held[ts.Name]
+		sort.Slice(rels, func(i, j int) bool {
+			if rels[i].SizeBytes != rels[j].SizeBytes {
+				return rels[i].SizeBytes > rels[j].SizeBytes
+			}
+			return rels[i].Name < rels[j].Name
+		})
+		row := tablespaceRow{Tablespace: ts, Relations: rels}
+		if len(rels) > tablespaceListed {
+			row.Relations, row.More = rels[:tablespaceListed], len(rels)-tablespaceListed
+		}
+		out = append(out, row)
+	}
+	return out
+}
@@ -0,0 +1,52 @@
+package report
+
+import (
+	"testing"
+
+	"github.com/koltyakov/pghealth/internal/collect"
+)
+
+// TestTablespacePlacement verifies relations are listed under the tablespace
+// holding them, unplaced ones under their database's default, largest first.
+func TestTablespacePlacement(t *testing.T) {
+	res := collect.Result{
+		ConnInfo:    collect.ConnInfo{CurrentDB: "app"},
+		DBs:         []collect.Database{{Name: "app", Tablespaces: "pg_default"}},
+		Tablespaces: []collect.Tablespace{{Name: "fast", Location: "/mnt/nvme"}, {Name: "pg_default"}},
+		Tables: []collect.TableStat{
+			{Database: "app", Schema: "public", Name: "events", SizeBytes: 10},
+			{Database: "app", Schema: "public", Name: "orders", SizeBytes: 30},
+			{Database: "app", Schema: "public", Name: "hot", SizeBytes: 20, Tablespace: "fast"},
+		},
+		Indexes: []collect.IndexStat{{Database: "app", Schema: "public", Name: "orders_pkey", SizeBytes: 5}},
+	}
+	rows := tablespacePlacement(res)
+	want := map[string][]string{
+		"fast":       {"public.hot"},
+		"pg_default": {"public.orders", "public.events", "public.orders_pkey"},
+	}
+	if len(rows) != len(want) {
+		t.Fatalf("got %d tablespaces, want %d", len(rows), len(want))
+	}
+	for _, row := range rows {
+		var got []string
+		for _, r := range row.Relations {
+			got = append(got, r.Name)
+		}
+		if len(got) != len(want[row.Name]) {
+			t.Errorf("%s holds %v, want %v", row.Name, got, want[row.Name])
+			continue
+		}
+		for i := range got {
+			if got[i] != want[row.Name][i] {
+				t.Errorf("%s holds %v, want %v", row.Name, got, want[row.Name])
+				break
+			}
+		}
+	}
+
+	res.Tablespaces = res.Tablespaces[1:]
+	if rows := tablespacePlacement(res); rows != nil {
+		t.Errorf("only pg_default: got %d rows, want none", len(rows))
+	}
+}
@@ -0,0 +1,158 @@
+package report
+
+import (
+	"fmt"
+	"html/template"
+	"strings"
+
+	"github.com/koltyakov/pghealth/internal/collect"
+)
+
+// Treemap geometry in SVG user units; the SVG scales to the page width.
+const (
+	treemapWidth  = 1000.0
+	treemapHeight = 480.0
+
+	// treemapHeader is the label strip on top of a database or schema.
+	treemapHeader = 16.0
+
+	// treemapPad insets children from the border of their parent.
+	treemapPad = 2.0
+
+	// treemapLabelWidth and treemapLabelHeight are the smallest rectangle that
+	// gets a text label; smaller ones rely on the hover title.
+	treemapLabelWidth  = 60.0
+	treemapLabelHeight = 14.0
+)
+
+// treemapFill colors the rectangles by node kind.
+var treemapFill = map[string]string{
+	collect.SizeDatabase: "#e5e7eb",
+	collect.SizeSchema:   "#f3f4f6",
+	collect.SizeTable:    "#93c5fd",
+	collect.SizeIndex:    "#fcd34d",
+	collect.SizeOther:    "#d1d5db",
+}
+
+// rect is an area of the treemap.
+type rect struct{ x, y, w, h float64 }
+
+// treemapSVG renders the storage hierarchy as a squarified treemap: databases
+// contain their schemas, schemas their tables and indexes. Empty without a
+// tree.
+func treemapSVG(root *collect.SizeNode) template.HTML {
+	if root == nil || root.Bytes <= 0 {
+		return ""
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg class="treemap" viewBox="0 0 %.0f %.0f" width="100%%" role="img" aria-label="Storage treemap">`, treemapWidth, treemapHeight)
+	layoutTreemap(&b, root.Children, rect{0, 0, treemapWidth, treemapHeight}, "")
+	b.WriteString("</svg>")
+	return template.HTML(b.String())
+}
+
+// layoutTreemap lays out nodes in r and draws them, nesting the children of
+// databases and schemas that have room for their header. path prefixes the
+// hover titles with the enclosing names.
+func layoutTreemap(b *strings.Builder, nodes []collect.SizeNode, r rect, path string) {
+	sizes := make([]float64, len(nodes))
+	for i, n := range nodes {
+		sizes[i] = float64(n.Bytes)
+	}
+	for i, cell := range squarify(sizes, r) {
+		if cell.w <= 0 || cell.h <= 0 {
+			continue
+		}
+		n := nodes[i]
+		name := n.Name
+		if n.Kind == collect.SizeOther {
+			name = "other"
+		}
+		title := path + name
+		fmt.Fprintf(b, `<g><title>%s (%s) — %s</title>`, template.HTMLEscapeString(title), n.Kind, fmtBytesStr(n.Bytes))
+		fmt.Fprintf(b, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" fill="%s" stroke="#fff" stroke-width="1"/>`,
+			cell.x, cell.y, cell.w, cell.h, treemapFill[n.Kind])
+		nested := len(n.Children) > 0 && cell.h > 2*treemapHeader && cell.w > 2*treemapLabelWidth
+		if cell.w >= treemapLabelWidth && cell.h >= treemapLabelHeight {
+			label := fmt.Sprintf("%s %s", name, fmtBytesStr(n.Bytes))
+			fmt.Fprintf(b, `<text x="%.1f" y="%.1f" font-size="11" fill="#111827">%s</text>`,
+				cell.x+3, cell.y+12, template.HTMLEscapeString(fitLabel(label, cell.w)))
+		}
+		b.WriteString("</g>")
+		if nested {
+			inner := rect{cell.x + treemapPad, cell.y + treemapHeader, cell.w - 2*treemapPad, cell.h - treemapHeader - treemapPad}
+			layoutTreemap(b, n.Children, inner, title+".")
+		}
+	}
+}
+
+// fitLabel shortens a label to the characters that fit in width at the
+// treemap font size, the ellipsis included.
+func fitLabel(s string, width float64) string {
+	return truncateRunes(s, max(int((width-6)/6.5)-1, 1))
+}
+
+// squarify splits r into rectangles with areas proportional to sizes, keeping
+// them close to squares (Bruls, Huizing and van Wijk); sizes sorted largest
+// first give the best shapes. Sizes from the first non-positive one on get
+// empty rectangles.
+func squarify(sizes []float64, r rect) []rect {
+	out := make([]rect, len(sizes))
+	var total float64
+	n := 0
+	for _, s := range sizes {
+		if s <= 0 {
+			break
+		}
+		total += s
+		n++
+	}
+	if total <= 0 || r.w <= 0 || r.h <= 0 {
+		return out
+	}
+	areas := make([]float64, n)
+	for i := range areas {
+		areas[i] = sizes[i] * r.w * r.h / total
+	}
+	for i := 0; i < n; {
+		side := min(r.w, r.h)
+		j := i + 1
+		for j < n && worstRatio(areas[i:j+1], side) <= worstRatio(areas[i:j], side) {
+			j++
+		}
+		var sum float64
+		for _, a := range areas[i:j] {
+			sum += a
+		}
+		if r.w >= r.h {
+			// Column along the left edge
+			thick, y := sum/r.h, r.y
+			for k, a := range areas[i:j] {
+				out[i+k] = rect{r.x, y, thick, a / thick}
+				y += a / thick
+			}
+			r.x, r.w = r.x+thick, r.w-thick
+		} else {
+			// Row along the top edge
+			thick, x := sum/r.w, r.x
+			for k, a := range areas[i:j] {
+				out[i+k] = rect{x, r.y, a / thick, thick}
+				x += a / thick
+			}
+			r.y, r.h = r.y+thick, r.h-thick
+		}
+		i = j
+	}
+	return out
+}
+
+// worstRatio is the largest aspect ratio of a row of areas laid along side.
+func worstRatio(row []float64, side float64) float64 {
+	var sum, hi float64
+	lo := row[0]
+	for _, a := range row {
+		sum += a
+		hi, lo = max(hi, a), min(lo, a)
+	}
+	return max(side*side*hi/(sum*sum), sum*sum/(side*side*lo))
+}
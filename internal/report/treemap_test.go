@@ -0,0 +1,57 @@
+package report
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/koltyakov/pghealth/internal/collect"
+)
+
+// TestSquarify verifies the rectangles tile the area in proportion to the
+// sizes without overlapping its bounds.
+func TestSquarify(t *testing.T) {
+	r := rect{0, 0, 600, 400}
+	sizes := []float64{6, 6, 4, 3, 2, 2, 1, 0}
+	cells := squarify(sizes, r)
+	var area float64
+	for i, c := range cells {
+		area += c.w * c.h
+		if want := sizes[i] / 24 * r.w * r.h; math.Abs(c.w*c.h-want) > 1e-6 {
+			t.Errorf("cell %d area = %.1f, expected %.1f", i, c.w*c.h, want)
+		}
+		if c.x < 0 || c.y < 0 || c.x+c.w > r.w+1e-6 || c.y+c.h > r.h+1e-6 {
+			t.Errorf("cell %d %+v outside %+v", i, c, r)
+		}
+	}
+	if math.Abs(area-r.w*r.h) > 1e-6 {
+		t.Errorf("cells cover %.1f, expected %.1f", area, r.w*r.h)
+	}
+	// The example of the paper: the two largest stack in the left half
+	if cells[0] != (rect{0, 0, 300, 200}) || cells[1] != (rect{0, 200, 300, 200}) {
+		t.Errorf("first column = %+v, %+v", cells[0], cells[1])
+	}
+}
+
+// TestTreemapSVG verifies nested databases, schemas and relations render with
+// escaped names and hover titles, and nothing renders without a tree.
+func TestTreemapSVG(t *testing.T) {
+	if treemapSVG(nil) != "" {
+		t.Error("expected no treemap without a size tree")
+	}
+	tree := &collect.SizeNode{Kind: collect.SizeCluster, Bytes: 3 << 30, Children: []collect.SizeNode{
+		{Name: "app", Kind: collect.SizeDatabase, Bytes: 3 << 30, Children: []collect.SizeNode{
+			{Name: "public", Kind: collect.SizeSchema, Bytes: 3 << 30, Children: []collect.SizeNode{
+				{Name: "orders<x>", Kind: collect.SizeTable, Bytes: 2 << 30},
+				{Name: "orders_pkey", Kind: collect.SizeIndex, Bytes: 1 << 30},
+			}},
+		}},
+	}}
+	svg := string(treemapSVG(tree))
+	for _, want := range []string{`<svg class="treemap"`, "<title>app.public.orders&lt;x&gt; (table) — 2.00 GB</title>",
+		"<title>app.public.orders_pkey (index)", `fill="#fcd34d"`, "</svg>"} {
+		if !strings.Contains(svg, want) {
+			t.Errorf("treemap missing %q", want)
+		}
+	}
+}
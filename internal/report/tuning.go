@@ -0,0 +1,23 @@
+package report
+
+import (
+	"strings"
+
+	"github.com/koltyakov/pghealth/internal/analyze"
+)
+
+// tuningScript renders the SQL of the configuration advice as one script to
+// review and run as a superuser; empty without advice.
+func tuningScript(a analyze.Analysis) string {
+	if len(a.Tuning) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("-- Configuration changes advised by the findings. Review each one, then run as a superuser.\n")
+	b.WriteString("-- Role settings apply to sessions opened afterwards.\n")
+	for _, stmt := range a.Tuning {
+		b.WriteString(stmt)
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
@@ -0,0 +1,76 @@
+// Package restore runs the restore verification hook: a command that
+// reports whether backups can actually be restored, typically by reading
+// the result of a nightly restore-validation job. Its exit status and
+// output go into the report next to the backup and archiving checks.
+package restore
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/koltyakov/pghealth/internal/collect"
+)
+
+const (
+	// DefaultTimeout bounds the hook unless -verify-restore-timeout is set.
+	DefaultTimeout = 10 * time.Minute
+
+	// maxOutput limits the output kept for the report; the tail is kept as
+	// tools print their verdict last.
+	maxOutput = 16 << 10
+
+	// waitDelay bounds waiting for the output after a timeout.
+	waitDelay = 2 * time.Second
+)
+
+// Verify runs command through the shell and records its exit status and
+// combined output. A command that cannot be started or times out has exit
+// code -1 and Error set.
+func Verify(ctx context.Context, command string) *collect.RestoreCheck {
+	c := &collect.RestoreCheck{Command: command, Started: time.Now()}
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", command)
+	}
+	var out bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &out, &out
+	// Children of the shell may keep the output open after it is killed
+	cmd.WaitDelay = waitDelay
+	err := cmd.Run()
+	c.Duration = time.Since(c.Started)
+	c.Output = tail(out.String(), maxOutput)
+
+	var exitErr *exec.ExitError
+	switch {
+	case ctx.Err() != nil:
+		c.ExitCode, c.Error = -1, "timed out after "+c.Duration.Round(time.Second).String()
+	case errors.As(err, &exitErr):
+		c.ExitCode = exitErr.ExitCode()
+	case err != nil:
+		c.ExitCode, c.Error = -1, err.Error()
+	}
+	return c
+}
+
+// tail returns the last n bytes of s, starting at a line boundary.
+func tail(s string, n int) string {
+	s = strings.TrimRight(s, "\n")
+	if len(s) <= n {
+		return s
+	}
+	cut := len(s) - n
+	if s[cut-1] != '\n' {
+		if i := strings.IndexByte(s[cut:], '\n'); i >= 0 {
+			cut += i + 1
+		}
+	}
+	s = s[cut:]
+	return "...\n" + s
+}
@@ -0,0 +1,50 @@
+package restore
+
+import (
+	"context"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestVerify verifies that exit status, output and timeouts are recorded.
+func TestVerify(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	tests := []struct {
+		command string
+		code    int
+		output  string
+		failed  bool
+	}{
+		{command: "echo restored; echo ok", output: "restored\nok"},
+		{command: "echo 'checksum mismatch' >&2; exit 3", code: 3, output: "checksum mismatch", failed: true},
+		{command: "exec sleep 5", code: -1, failed: true},
+	}
+	for _, tt := range tests {
+		ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+		c := Verify(ctx, tt.command)
+		cancel()
+		if c.ExitCode != tt.code || c.Output != tt.output || c.Passed() == tt.failed {
+			t.Errorf("Verify(%q) = %+v, want exit %d output %q", tt.command, c, tt.code, tt.output)
+		}
+		if tt.code == -1 && !strings.HasPrefix(c.Error, "timed out") {
+			t.Errorf("Verify(%q) error = %q, want a timeout", tt.command, c.Error)
+		}
+	}
+}
+
+// TestTail verifies that long output keeps whole trailing lines.
+func TestTail(t *testing.T) {
+	if got := tail("a\nbb\nccc\n", 100); got != "a\nbb\nccc" {
+		t.Errorf("tail short = %q", got)
+	}
+	if got := tail("aaaa\nbb\nccc\n", 7); got != "...\nbb\nccc" {
+		t.Errorf("tail at a line start = %q", got)
+	}
+	if got := tail("aaaa\nbb\nccc\n", 5); got != "...\nccc" {
+		t.Errorf("tail long = %q", got)
+	}
+}
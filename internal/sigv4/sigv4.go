@@ -0,0 +1,132 @@
+// Package sigv4 signs AWS requests with Signature Version 4 from the standard
+// AWS_* environment variables, for the few AWS APIs pghealth calls without
+// an SDK (RDS IAM tokens, Secrets Manager, S3 listings).
+package sigv4
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const algorithm = "AWS4-HMAC-SHA256"
+
+// Credentials are static or temporary (session) AWS credentials.
+type Credentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+}
+
+// FromEnv reads AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and
+// AWS_SESSION_TOKEN; callers check that the key pair is set.
+func FromEnv() Credentials {
+	return Credentials{
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}
+}
+
+// Region returns AWS_REGION or AWS_DEFAULT_REGION.
+func Region() string {
+	return FirstEnv("AWS_REGION", "AWS_DEFAULT_REGION")
+}
+
+// FirstEnv returns the first non-empty environment variable of names.
+func FirstEnv(names ...string) string {
+	for _, n := range names {
+		if v := os.Getenv(n); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// Sign adds the X-Amz-Date, X-Amz-Security-Token and Authorization headers to
+// req, signing the host and every header already set on req. payload is the
+// hex SHA-256 of the request body (see Hash).
+func Sign(req *http.Request, payload string, creds Credentials, region, service string, now time.Time) {
+	date, amzDate := now.Format("20060102"), now.Format("20060102T150405Z")
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	req.Header.Set("X-Amz-Date", amzDate)
+	if creds.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.SessionToken)
+	}
+
+	names := []string{"host"}
+	for k := range req.Header {
+		names = append(names, strings.ToLower(k))
+	}
+	sort.Strings(names)
+	var headers strings.Builder
+	for _, n := range names {
+		v := req.Header.Get(n)
+		if n == "host" {
+			v = req.URL.Host
+		}
+		headers.WriteString(n + ":" + strings.TrimSpace(v) + "\n")
+	}
+	signed := strings.Join(names, ";")
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	canonical := strings.Join([]string{req.Method, path, req.URL.RawQuery, headers.String(), signed, payload}, "\n")
+	req.Header.Set("Authorization", algorithm+" Credential="+creds.AccessKeyID+"/"+scope+
+		", SignedHeaders="+signed+", Signature="+signature(creds, date, region, service, amzDate, scope, canonical))
+}
+
+// Presign returns the query of a presigned GET of host and path: q with the
+// SigV4 parameters and signature added, valid for expires.
+func Presign(host, path string, q url.Values, creds Credentials, region, service string, expires time.Duration, now time.Time) string {
+	date, amzDate := now.Format("20060102"), now.Format("20060102T150405Z")
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	q.Set("X-Amz-Algorithm", algorithm)
+	q.Set("X-Amz-Credential", creds.AccessKeyID+"/"+scope)
+	q.Set("X-Amz-Date", amzDate)
+	q.Set("X-Amz-Expires", strconv.Itoa(int(expires.Seconds())))
+	q.Set("X-Amz-SignedHeaders", "host")
+	if creds.SessionToken != "" {
+		q.Set("X-Amz-Security-Token", creds.SessionToken)
+	}
+	// SigV4 requires RFC 3986 encoding: spaces as %20, not +
+	query := strings.ReplaceAll(q.Encode(), "+", "%20")
+
+	canonical := strings.Join([]string{"GET", path, query, "host:" + host + "\n", "host", Hash("")}, "\n")
+	return query + "&X-Amz-Signature=" + signature(creds, date, region, service, amzDate, scope, canonical)
+}
+
+// Key derives the SigV4 signing key.
+func Key(secret, date, region, service string) []byte {
+	k := hmacSHA256([]byte("AWS4"+secret), date)
+	k = hmacSHA256(k, region)
+	k = hmacSHA256(k, service)
+	return hmacSHA256(k, "aws4_request")
+}
+
+// Hash returns the hex SHA-256 of s, as SigV4 hashes payloads.
+func Hash(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+// signature signs the canonical request.
+func signature(creds Credentials, date, region, service, amzDate, scope, canonical string) string {
+	toSign := strings.Join([]string{algorithm, amzDate, scope, Hash(canonical)}, "\n")
+	return hex.EncodeToString(hmacSHA256(Key(creds.SecretAccessKey, date, region, service), toSign))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,47 @@
+package sigv4
+
+import (
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestKey verifies signing key derivation against the AWS documentation example.
+func TestKey(t *testing.T) {
+	key := Key("wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", "20120215", "us-east-1", "iam")
+	const expected = "f4780e2d9f65fa895f9c67b32ce1baf0b0d8a43505a000a1a9e090d414db404d"
+	if got := hex.EncodeToString(key); got != expected {
+		t.Errorf("Key() = %s, expected %s", got, expected)
+	}
+}
+
+// TestSign verifies the host, the session token and every header set before
+// signing are signed, and that signing is deterministic.
+func TestSign(t *testing.T) {
+	creds := Credentials{AccessKeyID: "AKID", SecretAccessKey: "secret", SessionToken: "session"}
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	sign := func() *http.Request {
+		req, _ := http.NewRequest(http.MethodGet, "https://bucket.s3.eu-west-1.amazonaws.com/?list-type=2&prefix=a%20b", nil)
+		req.Header.Set("X-Amz-Content-Sha256", Hash(""))
+		Sign(req, Hash(""), creds, "eu-west-1", "s3", now)
+		return req
+	}
+	req := sign()
+	authz := req.Header.Get("Authorization")
+	for _, want := range []string{
+		"AWS4-HMAC-SHA256 Credential=AKID/20240501/eu-west-1/s3/aws4_request",
+		"SignedHeaders=host;x-amz-content-sha256;x-amz-date;x-amz-security-token,",
+	} {
+		if !strings.Contains(authz, want) {
+			t.Errorf("Authorization = %q, missing %q", authz, want)
+		}
+	}
+	if req.Header.Get("X-Amz-Date") != "20240501T120000Z" || req.Header.Get("X-Amz-Security-Token") != "session" {
+		t.Errorf("headers = %v", req.Header)
+	}
+	if again := sign().Header.Get("Authorization"); again != authz {
+		t.Error("Sign() is not deterministic")
+	}
+}
@@ -0,0 +1,267 @@
+// Package slo evaluates service level objectives on a run and the archived
+// runs of the same target: whether the current value meets the target, the
+// share of runs that met it, how fast the error budget burns and where the
+// value is heading.
+//
+// Objectives are read from a YAML file:
+//
+//	objectives:
+//	  - metric: cache_hit
+//	    min: 99
+//	  - name: Query latency
+//	    metric: query_p95_ms
+//	    max: 200
+//	    goal: 90      # percentage of runs meeting the target (default 95)
+//	    window: 14    # runs evaluated, this one included (default 30)
+//	  - metric: replication_lag_seconds
+//	    max: 5
+package slo
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/koltyakov/pghealth/internal/collect"
+)
+
+const (
+	// defaultGoal is the percentage of runs that must meet a target.
+	defaultGoal = 95
+
+	// defaultWindow is the number of runs an objective is evaluated over.
+	defaultWindow = 30
+
+	// maxWindow bounds the archived runs read per evaluation.
+	maxWindow = 1000
+
+	// p95 is the quantile of the query latency metric.
+	p95 = 0.95
+)
+
+// metric reads a value from a run; ok is false when the run lacks the data.
+type metric struct {
+	name  string
+	value func(res collect.Result) (v float64, ok bool)
+}
+
+// metrics are the values objectives can be set on.
+var metrics = []metric{
+	{"cache_hit", func(res collect.Result) (float64, bool) { return res.CacheHitCurrent, res.CacheHitCurrent > 0 }},
+	{"cache_hit_overall", func(res collect.Result) (float64, bool) { return res.CacheHitOverall, res.CacheHitOverall > 0 }},
+	{"query_p95_ms", queryP95},
+	{"replication_lag_seconds", replicationLag},
+}
+
+// Objective is a target on one metric.
+type Objective struct {
+	Name   string   `yaml:"name"`
+	Metric string   `yaml:"metric"`
+	Min    *float64 `yaml:"min"`
+	Max    *float64 `yaml:"max"`
+	Goal   float64  `yaml:"goal"`   // percentage of runs that must meet the target
+	Window int      `yaml:"window"` // runs evaluated, the current one included
+}
+
+// Config is the objectives file.
+type Config struct {
+	Objectives []Objective `yaml:"objectives"`
+}
+
+// Load reads and validates an objectives file, filling in defaults.
+func Load(file string) (Config, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return Config{}, fmt.Errorf("read slo: %w", err)
+	}
+	var c Config
+	if err := yaml.Unmarshal(b, &c); err != nil {
+		return Config{}, fmt.Errorf("parse slo %s: %w", file, err)
+	}
+	if len(c.Objectives) == 0 {
+		return Config{}, fmt.Errorf("%s defines no objectives", file)
+	}
+	for i := range c.Objectives {
+		o := &c.Objectives[i]
+		if _, ok := lookup(o.Metric); !ok {
+			return Config{}, fmt.Errorf("%s: objective %d: unknown metric %q: use %s", file, i+1, o.Metric, metricNames())
+		}
+		if (o.Min == nil) == (o.Max == nil) {
+			return Config{}, fmt.Errorf("%s: objective %d (%s): set either min or max", file, i+1, o.Metric)
+		}
+		if o.Goal == 0 {
+			o.Goal = defaultGoal
+		}
+		if o.Goal <= 0 || o.Goal >= 100 {
+			return Config{}, fmt.Errorf("%s: objective %d (%s): goal must be between 0 and 100", file, i+1, o.Metric)
+		}
+		if o.Window == 0 {
+			o.Window = defaultWindow
+		}
+		if o.Window < 1 || o.Window > maxWindow {
+			return Config{}, fmt.Errorf("%s: objective %d (%s): window must be between 1 and %d runs", file, i+1, o.Metric, maxWindow)
+		}
+		if o.Name == "" {
+			o.Name = o.Metric
+		}
+	}
+	return c, nil
+}
+
+// History returns how many archived runs the objectives need.
+func (c Config) History() int {
+	n := 0
+	for _, o := range c.Objectives {
+		n = max(n, o.Window-1)
+	}
+	return n
+}
+
+// Evaluate measures every objective on res and the archived runs in history,
+// newest first.
+func (c Config) Evaluate(res collect.Result, history []collect.Result) []collect.SLOStatus {
+	out := make([]collect.SLOStatus, 0, len(c.Objectives))
+	for _, o := range c.Objectives {
+		m, _ := lookup(o.Metric)
+		st := collect.SLOStatus{Name: o.Name, Metric: o.Metric, Target: o.target(), Goal: o.Goal, LowerIsOK: o.Max != nil}
+		if v, ok := m.value(res); ok {
+			st.Value, st.Measured, st.Met = v, true, o.meets(v)
+			st.Runs++
+			if st.Met {
+				st.MetRuns++
+			}
+		}
+		var past []float64
+		for i := 0; i < len(history) && i < o.Window-1; i++ {
+			v, ok := m.value(history[i])
+			if !ok {
+				continue
+			}
+			past = append(past, v)
+			st.Runs++
+			if o.meets(v) {
+				st.MetRuns++
+			}
+		}
+		if st.Runs > 0 {
+			missed := float64(st.Runs-st.MetRuns) / float64(st.Runs)
+			st.BurnRate = missed / (1 - o.Goal/100)
+		}
+		if st.Measured && len(past) > 0 {
+			st.Trend, st.HasTrend = st.Value-median(past), true
+		}
+		out = append(out, st)
+	}
+	return out
+}
+
+func (o Objective) meets(v float64) bool {
+	if o.Min != nil {
+		return v >= *o.Min
+	}
+	return v <= *o.Max
+}
+
+func (o Objective) target() string {
+	if o.Min != nil {
+		return ">= " + strconv.FormatFloat(*o.Min, 'f', -1, 64)
+	}
+	return "<= " + strconv.FormatFloat(*o.Max, 'f', -1, 64)
+}
+
+func metricNames() string {
+	names := make([]string, len(metrics))
+	for i, m := range metrics {
+		names[i] = m.name
+	}
+	return strings.Join(names, ", ")
+}
+
+func lookup(name string) (metric, bool) {
+	for _, m := range metrics {
+		if m.name == name {
+			return m, true
+		}
+	}
+	return metric{}, false
+}
+
+// queryP95 is the mean execution time below which 95% of the calls of the
+// top queries by total time fall. pg_stat_statements keeps no per-call
+// times, so this is a percentile of query means rather than of calls.
+func queryP95(res collect.Result) (float64, bool) {
+	stmts := append([]collect.Statement(nil), res.Statements.TopByTotalTime...)
+	var calls float64
+	for _, s := range stmts {
+		calls += s.Calls
+	}
+	if calls == 0 {
+		return 0, false
+	}
+	sort.Slice(stmts, func(i, j int) bool { return stmts[i].MeanTime < stmts[j].MeanTime })
+	var seen float64
+	for _, s := range stmts {
+		seen += s.Calls
+		if seen >= calls*p95 {
+			return s.MeanTime, true
+		}
+	}
+	return stmts[len(stmts)-1].MeanTime, true
+}
+
+// replicationLag is the largest replay lag of the connected standbys in
+// seconds; no standbys means nothing to measure.
+func replicationLag(res collect.Result) (float64, bool) {
+	lag, ok := 0.0, false
+	for _, r := range res.ReplicationStats {
+		if s, err := parseInterval(r.ReplayLag); err == nil {
+			lag, ok = max(lag, s), true
+		}
+	}
+	return lag, ok
+}
+
+// parseInterval converts interval text such as "00:00:01.5" or
+// "1 day 02:00:00" to seconds.
+func parseInterval(s string) (float64, error) {
+	var secs float64
+	fields := strings.Fields(s)
+	if len(fields) == 0 {
+		return 0, fmt.Errorf("empty interval")
+	}
+	for i := 0; i+1 < len(fields); i += 2 {
+		n, err := strconv.ParseFloat(fields[i], 64)
+		if err != nil || !strings.HasPrefix(fields[i+1], "day") {
+			return 0, fmt.Errorf("unsupported interval %q", s)
+		}
+		secs += n * 86400
+	}
+	if len(fields)%2 == 0 {
+		return secs, nil
+	}
+	parts := strings.Split(fields[len(fields)-1], ":")
+	if len(parts) != 3 {
+		return 0, fmt.Errorf("unsupported interval %q", s)
+	}
+	for i, unit := range []float64{3600, 60, 1} {
+		n, err := strconv.ParseFloat(parts[i], 64)
+		if err != nil {
+			return 0, fmt.Errorf("unsupported interval %q", s)
+		}
+		secs += n * unit
+	}
+	return secs, nil
+}
+
+func median(v []float64) float64 {
+	s := append([]float64(nil), v...)
+	sort.Float64s(s)
+	if len(s)%2 == 1 {
+		return s[len(s)/2]
+	}
+	return (s[len(s)/2-1] + s[len(s)/2]) / 2
+}
@@ -0,0 +1,94 @@
+package slo
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/koltyakov/pghealth/internal/collect"
+)
+
+// TestLoad verifies parsing, defaults and validation of the objectives file.
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, body string) string {
+		p := filepath.Join(dir, name)
+		if err := os.WriteFile(p, []byte(body), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+
+	c, err := Load(write("ok.yaml", "objectives:\n  - metric: cache_hit\n    min: 99\n  - name: Latency\n    metric: query_p95_ms\n    max: 200\n    goal: 90\n    window: 14\n"))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if o := c.Objectives[0]; o.Name != "cache_hit" || o.Goal != defaultGoal || o.Window != defaultWindow || o.target() != ">= 99" {
+		t.Errorf("defaults not applied: %+v", o)
+	}
+	if c.History() != defaultWindow-1 {
+		t.Errorf("History() = %d", c.History())
+	}
+
+	for name, body := range map[string]string{
+		"empty.yaml":  "objectives: []\n",
+		"metric.yaml": "objectives:\n  - metric: tps\n    min: 1\n",
+		"both.yaml":   "objectives:\n  - metric: cache_hit\n    min: 1\n    max: 2\n",
+		"none.yaml":   "objectives:\n  - metric: cache_hit\n",
+		"goal.yaml":   "objectives:\n  - metric: cache_hit\n    min: 99\n    goal: 100\n",
+		"window.yaml": "objectives:\n  - metric: cache_hit\n    min: 99\n    window: -1\n",
+		"bad.yaml":    "objectives: [\n",
+	} {
+		if _, err := Load(write(name, body)); err == nil {
+			t.Errorf("Load(%s): want an error", name)
+		}
+	}
+}
+
+// TestEvaluate verifies compliance, burn rate and trend over archived runs.
+func TestEvaluate(t *testing.T) {
+	minHit, maxLag := 99.0, 5.0
+	c := Config{Objectives: []Objective{
+		{Name: "Cache hit", Metric: "cache_hit", Min: &minHit, Goal: 90, Window: 4},
+		{Name: "Lag", Metric: "replication_lag_seconds", Max: &maxLag, Goal: 90, Window: 30},
+	}}
+	res := collect.Result{CacheHitCurrent: 98.5}
+	history := []collect.Result{{CacheHitCurrent: 99.5}, {CacheHitCurrent: 99.2}, {}, {CacheHitCurrent: 97}, {CacheHitCurrent: 90}}
+
+	got := c.Evaluate(res, history)
+	hit := got[0]
+	// Window 4: this run and three archived ones, one of them without data
+	if !hit.Measured || hit.Met || hit.Runs != 3 || hit.MetRuns != 2 {
+		t.Fatalf("cache hit = %+v", hit)
+	}
+	if math.Abs(hit.BurnRate-1.0/3/0.1) > 1e-9 {
+		t.Errorf("burn rate = %v", hit.BurnRate)
+	}
+	if !hit.HasTrend || math.Abs(hit.Trend-(98.5-99.35)) > 1e-9 || !hit.Worsening() {
+		t.Errorf("trend = %v (%v)", hit.Trend, hit.HasTrend)
+	}
+	if lag := got[1]; lag.Measured || lag.Runs != 0 || lag.BurnRate != 0 {
+		t.Errorf("lag without standbys = %+v", lag)
+	}
+}
+
+// TestMetrics verifies the query latency percentile and replication lag.
+func TestMetrics(t *testing.T) {
+	var res collect.Result
+	res.Statements.TopByTotalTime = []collect.Statement{
+		{Calls: 900, MeanTime: 2},
+		{Calls: 60, MeanTime: 50},
+		{Calls: 40, MeanTime: 900},
+	}
+	if v, ok := queryP95(res); !ok || v != 50 {
+		t.Errorf("queryP95 = %v, %v", v, ok)
+	}
+	res.ReplicationStats = []collect.ReplicationStat{{ReplayLag: "00:00:01.5"}, {ReplayLag: "1 day 00:00:02"}, {ReplayLag: "garbage"}}
+	if v, ok := replicationLag(res); !ok || v != 86402 {
+		t.Errorf("replicationLag = %v, %v", v, ok)
+	}
+	if _, ok := queryP95(collect.Result{}); ok {
+		t.Error("queryP95 without statements should be unavailable")
+	}
+}
@@ -0,0 +1,64 @@
+// Package snapshot defines the portable JSON form of a pghealth run.
+//
+// A snapshot bundles run metadata, the raw collection result and the analysis
+// so it can be posted to a central service, archived, or re-rendered later
+// without access to the database.
+package snapshot
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/koltyakov/pghealth/internal/analyze"
+	"github.com/koltyakov/pghealth/internal/collect"
+)
+
+// SchemaVersion is bumped on incompatible changes to the snapshot layout.
+const SchemaVersion = 1
+
+// Snapshot is the serialized result of a single pghealth run.
+type Snapshot struct {
+	// Schema is the snapshot layout version (see SchemaVersion).
+	Schema int `json:"schema"`
+
+	// Meta describes the run itself (start time, duration, tool version).
+	Meta collect.Meta `json:"meta"`
+
+	// Result holds the raw collected metrics.
+	Result collect.Result `json:"result"`
+
+	// Analysis holds the findings derived from Result.
+	Analysis analyze.Analysis `json:"analysis"`
+}
+
+// New assembles a snapshot from the outputs of a run.
+func New(res collect.Result, a analyze.Analysis, meta collect.Meta) Snapshot {
+	return Snapshot{
+		Schema:   SchemaVersion,
+		Meta:     meta,
+		Result:   res,
+		Analysis: a,
+	}
+}
+
+// Marshal encodes the snapshot as compact JSON.
+func (s Snapshot) Marshal() ([]byte, error) {
+	b, err := json.Marshal(s)
+	if err != nil {
+		return nil, fmt.Errorf("encode snapshot: %w", err)
+	}
+	return b, nil
+}
+
+// Decode reads a JSON snapshot from r.
+func Decode(r io.Reader) (Snapshot, error) {
+	var s Snapshot
+	if err := json.NewDecoder(r).Decode(&s); err != nil {
+		return Snapshot{}, fmt.Errorf("decode snapshot: %w", err)
+	}
+	if s.Schema > SchemaVersion {
+		return Snapshot{}, fmt.Errorf("unsupported snapshot schema %d (max %d)", s.Schema, SchemaVersion)
+	}
+	return s, nil
+}
@@ -0,0 +1,110 @@
+package snapshot
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/koltyakov/pghealth/internal/analyze"
+	"github.com/koltyakov/pghealth/internal/collect"
+)
+
+// TestRoundTrip verifies a snapshot survives Marshal/Decode unchanged.
+func TestRoundTrip(t *testing.T) {
+	var res collect.Result
+	res.ConnInfo.CurrentDB = "app"
+	a := analyze.Analysis{Warnings: []analyze.Finding{{Title: "w", Code: "c"}}}
+
+	b, err := New(res, a, collect.Meta{Version: "v1"}).Marshal()
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	got, err := Decode(bytes.NewReader(b))
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if got.Schema != SchemaVersion || got.Result.ConnInfo.CurrentDB != "app" || got.Meta.Version != "v1" {
+		t.Errorf("unexpected snapshot after round trip: %+v", got.Meta)
+	}
+	if len(got.Analysis.Warnings) != 1 || got.Analysis.Warnings[0].Code != "c" {
+		t.Errorf("warnings not preserved: %+v", got.Analysis.Warnings)
+	}
+}
+
+// TestKeyNames pins the JSON keys of the snapshot layout, which readers of
+// archived and posted snapshots depend on.
+func TestKeyNames(t *testing.T) {
+	var res collect.Result
+	res.ConnInfo.CurrentDB = "app"
+	res.Timings = []collect.CollectorTiming{{Name: "tables"}}
+	a := analyze.Analysis{Warnings: []analyze.Finding{{Title: "w", Code: "c"}}}
+	b, err := New(res, a, collect.Meta{Version: "v1", Target: "db:5432/app"}).Marshal()
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+
+	var doc struct {
+		Schema int
+		Meta   map[string]any
+		Result struct {
+			ConnInfo map[string]any
+			Timings  []map[string]any
+		}
+		Analysis struct {
+			Warnings []map[string]any
+		}
+	}
+	if err := json.Unmarshal(b, &doc); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	for _, k := range []string{`"schema"`, `"meta"`, `"result"`, `"analysis"`, `"started_at"`, `"version"`, `"target"`} {
+		if !bytes.Contains(b, []byte(k)) {
+			t.Errorf("key %s missing", k)
+		}
+	}
+	if doc.Result.ConnInfo["CurrentDB"] != "app" {
+		t.Errorf("result.ConnInfo.CurrentDB missing: %v", doc.Result.ConnInfo)
+	}
+	if len(doc.Result.Timings) != 1 || doc.Result.Timings[0]["Name"] != "tables" {
+		t.Errorf("result.Timings[].Name missing: %v", doc.Result.Timings)
+	}
+	if len(doc.Analysis.Warnings) != 1 || doc.Analysis.Warnings[0]["Code"] != "c" || doc.Analysis.Warnings[0]["Title"] != "w" {
+		t.Errorf("analysis.Warnings[] keys missing: %v", doc.Analysis.Warnings)
+	}
+}
+
+// TestFieldsTagged verifies every exported field reachable from Snapshot has
+// a json tag, so renaming a Go field cannot change the layout unnoticed.
+func TestFieldsTagged(t *testing.T) {
+	seen := map[reflect.Type]bool{}
+	var walk func(rt reflect.Type)
+	walk = func(rt reflect.Type) {
+		for rt.Kind() == reflect.Pointer || rt.Kind() == reflect.Slice || rt.Kind() == reflect.Array || rt.Kind() == reflect.Map {
+			rt = rt.Elem()
+		}
+		if rt.Kind() != reflect.Struct || rt.PkgPath() == "time" || seen[rt] {
+			return
+		}
+		seen[rt] = true
+		for i := 0; i < rt.NumField(); i++ {
+			f := rt.Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			if name, _, _ := strings.Cut(f.Tag.Get("json"), ","); name == "" && !f.Anonymous {
+				t.Errorf("%s.%s has no json key", rt, f.Name)
+			}
+			walk(f.Type)
+		}
+	}
+	walk(reflect.TypeOf(Snapshot{}))
+}
+
+// TestDecodeFutureSchema verifies newer snapshot layouts are rejected.
+func TestDecodeFutureSchema(t *testing.T) {
+	if _, err := Decode(strings.NewReader(`{"schema":99}`)); err == nil {
+		t.Error("Decode() expected error for future schema")
+	}
+}
@@ -0,0 +1,45 @@
+package sqlparse
+
+import "strings"
+
+// Fingerprint normalizes sql so statements differing only in constants,
+// parameter numbers, IN list lengths, case, whitespace or comments compare
+// equal: literals and parameters become ?, IN lists become (?).
+func Fingerprint(sql string) string {
+	toks := lex(sql)
+	out := make([]string, 0, len(toks))
+	for i := 0; i < len(toks); i++ {
+		t := toks[i]
+		switch t.kind {
+		case tokString, tokNumber, tokParam:
+			out = append(out, "?")
+			continue
+		case tokQuoted:
+			out = append(out, `"`+t.text+`"`)
+			continue
+		}
+		if t.is(";") && i == len(toks)-1 {
+			break
+		}
+		out = append(out, t.text)
+		if t.is("in") && i+1 < len(toks) && toks[i+1].is("(") {
+			// Collapse a list of constants; subqueries are kept
+			j := i + 2
+			for j < len(toks) && (isValue(toks[j]) || toks[j].is(",")) {
+				j++
+			}
+			if j > i+2 && j < len(toks) && toks[j].is(")") {
+				out = append(out, "(", "?", ")")
+				i = j
+			}
+		}
+	}
+	var b strings.Builder
+	for i, t := range out {
+		if i > 0 && t != "," && t != ")" && t != "." && out[i-1] != "(" && out[i-1] != "." {
+			b.WriteByte(' ')
+		}
+		b.WriteString(t)
+	}
+	return b.String()
+}
@@ -0,0 +1,217 @@
+// Package sqlparse reads PostgreSQL statements as normalized by
+// pg_stat_statements without a full grammar: a lexer plus a clause-aware
+// walker that extracts referenced tables, filter predicates and sort keys.
+// It is pure Go so release builds stay CGO-free; syntax it does not know is
+// skipped rather than rejected.
+package sqlparse
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// tokenKind classifies lexed tokens.
+type tokenKind int
+
+const (
+	tokIdent  tokenKind = iota // keyword or unquoted identifier, folded to lower case
+	tokQuoted                  // "quoted identifier", case preserved
+	tokString                  // 'literal', E'...', $$dollar$$
+	tokNumber                  // numeric literal
+	tokParam                   // $1 placeholder
+	tokOp                      // operator such as =, <=, ::, ||
+	tokPunct                   // ( ) , . ; [ ]
+)
+
+type token struct {
+	kind tokenKind
+	text string
+}
+
+// is reports whether t is the keyword or punctuation s.
+func (t token) is(s string) bool {
+	return (t.kind == tokIdent || t.kind == tokPunct || t.kind == tokOp) && t.text == s
+}
+
+// eof reports whether t marks the end of input (see parser.at).
+func (t token) eof() bool { return t.kind == tokPunct && t.text == "" }
+
+// lex splits sql into tokens, dropping whitespace and comments.
+func lex(sql string) []token {
+	var out []token
+	for i := 0; i < len(sql); {
+		c := sql[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f':
+			i++
+		case c == '-' && i+1 < len(sql) && sql[i+1] == '-':
+			for i < len(sql) && sql[i] != '\n' {
+				i++
+			}
+		case c == '/' && i+1 < len(sql) && sql[i+1] == '*':
+			end := strings.Index(sql[i+2:], "*/")
+			if end < 0 {
+				i = len(sql)
+			} else {
+				i += end + 4
+			}
+		case c == '\'' || ((c == 'e' || c == 'E') && i+1 < len(sql) && sql[i+1] == '\''):
+			start := i
+			if c != '\'' {
+				i++
+			}
+			i = skipQuoted(sql, i+1, '\'')
+			out = append(out, token{tokString, sql[start:i]})
+		case c == '"':
+			j := skipQuoted(sql, i+1, '"')
+			out = append(out, token{tokQuoted, strings.ReplaceAll(strings.TrimSuffix(sql[i+1:j], `"`), `""`, `"`)})
+			i = j
+		case c == '$':
+			j := i + 1
+			for j < len(sql) && sql[j] >= '0' && sql[j] <= '9' {
+				j++
+			}
+			if j > i+1 {
+				out = append(out, token{tokParam, sql[i:j]})
+				i = j
+				continue
+			}
+			// Dollar-quoted string: $tag$ ... $tag$
+			for j < len(sql) && sql[j] != '$' && isIdentPart(rune(sql[j])) {
+				j++
+			}
+			if j < len(sql) && sql[j] == '$' {
+				tag := sql[i : j+1]
+				end := strings.Index(sql[j+1:], tag)
+				if end < 0 {
+					i = len(sql)
+				} else {
+					i = j + 1 + end + len(tag)
+				}
+				out = append(out, token{tokString, tag})
+				continue
+			}
+			out = append(out, token{tokOp, "$"})
+			i++
+		case c >= '0' && c <= '9' || (c == '.' && i+1 < len(sql) && sql[i+1] >= '0' && sql[i+1] <= '9'):
+			j := i
+			for j < len(sql) && (sql[j] >= '0' && sql[j] <= '9' || sql[j] == '.' || sql[j] == '_') {
+				j++
+			}
+			out = append(out, token{tokNumber, sql[i:j]})
+			i = j
+		case strings.IndexByte("(),.;[]", c) >= 0:
+			out = append(out, token{tokPunct, string(c)})
+			i++
+		case strings.IndexByte("+-*/<>=~!@#%^&|`?:", c) >= 0:
+			j := i
+			for j < len(sql) && strings.IndexByte("+-*/<>=~!@#%^&|`?:", sql[j]) >= 0 {
+				// A comment start ends the operator
+				if j > i && (sql[j:j+min(2, len(sql)-j)] == "--" || sql[j:j+min(2, len(sql)-j)] == "/*") {
+					break
+				}
+				j++
+			}
+			out = append(out, token{tokOp, sql[i:j]})
+			i = j
+		default:
+			r, size := utf8.DecodeRuneInString(sql[i:])
+			if !isIdentStart(r) {
+				i += size
+				continue
+			}
+			j := i + size
+			for j < len(sql) {
+				r, size := utf8.DecodeRuneInString(sql[j:])
+				if !isIdentPart(r) {
+					break
+				}
+				j += size
+			}
+			out = append(out, token{tokIdent, strings.ToLower(sql[i:j])})
+			i = j
+		}
+	}
+	return out
+}
+
+// skipQuoted returns the index after the quote closing a literal that starts
+// at i, treating a doubled quote as an escaped one.
+func skipQuoted(sql string, i int, quote byte) int {
+	j, _ := closeQuoted(sql, i, quote)
+	return j
+}
+
+// closeQuoted is skipQuoted that also reports whether the closing quote was
+// found before the end of sql.
+func closeQuoted(sql string, i int, quote byte) (int, bool) {
+	for i < len(sql) {
+		if sql[i] == quote {
+			if i+1 < len(sql) && sql[i+1] == quote {
+				i += 2
+				continue
+			}
+			return i + 1, true
+		}
+		i++
+	}
+	return i, false
+}
+
+// Truncated reports whether sql ends inside a literal, quoted identifier,
+// block comment or parenthesis, as statement text cut at a length limit does.
+func Truncated(sql string) bool {
+	depth := 0
+	for i := 0; i < len(sql); {
+		c := sql[i]
+		switch {
+		case c == '-' && i+1 < len(sql) && sql[i+1] == '-':
+			for i < len(sql) && sql[i] != '\n' {
+				i++
+			}
+		case c == '/' && i+1 < len(sql) && sql[i+1] == '*':
+			end := strings.Index(sql[i+2:], "*/")
+			if end < 0 {
+				return true
+			}
+			i += end + 4
+		case c == '\'' || c == '"':
+			j, ok := closeQuoted(sql, i+1, c)
+			if !ok {
+				return true
+			}
+			i = j
+		case c == '$':
+			j := i + 1
+			for j < len(sql) && sql[j] != '$' && isIdentPart(rune(sql[j])) && !(j == i+1 && sql[j] >= '0' && sql[j] <= '9') {
+				j++
+			}
+			if j < len(sql) && sql[j] == '$' {
+				tag := sql[i : j+1]
+				end := strings.Index(sql[j+1:], tag)
+				if end < 0 {
+					return true
+				}
+				i = j + 1 + end + len(tag)
+				continue
+			}
+			i++
+		case c == '(':
+			depth++
+			i++
+		case c == ')':
+			depth--
+			i++
+		default:
+			i++
+		}
+	}
+	return depth > 0
+}
+
+func isIdentStart(r rune) bool { return r == '_' || unicode.IsLetter(r) }
+
+func isIdentPart(r rune) bool {
+	return r == '_' || r == '$' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
@@ -0,0 +1,448 @@
+package sqlparse
+
+// Table is a relation referenced in a FROM, JOIN, UPDATE or INTO clause.
+type Table struct {
+	Schema string // empty when unqualified
+	Name   string
+	Alias  string // empty when none
+}
+
+// PredicateKind tells how a filter constrains a column.
+type PredicateKind int
+
+const (
+	// Equality is =, IN or IS NULL against a value.
+	Equality PredicateKind = iota
+	// Range is <, <=, >, >=, BETWEEN or LIKE against a value.
+	Range
+	// Join is equality with a column of another relation.
+	Join
+)
+
+// Predicate is a column constrained by a WHERE, ON or HAVING condition.
+// Table is the zero value when the column could not be attributed.
+type Predicate struct {
+	Table  Table
+	Column string
+	Kind   PredicateKind
+	// Value is the constant of an equality that survived normalization:
+	// a quoted string, a number, true, false or NULL for IS NULL. Empty
+	// for parameters and other kinds.
+	Value string
+}
+
+// SortKey is a plain column in an ORDER BY list.
+type SortKey struct {
+	Table  Table
+	Column string
+	Desc   bool
+}
+
+// Statement is what Parse extracts from one SQL statement.
+type Statement struct {
+	Command    string  // main command in lower case ("select", "update", ...), after any WITH clause
+	Tables     []Table // referenced relations, excluding CTE names
+	Joins      int     // JOIN keywords plus comma-separated FROM items
+	SelectStar bool    // the outermost select list has * or alias.*
+	Limit      bool    // the outermost query has LIMIT or FETCH
+	Filters    []Predicate
+	OrderBy    []SortKey
+}
+
+// Writes reports whether the statement modifies data.
+func (s Statement) Writes() bool {
+	switch s.Command {
+	case "insert", "update", "delete", "merge":
+		return true
+	}
+	return false
+}
+
+// commands are the keywords that start a statement body after WITH.
+var commands = map[string]bool{
+	"select": true, "insert": true, "update": true, "delete": true, "merge": true, "values": true, "table": true,
+}
+
+// reserved are keywords that end a table reference, so they are never taken
+// for an alias, and that never name a column in a predicate.
+var reserved = map[string]bool{
+	"all": true, "and": true, "any": true, "as": true, "asc": true, "between": true, "by": true,
+	"case": true, "cross": true, "current_date": true, "current_time": true, "current_timestamp": true,
+	"default": true, "delete": true, "desc": true, "distinct": true, "else": true, "end": true,
+	"except": true, "exists": true, "false": true, "fetch": true, "for": true, "from": true, "full": true,
+	"group": true, "having": true, "ilike": true, "in": true, "inner": true, "insert": true,
+	"intersect": true, "interval": true, "into": true, "is": true, "join": true, "lateral": true,
+	"left": true, "like": true, "limit": true, "natural": true, "not": true, "null": true,
+	"nulls": true, "offset": true, "on": true, "only": true, "or": true, "order": true,
+	"outer": true, "returning": true, "right": true, "select": true, "set": true, "similar": true,
+	"some": true, "tablesample": true, "then": true, "true": true, "union": true, "update": true,
+	"using": true, "values": true, "when": true, "where": true, "window": true, "with": true,
+}
+
+// Clauses tracked while walking tokens. Each parenthesis level has its own.
+const (
+	clauseOther = iota
+	clauseSelect
+	clauseFrom
+	clauseFilter
+	clauseOrder
+)
+
+// parser walks the tokens of one statement.
+type parser struct {
+	toks []token
+	st   Statement
+	// unresolved column references, attributed to tables once all are known
+	filters []pendingRef
+	sorts   []pendingRef
+}
+
+// pendingRef is a column reference with its optional qualifier.
+type pendingRef struct {
+	qualifier, column string
+	kind              PredicateKind
+	value             string
+	desc              bool
+}
+
+// Parse extracts referenced tables, filter predicates and sort keys from sql.
+// It never fails: unknown syntax yields fewer results.
+func Parse(sql string) Statement {
+	p := &parser{toks: lex(sql)}
+	p.walk()
+	p.resolve()
+	return p.st
+}
+
+// at returns the token at i, or an end-of-input token when out of range.
+func (p *parser) at(i int) token {
+	if i < 0 || i >= len(p.toks) {
+		return token{kind: tokPunct}
+	}
+	return p.toks[i]
+}
+
+func (p *parser) walk() {
+	if len(p.toks) > 0 && !p.toks[0].is("with") && p.toks[0].kind == tokIdent {
+		p.st.Command = p.toks[0].text
+	}
+	clauses := []int{clauseOther}
+	for i := 0; i < len(p.toks); i++ {
+		t := p.toks[i]
+		cur := &clauses[len(clauses)-1]
+		outer := len(clauses) == 1
+		if outer && p.st.Command == "" && t.kind == tokIdent && commands[t.text] {
+			p.st.Command = t.text
+		}
+		switch {
+		case t.is("("):
+			// Parenthesized conditions keep the clause; subqueries reset it
+			// with SELECT; function arguments in FROM are not table lists
+			next := *cur
+			if next == clauseFrom {
+				next = clauseOther
+			}
+			clauses = append(clauses, next)
+		case t.is(")"):
+			if len(clauses) > 1 {
+				clauses = clauses[:len(clauses)-1]
+			}
+		case t.kind == tokIdent && t.text == "select":
+			*cur = clauseSelect
+		case t.kind == tokOp && t.text == "*" && *cur == clauseSelect:
+			prev := p.at(i - 1)
+			if outer && (prev.is("select") || prev.is("distinct") || prev.is("all") || prev.is(",") || prev.is(".")) {
+				p.st.SelectStar = true
+			}
+		case t.kind == tokIdent && (t.text == "limit" || t.text == "fetch"):
+			*cur = clauseOther
+			p.st.Limit = p.st.Limit || outer
+		case t.kind == tokIdent && (t.text == "from" || t.text == "join"):
+			if t.text == "join" {
+				p.st.Joins++
+			}
+			*cur = clauseFrom
+			i = p.table(i+1, false)
+		case t.kind == tokIdent && (t.text == "update" || t.text == "into"):
+			// INTO may be followed by a column list, never by a function
+			*cur = clauseOther
+			i = p.table(i+1, true)
+		case t.kind == tokIdent && t.text == "using" && !p.at(i+1).is("("):
+			// DELETE ... USING list; JOIN ... USING (cols) is skipped
+			*cur = clauseFrom
+			i = p.table(i+1, false)
+		case t.is(",") && *cur == clauseFrom:
+			p.st.Joins++
+			i = p.table(i+1, false)
+		case t.kind == tokIdent && (t.text == "where" || t.text == "on" || t.text == "having"):
+			*cur = clauseFilter
+		case t.kind == tokIdent && t.text == "order" && p.at(i+1).is("by"):
+			*cur = clauseOrder
+			i++
+		case t.kind == tokIdent && clauseEnds[t.text]:
+			*cur = clauseOther
+		case *cur == clauseFilter && operandStart(p.at(i-1)):
+			p.predicate(i)
+		case *cur == clauseOrder && (p.at(i-1).is("by") || p.at(i-1).is(",")):
+			p.sortKey(i)
+		}
+	}
+}
+
+// clauseEnds are keywords starting a clause that is neither FROM, a filter
+// nor ORDER BY.
+var clauseEnds = map[string]bool{
+	"select": true, "group": true, "limit": true, "offset": true, "returning": true, "set": true,
+	"values": true, "union": true, "intersect": true, "except": true, "window": true, "fetch": true,
+	"for": true, "with": true,
+}
+
+// operandStart reports whether a condition operand can begin after prev, so
+// right-hand sides of comparisons are not read as new predicates.
+func operandStart(prev token) bool {
+	if prev.is("(") {
+		return true
+	}
+	if prev.kind != tokIdent {
+		return false
+	}
+	switch prev.text {
+	case "where", "on", "having", "and", "or", "not":
+		return true
+	}
+	return false
+}
+
+// table reads a table reference starting at i and returns the index of its
+// last token, or i-1 when there is none (subquery, function, VALUES). Unless
+// target is set, a name followed by a parenthesis is a set-returning function.
+func (p *parser) table(i int, target bool) int {
+	for p.at(i).kind == tokIdent && (p.at(i).text == "only" || p.at(i).text == "lateral") {
+		i++
+	}
+	schema, name, end, ok := p.name(i)
+	if !ok || (!target && p.at(end+1).is("(")) {
+		return i - 1
+	}
+	t := Table{Schema: schema, Name: name}
+	next := p.at(end + 1)
+	switch {
+	case next.kind == tokIdent && next.text == "as" && isName(p.at(end+2)):
+		t.Alias = p.at(end + 2).text
+		end += 2
+	case isName(next):
+		t.Alias = next.text
+		end++
+	}
+	p.st.Tables = append(p.st.Tables, t)
+	return end
+}
+
+// name reads a possibly qualified name starting at i and returns its last
+// two parts and the index of its last token.
+func (p *parser) name(i int) (qualifier, name string, end int, ok bool) {
+	if !isName(p.at(i)) {
+		return "", "", i, false
+	}
+	parts := []string{p.at(i).text}
+	end = i
+	for p.at(end+1).is(".") && isName(p.at(end+2)) {
+		parts = append(parts, p.at(end+2).text)
+		end += 2
+	}
+	if len(parts) > 1 {
+		qualifier = parts[len(parts)-2]
+	}
+	return qualifier, parts[len(parts)-1], end, true
+}
+
+// isName reports whether t can be an identifier rather than a keyword.
+func isName(t token) bool {
+	return t.kind == tokQuoted || (t.kind == tokIdent && !reserved[t.text])
+}
+
+// isValue reports whether t starts a constant operand.
+func isValue(t token) bool {
+	return t.kind == tokParam || t.kind == tokNumber || t.kind == tokString
+}
+
+// column reads a column reference starting at i, skipping a trailing cast,
+// and returns the index of the token following it. Function calls are not
+// column references.
+func (p *parser) column(i int) (qualifier, column string, next int, ok bool) {
+	qualifier, column, end, ok := p.name(i)
+	if !ok || p.at(end+1).is("(") || p.at(end+1).is(".") {
+		return "", "", i, false
+	}
+	next = end + 1
+	for p.at(next).is("::") {
+		next += 2
+	}
+	return qualifier, column, next, true
+}
+
+// predicate records the column condition starting at i, if any.
+func (p *parser) predicate(i int) {
+	if q, c, next, ok := p.column(i); ok {
+		op := p.at(next)
+		switch {
+		case op.is("="):
+			if rq, rc, _, ok := p.column(next + 1); ok {
+				p.filters = append(p.filters, pendingRef{qualifier: q, column: c, kind: Join}, pendingRef{qualifier: rq, column: rc, kind: Join})
+				return
+			}
+			p.filters = append(p.filters, pendingRef{qualifier: q, column: c, kind: Equality, value: constant(p.at(next+1), p.at(next+2))})
+		case op.is("in"):
+			p.filters = append(p.filters, pendingRef{qualifier: q, column: c, kind: Equality})
+		case op.is("is") && p.at(next+1).is("null"):
+			p.filters = append(p.filters, pendingRef{qualifier: q, column: c, kind: Equality, value: "NULL"})
+		case op.is("<"), op.is("<="), op.is(">"), op.is(">="), op.is("between"), op.is("like"), op.is("ilike"):
+			if _, _, _, ok := p.column(next + 1); ok {
+				return
+			}
+			p.filters = append(p.filters, pendingRef{qualifier: q, column: c, kind: Range})
+		}
+		return
+	}
+	// Reversed comparison: $1 = col, $1 < col
+	if !isValue(p.at(i)) {
+		return
+	}
+	op := p.at(i + 1)
+	q, c, _, ok := p.column(i + 2)
+	if !ok {
+		return
+	}
+	switch {
+	case op.is("="):
+		p.filters = append(p.filters, pendingRef{qualifier: q, column: c, kind: Equality, value: constant(p.at(i), token{kind: tokPunct})})
+	case op.is("<"), op.is("<="), op.is(">"), op.is(">="):
+		p.filters = append(p.filters, pendingRef{qualifier: q, column: c, kind: Range})
+	}
+}
+
+// constant returns the text of t when it is a literal that makes the whole
+// operand (next is not an operator, a cast or a subscript), or "".
+func constant(t, next token) string {
+	if next.kind == tokOp || next.is("(") || next.is(".") || next.is("[") {
+		return ""
+	}
+	switch {
+	case t.kind == tokNumber:
+		return t.text
+	case t.kind == tokString && t.text[0] == '\'':
+		return t.text
+	case t.is("true"), t.is("false"):
+		return t.text
+	}
+	return ""
+}
+
+// sortKey records the ORDER BY item starting at i when it is a plain column.
+func (p *parser) sortKey(i int) {
+	q, c, next, ok := p.column(i)
+	if !ok {
+		return
+	}
+	t := p.at(next)
+	desc := false
+	if t.kind == tokIdent && (t.text == "asc" || t.text == "desc") {
+		desc = t.text == "desc"
+		t = p.at(next + 1)
+	}
+	if t.is("nulls") || t.is(",") || t.is(")") || t.is(";") || t.eof() || t.kind == tokIdent && clauseEnds[t.text] {
+		p.sorts = append(p.sorts, pendingRef{qualifier: q, column: c, desc: desc})
+	}
+}
+
+// resolve drops CTE names from the tables and attributes column references:
+// qualified ones by alias or table name, unqualified ones only when the
+// statement references a single table.
+func (p *parser) resolve() {
+	ctes := p.cteNames()
+	tables := p.st.Tables[:0]
+	for _, t := range p.st.Tables {
+		if t.Schema == "" && ctes[t.Name] {
+			continue
+		}
+		tables = append(tables, t)
+	}
+	p.st.Tables = tables
+	if len(tables) == 0 {
+		p.st.Tables = nil
+	}
+
+	lookup := func(qualifier string) Table {
+		if qualifier == "" {
+			if len(p.st.Tables) == 1 {
+				return p.st.Tables[0]
+			}
+			return Table{}
+		}
+		for _, t := range p.st.Tables {
+			if t.Alias == qualifier {
+				return t
+			}
+		}
+		for _, t := range p.st.Tables {
+			if t.Alias == "" && t.Name == qualifier {
+				return t
+			}
+		}
+		return Table{}
+	}
+	for _, r := range p.filters {
+		p.st.Filters = append(p.st.Filters, Predicate{Table: lookup(r.qualifier), Column: r.column, Kind: r.kind, Value: r.value})
+	}
+	for _, r := range p.sorts {
+		p.st.OrderBy = append(p.st.OrderBy, SortKey{Table: lookup(r.qualifier), Column: r.column, Desc: r.desc})
+	}
+}
+
+// cteNames returns the names defined by WITH clauses: name [(cols)] AS [[NOT] MATERIALIZED] (.
+func (p *parser) cteNames() map[string]bool {
+	names := map[string]bool{}
+	for i, t := range p.toks {
+		if !t.is("with") && !t.is("recursive") && !t.is(",") {
+			continue
+		}
+		if !isName(p.at(i + 1)) {
+			continue
+		}
+		j := i + 2
+		if p.at(j).is("(") {
+			for j < len(p.toks) && !p.at(j).is(")") {
+				j++
+			}
+			j++
+		}
+		if !p.at(j).is("as") {
+			continue
+		}
+		j++
+		if p.at(j).is("not") {
+			j++
+		}
+		if p.at(j).is("materialized") {
+			j++
+		}
+		if p.at(j).is("(") {
+			names[p.at(i+1).text] = true
+		}
+	}
+	return names
+}
+
+// String returns the table as schema.name, or name when unqualified.
+func (t Table) String() string {
+	if t.Schema == "" {
+		return t.Name
+	}
+	return t.Schema + "." + t.Name
+}
+
+// Matches reports whether t refers to schema.name; an unqualified reference
+// matches any schema.
+func (t Table) Matches(schema, name string) bool {
+	return t.Name == name && (t.Schema == "" || t.Schema == schema)
+}
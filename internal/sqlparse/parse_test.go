@@ -0,0 +1,178 @@
+package sqlparse
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestLex verifies literals, quoted identifiers, parameters and comments are
+// tokenized without leaking their contents as identifiers.
+func TestLex(t *testing.T) {
+	toks := lex(`SELECT "Order Id", 'it''s from x' -- from y
+		FROM t /* join z */ WHERE a::int >= $1 AND b = $$from w$$`)
+	var got []string
+	for _, tok := range toks {
+		got = append(got, tok.text)
+	}
+	expected := []string{"select", "Order Id", ",", "'it''s from x'", "from", "t", "where", "a", "::", "int", ">=", "$1", "and", "b", "=", "$$"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("lex() = %q\nexpected %q", got, expected)
+	}
+}
+
+// TestParse verifies tables, predicates and sort keys are extracted and
+// attributed to their relations.
+func TestParse(t *testing.T) {
+	orders := Table{Schema: "public", Name: "orders", Alias: "o"}
+	customers := Table{Name: "customers", Alias: "c"}
+	events := Table{Name: "events"}
+	tests := []struct {
+		name    string
+		sql     string
+		tables  []Table
+		filters []Predicate
+		orderBy []SortKey
+	}{
+		{
+			name:   "single table",
+			sql:    "SELECT * FROM events WHERE tenant_id = $1 AND created_at >= $2 AND kind IN ($3, $4) ORDER BY created_at DESC LIMIT $5",
+			tables: []Table{events},
+			filters: []Predicate{
+				{Table: events, Column: "tenant_id", Kind: Equality},
+				{Table: events, Column: "created_at", Kind: Range},
+				{Table: events, Column: "kind", Kind: Equality},
+			},
+			orderBy: []SortKey{{Table: events, Column: "created_at", Desc: true}},
+		},
+		{
+			name:   "join with aliases",
+			sql:    "select o.id from public.orders o join customers as c on c.id = o.customer_id where o.status = $1 and $2 < o.total and lower(c.email) = $3 order by o.created_at, 2",
+			tables: []Table{orders, customers},
+			filters: []Predicate{
+				{Table: customers, Column: "id", Kind: Join},
+				{Table: orders, Column: "customer_id", Kind: Join},
+				{Table: orders, Column: "status", Kind: Equality},
+				{Table: orders, Column: "total", Kind: Range},
+			},
+			orderBy: []SortKey{{Table: orders, Column: "created_at"}},
+		},
+		{
+			name:    "unqualified columns across tables are not attributed",
+			sql:     "SELECT 1 FROM a, b WHERE x = $1",
+			tables:  []Table{{Name: "a"}, {Name: "b"}},
+			filters: []Predicate{{Column: "x", Kind: Equality}},
+		},
+		{
+			name:   "cte and subquery",
+			sql:    "WITH recent AS (SELECT id FROM events WHERE created_at > now() - interval '1 day') SELECT * FROM recent r WHERE r.id IS NULL",
+			tables: []Table{events},
+			filters: []Predicate{
+				{Table: events, Column: "created_at", Kind: Range},
+				{Column: "id", Kind: Equality, Value: "NULL"},
+			},
+		},
+		{
+			name:   "constants kept for partial predicates",
+			sql:    "SELECT id FROM events WHERE status = 'open' AND deleted = false AND 3 = priority AND owner = $1 AND total = 1 + $2 ORDER BY id",
+			tables: []Table{events},
+			filters: []Predicate{
+				{Table: events, Column: "status", Kind: Equality, Value: "'open'"},
+				{Table: events, Column: "deleted", Kind: Equality, Value: "false"},
+				{Table: events, Column: "priority", Kind: Equality, Value: "3"},
+				{Table: events, Column: "owner", Kind: Equality},
+				{Table: events, Column: "total", Kind: Equality},
+			},
+			orderBy: []SortKey{{Table: events, Column: "id"}},
+		},
+		{
+			name:    "update and insert targets",
+			sql:     "UPDATE events SET seen = true WHERE id = $1",
+			tables:  []Table{events},
+			filters: []Predicate{{Table: events, Column: "id", Kind: Equality}},
+		},
+		{
+			name:   "insert with column list and function in from",
+			sql:    "INSERT INTO events (id, kind) SELECT g, $1 FROM generate_series(1, $2) g",
+			tables: []Table{events},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Parse(tt.sql)
+			if !reflect.DeepEqual(got.Tables, tt.tables) {
+				t.Errorf("Tables = %+v, expected %+v", got.Tables, tt.tables)
+			}
+			if !reflect.DeepEqual(got.Filters, tt.filters) {
+				t.Errorf("Filters = %+v, expected %+v", got.Filters, tt.filters)
+			}
+			if !reflect.DeepEqual(got.OrderBy, tt.orderBy) {
+				t.Errorf("OrderBy = %+v, expected %+v", got.OrderBy, tt.orderBy)
+			}
+		})
+	}
+}
+
+// TestClassify verifies the command, join count, SELECT * and LIMIT flags
+// look at the outermost query only.
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		sql        string
+		command    string
+		writes     bool
+		joins      int
+		selectStar bool
+		limit      bool
+	}{
+		{"SELECT * FROM a JOIN b ON b.id = a.b_id LEFT JOIN c ON c.id = b.c_id", "select", false, 2, true, false},
+		{"select a.*, b.name from a, b where a.id = b.id limit $1", "select", false, 1, true, true},
+		{"SELECT count(*) FROM a WHERE EXISTS (SELECT * FROM b WHERE b.a_id = a.id LIMIT 1)", "select", false, 0, false, false},
+		{"WITH x AS (SELECT * FROM a) DELETE FROM b USING x WHERE b.id = x.id", "delete", true, 0, false, false},
+		{"insert into a (id) values ($1) returning *", "insert", true, 0, false, false},
+		{"SELECT id FROM a ORDER BY id FETCH FIRST 10 ROWS ONLY", "select", false, 0, false, true},
+		{"VACUUM ANALYZE a", "vacuum", false, 0, false, false},
+	}
+	for _, tt := range tests {
+		got := Parse(tt.sql)
+		if got.Command != tt.command || got.Writes() != tt.writes || got.Joins != tt.joins || got.SelectStar != tt.selectStar || got.Limit != tt.limit {
+			t.Errorf("Parse(%q) = command %q, writes %v, joins %d, select * %v, limit %v", tt.sql, got.Command, got.Writes(), got.Joins, got.SelectStar, got.Limit)
+		}
+	}
+}
+
+// TestFingerprint verifies statements differing in constants, IN list
+// lengths and formatting share a fingerprint.
+func TestFingerprint(t *testing.T) {
+	a := Fingerprint("SELECT * FROM items WHERE order_id IN ($1, $2, $3) AND kind = 'x' -- ORM")
+	b := Fingerprint("select *\n  from items where order_id in ($1) and kind = $2;")
+	if a != b {
+		t.Errorf("fingerprints differ:\n%s\n%s", a, b)
+	}
+	if expected := "select * from items where order_id in (?) and kind = ?"; a != expected {
+		t.Errorf("Fingerprint() = %q, expected %q", a, expected)
+	}
+	if got := Fingerprint("SELECT 1 FROM a WHERE id IN (SELECT a_id FROM b)"); got != "select ? from a where id in (select a_id from b)" {
+		t.Errorf("Fingerprint(subquery) = %q", got)
+	}
+}
+
+// TestTruncated verifies statements cut inside a literal, comment or
+// parenthesis are detected and complete ones are not.
+func TestTruncated(t *testing.T) {
+	tests := []struct {
+		sql  string
+		want bool
+	}{
+		{"SELECT * FROM orders WHERE id = $1", false},
+		{"SELECT 'it''s', \"a)b\" FROM t /* ( */ WHERE x IN ($1, $2) -- (", false},
+		{"DO $body$ BEGIN PERFORM f('('); END $body$", false},
+		{"SELECT * FROM orders WHERE id IN (SELECT order_id FROM items WHERE sku", true},
+		{"SELECT * FROM t WHERE name = 'abc", true},
+		{"SELECT * FROM t /* long comment", true},
+		{"DO $$ BEGIN PERFORM 1", true},
+	}
+	for _, tt := range tests {
+		if got := Truncated(tt.sql); got != tt.want {
+			t.Errorf("Truncated(%q) = %v, expected %v", tt.sql, got, tt.want)
+		}
+	}
+}
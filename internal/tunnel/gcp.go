@@ -0,0 +1,126 @@
+package tunnel
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+
+	"cloud.google.com/go/alloydbconn"
+	"cloud.google.com/go/cloudsqlconn"
+)
+
+// gcpUserAgent identifies pghealth in Cloud SQL and AlloyDB Admin API calls.
+const gcpUserAgent = "pghealth"
+
+// alloyDBInstanceRe matches AlloyDB instance URIs.
+var alloyDBInstanceRe = regexp.MustCompile(`^projects/[^/]+/locations/[^/]+/clusters/[^/]+/instances/[^/]+$`)
+
+// GCPConfig describes a Cloud SQL or AlloyDB instance reached through the
+// Go connectors, which handle TLS and (optionally) IAM database authentication
+// without the auth proxy sidecar.
+type GCPConfig struct {
+	Instance        string // Cloud SQL project:region:instance or AlloyDB projects/p/locations/r/clusters/c/instances/i
+	IAMAuthN        bool   // Log in as the IAM principal instead of with a password
+	PrivateIP       bool   // Connect to the Cloud SQL private IP
+	CredentialsFile string // Service account key; Application Default Credentials when empty
+}
+
+// GCP is an open Cloud SQL or AlloyDB connector.
+type GCP struct {
+	instance string
+	dial     func(ctx context.Context, instance string) (net.Conn, error)
+	close    func() error
+}
+
+// ValidateCloudSQLInstance checks the project:region:instance connection name.
+// Domain-scoped projects (example.com:project) are accepted.
+func ValidateCloudSQLInstance(name string) error {
+	parts := strings.Split(name, ":")
+	if len(parts) < 3 || len(parts) > 4 {
+		return fmt.Errorf("invalid Cloud SQL instance %q: expected project:region:instance", name)
+	}
+	for _, p := range parts {
+		if p == "" {
+			return fmt.Errorf("invalid Cloud SQL instance %q: expected project:region:instance", name)
+		}
+	}
+	return nil
+}
+
+// ValidateAlloyDBInstance checks the AlloyDB instance URI.
+func ValidateAlloyDBInstance(uri string) error {
+	if !alloyDBInstanceRe.MatchString(uri) {
+		return fmt.Errorf("invalid AlloyDB instance %q: expected projects/<p>/locations/<r>/clusters/<c>/instances/<i>", uri)
+	}
+	return nil
+}
+
+// CloudSQL starts a Cloud SQL connector for cfg.Instance.
+func CloudSQL(ctx context.Context, cfg GCPConfig) (*GCP, error) {
+	if err := ValidateCloudSQLInstance(cfg.Instance); err != nil {
+		return nil, err
+	}
+	opts := []cloudsqlconn.Option{cloudsqlconn.WithUserAgent(gcpUserAgent)}
+	if cfg.IAMAuthN {
+		opts = append(opts, cloudsqlconn.WithIAMAuthN())
+	}
+	if cfg.CredentialsFile != "" {
+		opts = append(opts, cloudsqlconn.WithCredentialsFile(cfg.CredentialsFile))
+	}
+	if cfg.PrivateIP {
+		opts = append(opts, cloudsqlconn.WithDefaultDialOptions(cloudsqlconn.WithPrivateIP()))
+	}
+	d, err := cloudsqlconn.NewDialer(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("cloud sql connector: %w", err)
+	}
+	return &GCP{
+		instance: cfg.Instance,
+		dial: func(ctx context.Context, instance string) (net.Conn, error) {
+			return d.Dial(ctx, instance)
+		},
+		close: d.Close,
+	}, nil
+}
+
+// AlloyDB starts an AlloyDB connector for cfg.Instance.
+func AlloyDB(ctx context.Context, cfg GCPConfig) (*GCP, error) {
+	if err := ValidateAlloyDBInstance(cfg.Instance); err != nil {
+		return nil, err
+	}
+	opts := []alloydbconn.Option{alloydbconn.WithUserAgent(gcpUserAgent)}
+	if cfg.IAMAuthN {
+		opts = append(opts, alloydbconn.WithIAMAuthN())
+	}
+	if cfg.CredentialsFile != "" {
+		opts = append(opts, alloydbconn.WithCredentialsFile(cfg.CredentialsFile))
+	}
+	d, err := alloydbconn.NewDialer(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("alloydb connector: %w", err)
+	}
+	return &GCP{
+		instance: cfg.Instance,
+		dial: func(ctx context.Context, instance string) (net.Conn, error) {
+			return d.Dial(ctx, instance)
+		},
+		close: d.Close,
+	}, nil
+}
+
+// DialContext opens an encrypted connection to the instance whatever address
+// is requested, so the host and port of the connection URL are ignored.
+func (g *GCP) DialContext(ctx context.Context, _, _ string) (net.Conn, error) {
+	conn, err := g.dial(ctx, g.instance)
+	if err != nil {
+		return nil, fmt.Errorf("connect to %s: %w", g.instance, err)
+	}
+	return conn, nil
+}
+
+// Close stops certificate refreshes of the connector.
+func (g *GCP) Close() error {
+	return g.close()
+}
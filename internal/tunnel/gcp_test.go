@@ -0,0 +1,25 @@
+package tunnel
+
+import "testing"
+
+// TestValidateGCPInstances verifies Cloud SQL connection names and AlloyDB instance URIs.
+func TestValidateGCPInstances(t *testing.T) {
+	for _, ok := range []string{"proj:europe-west1:main", "example.com:proj:us-central1:db"} {
+		if err := ValidateCloudSQLInstance(ok); err != nil {
+			t.Errorf("ValidateCloudSQLInstance(%q) error = %v", ok, err)
+		}
+	}
+	for _, bad := range []string{"main", "proj::main", "a:b:c:d:e"} {
+		if err := ValidateCloudSQLInstance(bad); err == nil {
+			t.Errorf("ValidateCloudSQLInstance(%q) should fail", bad)
+		}
+	}
+	if err := ValidateAlloyDBInstance("projects/p/locations/europe-west1/clusters/c/instances/primary"); err != nil {
+		t.Errorf("ValidateAlloyDBInstance() error = %v", err)
+	}
+	for _, bad := range []string{"p:r:i", "projects/p/locations/r/clusters/c", "projects/p/locations/r/clusters/c/instances/"} {
+		if err := ValidateAlloyDBInstance(bad); err == nil {
+			t.Errorf("ValidateAlloyDBInstance(%q) should fail", bad)
+		}
+	}
+}
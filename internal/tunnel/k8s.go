@@ -0,0 +1,158 @@
+package tunnel
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultPostgresPort is the remote port used when a port-forward target has none.
+const DefaultPostgresPort = 5432
+
+// portForwardReadyTimeout bounds how long kubectl may take to start forwarding.
+const portForwardReadyTimeout = 30 * time.Second
+
+// forwardingRe matches kubectl's "Forwarding from 127.0.0.1:54321 -> 5432".
+var forwardingRe = regexp.MustCompile(`Forwarding from (127\.0\.0\.1|\[::1\]):(\d+) ->`)
+
+// PortForwardTarget is a pod or service in a namespace.
+type PortForwardTarget struct {
+	Namespace string
+	Kind      string // pod or service
+	Name      string
+	Port      int
+}
+
+// ParsePortForwardTarget parses namespace/name[:port], namespace/pod/name[:port]
+// or namespace/svc/name[:port]. The kind defaults to pod and the port to 5432.
+func ParsePortForwardTarget(spec string) (PortForwardTarget, error) {
+	t := PortForwardTarget{Kind: "pod", Port: DefaultPostgresPort}
+	rest := spec
+	if i := strings.LastIndex(rest, ":"); i >= 0 {
+		p, err := strconv.Atoi(rest[i+1:])
+		if err != nil || p <= 0 || p > 65535 {
+			return t, fmt.Errorf("invalid port in %q", spec)
+		}
+		t.Port, rest = p, rest[:i]
+	}
+	parts := strings.Split(rest, "/")
+	switch len(parts) {
+	case 2:
+		t.Namespace, t.Name = parts[0], parts[1]
+	case 3:
+		t.Namespace, t.Name = parts[0], parts[2]
+		switch parts[1] {
+		case "pod", "pods", "po":
+			t.Kind = "pod"
+		case "svc", "service", "services":
+			t.Kind = "service"
+		default:
+			return t, fmt.Errorf("unsupported kind %q in %q: use pod or svc", parts[1], spec)
+		}
+	default:
+		return t, fmt.Errorf("invalid port-forward target %q: expected namespace/[svc/]name[:port]", spec)
+	}
+	if t.Namespace == "" || t.Name == "" {
+		return t, fmt.Errorf("invalid port-forward target %q: namespace and name are required", spec)
+	}
+	return t, nil
+}
+
+// PortForward is a running kubectl port-forward.
+type PortForward struct {
+	addr string
+	cmd  *exec.Cmd
+	done chan struct{}
+}
+
+// StartPortForward runs kubectl port-forward to a random local port for the
+// target and waits until it is ready. kubectl handles authentication with the
+// kube API using the current kubeconfig; kubeContext selects a context.
+func StartPortForward(ctx context.Context, kubectl, kubeContext string, t PortForwardTarget) (*PortForward, error) {
+	if kubectl == "" {
+		kubectl = "kubectl"
+	}
+	args := []string{"port-forward", "--namespace", t.Namespace, "--address", "127.0.0.1"}
+	if kubeContext != "" {
+		args = append(args, "--context", kubeContext)
+	}
+	args = append(args, t.Kind+"/"+t.Name, ":"+strconv.Itoa(t.Port))
+
+	// The forward outlives ctx (which only bounds startup) and is stopped by Close.
+	cmd := exec.Command(kubectl, args...)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("start kubectl: %w", err)
+	}
+	pf := &PortForward{cmd: cmd, done: make(chan struct{})}
+
+	ready := make(chan string, 1)
+	go func() {
+		sc := bufio.NewScanner(stdout)
+		for sc.Scan() {
+			if m := forwardingRe.FindStringSubmatch(sc.Text()); m != nil {
+				select {
+				case ready <- net.JoinHostPort(strings.Trim(m[1], "[]"), m[2]):
+				default:
+				}
+			}
+		}
+		_, _ = io.Copy(io.Discard, stdout)
+	}()
+	go func() {
+		_ = cmd.Wait()
+		close(pf.done)
+	}()
+
+	timer := time.NewTimer(portForwardReadyTimeout)
+	defer timer.Stop()
+	select {
+	case pf.addr = <-ready:
+		return pf, nil
+	case <-pf.done:
+		return nil, fmt.Errorf("kubectl port-forward exited: %s", strings.TrimSpace(stderr.String()))
+	case <-ctx.Done():
+		pf.Close()
+		return nil, ctx.Err()
+	case <-timer.C:
+		pf.Close()
+		return nil, errors.New("kubectl port-forward did not become ready in time")
+	}
+}
+
+// Addr is the local address forwarded to the target.
+func (pf *PortForward) Addr() string {
+	return pf.addr
+}
+
+// DialContext connects to the forwarded port whatever address is requested,
+// so the host and port of the connection URL are ignored.
+func (pf *PortForward) DialContext(ctx context.Context, network, _ string) (net.Conn, error) {
+	var d net.Dialer
+	return d.DialContext(ctx, network, pf.addr)
+}
+
+// Close stops kubectl and waits for it to exit.
+func (pf *PortForward) Close() error {
+	select {
+	case <-pf.done:
+		return nil
+	default:
+	}
+	_ = pf.cmd.Process.Kill()
+	<-pf.done
+	return nil
+}
@@ -0,0 +1,97 @@
+package tunnel
+
+import (
+	"context"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// TestParsePortForwardTarget verifies kind and port defaults of port-forward targets.
+func TestParsePortForwardTarget(t *testing.T) {
+	tests := []struct {
+		spec     string
+		expected PortForwardTarget
+	}{
+		{"db/postgres-0:5432", PortForwardTarget{"db", "pod", "postgres-0", 5432}},
+		{"db/postgres-0", PortForwardTarget{"db", "pod", "postgres-0", 5432}},
+		{"db/svc/postgres:6432", PortForwardTarget{"db", "service", "postgres", 6432}},
+		{"db/pod/postgres-1", PortForwardTarget{"db", "pod", "postgres-1", 5432}},
+	}
+	for _, tt := range tests {
+		got, err := ParsePortForwardTarget(tt.spec)
+		if err != nil || got != tt.expected {
+			t.Errorf("ParsePortForwardTarget(%q) = %+v, %v; expected %+v", tt.spec, got, err, tt.expected)
+		}
+	}
+	for _, bad := range []string{"postgres-0", "db/", "db/deploy/pg", "db/pg:0", "db/pg:x", "a/b/c/d"} {
+		if _, err := ParsePortForwardTarget(bad); err == nil {
+			t.Errorf("ParsePortForwardTarget(%q) should fail", bad)
+		}
+	}
+}
+
+// TestStartPortForward verifies the forwarded address is taken from kubectl
+// output and that dials ignore the requested address.
+func TestStartPortForward(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake kubectl is a shell script")
+	}
+	echo, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer echo.Close()
+	go func() {
+		for {
+			c, err := echo.Accept()
+			if err != nil {
+				return
+			}
+			go func() { _, _ = io.Copy(c, c); _ = c.Close() }()
+		}
+	}()
+	_, port, _ := net.SplitHostPort(echo.Addr().String())
+
+	kubectl := filepath.Join(t.TempDir(), "kubectl")
+	script := "#!/bin/sh\necho \"Forwarding from 127.0.0.1:" + port + " -> 5432\"\nexec sleep 60\n"
+	if err := os.WriteFile(kubectl, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	pf, err := StartPortForward(ctx, kubectl, "", PortForwardTarget{"db", "pod", "postgres-0", 5432})
+	if err != nil {
+		t.Fatalf("StartPortForward() error = %v", err)
+	}
+	defer pf.Close()
+	if pf.Addr() != echo.Addr().String() {
+		t.Errorf("Addr() = %q, expected %q", pf.Addr(), echo.Addr().String())
+	}
+
+	conn, err := pf.DialContext(ctx, "tcp", "db.internal:5432")
+	if err != nil {
+		t.Fatalf("DialContext() error = %v", err)
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte("ping")); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 4)
+	if _, err := io.ReadFull(conn, buf); err != nil || string(buf) != "ping" {
+		t.Errorf("port-forward echo = %q, %v; expected ping", buf, err)
+	}
+
+	failing := filepath.Join(t.TempDir(), "kubectl")
+	if err := os.WriteFile(failing, []byte("#!/bin/sh\necho 'pods \"x\" not found' >&2\nexit 1\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := StartPortForward(ctx, failing, "", PortForwardTarget{"db", "pod", "x", 5432}); err == nil {
+		t.Error("StartPortForward() should fail when kubectl exits")
+	}
+}
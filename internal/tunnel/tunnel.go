@@ -0,0 +1,208 @@
+// Package tunnel provides dialers that reach the database through an SSH
+// bastion (jump host), a SOCKS5 proxy, a Kubernetes port-forward or the Cloud
+// SQL / AlloyDB connectors, so production databases that are not directly
+// reachable can be checked without wrapping pghealth in manual tunnels.
+package tunnel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+	"golang.org/x/net/proxy"
+)
+
+// DialFunc opens a network connection to addr.
+type DialFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// DefaultSSHPort is used when the SSH target has no port.
+const DefaultSSHPort = "22"
+
+// sshHandshakeTimeout bounds connecting and authenticating to the bastion.
+const sshHandshakeTimeout = 15 * time.Second
+
+// SSHConfig describes the bastion the database is reached through.
+type SSHConfig struct {
+	Target     string // user@host[:port]
+	KeyFile    string // private key file; ssh-agent (SSH_AUTH_SOCK) is used as well when available
+	Passphrase string // passphrase of an encrypted KeyFile
+	KnownHosts string // known_hosts file; ~/.ssh/known_hosts when empty
+}
+
+// SSH is an open connection to a bastion host.
+type SSH struct {
+	client *ssh.Client
+}
+
+// ParseSSHTarget splits user@host[:port]. The user defaults to the current OS
+// user and the port to 22.
+func ParseSSHTarget(target string) (username, addr string, err error) {
+	host := target
+	if i := strings.LastIndex(target, "@"); i >= 0 {
+		username, host = target[:i], target[i+1:]
+	}
+	if host == "" {
+		return "", "", fmt.Errorf("invalid ssh target %q: expected user@host[:port]", target)
+	}
+	if _, _, splitErr := net.SplitHostPort(host); splitErr != nil {
+		host = net.JoinHostPort(strings.Trim(host, "[]"), DefaultSSHPort)
+	}
+	if username == "" {
+		u, err := user.Current()
+		if err != nil {
+			return "", "", fmt.Errorf("ssh target %q has no user: %w", target, err)
+		}
+		username = u.Username
+	}
+	return username, host, nil
+}
+
+// DialSSH connects and authenticates to the bastion. Host keys are verified
+// against the known_hosts file; unknown hosts are rejected.
+func DialSSH(ctx context.Context, cfg SSHConfig) (*SSH, error) {
+	username, addr, err := ParseSSHTarget(cfg.Target)
+	if err != nil {
+		return nil, err
+	}
+	auth, err := authMethods(cfg)
+	if err != nil {
+		return nil, err
+	}
+	hostKeys, err := hostKeyCallback(cfg.KnownHosts)
+	if err != nil {
+		return nil, err
+	}
+	clientCfg := &ssh.ClientConfig{
+		User:            username,
+		Auth:            auth,
+		HostKeyCallback: hostKeys,
+		Timeout:         sshHandshakeTimeout,
+	}
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("ssh dial %s: %w", addr, err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	c, chans, reqs, err := ssh.NewClientConn(conn, addr, clientCfg)
+	if err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("ssh handshake with %s: %w", addr, err)
+	}
+	_ = conn.SetDeadline(time.Time{})
+	return &SSH{client: ssh.NewClient(c, chans, reqs)}, nil
+}
+
+// DialContext opens a connection to addr from the bastion.
+func (t *SSH) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	type result struct {
+		conn net.Conn
+		err  error
+	}
+	ch := make(chan result, 1)
+	go func() {
+		c, err := t.client.Dial(network, addr)
+		ch <- result{c, err}
+	}()
+	select {
+	case r := <-ch:
+		if r.err != nil {
+			return nil, fmt.Errorf("ssh tunnel to %s: %w", addr, r.err)
+		}
+		return r.conn, nil
+	case <-ctx.Done():
+		go func() {
+			if r := <-ch; r.conn != nil {
+				_ = r.conn.Close()
+			}
+		}()
+		return nil, ctx.Err()
+	}
+}
+
+// Close closes the bastion connection and all tunnels through it.
+func (t *SSH) Close() error {
+	return t.client.Close()
+}
+
+// authMethods combines the key file and the ssh-agent, in that order.
+func authMethods(cfg SSHConfig) ([]ssh.AuthMethod, error) {
+	var methods []ssh.AuthMethod
+	if cfg.KeyFile != "" {
+		key, err := os.ReadFile(cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("read ssh key: %w", err)
+		}
+		var signer ssh.Signer
+		if cfg.Passphrase != "" {
+			signer, err = ssh.ParsePrivateKeyWithPassphrase(key, []byte(cfg.Passphrase))
+		} else {
+			signer, err = ssh.ParsePrivateKey(key)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("parse ssh key %s: %w", cfg.KeyFile, err)
+		}
+		methods = append(methods, ssh.PublicKeys(signer))
+	}
+	if sock := os.Getenv("SSH_AUTH_SOCK"); sock != "" {
+		if conn, err := net.Dial("unix", sock); err == nil {
+			methods = append(methods, ssh.PublicKeysCallback(agent.NewClient(conn).Signers))
+		}
+	}
+	if len(methods) == 0 {
+		return nil, errors.New("no ssh credentials: pass -ssh-key or run an ssh-agent")
+	}
+	return methods, nil
+}
+
+// hostKeyCallback verifies host keys against a known_hosts file.
+func hostKeyCallback(path string) (ssh.HostKeyCallback, error) {
+	if path == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, fmt.Errorf("locate known_hosts: %w", err)
+		}
+		path = filepath.Join(home, ".ssh", "known_hosts")
+	}
+	cb, err := knownhosts.New(path)
+	if err != nil {
+		return nil, fmt.Errorf("load known_hosts %s: %w", path, err)
+	}
+	return cb, nil
+}
+
+// SOCKS5 returns a dialer connecting through the proxy at proxyURL
+// (socks5://[user:pass@]host:port). Host names are resolved by the proxy.
+func SOCKS5(proxyURL string) (DialFunc, error) {
+	u, err := url.Parse(proxyURL)
+	if err != nil || (u.Scheme != "socks5" && u.Scheme != "socks5h") || u.Host == "" {
+		return nil, fmt.Errorf("invalid proxy %q: expected socks5://[user:pass@]host:port", proxyURL)
+	}
+	var auth *proxy.Auth
+	if u.User != nil {
+		pass, _ := u.User.Password()
+		auth = &proxy.Auth{User: u.User.Username(), Password: pass}
+	}
+	d, err := proxy.SOCKS5("tcp", u.Host, auth, proxy.Direct)
+	if err != nil {
+		return nil, fmt.Errorf("socks5 proxy: %w", err)
+	}
+	cd, ok := d.(proxy.ContextDialer)
+	if !ok {
+		return nil, errors.New("socks5 proxy: dialer does not support contexts")
+	}
+	return cd.DialContext, nil
+}
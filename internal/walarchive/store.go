@@ -0,0 +1,199 @@
+package walarchive
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/koltyakov/pghealth/internal/httputil"
+	"github.com/koltyakov/pghealth/internal/sigv4"
+)
+
+// store lists an archive relative to its root.
+type store interface {
+	// list returns the file names in the directory of prefix that start
+	// with its last element.
+	list(ctx context.Context, prefix string) ([]string, error)
+	// dirs returns the subdirectories of prefix as prefixes ending in "/".
+	dirs(ctx context.Context, prefix string) ([]string, error)
+}
+
+// openStore returns the store for an s3://bucket/prefix URL or a directory.
+func openStore(rawURL string) (store, error) {
+	if strings.HasPrefix(rawURL, "/") {
+		return dirStore(rawURL), nil
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme != "s3" || u.Host == "" {
+		return nil, fmt.Errorf("unsupported archive location %q: expected s3://bucket/prefix or a local directory", rawURL)
+	}
+	return newS3Store(u.Host, strings.Trim(u.Path, "/"))
+}
+
+// dirStore is an archive in a local (or mounted) directory.
+type dirStore string
+
+func (d dirStore) list(_ context.Context, prefix string) ([]string, error) {
+	dir, base := path.Split(prefix)
+	entries, err := os.ReadDir(filepath.Join(string(d), dir))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var out []string
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasPrefix(e.Name(), base) {
+			out = append(out, e.Name())
+		}
+	}
+	return out, nil
+}
+
+func (d dirStore) dirs(_ context.Context, prefix string) ([]string, error) {
+	entries, err := os.ReadDir(filepath.Join(string(d), prefix))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var out []string
+	for _, e := range entries {
+		if e.IsDir() {
+			out = append(out, prefix+e.Name()+"/")
+		}
+	}
+	return out, nil
+}
+
+// s3Store lists objects with ListObjectsV2, signing requests with AWS
+// Signature Version 4 from the standard AWS_* environment variables.
+// AWS_ENDPOINT_URL_S3 or AWS_ENDPOINT_URL select an S3-compatible service
+// (MinIO, Ceph), addressed path-style.
+type s3Store struct {
+	bucket   string
+	root     string // key prefix of the archive, without trailing slash
+	endpoint string // scheme://host, path-style when set
+	region   string
+	creds    sigv4.Credentials
+}
+
+func newS3Store(bucket, root string) (*s3Store, error) {
+	s := &s3Store{
+		bucket:   bucket,
+		root:     root,
+		endpoint: strings.TrimRight(sigv4.FirstEnv("AWS_ENDPOINT_URL_S3", "AWS_ENDPOINT_URL", "AWS_ENDPOINT"), "/"),
+		region:   sigv4.Region(),
+		creds:    sigv4.FromEnv(),
+	}
+	if s.creds.AccessKeyID == "" || s.creds.SecretAccessKey == "" {
+		return nil, errors.New("checking an S3 archive needs AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY")
+	}
+	if s.region == "" {
+		s.region = "us-east-1"
+	}
+	return s, nil
+}
+
+// listResult is the ListObjectsV2 response.
+type listResult struct {
+	Contents []struct {
+		Key string `xml:"Key"`
+	} `xml:"Contents"`
+	CommonPrefixes []struct {
+		Prefix string `xml:"Prefix"`
+	} `xml:"CommonPrefixes"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+func (s *s3Store) list(ctx context.Context, prefix string) ([]string, error) {
+	var out []string
+	err := s.listObjects(ctx, prefix, func(r listResult) {
+		for _, c := range r.Contents {
+			out = append(out, path.Base(c.Key))
+		}
+	})
+	return out, err
+}
+
+func (s *s3Store) dirs(ctx context.Context, prefix string) ([]string, error) {
+	var out []string
+	err := s.listObjects(ctx, prefix, func(r listResult) {
+		for _, p := range r.CommonPrefixes {
+			out = append(out, strings.TrimPrefix(p.Prefix, s.rootPrefix()))
+		}
+	})
+	return out, err
+}
+
+// rootPrefix is the key prefix of the archive root.
+func (s *s3Store) rootPrefix() string {
+	if s.root == "" {
+		return ""
+	}
+	return s.root + "/"
+}
+
+// listObjects pages through the objects directly under prefix.
+func (s *s3Store) listObjects(ctx context.Context, prefix string, page func(listResult)) error {
+	q := url.Values{}
+	q.Set("list-type", "2")
+	q.Set("prefix", s.rootPrefix()+prefix)
+	q.Set("delimiter", "/")
+	for {
+		var r listResult
+		if err := s.get(ctx, q, &r); err != nil {
+			return err
+		}
+		page(r)
+		if !r.IsTruncated || r.NextContinuationToken == "" {
+			return nil
+		}
+		q.Set("continuation-token", r.NextContinuationToken)
+	}
+}
+
+// get sends a signed GET to the bucket and decodes the XML response.
+func (s *s3Store) get(ctx context.Context, q url.Values, v any) error {
+	host, uri, scheme := s.bucket+".s3."+s.region+".amazonaws.com", "/", "https"
+	if s.endpoint != "" {
+		u, err := url.Parse(s.endpoint)
+		if err != nil {
+			return fmt.Errorf("invalid S3 endpoint %q: %w", s.endpoint, err)
+		}
+		host, uri, scheme = u.Host, "/"+s.bucket, u.Scheme
+	}
+	// SigV4 requires RFC 3986 encoding: spaces as %20, not +
+	query := strings.ReplaceAll(q.Encode(), "+", "%20")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, scheme+"://"+host+uri+"?"+query, nil)
+	if err != nil {
+		return fmt.Errorf("build request: %w", err)
+	}
+	// S3 requires the payload hash as a header too
+	req.Header.Set("X-Amz-Content-Sha256", sigv4.Hash(""))
+	sigv4.Sign(req, sigv4.Hash(""), s.creds, s.region, "s3", time.Now().UTC())
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("list s3://%s: %w", s.bucket, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("list s3://%s: %s: %s", s.bucket, resp.Status, httputil.ReadErrorBody(resp.Body))
+	}
+	if err := xml.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("list s3://%s: %w", s.bucket, err)
+	}
+	return nil
+}
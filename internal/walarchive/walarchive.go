@@ -0,0 +1,267 @@
+// Package walarchive locates the WAL archive written by archive_command
+// (WAL-G, pgBackRest, barman-cloud or a plain copy) and checks that the most
+// recently archived segments are actually present in it, in S3 or a local
+// directory. A gap breaks point-in-time recovery past it.
+package walarchive
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/koltyakov/pghealth/internal/collect"
+)
+
+const (
+	// DefaultTimeout bounds the archive lookups.
+	DefaultTimeout = 30 * time.Second
+
+	// Segments is how many of the latest archived segments are checked.
+	Segments = 16
+
+	// Auto derives the archive destination from archive_command.
+	Auto = "auto"
+
+	// walNameLen is the length of a WAL segment file name.
+	walNameLen = 24
+)
+
+// Supported archiving tools.
+const (
+	ToolWALG        = "wal-g"
+	ToolPgBackRest  = "pgbackrest"
+	ToolBarmanCloud = "barman-cloud"
+	ToolCopy        = "copy"
+)
+
+// Target is where and how archive_command stores segments.
+type Target struct {
+	Tool   string
+	URL    string // s3://bucket/prefix or a directory; empty when not derivable
+	Stanza string // pgBackRest stanza
+	Server string // barman-cloud server name
+}
+
+// Parse recognizes the archiving tool of archive_command and, where the
+// command says, the destination. WAL-G reads WALG_S3_PREFIX (or
+// WALG_FILE_PREFIX) from the environment or an envdir directory; pgBackRest
+// keeps its repository in pgbackrest.conf, so its URL stays empty.
+func Parse(command string) Target {
+	fields := strings.Fields(command)
+	for i := range fields {
+		fields[i] = strings.Trim(fields[i], `'"`)
+	}
+	var t Target
+	for i, f := range fields {
+		switch path.Base(f) {
+		case "wal-g":
+			if i+1 < len(fields) && fields[i+1] == "wal-push" {
+				t.Tool = ToolWALG
+				t.URL = walgPrefix(fields[:i])
+				return t
+			}
+		case "pgbackrest":
+			t.Tool = ToolPgBackRest
+			for j, a := range fields[i+1:] {
+				if s, ok := strings.CutPrefix(a, "--stanza="); ok {
+					t.Stanza = s
+				} else if a == "--stanza" && i+j+2 < len(fields) {
+					t.Stanza = fields[i+j+2]
+				}
+			}
+			return t
+		case "barman-cloud-wal-archive":
+			// barman-cloud-wal-archive [options] destination server-name wal-path
+			var args []string
+			for _, a := range fields[i+1:] {
+				if !strings.HasPrefix(a, "-") {
+					args = append(args, a)
+				}
+			}
+			t.Tool = ToolBarmanCloud
+			if len(args) >= 2 {
+				t.URL, t.Server = args[0], args[1]
+			}
+			return t
+		}
+	}
+	// A plain copy names the destination file: cp %p /archive/%f,
+	// aws s3 cp %p s3://bucket/wal/%f
+	for _, f := range fields {
+		dir, ok := strings.CutSuffix(f, "%f")
+		if !ok || !(strings.HasPrefix(dir, "s3://") || strings.HasPrefix(dir, "/")) {
+			continue
+		}
+		return Target{Tool: ToolCopy, URL: dir}
+	}
+	return t
+}
+
+// walgPrefix returns the WAL-G storage prefix from an "envdir <dir>" wrapper
+// or the environment.
+func walgPrefix(wrapper []string) string {
+	for i, f := range wrapper {
+		if path.Base(f) == "envdir" && i+1 < len(wrapper) {
+			for _, name := range []string{"WALG_S3_PREFIX", "WALG_FILE_PREFIX"} {
+				if b, err := os.ReadFile(filepath.Join(wrapper[i+1], name)); err == nil {
+					return strings.TrimSpace(string(b))
+				}
+			}
+		}
+	}
+	for _, name := range []string{"WALG_S3_PREFIX", "WALG_FILE_PREFIX"} {
+		if v := os.Getenv(name); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// Recent returns last and the n-1 segments before it on the same timeline,
+// newest first.
+func Recent(last string, n int, segmentSize int64) []string {
+	if len(last) != walNameLen || segmentSize <= 0 {
+		return nil
+	}
+	tli, err1 := strconv.ParseUint(last[:8], 16, 32)
+	log, err2 := strconv.ParseUint(last[8:16], 16, 32)
+	seg, err3 := strconv.ParseUint(last[16:], 16, 32)
+	if err1 != nil || err2 != nil || err3 != nil {
+		return nil
+	}
+	perLog := uint64(0x100000000 / segmentSize)
+	var out []string
+	for i := 0; i < n; i++ {
+		out = append(out, fmt.Sprintf("%08X%08X%08X", tli, log, seg))
+		if seg == 0 {
+			if log == 0 {
+				break
+			}
+			log, seg = log-1, perLog-1
+		} else {
+			seg--
+		}
+	}
+	return out
+}
+
+// Check looks up the latest archived segments of wa in the archive at url
+// (Auto derives it from archive_command).
+func Check(ctx context.Context, wa collect.WALArchiving, url string) *collect.WALArchiveCheck {
+	t := Parse(wa.Command)
+	if url != Auto {
+		t.URL = url
+	}
+	c := &collect.WALArchiveCheck{Tool: t.Tool, Destination: t.URL}
+	if t.Tool == "" && url == Auto {
+		c.Error = "archive_command uses no known tool (WAL-G, pgBackRest, barman-cloud, cp or aws s3 cp); pass the destination with -wal-archive-url"
+		return c
+	}
+	if t.URL == "" {
+		c.Error = "the archive destination is not in archive_command; pass it with -wal-archive-url"
+		return c
+	}
+	c.Checked = Recent(wa.LastArchivedWAL, Segments, wa.SegmentSize)
+	if len(c.Checked) == 0 {
+		c.Error = "no segment archived yet"
+		return c
+	}
+	st, err := openStore(t.URL)
+	if err != nil {
+		c.Error = err.Error()
+		return c
+	}
+	found, err := lookup(ctx, st, t, c.Checked)
+	if err != nil {
+		c.Error = err.Error()
+		return c
+	}
+	c.Missing = missing(c.Checked, found)
+	return c
+}
+
+// lookup lists the archive directories holding segments and returns the
+// segment names present.
+func lookup(ctx context.Context, st store, t Target, segments []string) (map[string]bool, error) {
+	base := ""
+	switch t.Tool {
+	case ToolPgBackRest:
+		if t.Stanza == "" {
+			return nil, fmt.Errorf("pgbackrest archive_command has no --stanza")
+		}
+		dirs, err := st.dirs(ctx, "archive/"+t.Stanza+"/")
+		if err != nil {
+			return nil, err
+		}
+		if len(dirs) == 0 {
+			return nil, fmt.Errorf("no archive/%s/<version> directory in the repository", t.Stanza)
+		}
+		base = latestVersionDir(dirs)
+	case ToolBarmanCloud:
+		base = t.Server + "/wals/"
+	case ToolWALG:
+		base = "wal_005/"
+	}
+
+	found := map[string]bool{}
+	listed := map[string]bool{}
+	for _, s := range segments {
+		// Segments of one log file share their first 16 characters
+		prefix := base + s[:16]
+		if t.Tool == ToolPgBackRest || t.Tool == ToolBarmanCloud {
+			prefix += "/"
+		}
+		if listed[prefix] {
+			continue
+		}
+		listed[prefix] = true
+		names, err := st.list(ctx, prefix)
+		if err != nil {
+			return nil, err
+		}
+		for _, n := range names {
+			if len(n) >= walNameLen {
+				found[strings.ToUpper(n[:walNameLen])] = true
+			}
+		}
+	}
+	return found, nil
+}
+
+// missing returns the segments absent from the archive, newest first. Older
+// segments only count when a still older one is present: a timeline starts
+// partway through the window, and the segments before it were archived
+// under the previous timeline.
+func missing(segments []string, found map[string]bool) []string {
+	oldest := -1
+	for i, s := range segments {
+		if found[s] {
+			oldest = i
+		}
+	}
+	var out []string
+	for i, s := range segments {
+		if !found[s] && (i == 0 || i < oldest) {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// latestVersionDir picks the newest pgBackRest "<pg version>-<id>" archive
+// directory.
+func latestVersionDir(dirs []string) string {
+	id := func(d string) int {
+		_, n, _ := strings.Cut(path.Base(strings.TrimSuffix(d, "/")), "-")
+		v, _ := strconv.Atoi(n)
+		return v
+	}
+	sort.Slice(dirs, func(i, j int) bool { return id(dirs[i]) < id(dirs[j]) })
+	return dirs[len(dirs)-1]
+}
@@ -0,0 +1,172 @@
+package walarchive
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/koltyakov/pghealth/internal/collect"
+)
+
+const segSize = 16 << 20
+
+// TestParse verifies that the archiving tool and destination are recognized
+// in common archive_command forms.
+func TestParse(t *testing.T) {
+	envdir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(envdir, "WALG_S3_PREFIX"), []byte("s3://backups/pg\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("WALG_S3_PREFIX", "")
+	t.Setenv("WALG_FILE_PREFIX", "")
+
+	tests := []struct {
+		command string
+		want    Target
+	}{
+		{"envdir " + envdir + " wal-g wal-push %p", Target{Tool: ToolWALG, URL: "s3://backups/pg"}},
+		{"/usr/local/bin/wal-g wal-push %p", Target{Tool: ToolWALG}},
+		{"pgbackrest --stanza=main archive-push %p", Target{Tool: ToolPgBackRest, Stanza: "main"}},
+		{"pgbackrest --stanza main archive-push %p", Target{Tool: ToolPgBackRest, Stanza: "main"}},
+		{"barman-cloud-wal-archive --gzip s3://barman/pg pg1 %p", Target{Tool: ToolBarmanCloud, URL: "s3://barman/pg", Server: "pg1"}},
+		{"test ! -f /mnt/archive/%f && cp %p /mnt/archive/%f", Target{Tool: ToolCopy, URL: "/mnt/archive/"}},
+		{"aws s3 cp %p 's3://bucket/wal/%f'", Target{Tool: ToolCopy, URL: "s3://bucket/wal/"}},
+		{"rsync -a %p backup:/archive/%f", Target{}},
+		{"", Target{}},
+	}
+	for _, tt := range tests {
+		if got := Parse(tt.command); got != tt.want {
+			t.Errorf("Parse(%q) = %+v, want %+v", tt.command, got, tt.want)
+		}
+	}
+
+	t.Setenv("WALG_S3_PREFIX", "s3://env/prefix")
+	if got := Parse("wal-g wal-push %p"); got.URL != "s3://env/prefix" {
+		t.Errorf("Parse with WALG_S3_PREFIX: URL = %q", got.URL)
+	}
+}
+
+// TestRecent verifies that segment names step back across log file
+// boundaries for the segment size.
+func TestRecent(t *testing.T) {
+	got := Recent("000000020000000A00000001", 4, segSize)
+	want := []string{
+		"000000020000000A00000001",
+		"000000020000000A00000000",
+		"0000000200000009000000FF",
+		"0000000200000009000000FE",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Recent 16MB = %v, want %v", got, want)
+	}
+	if got := Recent("000000010000000300000000", 2, 1<<30); got[1] != "000000010000000200000003" {
+		t.Errorf("Recent 1GB = %v", got)
+	}
+	if got := Recent("000000010000000000000001", 5, segSize); len(got) != 2 {
+		t.Errorf("Recent at the first segment = %v, want 2 names", got)
+	}
+	if got := Recent("00000001.history", 5, segSize); got != nil {
+		t.Errorf("Recent(history file) = %v, want nil", got)
+	}
+}
+
+// TestCheckDir verifies a copy archive in a local directory: a missing
+// segment inside the window is reported, segments older than a timeline
+// start are not.
+func TestCheckDir(t *testing.T) {
+	dir := t.TempDir()
+	segments := Recent("000000010000000A00000010", Segments, segSize)
+	for i, s := range segments {
+		if i == 3 {
+			continue
+		}
+		name := s
+		if i == 0 {
+			name += ".gz"
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wa := collect.WALArchiving{Command: "cp %p " + dir + "/%f", LastArchivedWAL: segments[0], SegmentSize: segSize}
+
+	c := Check(context.Background(), wa, Auto)
+	if c.Error != "" {
+		t.Fatalf("Check error: %s", c.Error)
+	}
+	if c.Tool != ToolCopy || len(c.Checked) != Segments {
+		t.Errorf("Check = %+v", c)
+	}
+	if want := []string{segments[3]}; !reflect.DeepEqual(c.Missing, want) {
+		t.Errorf("Missing = %v, want %v", c.Missing, want)
+	}
+
+	// A new timeline: only its first segments exist, nothing is missing
+	wa.LastArchivedWAL = "000000020000000A00000012"
+	if err := os.WriteFile(filepath.Join(dir, "000000020000000A00000011"), nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "000000020000000A00000012"), nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if c := Check(context.Background(), wa, dir); c.Error != "" || len(c.Missing) != 0 {
+		t.Errorf("Check after a timeline switch = %+v, want nothing missing", c)
+	}
+
+	if c := Check(context.Background(), collect.WALArchiving{Command: "/opt/archive.sh %p"}, Auto); c.Error == "" {
+		t.Error("Check with an unknown archive_command: want an error")
+	}
+}
+
+// TestCheckS3 verifies the ListObjectsV2 requests against an S3-compatible
+// endpoint for a pgBackRest repository.
+func TestCheckS3(t *testing.T) {
+	present := map[string]bool{}
+	segments := Recent("000000010000000500000003", 4, segSize)
+	for _, s := range segments[1:] {
+		present[s] = true
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/repo" || !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=key/") {
+			http.Error(w, "bad request", http.StatusForbidden)
+			return
+		}
+		prefix := r.URL.Query().Get("prefix")
+		fmt.Fprint(w, "<ListBucketResult>")
+		if prefix == "pgbackrest/archive/main/" {
+			fmt.Fprint(w, "<CommonPrefixes><Prefix>pgbackrest/archive/main/15-1/</Prefix></CommonPrefixes>")
+			fmt.Fprint(w, "<CommonPrefixes><Prefix>pgbackrest/archive/main/16-2/</Prefix></CommonPrefixes>")
+		}
+		for s := range present {
+			if strings.HasPrefix("pgbackrest/archive/main/16-2/"+s[:16]+"/"+s, prefix) {
+				fmt.Fprintf(w, "<Contents><Key>%s%s-0123abcd.gz</Key></Contents>", prefix, s)
+			}
+		}
+		fmt.Fprint(w, "</ListBucketResult>")
+	}))
+	defer srv.Close()
+	t.Setenv("AWS_ENDPOINT_URL_S3", srv.URL)
+	t.Setenv("AWS_ACCESS_KEY_ID", "key")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("AWS_SESSION_TOKEN", "")
+
+	wa := collect.WALArchiving{Command: "pgbackrest --stanza=main archive-push %p", LastArchivedWAL: segments[0], SegmentSize: segSize}
+	c := Check(context.Background(), wa, "s3://repo/pgbackrest")
+	if c.Error != "" {
+		t.Fatalf("Check error: %s", c.Error)
+	}
+	if want := []string{segments[0]}; !reflect.DeepEqual(c.Missing, want) {
+		t.Errorf("Missing = %v, want %v", c.Missing, want)
+	}
+
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "")
+	if c := Check(context.Background(), wa, "s3://repo/pgbackrest"); c.Error == "" {
+		t.Error("Check without credentials: want an error")
+	}
+}
@@ -0,0 +1,76 @@
+// Package webhook delivers run snapshots to an HTTP ingest endpoint.
+//
+// Payloads are signed with HMAC-SHA256 when a shared secret is configured so
+// the receiver can verify that a snapshot came from a trusted pghealth run.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/koltyakov/pghealth/internal/httputil"
+)
+
+const (
+	// SignatureHeader carries the payload signature in the form "sha256=<hex>".
+	SignatureHeader = "X-Pghealth-Signature"
+
+	// signaturePrefix identifies the signing algorithm in SignatureHeader.
+	signaturePrefix = "sha256="
+
+	// DefaultTimeout bounds a single delivery attempt.
+	DefaultTimeout = 30 * time.Second
+)
+
+// Sign returns the SignatureHeader value for body under secret.
+func Sign(body []byte, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify reports whether signature is a valid SignatureHeader value for body.
+// Comparison is constant-time.
+func Verify(body []byte, secret, signature string) bool {
+	if !strings.HasPrefix(signature, signaturePrefix) {
+		return false
+	}
+	return hmac.Equal([]byte(Sign(body, secret)), []byte(signature))
+}
+
+// Post sends body as JSON to url. When secret is non-empty the request is
+// signed via SignatureHeader. Any non-2xx response is returned as an error.
+func Post(ctx context.Context, url string, body []byte, secret, userAgent string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("build request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if userAgent != "" {
+		req.Header.Set("User-Agent", userAgent)
+	}
+	if secret != "" {
+		req.Header.Set(SignatureHeader, Sign(body, secret))
+	}
+
+	client := &http.Client{Timeout: DefaultTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("post snapshot: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("post snapshot: %s: %s", resp.Status, httputil.ReadErrorBody(resp.Body))
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return nil
+}
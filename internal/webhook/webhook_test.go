@@ -0,0 +1,64 @@
+package webhook
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestSignVerify verifies signature round-trips and rejects tampering.
+func TestSignVerify(t *testing.T) {
+	body := []byte(`{"schema":1}`)
+	sig := Sign(body, "s3cret")
+
+	if !Verify(body, "s3cret", sig) {
+		t.Error("Verify() rejected a valid signature")
+	}
+	if Verify(body, "other", sig) {
+		t.Error("Verify() accepted a signature made with another secret")
+	}
+	if Verify([]byte(`{"schema":2}`), "s3cret", sig) {
+		t.Error("Verify() accepted a tampered body")
+	}
+	if Verify(body, "s3cret", sig[len(signaturePrefix):]) {
+		t.Error("Verify() accepted a signature without prefix")
+	}
+}
+
+// TestPost verifies headers, signing and error propagation.
+func TestPost(t *testing.T) {
+	var gotSig, gotType string
+	var gotBody []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotSig = r.Header.Get(SignatureHeader)
+		gotType = r.Header.Get("Content-Type")
+		gotBody, _ = io.ReadAll(r.Body)
+		if r.URL.Path == "/fail" {
+			http.Error(w, "nope", http.StatusForbidden)
+		}
+	}))
+	defer srv.Close()
+
+	body := []byte(`{"ok":true}`)
+	if err := Post(context.Background(), srv.URL+"/ingest", body, "k", "pghealth/test"); err != nil {
+		t.Fatalf("Post() error = %v", err)
+	}
+	if gotType != "application/json" {
+		t.Errorf("Content-Type = %q", gotType)
+	}
+	if string(gotBody) != string(body) {
+		t.Errorf("body = %q, expected %q", gotBody, body)
+	}
+	if !Verify(body, "k", gotSig) {
+		t.Errorf("signature %q does not verify", gotSig)
+	}
+
+	if err := Post(context.Background(), srv.URL+"/fail", body, "", ""); err == nil {
+		t.Error("Post() expected error on 403")
+	}
+	if gotSig != "" {
+		t.Errorf("unsigned request carried signature %q", gotSig)
+	}
+}
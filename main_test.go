@@ -3,6 +3,9 @@ package main
 import (
 	"testing"
 	"time"
+
+	"github.com/koltyakov/pghealth/internal/analyze"
+	"github.com/koltyakov/pghealth/internal/collect"
 )
 
 // TestSlugify verifies the slugify function behavior.
@@ -145,29 +148,6 @@ func TestExpandOutPlaceholdersZeroTime(t *testing.T) {
 	}
 }
 
-// TestFirstNonEmpty verifies the first non-empty string selection.
-func TestFirstNonEmpty(t *testing.T) {
-	tests := []struct {
-		input    []string
-		expected string
-	}{
-		{[]string{"a", "b", "c"}, "a"},
-		{[]string{"", "b", "c"}, "b"},
-		{[]string{"", "", "c"}, "c"},
-		{[]string{"", "", ""}, ""},
-		{[]string{}, ""},
-		{[]string{"only"}, "only"},
-	}
-
-	for _, tt := range tests {
-		result := firstNonEmpty(tt.input...)
-		if result != tt.expected {
-			t.Errorf("firstNonEmpty(%v) = %q, expected %q",
-				tt.input, result, tt.expected)
-		}
-	}
-}
-
 // TestFlagsValidate verifies configuration validation.
 func TestFlagsValidate(t *testing.T) {
 	tests := []struct {
@@ -191,6 +171,159 @@ func TestFlagsValidate(t *testing.T) {
 			},
 			expectErr: true,
 		},
+		{
+			name: "dry run without URL",
+			flags: Flags{
+				Timeout: 30 * time.Second,
+				DryRun:  true,
+			},
+			expectErr: false,
+		},
+		{
+			name: "service without URL",
+			flags: Flags{
+				Service: "prod",
+				Timeout: 30 * time.Second,
+			},
+			expectErr: false,
+		},
+		{
+			name: "targets with patroni-url",
+			flags: Flags{
+				Targets:    "targets.yaml",
+				PatroniURL: "http://pg1:8008",
+				Timeout:    30 * time.Second,
+			},
+			expectErr: true,
+		},
+		{
+			name: "service with url-from",
+			flags: Flags{
+				Service: "prod",
+				URLFrom: "vault://secret/pg",
+				Timeout: 30 * time.Second,
+			},
+			expectErr: true,
+		},
+		{
+			name: "ssh and proxy combined",
+			flags: Flags{
+				URL:     "postgres://localhost/test",
+				Timeout: 30 * time.Second,
+				SSH:     "ops@bastion",
+				Proxy:   "socks5://127.0.0.1:1080",
+			},
+			expectErr: true,
+		},
+		{
+			name: "proxy and k8s port-forward combined",
+			flags: Flags{
+				URL:        "postgres://localhost/test",
+				Timeout:    30 * time.Second,
+				Proxy:      "socks5://127.0.0.1:1080",
+				K8sForward: "db/postgres-0",
+			},
+			expectErr: true,
+		},
+		{
+			name: "invalid k8s port-forward target",
+			flags: Flags{
+				URL:        "postgres://localhost/test",
+				Timeout:    30 * time.Second,
+				K8sForward: "postgres-0",
+			},
+			expectErr: true,
+		},
+		{
+			name: "cloudsql instance",
+			flags: Flags{
+				URL:      "postgres://pghealth@localhost/app",
+				Timeout:  30 * time.Second,
+				CloudSQL: "proj:europe-west1:main",
+				GCPIAM:   true,
+			},
+			expectErr: false,
+		},
+		{
+			name: "invalid alloydb instance",
+			flags: Flags{
+				URL:     "postgres://localhost/test",
+				Timeout: 30 * time.Second,
+				AlloyDB: "proj:europe-west1:main",
+			},
+			expectErr: true,
+		},
+		{
+			name: "unknown auth method",
+			flags: Flags{
+				URL:     "postgres://localhost/test",
+				Timeout: 30 * time.Second,
+				Auth:    "kerberos",
+			},
+			expectErr: true,
+		},
+		{
+			name: "url from secret manager",
+			flags: Flags{
+				URLFrom: "aws-sm://prod/pghealth",
+				Timeout: 30 * time.Second,
+			},
+			expectErr: false,
+		},
+		{
+			name: "soft deadline not below timeout",
+			flags: Flags{
+				URL:          "postgres://localhost/test",
+				Timeout:      30 * time.Second,
+				SoftDeadline: 30 * time.Second,
+			},
+			expectErr: true,
+		},
+		{
+			name: "too many top queries",
+			flags: Flags{
+				URL:        "postgres://localhost/test",
+				Timeout:    30 * time.Second,
+				TopQueries: collect.MaxTopQueries + 1,
+			},
+			expectErr: true,
+		},
+		{
+			name: "delta not below timeout",
+			flags: Flags{
+				URL:     "postgres://localhost/test",
+				Timeout: 30 * time.Second,
+				Delta:   time.Minute,
+			},
+			expectErr: true,
+		},
+		{
+			name: "unknown collector timeout",
+			flags: Flags{
+				URL:              "postgres://localhost/test",
+				Timeout:          30 * time.Second,
+				CollectorTimeout: "tablez=1m",
+			},
+			expectErr: true,
+		},
+		{
+			name: "invalid replica url",
+			flags: Flags{
+				URL:        "postgres://localhost/test",
+				ReplicaURL: "postgres://%zz",
+				Timeout:    30 * time.Second,
+			},
+			expectErr: true,
+		},
+		{
+			name: "too many retries",
+			flags: Flags{
+				URL:     "postgres://localhost/test",
+				Timeout: 30 * time.Second,
+				Retries: 11,
+			},
+			expectErr: true,
+		},
 		{
 			name: "zero timeout",
 			flags: Flags{
@@ -215,6 +348,142 @@ func TestFlagsValidate(t *testing.T) {
 			},
 			expectErr: true,
 		},
+		{
+			name: "github summary format",
+			flags: Flags{
+				URL:     "postgres://localhost/test",
+				Timeout: 30 * time.Second,
+				Format:  "github-summary",
+				FailOn:  "warn",
+			},
+			expectErr: false,
+		},
+		{
+			name: "json format",
+			flags: Flags{
+				URL:     "postgres://localhost/test",
+				Timeout: 30 * time.Second,
+				Format:  "json",
+			},
+			expectErr: false,
+		},
+		{
+			name: "history with archive",
+			flags: Flags{
+				URL:     "postgres://localhost/test",
+				Timeout: 30 * time.Second,
+				Archive: "pghealth.db",
+				History: 10,
+			},
+			expectErr: false,
+		},
+		{
+			name: "history without archive",
+			flags: Flags{
+				URL:     "postgres://localhost/test",
+				Timeout: 30 * time.Second,
+				History: 10,
+			},
+			expectErr: true,
+		},
+		{
+			name: "runbook template",
+			flags: Flags{
+				URL:     "postgres://localhost/test",
+				Timeout: 30 * time.Second,
+				Runbook: "https://wiki.example.com/pg/{code}",
+			},
+			expectErr: false,
+		},
+		{
+			name: "runbook template without scheme",
+			flags: Flags{
+				URL:     "postgres://localhost/test",
+				Timeout: 30 * time.Second,
+				Runbook: "wiki/pg/{code}",
+			},
+			expectErr: true,
+		},
+		{
+			name: "snapshot input without URL",
+			flags: Flags{
+				Input:   "snapshot.json",
+				Timeout: 30 * time.Second,
+			},
+			expectErr: false,
+		},
+		{
+			name: "unknown format",
+			flags: Flags{
+				URL:     "postgres://localhost/test",
+				Timeout: 30 * time.Second,
+				Format:  "pdf",
+			},
+			expectErr: true,
+		},
+		{
+			name: "statement timeout above timeout",
+			flags: Flags{
+				URL:              "postgres://localhost/test",
+				Timeout:          30 * time.Second,
+				StatementTimeout: time.Minute,
+			},
+			expectErr: true,
+		},
+		{
+			name: "negative query delay",
+			flags: Flags{
+				URL:        "postgres://localhost/test",
+				Timeout:    30 * time.Second,
+				QueryDelay: -time.Second,
+			},
+			expectErr: true,
+		},
+		{
+			name: "digest without archive",
+			flags: Flags{
+				URL:     "postgres://localhost/test",
+				Timeout: 30 * time.Second,
+				Digest:  "-",
+			},
+			expectErr: true,
+		},
+		{
+			name: "invalid disk size",
+			flags: Flags{
+				URL:      "postgres://localhost/test",
+				Timeout:  30 * time.Second,
+				DiskSize: "lots",
+			},
+			expectErr: true,
+		},
+		{
+			name: "disk size with targets",
+			flags: Flags{
+				Targets:  "targets.yaml",
+				Timeout:  30 * time.Second,
+				DiskSize: "500GB",
+			},
+			expectErr: true,
+		},
+		{
+			name: "unknown issues tracker",
+			flags: Flags{
+				URL:     "postgres://localhost/test",
+				Timeout: 30 * time.Second,
+				Issues:  "gitlab:group/project",
+			},
+			expectErr: true,
+		},
+		{
+			name: "unknown fail-on",
+			flags: Flags{
+				URL:     "postgres://localhost/test",
+				Timeout: 30 * time.Second,
+				FailOn:  "error",
+			},
+			expectErr: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -227,6 +496,118 @@ func TestFlagsValidate(t *testing.T) {
 	}
 }
 
+// TestParseCollectorTimeouts verifies -collector-timeout parsing.
+func TestParseCollectorTimeouts(t *testing.T) {
+	got, err := parseCollectorTimeouts(" tables=2m, plans = 30s ")
+	if err != nil || got["tables"] != 2*time.Minute || got["plans"] != 30*time.Second || len(got) != 2 {
+		t.Errorf("parseCollectorTimeouts() = %v, %v", got, err)
+	}
+	for _, bad := range []string{"tables", "tables=soon", "tables=-1s", "nope=1s"} {
+		if _, err := parseCollectorTimeouts(bad); err == nil {
+			t.Errorf("parseCollectorTimeouts(%q) should fail", bad)
+		}
+	}
+}
+
+// TestParseDiskSize verifies -disk-size parsing.
+func TestParseDiskSize(t *testing.T) {
+	for in, want := range map[string]int64{
+		"500GB":   500 << 30,
+		"1.5tib":  3 << 39,
+		" 2048mb": 2 << 30,
+		"4096":    4096,
+		"10 kB":   10 << 10,
+	} {
+		if got, err := parseDiskSize(in); err != nil || got != want {
+			t.Errorf("parseDiskSize(%q) = %d, %v; want %d", in, got, err, want)
+		}
+	}
+	for _, bad := range []string{"", "GB", "-1GB", "0", "ten GB", "5PB"} {
+		if _, err := parseDiskSize(bad); err == nil {
+			t.Errorf("parseDiskSize(%q) should fail", bad)
+		}
+	}
+}
+
+// TestFailOnExitCode verifies the -fail-on threshold mapping.
+func TestFailOnExitCode(t *testing.T) {
+	warn := analyze.Finding{Title: "w", Severity: analyze.SeverityWarning}
+	rec := analyze.Finding{Title: "r", Severity: analyze.SeverityRec}
+
+	tests := []struct {
+		name     string
+		failOn   string
+		analysis analyze.Analysis
+		expected int
+	}{
+		{"none ignores warnings", "none", analyze.Analysis{Warnings: []analyze.Finding{warn}}, exitSuccess},
+		{"warn with warning", "warn", analyze.Analysis{Warnings: []analyze.Finding{warn}}, exitFindings},
+		{"warn ignores recs", "warn", analyze.Analysis{Recommendations: []analyze.Finding{rec}}, exitSuccess},
+		{"rec with rec", "rec", analyze.Analysis{Recommendations: []analyze.Finding{rec}}, exitFindings},
+		{"rec clean", "rec", analyze.Analysis{}, exitSuccess},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := failOnExitCode(tt.failOn, tt.analysis); got != tt.expected {
+				t.Errorf("failOnExitCode(%q) = %d, expected %d", tt.failOn, got, tt.expected)
+			}
+		})
+	}
+}
+
+// TestFilterSuppressedQueries verifies queryid suppressions across top lists.
+func TestFilterSuppressedQueries(t *testing.T) {
+	var res collect.Result
+	res.Statements.TopByTotalTime = []collect.Statement{{QueryID: 1}, {QueryID: 2}, {Query: "no id"}}
+	res.Statements.TopByCalls = []collect.Statement{{QueryID: 2}}
+
+	got := filterSuppressedQueries(res, "cache-overall, queryid:2,queryid:bad")
+	if len(got.Statements.TopByTotalTime) != 2 || got.Statements.TopByTotalTime[0].QueryID != 1 {
+		t.Errorf("TopByTotalTime = %+v", got.Statements.TopByTotalTime)
+	}
+	if len(got.Statements.TopByCalls) != 0 {
+		t.Errorf("TopByCalls = %+v, expected empty", got.Statements.TopByCalls)
+	}
+	if same := filterSuppressedQueries(res, "cache-overall"); len(same.Statements.TopByTotalTime) != 3 {
+		t.Error("codes without queryid prefix must not filter queries")
+	}
+}
+
+// TestFilterIgnoredQueries verifies -ignore-query patterns and the
+// maintenance keyword drop statements by their text from all lists.
+func TestFilterIgnoredQueries(t *testing.T) {
+	var res collect.Result
+	res.Statements.TopByTotalTime = []collect.Statement{
+		{QueryID: 1, Query: "select * from orders where id = $1"},
+		{QueryID: 2, Query: "COPY public.orders (id, total) TO stdout;"},
+		{QueryID: 3, Query: "vacuum analyze public.orders"},
+		{QueryID: 4, Query: "select count(*) from audit_log"},
+	}
+	res.Statements.TopByCalls = []collect.Statement{{QueryID: 3, Query: "vacuum analyze public.orders"}}
+	res.Delta = &collect.DeltaStats{Statements: &collect.StatementWindow{Statements: []collect.StatementDelta{{QueryID: 2, Query: "COPY public.orders (id, total) TO stdout;"}}}}
+
+	var f ignoreQueryFlag
+	if err := f.Set("("); err == nil {
+		t.Error("Set() accepted an invalid pattern")
+	}
+	for _, v := range []string{ignoreMaintenance, `audit_log`} {
+		if err := f.Set(v); err != nil {
+			t.Fatalf("Set(%q) error = %v", v, err)
+		}
+	}
+	got := filterIgnoredQueries(res, f.patterns())
+	if len(got.Statements.TopByTotalTime) != 1 || got.Statements.TopByTotalTime[0].QueryID != 1 || len(got.Statements.TopByCalls) != 0 {
+		t.Errorf("statements = %+v", got.Statements)
+	}
+	if got.Statements.Ignored != 3 || len(got.Delta.Statements.Statements) != 0 {
+		t.Errorf("ignored = %d, delta = %+v", got.Statements.Ignored, got.Delta.Statements)
+	}
+	if len(res.Delta.Statements.Statements) != 1 {
+		t.Error("filterIgnoredQueries() modified the delta of its input")
+	}
+}
+
 // TestResolveOutputPath verifies output path resolution.
 func TestResolveOutputPath(t *testing.T) {
 	testTime := time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC)
@@ -269,3 +650,21 @@ func BenchmarkParseSuppressedSet(b *testing.B) {
 		parseSuppressedSet(input)
 	}
 }
+
+// TestLabelFlag verifies parsing and validation of -label key=value pairs.
+func TestLabelFlag(t *testing.T) {
+	l := labelFlag{}
+	for _, v := range []string{"env=prod", "team=payments", "note=a=b", "env=staging"} {
+		if err := l.Set(v); err != nil {
+			t.Fatalf("Set(%q): %v", v, err)
+		}
+	}
+	if got, want := l.String(), "env=staging,note=a=b,team=payments"; got != want {
+		t.Errorf("labels = %q, want %q", got, want)
+	}
+	for _, v := range []string{"env", "=prod", "1env=x", "env-name=x"} {
+		if err := l.Set(v); err == nil {
+			t.Errorf("Set(%q): want an error", v)
+		}
+	}
+}